@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Muneer320/RhinoBox/internal/config"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+)
+
+const usage = `usage:
+  rhinobox [serve]
+  rhinobox backup -o FILE [-base PREVIOUS] [-url URL [-key KEY] | -data DIR]
+  rhinobox restore [-data DIR] FULL [INCREMENTAL...]
+`
+
+// runCommand runs a maintenance subcommand and returns the exit code.
+func runCommand(name string, args []string) int {
+	var err error
+	switch name {
+	case "backup":
+		err = runBackup(args)
+	case "restore":
+		err = runRestore(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s", name, usage)
+		return 2
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rhinobox %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// runBackup writes a snapshot. With -url it asks a running server for one,
+// which holds writes back while it is taken; otherwise it reads the data
+// directory directly, which only works while the server is stopped.
+func runBackup(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	output := fs.String("o", "", "file to write the snapshot to")
+	basePath := fs.String("base", "", "earlier snapshot to take an incremental snapshot against")
+	serverURL := fs.String("url", "", "URL of a running server to take an online snapshot from")
+	apiKey := fs.String("key", os.Getenv("RHINOBOX_API_KEY"), "admin API key for -url (default $RHINOBOX_API_KEY)")
+	dataDir := fs.String("data", cfg.DataDir, "data directory for offline snapshots")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	var base *storage.BackupManifest
+	if *basePath != "" {
+		f, err := os.Open(*basePath)
+		if err != nil {
+			return err
+		}
+		base, err = storage.ReadBackupManifest(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("read base snapshot: %w", err)
+		}
+	}
+
+	out, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if *serverURL != "" {
+		err = fetchBackup(out, *serverURL, *apiKey, base)
+	} else {
+		var manifest *storage.BackupManifest
+		manifest, err = storage.WriteBackup(out, *dataDir, storage.BackupOptions{Base: base})
+		if err == nil {
+			files, size := manifest.Included()
+			fmt.Printf("snapshot %s: %d files, %d bytes, %d unchanged\n", manifest.ID, files, size, len(manifest.Files)-files)
+		} else if strings.Contains(err.Error(), "directory lock") {
+			err = fmt.Errorf("%w (is the server running? take online snapshots with -url)", err)
+		}
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*output)
+	}
+	return err
+}
+
+// fetchBackup downloads a snapshot from POST /admin/backup into out.
+func fetchBackup(out io.Writer, serverURL, apiKey string, base *storage.BackupManifest) error {
+	var body io.Reader = http.NoBody
+	if base != nil {
+		data, err := json.Marshal(base)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(serverURL, "/")+"/admin/backup", body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	n, err := io.Copy(out, resp.Body)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("snapshot written: %d bytes\n", n)
+	return nil
+}
+
+// runRestore rebuilds a data directory from a full snapshot and the
+// incremental snapshots taken after it, in order.
+func runRestore(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	dataDir := fs.String("data", cfg.DataDir, "empty data directory to restore into")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	archives := make([]io.Reader, 0, fs.NArg())
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		archives = append(archives, f)
+	}
+	manifest, err := storage.RestoreBackup(*dataDir, archives...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored snapshot %s (%s) into %s: %d files\n", manifest.ID, manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"), *dataDir, len(manifest.Files))
+	return nil
+}
@@ -16,6 +16,10 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] != "serve" {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		panic(err)
@@ -79,15 +83,15 @@ func main() {
 	case <-ctx.Done():
 		logger.Info("shutting down gracefully...")
 		
-		// Stop job queue first (commented out - method exists but may not be needed)
-		// srv.Stop()
-		
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 		if err := server.Shutdown(shutdownCtx); err != nil {
 			logger.Error("shutdown error", slog.Any("err", err))
 			os.Exit(1)
 		}
+
+		// Release the index store once in-flight requests have drained
+		srv.Stop()
 		logger.Info("server stopped")
 	case err := <-errCh:
 		if err != nil && err != http.ErrServerClosed {
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/jsonquery"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// maxArchiveHashes bounds the hash list of one archive request.
+const maxArchiveHashes = 10000
+
+// Page sizes of GET /files/{file_id}/archive/entries.
+const (
+	defaultArchiveEntriesLimit = 1000
+	maxArchiveEntriesLimit     = 10000
+)
+
+// archiveRequest is the body of POST /files/archive. Exactly one of hashes,
+// search and category selects the files.
+type archiveRequest struct {
+	Hashes []string `json:"hashes,omitempty"`
+	// Search takes /files/search parameters, such as {"q": "type:image"}.
+	Search map[string]string `json:"search,omitempty"`
+	// Category selects a category and everything below it, e.g. "images".
+	Category string `json:"category,omitempty"`
+	Format   string `json:"format,omitempty"`
+	Manifest bool   `json:"manifest,omitempty"`
+	// Name is the archive file name offered to the client, without extension.
+	Name string `json:"name,omitempty"`
+}
+
+// selectArchiveFiles resolves the selection of req to file metadata: in
+// request order for hashes, rank order for content searches and upload
+// order otherwise.
+func selectArchiveFiles(store *storage.Manager, req archiveRequest) ([]storage.FileMetadata, error) {
+	sources := 0
+	for _, set := range []bool{len(req.Hashes) > 0, req.Search != nil, strings.TrimSpace(req.Category) != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, apierrors.BadRequest("exactly one of hashes, search or category is required")
+	}
+
+	var files []storage.FileMetadata
+	switch {
+	case len(req.Hashes) > 0:
+		if len(req.Hashes) > maxArchiveHashes {
+			return nil, apierrors.BadRequestf("at most %d hashes per archive", maxArchiveHashes)
+		}
+		seen := make(map[string]bool, len(req.Hashes))
+		for _, hash := range req.Hashes {
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			meta, err := store.GetFileMetadata(hash)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, *meta)
+		}
+		return files, nil
+	case req.Search != nil:
+		search, err := parseFileSearch(savedSearchValues(req.Search))
+		if err != nil {
+			return nil, err
+		}
+		if files, _, err = search.run(store); err != nil || search.ranked {
+			return files, err
+		}
+	default:
+		files = store.FindByCategoryPrefix(strings.Trim(strings.TrimSpace(req.Category), "/"))
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].UploadedAt.Before(files[j].UploadedAt) })
+	return files, nil
+}
+
+// handleFileArchive handles POST /files/archive. It streams the selected
+// files as a zip or tar.gz built on the fly, so the response has no
+// Content-Length and a failure part way through truncates the archive.
+func (s *Server) handleFileArchive(w http.ResponseWriter, r *http.Request) {
+	var req archiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+	format, err := storage.ParseArchiveFormat(req.Format)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	store := s.tenant(r).storage
+	files, err := selectArchiveFiles(store, req)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	if len(files) == 0 {
+		s.handleError(w, r, apierrors.NotFound("no files match the selection"))
+		return
+	}
+
+	name := archiveFileName(req.Name)
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s%s"`, name, format.Extension()))
+	w.Header().Set("X-Archive-File-Count", fmt.Sprintf("%d", len(files)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	start := time.Now()
+	summary, err := store.WriteArchive(w, files, storage.ArchiveOptions{Format: format, Manifest: req.Manifest})
+	if err != nil {
+		s.logger.Warn("archive download aborted", slog.String("archive", name), slog.Any("err", err))
+		return
+	}
+	s.logger.Info("archive downloaded",
+		slog.String("archive", name),
+		slog.String("format", string(format)),
+		slog.Int("files", summary.Files),
+		slog.Int("missing", len(summary.Missing)),
+		slog.Int64("bytes", summary.Bytes),
+		slog.Duration("duration", time.Since(start)),
+	)
+}
+
+// archiveFileName returns a safe download name for an archive.
+func archiveFileName(requested string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '"' || r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(requested))
+	if name == "" {
+		return "rhinobox-" + time.Now().UTC().Format("20060102-150405")
+	}
+	return name
+}
+
+// ArchiveResult reports an archive unpacked by /ingest with expand=true.
+type ArchiveResult struct {
+	OriginalName  string                 `json:"original_name"`
+	Format        string                 `json:"format"`
+	Entries       []ArchiveEntryResult   `json:"entries"`
+	Skipped       []storage.SkippedEntry `json:"skipped,omitempty"`
+	ExpandedBytes int64                  `json:"expanded_bytes"`
+	// Error is set when unpacking stopped part way, e.g. at a limit.
+	Error string `json:"error,omitempty"`
+}
+
+// ArchiveEntryResult is one stored member of an unpacked archive.
+type ArchiveEntryResult struct {
+	ArchivePath  string `json:"archive_path"`
+	OriginalName string `json:"original_name"`
+	StoredPath   string `json:"stored_path"`
+	Category     string `json:"category"`
+	MimeType     string `json:"mime_type"`
+	Size         int64  `json:"size"`
+	Hash         string `json:"hash"`
+	Duplicate    bool   `json:"duplicate"`
+}
+
+// archiveLimits returns the configured archive expansion limits; zero
+// values fall back to storage.DefaultArchiveLimits.
+func (s *Server) archiveLimits() storage.ArchiveLimits {
+	return storage.ArchiveLimits{
+		MaxEntries:    s.cfg.ArchiveMaxEntries,
+		MaxTotalBytes: s.cfg.ArchiveMaxBytes,
+		MaxRatio:      float64(s.cfg.ArchiveMaxRatio),
+		MaxDepth:      s.cfg.ArchiveMaxDepth,
+	}
+}
+
+// expandArchive unpacks an uploaded archive into individual files. It
+// reports ok=false with no error for uploads that are not archives it can
+// unpack, which are then ingested as they are.
+func (s *Server) expandArchive(ts *tenantScope, header *multipart.FileHeader, comment, namespace, overrideType string) (ArchiveResult, bool, error) {
+	file, err := header.Open()
+	if err != nil {
+		return ArchiveResult{}, false, fmt.Errorf("open file: %w", err)
+	}
+	defer file.Close()
+	if _, ok := storage.DetectArchiveFormat(file); !ok {
+		return ArchiveResult{}, false, nil
+	}
+
+	metadata := map[string]string{}
+	if comment != "" {
+		metadata["comment"] = comment
+	}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	categoryHint := ""
+	if overrideType != "" && overrideType != "auto" {
+		categoryHint = overrideType
+	}
+	expanded, err := ts.storage.ExpandArchive(storage.ExpandArchiveRequest{
+		Reader:       file,
+		Size:         header.Size,
+		Filename:     header.Filename,
+		Metadata:     metadata,
+		CategoryHint: categoryHint,
+		Limits:       s.archiveLimits(),
+	})
+	if err != nil && (expanded == nil || len(expanded.Entries) == 0) {
+		if errors.Is(err, storage.ErrUnsupportedArchive) {
+			return ArchiveResult{}, false, nil
+		}
+		return ArchiveResult{}, false, err
+	}
+
+	result := ArchiveResult{
+		OriginalName:  header.Filename,
+		Format:        string(expanded.Format),
+		Entries:       make([]ArchiveEntryResult, 0, len(expanded.Entries)),
+		Skipped:       expanded.Skipped,
+		ExpandedBytes: expanded.ExpandedBytes,
+	}
+	for _, entry := range expanded.Entries {
+		meta := entry.Result.Metadata
+		result.Entries = append(result.Entries, ArchiveEntryResult{
+			ArchivePath:  entry.Path,
+			OriginalName: meta.OriginalName,
+			StoredPath:   meta.StoredPath,
+			Category:     meta.Category,
+			MimeType:     meta.MimeType,
+			Size:         meta.Size,
+			Hash:         meta.Hash,
+			Duplicate:    entry.Result.Duplicate,
+		})
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	s.logger.Info("archive expanded",
+		slog.String("archive", header.Filename),
+		slog.String("format", result.Format),
+		slog.Int("entries", len(result.Entries)),
+		slog.Int("skipped", len(result.Skipped)),
+		slog.Int64("expanded_bytes", result.ExpandedBytes),
+	)
+	return result, true, err
+}
+
+// archiveEntriesPage is a page of archive members and the cursor of the
+// next one.
+type archiveEntriesPage struct {
+	*storage.ArchiveListing
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// handleArchiveEntries handles GET /files/{file_id}/archive/entries. It
+// lists the members of a stored zip, tar, tar.gz or tar.zst file, limit at
+// a time; cursor continues from an earlier page. Members past the
+// configured archive entry limit are not read.
+func (s *Server) handleArchiveEntries(w http.ResponseWriter, r *http.Request) {
+	opts := storage.ArchiveListOptions{Limit: defaultArchiveEntriesLimit, MaxEntries: s.archiveLimits().MaxEntries}
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxArchiveEntriesLimit {
+			s.handleError(w, r, apierrors.BadRequestf("limit must be between 1 and %d", maxArchiveEntriesLimit))
+			return
+		}
+		opts.Limit = limit
+	}
+	if raw := r.URL.Query().Get("cursor"); raw != "" {
+		offset, err := jsonquery.DecodeCursor(raw)
+		if err != nil {
+			s.handleError(w, r, apierrors.BadRequest(err.Error()))
+			return
+		}
+		opts.Offset = offset
+	}
+
+	listing, err := s.tenant(r).storage.ListArchiveEntries(chi.URLParam(r, "file_id"), opts)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	page := archiveEntriesPage{ArchiveListing: listing}
+	if next := opts.Offset + listing.Count; listing.Count > 0 && next < listing.Total {
+		page.NextCursor = jsonquery.EncodeCursor(next)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleArchiveEntry handles GET /files/{file_id}/archive/entry?path=. It
+// streams one member of a stored archive as an attachment.
+func (s *Server) handleArchiveEntry(w http.ResponseWriter, r *http.Request) {
+	entryPath := r.URL.Query().Get("path")
+	if entryPath == "" {
+		s.handleError(w, r, apierrors.BadRequest("path query parameter is required"))
+		return
+	}
+	entry, err := s.tenant(r).storage.OpenArchiveEntry(chi.URLParam(r, "file_id"), entryPath)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	defer entry.Close()
+
+	body := bufio.NewReaderSize(entry, 512)
+	name := path.Base(entry.Entry.Path)
+	mimeType := mime.TypeByExtension(strings.ToLower(path.Ext(name)))
+	if mimeType == "" {
+		sniff, _ := body.Peek(512)
+		mimeType = http.DetectContentType(sniff)
+	}
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Length", strconv.FormatInt(entry.Entry.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Last-Modified", entry.Entry.Modified.Format(http.TimeFormat))
+	w.Header().Set("X-Archive-Hash", entry.Archive.Hash)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, body); err != nil {
+		s.logger.Warn("failed to copy archive entry to response", slog.String("path", entryPath), slog.Any("err", err))
+	}
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Muneer320/RhinoBox/internal/storage"
+)
+
+func TestFileArchive(t *testing.T) {
+	srv := newTestServer(t)
+	store := func(name, mimeType, content string) string {
+		t.Helper()
+		res, err := srv.storage.StoreFile(storage.StoreRequest{Reader: strings.NewReader(content), Filename: name, MimeType: mimeType, Size: int64(len(content))})
+		if err != nil {
+			t.Fatalf("store %s: %v", name, err)
+		}
+		return res.Metadata.Hash
+	}
+	doc := store("plan.txt", "text/plain", "launch plan")
+	store("plan.md", "text/markdown", "# another plan")
+	img := store("logo.png", "image/png", "\x89PNG logo")
+
+	archive := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/files/archive", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, req)
+		return resp
+	}
+	entries := func(resp *httptest.ResponseRecorder) map[string]string {
+		t.Helper()
+		zr, err := zip.NewReader(bytes.NewReader(resp.Body.Bytes()), int64(resp.Body.Len()))
+		if err != nil {
+			t.Fatalf("open zip: %v", err)
+		}
+		contents := map[string]string{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("open %s: %v", f.Name, err)
+			}
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			contents[f.Name] = string(data)
+		}
+		return contents
+	}
+
+	resp := archive(`{"hashes":["` + doc + `","` + img + `"],"manifest":true,"name":"export"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("archive by hashes: %d %s", resp.Code, resp.Body.String())
+	}
+	if got := resp.Header().Get("Content-Disposition"); got != `attachment; filename="export.zip"` {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+	contents := entries(resp)
+	if contents["plan.txt"] != "launch plan" || contents["logo.png"] != "\x89PNG logo" {
+		t.Fatalf("unexpected entries %v", contents)
+	}
+	var manifest storage.ArchiveManifest
+	if err := json.Unmarshal([]byte(contents["manifest.json"]), &manifest); err != nil || manifest.Count != 2 || manifest.Files[0].Hash != doc {
+		t.Fatalf("unexpected manifest %+v %v", manifest, err)
+	}
+
+	resp = archive(`{"search":{"extension":"png"}}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("archive by search: %d %s", resp.Code, resp.Body.String())
+	}
+	if contents := entries(resp); len(contents) != 1 || contents["logo.png"] == "" {
+		t.Fatalf("unexpected search entries %v", contents)
+	}
+
+	resp = archive(`{"category":"documents","format":"tar.gz"}`)
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("archive by category: %d %s", resp.Code, resp.Header().Get("Content-Type"))
+	}
+	if got := resp.Header().Get("X-Archive-File-Count"); got != "2" {
+		t.Errorf("expected both documents, got %s", got)
+	}
+
+	for body, want := range map[string]int{
+		`{}`: http.StatusBadRequest,
+		`{"hashes":["` + doc + `"],"category":"images"}`: http.StatusBadRequest,
+		`{"hashes":["` + doc + `"],"format":"rar"}`:      http.StatusBadRequest,
+		`{"hashes":["nope"]}`:                            http.StatusNotFound,
+		`{"category":"videos"}`:                          http.StatusNotFound,
+	} {
+		if resp := archive(body); resp.Code != want {
+			t.Errorf("%s: expected %d, got %d %s", body, want, resp.Code, resp.Body.String())
+		}
+	}
+}
+
+func TestUnifiedIngestExpandArchive(t *testing.T) {
+	srv := newTestServer(t)
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, content := range map[string]string{"trip/beach.png": "\x89PNG\r\n\x1a\n beach", "trip/notes.txt": "sunny"} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	ingest := func(expand string, content []byte) *httptest.ResponseRecorder {
+		t.Helper()
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("files", "trip.zip")
+		part.Write(content)
+		if expand != "" {
+			writer.WriteField("expand", expand)
+		}
+		writer.WriteField("namespace", "holidays")
+		writer.Close()
+		req := httptest.NewRequest(http.MethodPost, "/ingest", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := ingest("true", archive.Bytes())
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expand: %d %s", resp.Code, resp.Body.String())
+	}
+	var response UnifiedIngestResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	archives := response.Results.Archives
+	if len(archives) != 1 || archives[0].Format != "zip" || len(archives[0].Entries) != 2 || len(response.Results.Files) != 0 {
+		t.Fatalf("unexpected results %+v", response.Results)
+	}
+	for _, entry := range archives[0].Entries {
+		meta, err := srv.storage.GetFileMetadata(entry.Hash)
+		if err != nil {
+			t.Fatalf("metadata %s: %v", entry.ArchivePath, err)
+		}
+		if meta.Metadata["archive_path"] != entry.ArchivePath || meta.Metadata["namespace"] != "holidays" {
+			t.Errorf("unexpected metadata %v", meta.Metadata)
+		}
+		if entry.ArchivePath == "trip/beach.png" && !strings.HasPrefix(entry.Category, "images") {
+			t.Errorf("expected the png to be classified as an image, got %s", entry.Category)
+		}
+	}
+
+	// Without expand the archive is stored as one file.
+	resp = ingest("", archive.Bytes())
+	response = UnifiedIngestResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("ingest without expand: %d %v", resp.Code, err)
+	}
+	if len(response.Results.Files) != 1 || response.Results.Files[0].OriginalName != "trip.zip" || len(response.Results.Archives) != 0 {
+		t.Fatalf("expected the archive to be kept packed, got %+v", response.Results)
+	}
+	if resp := ingest("sometimes", archive.Bytes()); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid expand value, got %d", resp.Code)
+	}
+}
+
+func TestArchiveBrowsing(t *testing.T) {
+	srv := newTestServer(t)
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, _ := zw.Create("reports/q3.csv")
+	w.Write([]byte("region,total\nnorth,42\n"))
+	zw.Close()
+	stored, err := srv.storage.StoreFile(storage.StoreRequest{Reader: bytes.NewReader(archive.Bytes()), Filename: "reports.zip", MimeType: "application/zip", Size: int64(archive.Len())})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	hash := stored.Metadata.Hash
+
+	get := func(path string) *httptest.ResponseRecorder {
+		t.Helper()
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		return resp
+	}
+
+	resp := get("/files/" + hash + "/archive/entries")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("entries: %d %s", resp.Code, resp.Body.String())
+	}
+	var listing storage.ArchiveListing
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil || listing.Count != 1 || listing.Entries[0].Path != "reports/q3.csv" {
+		t.Fatalf("unexpected listing %+v %v", listing, err)
+	}
+
+	resp = get("/files/" + hash + "/archive/entry?path=reports/q3.csv")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("entry: %d %s", resp.Code, resp.Body.String())
+	}
+	if resp.Body.String() != "region,total\nnorth,42\n" || resp.Header().Get("Content-Disposition") != `attachment; filename=q3.csv` {
+		t.Fatalf("unexpected entry %q %v", resp.Body.String(), resp.Header())
+	}
+
+	if resp := get("/files/" + hash + "/archive/entry?path=nope.txt"); resp.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing entry, got %d", resp.Code)
+	}
+	if resp := get("/files/" + hash + "/archive/entry"); resp.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a path, got %d", resp.Code)
+	}
+	text, _ := srv.storage.StoreFile(storage.StoreRequest{Reader: strings.NewReader("plain"), Filename: "plain.txt", MimeType: "text/plain", Size: 5})
+	if resp := get("/files/" + text.Metadata.Hash + "/archive/entries"); resp.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415 for a file that is not an archive, got %d", resp.Code)
+	}
+}
+
+func TestArchiveEntriesPagination(t *testing.T) {
+	srv := newTestServer(t)
+	srv.cfg.ArchiveMaxEntries = 4
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(name))
+	}
+	zw.Close()
+	stored, err := srv.storage.StoreFile(storage.StoreRequest{Reader: bytes.NewReader(archive.Bytes()), Filename: "letters.zip", MimeType: "application/zip", Size: int64(archive.Len())})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	list := func(query string) archiveEntriesPage {
+		t.Helper()
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files/"+stored.Metadata.Hash+"/archive/entries"+query, nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("entries%s: %d %s", query, resp.Code, resp.Body.String())
+		}
+		page := archiveEntriesPage{ArchiveListing: &storage.ArchiveListing{}}
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return page
+	}
+
+	first := list("?limit=2")
+	if first.Count != 2 || first.Entries[0].Path != "a.txt" || first.Total != 4 || !first.Truncated || first.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v (next %q)", first.ArchiveListing, first.NextCursor)
+	}
+	second := list("?limit=2&cursor=" + first.NextCursor)
+	if second.Count != 2 || second.Entries[0].Path != "c.txt" || second.Entries[1].Path != "d.txt" || second.NextCursor != "" {
+		t.Fatalf("unexpected second page %+v (next %q)", second.ArchiveListing, second.NextCursor)
+	}
+
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files/"+stored.Metadata.Hash+"/archive/entries?limit=0", nil))
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for limit=0, got %d", resp.Code)
+	}
+}
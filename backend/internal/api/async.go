@@ -2,11 +2,18 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 
 	"github.com/Muneer320/RhinoBox/internal/queue"
+	"github.com/Muneer320/RhinoBox/internal/storage"
 	chi "github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
@@ -18,53 +25,14 @@ func (s *Server) handleAsyncIngest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	namespace := r.FormValue("namespace")
-	comment := r.FormValue("comment")
-
-	// Create job items from files
-	items := []queue.JobItem{}
-	if r.MultipartForm != nil && r.MultipartForm.File != nil {
-		for _, fileHeaders := range r.MultipartForm.File {
-			for _, fh := range fileHeaders {
-				items = append(items, queue.JobItem{
-					ID:   uuid.NewString(),
-					Type: "file",
-					Name: fh.Filename,
-					Size: fh.Size,
-					Data: fh,
-				})
-			}
-		}
-	}
-
-	if len(items) == 0 {
-		httpError(w, http.StatusBadRequest, "no files provided")
-		return
-	}
-
-	// Create job
 	job := &queue.Job{
 		ID:        uuid.NewString(),
 		Type:      queue.JobTypeBatch,
-		Items:     items,
-		Namespace: namespace,
-		Comment:   comment,
+		Namespace: r.FormValue("namespace"),
+		Tenant:    s.tenant(r).id,
+		Comment:   r.FormValue("comment"),
 	}
-
-	// Enqueue job
-	if err := s.jobQueue.Enqueue(job); err != nil {
-		httpError(w, http.StatusInternalServerError, fmt.Sprintf("failed to queue job: %v", err))
-		return
-	}
-
-	// Return job ID immediately
-	writeJSON(w, http.StatusAccepted, map[string]interface{}{
-		"job_id":           job.ID,
-		"status":           string(job.Status),
-		"total_items":      len(items),
-		"check_status_url": fmt.Sprintf("/jobs/%s", job.ID),
-		"created_at":       job.CreatedAt,
-	})
+	s.enqueueUploads(w, r, job, "file", "")
 }
 
 // handleMediaIngestAsync queues media files for async processing
@@ -74,40 +42,55 @@ func (s *Server) handleMediaIngestAsync(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	category := r.FormValue("category")
-	namespace := r.FormValue("namespace")
-
-	items := []queue.JobItem{}
-	if r.MultipartForm != nil && r.MultipartForm.File != nil {
-		for _, fileHeaders := range r.MultipartForm.File {
-			for _, fh := range fileHeaders {
-				items = append(items, queue.JobItem{
-					ID:   uuid.NewString(),
-					Type: "media",
-					Name: fh.Filename,
-					Size: fh.Size,
-					Data: fh,
-					Metadata: map[string]interface{}{
-						"category": category,
-					},
-				})
-			}
-		}
+	job := &queue.Job{
+		ID:        uuid.NewString(),
+		Type:      queue.JobTypeMedia,
+		Namespace: r.FormValue("namespace"),
+		Tenant:    s.tenant(r).id,
 	}
+	s.enqueueUploads(w, r, job, "media", r.FormValue("category"))
+}
 
-	if len(items) == 0 {
+// enqueueUploads spools every uploaded file into the job's spool directory,
+// so the job no longer depends on the request and survives a restart, then
+// queues it and replies with the job ID.
+func (s *Server) enqueueUploads(w http.ResponseWriter, r *http.Request, job *queue.Job, itemType, category string) {
+	if r.MultipartForm == nil || len(r.MultipartForm.File) == 0 {
 		httpError(w, http.StatusBadRequest, "no files provided")
 		return
 	}
 
-	job := &queue.Job{
-		ID:        uuid.NewString(),
-		Type:      queue.JobTypeMedia,
-		Items:     items,
-		Namespace: namespace,
+	spoolDir, err := s.jobQueue.SpoolDir(job.ID)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, fmt.Sprintf("failed to queue job: %v", err))
+		return
+	}
+	for _, fileHeaders := range r.MultipartForm.File {
+		for _, fh := range fileHeaders {
+			item := queue.JobItem{
+				ID:   uuid.NewString(),
+				Type: itemType,
+				Name: fh.Filename,
+				Size: fh.Size,
+				Metadata: map[string]interface{}{
+					"mime_type": fh.Header.Get("Content-Type"),
+				},
+			}
+			if category != "" {
+				item.Metadata["category"] = category
+			}
+			item.Source = filepath.Join(spoolDir, item.ID)
+			if err := spoolUpload(fh, item.Source); err != nil {
+				_ = os.RemoveAll(spoolDir)
+				httpError(w, http.StatusInternalServerError, fmt.Sprintf("failed to spool %s: %v", fh.Filename, err))
+				return
+			}
+			job.Items = append(job.Items, item)
+		}
 	}
 
 	if err := s.jobQueue.Enqueue(job); err != nil {
+		_ = os.RemoveAll(spoolDir)
 		httpError(w, http.StatusInternalServerError, fmt.Sprintf("failed to queue job: %v", err))
 		return
 	}
@@ -115,12 +98,35 @@ func (s *Server) handleMediaIngestAsync(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusAccepted, map[string]interface{}{
 		"job_id":           job.ID,
 		"status":           string(job.Status),
-		"total_items":      len(items),
+		"total_items":      len(job.Items),
 		"check_status_url": fmt.Sprintf("/jobs/%s", job.ID),
 		"created_at":       job.CreatedAt,
 	})
 }
 
+// spoolUpload copies an uploaded file to path and syncs it to disk.
+func spoolUpload(fh *multipart.FileHeader, path string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
 // handleJSONIngestAsync queues JSON documents for async processing
 func (s *Server) handleJSONIngestAsync(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
@@ -154,6 +160,7 @@ func (s *Server) handleJSONIngestAsync(w http.ResponseWriter, r *http.Request) {
 		Type:      queue.JobTypeJSON,
 		Items:     items,
 		Namespace: payload.Namespace,
+		Tenant:    s.tenant(r).id,
 		Comment:   payload.Comment,
 	}
 
@@ -171,11 +178,30 @@ func (s *Server) handleJSONIngestAsync(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ownsJob reports whether a job queued for tenant belongs to the tenant of
+// r. Jobs queued before tenants existed belong to the default tenant.
+func (s *Server) ownsJob(r *http.Request, tenant string) bool {
+	if tenant == "" {
+		tenant = storage.DefaultTenantID
+	}
+	return tenant == s.tenant(r).id
+}
+
+// tenantJob looks up a job of the requesting tenant. Other tenants' jobs are
+// reported as not found.
+func (s *Server) tenantJob(r *http.Request, jobID string) (*queue.Job, bool) {
+	job, found := s.jobQueue.Get(jobID)
+	if !found || !s.ownsJob(r, job.Tenant) {
+		return nil, false
+	}
+	return job, true
+}
+
 // handleJobStatus returns the current status of a job
 func (s *Server) handleJobStatus(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "job_id")
 
-	job, found := s.jobQueue.Get(jobID)
+	job, found := s.tenantJob(r, jobID)
 	if !found {
 		httpError(w, http.StatusNotFound, "job not found")
 		return
@@ -202,6 +228,16 @@ func (s *Server) handleJobStatus(w http.ResponseWriter, r *http.Request) {
 	if job.Error != "" {
 		response["error"] = job.Error
 	}
+	if job.MaxRetries > 0 {
+		response["retry_count"] = job.RetryCount
+		response["max_retries"] = job.MaxRetries
+	}
+	if job.NextAttemptAt != nil {
+		response["next_attempt_at"] = job.NextAttemptAt
+	}
+	if job.DeadLetteredAt != nil {
+		response["dead_lettered_at"] = job.DeadLetteredAt
+	}
 
 	// Calculate progress percentage
 	if job.Total > 0 {
@@ -215,15 +251,14 @@ func (s *Server) handleJobStatus(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleJobResult(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "job_id")
 
-	result, found := s.jobQueue.GetResult(jobID)
+	job, found := s.tenantJob(r, jobID)
 	if !found {
-		// Try getting job status instead
-		job, jobFound := s.jobQueue.Get(jobID)
-		if !jobFound {
-			httpError(w, http.StatusNotFound, "job not found")
-			return
-		}
+		httpError(w, http.StatusNotFound, "job not found")
+		return
+	}
 
+	result, found := s.jobQueue.GetResult(jobID)
+	if !found {
 		if job.Status != queue.StatusCompleted && job.Status != queue.StatusFailed {
 			httpError(w, http.StatusConflict, fmt.Sprintf("job not completed yet (status: %s)", job.Status))
 			return
@@ -247,7 +282,9 @@ func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	jobs := s.jobQueue.ListJobs()
+	jobs := slices.DeleteFunc(s.jobQueue.ListJobs(), func(job *queue.Job) bool {
+		return !s.ownsJob(r, job.Tenant)
+	})
 
 	// Limit results
 	if len(jobs) > limit {
@@ -280,7 +317,7 @@ func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "job_id")
 
-	job, found := s.jobQueue.Get(jobID)
+	job, found := s.tenantJob(r, jobID)
 	if !found {
 		httpError(w, http.StatusNotFound, "job not found")
 		return
@@ -300,6 +337,73 @@ func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleListDeadLetters lists jobs that exhausted their retries with items
+// still failing
+func (s *Server) handleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	jobs := slices.DeleteFunc(s.jobQueue.DeadLetters(), func(job *queue.Job) bool {
+		return !s.ownsJob(r, job.Tenant)
+	})
+
+	response := make([]map[string]interface{}, len(jobs))
+	for i, job := range jobs {
+		failed := make([]map[string]interface{}, 0)
+		for _, item := range job.Items {
+			if item.Status == queue.StatusFailed {
+				failed = append(failed, map[string]interface{}{
+					"id":    item.ID,
+					"name":  item.Name,
+					"error": item.Error,
+				})
+			}
+		}
+		response[i] = map[string]interface{}{
+			"job_id":           job.ID,
+			"type":             string(job.Type),
+			"status":           string(job.Status),
+			"total":            job.Total,
+			"retry_count":      job.RetryCount,
+			"error":            job.Error,
+			"created_at":       job.CreatedAt,
+			"dead_lettered_at": job.DeadLetteredAt,
+			"failed_items":     failed,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"jobs":  response,
+		"count": len(response),
+	})
+}
+
+// handleReplayJob re-queues the failed items of a dead-lettered job
+func (s *Server) handleReplayJob(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "job_id")
+
+	if _, found := s.tenantJob(r, jobID); !found {
+		httpError(w, http.StatusNotFound, "job not found")
+		return
+	}
+
+	job, err := s.jobQueue.Replay(jobID)
+	switch {
+	case errors.Is(err, queue.ErrJobNotFound):
+		httpError(w, http.StatusNotFound, "job not found")
+		return
+	case errors.Is(err, queue.ErrNotDeadLettered):
+		httpError(w, http.StatusConflict, "job is not in the dead-letter list")
+		return
+	case err != nil:
+		httpError(w, http.StatusInternalServerError, fmt.Sprintf("failed to replay job: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"job_id":           job.ID,
+		"status":           string(queue.StatusQueued),
+		"check_status_url": fmt.Sprintf("/jobs/%s", job.ID),
+	})
+}
+
 // handleJobStats returns queue statistics
 func (s *Server) handleJobStats(w http.ResponseWriter, r *http.Request) {
 	stats := s.jobQueue.Stats()
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAsyncMediaIngestSpoolsAndCompletes(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(srv.Stop)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, content := range map[string]string{"a.txt": "first async file", "b.txt": "second async file"} {
+		part, err := writer.CreateFormFile("files", name)
+		if err != nil {
+			t.Fatalf("create form file: %v", err)
+		}
+		part.Write([]byte(content))
+	}
+	writer.WriteField("category", "notes")
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/ingest/media/async", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var queued struct {
+		JobID      string `json:"job_id"`
+		TotalItems int    `json:"total_items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&queued); err != nil || queued.TotalItems != 2 {
+		t.Fatalf("decode queued job: %v (%+v)", err, queued)
+	}
+
+	var result struct {
+		Succeeded int `json:"succeeded"`
+		Failed    int `json:"failed"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp = httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/jobs/"+queued.JobID+"/result", nil))
+		if resp.Code == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatalf("decode result: %v", err)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job not finished: %d %s", resp.Code, resp.Body.String())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if result.Succeeded != 2 || result.Failed != 0 {
+		t.Fatalf("unexpected job result %+v", result)
+	}
+	if got := srv.storage.FindByOriginalName("b.txt"); len(got) != 1 {
+		t.Fatalf("expected b.txt to be stored, got %d matches", len(got))
+	}
+	if _, err := os.Stat(filepath.Join(srv.cfg.DataDir, "jobs", "spool", queued.JobID)); !os.IsNotExist(err) {
+		t.Fatalf("expected spool directory to be removed after success, got %v", err)
+	}
+}
+
+func TestDeadLetterEndpoints(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(srv.Stop)
+
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/jobs/dead-letter", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("list dead letters: expected 200, got %d", resp.Code)
+	}
+	var listing struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil || listing.Count != 0 {
+		t.Fatalf("expected an empty dead-letter list, got %+v (%v)", listing, err)
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/jobs/dead-letter/unknown/replay", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("replay unknown job: expected 404, got %d", resp.Code)
+	}
+}
+
+func TestJobsAreTenantScoped(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(srv.Stop)
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, newJSONRequest(t, "/admin/tenants", map[string]any{"id": "acme"}))
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("create tenant: expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	do := func(method, path, tenant string, body *bytes.Buffer, contentType string) *httptest.ResponseRecorder {
+		t.Helper()
+		if body == nil {
+			body = &bytes.Buffer{}
+		}
+		req := httptest.NewRequest(method, path, body)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if tenant != "" {
+			req.Header.Set(tenantHeader, tenant)
+		}
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("files", "acme.txt")
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	part.Write([]byte("acme async file"))
+	writer.Close()
+	resp = do(http.MethodPost, "/ingest/media/async", "acme", body, writer.FormDataContentType())
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var queued struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&queued); err != nil {
+		t.Fatalf("decode queued job: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp = do(http.MethodGet, "/jobs/"+queued.JobID+"/result", "acme", nil, "")
+		if resp.Code == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job not finished: %d %s", resp.Code, resp.Body.String())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if resp = do(http.MethodGet, "/jobs/"+queued.JobID, "acme", nil, ""); resp.Code != http.StatusOK {
+		t.Fatalf("own job status: expected 200, got %d", resp.Code)
+	}
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/jobs/" + queued.JobID},
+		{http.MethodGet, "/jobs/" + queued.JobID + "/result"},
+		{http.MethodDelete, "/jobs/" + queued.JobID},
+		{http.MethodPost, "/jobs/dead-letter/" + queued.JobID + "/replay"},
+	} {
+		if resp := do(req.method, req.path, "", nil, ""); resp.Code != http.StatusNotFound {
+			t.Errorf("%s %s from another tenant: expected 404, got %d", req.method, req.path, resp.Code)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/auth"
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// createAPIKeyRequest is the body of POST /admin/keys.
+type createAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	Tenant    string     `json:"tenant,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// apiKeyResponse is the public view of a key; the hash never leaves the server.
+type apiKeyResponse struct {
+	ID         string       `json:"id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	Scopes     []auth.Scope `json:"scopes"`
+	Tenant     string       `json:"tenant,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time   `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time   `json:"last_used_at,omitempty"`
+	Active     bool         `json:"active"`
+}
+
+func newAPIKeyResponse(key *auth.APIKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		Tenant:     key.Tenant,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		RevokedAt:  key.RevokedAt,
+		LastUsedAt: key.LastUsedAt,
+		Active:     key.Active(time.Now().UTC()),
+	}
+}
+
+// bootstrapAdminKey makes sure an enabled auth subsystem can be administered.
+// A configured bootstrap key is imported; otherwise a fresh admin key is
+// generated and logged once.
+func bootstrapAdminKey(keys *auth.KeyStore, bootstrap string, logger *slog.Logger) error {
+	if keys.HasActiveAdmin() {
+		return nil
+	}
+	req := auth.CreateKeyRequest{Name: "bootstrap", Scopes: []auth.Scope{auth.ScopeAdmin}}
+	if bootstrap != "" {
+		key, err := keys.Import(req, bootstrap)
+		if err != nil {
+			return err
+		}
+		logger.Info("imported bootstrap admin API key", slog.String("key_id", key.ID))
+		return nil
+	}
+	key, secret, err := keys.Create(req)
+	if err != nil {
+		return err
+	}
+	logger.Warn("generated bootstrap admin API key; store it now, it will not be shown again",
+		slog.String("key_id", key.ID),
+		slog.String("api_key", secret))
+	return nil
+}
+
+// handleCreateAPIKey handles POST /admin/keys.
+// The plaintext key is only returned in this response.
+func (s *Server) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		s.handleError(w, r, apierrors.BadRequest("expires_at must be in the future"))
+		return
+	}
+	if req.Tenant != "" {
+		if _, err := s.tenants.Get(req.Tenant); err != nil {
+			s.handleError(w, r, err)
+			return
+		}
+	}
+
+	scopes := make([]auth.Scope, 0, len(req.Scopes))
+	for _, raw := range req.Scopes {
+		scopes = append(scopes, auth.Scope(raw))
+		// Admin keys manage every tenant, so pinning one would be misleading.
+		if req.Tenant != "" && auth.Scope(raw) == auth.ScopeAdmin {
+			s.handleError(w, r, apierrors.BadRequest("admin keys cannot be pinned to a tenant"))
+			return
+		}
+	}
+	key, secret, err := s.apiKeys.Create(auth.CreateKeyRequest{
+		Name:      req.Name,
+		Scopes:    scopes,
+		Tenant:    req.Tenant,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	s.logger.Info("api key created",
+		slog.String("key_id", key.ID),
+		slog.String("name", key.Name),
+		slog.Any("scopes", key.Scopes),
+	)
+
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"key":     newAPIKeyResponse(key),
+		"api_key": secret,
+	})
+}
+
+// handleListAPIKeys handles GET /admin/keys.
+func (s *Server) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys := s.apiKeys.List()
+	response := make([]apiKeyResponse, 0, len(keys))
+	for i := range keys {
+		response = append(response, newAPIKeyResponse(&keys[i]))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys":  response,
+		"total": len(response),
+	})
+}
+
+// handleRevokeAPIKey handles DELETE /admin/keys/{key_id}.
+func (s *Server) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID := chi.URLParam(r, "key_id")
+	if caller, ok := auth.KeyFromContext(r.Context()); ok && caller.ID == keyID {
+		s.handleError(w, r, apierrors.Conflict("cannot revoke the key used for this request"))
+		return
+	}
+
+	key, err := s.apiKeys.Revoke(keyID)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	s.logger.Info("api key revoked", slog.String("key_id", key.ID))
+	writeJSON(w, http.StatusOK, map[string]any{"key": newAPIKeyResponse(key)})
+}
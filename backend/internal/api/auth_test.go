@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Muneer320/RhinoBox/internal/config"
+)
+
+const testBootstrapKey = "rbx_test_bootstrap_admin_key"
+
+func newAuthTestServer(t *testing.T) *Server {
+	t.Helper()
+	cfg := config.Config{
+		Addr:             ":0",
+		DataDir:          t.TempDir(),
+		MaxUploadBytes:   32 * 1024 * 1024,
+		AuthEnabled:      true,
+		AuthBootstrapKey: testBootstrapKey,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv, err := NewServer(cfg, logger)
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	t.Cleanup(srv.Stop)
+	return srv
+}
+
+// createTestKey issues a key through POST /admin/keys and returns its secret.
+func createTestKey(t *testing.T, srv *Server, body map[string]any) string {
+	t.Helper()
+	req := newJSONRequest(t, "/admin/keys", body)
+	req.Header.Set("Authorization", "Bearer "+testBootstrapKey)
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("create key: expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var created struct {
+		APIKey string `json:"api_key"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode key: %v", err)
+	}
+	return created.APIKey
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	srv := newAuthTestServer(t)
+
+	// Unauthenticated requests are rejected, public ones are not
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files", nil))
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d: %s", resp.Code, resp.Body.String())
+	}
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 for /healthz, got %d", resp.Code)
+	}
+
+	// Admin creates a read-only key
+	req := newJSONRequest(t, "/admin/keys", map[string]any{"name": "dashboard", "scopes": []string{"read"}})
+	req.Header.Set("Authorization", "Bearer "+testBootstrapKey)
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var created struct {
+		Key    apiKeyResponse `json:"key"`
+		APIKey string         `json:"api_key"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if created.APIKey == "" || created.Key.ID == "" || !created.Key.Active {
+		t.Fatalf("unexpected create response: %+v", created)
+	}
+
+	// The read key can list files but cannot manage keys
+	req = httptest.NewRequest(http.MethodGet, "/files", nil)
+	req.Header.Set("X-API-Key", created.APIKey)
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 with read key, got %d: %s", resp.Code, resp.Body.String())
+	}
+	req = httptest.NewRequest(http.MethodGet, "/admin/keys", nil)
+	req.Header.Set("X-API-Key", created.APIKey)
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for admin route, got %d", resp.Code)
+	}
+
+	// Listing never exposes hashes
+	req = httptest.NewRequest(http.MethodGet, "/admin/keys", nil)
+	req.Header.Set("Authorization", "Bearer "+testBootstrapKey)
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	var listed struct {
+		Keys  []map[string]any `json:"keys"`
+		Total int              `json:"total"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if listed.Total != 2 {
+		t.Fatalf("expected 2 keys (bootstrap + dashboard), got %d", listed.Total)
+	}
+	for _, key := range listed.Keys {
+		if _, ok := key["hash"]; ok {
+			t.Fatalf("key listing leaked hash: %v", key)
+		}
+	}
+
+	// Revoke and confirm the key stops working
+	req = httptest.NewRequest(http.MethodDelete, "/admin/keys/"+created.Key.ID, nil)
+	req.Header.Set("Authorization", "Bearer "+testBootstrapKey)
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200 on revoke, got %d: %s", resp.Code, resp.Body.String())
+	}
+	req = httptest.NewRequest(http.MethodGet, "/files", nil)
+	req.Header.Set("X-API-Key", created.APIKey)
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after revoke, got %d", resp.Code)
+	}
+}
+
+func TestCreateAPIKeyRejectsUnknownScope(t *testing.T) {
+	srv := newAuthTestServer(t)
+
+	req := newJSONRequest(t, "/admin/keys", map[string]any{"name": "bad", "scopes": []string{"write"}})
+	req.Header.Set("Authorization", "Bearer "+testBootstrapKey)
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+)
+
+// backupPath is the snapshot endpoint, which must not wait on its own gate.
+const backupPath = "/admin/backup"
+
+// gateWrites holds mutating requests back while a backup is being taken.
+// Reads carry on.
+func (s *Server) gateWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if r.URL.Path != backupPath {
+				s.writeGate.RLock()
+				defer s.writeGate.RUnlock()
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// quiesce waits for in-flight writes and job items to finish and holds new
+// ones back until the returned release function is called.
+func (s *Server) quiesce() (release func()) {
+	s.writeGate.Lock()
+	releaseJobs := func() {}
+	if s.jobQueue != nil {
+		releaseJobs = s.jobQueue.Hold()
+	}
+	return func() {
+		releaseJobs()
+		s.writeGate.Unlock()
+	}
+}
+
+// handleBackup handles POST /admin/backup. It streams a snapshot of the
+// whole data directory, every tenant included, as a tar.gz. An optional
+// body with the manifest of an earlier snapshot (its backup.json) makes the
+// snapshot incremental. Writes wait while the snapshot is spooled to a
+// temporary file, not while it downloads.
+func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	var opts storage.BackupOptions
+	var base storage.BackupManifest
+	if err := json.NewDecoder(r.Body).Decode(&base); err != nil && err != io.EOF {
+		s.handleError(w, r, apierrors.BadRequestf("invalid base manifest: %v", err))
+		return
+	} else if err == nil {
+		if base.Version != storage.BackupFormatVersion || base.ID == "" {
+			s.handleError(w, r, apierrors.BadRequestf("base manifest must be the backup.json of a version %d snapshot", storage.BackupFormatVersion))
+			return
+		}
+		opts.Base = &base
+	}
+
+	spool, err := os.CreateTemp("", "rhinobox-backup-*.tar.gz")
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	start := time.Now()
+	manifest, err := s.snapshot(spool, opts)
+	if err != nil {
+		s.logger.Warn("backup failed", slog.Any("err", err))
+		s.handleError(w, r, apierrors.InternalServerErrorf("backup failed: %v", err))
+		return
+	}
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	files, included := manifest.Included()
+	s.logger.Info("backup written",
+		slog.String("id", manifest.ID),
+		slog.String("base", manifest.Base),
+		slog.Int("files", files),
+		slog.Int("unchanged", len(manifest.Files)-files),
+		slog.Int64("bytes", included),
+		slog.Int64("archive_bytes", size),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	// Snapshots routinely outlast the server's write timeout.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	name := "rhinobox-backup-" + start.UTC().Format("20060102-150405")
+	if opts.Base != nil {
+		name += "-incremental"
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.tar.gz"`, name))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, spool); err != nil {
+		s.logger.Warn("backup download aborted", slog.String("backup", name), slog.Any("err", err))
+	}
+}
+
+// snapshot writes a snapshot to out while writes are held back.
+func (s *Server) snapshot(out io.Writer, opts storage.BackupOptions) (*storage.BackupManifest, error) {
+	start := time.Now()
+	release := s.quiesce()
+	defer release()
+	s.logger.Info("backup started", slog.Duration("quiesce", time.Since(start)), slog.Bool("incremental", opts.Base != nil))
+	return storage.WriteBackup(out, s.cfg.DataDir, opts)
+}
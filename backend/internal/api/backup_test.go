@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/storage"
+)
+
+func TestBackupEndpoint(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(srv.Stop)
+	first, err := srv.storage.StoreFile(storage.StoreRequest{Reader: strings.NewReader("first"), Filename: "first.txt", MimeType: "text/plain", Size: 5})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/admin/backup", nil))
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("backup: %d %s", resp.Code, resp.Header().Get("Content-Type"))
+	}
+	full := resp.Body.Bytes()
+	base, err := storage.ReadBackupManifest(bytes.NewReader(full))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+
+	second, err := srv.storage.StoreFile(storage.StoreRequest{Reader: strings.NewReader("second"), Filename: "second.txt", MimeType: "text/plain", Size: 6})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, newJSONRequest(t, "/admin/backup", base))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("incremental backup: %d %s", resp.Code, resp.Body.String())
+	}
+	if !strings.Contains(resp.Header().Get("Content-Disposition"), "-incremental.tar.gz") {
+		t.Fatalf("unexpected Content-Disposition %q", resp.Header().Get("Content-Disposition"))
+	}
+	incr := resp.Body.Bytes()
+
+	target := filepath.Join(t.TempDir(), "restored")
+	manifest, err := storage.RestoreBackup(target, bytes.NewReader(full), bytes.NewReader(incr))
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if manifest.Base != base.ID {
+		t.Fatalf("incremental base = %q, want %q", manifest.Base, base.ID)
+	}
+	restored, err := storage.NewManager(target)
+	if err != nil {
+		t.Fatalf("open restored: %v", err)
+	}
+	defer restored.Close()
+	for _, hash := range []string{first.Metadata.Hash, second.Metadata.Hash} {
+		if _, err := restored.GetFileMetadata(hash); err != nil {
+			t.Fatalf("restored index lacks %s: %v", hash, err)
+		}
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, newJSONRequest(t, "/admin/backup", map[string]any{"version": 99}))
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("bad base manifest: expected 400, got %d", resp.Code)
+	}
+}
+
+func TestBackupHoldsWritesBack(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(srv.Stop)
+
+	release := srv.quiesce()
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("read during backup: %d", resp.Code)
+	}
+
+	done := make(chan int)
+	go func() {
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodDelete, "/files/missing", nil))
+		done <- resp.Code
+	}()
+	select {
+	case code := <-done:
+		t.Fatalf("write finished during backup with %d", code)
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("write still held after the backup finished")
+	}
+}
+
+// stalledWriter is a response writer whose body writes block until release
+// is closed, like a client that stopped reading.
+type stalledWriter struct {
+	header  http.Header
+	writing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *stalledWriter) Header() http.Header { return w.header }
+func (w *stalledWriter) WriteHeader(int)     {}
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.writing) })
+	<-w.release
+	return len(p), nil
+}
+
+func TestBackupDownloadDoesNotHoldWrites(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(srv.Stop)
+	if _, err := srv.storage.StoreFile(storage.StoreRequest{Reader: strings.NewReader("data"), Filename: "a.txt", MimeType: "text/plain", Size: 4}); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	stalled := &stalledWriter{header: http.Header{}, writing: make(chan struct{}), release: make(chan struct{})}
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		srv.router.ServeHTTP(stalled, httptest.NewRequest(http.MethodPost, "/admin/backup", nil))
+	}()
+	defer func() {
+		close(stalled.release)
+		<-served
+	}()
+	select {
+	case <-stalled.writing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("backup never started streaming")
+	}
+
+	done := make(chan int)
+	go func() {
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodDelete, "/files/missing", nil))
+		done <- resp.Code
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("write held while the backup downloads")
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+)
+
+// searchSnippetLimit caps how many content search results carry a snippet;
+// each snippet re-reads its file.
+const searchSnippetLimit = 20
+
+// handleRebuildContentIndex re-indexes every stored file of the tenant,
+// repairing a content index that missed updates or predates it.
+func (s *Server) handleRebuildContentIndex(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	stats, err := s.tenant(r).storage.RebuildContentIndex()
+	if err != nil {
+		s.handleError(w, r, apierrors.InternalServerErrorf("rebuild content index: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"documents":   stats.Documents,
+		"tokens":      stats.Tokens,
+		"duration_ms": time.Since(started).Milliseconds(),
+	})
+}
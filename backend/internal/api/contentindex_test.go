@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Muneer320/RhinoBox/internal/storage"
+)
+
+func TestFileSearchContentFromIndex(t *testing.T) {
+	srv := newTestServer(t)
+	for name, content := range map[string]string{
+		"incident.txt": "Database failover completed; the replica lag stayed under a second.",
+		"runbook.md":   "Failover steps: promote the replica, then failover DNS. Failover drills run monthly.",
+		"notes.txt":    "Nothing about databases here.",
+	} {
+		if _, err := srv.storage.StoreFile(storage.StoreRequest{Reader: strings.NewReader(content), Filename: name, MimeType: "text/plain", Size: int64(len(content))}); err != nil {
+			t.Fatalf("store %s: %v", name, err)
+		}
+	}
+
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files/search?content=failover+replica", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var body struct {
+		Results []struct {
+			Name    string  `json:"name"`
+			Score   float64 `json:"score"`
+			Snippet string  `json:"snippet"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(body.Results) != 2 || body.Results[0].Name != "runbook.md" || body.Results[1].Name != "incident.txt" {
+		t.Fatalf("expected runbook.md ranked above incident.txt, got %+v", body.Results)
+	}
+	if body.Results[0].Score <= body.Results[1].Score || !strings.Contains(body.Results[0].Snippet, "<mark>Failover</mark>") {
+		t.Fatalf("expected ranked, highlighted results, got %+v", body.Results)
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/files/search/rebuild", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var stats struct {
+		Documents int `json:"documents"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil || stats.Documents != 3 {
+		t.Fatalf("expected 3 indexed documents, got %+v (%v)", stats, err)
+	}
+}
@@ -29,7 +29,7 @@ func (s *Server) handleDuplicateScan(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	result, err := s.storage.ScanForDuplicates(req)
+	result, err := s.tenant(r).storage.ScanForDuplicates(req)
 	if err != nil {
 		if errors.Is(err, storage.ErrScanInProgress) {
 			httpError(w, http.StatusConflict, err.Error())
@@ -56,7 +56,7 @@ func (s *Server) handleGetDuplicates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	groups, err := s.storage.GetDuplicateReport()
+	groups, err := s.tenant(r).storage.GetDuplicateReport()
 	if err != nil {
 		httpError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get duplicates: %v", err))
 		return
@@ -75,7 +75,7 @@ func (s *Server) handleVerifyDuplicates(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	result, err := s.storage.VerifyDeduplicationSystem()
+	result, err := s.tenant(r).storage.VerifyDeduplicationSystem()
 	if err != nil {
 		httpError(w, http.StatusInternalServerError, fmt.Sprintf("verification failed: %v", err))
 		return
@@ -105,7 +105,7 @@ func (s *Server) handleMergeDuplicates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := s.storage.MergeDuplicates(req)
+	result, err := s.tenant(r).storage.MergeDuplicates(req)
 	if err != nil {
 		switch {
 		case errors.Is(err, storage.ErrInvalidMergeRequest):
@@ -135,7 +135,7 @@ func (s *Server) handleDuplicateStatistics(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	stats, err := s.storage.GetDuplicateStatistics()
+	stats, err := s.tenant(r).storage.GetDuplicateStatistics()
 	if err != nil {
 		httpError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get statistics: %v", err))
 		return
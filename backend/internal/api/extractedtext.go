@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/queue"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+	chi "github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// reextractTextRequest is the body of POST /files/text/reextract. An empty
+// Hashes list re-extracts every document of the tenant.
+type reextractTextRequest struct {
+	Hashes []string `json:"hashes"`
+}
+
+// handleGetExtractedText handles GET /files/{file_id}/text, serving the plain
+// text extracted from a document. Missing text is extracted on demand.
+func (s *Server) handleGetExtractedText(w http.ResponseWriter, r *http.Request) {
+	hash := chi.URLParam(r, "file_id")
+	if hash == "" {
+		s.handleError(w, r, apierrors.BadRequest("file_id is required"))
+		return
+	}
+
+	result, err := s.tenant(r).storage.OpenExtractedText(hash)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	defer result.Reader.Close()
+
+	text := result.Text
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-File-Hash", hash)
+	w.Header().Set("X-Text-Format", text.Format)
+	w.Header().Set("X-Text-Truncated", strconv.FormatBool(text.Truncated))
+	http.ServeContent(w, r, "", text.ExtractedAt, result.Reader)
+}
+
+// handleReextractText handles POST /files/text/reextract by queueing a text
+// extraction job for the requested documents of the caller's tenant.
+func (s *Server) handleReextractText(w http.ResponseWriter, r *http.Request) {
+	var req reextractTextRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			s.handleError(w, r, apierrors.BadRequest("invalid JSON body"))
+			return
+		}
+	}
+
+	scope := s.tenant(r)
+	hashes := req.Hashes
+	if len(hashes) == 0 {
+		for _, meta := range scope.storage.GetAllMetadata() {
+			if storage.SupportsTextExtraction(meta) {
+				hashes = append(hashes, meta.Hash)
+			}
+		}
+	}
+	if len(hashes) == 0 {
+		s.handleError(w, r, apierrors.BadRequest("no documents to re-extract"))
+		return
+	}
+
+	job, err := s.enqueueTextExtractionJob(scope.id, hashes)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"job_id":           job.ID,
+		"status":           string(job.Status),
+		"total_items":      job.Total,
+		"check_status_url": fmt.Sprintf("/jobs/%s", job.ID),
+		"created_at":       job.CreatedAt,
+	})
+}
+
+// enqueueTextExtractionJob queues one text extraction job covering hashes for tenant.
+func (s *Server) enqueueTextExtractionJob(tenant string, hashes []string) (*queue.Job, error) {
+	items := make([]queue.JobItem, len(hashes))
+	for i, hash := range hashes {
+		items[i] = queue.JobItem{ID: uuid.NewString(), Type: "file", Name: hash}
+	}
+	job := &queue.Job{
+		ID:     uuid.NewString(),
+		Type:   queue.JobTypeTextExtraction,
+		Items:  items,
+		Tenant: tenant,
+	}
+	if err := s.jobQueue.Enqueue(job); err != nil {
+		return nil, fmt.Errorf("failed to queue text extraction job: %w", err)
+	}
+	return job, nil
+}
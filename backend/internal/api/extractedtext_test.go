@@ -0,0 +1,131 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func encodeTestODT(t *testing.T, text string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, part := range []struct{ name, body string }{
+		{"mimetype", "application/vnd.oasis.opendocument.text"},
+		{"content.xml", `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"><office:body><office:text><text:p>` + text + `</text:p></office:text></office:body></office:document-content>`},
+	} {
+		w, err := zw.Create(part.name)
+		if err != nil {
+			t.Fatalf("create %s: %v", part.name, err)
+		}
+		w.Write([]byte(part.body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close odt: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractedTextEndpointAndReextraction(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(srv.Stop)
+
+	resp := ingestMediaAs(t, srv, "", "minutes.odt", encodeTestODT(t, "Board approved the hiring plan"))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var ingest struct {
+		Stored []struct {
+			Hash string `json:"hash"`
+		} `json:"stored"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ingest); err != nil || len(ingest.Stored) != 1 {
+		t.Fatalf("decode ingest: %v", err)
+	}
+	hash := ingest.Stored[0].Hash
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files/"+hash+"/text", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("text: expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if got := resp.Body.String(); got != "Board approved the hiring plan" {
+		t.Fatalf("unexpected text %q", got)
+	}
+	if format := resp.Header().Get("X-Text-Format"); format != "odt" {
+		t.Fatalf("unexpected format header %q", format)
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files/search?content=hiring", nil))
+	if resp.Code != http.StatusOK || !bytes.Contains(resp.Body.Bytes(), []byte("minutes.odt")) {
+		t.Fatalf("expected content search to find the document, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, newJSONRequest(t, "/files/text/reextract", map[string]any{}))
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("reextract: expected 202, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var queued struct {
+		TotalItems     int    `json:"total_items"`
+		CheckStatusURL string `json:"check_status_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&queued); err != nil || queued.TotalItems != 1 {
+		t.Fatalf("expected one queued document, got %+v (%v)", queued, err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp = httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, queued.CheckStatusURL, nil))
+		var status struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatalf("decode job status: %v", err)
+		}
+		if status.Status == "completed" && status.Error == "" {
+			break
+		}
+		if status.Status == "failed" || status.Error != "" {
+			t.Fatalf("text extraction job failed: %s", status.Error)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("text extraction job still %s", status.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestExtractedTextRejectsOtherFiles(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(srv.Stop)
+
+	resp := ingestMediaAs(t, srv, "", "banner.png", encodeTestPNG(t, 10, 10))
+	var ingest struct {
+		Stored []struct {
+			Hash string `json:"hash"`
+		} `json:"stored"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ingest); err != nil || len(ingest.Stored) != 1 {
+		t.Fatalf("decode ingest: %v", err)
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files/"+ingest.Stored[0].Hash+"/text", nil))
+	if resp.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, newJSONRequest(t, "/files/text/reextract", map[string]any{}))
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without documents, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// handleListFolders handles GET /folders, listing the subfolders of
+// parent_id or the top-level folders.
+func (s *Server) handleListFolders(w http.ResponseWriter, r *http.Request) {
+	folders, err := s.tenant(r).storage.ListFolders(strings.TrimSpace(r.URL.Query().Get("parent_id")))
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"folders": folders,
+		"count":   len(folders),
+	})
+}
+
+// handleCreateFolder handles POST /folders.
+func (s *Server) handleCreateFolder(w http.ResponseWriter, r *http.Request) {
+	var req storage.CreateFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+	folder, err := s.tenant(r).storage.CreateFolder(req)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	s.logger.Info("folder created", slog.String("folder_id", folder.ID), slog.String("path", folder.Path))
+	writeJSON(w, http.StatusCreated, folder)
+}
+
+// handleGetFolder handles GET /folders/{folder_id}, returning the folder and
+// its direct subfolders.
+func (s *Server) handleGetFolder(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "folder_id")
+	store := s.tenant(r).storage
+	folder, err := store.GetFolder(id)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	subfolders, err := store.ListFolders(id)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"folder":     folder,
+		"subfolders": subfolders,
+	})
+}
+
+// handleUpdateFolder handles PATCH /folders/{folder_id}, renaming, describing
+// or moving a folder.
+func (s *Server) handleUpdateFolder(w http.ResponseWriter, r *http.Request) {
+	var req storage.UpdateFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+	folder, err := s.tenant(r).storage.UpdateFolder(chi.URLParam(r, "folder_id"), req)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, folder)
+}
+
+// handleDeleteFolder handles DELETE /folders/{folder_id}. Folders with
+// subfolders need ?recursive=true. Files are never deleted.
+func (s *Server) handleDeleteFolder(w http.ResponseWriter, r *http.Request) {
+	recursive := false
+	if raw := r.URL.Query().Get("recursive"); raw != "" {
+		var err error
+		if recursive, err = strconv.ParseBool(raw); err != nil {
+			s.handleError(w, r, apierrors.BadRequest("recursive must be true or false"))
+			return
+		}
+	}
+	id := chi.URLParam(r, "folder_id")
+	result, err := s.tenant(r).storage.DeleteFolder(id, recursive)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	s.logger.Info("folder deleted", slog.String("folder_id", id), slog.Int("folders", len(result.Deleted)))
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleListFolderFiles handles GET /folders/{folder_id}/files. It takes the
+// parameters of GET /files; without sort_by, files keep the folder order.
+func (s *Server) handleListFolderFiles(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	id := chi.URLParam(r, "folder_id")
+	result, err := s.tenant(r).storage.ListFolderFiles(id, opts)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	resp := fileListResponse(result)
+	resp["folder_id"] = id
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAddFolderFiles handles POST /folders/{folder_id}/files.
+func (s *Server) handleAddFolderFiles(w http.ResponseWriter, r *http.Request) {
+	var req storage.FolderFilesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+	result, err := s.tenant(r).storage.AddFilesToFolder(chi.URLParam(r, "folder_id"), req)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleRemoveFolderFiles handles POST /folders/{folder_id}/files/remove.
+func (s *Server) handleRemoveFolderFiles(w http.ResponseWriter, r *http.Request) {
+	var req storage.FolderFilesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+	result, err := s.tenant(r).storage.RemoveFilesFromFolder(chi.URLParam(r, "folder_id"), req)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleReorderFolder handles PUT /folders/{folder_id}/order.
+func (s *Server) handleReorderFolder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Hashes []string `json:"hashes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+	id := chi.URLParam(r, "folder_id")
+	if err := s.tenant(r).storage.ReorderFolder(id, req.Hashes); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"folder_id": id,
+		"hashes":    req.Hashes,
+	})
+}
+
+// handleGetFileFolders handles GET /files/{file_id}/folders, listing the
+// folders a file sits in.
+func (s *Server) handleGetFileFolders(w http.ResponseWriter, r *http.Request) {
+	fileID := chi.URLParam(r, "file_id")
+	folders, err := s.tenant(r).storage.FoldersForFile(fileID)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"file_id": fileID,
+		"folders": folders,
+		"count":   len(folders),
+	})
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFolderEndpoints(t *testing.T) {
+	srv := newTestServer(t)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, req)
+		return resp
+	}
+	var hashes []string
+	for _, name := range []string{"one.txt", "two.txt"} {
+		resp := ingestMediaAs(t, srv, "", name, []byte("content of "+name))
+		var ingest struct {
+			Stored []struct {
+				Hash string `json:"hash"`
+			} `json:"stored"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&ingest); err != nil || len(ingest.Stored) != 1 {
+			t.Fatalf("ingest %s: %d %v", name, resp.Code, err)
+		}
+		hashes = append(hashes, ingest.Stored[0].Hash)
+	}
+
+	resp := do(http.MethodPost, "/folders", `{"name":"Clients"}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", resp.Code, resp.Body.String())
+	}
+	var parent struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&parent)
+	resp = do(http.MethodPost, "/folders", `{"name":"Acme","parent_id":"`+parent.ID+`"}`)
+	var folder struct {
+		ID   string `json:"id"`
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&folder); err != nil || folder.Path != "/Clients/Acme" {
+		t.Fatalf("create child: %d %+v %v", resp.Code, folder, err)
+	}
+	if resp := do(http.MethodPost, "/folders", `{"name":"acme","parent_id":"`+parent.ID+`"}`); resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a duplicate name, got %d", resp.Code)
+	}
+
+	resp = do(http.MethodPost, "/folders/"+folder.ID+"/files", `{"hashes":["`+hashes[0]+`","`+hashes[1]+`"]}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("add files: %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := do(http.MethodPut, "/folders/"+folder.ID+"/order", `{"hashes":["`+hashes[1]+`","`+hashes[0]+`"]}`); resp.Code != http.StatusOK {
+		t.Fatalf("reorder: %d %s", resp.Code, resp.Body.String())
+	}
+
+	resp = do(http.MethodGet, "/folders/"+folder.ID+"/files", "")
+	var listed struct {
+		Files []struct {
+			Name string `json:"name"`
+		} `json:"files"`
+		Pagination struct {
+			Total int `json:"total"`
+		} `json:"pagination"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil || listed.Pagination.Total != 2 || listed.Files[0].Name != "two.txt" {
+		t.Fatalf("folder files: %+v %v", listed, err)
+	}
+
+	resp = do(http.MethodGet, "/folders/"+parent.ID, "")
+	var detail struct {
+		Subfolders []struct {
+			FileCount int `json:"file_count"`
+		} `json:"subfolders"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&detail); err != nil || len(detail.Subfolders) != 1 || detail.Subfolders[0].FileCount != 2 {
+		t.Fatalf("folder detail: %+v %v", detail, err)
+	}
+
+	if resp := do(http.MethodPost, "/folders/"+folder.ID+"/files/remove", `{"hashes":["`+hashes[0]+`"]}`); resp.Code != http.StatusOK {
+		t.Fatalf("remove files: %d", resp.Code)
+	}
+	resp = do(http.MethodGet, "/files/"+hashes[1]+"/folders", "")
+	var member struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&member); err != nil || member.Count != 1 {
+		t.Fatalf("file folders: %+v %v", member, err)
+	}
+
+	if resp := do(http.MethodDelete, "/folders/"+parent.ID, ""); resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409 deleting a folder with subfolders, got %d", resp.Code)
+	}
+	if resp := do(http.MethodDelete, "/folders/"+parent.ID+"?recursive=true", ""); resp.Code != http.StatusOK {
+		t.Fatalf("recursive delete: %d", resp.Code)
+	}
+	if resp := do(http.MethodGet, "/folders/"+folder.ID, ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", resp.Code)
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -33,9 +35,10 @@ type UnifiedIngestResponse struct {
 }
 
 type UnifiedIngestResults struct {
-	Media []MediaResult   `json:"media,omitempty"`
-	JSON  []JSONResult    `json:"json,omitempty"`
-	Files []GenericResult `json:"files,omitempty"`
+	Media    []MediaResult   `json:"media,omitempty"`
+	JSON     []JSONResult    `json:"json,omitempty"`
+	Files    []GenericResult `json:"files,omitempty"`
+	Archives []ArchiveResult `json:"archives,omitempty"`
 }
 
 type MediaResult struct {
@@ -53,14 +56,16 @@ type MediaResult struct {
 }
 
 type JSONResult struct {
-	StorageType           string              `json:"storage_type"` // "sql" or "nosql"
-	Database              string              `json:"database,omitempty"`
-	TableOrCollection     string              `json:"table_or_collection"`
-	RecordsInserted       int                 `json:"records_inserted"`
-	SchemaCreated         bool                `json:"schema_created"`
-	RelationshipsDetected []string            `json:"relationships_detected,omitempty"`
-	Decision              jsonschema.Decision `json:"decision,omitempty"`
-	BatchPath             string              `json:"batch_path"`
+	StorageType           string                    `json:"storage_type"` // "sql" or "nosql"
+	Database              string                    `json:"database,omitempty"`
+	TableOrCollection     string                    `json:"table_or_collection"`
+	RecordsInserted       int                       `json:"records_inserted"`
+	SchemaCreated         bool                      `json:"schema_created"`
+	RelationshipsDetected []string                  `json:"relationships_detected,omitempty"`
+	Decision              jsonschema.Decision       `json:"decision,omitempty"`
+	BatchPath             string                    `json:"batch_path"`
+	SchemaVersion         int                       `json:"schema_version,omitempty"`
+	SchemaChanges         []jsonschema.ColumnChange `json:"schema_changes,omitempty"`
 }
 
 type GenericResult struct {
@@ -121,12 +126,35 @@ func (s *Server) handleUnifiedIngest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// expand=true unpacks archives into their files
+	expand := false
+	if raw := r.FormValue("expand"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Sprintf("invalid expand value: %s (must be true or false)", raw))
+			return
+		}
+		expand = parsed
+	}
+
 	// Process files (media, JSON, or generic)
 	if r.MultipartForm != nil && len(r.MultipartForm.File) > 0 {
 		processingStart := time.Now()
 		for fieldName, headers := range r.MultipartForm.File {
 			for _, header := range headers {
-				result, err := s.routeFile(header, fieldName, comment, namespace, overrideType)
+				if expand {
+					archive, ok, err := s.expandArchive(s.tenant(r), header, comment, namespace, overrideType)
+					if err != nil {
+						response.Errors = append(response.Errors, fmt.Sprintf("%s: %v", header.Filename, err))
+					}
+					if ok {
+						response.Results.Archives = append(response.Results.Archives, archive)
+					}
+					if ok || err != nil {
+						continue
+					}
+				}
+				result, err := s.routeFile(r.Context(), s.tenant(r), header, fieldName, comment, namespace, overrideType)
 				if err != nil {
 					response.Errors = append(response.Errors, fmt.Sprintf("%s: %v", header.Filename, err))
 					continue
@@ -148,7 +176,7 @@ func (s *Server) handleUnifiedIngest(w http.ResponseWriter, r *http.Request) {
 	// Process inline JSON data
 	if dataStr != "" {
 		jsonStart := time.Now()
-		result, err := s.processInlineJSON(dataStr, namespace, comment, metadata)
+		result, err := s.processInlineJSON(r.Context(), s.tenant(r), dataStr, namespace, comment, metadata)
 		if err != nil {
 			response.Errors = append(response.Errors, fmt.Sprintf("JSON processing: %v", err))
 		} else {
@@ -159,7 +187,7 @@ func (s *Server) handleUnifiedIngest(w http.ResponseWriter, r *http.Request) {
 
 	response.Timing["total_ms"] = time.Since(startTime).Milliseconds()
 
-	if len(response.Errors) > 0 && len(response.Results.Media) == 0 && len(response.Results.JSON) == 0 && len(response.Results.Files) == 0 {
+	if len(response.Errors) > 0 && len(response.Results.Media) == 0 && len(response.Results.JSON) == 0 && len(response.Results.Files) == 0 && len(response.Results.Archives) == 0 {
 		httpError(w, http.StatusBadRequest, fmt.Sprintf("all items failed: %v", response.Errors))
 		return
 	}
@@ -168,7 +196,7 @@ func (s *Server) handleUnifiedIngest(w http.ResponseWriter, r *http.Request) {
 }
 
 // routeFile determines content type and routes to appropriate pipeline.
-func (s *Server) routeFile(header *multipart.FileHeader, fieldName, comment, namespace, overrideType string) (any, error) {
+func (s *Server) routeFile(ctx context.Context, ts *tenantScope, header *multipart.FileHeader, fieldName, comment, namespace, overrideType string) (any, error) {
 	file, err := header.Open()
 	if err != nil {
 		return nil, fmt.Errorf("open file: %w", err)
@@ -185,8 +213,8 @@ func (s *Server) routeFile(header *multipart.FileHeader, fieldName, comment, nam
 		slog.String("override", overrideType))
 
 	// Check if format is recognized
-	classifier := s.storage.Classifier()
-	rulesMgr := s.storage.RoutingRules()
+	classifier := ts.storage.Classifier()
+	rulesMgr := ts.storage.RoutingRules()
 	
 	isRecognized := classifier.IsRecognized(detectedMimeType, header.Filename)
 	hasCustomRule := rulesMgr != nil && rulesMgr.FindRule(detectedMimeType, ext) != nil
@@ -194,15 +222,15 @@ func (s *Server) routeFile(header *multipart.FileHeader, fieldName, comment, nam
 
 	// Route based on MIME type or override
 	if isMediaType(detectedMimeType) || (overrideType != "auto" && overrideType != "" && (overrideType == "image" || overrideType == "video" || overrideType == "audio")) {
-		return s.processMediaFile(header, comment, detectedMimeType, overrideType)
+		return s.processMediaFile(ts, header, comment, detectedMimeType, overrideType)
 	}
 
 	if isJSONType(detectedMimeType) {
-		return s.processJSONFile(header, namespace, comment)
+		return s.processJSONFile(ctx, ts, header, namespace, comment)
 	}
 
 	// For generic files, check if unrecognized
-	result, err := s.processGenericFile(header, namespace, detectedMimeType, overrideType)
+	result, err := s.processGenericFile(ts, header, namespace, detectedMimeType, overrideType)
 	if err != nil {
 		return result, err
 	}
@@ -217,7 +245,7 @@ func (s *Server) routeFile(header *multipart.FileHeader, fieldName, comment, nam
 }
 
 // processMediaFile handles images, videos, audio.
-func (s *Server) processMediaFile(header *multipart.FileHeader, comment, detectedMimeType, overrideType string) (MediaResult, error) {
+func (s *Server) processMediaFile(ts *tenantScope, header *multipart.FileHeader, comment, detectedMimeType, overrideType string) (MediaResult, error) {
 	file, err := header.Open()
 	if err != nil {
 		return MediaResult{}, fmt.Errorf("open file: %w", err)
@@ -260,7 +288,7 @@ func (s *Server) processMediaFile(header *multipart.FileHeader, comment, detecte
 		CategoryHint: categoryHint,
 	}
 
-	result, err := s.fileService.StoreFile(req)
+	result, err := ts.fileService.StoreFile(req)
 	if err != nil {
 		return MediaResult{}, err
 	}
@@ -297,7 +325,7 @@ func (s *Server) processMediaFile(header *multipart.FileHeader, comment, detecte
 }
 
 // processGenericFile handles PDFs, documents, archives, etc.
-func (s *Server) processGenericFile(header *multipart.FileHeader, namespace, detectedMimeType, overrideType string) (GenericResult, error) {
+func (s *Server) processGenericFile(ts *tenantScope, header *multipart.FileHeader, namespace, detectedMimeType, overrideType string) (GenericResult, error) {
 	file, err := header.Open()
 	if err != nil {
 		return GenericResult{}, fmt.Errorf("open file: %w", err)
@@ -317,7 +345,7 @@ func (s *Server) processGenericFile(header *multipart.FileHeader, namespace, det
 		category = overrideType
 	}
 
-	relPath, err := s.fileService.StoreMediaFile([]string{"files", category}, header.Filename, file)
+	relPath, err := ts.fileService.StoreMediaFile([]string{"files", category}, header.Filename, file)
 	if err != nil {
 		return GenericResult{}, err
 	}
@@ -340,7 +368,7 @@ func (s *Server) processGenericFile(header *multipart.FileHeader, namespace, det
 }
 
 // processJSONFile handles JSON files uploaded through multipart form.
-func (s *Server) processJSONFile(header *multipart.FileHeader, namespace, comment string) (JSONResult, error) {
+func (s *Server) processJSONFile(ctx context.Context, ts *tenantScope, header *multipart.FileHeader, namespace, comment string) (JSONResult, error) {
 	file, err := header.Open()
 	if err != nil {
 		return JSONResult{}, fmt.Errorf("open file: %w", err)
@@ -354,11 +382,11 @@ func (s *Server) processJSONFile(header *multipart.FileHeader, namespace, commen
 	}
 
 	// Process using the inline JSON handler
-	return s.processInlineJSON(string(data), namespace, comment, nil)
+	return s.processInlineJSON(ctx, ts, string(data), namespace, comment, nil)
 }
 
 // processInlineJSON handles JSON data from request body or form field.
-func (s *Server) processInlineJSON(dataStr, namespace, comment string, metadata map[string]any) (JSONResult, error) {
+func (s *Server) processInlineJSON(ctx context.Context, ts *tenantScope, dataStr, namespace, comment string, metadata map[string]any) (JSONResult, error) {
 	var data any
 	if err := json.Unmarshal([]byte(dataStr), &data); err != nil {
 		return JSONResult{}, fmt.Errorf("invalid JSON: %w", err)
@@ -389,24 +417,7 @@ func (s *Server) processInlineJSON(dataStr, namespace, comment string, metadata
 	analysis = jsonschema.IncorporateCommentHints(analysis, comment)
 	decision := jsonschema.DecideStorage(namespace, docs, summary, analysis)
 
-	batchRel := s.fileService.NextJSONBatchPath(decision.Engine, namespace)
-	if _, err := s.fileService.AppendNDJSON(batchRel, docs); err != nil {
-		return JSONResult{}, fmt.Errorf("store batch: %w", err)
-	}
-
-	schemaCreated := false
-	if decision.Engine == "sql" && decision.Schema != "" {
-		schemaCreated = true
-	}
-
-	return JSONResult{
-		StorageType:       decision.Engine,
-		TableOrCollection: decision.Table,
-		RecordsInserted:   len(docs),
-		SchemaCreated:     schemaCreated,
-		Decision:          decision,
-		BatchPath:         batchRel,
-	}, nil
+	return s.storeJSONBatch(ctx, ts, namespace, decision, docs)
 }
 
 func detectMIMEType(header *multipart.FileHeader) string {
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/jsonschema"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// schemaHistoryPath returns where a namespace's schema history is kept in
+// the tenant's storage.
+func schemaHistoryPath(table string) string {
+	return filepath.Join("json", "schema_history", table+".json")
+}
+
+// loadSchemaHistory reads a namespace's schema history; a namespace that
+// was never ingested yields an empty history and false.
+func loadSchemaHistory(ts *tenantScope, table string) (*jsonschema.SchemaHistory, bool, error) {
+	var history jsonschema.SchemaHistory
+	found, err := ts.storage.ReadJSONFile(schemaHistoryPath(table), &history)
+	if err != nil {
+		return nil, false, err
+	}
+	return &history, found, nil
+}
+
+// lockJSONNamespace serializes batches of one tenant namespace so schema
+// versions are recorded and migrations applied one batch at a time.
+func (s *Server) lockJSONNamespace(tenantID, table string) func() {
+	v, _ := s.namespaceLocks.LoadOrStore(tenantID+"/"+table, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+func (s *Server) handleJSONSchemaHistory(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	history, found, err := loadSchemaHistory(s.tenant(r), jsonschema.TableName(namespace))
+	if err != nil {
+		s.handleError(w, r, apierrors.InternalServerErrorf("read schema history: %v", err))
+		return
+	}
+	if !found || len(history.Versions) == 0 {
+		s.handleError(w, r, apierrors.NotFoundf("no schema history for namespace %q", namespace))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"namespace":       history.Namespace,
+		"table":           history.Table,
+		"current_version": history.Versions[len(history.Versions)-1].Version,
+		"versions":        history.Versions,
+	})
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Muneer320/RhinoBox/internal/jsonschema"
+)
+
+func TestJSONSchemaHistoryTracksDrift(t *testing.T) {
+	srv := newTestServer(t)
+	pg := newFakeSQLStore()
+	srv.sqlDB = pg
+
+	postJSONIngest(t, srv, map[string]any{
+		"namespace": "orders",
+		"documents": []map[string]any{
+			{"id": 1, "user_id": 10, "amount": 250, "status": "paid"},
+			{"id": 2, "user_id": 11, "amount": 75, "status": "pending"},
+		},
+	})
+	body := postJSONIngest(t, srv, map[string]any{
+		"namespace": "orders",
+		"documents": []map[string]any{
+			{"id": 3, "user_id": 12, "amount": 30, "status": "paid"},
+		},
+	})
+	if body.SchemaVersion != 1 || len(body.SchemaChanges) != 0 {
+		t.Fatalf("expected unchanged version 1, got %+v", body)
+	}
+
+	body = postJSONIngest(t, srv, map[string]any{
+		"namespace": "orders",
+		"documents": []map[string]any{
+			{"id": 4, "user_id": 10, "amount": 12.5, "status": "paid", "coupon": nil},
+			{"id": 5, "user_id": 13, "amount": 99.75, "status": "paid", "coupon": nil},
+		},
+	})
+	if body.SchemaVersion != 2 || len(body.SchemaChanges) != 1 || body.SchemaChanges[0].Kind != jsonschema.ChangeWidened {
+		t.Fatalf("expected amount widened in version 2, got %+v", body)
+	}
+	if len(pg.executed) != 1 || pg.executed[0] != `ALTER TABLE "orders" ALTER COLUMN "amount" TYPE DOUBLE PRECISION;` {
+		t.Fatalf("expected amount migration to be applied, got %q", pg.executed)
+	}
+	if _, ok := pg.inserted["orders"][3]["coupon"]; ok {
+		t.Fatalf("expected null-only coupon to be left out of the insert")
+	}
+
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/json/orders/schema/history", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var history struct {
+		CurrentVersion int                        `json:"current_version"`
+		Versions       []jsonschema.SchemaVersion `json:"versions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if history.CurrentVersion != 2 || len(history.Versions) != 2 {
+		t.Fatalf("expected 2 versions, got %+v", history)
+	}
+	if v1 := history.Versions[0]; v1.Batches != 2 || v1.Documents != 3 || !strings.HasPrefix(v1.Migration[0], `CREATE TABLE IF NOT EXISTS "orders"`) {
+		t.Fatalf("unexpected version 1 %+v", v1)
+	}
+	if got := history.Versions[1].Columns["amount"].Type; got != "DOUBLE PRECISION" {
+		t.Fatalf("expected amount DOUBLE PRECISION in version 2, got %q", got)
+	}
+}
+
+func TestJSONSchemaHistoryNotFound(t *testing.T) {
+	srv := newTestServer(t)
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/json/missing/schema/history", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/jsonquery"
+	"github.com/Muneer320/RhinoBox/internal/jsonschema"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// recordSource is one store holding records of a JSON namespace.
+type recordSource struct {
+	name  string
+	fetch func(ctx context.Context, q jsonquery.Query) ([]map[string]any, error)
+}
+
+// jsonRecordSources returns the stores that hold records of namespace:
+// its Postgres table, its MongoDB collection and its NDJSON batches.
+func (s *Server) jsonRecordSources(ctx context.Context, ts *tenantScope, namespace string) ([]recordSource, error) {
+	name := jsonschema.TableName(namespace)
+	var sources []recordSource
+
+	if s.sqlDB != nil {
+		table := tenantTableName(ts.id, name)
+		exists, err := s.sqlDB.TableExists(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			sources = append(sources, recordSource{name: "postgres", fetch: func(ctx context.Context, q jsonquery.Query) ([]map[string]any, error) {
+				return s.sqlDB.QueryJSON(ctx, table, q)
+			}})
+		}
+	}
+
+	if s.docDB != nil {
+		dbName := tenantDatabaseName(ts.id, s.cfg.MongoDatabase)
+		exists, err := s.docDB.CollectionExists(ctx, dbName, name)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			sources = append(sources, recordSource{name: "mongodb", fetch: func(ctx context.Context, q jsonquery.Query) ([]map[string]any, error) {
+				return s.docDB.FindJSON(ctx, dbName, name, q)
+			}})
+		}
+	}
+
+	found, err := ts.storage.ScanJSONNamespace(namespace, func(map[string]any) bool { return false })
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		sources = append(sources, recordSource{name: "ndjson", fetch: func(_ context.Context, q jsonquery.Query) ([]map[string]any, error) {
+			return jsonquery.Collect(q, func(yield func(map[string]any) bool) error {
+				_, err := ts.storage.ScanJSONNamespace(namespace, yield)
+				return err
+			})
+		}})
+	}
+	return sources, nil
+}
+
+// handleJSONRecords queries the records of an ingested JSON namespace with
+// field filters, projection, sorting and cursor pagination. A namespace
+// whose batches were split across stores is queried as one result set.
+func (s *Server) handleJSONRecords(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	q, err := jsonquery.Parse(r.URL.Query())
+	if err != nil {
+		s.handleError(w, r, apierrors.BadRequest(err.Error()))
+		return
+	}
+
+	ts := s.tenant(r)
+	sources, err := s.jsonRecordSources(r.Context(), ts, namespace)
+	if err != nil {
+		s.handleError(w, r, apierrors.InternalServerErrorf("resolve namespace: %v", err))
+		return
+	}
+	if len(sources) == 0 {
+		s.handleError(w, r, apierrors.NotFoundf("namespace %q has no records", namespace))
+		return
+	}
+
+	// Fetch one record past the page to learn whether another page follows.
+	page := q
+	page.Limit = q.Limit + 1
+	var records []map[string]any
+	if len(sources) == 1 {
+		records, err = sources[0].fetch(r.Context(), page)
+	} else {
+		records, err = mergeRecordSources(r.Context(), sources, page)
+	}
+	if err != nil {
+		s.handleError(w, r, apierrors.InternalServerErrorf("query records: %v", err))
+		return
+	}
+
+	nextCursor := ""
+	if len(records) > q.Limit {
+		records = records[:q.Limit]
+		nextCursor = jsonquery.EncodeCursor(q.Offset + q.Limit)
+	}
+	names := make([]string, len(sources))
+	for i, src := range sources {
+		names[i] = src.name
+	}
+	for i, doc := range records {
+		records[i] = jsonquery.Project(doc, q.Fields)
+	}
+
+	resp := map[string]any{
+		"namespace": namespace,
+		"sources":   names,
+		"records":   records,
+		"count":     len(records),
+	}
+	if nextCursor != "" {
+		resp["next_cursor"] = nextCursor
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// mergeRecordSources reads the leading Offset+Limit records of every source
+// and merges them in query order.
+func mergeRecordSources(ctx context.Context, sources []recordSource, q jsonquery.Query) ([]map[string]any, error) {
+	prefix := q
+	prefix.Offset = 0
+	prefix.Limit = q.Offset + q.Limit
+	pages := make([][]map[string]any, 0, len(sources))
+	for _, src := range sources {
+		docs, err := src.fetch(ctx, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src.name, err)
+		}
+		pages = append(pages, docs)
+	}
+	return jsonquery.Merge(q, pages...), nil
+}
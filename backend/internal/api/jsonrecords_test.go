@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type jsonRecordsResponse struct {
+	Sources    []string         `json:"sources"`
+	Records    []map[string]any `json:"records"`
+	Count      int              `json:"count"`
+	NextCursor string           `json:"next_cursor"`
+}
+
+func getJSONRecords(t *testing.T, srv *Server, path string, wantStatus int) jsonRecordsResponse {
+	t.Helper()
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+	if resp.Code != wantStatus {
+		t.Fatalf("GET %s: expected %d, got %d: %s", path, wantStatus, resp.Code, resp.Body.String())
+	}
+	var body jsonRecordsResponse
+	if wantStatus == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return body
+}
+
+func ingestOrders(t *testing.T, srv *Server) {
+	t.Helper()
+	postJSONIngest(t, srv, map[string]any{
+		"namespace": "orders",
+		"documents": []map[string]any{
+			{"id": 1, "user_id": 10, "amount": 250.5, "status": "paid"},
+			{"id": 2, "user_id": 11, "amount": 75.25, "status": "pending"},
+			{"id": 3, "user_id": 10, "amount": 120.0, "status": "paid"},
+			{"id": 4, "user_id": 12, "amount": 30.75, "status": "refunded"},
+		},
+	})
+}
+
+func TestJSONRecordsFromNDJSON(t *testing.T) {
+	srv := newTestServer(t)
+	ingestOrders(t, srv)
+
+	body := getJSONRecords(t, srv, "/json/orders/records?status=paid&sort=-amount&fields=id,amount", http.StatusOK)
+	if len(body.Sources) != 1 || body.Sources[0] != "ndjson" {
+		t.Fatalf("expected ndjson source, got %v", body.Sources)
+	}
+	if body.Count != 2 {
+		t.Fatalf("expected 2 paid orders, got %d: %v", body.Count, body.Records)
+	}
+	if body.Records[0]["id"] != float64(1) || body.Records[1]["id"] != float64(3) {
+		t.Fatalf("expected orders 1 then 3, got %v", body.Records)
+	}
+	if _, ok := body.Records[0]["status"]; ok {
+		t.Fatalf("expected projection to drop status, got %v", body.Records[0])
+	}
+
+	body = getJSONRecords(t, srv, "/json/orders/records?amount[lt]=100", http.StatusOK)
+	if body.Count != 2 {
+		t.Fatalf("expected 2 orders under 100, got %v", body.Records)
+	}
+}
+
+func TestJSONRecordsCursorPagination(t *testing.T) {
+	srv := newTestServer(t)
+	ingestOrders(t, srv)
+
+	var ids []float64
+	path := "/json/orders/records?sort=id&limit=3"
+	for page := 0; ; page++ {
+		if page > 3 {
+			t.Fatalf("pagination did not terminate")
+		}
+		body := getJSONRecords(t, srv, path, http.StatusOK)
+		for _, rec := range body.Records {
+			ids = append(ids, rec["id"].(float64))
+		}
+		if body.NextCursor == "" {
+			break
+		}
+		path = "/json/orders/records?sort=id&limit=3&cursor=" + body.NextCursor
+	}
+	if len(ids) != 4 || ids[0] != 1 || ids[3] != 4 {
+		t.Fatalf("expected ids 1..4 across pages, got %v", ids)
+	}
+}
+
+func TestJSONRecordsMergesSources(t *testing.T) {
+	srv := newTestServer(t)
+	ingestOrders(t, srv) // NDJSON, no database yet
+
+	pg := newFakeSQLStore()
+	srv.sqlDB = pg
+	postJSONIngest(t, srv, map[string]any{
+		"namespace": "orders",
+		"documents": []map[string]any{
+			{"id": 5, "user_id": 13, "amount": 500.5, "status": "paid"},
+			{"id": 6, "user_id": 14, "amount": 5.5, "status": "paid"},
+		},
+	})
+
+	body := getJSONRecords(t, srv, "/json/orders/records?status=paid&sort=-amount&limit=2", http.StatusOK)
+	if len(body.Sources) != 2 {
+		t.Fatalf("expected postgres and ndjson sources, got %v", body.Sources)
+	}
+	if body.Count != 2 || body.Records[0]["id"] != float64(5) || body.Records[1]["id"] != float64(1) {
+		t.Fatalf("expected orders 5 then 1, got %v", body.Records)
+	}
+	body = getJSONRecords(t, srv, "/json/orders/records?status=paid&sort=-amount&limit=2&cursor="+body.NextCursor, http.StatusOK)
+	if body.Count != 2 || body.Records[0]["id"] != float64(3) || body.Records[1]["id"] != float64(6) {
+		t.Fatalf("expected orders 3 then 6, got %v", body.Records)
+	}
+	if body.NextCursor != "" {
+		t.Fatalf("expected last page, got cursor %s", body.NextCursor)
+	}
+}
+
+func TestJSONRecordsErrors(t *testing.T) {
+	srv := newTestServer(t)
+	ingestOrders(t, srv)
+
+	getJSONRecords(t, srv, "/json/missing/records", http.StatusNotFound)
+	getJSONRecords(t, srv, "/json/orders/records?amount[between]=1", http.StatusBadRequest)
+	getJSONRecords(t, srv, "/json/orders/records?limit=0", http.StatusBadRequest)
+	getJSONRecords(t, srv, "/json/orders/records?cursor=***", http.StatusBadRequest)
+}
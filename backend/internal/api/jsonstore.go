@@ -0,0 +1,258 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/database"
+	"github.com/Muneer320/RhinoBox/internal/jsonquery"
+	"github.com/Muneer320/RhinoBox/internal/jsonschema"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+)
+
+// sqlStore is the subset of database.PostgresDB used to load and query SQL-routed batches.
+type sqlStore interface {
+	TableExists(ctx context.Context, table string) (bool, error)
+	CreateTableFromSchema(ctx context.Context, table string, schema map[string]string) error
+	AddMissingColumns(ctx context.Context, table string, schema map[string]string) error
+	BatchInsertJSON(ctx context.Context, table string, docs []map[string]any) error
+	ExecuteSQL(ctx context.Context, query string, args ...any) error
+	QueryJSON(ctx context.Context, table string, q jsonquery.Query) ([]map[string]any, error)
+}
+
+// documentStore is the subset of database.MongoDB used to load and query NoSQL-routed batches.
+type documentStore interface {
+	BulkInsert(ctx context.Context, database, collection string, docs []map[string]any) error
+	CollectionExists(ctx context.Context, database, collection string) (bool, error)
+	FindJSON(ctx context.Context, database, collection string, q jsonquery.Query) ([]map[string]any, error)
+}
+
+var (
+	_ sqlStore      = (*database.PostgresDB)(nil)
+	_ documentStore = (*database.MongoDB)(nil)
+)
+
+// dbCloseTimeout bounds how long shutdown waits for MongoDB to disconnect.
+const dbCloseTimeout = 5 * time.Second
+
+// connectDatabases opens the optional Postgres and MongoDB connections. A
+// database that cannot be reached is logged and skipped, leaving its engine
+// on NDJSON files.
+func (s *Server) connectDatabases(ctx context.Context) {
+	if s.cfg.PostgresURL != "" {
+		pg, err := database.NewPostgresDB(ctx, s.cfg.PostgresURL)
+		if err != nil {
+			s.logger.Error("postgres unavailable, sql batches will be stored as ndjson", slog.Any("err", err))
+		} else {
+			s.postgres = pg
+			s.sqlDB = pg
+			s.logger.Info("postgres connected")
+		}
+	}
+	if s.cfg.MongoURL != "" {
+		mongo, err := database.NewMongoDB(ctx, s.cfg.MongoURL)
+		if err != nil {
+			s.logger.Error("mongodb unavailable, nosql batches will be stored as ndjson", slog.Any("err", err))
+		} else {
+			s.mongo = mongo
+			s.docDB = mongo
+			s.logger.Info("mongodb connected", slog.String("database", s.cfg.MongoDatabase))
+		}
+	}
+}
+
+// closeDatabases releases the connections opened by connectDatabases.
+func (s *Server) closeDatabases() {
+	if s.postgres != nil {
+		s.postgres.Close()
+	}
+	if s.mongo != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), dbCloseTimeout)
+		defer cancel()
+		if err := s.mongo.Close(ctx); err != nil {
+			s.logger.Warn("failed to close mongodb", slog.Any("err", err))
+		}
+	}
+}
+
+// storeJSONBatch loads an analyzed batch into the database chosen by the
+// decision. Without a configured database for the engine the batch is
+// appended to an NDJSON file instead. Every stored batch is recorded in the
+// namespace's schema history; a batch that changes the schema adds a version.
+func (s *Server) storeJSONBatch(ctx context.Context, ts *tenantScope, namespace string, decision jsonschema.Decision, docs []map[string]any) (JSONResult, error) {
+	result := JSONResult{
+		StorageType:       decision.Engine,
+		TableOrCollection: decision.Table,
+		Decision:          decision,
+	}
+
+	unlock := s.lockJSONNamespace(ts.id, decision.Table)
+	defer unlock()
+
+	history, _, err := loadSchemaHistory(ts, decision.Table)
+	if err != nil {
+		return JSONResult{}, fmt.Errorf("load schema history: %w", err)
+	}
+	if history.Table == "" {
+		history.Namespace = namespace
+		history.Table = tenantTableName(ts.id, decision.Table)
+	}
+	previous := history.Current()
+	version, changed := history.Record(jsonschema.ObservedColumns(decision.Table, docs), decision.Engine, len(docs), time.Now())
+
+	switch {
+	case decision.Engine == "sql" && s.sqlDB != nil:
+		table := tenantTableName(ts.id, decision.Table)
+		created, err := s.ensureTable(ctx, table, previous, version.Columns)
+		if err != nil {
+			return JSONResult{}, err
+		}
+		if err := s.sqlDB.BatchInsertJSON(ctx, table, withoutUntypedNulls(docs, version.Columns)); err != nil {
+			return JSONResult{}, fmt.Errorf("insert into %s: %w", table, err)
+		}
+		result.Database = "postgres"
+		result.TableOrCollection = table
+		result.RecordsInserted = len(docs)
+		result.SchemaCreated = created
+	case decision.Engine == "nosql" && s.docDB != nil:
+		dbName := tenantDatabaseName(ts.id, s.cfg.MongoDatabase)
+		if err := s.docDB.BulkInsert(ctx, dbName, decision.Table, docs); err != nil {
+			return JSONResult{}, fmt.Errorf("insert into %s.%s: %w", dbName, decision.Table, err)
+		}
+		result.Database = "mongodb"
+		result.RecordsInserted = len(docs)
+	default:
+		batchRel := ts.storage.NextJSONBatchPath(decision.Engine, namespace)
+		if _, err := ts.storage.AppendNDJSON(batchRel, docs); err != nil {
+			return JSONResult{}, fmt.Errorf("store batch: %w", err)
+		}
+		result.RecordsInserted = len(docs)
+		result.BatchPath = batchRel
+	}
+
+	// The history is saved only once the batch is stored, so a failed load
+	// is retried against the same previous version.
+	if _, err := ts.storage.WriteJSONFile(schemaHistoryPath(decision.Table), history); err != nil {
+		return JSONResult{}, fmt.Errorf("save schema history: %w", err)
+	}
+	result.SchemaVersion = version.Version
+	if changed {
+		result.SchemaChanges = version.Changes
+	}
+	return result, nil
+}
+
+// ensureTable creates table from the namespace's columns, or migrates an
+// existing table from the previous schema version: new columns are added
+// and widened or conflicting columns are altered in place. It reports
+// whether the table was created.
+func (s *Server) ensureTable(ctx context.Context, table string, previous, columns map[string]jsonschema.ColumnDef) (bool, error) {
+	schema := make(map[string]string, len(columns))
+	for name, col := range columns {
+		schema[name] = storageColumnType(col.Type)
+	}
+
+	exists, err := s.sqlDB.TableExists(ctx, table)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		if err := s.sqlDB.CreateTableFromSchema(ctx, table, schema); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if err := s.sqlDB.AddMissingColumns(ctx, table, schema); err != nil {
+		return false, err
+	}
+	for _, stmt := range jsonschema.MigrationDDL(table, nil, storageTypeChanges(previous, columns)) {
+		if err := s.sqlDB.ExecuteSQL(ctx, stmt); err != nil {
+			return false, fmt.Errorf("migrate %s: %w", table, err)
+		}
+	}
+	return false, nil
+}
+
+// withoutUntypedNulls drops null fields that have no column yet: a field
+// only ever seen as null has no type to create the column with, and a
+// missing column reads back as null anyway.
+func withoutUntypedNulls(docs []map[string]any, columns map[string]jsonschema.ColumnDef) []map[string]any {
+	out, copied := docs, false
+	for i, doc := range docs {
+		var trimmed map[string]any
+		for field, value := range doc {
+			if _, ok := columns[field]; ok || value != nil {
+				continue
+			}
+			if trimmed == nil {
+				trimmed = make(map[string]any, len(doc))
+				for k, v := range doc {
+					trimmed[k] = v
+				}
+			}
+			delete(trimmed, field)
+		}
+		if trimmed == nil {
+			continue
+		}
+		if !copied {
+			out, copied = append([]map[string]any(nil), docs...), true
+		}
+		out[i] = trimmed
+	}
+	return out
+}
+
+// storageColumnType maps an inferred type to the column type the loader
+// creates. Columns are nullable and VARCHAR widths are dropped: both are
+// inferred from a single batch and later batches may not fit them.
+func storageColumnType(colType string) string {
+	if strings.HasPrefix(colType, "VARCHAR") {
+		return "TEXT"
+	}
+	return colType
+}
+
+// storageTypeChanges lists the columns whose loaded type differs between two
+// schema versions, ignoring changes absorbed by storageColumnType.
+func storageTypeChanges(previous, columns map[string]jsonschema.ColumnDef) []jsonschema.ColumnChange {
+	var changes []jsonschema.ColumnChange
+	for name, col := range columns {
+		prev, ok := previous[name]
+		if !ok {
+			continue
+		}
+		from, to := storageColumnType(prev.Type), storageColumnType(col.Type)
+		if from == to {
+			continue
+		}
+		kind := jsonschema.ChangeWidened
+		if to == "JSONB" {
+			kind = jsonschema.ChangeConflicting
+		}
+		changes = append(changes, jsonschema.ColumnChange{Column: name, Kind: kind, From: from, To: to})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Column < changes[j].Column })
+	return changes
+}
+
+// tenantTableName prefixes table with the tenant ID so tenants sharing a
+// database never write to each other's tables.
+func tenantTableName(tenantID, table string) string {
+	if tenantID == "" || tenantID == storage.DefaultTenantID {
+		return table
+	}
+	return strings.ReplaceAll(tenantID, "-", "_") + "_" + table
+}
+
+// tenantDatabaseName returns the MongoDB database holding a tenant's collections.
+func tenantDatabaseName(tenantID, base string) string {
+	if tenantID == "" || tenantID == storage.DefaultTenantID {
+		return base
+	}
+	return base + "_" + tenantID
+}
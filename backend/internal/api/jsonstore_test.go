@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Muneer320/RhinoBox/internal/jsonquery"
+	"github.com/Muneer320/RhinoBox/internal/jsonschema"
+)
+
+type fakeSQLStore struct {
+	tables   map[string]map[string]string
+	inserted map[string][]map[string]any
+	executed []string
+}
+
+func newFakeSQLStore() *fakeSQLStore {
+	return &fakeSQLStore{tables: map[string]map[string]string{}, inserted: map[string][]map[string]any{}}
+}
+
+func (f *fakeSQLStore) TableExists(_ context.Context, table string) (bool, error) {
+	_, ok := f.tables[table]
+	return ok, nil
+}
+
+func (f *fakeSQLStore) CreateTableFromSchema(_ context.Context, table string, schema map[string]string) error {
+	f.tables[table] = map[string]string{}
+	for col, typ := range schema {
+		f.tables[table][col] = typ
+	}
+	return nil
+}
+
+func (f *fakeSQLStore) AddMissingColumns(_ context.Context, table string, schema map[string]string) error {
+	for col, typ := range schema {
+		if _, ok := f.tables[table][col]; !ok {
+			f.tables[table][col] = typ
+		}
+	}
+	return nil
+}
+
+func (f *fakeSQLStore) BatchInsertJSON(_ context.Context, table string, docs []map[string]any) error {
+	f.inserted[table] = append(f.inserted[table], docs...)
+	return nil
+}
+
+func (f *fakeSQLStore) ExecuteSQL(_ context.Context, query string, _ ...any) error {
+	f.executed = append(f.executed, query)
+	return nil
+}
+
+func (f *fakeSQLStore) QueryJSON(_ context.Context, table string, q jsonquery.Query) ([]map[string]any, error) {
+	return collectDocs(f.inserted[table], q)
+}
+
+type fakeDocumentStore struct {
+	inserted map[string][]map[string]any
+}
+
+func (f *fakeDocumentStore) BulkInsert(_ context.Context, database, collection string, docs []map[string]any) error {
+	key := database + "." + collection
+	f.inserted[key] = append(f.inserted[key], docs...)
+	return nil
+}
+
+func (f *fakeDocumentStore) CollectionExists(_ context.Context, database, collection string) (bool, error) {
+	_, ok := f.inserted[database+"."+collection]
+	return ok, nil
+}
+
+func (f *fakeDocumentStore) FindJSON(_ context.Context, database, collection string, q jsonquery.Query) ([]map[string]any, error) {
+	return collectDocs(f.inserted[database+"."+collection], q)
+}
+
+// collectDocs evaluates q in memory, standing in for the database translations.
+func collectDocs(docs []map[string]any, q jsonquery.Query) ([]map[string]any, error) {
+	return jsonquery.Collect(q, func(yield func(map[string]any) bool) error {
+		for _, doc := range docs {
+			if !yield(doc) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+type jsonIngestResponse struct {
+	BatchPath         string `json:"batch_path"`
+	Database          string `json:"database"`
+	TableOrCollection string `json:"table_or_collection"`
+	RecordsInserted   int    `json:"records_inserted"`
+	SchemaCreated     bool   `json:"schema_created"`
+
+	SchemaVersion int                       `json:"schema_version"`
+	SchemaChanges []jsonschema.ColumnChange `json:"schema_changes"`
+}
+
+func postJSONIngest(t *testing.T, srv *Server, payload map[string]any) jsonIngestResponse {
+	t.Helper()
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, newJSONRequest(t, "/ingest/json", payload))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var body jsonIngestResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return body
+}
+
+func TestJSONIngestLoadsPostgres(t *testing.T) {
+	srv := newTestServer(t)
+	pg := newFakeSQLStore()
+	srv.sqlDB = pg
+
+	body := postJSONIngest(t, srv, map[string]any{
+		"namespace": "orders",
+		"documents": []map[string]any{
+			{"id": 1, "user_id": 10, "amount": 100.5},
+			{"id": 2, "user_id": 11, "amount": 200.25},
+		},
+	})
+	if body.Database != "postgres" || body.TableOrCollection != "orders" {
+		t.Fatalf("expected postgres table orders, got %+v", body)
+	}
+	if !body.SchemaCreated || body.RecordsInserted != 2 {
+		t.Fatalf("expected created table with 2 records, got %+v", body)
+	}
+	if body.BatchPath != "" {
+		t.Fatalf("expected no NDJSON batch, got %s", body.BatchPath)
+	}
+	if got := pg.tables["orders"]["amount"]; got != "DOUBLE PRECISION" {
+		t.Fatalf("expected amount DOUBLE PRECISION, got %q", got)
+	}
+
+	body = postJSONIngest(t, srv, map[string]any{
+		"namespace": "orders",
+		"documents": []map[string]any{
+			{"id": 3, "user_id": 12, "amount": 5.5, "status": "paid"},
+		},
+	})
+	if body.SchemaCreated {
+		t.Fatalf("expected existing table to be reused")
+	}
+	if _, ok := pg.tables["orders"]["status"]; !ok {
+		t.Fatalf("expected status column to be added, got %v", pg.tables["orders"])
+	}
+	if got := len(pg.inserted["orders"]); got != 3 {
+		t.Fatalf("expected 3 inserted rows, got %d", got)
+	}
+}
+
+func TestJSONIngestLoadsMongo(t *testing.T) {
+	srv := newTestServer(t)
+	srv.cfg.MongoDatabase = "rhinobox"
+	mongo := &fakeDocumentStore{inserted: map[string][]map[string]any{}}
+	srv.docDB = mongo
+
+	body := postJSONIngest(t, srv, map[string]any{
+		"namespace": "activity",
+		"comment":   "flexible schema nosql high write",
+		"documents": []map[string]any{
+			{
+				"user":   map[string]any{"id": "u1", "name": "Alice"},
+				"events": []any{map[string]any{"type": "click", "meta": map[string]any{"at": "2025-11-15"}}},
+			},
+			{
+				"user": map[string]any{"id": "u2"},
+				"events": []any{
+					map[string]any{"type": "view", "meta": map[string]any{"device": "mobile"}},
+					map[string]any{"type": "purchase", "amount": 42, "items": []any{"book", "pen"}},
+				},
+			},
+		},
+	})
+	if body.Database != "mongodb" || body.RecordsInserted != 2 {
+		t.Fatalf("expected 2 records in mongodb, got %+v", body)
+	}
+	if got := len(mongo.inserted["rhinobox.activity"]); got != 2 {
+		t.Fatalf("expected 2 documents in rhinobox.activity, got %d", got)
+	}
+}
+
+func TestJSONIngestFallsBackToNDJSON(t *testing.T) {
+	srv := newTestServer(t)
+	// Only MongoDB is configured, so SQL-routed batches stay on disk.
+	srv.docDB = &fakeDocumentStore{inserted: map[string][]map[string]any{}}
+
+	body := postJSONIngest(t, srv, map[string]any{
+		"namespace": "orders",
+		"documents": []map[string]any{{"id": 1, "amount": 1.0}},
+	})
+	if body.Database != "" || body.BatchPath == "" {
+		t.Fatalf("expected NDJSON fallback, got %+v", body)
+	}
+	if body.SchemaCreated {
+		t.Fatalf("expected schema_created=false without a database")
+	}
+}
+
+func TestTenantTableName(t *testing.T) {
+	if got := tenantTableName("default", "orders"); got != "orders" {
+		t.Fatalf("default tenant: got %s", got)
+	}
+	if got := tenantTableName("acme-corp", "orders"); got != "acme_corp_orders" {
+		t.Fatalf("named tenant: got %s", got)
+	}
+	if got := tenantDatabaseName("acme", "rhinobox"); got != "rhinobox_acme" {
+		t.Fatalf("mongo database: got %s", got)
+	}
+}
@@ -18,7 +18,7 @@ func (s *Server) handleGetNotes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	notes, err := s.fileService.GetNotes(fileID)
+	notes, err := s.tenant(r).fileService.GetNotes(fileID)
 	if err != nil {
 		if err.Error() == "file not found" || err.Error() == "file_id is required" {
 			httpError(w, http.StatusNotFound, err.Error())
@@ -76,7 +76,7 @@ func (s *Server) handleAddNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	note, err := s.fileService.AddNote(fileID, req.Text, req.Author)
+	note, err := s.tenant(r).fileService.AddNote(fileID, req.Text, req.Author)
 	if err != nil {
 		if err.Error() == "file not found" || err.Error() == "file_id is required" {
 			httpError(w, http.StatusNotFound, err.Error())
@@ -133,7 +133,7 @@ func (s *Server) handleUpdateNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	note, err := s.fileService.UpdateNote(fileID, noteID, req.Text)
+	note, err := s.tenant(r).fileService.UpdateNote(fileID, noteID, req.Text)
 	if err != nil {
 		if err.Error() == "file not found" || err.Error() == "file_id is required" {
 			httpError(w, http.StatusNotFound, err.Error())
@@ -180,7 +180,7 @@ func (s *Server) handleDeleteNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := s.fileService.DeleteNote(fileID, noteID)
+	err := s.tenant(r).fileService.DeleteNote(fileID, noteID)
 	if err != nil {
 		if err.Error() == "file not found" || err.Error() == "file_id is required" {
 			httpError(w, http.StatusNotFound, err.Error())
@@ -0,0 +1,407 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/auth"
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+	chi "github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// uploadTokenPurpose prefixes presigned upload token payloads.
+	uploadTokenPurpose = "upload:"
+
+	defaultPresignTTL = 15 * time.Minute
+	maxPresignTTL     = 24 * time.Hour
+	// maxPresignMimeTypes bounds the allowed MIME types of one URL.
+	maxPresignMimeTypes = 32
+	// presignMultipartOverhead is the room left for multipart framing and
+	// form fields on top of a URL's size limit.
+	presignMultipartOverhead = 64 * 1024
+)
+
+// presignRequest is the body of POST /ingest/presign.
+type presignRequest struct {
+	Namespace        string   `json:"namespace,omitempty"`
+	Category         string   `json:"category,omitempty"`
+	Comment          string   `json:"comment,omitempty"`
+	MaxBytes         int64    `json:"max_bytes,omitempty"`
+	AllowedMimeTypes []string `json:"allowed_mime_types,omitempty"`
+	ExpiresIn        string   `json:"expires_in,omitempty"`
+}
+
+// uploadClaims are the constraints signed into a presigned upload URL. The
+// URL uploads a single file; the tenant's store records its nonce once used.
+type uploadClaims struct {
+	Nonce     string   `json:"nonce"`
+	Tenant    string   `json:"tenant"`
+	Namespace string   `json:"namespace,omitempty"`
+	Category  string   `json:"category,omitempty"`
+	Comment   string   `json:"comment,omitempty"`
+	MaxBytes  int64    `json:"max_bytes"`
+	MimeTypes []string `json:"allowed_mime_types,omitempty"`
+	ExpiresAt int64    `json:"expires_at"`
+}
+
+// allowsMimeType reports whether mimeType matches the allowed types, which
+// may end in "/*" to allow a whole family. No allowed types allows any.
+func (c *uploadClaims) allowsMimeType(mimeType string) bool {
+	if len(c.MimeTypes) == 0 {
+		return true
+	}
+	for _, allowed := range c.MimeTypes {
+		if family, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(mimeType, family+"/") {
+				return true
+			}
+		} else if mimeType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffedAliases maps declared MIME types to the name http.DetectContentType
+// uses for the same format.
+var sniffedAliases = map[string]string{
+	"application/gzip":             "application/x-gzip",
+	"application/x-zip-compressed": "application/zip",
+	"application/vnd.rar":          "application/x-rar-compressed",
+	"audio/mp3":                    "audio/mpeg",
+	"audio/ogg":                    "application/ogg",
+	"audio/wav":                    "audio/wave",
+	"audio/x-wav":                  "audio/wave",
+	"image/jpg":                    "image/jpeg",
+	"image/vnd.microsoft.icon":     "image/x-icon",
+	"video/ogg":                    "application/ogg",
+	"video/x-msvideo":              "video/avi",
+}
+
+// sniffedTypes are the types http.DetectContentType recognises by their
+// leading bytes, apart from the generic ones it falls back to.
+var sniffedTypes = map[string]bool{
+	"application/ogg": true, "application/pdf": true, "application/postscript": true,
+	"application/wasm": true, "application/x-gzip": true, "application/x-rar-compressed": true,
+	"audio/aiff": true, "audio/basic": true, "audio/midi": true, "audio/mpeg": true, "audio/wave": true,
+	"font/collection": true, "font/otf": true, "font/ttf": true, "font/woff": true, "font/woff2": true,
+	"image/bmp": true, "image/gif": true, "image/jpeg": true, "image/png": true, "image/webp": true, "image/x-icon": true,
+	"text/html": true, "video/avi": true, "video/mp4": true, "video/webm": true,
+}
+
+// mimeTypeMatchesContent reports whether the declared type of an upload
+// agrees with the type sniffed from its first bytes. Formats the sniffer
+// knows must sniff as themselves; others must sniff as the generic text or
+// binary type that fits them, so zip-based office documents still pass.
+func mimeTypeMatchesContent(declared, sniffed string) bool {
+	if alias, ok := sniffedAliases[declared]; ok {
+		declared = alias
+	}
+	if declared == sniffed {
+		return true
+	}
+	if sniffedTypes[declared] {
+		return false
+	}
+	textual := strings.HasPrefix(declared, "text/") || strings.HasSuffix(declared, "+json") || strings.HasSuffix(declared, "+xml") ||
+		declared == "application/json" || declared == "application/xml" || declared == "application/javascript" ||
+		declared == "application/x-ndjson" || declared == "application/yaml" || declared == "application/x-yaml"
+	switch sniffed {
+	case "text/plain", "text/xml":
+		return textual
+	case "application/octet-stream", "application/zip":
+		return !textual
+	}
+	return false
+}
+
+// normalizeMimeType lowercases a media type and drops its parameters.
+func normalizeMimeType(value string) string {
+	if mediaType, _, err := mime.ParseMediaType(value); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// parseUploadToken checks the signature and expiry of token.
+func (s *Server) parseUploadToken(token string) (*uploadClaims, error) {
+	payload, err := s.signer.Verify(token)
+	if err != nil {
+		return nil, apierrors.Forbidden("invalid upload URL")
+	}
+	raw, ok := strings.CutPrefix(payload, uploadTokenPurpose)
+	if !ok {
+		return nil, apierrors.Forbidden("invalid upload URL")
+	}
+	var claims uploadClaims
+	if err := json.Unmarshal([]byte(raw), &claims); err != nil || claims.Nonce == "" {
+		return nil, apierrors.Forbidden("invalid upload URL")
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, apierrors.Forbidden("upload URL has expired")
+	}
+	return &claims, nil
+}
+
+// handlePresignUpload handles POST /ingest/presign. It mints a URL that
+// uploads one file into the caller's tenant without an API key.
+func (s *Server) handlePresignUpload(w http.ResponseWriter, r *http.Request) {
+	var req presignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+
+	ttl := defaultPresignTTL
+	if req.ExpiresIn != "" {
+		parsed, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || parsed <= 0 || parsed > maxPresignTTL {
+			s.handleError(w, r, apierrors.BadRequestf("invalid expires_in %q: expected a duration between 1s and 24h", req.ExpiresIn))
+			return
+		}
+		ttl = parsed
+	}
+	maxBytes := req.MaxBytes
+	if maxBytes < 0 {
+		s.handleError(w, r, apierrors.BadRequest("max_bytes must not be negative"))
+		return
+	}
+	if maxBytes == 0 || (s.cfg.MaxUploadBytes > 0 && maxBytes > s.cfg.MaxUploadBytes) {
+		maxBytes = s.cfg.MaxUploadBytes
+	}
+	if len(req.AllowedMimeTypes) > maxPresignMimeTypes {
+		s.handleError(w, r, apierrors.BadRequestf("at most %d allowed_mime_types", maxPresignMimeTypes))
+		return
+	}
+	mimeTypes := make([]string, 0, len(req.AllowedMimeTypes))
+	for _, raw := range req.AllowedMimeTypes {
+		mimeType := normalizeMimeType(raw)
+		if family, ok := strings.CutSuffix(mimeType, "/*"); ok && family != "" && !strings.Contains(family, "/") {
+			mimeTypes = append(mimeTypes, mimeType)
+			continue
+		}
+		if _, _, err := mime.ParseMediaType(mimeType); err != nil || !strings.Contains(mimeType, "/") {
+			s.handleError(w, r, apierrors.BadRequestf("invalid MIME type %q", raw))
+			return
+		}
+		mimeTypes = append(mimeTypes, mimeType)
+	}
+
+	scope := s.tenant(r)
+	expiresAt := time.Now().Add(ttl).UTC().Truncate(time.Second)
+	claims := uploadClaims{
+		Nonce:     uuid.NewString(),
+		Tenant:    scope.id,
+		Namespace: strings.TrimSpace(req.Namespace),
+		Category:  strings.TrimSpace(req.Category),
+		Comment:   req.Comment,
+		MaxBytes:  maxBytes,
+		MimeTypes: mimeTypes,
+		ExpiresAt: expiresAt.Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	token := s.signer.Sign(uploadTokenPurpose + string(payload))
+
+	s.logger.Info("presigned upload URL created",
+		slog.String("tenant", scope.id),
+		slog.String("namespace", claims.Namespace),
+		slog.Time("expires_at", expiresAt),
+	)
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"url":         auth.PresignedUploadPathPrefix + token,
+		"token":       token,
+		"methods":     []string{http.MethodPut, http.MethodPost},
+		"expires_at":  expiresAt,
+		"constraints": claims,
+	})
+}
+
+// handlePresignedUpload handles PUT and POST /ingest/presigned/{token}. PUT
+// takes the file as the raw body, named by the filename query parameter or
+// Content-Disposition; POST takes a multipart form with a "file" part, as
+// browsers send it. Each URL stores one file; an upload that fails frees
+// the URL for another attempt.
+func (s *Server) handlePresignedUpload(w http.ResponseWriter, r *http.Request) {
+	claims, err := s.parseUploadToken(chi.URLParam(r, "token"))
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	scope, err := s.tenantScopeFor(claims.Tenant)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, scope))
+	if err := scope.storage.ClaimUploadURL(claims.Nonce, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	stored := false
+	defer func() {
+		if stored {
+			return
+		}
+		if err := scope.storage.ReleaseUploadURL(claims.Nonce); err != nil {
+			s.logger.Warn("failed to release upload URL", slog.Any("err", err))
+		}
+	}()
+
+	var body io.Reader
+	var filename, mimeType string
+	size := r.ContentLength
+	limit := claims.MaxBytes
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	multipartUpload := r.Method == http.MethodPost && mediaType == "multipart/form-data"
+	if multipartUpload && limit > 0 {
+		limit += presignMultipartOverhead
+	}
+	if limit > 0 && r.ContentLength > limit {
+		s.handleError(w, r, uploadTooLarge(claims.MaxBytes))
+		return
+	}
+	if limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	if multipartUpload {
+		part, err := presignedFilePart(r)
+		if err != nil {
+			s.handleError(w, r, presignedReadError(err, claims.MaxBytes))
+			return
+		}
+		defer part.Close()
+		body, filename, mimeType, size = part, part.FileName(), part.Header.Get("Content-Type"), 0
+		if claims.MaxBytes > 0 {
+			// The size limit covers the file itself, not the form around it.
+			body = http.MaxBytesReader(nil, part, claims.MaxBytes)
+		}
+	} else {
+		body, filename, mimeType = r.Body, r.URL.Query().Get("filename"), r.Header.Get("Content-Type")
+		if filename == "" {
+			if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Disposition")); err == nil {
+				filename = params["filename"]
+			}
+		}
+	}
+	filename = filepath.Base(filepath.Clean("/" + filepath.ToSlash(filename)))
+	if filename == "/" || filename == "." {
+		filename = "upload"
+	}
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(body, sniff)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		s.handleError(w, r, presignedReadError(err, claims.MaxBytes))
+		return
+	}
+	mimeType = normalizeMimeType(mimeType)
+	sniffed := normalizeMimeType(http.DetectContentType(sniff[:n]))
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = sniffed
+	}
+	if len(claims.MimeTypes) > 0 && !mimeTypeMatchesContent(mimeType, sniffed) {
+		s.handleError(w, r, apierrors.NewAPIError(apierrors.ErrorCodeUnsupportedMedia,
+			fmt.Sprintf("content of the file does not match its MIME type %s", mimeType)).
+			WithDetails("detected_mime_type", sniffed))
+		return
+	}
+	if !claims.allowsMimeType(mimeType) {
+		s.handleError(w, r, apierrors.NewAPIError(apierrors.ErrorCodeUnsupportedMedia,
+			fmt.Sprintf("MIME type %s is not allowed by this upload URL", mimeType)).
+			WithDetails("allowed_mime_types", claims.MimeTypes))
+		return
+	}
+	if size < 0 {
+		size = 0
+	}
+
+	metadata := map[string]string{}
+	if claims.Namespace != "" {
+		metadata["namespace"] = claims.Namespace
+	}
+	if claims.Comment != "" {
+		metadata["comment"] = claims.Comment
+	}
+	result, err := scope.storage.StoreFile(storage.StoreRequest{
+		Reader:       io.MultiReader(bytes.NewReader(sniff[:n]), body),
+		Filename:     filename,
+		MimeType:     mimeType,
+		Size:         size,
+		Metadata:     metadata,
+		CategoryHint: claims.Category,
+	})
+	if err != nil {
+		s.handleError(w, r, presignedReadError(err, claims.MaxBytes))
+		return
+	}
+	stored = true
+
+	record := mediaIngestRecord(result, claims.Comment)
+	if claims.Namespace != "" {
+		record["namespace"] = claims.Namespace
+	}
+	logRecord := make(map[string]any, len(record)+1)
+	for key, value := range record {
+		logRecord[key] = value
+	}
+	logRecord["presigned"] = true
+	if _, err := scope.storage.AppendNDJSON(filepath.ToSlash(filepath.Join("media", "ingest_log.ndjson")), []map[string]any{logRecord}); err != nil {
+		// log but don't fail request
+		s.logger.Warn("failed to append media log", slog.Any("err", err))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"stored": []map[string]any{record}})
+}
+
+// presignedFilePart returns the first file part of a multipart upload.
+func presignedFilePart(r *http.Request) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, apierrors.BadRequestf("invalid multipart payload: %v", err)
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, apierrors.BadRequest("no file provided")
+		}
+		if err != nil {
+			return nil, apierrors.BadRequestf("invalid multipart payload: %v", err)
+		}
+		if part.FileName() != "" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// presignedReadError reports bodies cut off by the URL's size limit as 413.
+func presignedReadError(err error, maxBytes int64) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return uploadTooLarge(maxBytes)
+	}
+	return err
+}
+
+func uploadTooLarge(maxBytes int64) *apierrors.APIError {
+	return apierrors.NewAPIError(apierrors.ErrorCodeRequestTooLarge,
+		fmt.Sprintf("upload exceeds the %d byte limit of this upload URL", maxBytes))
+}
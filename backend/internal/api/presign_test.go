@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPresignedUploads(t *testing.T) {
+	srv := newAuthTestServer(t)
+	do := func(method, path, contentType string, body []byte, header map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, req)
+		return resp
+	}
+	admin := map[string]string{"Authorization": "Bearer " + testBootstrapKey}
+	presign := func(body string) string {
+		t.Helper()
+		resp := do(http.MethodPost, "/ingest/presign", "application/json", []byte(body), admin)
+		var presigned struct {
+			URL string `json:"url"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&presigned); err != nil || resp.Code != http.StatusCreated {
+			t.Fatalf("presign: %d %v", resp.Code, err)
+		}
+		return presigned.URL
+	}
+	type stored struct {
+		Stored []struct {
+			Hash      string `json:"hash"`
+			Category  string `json:"category"`
+			MimeType  string `json:"mime_type"`
+			Namespace string `json:"namespace"`
+			Duplicate bool   `json:"duplicate"`
+		} `json:"stored"`
+	}
+
+	if resp := do(http.MethodPost, "/ingest/presign", "application/json", []byte(`{}`), nil); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected minting a URL to need an API key, got %d", resp.Code)
+	}
+	if resp := do(http.MethodPost, "/ingest/presign", "application/json", []byte(`{"allowed_mime_types":["pdf"]}`), admin); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid MIME type, got %d", resp.Code)
+	}
+	constraints := `{"namespace":"partner-acme","category":"document","max_bytes":64,"allowed_mime_types":["text/*","application/pdf"],"expires_in":"10m"}`
+	url := presign(constraints)
+
+	// The URL needs no API key and goes through classification and dedup.
+	resp := do(http.MethodPut, url+"?filename=notes.txt", "text/plain; charset=utf-8", []byte("partner notes"), nil)
+	var result stored
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || resp.Code != http.StatusOK || len(result.Stored) != 1 {
+		t.Fatalf("put: %d %v", resp.Code, err)
+	}
+	upload := result.Stored[0]
+	if upload.Namespace != "partner-acme" || upload.MimeType != "text/plain" || !strings.HasPrefix(upload.Category, "documents") {
+		t.Fatalf("unexpected upload %+v", upload)
+	}
+	meta, err := srv.storage.GetFileMetadata(upload.Hash)
+	if err != nil || meta.Metadata["namespace"] != "partner-acme" {
+		t.Fatalf("expected the namespace in the file metadata, got %+v %v", meta, err)
+	}
+
+	// Each URL stores a single file.
+	if resp := do(http.MethodPut, url+"?filename=again.txt", "text/plain", []byte("more notes"), nil); resp.Code != http.StatusGone {
+		t.Fatalf("expected 410 for a used URL, got %d", resp.Code)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "copy.txt")
+	part.Write([]byte("partner notes"))
+	writer.Close()
+	resp = do(http.MethodPost, presign(constraints), writer.FormDataContentType(), body.Bytes(), nil)
+	result = stored{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || resp.Code != http.StatusOK || !result.Stored[0].Duplicate {
+		t.Fatalf("multipart post: %d %+v %v", resp.Code, result, err)
+	}
+
+	// Rejected uploads leave the URL usable.
+	url = presign(constraints)
+	if resp := do(http.MethodPut, url+"?filename=a.png", "image/png", []byte("\x89PNG\r\n\x1a\n"), nil); resp.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for a disallowed type, got %d", resp.Code)
+	}
+	images := presign(`{"allowed_mime_types":["image/*"]}`)
+	if resp := do(http.MethodPut, images+"?filename=fake.png", "image/png", []byte("#!/bin/sh\necho not an image\n"), nil); resp.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for a declared image/png that is not an image, got %d", resp.Code)
+	}
+	if resp := do(http.MethodPut, url+"?filename=fake.pdf", "application/pdf", []byte("plain text"), nil); resp.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for a declared PDF that is not one, got %d", resp.Code)
+	}
+	if resp := do(http.MethodPut, images+"?filename=dot.png", "image/png", []byte("\x89PNG\r\n\x1a\n"), nil); resp.Code != http.StatusOK {
+		t.Fatalf("expected a real PNG to pass, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := do(http.MethodPut, url+"?filename=big.txt", "text/plain", bytes.Repeat([]byte("x"), 65), nil); resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 above max_bytes, got %d", resp.Code)
+	}
+	if resp := do(http.MethodPut, url+"?filename=rows.csv", "text/csv", []byte("a,b\n1,2\n"), nil); resp.Code != http.StatusOK {
+		t.Fatalf("expected CSV declared as text/csv to pass, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := do(http.MethodPut, url+"?filename=rows.csv", "text/csv", []byte("a,b\n3,4\n"), nil); resp.Code != http.StatusGone {
+		t.Fatalf("expected 410 for a used URL, got %d", resp.Code)
+	}
+	if resp := do(http.MethodPut, url[:len(url)-2]+"xx?filename=a.txt", "text/plain", []byte("x"), nil); resp.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a tampered URL, got %d", resp.Code)
+	}
+
+	expired, _ := json.Marshal(uploadClaims{Nonce: "expired", Tenant: "default", MaxBytes: 64, ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	expiredURL := "/ingest/presigned/" + srv.signer.Sign(uploadTokenPurpose+string(expired))
+	if resp := do(http.MethodPut, expiredURL+"?filename=a.txt", "text/plain", []byte("x"), nil); resp.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for an expired URL, got %d", resp.Code)
+	}
+	share := srv.signer.Sign(shareTokenPurpose + ":default:x")
+	if resp := do(http.MethodPut, "/ingest/presigned/"+share+"?filename=a.txt", "text/plain", []byte("x"), nil); resp.Code != http.StatusForbidden {
+		t.Fatalf("expected share tokens to be rejected, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/auth"
+	"github.com/Muneer320/RhinoBox/internal/cache"
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/services"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// savedSearchSorts are the sort_by values of a saved search; an empty value
+// keeps rank order for ranked searches and sorts by upload time otherwise.
+var savedSearchSorts = map[string]bool{"": true, "name": true, "uploaded_at": true, "size": true, "category": true, "mime_type": true}
+
+// savedSearchValues turns saved search parameters back into a query.
+func savedSearchValues(params map[string]string) url.Values {
+	values := make(url.Values, len(params))
+	for key, value := range params {
+		values.Set(key, value)
+	}
+	return values
+}
+
+// savedSearchEvaluator runs saved searches against store the way
+// /files/search runs its parameters.
+func savedSearchEvaluator(store *storage.Manager) services.SmartEvaluator {
+	return func(saved storage.SavedSearch) ([]storage.FileMetadata, error) {
+		search, err := parseFileSearch(savedSearchValues(saved.Params))
+		if err != nil {
+			return nil, err
+		}
+		results, _, err := search.run(store)
+		return results, err
+	}
+}
+
+// newCollectionService creates the collection service of a tenant. Smart
+// collections are evaluated against store and re-evaluated after uploads.
+func newCollectionService(store *storage.Manager, collectionCache *cache.Cache, logger *slog.Logger) *services.CollectionService {
+	collections := services.NewCollectionService(store, collectionCache, logger)
+	collections.SetSmartEvaluator(savedSearchEvaluator(store))
+	store.OnStore(func(storage.FileMetadata) {
+		collections.InvalidateSmartCollection("")
+	})
+	return collections
+}
+
+// validateSavedSearch checks that params parse as /files/search parameters
+// and that the sort is one ListFiles understands.
+func validateSavedSearch(params map[string]string, sortBy, order string) error {
+	if params != nil {
+		if _, err := parseFileSearch(savedSearchValues(params)); err != nil {
+			return err
+		}
+	}
+	if !savedSearchSorts[sortBy] {
+		return apierrors.BadRequestf("invalid sort_by %q: expected name, uploaded_at, size, category or mime_type", sortBy)
+	}
+	if order != "" && order != "asc" && order != "desc" {
+		return apierrors.BadRequestf("invalid order %q: expected asc or desc", order)
+	}
+	return nil
+}
+
+// viewerID identifies who is viewing a saved search, so each API key has its
+// own "new since last viewed" count. Unauthenticated requests share one.
+func viewerID(r *http.Request) string {
+	if key, ok := auth.KeyFromContext(r.Context()); ok {
+		return key.ID
+	}
+	return ""
+}
+
+// handleListSavedSearches handles GET /saved-searches.
+func (s *Server) handleListSavedSearches(w http.ResponseWriter, r *http.Request) {
+	collections, err := s.tenant(r).collectionService.GetSmartCollections(viewerID(r))
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"saved_searches": collections,
+		"count":          len(collections),
+	})
+}
+
+// handleCreateSavedSearch handles POST /saved-searches.
+func (s *Server) handleCreateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	var req storage.SavedSearch
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+	if req.Params == nil {
+		req.Params = map[string]string{}
+	}
+	if err := validateSavedSearch(req.Params, req.SortBy, req.Order); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	scope := s.tenant(r)
+	saved, err := scope.storage.CreateSavedSearch(req)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	s.logger.Info("saved search created", slog.String("id", saved.ID), slog.String("name", saved.Name))
+	s.writeSmartCollection(w, r, scope, saved.ID, http.StatusCreated)
+}
+
+// handleGetSavedSearch handles GET /saved-searches/{search_id}.
+func (s *Server) handleGetSavedSearch(w http.ResponseWriter, r *http.Request) {
+	s.writeSmartCollection(w, r, s.tenant(r), chi.URLParam(r, "search_id"), http.StatusOK)
+}
+
+// handleUpdateSavedSearch handles PATCH /saved-searches/{search_id}.
+func (s *Server) handleUpdateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	var req storage.UpdateSavedSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+	var sortBy, order string
+	if req.SortBy != nil {
+		sortBy = *req.SortBy
+	}
+	if req.Order != nil {
+		order = *req.Order
+	}
+	if err := validateSavedSearch(req.Params, sortBy, order); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	id := chi.URLParam(r, "search_id")
+	scope := s.tenant(r)
+	if _, err := scope.storage.UpdateSavedSearch(id, req); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	scope.collectionService.InvalidateSmartCollection(id)
+	s.writeSmartCollection(w, r, scope, id, http.StatusOK)
+}
+
+// handleDeleteSavedSearch handles DELETE /saved-searches/{search_id}.
+func (s *Server) handleDeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "search_id")
+	scope := s.tenant(r)
+	if err := scope.storage.DeleteSavedSearch(id); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	scope.collectionService.InvalidateSmartCollection(id)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "saved search deleted",
+		"id":      id,
+	})
+}
+
+// handleSavedSearchFiles handles GET /saved-searches/{search_id}/files. It
+// runs the search live, pages the results like GET /files and marks the
+// saved search as viewed; files uploaded since the previous view are flagged
+// as new.
+func (s *Server) handleSavedSearchFiles(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "search_id")
+	scope := s.tenant(r)
+	saved, err := scope.storage.GetSavedSearch(id)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	opts, err := parseListOptions(r)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	if opts.SortBy == "" {
+		opts.SortBy = saved.SortBy
+	}
+	if opts.Order == "" {
+		opts.Order = saved.Order
+	}
+	if err := validateSavedSearch(nil, opts.SortBy, opts.Order); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	search, err := parseFileSearch(savedSearchValues(saved.Params))
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	results, _, err := search.run(scope.storage)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	scope.collectionService.RefreshSmartCollection(*saved, results)
+
+	viewedAt, err := scope.storage.MarkSavedSearchViewed(id, viewerID(r), time.Now())
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	newCount := 0
+	for _, meta := range results {
+		if meta.UploadedAt.After(viewedAt) {
+			newCount++
+		}
+	}
+
+	if opts.SortBy == "" && !search.ranked {
+		opts.SortBy = "uploaded_at"
+	}
+	page := storage.PageFiles(results, opts)
+	resp := fileListResponse(page)
+	for i, entry := range resp["files"].([]map[string]any) {
+		entry["new"] = page.Files[i].UploadedAt.After(viewedAt)
+	}
+	resp["saved_search"] = saved
+	resp["new_since_last_viewed"] = newCount
+	if !viewedAt.IsZero() {
+		resp["last_viewed_at"] = viewedAt.Format(time.RFC3339)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeSmartCollection writes the saved search with id as a smart collection.
+func (s *Server) writeSmartCollection(w http.ResponseWriter, r *http.Request, scope *tenantScope, id string, status int) {
+	collection, err := scope.collectionService.GetSmartCollection(id, viewerID(r))
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, status, collection)
+}
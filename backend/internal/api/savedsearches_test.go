@@ -0,0 +1,109 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSavedSearchEndpoints(t *testing.T) {
+	srv := newTestServer(t)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, req)
+		return resp
+	}
+	type smart struct {
+		ID                 string `json:"id"`
+		DisplayName        string `json:"display_name"`
+		NewSinceLastViewed int    `json:"new_since_last_viewed"`
+		Stats              struct {
+			FileCount int `json:"file_count"`
+		} `json:"stats"`
+	}
+	ingestMediaAs(t, srv, "", "notes.txt", []byte("meeting notes"))
+	ingestMediaAs(t, srv, "", "draft.md", []byte("# draft"))
+
+	if resp := do(http.MethodPost, "/saved-searches", `{"name":"Broken","params":{"q":"ext:"}}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid query, got %d", resp.Code)
+	}
+	if resp := do(http.MethodPost, "/saved-searches", `{"name":"Empty","params":{}}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without parameters, got %d", resp.Code)
+	}
+	resp := do(http.MethodPost, "/saved-searches", `{"name":"Text files","params":{"extension":"txt"}}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", resp.Code, resp.Body.String())
+	}
+	var created smart
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil || created.Stats.FileCount != 1 || created.NewSinceLastViewed != 1 {
+		t.Fatalf("created: %+v %v", created, err)
+	}
+	if resp := do(http.MethodPost, "/saved-searches", `{"name":"text files","params":{"type":"image"}}`); resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a duplicate name, got %d", resp.Code)
+	}
+
+	resp = do(http.MethodGet, "/collections", "")
+	var collections struct {
+		SmartCollections []smart `json:"smart_collections"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&collections); err != nil || len(collections.SmartCollections) != 1 || collections.SmartCollections[0].DisplayName != "Text files" {
+		t.Fatalf("collections: %+v %v", collections, err)
+	}
+
+	type fileList struct {
+		Files []struct {
+			Name string `json:"name"`
+			New  bool   `json:"new"`
+		} `json:"files"`
+		NewSinceLastViewed int `json:"new_since_last_viewed"`
+	}
+	listFiles := func() fileList {
+		t.Helper()
+		resp := do(http.MethodGet, "/saved-searches/"+created.ID+"/files", "")
+		var list fileList
+		if err := json.NewDecoder(resp.Body).Decode(&list); err != nil || resp.Code != http.StatusOK {
+			t.Fatalf("saved search files: %d %v", resp.Code, err)
+		}
+		return list
+	}
+	if list := listFiles(); len(list.Files) != 1 || !list.Files[0].New || list.NewSinceLastViewed != 1 {
+		t.Fatalf("first view: %+v", list)
+	}
+	if list := listFiles(); list.Files[0].New || list.NewSinceLastViewed != 0 {
+		t.Fatalf("second view: %+v", list)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	ingestMediaAs(t, srv, "", "todo.txt", []byte("buy milk"))
+	resp = do(http.MethodGet, "/saved-searches/"+created.ID, "")
+	var detail smart
+	if err := json.NewDecoder(resp.Body).Decode(&detail); err != nil || detail.Stats.FileCount != 2 || detail.NewSinceLastViewed != 1 {
+		t.Fatalf("after upload: %+v %v", detail, err)
+	}
+	if list := listFiles(); len(list.Files) != 2 || list.Files[0].Name != "todo.txt" || !list.Files[0].New || list.Files[1].New {
+		t.Fatalf("view after upload: %+v", list)
+	}
+
+	if resp := do(http.MethodPatch, "/saved-searches/"+created.ID, `{"params":{"extension":"md"}}`); resp.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", resp.Code, resp.Body.String())
+	}
+	if list := listFiles(); len(list.Files) != 1 || list.Files[0].Name != "draft.md" {
+		t.Fatalf("after update: %+v", list)
+	}
+
+	if resp := do(http.MethodDelete, "/saved-searches/"+created.ID, ""); resp.Code != http.StatusOK {
+		t.Fatalf("delete: %d", resp.Code)
+	}
+	if resp := do(http.MethodGet, "/saved-searches/"+created.ID, ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", resp.Code)
+	}
+}
@@ -742,3 +742,79 @@ func TestFileSearch_ByMimeType(t *testing.T) {
 		t.Errorf("expected at least 2 PDF results, got %d", payload.Count)
 	}
 }
+
+func TestFileSearch_QueryLanguage(t *testing.T) {
+	srv := newTestServer(t)
+
+	hashes := make(map[string]string)
+	for name, content := range map[string][]byte{
+		"launch.png": encodeTestPNG(t, 40, 30),
+		"orbit.png":  encodeTestPNG(t, 20, 20),
+		"brief.txt":  []byte("mission brief"),
+	} {
+		resp := ingestMediaAs(t, srv, "", name, content)
+		var ingest struct {
+			Stored []struct {
+				Hash string `json:"hash"`
+			} `json:"stored"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&ingest); err != nil || len(ingest.Stored) != 1 {
+			t.Fatalf("ingest %s: %d %v", name, resp.Code, err)
+		}
+		hashes[name] = ingest.Stored[0].Hash
+	}
+	for _, name := range []string{"launch.png", "brief.txt"} {
+		if _, err := srv.storage.UpdateFileMetadata(storage.MetadataUpdateRequest{Hash: hashes[name], Action: "merge", Metadata: map[string]string{"project": "apollo"}}); err != nil {
+			t.Fatalf("set metadata on %s: %v", name, err)
+		}
+	}
+
+	search := func(query string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files/search?q="+url.QueryEscape(query), nil))
+		return resp
+	}
+
+	resp := search(`meta.project:apollo AND (ext:png OR ext:jpg) width>=30 uploaded:2000..`)
+	var result struct {
+		Query   string           `json:"query"`
+		Count   int              `json:"count"`
+		Results []map[string]any `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if result.Count != 1 || result.Results[0]["name"] != "launch.png" {
+		t.Fatalf("expected only launch.png, got %+v", result)
+	}
+	if _, ok := result.Results[0]["score"]; ok {
+		t.Fatalf("queries without content terms should not be scored")
+	}
+	if !strings.Contains(result.Query, "meta.project:apollo AND (ext:png OR ext:jpg)") {
+		t.Fatalf("expected the canonical query to be echoed, got %q", result.Query)
+	}
+
+	// The q parameter combines with the classic filters.
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files/search?extension=txt&q="+url.QueryEscape("-ext:png"), nil))
+	if !strings.Contains(resp.Body.String(), "brief.txt") || strings.Contains(resp.Body.String(), "orbit.png") {
+		t.Fatalf("expected q to be ANDed with extension, got %s", resp.Body.String())
+	}
+
+	resp = search(`size>10MB AND (ext:png`)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a syntax error, got %d", resp.Code)
+	}
+	var apiErr struct {
+		Error struct {
+			Message string         `json:"message"`
+			Details map[string]any `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if !strings.Contains(apiErr.Error.Message, "missing ')'") || apiErr.Error.Details["position"] != float64(15) {
+		t.Fatalf("expected a positioned syntax error, got %+v", apiErr)
+	}
+}
@@ -4,20 +4,26 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Muneer320/RhinoBox/internal/auth"
 	"github.com/Muneer320/RhinoBox/internal/cache"
 	"github.com/Muneer320/RhinoBox/internal/config"
+	"github.com/Muneer320/RhinoBox/internal/database"
 	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/filequery"
 	"github.com/Muneer320/RhinoBox/internal/jsonschema"
 	"github.com/Muneer320/RhinoBox/internal/media"
 	"github.com/Muneer320/RhinoBox/internal/middleware"
@@ -25,6 +31,7 @@ import (
 	respmw "github.com/Muneer320/RhinoBox/internal/middleware"
 	validationmw "github.com/Muneer320/RhinoBox/internal/middleware"
 	"github.com/Muneer320/RhinoBox/internal/queue"
+	"github.com/Muneer320/RhinoBox/internal/retry"
 	"github.com/Muneer320/RhinoBox/internal/service"
 	"github.com/Muneer320/RhinoBox/internal/services"
 	"github.com/Muneer320/RhinoBox/internal/storage"
@@ -45,14 +52,43 @@ type Server struct {
 	server           *http.Server
 	errorHandler     *errormiddleware.ErrorHandler
 	rateLimiter      *middleware.RateLimiter
+	apiKeys          *auth.KeyStore
+	signer           *auth.Signer
+	tenants          *storage.TenantRegistry
+	defaultTenant    *tenantScope
+	tenantScopes     map[string]*tenantScope
+	tenantsMu        sync.Mutex
+	stopPurger       chan struct{}
+	purgerDone       chan struct{}
+	stopSweeper      chan struct{}
+	sweeperDone      chan struct{}
+
+	// Optional databases that analyzed JSON batches are loaded into; when
+	// nil the batches are kept as NDJSON files.
+	postgres *database.PostgresDB
+	mongo    *database.MongoDB
+	sqlDB    sqlStore
+	docDB    documentStore
+
+	// namespaceLocks holds a *sync.Mutex per tenant JSON namespace.
+	namespaceLocks sync.Map
+
+	// writeGate is read-held by mutating requests and write-held while a
+	// backup is taken.
+	writeGate sync.RWMutex
 }
 
 // NewServer constructs the HTTP server with routing and dependencies.
 func NewServer(cfg config.Config, logger *slog.Logger) (*Server, error) {
-	store, err := storage.NewManager(cfg.DataDir)
+	backend, err := newStorageBackend(cfg)
 	if err != nil {
 		return nil, err
 	}
+	store, err := storage.NewManagerWithBackend(cfg.DataDir, backend)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("storage backend ready", slog.String("backend", backend.Name()))
 
 	fileService := service.NewFileService(store, logger)
 	errorHandler := errormiddleware.NewErrorHandler(logger)
@@ -66,7 +102,26 @@ func NewServer(cfg config.Config, logger *slog.Logger) (*Server, error) {
 	}
 
 	// Initialize collection service
-	collectionService := services.NewCollectionService(store, cacheInstance, logger)
+	collectionService := newCollectionService(store, cacheInstance, logger)
+
+	tenants, err := storage.NewTenantRegistry(cfg.DataDir, store, tenantBackendFactory(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize tenant registry: %w", err)
+	}
+
+	apiKeys, err := auth.NewKeyStore(cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize api key store: %w", err)
+	}
+	signer, err := auth.LoadSigner(cfg.DataDir, cfg.SigningSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize signing key: %w", err)
+	}
+	if cfg.AuthEnabled {
+		if err := bootstrapAdminKey(apiKeys, cfg.AuthBootstrapKey, logger); err != nil {
+			return nil, err
+		}
+	}
 
 	s := &Server{
 		cfg:              cfg,
@@ -75,13 +130,89 @@ func NewServer(cfg config.Config, logger *slog.Logger) (*Server, error) {
 		storage:          store,
 		fileService:      fileService,
 		collectionService: collectionService,
-		jobQueue:         nil, // TODO: Initialize when async endpoints are needed
 		errorHandler:      errorHandler,
+		apiKeys:          apiKeys,
+		signer:           signer,
+		tenants:          tenants,
+		defaultTenant: &tenantScope{
+			id:                storage.DefaultTenantID,
+			storage:           store,
+			fileService:       fileService,
+			collectionService: collectionService,
+			collectionCache:   cacheInstance,
+		},
+		tenantScopes: make(map[string]*tenantScope),
+	}
+	s.configureStore(storage.DefaultTenantID, store)
+
+	s.connectDatabases(context.Background())
+
+	retryCfg := retry.DefaultConfig()
+	if cfg.QueueRetryDelay > 0 {
+		retryCfg.InitialDelay = cfg.QueueRetryDelay
+	}
+	if cfg.QueueRetryMaxDelay > 0 {
+		retryCfg.MaxDelay = cfg.QueueRetryMaxDelay
+	}
+	mediaProcessor := queue.NewMediaProcessor(s.storeForJob)
+	jobQueue, err := queue.New(queue.Config{
+		MaxWorkers:  cfg.QueueWorkers,
+		PersistPath: filepath.Join(cfg.DataDir, "jobs"),
+		MaxRetries:  cfg.QueueMaxRetries,
+		Retry:       retryCfg,
+	}, queue.Dispatcher{
+		queue.JobTypeMedia:          mediaProcessor,
+		queue.JobTypeBatch:          mediaProcessor,
+		queue.JobTypeThumbnail:      queue.NewThumbnailProcessor(s.storeForJob),
+		queue.JobTypeTextExtraction: queue.NewTextExtractionProcessor(s.storeForJob),
+	})
+	if err != nil {
+		s.closeDatabases()
+		return nil, fmt.Errorf("failed to initialize job queue: %w", err)
 	}
+	s.jobQueue = jobQueue
+
 	s.routes()
+	s.startTrashPurger()
+	s.startUploadSweeper()
 	return s, nil
 }
 
+// newStorageBackend selects the blob backend from configuration, wrapping it
+// with content-defined chunking when enabled.
+func newStorageBackend(cfg config.Config) (storage.Backend, error) {
+	var backend storage.Backend
+	switch cfg.Storage.Backend {
+	case "", "local":
+		backend = storage.NewLocalBackend(cfg.DataDir)
+	case "s3":
+		s3, err := storage.NewS3Backend(storage.S3Config{
+			Endpoint:  cfg.Storage.S3Endpoint,
+			Region:    cfg.Storage.S3Region,
+			Bucket:    cfg.Storage.S3Bucket,
+			AccessKey: cfg.Storage.S3AccessKey,
+			SecretKey: cfg.Storage.S3SecretKey,
+			Prefix:    cfg.Storage.S3Prefix,
+			PathStyle: cfg.Storage.S3PathStyle,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize s3 backend: %w", err)
+		}
+		backend = s3
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+
+	if !cfg.Storage.Chunking {
+		return backend, nil
+	}
+	chunked, err := storage.NewChunkedBackend(cfg.DataDir, backend, cfg.Storage.ChunkAvgKB*1024)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize chunked storage: %w", err)
+	}
+	return chunked, nil
+}
+
 // setupValidation configures validation middleware
 func (s *Server) setupValidation() *validationmw.Validator {
 	validator := validationmw.NewValidator(s.logger)
@@ -95,9 +226,21 @@ func (s *Server) Stop() {
 	if s.rateLimiter != nil {
 		s.rateLimiter.Stop()
 	}
-	// Job queue shutdown will be implemented when async endpoints are added
+	s.stopTrashPurger()
+	s.stopUploadSweeper()
 	if s.jobQueue != nil {
-		// s.jobQueue.Shutdown() // TODO: Implement when queue is initialized
+		s.jobQueue.Stop()
+	}
+	s.closeDatabases()
+	if s.tenants != nil {
+		if err := s.closeTenants(); err != nil {
+			s.logger.Warn("failed to close tenant storage", slog.Any("err", err))
+		}
+	}
+	if s.storage != nil {
+		if err := s.storage.Close(); err != nil {
+			s.logger.Warn("failed to close storage", slog.Any("err", err))
+		}
 	}
 }
 
@@ -113,6 +256,16 @@ func (s *Server) routes() {
 	ipFilter := middleware.NewIPFilterMiddleware(s.cfg.Security, s.logger)
 	r.Use(ipFilter.Handler)
 
+	// 1b. API key authentication (when RHINOBOX_AUTH_ENABLED is set)
+	authn := middleware.NewAuthMiddleware(s.cfg.AuthEnabled, s.apiKeys, s.errorHandler, s.logger)
+	r.Use(authn.Handler)
+
+	// 1c. Tenant resolution (after auth so pinned keys decide the tenant)
+	r.Use(s.resolveTenant)
+
+	// 1d. Hold writes back while a backup runs
+	r.Use(s.gateWrites)
+
 	// 2. Request Size Limit (early - before body is read)
 	requestSizeLimit := middleware.NewRequestSizeLimitMiddleware(s.cfg.Security, s.logger)
 	r.Use(requestSizeLimit.Handler)
@@ -149,27 +302,119 @@ func (s *Server) routes() {
 	r.Post("/ingest", s.handleUnifiedIngest)
 	r.Post("/ingest/media", s.handleMediaIngest)
 	r.Post("/ingest/json", s.handleJSONIngest)
+	r.Get("/json/{namespace}/records", s.handleJSONRecords)
+	r.Get("/json/{namespace}/schema/history", s.handleJSONSchemaHistory)
+	r.Post("/ingest/async", s.handleAsyncIngest)
+	r.Post("/ingest/media/async", s.handleMediaIngestAsync)
+
+	// Resumable uploads (tus 1.0)
+	r.Options("/ingest/uploads", s.handleTusOptions)
+	r.Post("/ingest/uploads", s.handleTusCreate)
+	r.Head("/ingest/uploads/{upload_id}", s.handleTusHead)
+	r.Patch("/ingest/uploads/{upload_id}", s.handleTusPatch)
+	r.Delete("/ingest/uploads/{upload_id}", s.handleTusTerminate)
+	r.Get("/ingest/uploads/{upload_id}", s.handleUploadStatus)
+
+	// Presigned uploads: minted with an API key, used without one
+	r.Post("/ingest/presign", s.handlePresignUpload)
+	r.Put("/ingest/presigned/{token}", s.handlePresignedUpload)
+	r.Post("/ingest/presigned/{token}", s.handlePresignedUpload)
+
 	r.Patch("/files/rename", s.handleFileRename)
 	// More specific routes must come before parameterized routes
 	r.Get("/files", s.handleGetFiles)
 	r.Get("/files/type/{type}", s.handleGetFilesByType)
 	r.Get("/files/search", s.handleFileSearch)
+	r.Post("/files/search/rebuild", s.handleRebuildContentIndex)
 	r.Get("/files/download", s.handleFileDownload)
 	r.Get("/files/metadata", s.handleFileMetadata)
 	r.Get("/files/stream", s.handleFileStream)
+	r.Post("/files/archive", s.handleFileArchive)
 	r.Delete("/files/{file_id}", s.handleFileDelete)
 	r.Patch("/files/{file_id}/metadata", s.handleMetadataUpdate)
 	r.Post("/files/metadata/batch", s.handleBatchMetadataUpdate)
 
+	// Tags
+	r.Get("/tags", s.handleListTags)
+	r.Post("/files/tags", s.handleTagFiles)
+
+	// Virtual folders
+	r.Get("/folders", s.handleListFolders)
+	r.Post("/folders", s.handleCreateFolder)
+	r.Get("/folders/{folder_id}", s.handleGetFolder)
+	r.Patch("/folders/{folder_id}", s.handleUpdateFolder)
+	r.Delete("/folders/{folder_id}", s.handleDeleteFolder)
+	r.Get("/folders/{folder_id}/files", s.handleListFolderFiles)
+	r.Post("/folders/{folder_id}/files", s.handleAddFolderFiles)
+	r.Post("/folders/{folder_id}/files/remove", s.handleRemoveFolderFiles)
+	r.Put("/folders/{folder_id}/order", s.handleReorderFolder)
+	r.Get("/files/{file_id}/folders", s.handleGetFileFolders)
+
+	// Share links: managed with an API key, served at /s/{token} without one
+	r.Post("/files/{file_id}/shares", s.handleCreateShare)
+	r.Get("/files/{file_id}/shares", s.handleListFileShares)
+	r.Get("/shares", s.handleListShares)
+	r.Get("/shares/{share_id}", s.handleGetShare)
+	r.Delete("/shares/{share_id}", s.handleRevokeShare)
+	r.Get("/s/{token}", s.handleShareDownload)
+	r.Get("/s/{token}/stream", s.handleShareStream)
+
+	// Browsing stored archives
+	r.Get("/files/{file_id}/archive/entries", s.handleArchiveEntries)
+	r.Get("/files/{file_id}/archive/entry", s.handleArchiveEntry)
+
+	// Saved searches (smart collections)
+	r.Get("/saved-searches", s.handleListSavedSearches)
+	r.Post("/saved-searches", s.handleCreateSavedSearch)
+	r.Get("/saved-searches/{search_id}", s.handleGetSavedSearch)
+	r.Patch("/saved-searches/{search_id}", s.handleUpdateSavedSearch)
+	r.Delete("/saved-searches/{search_id}", s.handleDeleteSavedSearch)
+	r.Get("/saved-searches/{search_id}/files", s.handleSavedSearchFiles)
+
+	// Image thumbnails
+	r.Get("/files/{file_id}/thumbnail", s.handleGetThumbnail)
+	r.Post("/thumbnails/regenerate", s.handleRegenerateThumbnails)
+
+	// Text extracted from documents for content search
+	r.Get("/files/{file_id}/text", s.handleGetExtractedText)
+	r.Post("/files/text/reextract", s.handleReextractText)
+
+	// Background jobs
+	r.Get("/jobs", s.handleListJobs)
+	r.Get("/jobs/stats", s.handleJobStats)
+	r.Get("/jobs/dead-letter", s.handleListDeadLetters)
+	r.Post("/jobs/dead-letter/{job_id}/replay", s.handleReplayJob)
+	r.Get("/jobs/{job_id}", s.handleJobStatus)
+	r.Get("/jobs/{job_id}/result", s.handleJobResult)
+	r.Delete("/jobs/{job_id}", s.handleCancelJob)
+
 	// Notes endpoints
 	r.Get("/files/{file_id}/notes", s.handleGetNotes)
 	r.Post("/files/{file_id}/notes", s.handleAddNote)
 	r.Patch("/files/{file_id}/notes/{note_id}", s.handleUpdateNote)
 	r.Delete("/files/{file_id}/notes/{note_id}", s.handleDeleteNote)
 
+	// Trash (soft-deleted files)
+	r.Get("/trash", s.handleListTrash)
+	r.Post("/trash/{hash}/restore", s.handleRestoreTrash)
+
 	r.Get("/statistics", s.handleStatistics)
 	r.Get("/collections", s.handleGetCollections)
 	r.Get("/collections/{type}/stats", s.handleGetCollectionStats)
+
+	// API key administration (admin scope)
+	r.Get("/admin/keys", s.handleListAPIKeys)
+	r.Post("/admin/keys", s.handleCreateAPIKey)
+	r.Delete("/admin/keys/{key_id}", s.handleRevokeAPIKey)
+
+	// Tenant administration (admin scope)
+	r.Get("/admin/tenants", s.handleListTenants)
+	r.Post("/admin/tenants", s.handleCreateTenant)
+	r.Get("/admin/tenants/{tenant_id}", s.handleGetTenant)
+	r.Patch("/admin/tenants/{tenant_id}", s.handleUpdateTenantQuota)
+
+	// Instance backup (admin scope)
+	r.Post(backupPath, s.handleBackup)
 }
 
 
@@ -234,7 +479,7 @@ func (s *Server) handleMediaIngest(w http.ResponseWriter, r *http.Request) {
 
 	for _, headers := range r.MultipartForm.File {
 		for _, header := range headers {
-			record, err := s.storeSingleFile(header, categoryHint, comment)
+			record, err := s.storeSingleFile(s.tenant(r), header, categoryHint, comment)
 			if err != nil {
 				s.handleError(w, r, err)
 				return
@@ -245,7 +490,7 @@ func (s *Server) handleMediaIngest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if len(records) > 0 {
-		if _, err := s.storage.AppendNDJSON(filepath.ToSlash(filepath.Join("media", "ingest_log.ndjson")), records); err != nil {
+		if _, err := s.tenant(r).storage.AppendNDJSON(filepath.ToSlash(filepath.Join("media", "ingest_log.ndjson")), records); err != nil {
 			// log but don't fail request
 			s.logger.Warn("failed to append media log", slog.Any("err", err))
 		}
@@ -260,7 +505,7 @@ func (s *Server) handleMediaIngestParallel(w http.ResponseWriter, r *http.Reques
 	defer cancel()
 
 	// Create worker pool
-	pool := media.NewWorkerPool(ctx, s.storage, 0) // 0 = auto-detect worker count
+	pool := media.NewWorkerPool(ctx, s.tenant(r).storage, 0) // 0 = auto-detect worker count
 	if err := pool.Start(); err != nil {
 		s.handleError(w, r, apierrors.InternalServerErrorf("start worker pool: %v", err))
 		return
@@ -329,7 +574,7 @@ func (s *Server) handleMediaIngestParallel(w http.ResponseWriter, r *http.Reques
 
 	// Log batch processing
 	if len(records) > 0 {
-		if _, err := s.storage.AppendNDJSON(filepath.ToSlash(filepath.Join("media", "ingest_log.ndjson")), records); err != nil {
+		if _, err := s.tenant(r).storage.AppendNDJSON(filepath.ToSlash(filepath.Join("media", "ingest_log.ndjson")), records); err != nil {
 			s.logger.Warn("failed to append media log", slog.Any("err", err))
 		}
 	}
@@ -337,7 +582,7 @@ func (s *Server) handleMediaIngestParallel(w http.ResponseWriter, r *http.Reques
 	writeJSON(w, http.StatusOK, map[string]any{"stored": responses})
 }
 
-func (s *Server) storeSingleFile(header *multipart.FileHeader, categoryHint, comment string) (map[string]any, error) {
+func (s *Server) storeSingleFile(ts *tenantScope, header *multipart.FileHeader, categoryHint, comment string) (map[string]any, error) {
 	file, err := header.Open()
 	if err != nil {
 		return nil, fmt.Errorf("open file: %w", err)
@@ -359,7 +604,7 @@ func (s *Server) storeSingleFile(header *multipart.FileHeader, categoryHint, com
 		metadata["comment"] = comment
 	}
 
-	result, err := s.storage.StoreFile(storage.StoreRequest{
+	result, err := ts.storage.StoreFile(storage.StoreRequest{
 		Reader:       reader,
 		Filename:     header.Filename,
 		MimeType:     mimeType,
@@ -370,7 +615,11 @@ func (s *Server) storeSingleFile(header *multipart.FileHeader, categoryHint, com
 	if err != nil {
 		return nil, err
 	}
+	return mediaIngestRecord(result, comment), nil
+}
 
+// mediaIngestRecord builds the response and ingest log entry for a stored file.
+func mediaIngestRecord(result *storage.StoreResult, comment string) map[string]any {
 	mediaType := result.Metadata.Category
 	if idx := strings.Index(mediaType, "/"); idx > 0 {
 		mediaType = mediaType[:idx]
@@ -390,7 +639,7 @@ func (s *Server) storeSingleFile(header *multipart.FileHeader, categoryHint, com
 	if result.Duplicate {
 		record["duplicate"] = true
 	}
-	return record, nil
+	return record
 }
 
 func (s *Server) handleJSONIngest(w http.ResponseWriter, r *http.Request) {
@@ -418,9 +667,9 @@ func (s *Server) handleJSONIngest(w http.ResponseWriter, r *http.Request) {
 	analysis = jsonschema.IncorporateCommentHints(analysis, req.Comment)
 	decision := jsonschema.DecideStorage(req.Namespace, docs, summary, analysis)
 
-	batchRel := s.storage.NextJSONBatchPath(decision.Engine, req.Namespace)
-	if _, err := s.storage.AppendNDJSON(batchRel, docs); err != nil {
-		s.handleError(w, r, apierrors.InternalServerErrorf("store batch: %v", err))
+	stored, err := s.storeJSONBatch(r.Context(), s.tenant(r), req.Namespace, decision, docs)
+	if err != nil {
+		s.handleError(w, r, apierrors.InternalServerErrorf("%v", err))
 		return
 	}
 
@@ -433,8 +682,7 @@ func (s *Server) handleJSONIngest(w http.ResponseWriter, r *http.Request) {
 			"summary":  decision.Summary,
 			"analysis": decision.Analysis,
 		}
-		var err error
-		schemaPath, err = s.storage.WriteJSONFile(filepath.Join("json", "sql", decision.Table, "schema.json"), schemaPayload)
+		schemaPath, err = s.tenant(r).storage.WriteJSONFile(filepath.Join("json", "sql", decision.Table, "schema.json"), schemaPayload)
 		if err != nil {
 			s.handleError(w, r, apierrors.InternalServerErrorf("write schema: %v", err))
 			return
@@ -448,19 +696,27 @@ func (s *Server) handleJSONIngest(w http.ResponseWriter, r *http.Request) {
 		"decision":    decision.Engine,
 		"confidence":  decision.Confidence,
 		"documents":   len(docs),
-		"batch_path":  batchRel,
+		"batch_path":  stored.BatchPath,
+		"database":    stored.Database,
+		"table":       stored.TableOrCollection,
 		"schema_path": schemaPath,
 		"ingested_at": time.Now().UTC().Format(time.RFC3339),
 	}
-	if _, err := s.storage.AppendNDJSON(filepath.Join("json", "ingest_log.ndjson"), []map[string]any{logRecord}); err != nil {
+	if _, err := s.tenant(r).storage.AppendNDJSON(filepath.Join("json", "ingest_log.ndjson"), []map[string]any{logRecord}); err != nil {
 		s.logger.Warn("failed to append json log", slog.Any("err", err))
 	}
 
 	writeJSON(w, http.StatusOK, map[string]any{
-		"decision":    decision,
-		"batch_path":  batchRel,
-		"schema_path": schemaPath,
-		"documents":   len(docs),
+		"decision":            decision,
+		"batch_path":          stored.BatchPath,
+		"schema_path":         schemaPath,
+		"documents":           len(docs),
+		"database":            stored.Database,
+		"table_or_collection": stored.TableOrCollection,
+		"records_inserted":    stored.RecordsInserted,
+		"schema_created":      stored.SchemaCreated,
+		"schema_version":      stored.SchemaVersion,
+		"schema_changes":      stored.SchemaChanges,
 	})
 }
 
@@ -481,7 +737,7 @@ func (s *Server) handleFileRename(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := s.storage.RenameFile(req)
+	result, err := s.tenant(r).storage.RenameFile(req)
 	if err != nil {
 		s.handleError(w, r, err)
 		return
@@ -508,7 +764,7 @@ func (s *Server) handleFileDelete(w http.ResponseWriter, r *http.Request) {
 		Hash: fileID,
 	}
 
-	result, err := s.storage.DeleteFile(req)
+	result, err := s.tenant(r).storage.DeleteFile(req)
 	if err != nil {
 		s.handleError(w, r, err)
 		return
@@ -544,7 +800,7 @@ func (s *Server) handleMetadataUpdate(w http.ResponseWriter, r *http.Request) {
 		req.Action = "merge"
 	}
 
-	result, err := s.storage.UpdateFileMetadata(req)
+	result, err := s.tenant(r).storage.UpdateFileMetadata(req)
 	if err != nil {
 		s.handleError(w, r, err)
 		return
@@ -580,7 +836,7 @@ func (s *Server) handleBatchMetadataUpdate(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-results, errs := s.storage.BatchUpdateFileMetadata(req.Updates)
+results, errs := s.tenant(r).storage.BatchUpdateFileMetadata(req.Updates)
 
 // Add timestamps and count successes/failures
 successCount := 0
@@ -625,14 +881,16 @@ writeJSON(w, http.StatusOK, map[string]any{
 }
 
 func (s *Server) handleFileSearch(w http.ResponseWriter, r *http.Request) {
-	// Get search query from URL parameter
-	query := r.URL.Query().Get("name")
-	if query == "" {
-		s.handleError(w, r, apierrors.BadRequest("name query parameter is required"))
+	search, err := parseFileSearch(r.URL.Query())
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	results, matches, err := search.run(s.tenant(r).storage)
+	if err != nil {
+		s.handleError(w, r, err)
 		return
 	}
-
-	results := s.storage.FindByOriginalName(query)
 
 	// Transform results to include frontend-friendly field names
 	formattedResults := make([]map[string]any, len(results))
@@ -652,14 +910,195 @@ func (s *Server) handleFileSearch(w http.ResponseWriter, r *http.Request) {
 			"modified_at":   meta.UploadedAt,
 			"ingested_at":   meta.UploadedAt,
 			"metadata":      meta.Metadata,
+			"tags":          meta.Tags,
+		}
+		if meta.Extracted != nil {
+			formattedResults[i]["extracted"] = meta.Extracted
+		}
+		if search.ranked {
+			formattedResults[i]["score"] = matches[i].Score
+			if matches[i].Snippet != "" {
+				formattedResults[i]["snippet"] = matches[i].Snippet
+			}
 		}
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
-		"query":   query,
+	response := map[string]any{
+		"filters": search.applied,
 		"results": formattedResults,
 		"count":   len(formattedResults),
-	})
+	}
+	if search.expr != nil {
+		// The canonical form shows how the query was grouped.
+		response["query"] = search.expr.String()
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// fileSearch is a parsed set of /files/search parameters.
+type fileSearch struct {
+	filters storage.SearchFilters
+	applied map[string]string
+	expr    filequery.Expr
+	ranked  bool
+}
+
+// parseFileSearch parses and validates /files/search parameters, including
+// the q query.
+func parseFileSearch(query url.Values) (*fileSearch, error) {
+	filters, applied, err := parseSearchFilters(query)
+	if err != nil {
+		return nil, err
+	}
+	search := &fileSearch{filters: filters, applied: applied, ranked: filters.ContentSearch != ""}
+	if q := strings.TrimSpace(query.Get("q")); q != "" {
+		if search.expr, err = filequery.Parse(q); err != nil {
+			return nil, searchQueryError(q, err)
+		}
+		applied["q"] = q
+		filequery.Walk(search.expr, func(t *filequery.Term) {
+			search.ranked = search.ranked || t.Field == "content"
+		})
+	}
+	if len(applied) == 0 {
+		return nil, apierrors.BadRequest("at least one filter is required (q, name, extension, type, category, mime_type, tag, content, date_from, date_to, or an extracted metadata filter)")
+	}
+	return search, nil
+}
+
+// run evaluates the search against store. Ranked searches also return their
+// content matches, in rank order.
+func (fs *fileSearch) run(store *storage.Manager) ([]storage.FileMetadata, []storage.ContentMatch, error) {
+	var results []storage.FileMetadata
+	var matches []storage.ContentMatch
+	var err error
+	switch {
+	case fs.expr != nil:
+		matches, err = store.SearchQuery(fs.expr, fs.filters, searchSnippetLimit)
+	case fs.ranked:
+		matches, err = store.SearchContent(fs.filters, searchSnippetLimit)
+	default:
+		results = store.SearchFiles(fs.filters)
+	}
+	if err != nil {
+		return nil, nil, apierrors.InternalServerErrorf("content search: %v", err)
+	}
+	if matches != nil {
+		results = make([]storage.FileMetadata, len(matches))
+		for i, match := range matches {
+			results[i] = match.Metadata
+		}
+	}
+	return results, matches, nil
+}
+
+// searchQueryError reports a q parameter that does not parse, pointing at
+// the offending position.
+func searchQueryError(q string, err error) error {
+	var syntaxErr *filequery.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return apierrors.BadRequestf("invalid query: %v", err)
+	}
+	return apierrors.BadRequestf("invalid query: %s", syntaxErr.Msg).
+		WithDetails("query", q).
+		WithDetails("position", syntaxErr.Pos)
+}
+
+// parseSearchFilters maps /files/search query parameters onto storage
+// filters. It also returns the parameters that were applied, echoed back in
+// the response.
+func parseSearchFilters(query url.Values) (storage.SearchFilters, map[string]string, error) {
+	var filters storage.SearchFilters
+	applied := make(map[string]string)
+	get := func(name string) string {
+		value := strings.TrimSpace(query.Get(name))
+		if value != "" {
+			applied[name] = value
+		}
+		return value
+	}
+
+	filters.Name = get("name")
+	filters.Extension = get("extension")
+	filters.Type = get("type")
+	filters.Category = get("category")
+	filters.MimeType = get("mime_type")
+	filters.ContentSearch = get("content")
+	filters.Camera = get("camera")
+	filters.Artist = get("artist")
+	filters.Album = get("album")
+	filters.Codec = get("codec")
+
+	tags, err := parseTagParams(query)
+	if err != nil {
+		return filters, nil, err
+	}
+	if len(tags) > 0 {
+		filters.Tags = tags
+		applied["tag"] = strings.Join(tags, ",")
+	}
+
+	if filters.DateFrom, err = parseSearchTime("date_from", get("date_from"), false); err != nil {
+		return filters, nil, err
+	}
+	if filters.DateTo, err = parseSearchTime("date_to", get("date_to"), true); err != nil {
+		return filters, nil, err
+	}
+	if filters.TakenFrom, err = parseSearchTime("taken_from", get("taken_from"), false); err != nil {
+		return filters, nil, err
+	}
+	if filters.TakenTo, err = parseSearchTime("taken_to", get("taken_to"), true); err != nil {
+		return filters, nil, err
+	}
+
+	if value := get("has_gps"); value != "" {
+		if filters.HasGPS, err = strconv.ParseBool(value); err != nil {
+			return filters, nil, apierrors.BadRequestf("invalid has_gps %q: expected true or false", value)
+		}
+		if !filters.HasGPS {
+			// has_gps=false is not a filter of its own.
+			delete(applied, "has_gps")
+		}
+	}
+	for name, dst := range map[string]*int{"min_width": &filters.MinWidth, "min_height": &filters.MinHeight} {
+		if value := get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return filters, nil, apierrors.BadRequestf("invalid %s %q: expected a non-negative integer", name, value)
+			}
+			*dst = n
+		}
+	}
+	for name, dst := range map[string]*time.Duration{"min_duration": &filters.MinDuration, "max_duration": &filters.MaxDuration} {
+		if value := get(name); value != "" {
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				return filters, nil, apierrors.BadRequestf("invalid %s %q: expected seconds", name, value)
+			}
+			*dst = time.Duration(seconds * float64(time.Second))
+		}
+	}
+
+	return filters, applied, nil
+}
+
+// parseSearchTime accepts RFC3339 or YYYY-MM-DD. A plain date used as an
+// upper bound covers the whole day.
+func parseSearchTime(name, value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ts, err := time.Parse(time.RFC3339, value); err == nil {
+		return ts, nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, apierrors.BadRequestf("invalid %s %q: expected RFC3339 or YYYY-MM-DD", name, value)
+	}
+	if endOfDay {
+		return day.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return day, nil
 }
 
 // handleFileDownload downloads a file by hash or path.
@@ -671,9 +1110,9 @@ func (s *Server) handleFileDownload(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	if hash != "" {
-		result, err = s.storage.GetFileByHash(hash)
+		result, err = s.tenant(r).storage.GetFileByHash(hash)
 	} else if path != "" {
-		result, err = s.storage.GetFileByPath(path)
+		result, err = s.tenant(r).storage.GetFileByPath(path)
 	} else {
 		s.handleError(w, r, apierrors.BadRequest("hash or path query parameter is required"))
 		return
@@ -705,7 +1144,7 @@ func (s *Server) handleFileMetadata(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	metadata, err := s.storage.GetFileMetadata(hash)
+	metadata, err := s.tenant(r).storage.GetFileMetadata(hash)
 	if err != nil {
 		s.handleError(w, r, err)
 		return
@@ -723,9 +1162,9 @@ func (s *Server) handleFileStream(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	if hash != "" {
-		result, err = s.storage.GetFileByHash(hash)
+		result, err = s.tenant(r).storage.GetFileByHash(hash)
 	} else if path != "" {
-		result, err = s.storage.GetFileByPath(path)
+		result, err = s.tenant(r).storage.GetFileByPath(path)
 	} else {
 		s.handleError(w, r, apierrors.BadRequest("hash or path query parameter is required"))
 		return
@@ -737,6 +1176,17 @@ func (s *Server) handleFileStream(w http.ResponseWriter, r *http.Request) {
 	}
 	defer result.Reader.Close()
 
+	s.serveStream(w, r, result, func(rangeStart, rangeEnd *int64) error {
+		// Log download with range info
+		_ = s.logDownload(r, result, rangeStart, rangeEnd)
+		return nil
+	})
+}
+
+// serveStream writes result inline with range request support. admit is
+// called with the requested range, if any, before anything is written; when
+// it fails the error is returned instead of the file.
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, result *storage.FileRetrievalResult, admit func(rangeStart, rangeEnd *int64) error) {
 	// Check If-Modified-Since for 304 response
 	if s.shouldReturn304(w, r, result) {
 		return
@@ -763,8 +1213,10 @@ func (s *Server) handleFileStream(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Log download with range info
-	_ = s.logDownload(r, result, rangeStart, rangeEnd)
+	if err := admit(rangeStart, rangeEnd); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
 
 	// Set headers for streaming
 	if rangeStart != nil && rangeEnd != nil {
@@ -942,12 +1394,17 @@ func (s *Server) setFileHeaders(w http.ResponseWriter, r *http.Request, result *
 
 // logDownload logs a download event for analytics.
 func (s *Server) logDownload(r *http.Request, result *storage.FileRetrievalResult, rangeStart, rangeEnd *int64) error {
+	return s.tenant(r).storage.LogDownload(downloadLog(r, result, rangeStart, rangeEnd))
+}
+
+// downloadLog describes the download of result by r.
+func downloadLog(r *http.Request, result *storage.FileRetrievalResult, rangeStart, rangeEnd *int64) storage.DownloadLog {
 	ip := r.RemoteAddr
 	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
 		ip = forwarded
 	}
 
-	log := storage.DownloadLog{
+	return storage.DownloadLog{
 		Hash:         result.Metadata.Hash,
 		StoredPath:   result.Metadata.StoredPath,
 		OriginalName: result.Metadata.OriginalName,
@@ -959,13 +1416,11 @@ func (s *Server) logDownload(r *http.Request, result *storage.FileRetrievalResul
 		UserAgent:    r.UserAgent(),
 		IPAddress:    ip,
 	}
-
-	return s.storage.LogDownload(log)
 }
 
 // handleStatistics returns dashboard statistics.
 func (s *Server) handleStatistics(w http.ResponseWriter, r *http.Request) {
-	stats, err := s.storage.GetStatistics()
+	stats, err := s.tenant(r).storage.GetStatistics()
 	if err != nil {
 		httpError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get statistics: %v", err))
 		return
@@ -982,18 +1437,28 @@ func (s *Server) handleStatistics(w http.ResponseWriter, r *http.Request) {
 		"storageUsedBytes": stats.StorageUsed,
 		"collectionDetails": stats.Collections,
 	}
+	if stats.Chunking != nil {
+		response["chunking"] = stats.Chunking
+	}
 
 	writeJSON(w, http.StatusOK, response)
 }
 
 // handleGetCollections returns all collections with their statistics.
 func (s *Server) handleGetCollections(w http.ResponseWriter, r *http.Request) {
-	response, err := s.collectionService.GetAllCollections()
+	collectionService := s.tenant(r).collectionService
+	response, err := collectionService.GetAllCollections()
 	if err != nil {
 		s.logger.Error("failed to get collections", slog.Any("err", err))
 		s.handleError(w, r, apierrors.InternalServerErrorf("failed to get collections: %v", err))
 		return
 	}
+	// Smart collections carry per-viewer counts, so they are not part of
+	// the cached response.
+	if response.SmartCollections, err = collectionService.GetSmartCollections(viewerID(r)); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
 
 	writeJSON(w, http.StatusOK, response)
 }
@@ -1006,7 +1471,7 @@ func (s *Server) handleGetCollectionStats(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	response, err := s.collectionService.GetCollectionStats(collectionType)
+	response, err := s.tenant(r).collectionService.GetCollectionStats(collectionType)
 	if err != nil {
 		if strings.Contains(err.Error(), "invalid collection type") {
 			s.handleError(w, r, apierrors.BadRequest(err.Error()))
@@ -1056,7 +1521,7 @@ func (s *Server) handleGetFilesByType(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get files
-	result, err := s.storage.GetFilesByType(req)
+	result, err := s.tenant(r).storage.GetFilesByType(req)
 	if err != nil {
 		s.handleError(w, r, apierrors.InternalServerError(fmt.Sprintf("failed to get files: %v", err)))
 		return
@@ -1129,6 +1594,25 @@ func (s *Server) handleGetFilesByType(w http.ResponseWriter, r *http.Request) {
 
 // handleGetFiles returns a paginated list of files and supports common filters.
 func (s *Server) handleGetFiles(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	// Fetch from storage manager
+	result, err := s.tenant(r).storage.ListFiles(opts)
+	if err != nil {
+		s.handleError(w, r, apierrors.InternalServerError(fmt.Sprintf("failed to list files: %v", err)))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, fileListResponse(result))
+}
+
+// parseListOptions reads the pagination, filter and sort parameters shared by
+// the file listing endpoints.
+func parseListOptions(r *http.Request) (storage.ListOptions, error) {
 	// Parse pagination parameters
 	page := 1
 	limit := 50
@@ -1155,6 +1639,11 @@ func (s *Server) handleGetFiles(w http.ResponseWriter, r *http.Request) {
 		SortBy:   r.URL.Query().Get("sort_by"),
 		Order:    r.URL.Query().Get("order"),
 	}
+	tags, err := parseTagParams(r.URL.Query())
+	if err != nil {
+		return opts, err
+	}
+	opts.Tags = tags
 
 	// Optional date filtering (RFC3339)
 	if df := r.URL.Query().Get("date_from"); df != "" {
@@ -1167,15 +1656,11 @@ func (s *Server) handleGetFiles(w http.ResponseWriter, r *http.Request) {
 			opts.DateTo = t
 		}
 	}
+	return opts, nil
+}
 
-	// Fetch from storage manager
-	result, err := s.storage.ListFiles(opts)
-	if err != nil {
-		s.handleError(w, r, apierrors.InternalServerError(fmt.Sprintf("failed to list files: %v", err)))
-		return
-	}
-
-	// Convert to response format expected by frontend
+// fileListResponse converts a listing to the response format expected by the frontend.
+func fileListResponse(result *storage.ListResult) map[string]any {
 	files := make([]map[string]any, 0, len(result.Files))
 	for _, file := range result.Files {
 		dimensions := ""
@@ -1223,6 +1708,7 @@ func (s *Server) handleGetFiles(w http.ResponseWriter, r *http.Request) {
 			"namespace":     namespace,
 			"collection":    namespace,
 			"engine":        engine,
+			"tags":          file.Tags,
 		})
 	}
 
@@ -1238,7 +1724,7 @@ func (s *Server) handleGetFiles(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	writeJSON(w, http.StatusOK, resp)
+	return resp
 }
 
 // Helper structs
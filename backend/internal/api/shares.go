@@ -0,0 +1,342 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/auth"
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+	chi "github.com/go-chi/chi/v5"
+)
+
+const (
+	// shareTokenPurpose prefixes share link token payloads so tokens signed
+	// for other purposes are never accepted as share links.
+	shareTokenPurpose = "share"
+	// sharePasswordHeader carries the password of a protected share link;
+	// the password query parameter works too, for players that cannot set
+	// headers.
+	sharePasswordHeader = "X-Share-Password"
+	// shareSessionPurpose prefixes download session payloads. A session is
+	// issued with every counted download, in the X-Share-Session header and
+	// a cookie scoped to the link, and lets range requests that resume or
+	// seek within that download through without counting again.
+	shareSessionPurpose = "share-session"
+	shareSessionHeader  = "X-Share-Session"
+	shareSessionCookie  = "rhinobox_share_session"
+	shareSessionTTL     = time.Hour
+
+	defaultShareTTL = 7 * 24 * time.Hour
+	maxShareTTL     = 365 * 24 * time.Hour
+)
+
+// createShareRequest is the body of POST /files/{file_id}/shares. Either
+// expires_at or expires_in (a Go duration such as "72h") may be given; links
+// expire after seven days by default.
+type createShareRequest struct {
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	ExpiresIn    string     `json:"expires_in,omitempty"`
+	Password     string     `json:"password,omitempty"`
+	MaxDownloads int        `json:"max_downloads,omitempty"`
+}
+
+// shareResponse is the public view of a share link; the password hash never
+// leaves the server.
+type shareResponse struct {
+	ID             string     `json:"id"`
+	FileID         string     `json:"file_id"`
+	OriginalName   string     `json:"original_name"`
+	Token          string     `json:"token"`
+	URL            string     `json:"url"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	MaxDownloads   int        `json:"max_downloads,omitempty"`
+	Downloads      int        `json:"downloads"`
+	Protected      bool       `json:"password_protected"`
+	CreatedBy      string     `json:"created_by,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	Active         bool       `json:"active"`
+}
+
+func (s *Server) newShareResponse(tenantID string, share *storage.ShareLink) shareResponse {
+	token := s.shareToken(tenantID, share.ID)
+	return shareResponse{
+		ID:             share.ID,
+		FileID:         share.Hash,
+		OriginalName:   share.OriginalName,
+		Token:          token,
+		URL:            auth.SharePathPrefix + token,
+		CreatedAt:      share.CreatedAt,
+		ExpiresAt:      share.ExpiresAt,
+		MaxDownloads:   share.MaxDownloads,
+		Downloads:      share.Downloads,
+		Protected:      share.Protected(),
+		CreatedBy:      share.CreatedBy,
+		RevokedAt:      share.RevokedAt,
+		LastAccessedAt: share.LastAccessedAt,
+		Active:         share.Available(time.Now()) == nil,
+	}
+}
+
+// shareToken signs the tenant and ID of a share link. The token is stable,
+// so it can be shown again when listing links; revoking the link is what
+// invalidates it.
+func (s *Server) shareToken(tenantID, shareID string) string {
+	return s.signer.Sign(shareTokenPurpose + ":" + tenantID + ":" + shareID)
+}
+
+// parseShareToken checks the signature of token and returns the tenant and
+// share link it names.
+func (s *Server) parseShareToken(token string) (tenantID, shareID string, err error) {
+	payload, err := s.signer.Verify(token)
+	if err != nil {
+		return "", "", apierrors.NotFound("share link not found")
+	}
+	parts := strings.Split(payload, ":")
+	if len(parts) != 3 || parts[0] != shareTokenPurpose {
+		return "", "", apierrors.NotFound("share link not found")
+	}
+	return parts[1], parts[2], nil
+}
+
+// issueShareSession signs a download session for a share link and attaches
+// it to the response.
+func (s *Server) issueShareSession(w http.ResponseWriter, r *http.Request, tenantID, shareID string) {
+	expires := time.Now().Add(shareSessionTTL)
+	session := s.signer.Sign(strings.Join([]string{shareSessionPurpose, tenantID, shareID, strconv.FormatInt(expires.Unix(), 10)}, ":"))
+	w.Header().Set(shareSessionHeader, session)
+	http.SetCookie(w, &http.Cookie{
+		Name:     shareSessionCookie,
+		Value:    session,
+		Path:     "/s/" + chi.URLParam(r, "token"),
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// hasShareSession reports whether r carries an unexpired download session
+// issued for the share link.
+func (s *Server) hasShareSession(r *http.Request, tenantID, shareID string) bool {
+	session := r.Header.Get(shareSessionHeader)
+	if session == "" {
+		if cookie, err := r.Cookie(shareSessionCookie); err == nil {
+			session = cookie.Value
+		}
+	}
+	if session == "" {
+		return false
+	}
+	payload, err := s.signer.Verify(session)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(payload, ":")
+	if len(parts) != 4 || parts[0] != shareSessionPurpose || parts[1] != tenantID || parts[2] != shareID {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[3], 10, 64)
+	return err == nil && time.Now().Unix() < expires
+}
+
+func (s *Server) shareListResponse(tenantID string, shares []storage.ShareLink) []shareResponse {
+	response := make([]shareResponse, 0, len(shares))
+	for i := range shares {
+		response = append(response, s.newShareResponse(tenantID, &shares[i]))
+	}
+	return response
+}
+
+// handleCreateShare handles POST /files/{file_id}/shares.
+// The password is only accepted here; it is stored hashed.
+func (s *Server) handleCreateShare(w http.ResponseWriter, r *http.Request) {
+	var req createShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(defaultShareTTL)
+	switch {
+	case req.ExpiresAt != nil && req.ExpiresIn != "":
+		s.handleError(w, r, apierrors.BadRequest("set expires_at or expires_in, not both"))
+		return
+	case req.ExpiresAt != nil:
+		expiresAt = *req.ExpiresAt
+	case req.ExpiresIn != "":
+		ttl, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			s.handleError(w, r, apierrors.BadRequestf("invalid expires_in %q: expected a positive duration such as 72h", req.ExpiresIn))
+			return
+		}
+		expiresAt = now.Add(ttl)
+	}
+	if expiresAt.After(now.Add(maxShareTTL)) {
+		s.handleError(w, r, apierrors.BadRequest("share links expire within 365 days"))
+		return
+	}
+
+	createdBy := ""
+	if key, ok := auth.KeyFromContext(r.Context()); ok {
+		createdBy = key.ID
+	}
+	scope := s.tenant(r)
+	share, err := scope.storage.CreateShare(storage.CreateShareRequest{
+		Hash:         chi.URLParam(r, "file_id"),
+		ExpiresAt:    expiresAt,
+		Password:     req.Password,
+		MaxDownloads: req.MaxDownloads,
+		CreatedBy:    createdBy,
+	})
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	s.logger.Info("share link created",
+		slog.String("share_id", share.ID),
+		slog.String("hash", share.Hash),
+		slog.Time("expires_at", share.ExpiresAt),
+	)
+	writeJSON(w, http.StatusCreated, s.newShareResponse(scope.id, share))
+}
+
+// handleListFileShares handles GET /files/{file_id}/shares.
+func (s *Server) handleListFileShares(w http.ResponseWriter, r *http.Request) {
+	fileID := chi.URLParam(r, "file_id")
+	scope := s.tenant(r)
+	shares, err := scope.storage.ListShares(fileID)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"file_id": fileID,
+		"shares":  s.shareListResponse(scope.id, shares),
+		"count":   len(shares),
+	})
+}
+
+// handleListShares handles GET /shares.
+func (s *Server) handleListShares(w http.ResponseWriter, r *http.Request) {
+	scope := s.tenant(r)
+	shares, err := scope.storage.ListShares("")
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"shares": s.shareListResponse(scope.id, shares),
+		"count":  len(shares),
+	})
+}
+
+// handleGetShare handles GET /shares/{share_id}.
+func (s *Server) handleGetShare(w http.ResponseWriter, r *http.Request) {
+	scope := s.tenant(r)
+	share, err := scope.storage.GetShare(chi.URLParam(r, "share_id"))
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.newShareResponse(scope.id, share))
+}
+
+// handleRevokeShare handles DELETE /shares/{share_id}.
+func (s *Server) handleRevokeShare(w http.ResponseWriter, r *http.Request) {
+	scope := s.tenant(r)
+	share, err := scope.storage.RevokeShare(chi.URLParam(r, "share_id"))
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	s.logger.Info("share link revoked", slog.String("share_id", share.ID))
+	writeJSON(w, http.StatusOK, s.newShareResponse(scope.id, share))
+}
+
+// handleShareDownload handles GET /s/{token}.
+func (s *Server) handleShareDownload(w http.ResponseWriter, r *http.Request) {
+	s.serveShare(w, r, false)
+}
+
+// handleShareStream handles GET /s/{token}/stream.
+func (s *Server) handleShareStream(w http.ResponseWriter, r *http.Request) {
+	s.serveShare(w, r, true)
+}
+
+// serveShare serves the file of a share link to a recipient without an API
+// key. Every request that serves bytes counts against the link's download
+// limit, except range requests carrying the session issued by an earlier
+// counted download; every access is logged.
+func (s *Server) serveShare(w http.ResponseWriter, r *http.Request, stream bool) {
+	tenantID, shareID, err := s.parseShareToken(chi.URLParam(r, "token"))
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	scope, err := s.tenantScopeFor(tenantID)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, scope))
+
+	password := r.Header.Get(sharePasswordHeader)
+	if password == "" {
+		password = r.URL.Query().Get("password")
+	}
+	// A range request within a counted download may go on after that
+	// download used up the limit.
+	resuming := r.Header.Get("Range") != "" && s.hasShareSession(r, tenantID, shareID)
+	var share *storage.ShareLink
+	if resuming {
+		share, err = scope.storage.ResumeShare(shareID, password)
+	} else {
+		share, err = scope.storage.UseShare(shareID, password, false)
+	}
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	result, err := scope.storage.GetFileByHash(share.Hash)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	defer result.Reader.Close()
+
+	admit := func(rangeStart, rangeEnd *int64) error {
+		if rangeStart == nil || !resuming {
+			if _, err := scope.storage.UseShare(shareID, password, true); err != nil {
+				return err
+			}
+			s.issueShareSession(w, r, tenantID, shareID)
+		}
+		log := downloadLog(r, result, rangeStart, rangeEnd)
+		log.ShareID = shareID
+		_ = scope.storage.LogDownload(log)
+		return nil
+	}
+	if stream {
+		s.serveStream(w, r, result, admit)
+		return
+	}
+
+	if err := admit(nil, nil); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	s.setFileHeaders(w, r, result, "attachment")
+	if _, err := io.Copy(w, result.Reader); err != nil {
+		s.logger.Warn("failed to copy shared file to response", slog.Any("err", err))
+	}
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Muneer320/RhinoBox/internal/storage"
+)
+
+func TestShareLinks(t *testing.T) {
+	srv := newAuthTestServer(t)
+	content := "0123456789 shared video bytes"
+	stored, err := srv.storage.StoreFile(storage.StoreRequest{Reader: strings.NewReader(content), Filename: "clip.mp4", MimeType: "video/mp4", Size: int64(len(content))})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	hash := stored.Metadata.Hash
+
+	do := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, req)
+		return resp
+	}
+	admin := map[string]string{"Authorization": "Bearer " + testBootstrapKey}
+
+	if resp := do(http.MethodPost, "/files/"+hash+"/shares", `{}`, nil); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected creating a link to need an API key, got %d", resp.Code)
+	}
+	ingestKey := createTestKey(t, srv, map[string]any{"name": "uploader", "scopes": []string{"ingest"}})
+	if resp := do(http.MethodPost, "/files/"+hash+"/shares", `{}`, map[string]string{"X-API-Key": ingestKey}); resp.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for an ingest-only key, got %d", resp.Code)
+	}
+	if resp := do(http.MethodPost, "/files/"+hash+"/shares", `{"expires_in":"-1h"}`, admin); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a negative expiry, got %d", resp.Code)
+	}
+	resp := do(http.MethodPost, "/files/"+hash+"/shares", `{"expires_in":"1h","password":"hunter2","max_downloads":2}`, admin)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", resp.Code, resp.Body.String())
+	}
+	var share shareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&share); err != nil || !share.Protected || !share.Active || share.URL != "/s/"+share.Token {
+		t.Fatalf("created: %+v %v", share, err)
+	}
+	if strings.Contains(resp.Body.String(), "hunter2") || strings.Contains(resp.Body.String(), "pbkdf2") {
+		t.Fatal("the password must not be returned")
+	}
+
+	// Recipients need no API key, only the password.
+	if resp := do(http.MethodGet, share.URL, "", nil); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the password, got %d", resp.Code)
+	}
+	resp = do(http.MethodGet, share.URL, "", map[string]string{sharePasswordHeader: "hunter2"})
+	if resp.Code != http.StatusOK || resp.Body.String() != content || !strings.HasPrefix(resp.Header().Get("Content-Disposition"), "attachment") {
+		t.Fatalf("download: %d %q", resp.Code, resp.Body.String())
+	}
+
+	session := resp.Header().Get(shareSessionHeader)
+	if session == "" {
+		t.Fatal("expected a counted download to issue a session")
+	}
+
+	// Range requests within that download's session do not count again.
+	resp = do(http.MethodGet, share.URL+"/stream?password=hunter2", "", map[string]string{"Range": "bytes=10-", shareSessionHeader: session})
+	if resp.Code != http.StatusPartialContent || resp.Body.String() != content[10:] {
+		t.Fatalf("stream range: %d %q", resp.Code, resp.Body.String())
+	}
+	if resp := do(http.MethodGet, share.URL+"/stream?password=hunter2", "", nil); resp.Code != http.StatusOK {
+		t.Fatalf("stream: %d", resp.Code)
+	}
+	if resp := do(http.MethodGet, share.URL+"?password=hunter2", "", nil); resp.Code != http.StatusGone {
+		t.Fatalf("expected 410 after the download limit, got %d", resp.Code)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(srv.storage.Root(), "metadata", "download_log.ndjson"))
+	if err != nil || strings.Count(string(raw), `"share_id":"`+share.ID+`"`) != 3 {
+		t.Fatalf("expected 3 logged share accesses, got %s %v", raw, err)
+	}
+
+	// A tampered token is rejected without revealing anything.
+	tampered := share.URL[:len(share.URL)-2] + "xx"
+	if resp := do(http.MethodGet, tampered, "", nil); resp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a tampered token, got %d", resp.Code)
+	}
+
+	resp = do(http.MethodPost, "/files/"+hash+"/shares", `{}`, admin)
+	var open shareResponse
+	json.NewDecoder(resp.Body).Decode(&open)
+	if resp := do(http.MethodGet, open.URL, "", nil); resp.Code != http.StatusOK {
+		t.Fatalf("open link: %d", resp.Code)
+	}
+	resp = do(http.MethodGet, "/files/"+hash+"/shares", "", admin)
+	var listed struct {
+		Shares []shareResponse `json:"shares"`
+		Count  int             `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil || listed.Count != 2 || listed.Shares[1].Downloads != 2 || listed.Shares[1].Active {
+		t.Fatalf("list: %+v %v", listed, err)
+	}
+
+	if resp := do(http.MethodDelete, "/shares/"+open.ID, "", admin); resp.Code != http.StatusOK {
+		t.Fatalf("revoke: %d", resp.Code)
+	}
+	if resp := do(http.MethodGet, open.URL, "", nil); resp.Code != http.StatusGone {
+		t.Fatalf("expected 410 after revoking, got %d", resp.Code)
+	}
+}
+
+func TestShareLinkRangesCountWithoutSession(t *testing.T) {
+	srv := newAuthTestServer(t)
+	content := "0123456789 shared report bytes"
+	stored, err := srv.storage.StoreFile(storage.StoreRequest{Reader: strings.NewReader(content), Filename: "report.txt", MimeType: "text/plain", Size: int64(len(content))})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	req := newJSONRequest(t, "/files/"+stored.Metadata.Hash+"/shares", map[string]any{"max_downloads": 1})
+	req.Header.Set("Authorization", "Bearer "+testBootstrapKey)
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	var share shareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&share); err != nil || resp.Code != http.StatusCreated {
+		t.Fatalf("create: %d %v", resp.Code, err)
+	}
+
+	fetch := func(rangeHeader string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, share.URL+"/stream", nil)
+		req.Header.Set("Range", rangeHeader)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		resp := httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// The first download is fetched in two ranges; the session cookie keeps
+	// the second from counting.
+	first := fetch("bytes=0-9")
+	if first.Code != http.StatusPartialContent || first.Body.String() != content[:10] {
+		t.Fatalf("first range: %d %q", first.Code, first.Body.String())
+	}
+	cookies := first.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != shareSessionCookie || cookies[0].Path != share.URL {
+		t.Fatalf("expected a session cookie scoped to the link, got %+v", cookies)
+	}
+	if resp := fetch("bytes=10-", cookies...); resp.Code != http.StatusPartialContent || resp.Body.String() != content[10:] {
+		t.Fatalf("resumed range: %d %q", resp.Code, resp.Body.String())
+	}
+
+	// A second download made of ranges without a session is refused.
+	for _, rangeHeader := range []string{"bytes=0-9", "bytes=10-"} {
+		if resp := fetch(rangeHeader); resp.Code != http.StatusGone {
+			t.Fatalf("range %s without a session: expected 410, got %d", rangeHeader, resp.Code)
+		}
+	}
+
+	// Sessions are bound to the link they were issued for.
+	other := srv.signer.Sign(strings.Join([]string{shareSessionPurpose, storage.DefaultTenantID, "other", "9999999999"}, ":"))
+	if resp := fetch("bytes=10-", &http.Cookie{Name: shareSessionCookie, Value: other}); resp.Code != http.StatusGone {
+		t.Fatalf("foreign session: expected 410, got %d", resp.Code)
+	}
+}
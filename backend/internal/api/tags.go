@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+)
+
+// handleListTags handles GET /tags, listing every tag in use with its file
+// counts. The optional prefix parameter limits the list to one subtree.
+func (s *Server) handleListTags(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimSpace(r.URL.Query().Get("prefix"))
+	tags, err := s.tenant(r).storage.ListTags(prefix)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"tags":  tags,
+		"count": len(tags),
+	})
+}
+
+// handleTagFiles handles POST /files/tags, adding and removing tags on a
+// batch of files.
+func (s *Server) handleTagFiles(w http.ResponseWriter, r *http.Request) {
+	var req storage.TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+
+	result, err := s.tenant(r).storage.TagFiles(req)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	s.logger.Info("files tagged",
+		slog.Int("total", result.Total),
+		slog.Int("success", result.SuccessCount),
+		slog.Int("failed", result.FailureCount),
+	)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// parseTagParams reads the tag query parameter, which may be repeated or
+// hold comma-separated tags, and normalizes the tags.
+func parseTagParams(query url.Values) ([]string, error) {
+	var raw []string
+	for _, value := range query["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				raw = append(raw, tag)
+			}
+		}
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	tags, err := storage.NormalizeTags(raw)
+	if err != nil {
+		return nil, apierrors.BadRequest(err.Error())
+	}
+	return tags, nil
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTagEndpoints(t *testing.T) {
+	srv := newTestServer(t)
+
+	hashes := make(map[string]string)
+	for _, name := range []string{"launch.txt", "orbit.txt", "budget.txt"} {
+		resp := ingestMediaAs(t, srv, "", name, []byte("content of "+name))
+		var ingest struct {
+			Stored []struct {
+				Hash string `json:"hash"`
+			} `json:"stored"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&ingest); err != nil || len(ingest.Stored) != 1 {
+			t.Fatalf("ingest %s: %d %v", name, resp.Code, err)
+		}
+		hashes[name] = ingest.Stored[0].Hash
+	}
+
+	tag := func(body string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/files/tags", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		srv.router.ServeHTTP(resp, req)
+		return resp
+	}
+	resp := tag(`{"hashes":["` + hashes["launch.txt"] + `","` + hashes["orbit.txt"] + `"],"add":["Project/Apollo/2026"]}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("tag: %d %s", resp.Code, resp.Body.String())
+	}
+	var tagged struct {
+		SuccessCount int `json:"success_count"`
+		Results      []struct {
+			Tags []string `json:"tags"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tagged); err != nil || tagged.SuccessCount != 2 || tagged.Results[0].Tags[0] != "project/apollo/2026" {
+		t.Fatalf("unexpected tag response %+v (%v)", tagged, err)
+	}
+	if resp := tag(`{"hashes":["` + hashes["budget.txt"] + `"],"add":["finance"]}`); resp.Code != http.StatusOK {
+		t.Fatalf("tag budget: %d", resp.Code)
+	}
+	if resp := tag(`{"hashes":["` + hashes["budget.txt"] + `"],"add":["a//b"]}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid tag, got %d", resp.Code)
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/tags?prefix=project", nil))
+	var listed struct {
+		Count int `json:"count"`
+		Tags  []struct {
+			Tag   string `json:"tag"`
+			Count int    `json:"count"`
+			Total int    `json:"total"`
+		} `json:"tags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode tags: %v", err)
+	}
+	if listed.Count != 3 || listed.Tags[0].Tag != "project" || listed.Tags[0].Total != 2 || listed.Tags[2].Count != 2 {
+		t.Fatalf("unexpected tag listing %+v", listed)
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files?tag=project/apollo", nil))
+	var files struct {
+		Files []map[string]any `json:"files"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&files); err != nil || len(files.Files) != 2 {
+		t.Fatalf("expected two files tagged under project/apollo, got %+v (%v)", files, err)
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files/search?tag=finance", nil))
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "budget.txt") || strings.Contains(resp.Body.String(), "launch.txt") {
+		t.Fatalf("expected tag search to match budget.txt only, got %d %s", resp.Code, resp.Body.String())
+	}
+}
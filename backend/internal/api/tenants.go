@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Muneer320/RhinoBox/internal/auth"
+	"github.com/Muneer320/RhinoBox/internal/cache"
+	"github.com/Muneer320/RhinoBox/internal/config"
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/service"
+	"github.com/Muneer320/RhinoBox/internal/services"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// tenantHeader selects the tenant for keys that are not pinned to one.
+const tenantHeader = "X-Tenant-ID"
+
+// tenantScope bundles the services bound to one tenant's storage tree.
+type tenantScope struct {
+	id                string
+	storage           *storage.Manager
+	fileService       *service.FileService
+	collectionService *services.CollectionService
+	collectionCache   *cache.Cache
+}
+
+type tenantContextKey struct{}
+
+// tenantBackendFactory builds per-tenant blob backends: local tenants get
+// their own root, S3 tenants get their own key prefix in the shared bucket.
+func tenantBackendFactory(cfg config.Config) storage.BackendFactory {
+	return func(tenantID, root string) (storage.Backend, error) {
+		tenantCfg := cfg
+		tenantCfg.DataDir = root
+		tenantCfg.Storage.S3Prefix = path.Join(cfg.Storage.S3Prefix, "tenants", tenantID)
+		return newStorageBackend(tenantCfg)
+	}
+}
+
+// tenant returns the scope resolved for r, falling back to the default tenant
+// for requests that bypass resolveTenant (e.g. handlers invoked directly in tests).
+func (s *Server) tenant(r *http.Request) *tenantScope {
+	if scope, ok := r.Context().Value(tenantContextKey{}).(*tenantScope); ok {
+		return scope
+	}
+	return s.defaultTenant
+}
+
+// tenantScopeFor returns the cached scope for id, opening its storage on first use.
+func (s *Server) tenantScopeFor(id string) (*tenantScope, error) {
+	if id == storage.DefaultTenantID {
+		return s.defaultTenant, nil
+	}
+
+	s.tenantsMu.Lock()
+	defer s.tenantsMu.Unlock()
+	if scope, ok := s.tenantScopes[id]; ok {
+		return scope, nil
+	}
+
+	store, err := s.tenants.Manager(id)
+	if err != nil {
+		return nil, err
+	}
+	cacheConfig := cache.DefaultConfig()
+	cacheConfig.L3Path = filepath.Join(store.Root(), "cache", "collections")
+	cacheInstance, err := cache.New(cacheConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize collection cache for tenant %s: %w", id, err)
+	}
+	s.configureStore(id, store)
+	scope := &tenantScope{
+		id:                id,
+		storage:           store,
+		fileService:       service.NewFileService(store, s.logger),
+		collectionService: newCollectionService(store, cacheInstance, s.logger),
+		collectionCache:   cacheInstance,
+	}
+	s.tenantScopes[id] = scope
+	return scope, nil
+}
+
+// resolveTenant picks the tenant for the request. A key pinned to a tenant
+// always uses it; otherwise X-Tenant-ID chooses, defaulting to "default".
+func (s *Server) resolveTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth.RequiredScope(r) == auth.ScopeNone || strings.HasPrefix(r.URL.Path, "/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requested := strings.ToLower(strings.TrimSpace(r.Header.Get(tenantHeader)))
+		id := requested
+		if key, ok := auth.KeyFromContext(r.Context()); ok && key.Tenant != "" {
+			if requested != "" && requested != key.Tenant {
+				s.handleError(w, r, apierrors.Forbidden("API key is not valid for this tenant").
+					WithDetails("tenant", requested))
+				return
+			}
+			id = key.Tenant
+		}
+		if id == "" {
+			id = storage.DefaultTenantID
+		}
+
+		scope, err := s.tenantScopeFor(id)
+		if err != nil {
+			s.handleError(w, r, err)
+			return
+		}
+		w.Header().Set(tenantHeader, scope.id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, scope)))
+	})
+}
+
+// closeTenants releases every non-default tenant scope.
+func (s *Server) closeTenants() error {
+	s.tenantsMu.Lock()
+	defer s.tenantsMu.Unlock()
+	var errs []error
+	for id, scope := range s.tenantScopes {
+		errs = append(errs, scope.collectionCache.Close())
+		delete(s.tenantScopes, id)
+	}
+	errs = append(errs, s.tenants.Close())
+	return errors.Join(errs...)
+}
+
+// tenantRequest is the body of POST /admin/tenants and PATCH /admin/tenants/{tenant_id}.
+type tenantRequest struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MaxBytes *int64 `json:"max_bytes,omitempty"`
+	MaxFiles *int64 `json:"max_files,omitempty"`
+}
+
+// tenantResponse adds live usage to the stored tenant record.
+func (s *Server) tenantResponse(t *storage.Tenant) (map[string]any, error) {
+	scope, err := s.tenantScopeFor(t.ID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := scope.storage.Usage()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"id":         t.ID,
+		"name":       t.Name,
+		"quota":      t.Quota,
+		"usage":      usage,
+		"created_at": t.CreatedAt,
+	}, nil
+}
+
+// handleCreateTenant handles POST /admin/tenants.
+func (s *Server) handleCreateTenant(w http.ResponseWriter, r *http.Request) {
+	var req tenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+
+	tenant := storage.Tenant{ID: req.ID, Name: req.Name}
+	if req.MaxBytes != nil {
+		tenant.Quota.MaxBytes = *req.MaxBytes
+	}
+	if req.MaxFiles != nil {
+		tenant.Quota.MaxFiles = *req.MaxFiles
+	}
+	created, err := s.tenants.Create(tenant)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	response, err := s.tenantResponse(created)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	s.logger.Info("tenant created", slog.String("tenant", created.ID))
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// handleListTenants handles GET /admin/tenants.
+func (s *Server) handleListTenants(w http.ResponseWriter, r *http.Request) {
+	tenants := s.tenants.List()
+	response := make([]map[string]any, 0, len(tenants))
+	for i := range tenants {
+		item, err := s.tenantResponse(&tenants[i])
+		if err != nil {
+			s.handleError(w, r, err)
+			return
+		}
+		response = append(response, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"tenants": response,
+		"total":   len(response),
+	})
+}
+
+// handleGetTenant handles GET /admin/tenants/{tenant_id}.
+func (s *Server) handleGetTenant(w http.ResponseWriter, r *http.Request) {
+	tenant, err := s.tenants.Get(chi.URLParam(r, "tenant_id"))
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	response, err := s.tenantResponse(tenant)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleUpdateTenantQuota handles PATCH /admin/tenants/{tenant_id}.
+// Omitted limits keep their current value; 0 removes a limit.
+func (s *Server) handleUpdateTenantQuota(w http.ResponseWriter, r *http.Request) {
+	tenantID := chi.URLParam(r, "tenant_id")
+	var req tenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid JSON: %v", err))
+		return
+	}
+
+	current, err := s.tenants.Get(tenantID)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	quota := current.Quota
+	if req.MaxBytes != nil {
+		quota.MaxBytes = *req.MaxBytes
+	}
+	if req.MaxFiles != nil {
+		quota.MaxFiles = *req.MaxFiles
+	}
+	updated, err := s.tenants.SetQuota(tenantID, quota)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	response, err := s.tenantResponse(updated)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	s.logger.Info("tenant quota updated",
+		slog.String("tenant", updated.ID),
+		slog.Int64("max_bytes", quota.MaxBytes),
+		slog.Int64("max_files", quota.MaxFiles),
+	)
+	writeJSON(w, http.StatusOK, response)
+}
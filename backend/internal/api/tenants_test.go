@@ -0,0 +1,152 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func ingestMediaAs(t *testing.T, srv *Server, tenant, name string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/ingest/media", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if tenant != "" {
+		req.Header.Set(tenantHeader, tenant)
+	}
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	return resp
+}
+
+func listFilesAs(t *testing.T, srv *Server, tenant string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/files", nil)
+	if tenant != "" {
+		req.Header.Set(tenantHeader, tenant)
+	}
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("list files: expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var payload struct {
+		Pagination struct {
+			Total int `json:"total"`
+		} `json:"pagination"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return payload.Pagination.Total
+}
+
+func TestTenantScopedIngestAndListing(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(srv.Stop)
+
+	req := newJSONRequest(t, "/admin/tenants", map[string]any{"id": "acme", "max_files": 1})
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("create tenant: expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	if resp := ingestMediaAs(t, srv, "acme", "a.jpg", []byte("tenant image")); resp.Code != http.StatusOK {
+		t.Fatalf("tenant ingest: expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := ingestMediaAs(t, srv, "", "b.jpg", []byte("default image")); resp.Code != http.StatusOK {
+		t.Fatalf("default ingest: expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	if got := listFilesAs(t, srv, "acme"); got != 1 {
+		t.Fatalf("expected 1 file for acme, got %d", got)
+	}
+	if got := listFilesAs(t, srv, ""); got != 1 {
+		t.Fatalf("expected 1 file for default tenant, got %d", got)
+	}
+
+	// Quota of one file is used up
+	resp = ingestMediaAs(t, srv, "acme", "c.jpg", []byte("over quota"))
+	if resp.Code != http.StatusInsufficientStorage {
+		t.Fatalf("expected 507, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	// Unknown tenants are rejected
+	req = httptest.NewRequest(http.MethodGet, "/files", nil)
+	req.Header.Set(tenantHeader, "nobody")
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown tenant, got %d", resp.Code)
+	}
+
+	// Usage is reported on the admin endpoint
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/admin/tenants/acme", nil))
+	var tenant struct {
+		Usage struct {
+			Files int64 `json:"files"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tenant); err != nil {
+		t.Fatalf("decode tenant: %v", err)
+	}
+	if tenant.Usage.Files != 1 {
+		t.Fatalf("expected usage of 1 file, got %d", tenant.Usage.Files)
+	}
+}
+
+func TestTenantPinnedAPIKey(t *testing.T) {
+	srv := newAuthTestServer(t)
+
+	req := newJSONRequest(t, "/admin/tenants", map[string]any{"id": "acme"})
+	req.Header.Set("Authorization", "Bearer "+testBootstrapKey)
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("create tenant: expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	req = newJSONRequest(t, "/admin/keys", map[string]any{"name": "acme-reader", "scopes": []string{"read"}, "tenant": "acme"})
+	req.Header.Set("Authorization", "Bearer "+testBootstrapKey)
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("create key: expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var created struct {
+		APIKey string `json:"api_key"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/files", nil)
+	req.Header.Set("X-API-Key", created.APIKey)
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK || resp.Header().Get(tenantHeader) != "acme" {
+		t.Fatalf("expected 200 scoped to acme, got %d (%s)", resp.Code, resp.Header().Get(tenantHeader))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/files", nil)
+	req.Header.Set("X-API-Key", created.APIKey)
+	req.Header.Set(tenantHeader, "default")
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 when a pinned key asks for another tenant, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/queue"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+	chi "github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// regenerateThumbnailsRequest is the body of POST /thumbnails/regenerate.
+// An empty Hashes list regenerates every image of the tenant.
+type regenerateThumbnailsRequest struct {
+	Hashes []string `json:"hashes"`
+}
+
+// handleGetThumbnail handles GET /files/{file_id}/thumbnail?size=. Missing
+// derivatives are generated on demand.
+func (s *Server) handleGetThumbnail(w http.ResponseWriter, r *http.Request) {
+	hash := chi.URLParam(r, "file_id")
+	if hash == "" {
+		s.handleError(w, r, apierrors.BadRequest("file_id is required"))
+		return
+	}
+
+	size := 0
+	if raw := r.URL.Query().Get("size"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			s.handleError(w, r, apierrors.BadRequestf("invalid size %q", raw))
+			return
+		}
+		size = parsed
+	}
+
+	result, err := s.tenant(r).storage.OpenThumbnail(hash, size)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	defer result.Reader.Close()
+
+	thumb := result.Thumbnail
+	w.Header().Set("Content-Type", thumb.MimeType)
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%d"`, hash, thumb.Size))
+	w.Header().Set("X-File-Hash", hash)
+	w.Header().Set("X-Thumbnail-Size", strconv.Itoa(thumb.Size))
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(w, r, "", time.Time{}, result.Reader)
+}
+
+// handleRegenerateThumbnails handles POST /thumbnails/regenerate by queueing a
+// thumbnail job for the requested files of the caller's tenant.
+func (s *Server) handleRegenerateThumbnails(w http.ResponseWriter, r *http.Request) {
+	var req regenerateThumbnailsRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			s.handleError(w, r, apierrors.BadRequest("invalid JSON body"))
+			return
+		}
+	}
+
+	scope := s.tenant(r)
+	hashes := req.Hashes
+	if len(hashes) == 0 {
+		for _, meta := range scope.storage.GetAllMetadata() {
+			if storage.SupportsThumbnails(meta) {
+				hashes = append(hashes, meta.Hash)
+			}
+		}
+	}
+	if len(hashes) == 0 {
+		s.handleError(w, r, apierrors.BadRequest("no images to regenerate"))
+		return
+	}
+
+	job, err := s.enqueueThumbnailJob(scope.id, hashes)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"job_id":           job.ID,
+		"status":           string(job.Status),
+		"total_items":      job.Total,
+		"sizes":            scope.storage.ThumbnailSizes(),
+		"check_status_url": fmt.Sprintf("/jobs/%s", job.ID),
+		"created_at":       job.CreatedAt,
+	})
+}
+
+// enqueueThumbnailJob queues one thumbnail job covering hashes for tenant.
+func (s *Server) enqueueThumbnailJob(tenant string, hashes []string) (*queue.Job, error) {
+	items := make([]queue.JobItem, len(hashes))
+	for i, hash := range hashes {
+		items[i] = queue.JobItem{ID: uuid.NewString(), Type: "file", Name: hash}
+	}
+	job := &queue.Job{
+		ID:     uuid.NewString(),
+		Type:   queue.JobTypeThumbnail,
+		Items:  items,
+		Tenant: tenant,
+	}
+	if err := s.jobQueue.Enqueue(job); err != nil {
+		return nil, fmt.Errorf("failed to queue thumbnail job: %w", err)
+	}
+	return job, nil
+}
+
+// configureStore applies server-wide storage settings to a tenant's Manager:
+// thumbnail sizes and, when enabled, background thumbnail generation on ingest.
+func (s *Server) configureStore(tenant string, store *storage.Manager) {
+	store.SetThumbnailSizes(s.cfg.ThumbnailSizes)
+	if !s.cfg.ThumbnailsOnIngest {
+		return
+	}
+	store.OnStore(func(meta storage.FileMetadata) {
+		if !storage.SupportsThumbnails(meta) {
+			return
+		}
+		if _, err := s.enqueueThumbnailJob(tenant, []string{meta.Hash}); err != nil {
+			s.logger.Warn("failed to queue thumbnails",
+				slog.String("tenant", tenant),
+				slog.String("hash", meta.Hash),
+				slog.Any("err", err))
+		}
+	})
+}
+
+// storeForJob resolves the Manager a queued job operates on.
+func (s *Server) storeForJob(tenant string) (*storage.Manager, error) {
+	if tenant == "" {
+		tenant = storage.DefaultTenantID
+	}
+	scope, err := s.tenantScopeFor(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return scope.storage, nil
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/storage"
+)
+
+func encodeTestPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestThumbnailEndpointAndRegeneration(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(srv.Stop)
+
+	resp := ingestMediaAs(t, srv, "", "banner.png", encodeTestPNG(t, 400, 200))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var ingest struct {
+		Stored []struct {
+			Hash string `json:"hash"`
+		} `json:"stored"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ingest); err != nil || len(ingest.Stored) != 1 {
+		t.Fatalf("decode ingest: %v", err)
+	}
+	hash := ingest.Stored[0].Hash
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files/"+hash+"/thumbnail?size=256", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("thumbnail: expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if ct := resp.Header().Get("Content-Type"); ct != "image/png" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	cfg, _, err := image.DecodeConfig(resp.Body)
+	if err != nil || cfg.Width != 256 || cfg.Height != 128 {
+		t.Fatalf("expected a 256x128 thumbnail, got %dx%d (err %v)", cfg.Width, cfg.Height, err)
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files/"+hash+"/thumbnail?size=300", nil))
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("unconfigured size: expected 400, got %d", resp.Code)
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, newJSONRequest(t, "/thumbnails/regenerate", map[string]any{"hashes": []string{hash}}))
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("regenerate: expected 202, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var queued struct {
+		JobID          string `json:"job_id"`
+		CheckStatusURL string `json:"check_status_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&queued); err != nil || queued.JobID == "" {
+		t.Fatalf("decode regenerate: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp = httptest.NewRecorder()
+		srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, queued.CheckStatusURL, nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("job status: expected 200, got %d", resp.Code)
+		}
+		var status struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatalf("decode job status: %v", err)
+		}
+		if status.Status == "completed" && status.Error == "" {
+			break
+		}
+		if status.Status == "failed" || status.Error != "" {
+			t.Fatalf("thumbnail job failed: %s", status.Error)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("thumbnail job still %s", status.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	set, err := srv.storage.OpenThumbnail(hash, 512)
+	if err != nil {
+		t.Fatalf("open regenerated thumbnail: %v", err)
+	}
+	set.Reader.Close()
+	if !strings.Contains(set.Thumbnail.Key, "/.thumbnails/") {
+		t.Fatalf("unexpected thumbnail key %s", set.Thumbnail.Key)
+	}
+}
+
+func TestThumbnailRejectsNonImages(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(srv.Stop)
+
+	stored, err := srv.storage.StoreFile(storage.StoreRequest{
+		Reader:   strings.NewReader("plain text"),
+		Filename: "readme.txt",
+		MimeType: "text/plain",
+		Size:     int64(len("plain text")),
+	})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/files/"+stored.Metadata.Hash+"/thumbnail", nil))
+	if resp.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d: %s", resp.Code, resp.Body.String())
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// trashItem is a trash entry as returned by GET /trash.
+type trashItem struct {
+	storage.FileMetadata
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time `json:"purge_at,omitempty"`
+}
+
+// handleListTrash handles GET /trash for the request's tenant.
+func (s *Server) handleListTrash(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.tenant(r).storage.ListTrash()
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	items := make([]trashItem, 0, len(entries))
+	for _, entry := range entries {
+		item := trashItem{FileMetadata: entry.Metadata, DeletedAt: entry.DeletedAt}
+		if s.cfg.TrashRetention > 0 {
+			purgeAt := entry.DeletedAt.Add(s.cfg.TrashRetention)
+			item.PurgeAt = &purgeAt
+		}
+		items = append(items, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"files": items,
+		"total": len(items),
+	})
+}
+
+// handleRestoreTrash handles POST /trash/{hash}/restore.
+func (s *Server) handleRestoreTrash(w http.ResponseWriter, r *http.Request) {
+	hash := chi.URLParam(r, "hash")
+	if hash == "" {
+		s.handleError(w, r, apierrors.BadRequest("hash is required"))
+		return
+	}
+
+	result, err := s.tenant(r).storage.RestoreFile(hash)
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	s.logger.Info("file restored",
+		slog.String("hash", hash),
+		slog.String("original_name", result.Metadata.OriginalName),
+		slog.String("stored_path", result.Metadata.StoredPath),
+	)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// startTrashPurger periodically purges trash entries older than the retention
+// period in every tenant. It is a no-op when retention or interval is zero.
+func (s *Server) startTrashPurger() {
+	if s.cfg.TrashRetention <= 0 || s.cfg.TrashPurgeInterval <= 0 {
+		return
+	}
+	s.stopPurger = make(chan struct{})
+	s.purgerDone = make(chan struct{})
+
+	go func() {
+		defer close(s.purgerDone)
+		ticker := time.NewTicker(s.cfg.TrashPurgeInterval)
+		defer ticker.Stop()
+
+		s.purgeTrash()
+		for {
+			select {
+			case <-ticker.C:
+				s.purgeTrash()
+			case <-s.stopPurger:
+				return
+			}
+		}
+	}()
+}
+
+// stopTrashPurger stops the purger goroutine and waits for an active run to finish.
+func (s *Server) stopTrashPurger() {
+	if s.stopPurger == nil {
+		return
+	}
+	close(s.stopPurger)
+	<-s.purgerDone
+	s.stopPurger = nil
+}
+
+// purgeTrash runs one purge pass over all tenants.
+func (s *Server) purgeTrash() {
+	s.writeGate.RLock()
+	defer s.writeGate.RUnlock()
+	cutoff := time.Now().UTC().Add(-s.cfg.TrashRetention)
+	for _, tenant := range s.tenants.List() {
+		scope, err := s.tenantScopeFor(tenant.ID)
+		if err != nil {
+			s.logger.Warn("trash purge: failed to open tenant", slog.String("tenant", tenant.ID), slog.Any("err", err))
+			continue
+		}
+		result, err := scope.storage.PurgeTrash(cutoff)
+		if err != nil {
+			s.logger.Warn("trash purge failed", slog.String("tenant", tenant.ID), slog.Any("err", err))
+		}
+		if result != nil && result.Purged > 0 {
+			s.logger.Info("trash purged",
+				slog.String("tenant", tenant.ID),
+				slog.Int("files", result.Purged),
+				slog.Int64("bytes", result.Bytes),
+			)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Muneer320/RhinoBox/internal/storage"
+)
+
+func TestTrashListAndRestore(t *testing.T) {
+	srv := newTestServer(t)
+	t.Cleanup(srv.Stop)
+
+	resp := ingestMediaAs(t, srv, "", "photo.jpg", []byte("trash me"))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("ingest: expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var ingest struct {
+		Stored []struct {
+			Hash string `json:"hash"`
+		} `json:"stored"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ingest); err != nil || len(ingest.Stored) != 1 {
+		t.Fatalf("decode ingest: %v", err)
+	}
+	hash := ingest.Stored[0].Hash
+
+	if _, err := srv.storage.DeleteFile(storage.DeleteRequest{Hash: hash}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := listFilesAs(t, srv, ""); got != 0 {
+		t.Fatalf("expected no live files after delete, got %d", got)
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/trash", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("list trash: expected 200, got %d", resp.Code)
+	}
+	var trash struct {
+		Files []struct {
+			Hash         string `json:"hash"`
+			OriginalName string `json:"original_name"`
+		} `json:"files"`
+		Total int `json:"total"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&trash); err != nil {
+		t.Fatalf("decode trash: %v", err)
+	}
+	if trash.Total != 1 || trash.Files[0].Hash != hash || trash.Files[0].OriginalName != "photo.jpg" {
+		t.Fatalf("unexpected trash listing: %+v", trash)
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/trash/"+hash+"/restore", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("restore: expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if got := listFilesAs(t, srv, ""); got != 1 {
+		t.Fatalf("expected restored file to be listed, got %d", got)
+	}
+
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/trash/"+hash+"/restore", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("second restore: expected 404, got %d", resp.Code)
+	}
+}
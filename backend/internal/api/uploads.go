@@ -0,0 +1,360 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+	"github.com/Muneer320/RhinoBox/internal/storage"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// tus 1.0 resumable upload protocol (https://tus.io/protocols/resumable-upload).
+// Supported extensions: creation, creation-with-upload, termination, and
+// expiration when uploads expire.
+const (
+	tusVersion        = "1.0.0"
+	tusExtensions     = "creation,creation-with-upload,termination"
+	tusChunkMediaType = "application/offset+octet-stream"
+	tusUploadsPath    = "/ingest/uploads"
+
+	// uploadSweepInterval bounds how often abandoned uploads are looked for.
+	uploadSweepInterval = time.Hour
+)
+
+// tusHeaders sets the headers every tus response carries.
+func tusHeaders(w http.ResponseWriter) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// requireTusVersion rejects requests that do not speak tus 1.0.0.
+func requireTusVersion(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Tus-Resumable") == tusVersion {
+		return true
+	}
+	w.Header().Set("Tus-Version", tusVersion)
+	httpError(w, http.StatusPreconditionFailed, fmt.Sprintf("unsupported tus version, expected Tus-Resumable: %s", tusVersion))
+	return false
+}
+
+// setUploadExpires announces when an unfinished upload expires.
+func (s *Server) setUploadExpires(w http.ResponseWriter, session *storage.UploadSession) {
+	if s.cfg.UploadExpiry <= 0 || session == nil || session.Completed {
+		return
+	}
+	w.Header().Set("Upload-Expires", session.UpdatedAt.Add(s.cfg.UploadExpiry).UTC().Format(http.TimeFormat))
+}
+
+// parseUploadMetadata decodes the Upload-Metadata header: comma-separated
+// "key base64(value)" pairs, where the value may be omitted.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return pairs, nil
+	}
+	for _, item := range strings.Split(header, ",") {
+		fields := strings.Fields(item)
+		switch len(fields) {
+		case 1:
+			pairs[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 value for %q", fields[0])
+			}
+			pairs[fields[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("malformed Upload-Metadata entry %q", strings.TrimSpace(item))
+		}
+	}
+	return pairs, nil
+}
+
+// encodeUploadMetadata renders the Upload-Metadata header for HEAD responses.
+func encodeUploadMetadata(session *storage.UploadSession) string {
+	pairs := map[string]string{}
+	if session.Filename != "" {
+		pairs["filename"] = session.Filename
+	}
+	if session.MimeType != "" {
+		pairs["filetype"] = session.MimeType
+	}
+	if session.CategoryHint != "" {
+		pairs["category"] = session.CategoryHint
+	}
+	if comment := session.Metadata["comment"]; comment != "" {
+		pairs["comment"] = comment
+	}
+	keys := make([]string, 0, len(pairs))
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	items := make([]string, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, k+" "+base64.StdEncoding.EncodeToString([]byte(pairs[k])))
+	}
+	return strings.Join(items, ",")
+}
+
+// handleTusOptions handles OPTIONS /ingest/uploads (server capability discovery).
+func (s *Server) handleTusOptions(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	w.Header().Set("Tus-Version", tusVersion)
+	if s.cfg.UploadExpiry > 0 {
+		w.Header().Set("Tus-Extension", tusExtensions+",expiration")
+	} else {
+		w.Header().Set("Tus-Extension", tusExtensions)
+	}
+	if s.cfg.MaxUploadBytes > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(s.cfg.MaxUploadBytes, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTusCreate handles POST /ingest/uploads.
+// Recognised Upload-Metadata keys: filename, filetype, category, comment.
+func (s *Server) handleTusCreate(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	if !requireTusVersion(w, r) {
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		s.handleError(w, r, apierrors.BadRequest("Upload-Length header must be a non-negative integer"))
+		return
+	}
+	if s.cfg.MaxUploadBytes > 0 && length > s.cfg.MaxUploadBytes {
+		s.handleError(w, r, apierrors.NewAPIError(apierrors.ErrorCodeRequestTooLarge,
+			fmt.Sprintf("upload length %d exceeds maximum of %d bytes", length, s.cfg.MaxUploadBytes)))
+		return
+	}
+
+	pairs, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		s.handleError(w, r, apierrors.BadRequestf("invalid Upload-Metadata: %v", err))
+		return
+	}
+	metadata := map[string]string{}
+	if comment := pairs["comment"]; comment != "" {
+		metadata["comment"] = comment
+	}
+
+	session, err := s.tenant(r).storage.CreateUpload(storage.UploadRequest{
+		Length:       length,
+		Filename:     pairs["filename"],
+		MimeType:     pairs["filetype"],
+		CategoryHint: pairs["category"],
+		Metadata:     metadata,
+	})
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", tusUploadsPath+"/"+session.ID)
+
+	// creation-with-upload: the POST body may already carry the first chunk.
+	if r.ContentLength > 0 && r.Header.Get("Content-Type") == tusChunkMediaType {
+		session, err = s.tenant(r).storage.WriteUploadChunk(session.ID, 0, r.Body)
+		if err != nil {
+			s.handleError(w, r, err)
+			return
+		}
+	}
+	s.afterUploadWrite(s.tenant(r), session)
+
+	s.logger.Info("upload created",
+		slog.String("upload_id", session.ID),
+		slog.Int64("length", session.Length),
+		slog.String("filename", session.Filename),
+	)
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	s.setUploadExpires(w, session)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleTusHead handles HEAD /ingest/uploads/{upload_id}.
+func (s *Server) handleTusHead(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	if !requireTusVersion(w, r) {
+		return
+	}
+
+	session, err := s.tenant(r).storage.GetUpload(chi.URLParam(r, "upload_id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrUploadNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.Length, 10))
+	if meta := encodeUploadMetadata(session); meta != "" {
+		w.Header().Set("Upload-Metadata", meta)
+	}
+	s.setUploadExpires(w, session)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleTusPatch handles PATCH /ingest/uploads/{upload_id}.
+func (s *Server) handleTusPatch(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	if !requireTusVersion(w, r) {
+		return
+	}
+	if r.Header.Get("Content-Type") != tusChunkMediaType {
+		httpError(w, http.StatusUnsupportedMediaType, "Content-Type must be "+tusChunkMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		s.handleError(w, r, apierrors.BadRequest("Upload-Offset header must be a non-negative integer"))
+		return
+	}
+
+	session, err := s.tenant(r).storage.WriteUploadChunk(chi.URLParam(r, "upload_id"), offset, r.Body)
+	if session != nil {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+		s.setUploadExpires(w, session)
+	}
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+	s.afterUploadWrite(s.tenant(r), session)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTusTerminate handles DELETE /ingest/uploads/{upload_id}.
+func (s *Server) handleTusTerminate(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	if !requireTusVersion(w, r) {
+		return
+	}
+
+	uploadID := chi.URLParam(r, "upload_id")
+	if err := s.tenant(r).storage.TerminateUpload(uploadID); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	s.logger.Info("upload terminated", slog.String("upload_id", uploadID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleUploadStatus handles GET /ingest/uploads/{upload_id}. It is not part of
+// tus; clients use it to read the stored file record once the upload completes.
+func (s *Server) handleUploadStatus(w http.ResponseWriter, r *http.Request) {
+	session, err := s.tenant(r).storage.GetUpload(chi.URLParam(r, "upload_id"))
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	response := map[string]any{
+		"upload_id":  session.ID,
+		"offset":     session.Offset,
+		"length":     session.Length,
+		"completed":  session.Completed,
+		"created_at": session.CreatedAt,
+		"updated_at": session.UpdatedAt,
+	}
+	if session.Result != nil {
+		response["file"] = mediaIngestRecord(&storage.StoreResult{Metadata: *session.Result, Duplicate: session.Duplicate}, session.Metadata["comment"])
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// afterUploadWrite records completed uploads in the media ingest log, matching
+// what the multipart ingest endpoints write.
+func (s *Server) afterUploadWrite(ts *tenantScope, session *storage.UploadSession) {
+	if session == nil || !session.Completed || session.Result == nil {
+		return
+	}
+	record := mediaIngestRecord(&storage.StoreResult{Metadata: *session.Result, Duplicate: session.Duplicate}, session.Metadata["comment"])
+	record["upload_id"] = session.ID
+	if _, err := ts.storage.AppendNDJSON(filepath.ToSlash(filepath.Join("media", "ingest_log.ndjson")), []map[string]any{record}); err != nil {
+		// log but don't fail request
+		s.logger.Warn("failed to append media log", slog.Any("err", err))
+	}
+
+	s.logger.Info("upload completed",
+		slog.String("upload_id", session.ID),
+		slog.String("hash", session.Result.Hash),
+		slog.Bool("duplicate", session.Duplicate),
+	)
+}
+
+// startUploadSweeper periodically discards resumable uploads that went
+// longer than UploadExpiry without a chunk, in every tenant. It is a no-op
+// when UploadExpiry is zero.
+func (s *Server) startUploadSweeper() {
+	if s.cfg.UploadExpiry <= 0 {
+		return
+	}
+	s.stopSweeper = make(chan struct{})
+	s.sweeperDone = make(chan struct{})
+
+	go func() {
+		defer close(s.sweeperDone)
+		ticker := time.NewTicker(min(s.cfg.UploadExpiry, uploadSweepInterval))
+		defer ticker.Stop()
+
+		s.expireUploads()
+		for {
+			select {
+			case <-ticker.C:
+				s.expireUploads()
+			case <-s.stopSweeper:
+				return
+			}
+		}
+	}()
+}
+
+// stopUploadSweeper stops the sweeper goroutine and waits for an active run to finish.
+func (s *Server) stopUploadSweeper() {
+	if s.stopSweeper == nil {
+		return
+	}
+	close(s.stopSweeper)
+	<-s.sweeperDone
+	s.stopSweeper = nil
+}
+
+// expireUploads runs one sweep over all tenants.
+func (s *Server) expireUploads() {
+	s.writeGate.RLock()
+	defer s.writeGate.RUnlock()
+	cutoff := time.Now().UTC().Add(-s.cfg.UploadExpiry)
+	for _, tenant := range s.tenants.List() {
+		scope, err := s.tenantScopeFor(tenant.ID)
+		if err != nil {
+			s.logger.Warn("upload sweep: failed to open tenant", slog.String("tenant", tenant.ID), slog.Any("err", err))
+			continue
+		}
+		expired, err := scope.storage.ExpireUploads(cutoff)
+		if err != nil {
+			s.logger.Warn("upload sweep failed", slog.String("tenant", tenant.ID), slog.Any("err", err))
+		}
+		if expired > 0 {
+			s.logger.Info("expired uploads removed", slog.String("tenant", tenant.ID), slog.Int("uploads", expired))
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func tusRequest(method, target string, body []byte) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.Header.Set("Tus-Resumable", tusVersion)
+	return req
+}
+
+func createTusUpload(t *testing.T, srv *Server, length int, metadata string) string {
+	t.Helper()
+	req := tusRequest(http.MethodPost, "/ingest/uploads", nil)
+	req.Header.Set("Upload-Length", strconv.Itoa(length))
+	req.Header.Set("Upload-Metadata", metadata)
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	location := resp.Header().Get("Location")
+	if location == "" {
+		t.Fatal("missing Location header")
+	}
+	return location
+}
+
+func patchTusUpload(srv *Server, location string, offset int, chunk []byte) *httptest.ResponseRecorder {
+	req := tusRequest(http.MethodPatch, location, chunk)
+	req.Header.Set("Content-Type", tusChunkMediaType)
+	req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestTusUploadLifecycle(t *testing.T) {
+	srv := newTestServer(t)
+
+	content := []byte("resumable upload payload that arrives in pieces")
+	metadata := "filename " + base64.StdEncoding.EncodeToString([]byte("notes.txt")) +
+		",filetype " + base64.StdEncoding.EncodeToString([]byte("text/plain")) +
+		",comment " + base64.StdEncoding.EncodeToString([]byte("chunked"))
+	location := createTusUpload(t, srv, len(content), metadata)
+
+	// First chunk
+	resp := patchTusUpload(srv, location, 0, content[:10])
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("patch 1: expected 204, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if got := resp.Header().Get("Upload-Offset"); got != "10" {
+		t.Fatalf("expected Upload-Offset 10, got %q", got)
+	}
+
+	// Resume: HEAD reports the offset
+	head := tusRequest(http.MethodHead, location, nil)
+	headResp := httptest.NewRecorder()
+	srv.router.ServeHTTP(headResp, head)
+	if headResp.Code != http.StatusOK {
+		t.Fatalf("head: expected 200, got %d", headResp.Code)
+	}
+	if got := headResp.Header().Get("Upload-Offset"); got != "10" {
+		t.Fatalf("head: expected offset 10, got %q", got)
+	}
+	if got := headResp.Header().Get("Upload-Length"); got != strconv.Itoa(len(content)) {
+		t.Fatalf("head: expected length %d, got %q", len(content), got)
+	}
+
+	// Wrong offset is rejected
+	if resp := patchTusUpload(srv, location, 5, content[5:]); resp.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for offset mismatch, got %d", resp.Code)
+	}
+
+	// Final chunk completes the upload
+	resp = patchTusUpload(srv, location, 10, content[10:])
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("patch 2: expected 204, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	statusResp := httptest.NewRecorder()
+	srv.router.ServeHTTP(statusResp, httptest.NewRequest(http.MethodGet, location, nil))
+	if statusResp.Code != http.StatusOK {
+		t.Fatalf("status: expected 200, got %d", statusResp.Code)
+	}
+	var status struct {
+		Completed bool           `json:"completed"`
+		File      map[string]any `json:"file"`
+	}
+	if err := json.NewDecoder(statusResp.Body).Decode(&status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if !status.Completed {
+		t.Fatal("upload should be completed")
+	}
+	if status.File["original_name"] != "notes.txt" || status.File["comment"] != "chunked" {
+		t.Fatalf("unexpected stored record: %+v", status.File)
+	}
+
+	storedPath, _ := status.File["path"].(string)
+	data, err := os.ReadFile(filepath.Join(srv.cfg.DataDir, filepath.FromSlash(storedPath)))
+	if err != nil {
+		t.Fatalf("read stored file: %v", err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("stored content mismatch: %q", data)
+	}
+}
+
+func TestTusUploadDeduplicates(t *testing.T) {
+	srv := newTestServer(t)
+	content := []byte("same bytes twice")
+	metadata := "filename " + base64.StdEncoding.EncodeToString([]byte("a.txt"))
+
+	for i := 0; i < 2; i++ {
+		location := createTusUpload(t, srv, len(content), metadata)
+		if resp := patchTusUpload(srv, location, 0, content); resp.Code != http.StatusNoContent {
+			t.Fatalf("upload %d: expected 204, got %d", i, resp.Code)
+		}
+		upload, err := srv.storage.GetUpload(filepath.Base(location))
+		if err != nil {
+			t.Fatalf("get upload: %v", err)
+		}
+		if upload.Duplicate != (i == 1) {
+			t.Fatalf("upload %d: expected duplicate=%v", i, i == 1)
+		}
+	}
+}
+
+func TestTusUploadTerminate(t *testing.T) {
+	srv := newTestServer(t)
+	location := createTusUpload(t, srv, 100, "")
+
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, tusRequest(http.MethodDelete, location, nil))
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.Code)
+	}
+
+	headResp := httptest.NewRecorder()
+	srv.router.ServeHTTP(headResp, tusRequest(http.MethodHead, location, nil))
+	if headResp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after termination, got %d", headResp.Code)
+	}
+}
+
+func TestTusProtocolChecks(t *testing.T) {
+	srv := newTestServer(t)
+
+	// Missing Tus-Resumable
+	req := httptest.NewRequest(http.MethodPost, "/ingest/uploads", nil)
+	req.Header.Set("Upload-Length", "10")
+	resp := httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412, got %d", resp.Code)
+	}
+
+	// Declared length above MaxUploadBytes
+	req = tusRequest(http.MethodPost, "/ingest/uploads", nil)
+	req.Header.Set("Upload-Length", strconv.FormatInt(srv.cfg.MaxUploadBytes+1, 10))
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", resp.Code)
+	}
+
+	// Wrong chunk content type
+	location := createTusUpload(t, srv, 4, "")
+	req = tusRequest(http.MethodPatch, location, []byte("data"))
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Upload-Offset", "0")
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", resp.Code)
+	}
+
+	// Chunk longer than the declared length
+	if resp := patchTusUpload(srv, location, 0, []byte("too long")); resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for oversized chunk, got %d", resp.Code)
+	}
+
+	// Capability discovery
+	resp = httptest.NewRecorder()
+	srv.router.ServeHTTP(resp, httptest.NewRequest(http.MethodOptions, "/ingest/uploads", nil))
+	if resp.Code != http.StatusNoContent || resp.Header().Get("Tus-Version") != tusVersion {
+		t.Fatalf("unexpected OPTIONS response: %d %v", resp.Code, resp.Header())
+	}
+}
+
+func TestTusUploadExpiration(t *testing.T) {
+	srv := newTestServer(t)
+	srv.cfg.UploadExpiry = time.Hour
+
+	location := createTusUpload(t, srv, 8, "")
+	resp := patchTusUpload(srv, location, 0, []byte("half"))
+	expires, err := http.ParseTime(resp.Header().Get("Upload-Expires"))
+	if err != nil || time.Until(expires) <= 0 || time.Until(expires) > time.Hour {
+		t.Fatalf("unexpected Upload-Expires %q: %v", resp.Header().Get("Upload-Expires"), err)
+	}
+
+	// A negative expiry puts the cutoff ahead of the last chunk.
+	srv.cfg.UploadExpiry = -time.Minute
+	srv.expireUploads()
+	headResp := httptest.NewRecorder()
+	srv.router.ServeHTTP(headResp, tusRequest(http.MethodHead, location, nil))
+	if headResp.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after expiry, got %d", headResp.Code)
+	}
+}
@@ -79,7 +79,7 @@ func (s *Server) handleCreateVersion(w http.ResponseWriter, r *http.Request) {
 		UploadedBy: uploadedBy,
 	}
 
-	result, err := s.storage.CreateVersion(versionReq)
+	result, err := s.tenant(r).storage.CreateVersion(versionReq)
 	if err != nil {
 		if errors.Is(err, storage.ErrFileNotFound) {
 			httpError(w, http.StatusNotFound, err.Error())
@@ -114,7 +114,7 @@ func (s *Server) handleListVersions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	versions, err := s.storage.ListVersions(fileID)
+	versions, err := s.tenant(r).storage.ListVersions(fileID)
 	if err != nil {
 		if errors.Is(err, storage.ErrFileNotFound) {
 			httpError(w, http.StatusNotFound, err.Error())
@@ -167,7 +167,7 @@ func (s *Server) handleGetVersion(w http.ResponseWriter, r *http.Request) {
 
 	if download {
 		// Download the file
-		result, err := s.storage.GetVersionFile(fileID, versionNumber)
+		result, err := s.tenant(r).storage.GetVersionFile(fileID, versionNumber)
 		if err != nil {
 			if errors.Is(err, storage.ErrFileNotFound) || errors.Is(err, storage.ErrVersionNotFound) {
 				httpError(w, http.StatusNotFound, err.Error())
@@ -190,7 +190,7 @@ func (s *Server) handleGetVersion(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		// Return version metadata
-		version, err := s.storage.GetVersion(fileID, versionNumber)
+		version, err := s.tenant(r).storage.GetVersion(fileID, versionNumber)
 		if err != nil {
 			if errors.Is(err, storage.ErrFileNotFound) || errors.Is(err, storage.ErrVersionNotFound) {
 				httpError(w, http.StatusNotFound, err.Error())
@@ -240,7 +240,7 @@ func (s *Server) handleRevertVersion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	version, err := s.storage.RevertVersion(fileID, req.Version, req.Comment)
+	version, err := s.tenant(r).storage.RevertVersion(fileID, req.Version, req.Comment)
 	if err != nil {
 		if errors.Is(err, storage.ErrFileNotFound) || errors.Is(err, storage.ErrVersionNotFound) {
 			httpError(w, http.StatusNotFound, err.Error())
@@ -299,7 +299,7 @@ func (s *Server) handleVersionDiff(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	diff, err := s.storage.GetVersionDiff(fileID, fromVersion, toVersion)
+	diff, err := s.tenant(r).storage.GetVersionDiff(fileID, fromVersion, toVersion)
 	if err != nil {
 		if errors.Is(err, storage.ErrFileNotFound) || errors.Is(err, storage.ErrVersionNotFound) {
 			httpError(w, http.StatusNotFound, err.Error())
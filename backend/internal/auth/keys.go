@@ -0,0 +1,307 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// keyPrefix marks RhinoBox API keys so they are easy to spot in logs and secret scanners.
+const keyPrefix = "rbx_"
+
+var (
+	// ErrKeyNotFound is returned when an API key ID is unknown.
+	ErrKeyNotFound = errors.New("api key not found")
+	// ErrInvalidKey is returned when a presented key does not match an active key.
+	ErrInvalidKey = errors.New("invalid api key")
+	// ErrKeyRevoked is returned when a presented key has been revoked.
+	ErrKeyRevoked = errors.New("api key revoked")
+	// ErrKeyExpired is returned when a presented key is past its expiry.
+	ErrKeyExpired = errors.New("api key expired")
+	// ErrInvalidScope is returned when a key is created with an unknown scope.
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// APIKey is the persisted record for an issued key. Only the SHA-256 hash of
+// the secret is kept; the plaintext is returned once, from Create.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"hash"`
+	Scopes     []Scope    `json:"scopes"`
+	Tenant     string     `json:"tenant,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Allows reports whether the key grants scope.
+func (k *APIKey) Allows(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == ScopeAdmin || s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the key is neither revoked nor expired at now.
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// CreateKeyRequest captures parameters for issuing a new key.
+type CreateKeyRequest struct {
+	Name   string
+	Scopes []Scope
+	// Tenant pins the key to one tenant. Empty keys may pick any tenant.
+	Tenant    string
+	ExpiresAt *time.Time
+}
+
+// KeyStore keeps API keys in DataDir/auth/keys.json. The whole set is small
+// and held in memory; every mutation rewrites the file atomically.
+type KeyStore struct {
+	path   string
+	mu     sync.RWMutex
+	keys   map[string]*APIKey // id -> key
+	byHash map[string]*APIKey // sha256(secret) -> key
+}
+
+// NewKeyStore loads (or creates) the key store under dataDir.
+func NewKeyStore(dataDir string) (*KeyStore, error) {
+	dir := filepath.Join(dataDir, "auth")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create auth dir: %w", err)
+	}
+	store := &KeyStore{
+		path:   filepath.Join(dir, "keys.json"),
+		keys:   make(map[string]*APIKey),
+		byHash: make(map[string]*APIKey),
+	}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *KeyStore) load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var keys []*APIKey
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return fmt.Errorf("decode api keys: %w", err)
+		}
+	}
+	for _, key := range keys {
+		s.keys[key.ID] = key
+		s.byHash[key.Hash] = key
+	}
+	return nil
+}
+
+// persist writes the key set to disk. Callers must hold s.mu.
+func (s *KeyStore) persist() error {
+	keys := make([]*APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+
+	buf, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Create issues a new key and returns its record together with the plaintext
+// secret. The secret cannot be recovered later.
+func (s *KeyStore) Create(req CreateKeyRequest) (*APIKey, string, error) {
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := s.Import(req, secret)
+	if err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// Import registers an externally supplied secret, e.g. a bootstrap key from
+// the environment.
+func (s *KeyStore) Import(req CreateKeyRequest, secret string) (*APIKey, error) {
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(secret) == "" {
+		return nil, fmt.Errorf("%w: empty secret", ErrInvalidKey)
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "unnamed"
+	}
+	key := &APIKey{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Prefix:    displayPrefix(secret),
+		Hash:      hashSecret(secret),
+		Scopes:    scopes,
+		Tenant:    req.Tenant,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.byHash[key.Hash]; exists {
+		return nil, fmt.Errorf("%w: key already registered", ErrInvalidKey)
+	}
+	s.keys[key.ID] = key
+	s.byHash[key.Hash] = key
+	if err := s.persist(); err != nil {
+		delete(s.keys, key.ID)
+		delete(s.byHash, key.Hash)
+		return nil, err
+	}
+	return key, nil
+}
+
+// Authenticate resolves a presented secret to its key record.
+func (s *KeyStore) Authenticate(secret string) (*APIKey, error) {
+	if secret == "" {
+		return nil, ErrInvalidKey
+	}
+	hash := hashSecret(secret)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.byHash[hash]
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	now := time.Now().UTC()
+	if key.RevokedAt != nil {
+		return nil, ErrKeyRevoked
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, ErrKeyExpired
+	}
+	// Last use is tracked in memory only; it is flushed with the next mutation.
+	key.LastUsedAt = &now
+	copied := *key
+	return &copied, nil
+}
+
+// List returns all keys, including revoked ones, oldest first.
+func (s *KeyStore) List() []APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, *key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys
+}
+
+// Get returns a single key by ID.
+func (s *KeyStore) Get(id string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: id=%s", ErrKeyNotFound, id)
+	}
+	copied := *key
+	return &copied, nil
+}
+
+// Revoke disables a key. Revoking an already revoked key is a no-op.
+func (s *KeyStore) Revoke(id string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: id=%s", ErrKeyNotFound, id)
+	}
+	if key.RevokedAt == nil {
+		now := time.Now().UTC()
+		key.RevokedAt = &now
+		if err := s.persist(); err != nil {
+			key.RevokedAt = nil
+			return nil, err
+		}
+	}
+	copied := *key
+	return &copied, nil
+}
+
+// HasActiveAdmin reports whether at least one usable admin key exists.
+func (s *KeyStore) HasActiveAdmin() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now().UTC()
+	for _, key := range s.keys {
+		if key.Active(now) && key.Allows(ScopeAdmin) {
+			return true
+		}
+	}
+	return false
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate api key: %w", err)
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// displayPrefix keeps enough of the secret for operators to tell keys apart.
+func displayPrefix(secret string) string {
+	const visible = 8
+	if strings.HasPrefix(secret, keyPrefix) && len(secret) > len(keyPrefix)+visible {
+		return secret[:len(keyPrefix)+visible]
+	}
+	if len(secret) > visible {
+		return secret[:visible/2]
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyStoreCreateAuthenticateRevoke(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewKeyStore(dir)
+	if err != nil {
+		t.Fatalf("new key store: %v", err)
+	}
+
+	key, secret, err := store.Create(CreateKeyRequest{Name: "ci", Scopes: []Scope{"ingest", "read", "read"}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.HasPrefix(secret, keyPrefix) {
+		t.Fatalf("expected %q prefix, got %q", keyPrefix, secret)
+	}
+	if len(key.Scopes) != 2 || key.Scopes[0] != ScopeIngest || key.Scopes[1] != ScopeRead {
+		t.Fatalf("expected normalized scopes [ingest read], got %v", key.Scopes)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "auth", "keys.json"))
+	if err != nil {
+		t.Fatalf("read keys file: %v", err)
+	}
+	if strings.Contains(string(raw), secret) {
+		t.Fatal("plaintext secret must not be persisted")
+	}
+
+	got, err := store.Authenticate(secret)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if got.ID != key.ID || !got.Allows(ScopeRead) || got.Allows(ScopeDelete) {
+		t.Fatalf("unexpected key %+v", got)
+	}
+	if _, err := store.Authenticate(secret + "x"); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("expected ErrInvalidKey, got %v", err)
+	}
+
+	// Reload from disk
+	reloaded, err := NewKeyStore(dir)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, err := reloaded.Authenticate(secret); err != nil {
+		t.Fatalf("authenticate after reload: %v", err)
+	}
+
+	if _, err := reloaded.Revoke(key.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := reloaded.Authenticate(secret); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("expected ErrKeyRevoked, got %v", err)
+	}
+	if _, err := reloaded.Revoke("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestKeyStoreRejectsInvalidScopesAndExpiredKeys(t *testing.T) {
+	store, err := NewKeyStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new key store: %v", err)
+	}
+
+	if _, _, err := store.Create(CreateKeyRequest{Name: "none"}); !errors.Is(err, ErrInvalidScope) {
+		t.Fatalf("expected ErrInvalidScope for empty scopes, got %v", err)
+	}
+	if _, _, err := store.Create(CreateKeyRequest{Name: "bad", Scopes: []Scope{"write"}}); !errors.Is(err, ErrInvalidScope) {
+		t.Fatalf("expected ErrInvalidScope, got %v", err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	_, secret, err := store.Create(CreateKeyRequest{Name: "old", Scopes: []Scope{ScopeAdmin}, ExpiresAt: &past})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := store.Authenticate(secret); !errors.Is(err, ErrKeyExpired) {
+		t.Fatalf("expected ErrKeyExpired, got %v", err)
+	}
+	if store.HasActiveAdmin() {
+		t.Fatal("expired admin key must not count as active")
+	}
+}
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   Scope
+	}{
+		{"GET", "/healthz", ScopeNone},
+		{"GET", "/api/config", ScopeNone},
+		{"OPTIONS", "/ingest/uploads", ScopeNone},
+		{"GET", "/files", ScopeRead},
+		{"GET", "/files/download", ScopeRead},
+		{"POST", "/ingest/media", ScopeIngest},
+		{"POST", "/files/archive", ScopeRead},
+		{"POST", "/files/abc/shares", ScopeRead},
+		{"POST", "/files/abc/notes", ScopeIngest},
+		{"HEAD", "/ingest/uploads/abc", ScopeIngest},
+		{"DELETE", "/ingest/uploads/abc", ScopeIngest},
+		{"PATCH", "/files/abc/metadata", ScopeIngest},
+		{"DELETE", "/files/abc", ScopeDelete},
+		{"GET", "/admin/keys", ScopeAdmin},
+		{"DELETE", "/admin/keys/abc", ScopeAdmin},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := RequiredScope(req); got != tt.want {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Scope names a permission granted to an API key.
+type Scope string
+
+const (
+	ScopeRead   Scope = "read"   // list, search, download and stream
+	ScopeIngest Scope = "ingest" // upload and modify files and metadata
+	ScopeDelete Scope = "delete" // delete files and notes
+	ScopeAdmin  Scope = "admin"  // manage keys; implies every other scope
+)
+
+// ScopeNone marks requests that do not require a key.
+const ScopeNone Scope = ""
+
+// AllScopes lists the scopes accepted when issuing keys.
+var AllScopes = []Scope{ScopeRead, ScopeIngest, ScopeDelete, ScopeAdmin}
+
+// ParseScope validates a scope name.
+func ParseScope(raw string) (Scope, error) {
+	scope := Scope(strings.ToLower(strings.TrimSpace(raw)))
+	for _, known := range AllScopes {
+		if scope == known {
+			return scope, nil
+		}
+	}
+	return ScopeNone, fmt.Errorf("%w: %q", ErrInvalidScope, raw)
+}
+
+// normalizeScopes validates, de-duplicates and sorts scopes. At least one is required.
+func normalizeScopes(scopes []Scope) ([]Scope, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	seen := make(map[Scope]bool, len(scopes))
+	result := make([]Scope, 0, len(scopes))
+	for _, raw := range scopes {
+		scope, err := ParseScope(string(raw))
+		if err != nil {
+			return nil, err
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result, nil
+}
+
+// publicPaths never require a key: probes and the config endpoint the
+// frontend reads before it knows whether to prompt for credentials.
+var publicPaths = map[string]bool{
+	"/healthz":    true,
+	"/api/config": true,
+}
+
+// readPosts are POST routes that only read, taking their selection in the
+// body because it does not fit in a query string.
+var readPosts = map[string]bool{
+	"/files/archive": true,
+}
+
+const (
+	// SharePathPrefix is where share links are served.
+	SharePathPrefix = "/s/"
+	// PresignedUploadPathPrefix is where presigned upload URLs point.
+	PresignedUploadPathPrefix = "/ingest/presigned/"
+)
+
+// RequiredScope maps a request onto the scope it needs. Routes are matched by
+// method and path prefix, the same way the validator keys its schemas.
+func RequiredScope(r *http.Request) Scope {
+	path := r.URL.Path
+	if r.Method == http.MethodOptions || publicPaths[path] {
+		return ScopeNone
+	}
+	// Share links and presigned uploads carry their own signed token
+	// instead of an API key.
+	if strings.HasPrefix(path, SharePathPrefix) || strings.HasPrefix(path, PresignedUploadPathPrefix) {
+		return ScopeNone
+	}
+	if path == "/admin" || strings.HasPrefix(path, "/admin/") {
+		return ScopeAdmin
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		// HEAD on a tus upload is part of the upload flow, not a read.
+		if r.Method == http.MethodHead && strings.HasPrefix(path, "/ingest/") {
+			return ScopeIngest
+		}
+		return ScopeRead
+	case http.MethodDelete:
+		// Terminating an in-flight upload belongs to whoever may upload.
+		if strings.HasPrefix(path, "/ingest/") {
+			return ScopeIngest
+		}
+		return ScopeDelete
+	case http.MethodPost:
+		if readPosts[path] || isShareCreatePath(path) {
+			return ScopeRead
+		}
+		return ScopeIngest
+	default:
+		return ScopeIngest
+	}
+}
+
+// isShareCreatePath matches /files/{file_id}/shares. A share link hands the
+// file to anyone holding it, so creating one needs the scope that reads it.
+func isShareCreatePath(path string) bool {
+	fileID, ok := strings.CutSuffix(strings.TrimPrefix(path, "/files/"), "/shares")
+	return ok && strings.HasPrefix(path, "/files/") && fileID != "" && !strings.Contains(fileID, "/")
+}
+
+type contextKey struct{}
+
+// WithKey returns a context carrying the authenticated key.
+func WithKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// KeyFromContext returns the key that authenticated the request, if any.
+func KeyFromContext(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(contextKey{}).(*APIKey)
+	return key, ok && key != nil
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignerRoundTrip(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	token := signer.Sign("share:default:42")
+	payload, err := signer.Verify(token)
+	if err != nil || payload != "share:default:42" {
+		t.Fatalf("verify: %q %v", payload, err)
+	}
+
+	encoded, sig, _ := strings.Cut(token, ".")
+	forged := NewSigner([]byte("secret")).Sign("share:other:42")
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	for _, bad := range []string{"", encoded, forgedPayload + "." + sig, token + "x"} {
+		if _, err := signer.Verify(bad); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("expected ErrInvalidSignature for %q, got %v", bad, err)
+		}
+	}
+	if _, err := NewSigner([]byte("other")).Verify(token); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected tokens to be bound to the key, got %v", err)
+	}
+}
+
+func TestLoadSignerPersistsKey(t *testing.T) {
+	dir := t.TempDir()
+	first, err := LoadSigner(dir, "")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	token := first.Sign("payload")
+	second, err := LoadSigner(dir, "")
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, err := second.Verify(token); err != nil {
+		t.Fatalf("expected the generated key to survive a restart, got %v", err)
+	}
+	configured, _ := LoadSigner(dir, "configured")
+	if _, err := configured.Verify(token); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected a configured secret to take precedence, got %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 )
 
 // Config captures the runtime configuration for RhinoBox.
@@ -17,12 +18,55 @@ type Config struct {
 	PostgresURL    string
 	MongoURL       string
 	DBMaxConns     int
+	// MongoDatabase holds NoSQL-routed JSON collections; other tenants use
+	// "<MongoDatabase>_<tenant>"
+	MongoDatabase string
 	
 	// Authentication configuration
 	AuthEnabled bool
+	// AuthBootstrapKey seeds an admin API key on first start when no admin key exists
+	AuthBootstrapKey string
+	// SigningSecret keys the HMAC of share link tokens. When empty a random
+	// key is generated once and kept in DataDir/auth/signing.key
+	SigningSecret string
 	
+	// Trash configuration: deleted files are purged once older than
+	// TrashRetention. A zero retention keeps trashed files until restored.
+	TrashRetention     time.Duration
+	TrashPurgeInterval time.Duration
+
+	// UploadExpiry is how long a resumable upload may go without a chunk
+	// before its staged data is discarded. Zero keeps uploads until they
+	// complete or are terminated.
+	UploadExpiry time.Duration
+
+	// Thumbnail configuration: ThumbnailSizes are the longest-edge pixel
+	// sizes generated for images. When ThumbnailsOnIngest is set, every newly
+	// stored image gets a thumbnail job queued.
+	ThumbnailSizes     []int
+	ThumbnailsOnIngest bool
+
+	// Archive expansion limits for /ingest?expand=true: entries and
+	// expanded bytes per upload, the compression ratio of each archive and
+	// how many levels of nested archives are unpacked.
+	ArchiveMaxEntries int
+	ArchiveMaxBytes   int64
+	ArchiveMaxRatio   int
+	ArchiveMaxDepth   int
+
+	// QueueWorkers is the number of background job queue workers. Jobs with
+	// failed items are retried QueueMaxRetries times, backing off from
+	// QueueRetryDelay up to QueueRetryMaxDelay, before being dead-lettered.
+	QueueWorkers       int
+	QueueMaxRetries    int
+	QueueRetryDelay    time.Duration
+	QueueRetryMaxDelay time.Duration
+
 	// Security configuration
 	Security SecurityConfig
+
+	// Blob storage backend configuration
+	Storage StorageConfig
 }
 
 // Load reads environment variables and falls back to sane defaults for hackathon usage.
@@ -44,6 +88,7 @@ func Load() (Config, error) {
 	// Database configuration (optional)
 	postgresURL := getEnv("RHINOBOX_POSTGRES_URL", "")
 	mongoURL := getEnv("RHINOBOX_MONGO_URL", "")
+	mongoDatabase := getEnv("RHINOBOX_MONGO_DATABASE", "rhinobox")
 	
 	dbMaxConns := 100
 	if raw := os.Getenv("RHINOBOX_DB_MAX_CONNS"); raw != "" {
@@ -54,6 +99,31 @@ func Load() (Config, error) {
 
 	// Authentication configuration (defaults to false for security)
 	authEnabled := getBoolEnvFromEnv("RHINOBOX_AUTH_ENABLED", false)
+	authBootstrapKey := getEnv("RHINOBOX_AUTH_BOOTSTRAP_KEY", "")
+	signingSecret := getEnv("RHINOBOX_SIGNING_SECRET", "")
+
+	// Trash retention (defaults to 30 days, checked hourly; 0 days disables purging)
+	trashRetention := time.Duration(getIntEnv("RHINOBOX_TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour
+	trashPurgeInterval := getDurationEnv("RHINOBOX_TRASH_PURGE_INTERVAL", time.Hour)
+
+	// Resumable uploads (abandoned after a day without a chunk; 0 keeps them)
+	uploadExpiry := getDurationEnv("RHINOBOX_UPLOAD_EXPIRY", 24*time.Hour)
+
+	// Thumbnails (defaults to 128, 256 and 512 px, generated in the background on ingest)
+	thumbnailSizes := getIntSliceEnv("RHINOBOX_THUMBNAIL_SIZES", []int{128, 256, 512})
+	thumbnailsOnIngest := getBoolEnvFromEnv("RHINOBOX_THUMBNAILS_ON_INGEST", true)
+
+	// Archive expansion limits (defaults to 10000 entries, 10 GiB, 100:1 and 3 levels)
+	archiveMaxEntries := getIntEnv("RHINOBOX_ARCHIVE_MAX_ENTRIES", 10000)
+	archiveMaxBytes := int64(getIntEnv("RHINOBOX_ARCHIVE_MAX_MB", 10*1024)) * 1024 * 1024
+	archiveMaxRatio := getIntEnv("RHINOBOX_ARCHIVE_MAX_RATIO", 100)
+	archiveMaxDepth := getIntEnv("RHINOBOX_ARCHIVE_MAX_DEPTH", 3)
+
+	// Background job workers
+	queueWorkers := getIntEnv("RHINOBOX_QUEUE_WORKERS", 10)
+	queueMaxRetries := getIntEnv("RHINOBOX_QUEUE_MAX_RETRIES", 3)
+	queueRetryDelay := getDurationEnv("RHINOBOX_QUEUE_RETRY_DELAY", time.Second)
+	queueRetryMaxDelay := getDurationEnv("RHINOBOX_QUEUE_RETRY_MAX_DELAY", 5*time.Minute)
 
 	return Config{
 		Addr:           addr,
@@ -62,8 +132,25 @@ func Load() (Config, error) {
 		PostgresURL:    postgresURL,
 		MongoURL:       mongoURL,
 		DBMaxConns:     dbMaxConns,
+		MongoDatabase:  mongoDatabase,
 		AuthEnabled:    authEnabled,
+		AuthBootstrapKey: authBootstrapKey,
+		SigningSecret:    signingSecret,
+		TrashRetention:     trashRetention,
+		TrashPurgeInterval: trashPurgeInterval,
+		UploadExpiry:       uploadExpiry,
+		ThumbnailSizes:     thumbnailSizes,
+		ThumbnailsOnIngest: thumbnailsOnIngest,
+		ArchiveMaxEntries:  archiveMaxEntries,
+		ArchiveMaxBytes:    archiveMaxBytes,
+		ArchiveMaxRatio:    archiveMaxRatio,
+		ArchiveMaxDepth:    archiveMaxDepth,
+		QueueWorkers:       queueWorkers,
+		QueueMaxRetries:    queueMaxRetries,
+		QueueRetryDelay:    queueRetryDelay,
+		QueueRetryMaxDelay: queueRetryMaxDelay,
 		Security:       LoadSecurityConfig(),
+		Storage:        LoadStorageConfig(),
 	}, nil
 }
 
@@ -86,3 +173,18 @@ func getBoolEnvFromEnv(key string, defaultValue bool) bool {
 	}
 	return b
 }
+
+// getIntSliceEnv reads a comma-separated list of positive integers. Invalid
+// entries are skipped; an empty result falls back to defaultValue.
+func getIntSliceEnv(key string, defaultValue []int) []int {
+	var values []int
+	for _, raw := range getStringSliceEnv(key, nil) {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			values = append(values, n)
+		}
+	}
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
@@ -52,8 +52,8 @@ func LoadSecurityConfig() SecurityConfig {
 		// CORS defaults
 		CORSEnabled:      getBoolEnv("RHINOBOX_CORS_ENABLED", true),
 		CORSOrigins:      getStringSliceEnv("RHINOBOX_CORS_ORIGINS", []string{"http://localhost:5173", "http://127.0.0.1:5173", "*"}),
-		CORSAllowMethods: getStringSliceEnv("RHINOBOX_CORS_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
-		CORSAllowHeaders: getStringSliceEnv("RHINOBOX_CORS_HEADERS", []string{"Content-Type", "Authorization", "X-Requested-With"}),
+		CORSAllowMethods: getStringSliceEnv("RHINOBOX_CORS_METHODS", []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		CORSAllowHeaders: getStringSliceEnv("RHINOBOX_CORS_HEADERS", []string{"Content-Type", "Authorization", "X-API-Key", "X-Requested-With", "Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata"}),
 		CORSMaxAge:       getDurationEnv("RHINOBOX_CORS_MAX_AGE", 3600*time.Second),
 		CORSAllowCreds:   getBoolEnv("RHINOBOX_CORS_CREDENTIALS", false),
 
@@ -0,0 +1,39 @@
+package config
+
+import "strings"
+
+// StorageConfig selects where file contents are kept. Metadata and indexes
+// always live under DataDir regardless of backend.
+type StorageConfig struct {
+	Backend string // "local" (default) or "s3"
+
+	// S3-compatible object storage (AWS S3, MinIO, ...)
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3Prefix    string
+	S3PathStyle bool
+
+	// Chunking splits blobs into content-defined chunks that are stored once,
+	// so near-identical files share most of their bytes.
+	Chunking   bool
+	ChunkAvgKB int
+}
+
+// LoadStorageConfig loads blob backend configuration from environment variables
+func LoadStorageConfig() StorageConfig {
+	return StorageConfig{
+		Backend:     strings.ToLower(getEnv("RHINOBOX_STORAGE_BACKEND", "local")),
+		S3Endpoint:  getEnv("RHINOBOX_S3_ENDPOINT", ""),
+		S3Region:    getEnv("RHINOBOX_S3_REGION", "us-east-1"),
+		S3Bucket:    getEnv("RHINOBOX_S3_BUCKET", ""),
+		S3AccessKey: getEnv("RHINOBOX_S3_ACCESS_KEY", ""),
+		S3SecretKey: getEnv("RHINOBOX_S3_SECRET_KEY", ""),
+		S3Prefix:    getEnv("RHINOBOX_S3_PREFIX", ""),
+		S3PathStyle: getBoolEnv("RHINOBOX_S3_PATH_STYLE", true),
+		Chunking:    getBoolEnv("RHINOBOX_STORAGE_CHUNKING", false),
+		ChunkAvgKB:  getIntEnv("RHINOBOX_CHUNK_AVG_KB", 64),
+	}
+}
@@ -70,7 +70,7 @@ func (db *MongoDB) BulkInsert(ctx context.Context, database, collection string,
 		// Build write models for BulkWrite
 		models := make([]mongo.WriteModel, len(batch))
 		for j, doc := range batch {
-			models[j] = mongo.NewInsertOneModel().SetDocument(doc)
+			models[j] = mongo.NewInsertOneModel().SetDocument(normalizeValue(doc))
 		}
 
 		// Execute bulk write with unordered mode (parallel execution)
@@ -71,23 +71,32 @@ func (db *PostgresDB) Stats() *pgxpool.Stat {
 // CreateTableFromSchema creates a PostgreSQL table from a schema definition
 // Schema format: map[columnName]string where string is the SQL type (e.g., "BIGINT", "TEXT")
 func (db *PostgresDB) CreateTableFromSchema(ctx context.Context, tableName string, schema map[string]string) error {
-	// Build CREATE TABLE statement
-	var columns []string
-	for colName, colType := range schema {
-		columns = append(columns, fmt.Sprintf("%q %s", colName, colType))
+	_, err := db.pool.Exec(ctx, createTableSQL(tableName, schema))
+	if err != nil {
+		return fmt.Errorf("create table %s: %w", tableName, err)
 	}
 
-	query := fmt.Sprintf(
-		"CREATE TABLE IF NOT EXISTS %q (%s)",
-		tableName,
-		strings.Join(columns, ", "),
-	)
+	return nil
+}
 
-	_, err := db.pool.Exec(ctx, query)
+// TableExists reports whether a table is visible on the connection's search path
+func (db *PostgresDB) TableExists(ctx context.Context, tableName string) (bool, error) {
+	var exists bool
+	err := db.pool.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", quoteIdentifier(tableName)).Scan(&exists)
 	if err != nil {
-		return fmt.Errorf("create table %s: %w", tableName, err)
+		return false, fmt.Errorf("check table %s: %w", tableName, err)
 	}
+	return exists, nil
+}
 
+// AddMissingColumns adds every column in schema that the table does not have yet
+// Schema format matches CreateTableFromSchema
+func (db *PostgresDB) AddMissingColumns(ctx context.Context, tableName string, schema map[string]string) error {
+	for _, colName := range sortedKeys(schema) {
+		if _, err := db.pool.Exec(ctx, addColumnSQL(tableName, colName, schema[colName])); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", tableName, colName, err)
+		}
+	}
 	return nil
 }
 
@@ -128,11 +137,8 @@ func (db *PostgresDB) copyInsert(ctx context.Context, table string, docs []map[s
 		return nil
 	}
 
-	// Extract column names from first document
-	var columns []string
-	for col := range docs[0] {
-		columns = append(columns, col)
-	}
+	// Documents may carry different fields; missing ones are inserted as NULL
+	columns := batchColumns(docs)
 
 	// Acquire a dedicated connection for COPY
 	conn, err := db.pool.Acquire(ctx)
@@ -146,7 +152,7 @@ func (db *PostgresDB) copyInsert(ctx context.Context, table string, docs []map[s
 	for i, doc := range docs {
 		row := make([]any, len(columns))
 		for j, col := range columns {
-			row[j] = doc[col]
+			row[j] = normalizeValue(doc[col])
 		}
 		rows[i] = row
 	}
@@ -173,34 +179,17 @@ func (db *PostgresDB) multiInsert(ctx context.Context, table string, docs []map[
 		return nil
 	}
 
-	// Extract column names from first document
-	var columns []string
-	for col := range docs[0] {
-		columns = append(columns, col)
-	}
+	// Documents may carry different fields; missing ones are inserted as NULL
+	columns := batchColumns(docs)
 
-	// Build multi-value INSERT statement
-	var placeholders []string
 	var values []any
-
-	for i, doc := range docs {
-		var rowPlaceholders []string
-		for j, col := range columns {
-			placeholder := fmt.Sprintf("$%d", i*len(columns)+j+1)
-			rowPlaceholders = append(rowPlaceholders, placeholder)
-			values = append(values, doc[col])
+	for _, doc := range docs {
+		for _, col := range columns {
+			values = append(values, normalizeValue(doc[col]))
 		}
-		placeholders = append(placeholders, fmt.Sprintf("(%s)", strings.Join(rowPlaceholders, ", ")))
 	}
 
-	query := fmt.Sprintf(
-		"INSERT INTO %q (%s) VALUES %s",
-		table,
-		strings.Join(quoteIdentifiers(columns), ", "),
-		strings.Join(placeholders, ", "),
-	)
-
-	_, err := db.pool.Exec(ctx, query, values...)
+	_, err := db.pool.Exec(ctx, insertSQL(table, columns, len(docs)), values...)
 	if err != nil {
 		return fmt.Errorf("exec insert: %w", err)
 	}
@@ -219,11 +208,50 @@ func (db *PostgresDB) Query(ctx context.Context, query string, args ...any) (pgx
 	return db.pool.Query(ctx, query, args...)
 }
 
+// createTableSQL builds the CREATE TABLE statement for CreateTableFromSchema
+func createTableSQL(table string, schema map[string]string) string {
+	var columns []string
+	for _, colName := range sortedKeys(schema) {
+		columns = append(columns, quoteIdentifier(colName)+" "+schema[colName])
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdentifier(table), strings.Join(columns, ", "))
+}
+
+// addColumnSQL builds the ALTER TABLE statement that adds one column
+func addColumnSQL(table, column, sqlType string) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", quoteIdentifier(table), quoteIdentifier(column), sqlType)
+}
+
+// insertSQL builds a multi-value INSERT of rows rows over columns
+// INSERT INTO table (col1, col2) VALUES ($1, $2), ($3, $4), ...
+func insertSQL(table string, columns []string, rows int) string {
+	placeholders := make([]string, rows)
+	for i := range placeholders {
+		row := make([]string, len(columns))
+		for j := range columns {
+			row[j] = fmt.Sprintf("$%d", i*len(columns)+j+1)
+		}
+		placeholders[i] = "(" + strings.Join(row, ", ") + ")"
+	}
+	return fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES %s",
+		quoteIdentifier(table),
+		strings.Join(quoteIdentifiers(columns), ", "),
+		strings.Join(placeholders, ", "),
+	)
+}
+
+// quoteIdentifier quotes a table or column name, doubling embedded quotes.
+// Field names come straight from ingested documents.
+func quoteIdentifier(id string) string {
+	return pgx.Identifier{id}.Sanitize()
+}
+
 // quoteIdentifiers quotes SQL identifiers
 func quoteIdentifiers(ids []string) []string {
 	quoted := make([]string, len(ids))
 	for i, id := range ids {
-		quoted[i] = fmt.Sprintf("%q", id)
+		quoted[i] = quoteIdentifier(id)
 	}
 	return quoted
 }
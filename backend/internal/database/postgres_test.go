@@ -0,0 +1,32 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Muneer320/RhinoBox/internal/jsonquery"
+)
+
+func TestPostgresSQLQuotesFieldNames(t *testing.T) {
+	const field = `a" text); DROP TABLE x; --`
+	const quoted = `"a"" text); DROP TABLE x; --"`
+
+	create := createTableSQL(`ev"ents`, map[string]string{field: "TEXT", "id": "BIGINT"})
+	if want := `CREATE TABLE IF NOT EXISTS "ev""ents" (` + quoted + ` TEXT, "id" BIGINT)`; create != want {
+		t.Fatalf("createTableSQL = %s\nwant %s", create, want)
+	}
+	if got, want := addColumnSQL("events", field, "TEXT"), `ALTER TABLE "events" ADD COLUMN IF NOT EXISTS `+quoted+` TEXT`; got != want {
+		t.Fatalf("addColumnSQL = %s\nwant %s", got, want)
+	}
+	if got, want := insertSQL("events", []string{field, "id"}, 2), `INSERT INTO "events" (`+quoted+`, "id") VALUES ($1, $2), ($3, $4)`; got != want {
+		t.Fatalf("insertSQL = %s\nwant %s", got, want)
+	}
+
+	query, _, err := buildSQLQuery(`ev"ents`, jsonquery.Query{})
+	if err != nil {
+		t.Fatalf("buildSQLQuery: %v", err)
+	}
+	if !strings.Contains(query, `FROM "ev""ents" t`) {
+		t.Fatalf("table not quoted: %s", query)
+	}
+}
@@ -0,0 +1,306 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/jsonquery"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// QueryJSON runs q against table and returns each matching row as a JSON
+// object. Filters and sort keys address columns, or keys inside JSONB
+// columns with dotted paths. Results follow jsonquery's ordering: values
+// grouped by kind (numbers, strings, booleans, others), missing values last,
+// ties in insertion order.
+func (db *PostgresDB) QueryJSON(ctx context.Context, table string, q jsonquery.Query) ([]map[string]any, error) {
+	query, args, err := buildSQLQuery(table, q)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	docs := make([]map[string]any, 0)
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, fmt.Errorf("scan %s: %w", table, err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var doc map[string]any
+		if err := dec.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode %s row: %w", table, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// sqlKindRank mirrors jsonquery's kind ordering; 4 marks missing or null.
+const sqlKindRank = `CASE jsonb_typeof(coalesce(%[1]s, 'null'::jsonb)) WHEN 'number' THEN 0 WHEN 'string' THEN 1 WHEN 'boolean' THEN 2 WHEN 'null' THEN 4 ELSE 3 END`
+
+// buildSQLQuery translates q into a SELECT over to_jsonb of each row. Field
+// paths and values are always bound as parameters.
+func buildSQLQuery(table string, q jsonquery.Query) (string, []any, error) {
+	var args []any
+	bind := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	value := func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return bind(string(data)) + "::text::jsonb", nil
+	}
+
+	var where []string
+	for _, f := range q.Filters {
+		path := "doc #> " + bind(jsonquery.Path(f.Field)) + "::text[]"
+		orNull := "coalesce(" + path + ", 'null'::jsonb)"
+		var clause string
+		switch f.Op {
+		case jsonquery.OpExists:
+			if f.Value.(bool) {
+				clause = orNull + " <> 'null'::jsonb"
+			} else {
+				clause = orNull + " = 'null'::jsonb"
+			}
+		case jsonquery.OpContains:
+			clause = fmt.Sprintf("jsonb_typeof(%s) = 'string' AND strpos(lower(%s), lower(%s)) > 0",
+				path, "doc #>> "+bind(jsonquery.Path(f.Field))+"::text[]", bind(f.Value.(string)))
+		case jsonquery.OpIn:
+			encoded := make([]string, 0, len(f.Value.([]any)))
+			for _, v := range f.Value.([]any) {
+				data, err := json.Marshal(v)
+				if err != nil {
+					return "", nil, err
+				}
+				encoded = append(encoded, string(data))
+			}
+			clause = fmt.Sprintf("%s = ANY(%s::text[]::jsonb[])", orNull, bind(encoded))
+		case jsonquery.OpEq, jsonquery.OpNe:
+			v, err := value(f.Value)
+			if err != nil {
+				return "", nil, err
+			}
+			if f.Op == jsonquery.OpEq {
+				clause = orNull + " = " + v
+			} else {
+				clause = orNull + " <> " + v
+			}
+		default:
+			v, err := value(f.Value)
+			if err != nil {
+				return "", nil, err
+			}
+			// Range comparisons only hold between values of the same JSON type.
+			clause = fmt.Sprintf("jsonb_typeof(%s) = jsonb_typeof(%s) AND %s %s %s", path, v, path, sqlOperators[f.Op], v)
+		}
+		where = append(where, "("+clause+")")
+	}
+
+	var order []string
+	for _, key := range q.Sort {
+		path := "doc #> " + bind(jsonquery.Path(key.Field)) + "::text[]"
+		rank := fmt.Sprintf(sqlKindRank, path)
+		dir := "ASC"
+		if key.Desc {
+			dir = "DESC"
+		}
+		order = append(order, "("+rank+") = 4", rank+" "+dir, path+" "+dir)
+	}
+	order = append(order, "pos")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT doc FROM (SELECT to_jsonb(t) AS doc, t.ctid AS pos FROM %s t) q", quoteIdentifier(table))
+	if len(where) > 0 {
+		b.WriteString(" WHERE " + strings.Join(where, " AND "))
+	}
+	b.WriteString(" ORDER BY " + strings.Join(order, ", "))
+	if q.Limit > 0 {
+		b.WriteString(" LIMIT " + bind(q.Limit))
+	}
+	if q.Offset > 0 {
+		b.WriteString(" OFFSET " + bind(q.Offset))
+	}
+	return b.String(), args, nil
+}
+
+var sqlOperators = map[jsonquery.Op]string{
+	jsonquery.OpGt:  ">",
+	jsonquery.OpGte: ">=",
+	jsonquery.OpLt:  "<",
+	jsonquery.OpLte: "<=",
+}
+
+// CollectionExists reports whether collection exists in database.
+func (db *MongoDB) CollectionExists(ctx context.Context, database, collection string) (bool, error) {
+	names, err := db.client.Database(database).ListCollectionNames(ctx, bson.D{{Key: "name", Value: collection}})
+	if err != nil {
+		return false, fmt.Errorf("list collections: %w", err)
+	}
+	return len(names) > 0, nil
+}
+
+// FindJSON runs q against a collection with the same semantics as
+// PostgresDB.QueryJSON. The _id field is not returned.
+func (db *MongoDB) FindJSON(ctx context.Context, database, collection string, q jsonquery.Query) ([]map[string]any, error) {
+	coll := db.client.Database(database).Collection(collection)
+	cursor, err := coll.Aggregate(ctx, buildMongoPipeline(q))
+	if err != nil {
+		return nil, fmt.Errorf("find in %s.%s: %w", database, collection, err)
+	}
+	defer cursor.Close(ctx)
+
+	docs := make([]map[string]any, 0)
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("decode %s.%s: %w", database, collection, err)
+		}
+		docs = append(docs, fromBSON(doc).(map[string]any))
+	}
+	return docs, cursor.Err()
+}
+
+// buildMongoPipeline translates q into an aggregation. Sorting goes through
+// computed rank fields so missing values sort last and mixed kinds order
+// like the other stores.
+func buildMongoPipeline(q jsonquery.Query) mongo.Pipeline {
+	pipeline := mongo.Pipeline{}
+	if len(q.Filters) > 0 {
+		clauses := make(bson.A, 0, len(q.Filters))
+		for _, f := range q.Filters {
+			clauses = append(clauses, mongoClause(f))
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.D{{Key: "$and", Value: clauses}}}})
+	}
+
+	sortSpec := bson.D{}
+	ranks := bson.D{}
+	hidden := bson.D{{Key: "_id", Value: 0}}
+	for i, key := range q.Sort {
+		dir := 1
+		if key.Desc {
+			dir = -1
+		}
+		rank := fmt.Sprintf("_rank%d", i)
+		missing := fmt.Sprintf("_missing%d", i)
+		// $addFields cannot reference fields it adds, so missing is
+		// derived from the field itself rather than from rank.
+		ranks = append(ranks,
+			bson.E{Key: rank, Value: mongoKindRank("$" + key.Field)},
+			bson.E{Key: missing, Value: bson.D{{Key: "$in", Value: bson.A{bson.D{{Key: "$type", Value: "$" + key.Field}}, bson.A{"missing", "null"}}}}},
+		)
+		sortSpec = append(sortSpec,
+			bson.E{Key: missing, Value: 1},
+			bson.E{Key: rank, Value: dir},
+			bson.E{Key: key.Field, Value: dir},
+		)
+		hidden = append(hidden, bson.E{Key: rank, Value: 0}, bson.E{Key: missing, Value: 0})
+	}
+	sortSpec = append(sortSpec, bson.E{Key: "_id", Value: 1})
+
+	if len(ranks) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: ranks}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: sortSpec}})
+	if q.Offset > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: int64(q.Offset)}})
+	}
+	if q.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: int64(q.Limit)}})
+	}
+	return append(pipeline, bson.D{{Key: "$project", Value: hidden}})
+}
+
+func mongoKindRank(field string) bson.D {
+	typeIn := func(types ...string) bson.D {
+		return bson.D{{Key: "$in", Value: bson.A{bson.D{{Key: "$type", Value: field}}, types}}}
+	}
+	return bson.D{{Key: "$switch", Value: bson.D{
+		{Key: "branches", Value: bson.A{
+			bson.D{{Key: "case", Value: typeIn("double", "int", "long", "decimal")}, {Key: "then", Value: 0}},
+			bson.D{{Key: "case", Value: typeIn("string")}, {Key: "then", Value: 1}},
+			bson.D{{Key: "case", Value: typeIn("bool")}, {Key: "then", Value: 2}},
+			bson.D{{Key: "case", Value: typeIn("missing", "null")}, {Key: "then", Value: 4}},
+		}},
+		{Key: "default", Value: 3},
+	}}}
+}
+
+func mongoClause(f jsonquery.Filter) bson.D {
+	var cond any
+	switch f.Op {
+	case jsonquery.OpEq:
+		cond = f.Value
+	case jsonquery.OpNe:
+		cond = bson.D{{Key: "$ne", Value: f.Value}}
+	case jsonquery.OpGt:
+		cond = bson.D{{Key: "$gt", Value: f.Value}}
+	case jsonquery.OpGte:
+		cond = bson.D{{Key: "$gte", Value: f.Value}}
+	case jsonquery.OpLt:
+		cond = bson.D{{Key: "$lt", Value: f.Value}}
+	case jsonquery.OpLte:
+		cond = bson.D{{Key: "$lte", Value: f.Value}}
+	case jsonquery.OpIn:
+		cond = bson.D{{Key: "$in", Value: bson.A(f.Value.([]any))}}
+	case jsonquery.OpContains:
+		cond = primitive.Regex{Pattern: regexp.QuoteMeta(f.Value.(string)), Options: "i"}
+	case jsonquery.OpExists:
+		// {field: null} matches missing and null values alike.
+		if f.Value.(bool) {
+			cond = bson.D{{Key: "$ne", Value: nil}}
+		} else {
+			cond = nil
+		}
+	}
+	return bson.D{{Key: f.Field, Value: cond}}
+}
+
+// fromBSON converts driver types to plain JSON-encodable values.
+func fromBSON(v any) any {
+	switch val := v.(type) {
+	case bson.M:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[k] = fromBSON(item)
+		}
+		return out
+	case bson.D:
+		out := make(map[string]any, len(val))
+		for _, e := range val {
+			out[e.Key] = fromBSON(e.Value)
+		}
+		return out
+	case bson.A:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = fromBSON(item)
+		}
+		return out
+	case primitive.ObjectID:
+		return val.Hex()
+	case primitive.DateTime:
+		return val.Time().UTC().Format(time.RFC3339Nano)
+	case primitive.Decimal128:
+		return val.String()
+	default:
+		return v
+	}
+}
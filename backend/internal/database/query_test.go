@@ -0,0 +1,92 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Muneer320/RhinoBox/internal/jsonquery"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBuildSQLQuery(t *testing.T) {
+	q := jsonquery.Query{
+		Filters: []jsonquery.Filter{
+			{Field: "amount", Op: jsonquery.OpGte, Value: int64(10)},
+			{Field: "user.name", Op: jsonquery.OpEq, Value: "Robert'); DROP TABLE orders;--"},
+			{Field: "tags", Op: jsonquery.OpIn, Value: []any{"a", int64(2)}},
+		},
+		Sort:   []jsonquery.SortKey{{Field: "amount", Desc: true}},
+		Offset: 20,
+		Limit:  11,
+	}
+	query, args, err := buildSQLQuery("orders", q)
+	if err != nil {
+		t.Fatalf("buildSQLQuery: %v", err)
+	}
+
+	for _, want := range []string{
+		`FROM "orders" t`,
+		`jsonb_typeof(doc #> $1::text[]) = jsonb_typeof($2::text::jsonb) AND doc #> $1::text[] >= $2::text::jsonb`,
+		`coalesce(doc #> $3::text[], 'null'::jsonb) = $4::text::jsonb`,
+		`= ANY($6::text[]::jsonb[])`,
+		`doc #> $7::text[] DESC, pos LIMIT $8 OFFSET $9`,
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+	if strings.Contains(query, "DROP TABLE") {
+		t.Fatalf("filter value was inlined into SQL: %s", query)
+	}
+
+	wantArgs := []any{
+		[]string{"amount"}, "10",
+		[]string{"user", "name"}, `"Robert'); DROP TABLE orders;--"`,
+		[]string{"tags"}, []string{`"a"`, "2"},
+		[]string{"amount"}, 11, 20,
+	}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Fatalf("args = %#v\nwant %#v", args, wantArgs)
+	}
+}
+
+func TestBuildMongoPipeline(t *testing.T) {
+	q := jsonquery.Query{
+		Filters: []jsonquery.Filter{
+			{Field: "status", Op: jsonquery.OpNe, Value: "paid"},
+			{Field: "deleted", Op: jsonquery.OpExists, Value: false},
+		},
+		Sort:  []jsonquery.SortKey{{Field: "amount", Desc: true}},
+		Limit: 5,
+	}
+	pipeline := buildMongoPipeline(q)
+
+	stages := make([]string, len(pipeline))
+	for i, stage := range pipeline {
+		stages[i] = stage[0].Key
+	}
+	if want := []string{"$match", "$addFields", "$sort", "$limit", "$project"}; !reflect.DeepEqual(stages, want) {
+		t.Fatalf("stages = %v, want %v", stages, want)
+	}
+
+	match := pipeline[0][0].Value.(bson.D)[0].Value.(bson.A)
+	if got := match[0].(bson.D)[0]; got.Key != "status" || !reflect.DeepEqual(got.Value, bson.D{{Key: "$ne", Value: "paid"}}) {
+		t.Fatalf("status clause = %#v", got)
+	}
+	if got := match[1].(bson.D)[0]; got.Key != "deleted" || got.Value != nil {
+		t.Fatalf("deleted clause = %#v", got)
+	}
+
+	sortSpec := pipeline[2][0].Value.(bson.D)
+	keys := make([]string, len(sortSpec))
+	for i, e := range sortSpec {
+		keys[i] = e.Key
+	}
+	if want := []string{"_missing0", "_rank0", "amount", "_id"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("sort keys = %v, want %v", keys, want)
+	}
+	if sortSpec[2].Value != -1 {
+		t.Fatalf("expected descending amount, got %v", sortSpec[2].Value)
+	}
+}
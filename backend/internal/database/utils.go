@@ -1,5 +1,11 @@
 package database
 
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
 // min returns the minimum of two integers
 func min(a, b int) int {
 	if a < b {
@@ -7,3 +13,54 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+// sortedKeys returns the keys of m in lexical order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// batchColumns returns the union of fields across docs, sorted
+func batchColumns(docs []map[string]any) []string {
+	seen := make(map[string]struct{})
+	for _, doc := range docs {
+		for col := range doc {
+			seen[col] = struct{}{}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// normalizeValue converts json.Number (from decoders using UseNumber) into
+// int64 or float64, recursing into nested objects and arrays. Drivers would
+// otherwise encode it as a string.
+func normalizeValue(v any) any {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(val.String(), 10, 64); err == nil {
+			return i
+		}
+		if f, err := val.Float64(); err == nil {
+			return f
+		}
+		return val.String()
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[k] = normalizeValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = normalizeValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
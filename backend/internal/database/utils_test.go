@@ -0,0 +1,38 @@
+package database
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestBatchColumnsUnion(t *testing.T) {
+	docs := []map[string]any{
+		{"b": 1, "a": 2},
+		{"c": 3},
+	}
+	got := batchColumns(docs)
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("batchColumns = %v, want %v", got, want)
+	}
+}
+
+func TestNormalizeValue(t *testing.T) {
+	in := map[string]any{
+		"count": json.Number("42"),
+		"price": json.Number("9.5"),
+		"tags":  []any{json.Number("1"), "x"},
+		"meta":  map[string]any{"n": json.Number("-3")},
+	}
+	got := normalizeValue(in)
+	want := map[string]any{
+		"count": int64(42),
+		"price": 9.5,
+		"tags":  []any{int64(1), "x"},
+		"meta":  map[string]any{"n": int64(-3)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("normalizeValue = %#v, want %#v", got, want)
+	}
+}
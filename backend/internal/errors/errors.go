@@ -19,6 +19,9 @@ const (
 	ErrorCodeRequestTooLarge     ErrorCode = "REQUEST_TOO_LARGE"
 	ErrorCodeRangeNotSatisfiable ErrorCode = "RANGE_NOT_SATISFIABLE"
 	ErrorCodeTimeout             ErrorCode = "TIMEOUT"
+	ErrorCodeQuotaExceeded       ErrorCode = "QUOTA_EXCEEDED"
+	ErrorCodeUnsupportedMedia    ErrorCode = "UNSUPPORTED_MEDIA_TYPE"
+	ErrorCodeGone                ErrorCode = "GONE"
 
 	// Server errors (5xx)
 	ErrorCodeInternalServerError ErrorCode = "INTERNAL_SERVER_ERROR"
@@ -125,3 +128,11 @@ func Timeout(message string) *APIError {
 	return NewAPIError(ErrorCodeTimeout, message)
 }
 
+
+func Unauthorized(message string) *APIError {
+	return NewAPIError(ErrorCodeUnauthorized, message)
+}
+
+func Forbidden(message string) *APIError {
+	return NewAPIError(ErrorCodeForbidden, message)
+}
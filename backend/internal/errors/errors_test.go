@@ -83,6 +83,8 @@ func TestErrorConstructors(t *testing.T) {
 		{"Conflict", Conflict, ErrorCodeConflict},
 		{"ValidationFailed", ValidationFailed, ErrorCodeValidationFailed},
 		{"Timeout", Timeout, ErrorCodeTimeout},
+		{"Unauthorized", Unauthorized, ErrorCodeUnauthorized},
+		{"Forbidden", Forbidden, ErrorCodeForbidden},
 	}
 
 	for _, tt := range tests {
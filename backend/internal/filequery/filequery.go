@@ -0,0 +1,695 @@
+// Package filequery parses the query language accepted by the q parameter of
+// /files/search, for example:
+//
+//	category:images AND size>10MB AND (ext:png OR ext:jpg) meta.project:apollo uploaded:2026-01..2026-03
+//
+// Terms are field:value comparisons or bare words, which match the file
+// name. Terms next to each other are ANDed; AND, OR, NOT, a leading - and
+// parentheses combine them. The storage package evaluates the parsed
+// expression against file metadata.
+package filequery
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Op is the comparison of a term.
+type Op string
+
+const (
+	// OpMatch is field:value. Text fields match a substring, or a glob when
+	// the value contains * or ?; numbers and dates match a value, a period
+	// or a low..high range.
+	OpMatch Op = ":"
+	// OpEq is field=value, an exact match.
+	OpEq  Op = "="
+	OpGt  Op = ">"
+	OpGte Op = ">="
+	OpLt  Op = "<"
+	OpLte Op = "<="
+)
+
+// MetaPrefix introduces a term on a user metadata key, as in meta.project:apollo.
+const MetaPrefix = "meta."
+
+// maxDepth bounds the nesting of parentheses and negations.
+const maxDepth = 64
+
+// SyntaxError reports why a query could not be parsed and where.
+type SyntaxError struct {
+	// Pos is the 1-based byte column of the offending input.
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// Expr is a parsed query: an *And, *Or, *Not or *Term.
+type Expr interface {
+	// String renders the expression in canonical query syntax.
+	String() string
+}
+
+// And matches files that match every expression.
+type And struct{ Exprs []Expr }
+
+// Or matches files that match any expression.
+type Or struct{ Exprs []Expr }
+
+// Not matches files that do not match Expr.
+type Not struct{ Expr Expr }
+
+// Term compares one field of a file with a value.
+type Term struct {
+	// Field is the canonical field name, MetaPrefix+key for user metadata,
+	// or "has" for presence tests.
+	Field string
+	Op    Op
+	// Value is the value as written, without quotes.
+	Value string
+	// Range holds the matching interval of numeric and date fields, and of
+	// metadata values that parse as a number or date. Nil otherwise.
+	Range *Range
+	// Pos is the 1-based column of the term in the query.
+	Pos int
+}
+
+// Range is an interval of numbers. Dates are expressed as Unix seconds and
+// set Time.
+type Range struct {
+	Min, Max                   float64
+	HasMin, HasMax             bool
+	MinExclusive, MaxExclusive bool
+	Time                       bool
+}
+
+// Contains reports whether v lies in the range.
+func (r *Range) Contains(v float64) bool {
+	if r.HasMin && (v < r.Min || r.MinExclusive && v == r.Min) {
+		return false
+	}
+	if r.HasMax && (v > r.Max || r.MaxExclusive && v == r.Max) {
+		return false
+	}
+	return true
+}
+
+// ContainsTime reports whether t lies in a date range.
+func (r *Range) ContainsTime(t time.Time) bool {
+	return r.Contains(TimeValue(t))
+}
+
+// TimeValue converts t to the Unix seconds used by date ranges.
+func TimeValue(t time.Time) float64 {
+	return float64(t.Unix()) + float64(t.Nanosecond())/1e9
+}
+
+func (a *And) String() string { return joinExprs(a.Exprs, " AND ", true) }
+func (o *Or) String() string  { return joinExprs(o.Exprs, " OR ", false) }
+
+func (n *Not) String() string {
+	if _, ok := n.Expr.(*Term); ok {
+		return "NOT " + n.Expr.String()
+	}
+	return "NOT (" + n.Expr.String() + ")"
+}
+
+func (t *Term) String() string {
+	return t.Field + string(t.Op) + quoteValue(t.Value)
+}
+
+func joinExprs(exprs []Expr, sep string, groupOr bool) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = e.String()
+		if _, ok := e.(*Or); ok && groupOr {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+func quoteValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n\"()\\") {
+		return v
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
+
+// Walk calls fn for every term of e.
+func Walk(e Expr, fn func(*Term)) {
+	switch e := e.(type) {
+	case *And:
+		for _, sub := range e.Exprs {
+			Walk(sub, fn)
+		}
+	case *Or:
+		for _, sub := range e.Exprs {
+			Walk(sub, fn)
+		}
+	case *Not:
+		Walk(e.Expr, fn)
+	case *Term:
+		fn(e)
+	}
+}
+
+// fieldKind decides which operators and values a field accepts.
+type fieldKind int
+
+const (
+	kindText fieldKind = iota
+	kindSize
+	kindNumber
+	kindDuration
+	kindDate
+	kindMeta
+	kindHas
+)
+
+// fields lists every field by canonical name. Text fields accept : and =.
+var fields = map[string]fieldKind{
+	"name":     kindText,
+	"ext":      kindText,
+	"category": kindText,
+	"type":     kindText,
+	"mime":     kindText,
+	"path":     kindText,
+	"hash":     kindText,
+	"content":  kindText,
+	"camera":   kindText,
+	"artist":   kindText,
+	"album":    kindText,
+	"codec":    kindText,
+	"tag":      kindText,
+	"size":     kindSize,
+	"width":    kindNumber,
+	"height":   kindNumber,
+	"duration": kindDuration,
+	"uploaded": kindDate,
+	"taken":    kindDate,
+	"has":      kindHas,
+}
+
+// aliases maps alternative spellings, including the /files/search
+// parameter names, to canonical fields.
+var aliases = map[string]string{
+	"filename":    "name",
+	"extension":   "ext",
+	"mime_type":   "mime",
+	"mimetype":    "mime",
+	"date":        "uploaded",
+	"uploaded_at": "uploaded",
+	"taken_at":    "taken",
+	"tags":        "tag",
+}
+
+// hasTargets are the values accepted by has: besides meta.<key>.
+var hasTargets = map[string]bool{"gps": true, "extracted": true, "tags": true}
+
+// Parse parses a query. Errors are *SyntaxError.
+func Parse(query string) (Expr, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, &SyntaxError{Pos: 1, Msg: "query is empty"}
+	}
+	expr, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		if tok.kind == tokRParen {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: "unmatched ')'"}
+		}
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %s", tok.describe())}
+	}
+	return expr, nil
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token { return p.tokens[p.next] }
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tokEOF {
+		p.next++
+	}
+	return tok
+}
+
+func (p *parser) parseOr(depth int) (Expr, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	exprs := []Expr{left}
+	for p.peek().kind == tokOr {
+		op := p.advance()
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, p.afterOperator(op, err)
+		}
+		exprs = append(exprs, right)
+	}
+	if len(exprs) == 1 {
+		return left, nil
+	}
+	return &Or{Exprs: exprs}, nil
+}
+
+func (p *parser) parseAnd(depth int) (Expr, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	exprs := []Expr{left}
+	for {
+		tok := p.peek()
+		if tok.kind == tokAnd {
+			p.advance()
+			right, err := p.parseUnary(depth)
+			if err != nil {
+				return nil, p.afterOperator(tok, err)
+			}
+			exprs = append(exprs, right)
+			continue
+		}
+		if tok.kind != tokWord && tok.kind != tokNot && tok.kind != tokLParen {
+			break
+		}
+		// Juxtaposed terms are ANDed.
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, right)
+	}
+	if len(exprs) == 1 {
+		return left, nil
+	}
+	return &And{Exprs: exprs}, nil
+}
+
+// afterOperator rewords a missing-term error to name the operator before it.
+func (p *parser) afterOperator(op token, err error) error {
+	if se, ok := err.(*SyntaxError); ok && se.Msg == msgExpectedTerm {
+		return &SyntaxError{Pos: se.Pos, Msg: fmt.Sprintf("expected a search term after %s", op.text)}
+	}
+	return err
+}
+
+const msgExpectedTerm = "expected a search term"
+
+func (p *parser) parseUnary(depth int) (Expr, error) {
+	if depth > maxDepth {
+		return nil, &SyntaxError{Pos: p.peek().pos, Msg: "query is nested too deeply"}
+	}
+	tok := p.peek()
+	switch tok.kind {
+	case tokNot:
+		p.advance()
+		expr, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, p.afterOperator(tok, err)
+		}
+		return &Not{Expr: expr}, nil
+	case tokLParen:
+		p.advance()
+		if p.peek().kind == tokRParen {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: "empty parentheses"}
+		}
+		expr, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokRParen {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: "missing ')' to close this '('"}
+		}
+		p.advance()
+		return expr, nil
+	case tokWord:
+		p.advance()
+		return parseTerm(tok)
+	case tokEOF:
+		return nil, &SyntaxError{Pos: tok.pos, Msg: msgExpectedTerm}
+	default:
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("%s, found %s", msgExpectedTerm, tok.describe())}
+	}
+}
+
+// parseTerm splits a word into field, operator and value. Words without an
+// operator after a field-like prefix match the file name.
+func parseTerm(tok token) (Expr, error) {
+	field, op, value, valuePos, ok := splitTerm(tok)
+	if !ok {
+		return &Term{Field: "name", Op: OpMatch, Value: tok.value(), Pos: tok.pos}, nil
+	}
+
+	name, kind, err := resolveField(field, tok.pos)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" && !tok.quoted {
+		return nil, &SyntaxError{Pos: valuePos, Msg: fmt.Sprintf("missing value after %s%s", field, op)}
+	}
+
+	negate := op == "!="
+	if negate {
+		op = string(OpEq)
+	}
+	term := &Term{Field: name, Op: Op(op), Value: value, Pos: tok.pos}
+	if err := term.resolveValue(kind, tok.quoted, valuePos); err != nil {
+		return nil, err
+	}
+	if negate {
+		return &Not{Expr: term}, nil
+	}
+	return term, nil
+}
+
+// splitTerm finds the operator of a field term. The field part ends at the
+// first operator character and may not be quoted.
+func splitTerm(tok token) (field, op, value string, valuePos int, ok bool) {
+	text := tok.text
+	i := strings.IndexAny(text, ":=<>!")
+	if i <= 0 || !isFieldName(text[:i]) {
+		return "", "", "", 0, false
+	}
+	switch {
+	case strings.HasPrefix(text[i:], ">="), strings.HasPrefix(text[i:], "<="), strings.HasPrefix(text[i:], "!="):
+		op = text[i : i+2]
+	case text[i] == '!':
+		return "", "", "", 0, false
+	default:
+		op = text[i : i+1]
+	}
+	value = text[i+len(op):]
+	if tok.quoted {
+		value += tok.quotedText
+	}
+	return text[:i], op, value, tok.pos + i + len(op), true
+}
+
+func isFieldName(s string) bool {
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case i > 0 && (r >= '0' && r <= '9' || r == '.' || r == '-'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// resolveField returns the canonical name and kind of a field as written.
+func resolveField(field string, pos int) (string, fieldKind, error) {
+	lower := strings.ToLower(field)
+	for _, prefix := range []string{MetaPrefix, "metadata."} {
+		if strings.HasPrefix(lower, prefix) {
+			key := field[len(prefix):]
+			if key == "" {
+				return "", 0, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("missing metadata key after %q", field)}
+			}
+			return MetaPrefix + key, kindMeta, nil
+		}
+	}
+	if alias, ok := aliases[lower]; ok {
+		lower = alias
+	}
+	if kind, ok := fields[lower]; ok {
+		return lower, kind, nil
+	}
+
+	msg := fmt.Sprintf("unknown field %q", field)
+	if suggestion := closestField(lower); suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", suggestion)
+	} else {
+		msg += "; use meta.<key> for custom metadata or quote the value to search names"
+	}
+	return "", 0, &SyntaxError{Pos: pos, Msg: msg}
+}
+
+// closestField suggests a known field within two edits of name.
+func closestField(name string) string {
+	names := make([]string, 0, len(fields)+len(aliases))
+	for f := range fields {
+		names = append(names, f)
+	}
+	for a := range aliases {
+		names = append(names, a)
+	}
+	sort.Strings(names)
+
+	best, bestDist := "", 3
+	for _, candidate := range names {
+		if d := editDistance(name, candidate); d < bestDist {
+			best, bestDist = candidate, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// resolveValue validates the operator and parses the value of t for a field of kind.
+func (t *Term) resolveValue(kind fieldKind, quoted bool, pos int) error {
+	switch kind {
+	case kindText:
+		if t.Op != OpMatch && t.Op != OpEq {
+			return &SyntaxError{Pos: t.Pos, Msg: fmt.Sprintf("%s only supports : and = (and != to exclude)", t.Field)}
+		}
+		return nil
+	case kindHas:
+		if t.Op != OpMatch {
+			return &SyntaxError{Pos: t.Pos, Msg: "has only supports :, as in has:gps"}
+		}
+		lower := strings.ToLower(t.Value)
+		switch {
+		case hasTargets[lower]:
+			t.Value = lower
+		case strings.HasPrefix(lower, MetaPrefix) && len(t.Value) > len(MetaPrefix):
+			t.Value = MetaPrefix + t.Value[len(MetaPrefix):]
+		default:
+			return &SyntaxError{Pos: pos, Msg: fmt.Sprintf("unknown has:%s; expected has:gps, has:extracted, has:tags or has:meta.<key>", t.Value)}
+		}
+		return nil
+	case kindMeta:
+		// Values that read as a number or a date also compare as one;
+		// anything else compares as text.
+		if !quoted {
+			if r, err := parseRange(t.Op, t.Value, parseNumber, pos); err == nil {
+				t.Range = r
+			} else if r, err := parseRange(t.Op, t.Value, parseDate, pos); err == nil {
+				t.Range = r
+			}
+		}
+		return nil
+	}
+
+	parse := map[fieldKind]spanParser{
+		kindSize:     parseSize,
+		kindNumber:   parseNumber,
+		kindDuration: parseDuration,
+		kindDate:     parseDate,
+	}[kind]
+	r, err := parseRange(t.Op, t.Value, parse, pos)
+	if err != nil {
+		return err
+	}
+	t.Range = r
+	return nil
+}
+
+// span is the interval one value denotes: a single number, or a period
+// such as a whole month for 2026-03.
+type span struct {
+	lo, hi float64
+	// period reports that hi is an exclusive end.
+	period bool
+	time   bool
+}
+
+type spanParser func(value string, pos int) (span, error)
+
+// parseRange turns op and value into the interval a term matches. With :
+// a value may be a low..high range with either end left open.
+func parseRange(op Op, value string, parse spanParser, pos int) (*Range, error) {
+	if op == OpMatch {
+		if low, high, ok := strings.Cut(value, ".."); ok {
+			return parseBounds(low, high, parse, pos)
+		}
+	}
+	s, err := parse(value, pos)
+	if err != nil {
+		return nil, err
+	}
+	r := &Range{Time: s.time}
+	switch op {
+	case OpMatch, OpEq:
+		r.setMin(s.lo, false)
+		r.setMax(s.hi, s.period)
+	case OpGt:
+		r.setMin(s.hi, !s.period)
+	case OpGte:
+		r.setMin(s.lo, false)
+	case OpLt:
+		r.setMax(s.lo, true)
+	case OpLte:
+		r.setMax(s.hi, s.period)
+	}
+	return r, nil
+}
+
+func parseBounds(low, high string, parse spanParser, pos int) (*Range, error) {
+	if low == "" && high == "" {
+		return nil, &SyntaxError{Pos: pos, Msg: "range needs at least one end, as in 1MB..10MB"}
+	}
+	r := &Range{}
+	var lo, hi span
+	var err error
+	if low != "" {
+		if lo, err = parse(low, pos); err != nil {
+			return nil, err
+		}
+		r.Time = lo.time
+		r.setMin(lo.lo, false)
+	}
+	if high != "" {
+		highPos := pos + len(low) + 2
+		if hi, err = parse(high, highPos); err != nil {
+			return nil, err
+		}
+		r.Time = hi.time
+		r.setMax(hi.hi, hi.period)
+	}
+	if r.HasMin && r.HasMax && (r.Min > r.Max || r.Min == r.Max && r.MaxExclusive) {
+		return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("empty range: %s is after %s", low, high)}
+	}
+	return r, nil
+}
+
+func (r *Range) setMin(v float64, exclusive bool) {
+	r.Min, r.HasMin, r.MinExclusive = v, true, exclusive
+}
+
+func (r *Range) setMax(v float64, exclusive bool) {
+	r.Max, r.HasMax, r.MaxExclusive = v, true, exclusive
+}
+
+func parseNumber(value string, pos int) (span, error) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return span{}, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("invalid number %q", value)}
+	}
+	return span{lo: n, hi: n}, nil
+}
+
+// sizeUnits are binary multiples, matching how sizes are displayed.
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40, "tib": 1 << 40,
+}
+
+func parseSize(value string, pos int) (span, error) {
+	i := strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(value)
+	}
+	n, err := strconv.ParseFloat(value[:i], 64)
+	unit, ok := sizeUnits[strings.ToLower(value[i:])]
+	if err != nil || !ok {
+		return span{}, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("invalid size %q: expected a number with an optional unit (B, KB, MB, GB, TB)", value)}
+	}
+	n = math.Round(n * unit)
+	return span{lo: n, hi: n}, nil
+}
+
+// parseDuration accepts seconds or a Go duration such as 1m30s.
+func parseDuration(value string, pos int) (span, error) {
+	if n, err := strconv.ParseFloat(value, 64); err == nil && n >= 0 {
+		return span{lo: n, hi: n}, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return span{}, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("invalid duration %q: expected seconds or a duration such as 1m30s", value)}
+	}
+	return span{lo: d.Seconds(), hi: d.Seconds()}, nil
+}
+
+// dateLayouts are the accepted date precisions, each with the period it
+// covers. Dates without a zone are UTC.
+var dateLayouts = []struct {
+	layout string
+	next   func(time.Time) time.Time
+}{
+	{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+	{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+}
+
+// parseDate accepts RFC3339 instants and YYYY, YYYY-MM or YYYY-MM-DD
+// periods, so uploaded:2026-03 covers all of March.
+func parseDate(value string, pos int) (span, error) {
+	if ts, err := time.Parse(time.RFC3339, value); err == nil {
+		v := TimeValue(ts)
+		return span{lo: v, hi: v, time: true}, nil
+	}
+	for _, l := range dateLayouts {
+		if len(value) != len(l.layout) {
+			continue
+		}
+		if start, err := time.Parse(l.layout, value); err == nil {
+			return span{lo: TimeValue(start), hi: TimeValue(l.next(start)), period: true, time: true}, nil
+		}
+	}
+	return span{}, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("invalid date %q: expected YYYY, YYYY-MM, YYYY-MM-DD or RFC3339", value)}
+}
+
+// ParseMetaValue reads a metadata value the way a term's Range was built:
+// as a date for date ranges, as a number otherwise.
+func ParseMetaValue(r *Range, value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	if r.Time {
+		s, err := parseDate(value, 0)
+		return s.lo, err == nil
+	}
+	s, err := parseNumber(value, 0)
+	return s.lo, err == nil
+}
@@ -0,0 +1,124 @@
+package filequery
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCanonical(t *testing.T) {
+	cases := map[string]string{
+		`category:images AND size>10MB AND (ext:png OR ext:jpg) AND meta.project:apollo uploaded:2026-01..2026-03`: `category:images AND size>10MB AND (ext:png OR ext:jpg) AND meta.project:apollo AND uploaded:2026-01..2026-03`,
+		`a b OR c`:       `name:a AND name:b OR name:c`,
+		`a AND (b OR c)`: `name:a AND (name:b OR name:c)`,
+		`-ext:png not (type:video or type:audio)`: `NOT ext:png AND NOT (type:video OR type:audio)`,
+		`meta.owner!=bob`:                         `NOT meta.owner=bob`,
+		`Extension:PDF mime_type=image/png`:       `ext:PDF AND mime=image/png`,
+		`"annual report" name:"Q1 (draft)"`:       `name:"annual report" AND name:"Q1 (draft)"`,
+		`has:GPS has:meta.Project`:                `has:gps AND has:meta.Project`,
+		`"AND" size:..1KB`:                        `name:AND AND size:..1KB`,
+		`tags:project/apollo has:Tags`:            `tag:project/apollo AND has:tags`,
+	}
+	for query, want := range cases {
+		expr, err := Parse(query)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", query, err)
+			continue
+		}
+		if got := expr.String(); got != want {
+			t.Errorf("Parse(%q) = %s\nwant %s", query, got, want)
+		}
+	}
+}
+
+func TestParseRanges(t *testing.T) {
+	term := func(query string) *Term {
+		t.Helper()
+		expr, err := Parse(query)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", query, err)
+		}
+		return expr.(*Term)
+	}
+	day := func(s string) float64 {
+		ts, _ := time.Parse("2006-01-02", s)
+		return TimeValue(ts)
+	}
+
+	r := term("size>10MB").Range
+	if !r.HasMin || r.Min != 10<<20 || !r.MinExclusive || r.HasMax {
+		t.Fatalf("size>10MB: %+v", r)
+	}
+	if r := term("size:1.5k").Range; !r.Contains(1536) || r.Contains(1537) {
+		t.Fatalf("size:1.5k: %+v", r)
+	}
+
+	// A month covers every instant in it, whichever operator is used.
+	months := term("uploaded:2026-01..2026-03").Range
+	if !months.Time || !months.Contains(day("2026-01-01")) || !months.Contains(day("2026-03-31")+3600) || months.Contains(day("2026-04-01")) {
+		t.Fatalf("month range: %+v", months)
+	}
+	if r := term("uploaded>2026-01").Range; r.Contains(day("2026-01-31")) || !r.Contains(day("2026-02-01")) {
+		t.Fatalf("uploaded>2026-01: %+v", r)
+	}
+	if r := term("uploaded<=2026").Range; !r.Contains(day("2026-12-31")) || r.Contains(day("2027-01-01")) {
+		t.Fatalf("uploaded<=2026: %+v", r)
+	}
+	if r := term("duration:1m..2m30s").Range; r.Min != 60 || r.Max != 150 {
+		t.Fatalf("duration range: %+v", r)
+	}
+
+	// Metadata values compare as numbers or dates when they read as one.
+	if r := term("meta.rev>=3").Range; r == nil || r.Time || r.Min != 3 {
+		t.Fatalf("meta.rev>=3: %+v", r)
+	}
+	if r := term("meta.due:2026-05").Range; r == nil || !r.Time {
+		t.Fatalf("meta.due:2026-05: %+v", r)
+	}
+	if r := term(`meta.rev:"3"`).Range; r != nil {
+		t.Fatalf("quoted metadata values compare as text: %+v", r)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{"", 1, "query is empty"},
+		{"ext:png AND", 12, "expected a search term after AND"},
+		{"(ext:png OR ext:jpg", 1, "missing ')'"},
+		{"ext:png)", 8, "unmatched ')'"},
+		{"()", 1, "empty parentheses"},
+		{"OR ext:png", 1, "expected a search term, found OR"},
+		{"sise>10MB", 1, `unknown field "sise" (did you mean "size"?)`},
+		{"colour:red", 1, "use meta.<key>"},
+		{"size>10XB", 6, `invalid size "10XB"`},
+		{"uploaded:2026-13", 10, `invalid date "2026-13"`},
+		{"uploaded:2026-03..2026-01", 10, "empty range"},
+		{"size:1MB..oops", 11, `invalid size "oops"`},
+		{"name>report", 1, "name only supports : and ="},
+		{"ext:", 5, "missing value after ext:"},
+		{`name:"open`, 6, "unterminated quoted string"},
+		{"has:colour", 5, "unknown has:colour"},
+		{"NOT", 4, "expected a search term after NOT"},
+	}
+	for _, tc := range cases {
+		_, err := Parse(tc.query)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q): expected a SyntaxError, got %v", tc.query, err)
+			continue
+		}
+		if syntaxErr.Pos != tc.pos || !strings.Contains(syntaxErr.Msg, tc.msg) {
+			t.Errorf("Parse(%q) = %q at %d, want %q at %d", tc.query, syntaxErr.Msg, syntaxErr.Pos, tc.msg, tc.pos)
+		}
+	}
+
+	deep := strings.Repeat("(", maxDepth+2) + "a" + strings.Repeat(")", maxDepth+2)
+	if _, err := Parse(deep); err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+		t.Fatalf("expected a nesting error, got %v", err)
+	}
+}
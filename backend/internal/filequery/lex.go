@@ -0,0 +1,124 @@
+package filequery
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+// token is a lexical unit. A word's text runs up to the first quote; the
+// quoted remainder, unescaped, is in quotedText.
+type token struct {
+	kind       tokenKind
+	text       string
+	quoted     bool
+	quotedText string
+	pos        int
+}
+
+// value is the word as a bare search term.
+func (t token) value() string {
+	return t.text + t.quotedText
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokLParen, tokRParen:
+		return fmt.Sprintf("'%s'", t.text)
+	case tokWord:
+		return fmt.Sprintf("%q", t.value())
+	}
+	return t.text
+}
+
+// keywords are matched case-insensitively; quote a word to search for it.
+var keywords = map[string]tokenKind{"AND": tokAnd, "OR": tokOr, "NOT": tokNot}
+
+func lex(query string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(query) {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			kind := tokLParen
+			if c == ')' {
+				kind = tokRParen
+			}
+			tokens = append(tokens, token{kind: kind, text: string(c), pos: i + 1})
+			i++
+		case c == '-' && i+1 < len(query) && !isDelimiter(query[i+1]):
+			tokens = append(tokens, token{kind: tokNot, text: "-", pos: i + 1})
+			i++
+		default:
+			tok, next, err := lexWord(query, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i = next
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(query) + 1}), nil
+}
+
+func isDelimiter(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(' || c == ')'
+}
+
+// lexWord reads a word starting at start. A quote ends the unquoted part;
+// the word ends at its closing quote.
+func lexWord(query string, start int) (token, int, error) {
+	tok := token{kind: tokWord, pos: start + 1}
+	i := start
+	for i < len(query) && !isDelimiter(query[i]) && query[i] != '"' {
+		i++
+	}
+	tok.text = query[start:i]
+	if i == len(query) || query[i] != '"' {
+		if kind, ok := keywords[strings.ToUpper(tok.text)]; ok {
+			tok.kind = kind
+		}
+		return tok, i, nil
+	}
+
+	open := i
+	var b strings.Builder
+	for i++; ; i++ {
+		if i >= len(query) {
+			return tok, 0, &SyntaxError{Pos: open + 1, Msg: "unterminated quoted string"}
+		}
+		c := query[i]
+		if c == '\\' && i+1 < len(query) {
+			i++
+			b.WriteByte(query[i])
+			continue
+		}
+		if c == '"' {
+			break
+		}
+		b.WriteByte(c)
+	}
+	i++
+	if i < len(query) && !isDelimiter(query[i]) {
+		return tok, 0, &SyntaxError{Pos: i + 1, Msg: "expected a space or ')' after the closing quote"}
+	}
+	tok.quoted = true
+	tok.quotedText = b.String()
+	return tok, i, nil
+}
@@ -0,0 +1,272 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Lookup returns the value at a dotted field path and whether it is present.
+func Lookup(doc map[string]any, field string) (any, bool) {
+	var current any = doc
+	for _, key := range Path(field) {
+		obj, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = obj[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Match reports whether doc satisfies every filter.
+func Match(doc map[string]any, filters []Filter) bool {
+	for _, f := range filters {
+		if !matchFilter(doc, f) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchFilter(doc map[string]any, f Filter) bool {
+	value, ok := Lookup(doc, f.Field)
+	present := ok && value != nil
+
+	switch f.Op {
+	case OpExists:
+		return present == f.Value.(bool)
+	case OpEq:
+		return equal(value, f.Value)
+	case OpNe:
+		return !equal(value, f.Value)
+	case OpIn:
+		for _, candidate := range f.Value.([]any) {
+			if equal(value, candidate) {
+				return true
+			}
+		}
+		return false
+	case OpContains:
+		s, ok := value.(string)
+		return ok && strings.Contains(strings.ToLower(s), strings.ToLower(f.Value.(string)))
+	}
+
+	// Range operators only compare values of the same kind.
+	cmp, ok := compare(value, f.Value)
+	if !ok {
+		return false
+	}
+	switch f.Op {
+	case OpGt:
+		return cmp > 0
+	case OpGte:
+		return cmp >= 0
+	case OpLt:
+		return cmp < 0
+	case OpLte:
+		return cmp <= 0
+	}
+	return false
+}
+
+// equal treats missing and null alike and compares numbers by value.
+func equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	cmp, ok := compare(a, b)
+	return ok && cmp == 0
+}
+
+// compare orders two scalars of the same kind. It reports false for
+// mismatched kinds, nulls, objects and arrays.
+func compare(a, b any) (int, bool) {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x == y:
+			return 0, true
+		case !x:
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+// number converts the numeric types produced by JSON decoders and drivers.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// kindRank orders values of different kinds when sorting: numbers, strings,
+// booleans, then objects and arrays. Missing and null values sort last in
+// either direction.
+func kindRank(v any) int {
+	if _, ok := number(v); ok {
+		return 0
+	}
+	switch v.(type) {
+	case string:
+		return 1
+	case bool:
+		return 2
+	case nil:
+		return 4
+	}
+	return 3
+}
+
+// Less reports whether a sorts before b under keys.
+func Less(keys []SortKey, a, b map[string]any) bool {
+	for _, key := range keys {
+		x, _ := Lookup(a, key.Field)
+		y, _ := Lookup(b, key.Field)
+		rx, ry := kindRank(x), kindRank(y)
+		if rx == 4 || ry == 4 {
+			if rx != ry {
+				return ry == 4
+			}
+			continue
+		}
+		cmp := rx - ry
+		if cmp == 0 {
+			cmp, _ = compare(x, y)
+		}
+		if cmp == 0 {
+			continue
+		}
+		if key.Desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	}
+	return false
+}
+
+// Sort orders docs by keys, keeping the existing order among ties.
+func Sort(docs []map[string]any, keys []SortKey) {
+	if len(keys) == 0 {
+		return
+	}
+	sort.SliceStable(docs, func(i, j int) bool { return Less(keys, docs[i], docs[j]) })
+}
+
+// Project returns doc restricted to fields, or doc itself when fields is empty.
+func Project(doc map[string]any, fields []string) map[string]any {
+	if len(fields) == 0 {
+		return doc
+	}
+	out := make(map[string]any, len(fields))
+	for _, field := range fields {
+		if v, ok := doc[field]; ok {
+			out[field] = v
+		}
+	}
+	return out
+}
+
+// Page returns the Offset/Limit window of docs, which must already be in
+// query order.
+func Page(docs []map[string]any, q Query) []map[string]any {
+	if q.Offset >= len(docs) {
+		return []map[string]any{}
+	}
+	end := len(docs)
+	if q.Limit > 0 && q.Offset+q.Limit < end {
+		end = q.Offset + q.Limit
+	}
+	return docs[q.Offset:end]
+}
+
+// Collect runs q over the documents produced by scan, which calls its
+// argument for each document in storage order until it returns false.
+// Only matching documents are retained, and without a sort the scan stops
+// as soon as the requested page is complete.
+func Collect(q Query, scan func(yield func(map[string]any) bool) error) ([]map[string]any, error) {
+	keep := q.Offset + q.Limit
+	var matches []map[string]any
+	err := scan(func(doc map[string]any) bool {
+		if !Match(doc, q.Filters) {
+			return true
+		}
+		matches = append(matches, doc)
+		if len(q.Sort) == 0 {
+			return q.Limit <= 0 || len(matches) < keep
+		}
+		// Bound memory on large namespaces: the stable sort keeps earlier
+		// documents first among ties, so truncating preserves the result.
+		if q.Limit > 0 && len(matches) >= 2*keep+64 {
+			Sort(matches, q.Sort)
+			matches = matches[:keep]
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	Sort(matches, q.Sort)
+	return Page(matches, q), nil
+}
+
+// Merge combines the leading rows of several sources, each holding at least
+// the first Offset+Limit matches in query order, into the requested page.
+func Merge(q Query, sources ...[]map[string]any) []map[string]any {
+	var all []map[string]any
+	for _, docs := range sources {
+		all = append(all, docs...)
+	}
+	Sort(all, q.Sort)
+	return Page(all, q)
+}
+
+func sortFilters(filters []Filter) {
+	sort.SliceStable(filters, func(i, j int) bool {
+		if filters[i].Field != filters[j].Field {
+			return filters[i].Field < filters[j].Field
+		}
+		return filters[i].Op < filters[j].Op
+	})
+}
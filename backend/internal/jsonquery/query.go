@@ -0,0 +1,231 @@
+// Package jsonquery describes record queries over ingested JSON namespaces
+// and evaluates them in memory. The database package translates the same
+// queries to SQL and MongoDB so results match whichever store holds a namespace.
+package jsonquery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Op is a filter comparison operator.
+type Op string
+
+const (
+	OpEq       Op = "eq"
+	OpNe       Op = "ne"
+	OpGt       Op = "gt"
+	OpGte      Op = "gte"
+	OpLt       Op = "lt"
+	OpLte      Op = "lte"
+	OpContains Op = "contains"
+	OpIn       Op = "in"
+	OpExists   Op = "exists"
+)
+
+var validOps = map[Op]bool{
+	OpEq: true, OpNe: true, OpGt: true, OpGte: true, OpLt: true, OpLte: true,
+	OpContains: true, OpIn: true, OpExists: true,
+}
+
+const (
+	// DefaultLimit is the page size used when a query sets none.
+	DefaultLimit = 100
+	// MaxLimit caps the page size.
+	MaxLimit = 1000
+)
+
+// ErrInvalidQuery wraps every error returned by Parse.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// fieldPattern matches field paths; dots separate nested object keys.
+var fieldPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_-]*(\.[A-Za-z0-9_][A-Za-z0-9_-]*)*$`)
+
+// jsonNumber matches the JSON number grammar, which rejects leading zeros.
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// reservedParams are query parameters that are not field filters.
+var reservedParams = map[string]bool{"fields": true, "sort": true, "limit": true, "cursor": true}
+
+// Filter compares the value at Field with Value. For OpIn, Value is a
+// []any; for OpExists it is a bool.
+type Filter struct {
+	Field string
+	Op    Op
+	Value any
+}
+
+// SortKey orders results by Field.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// Query selects, orders and pages records. Fields, when set, limits the
+// returned top-level keys.
+type Query struct {
+	Filters []Filter
+	Sort    []SortKey
+	Fields  []string
+	Offset  int
+	Limit   int
+}
+
+// Parse builds a query from URL parameters:
+//
+//	status=paid              equality
+//	amount[gte]=10           eq, ne, gt, gte, lt, lte, contains, in, exists
+//	fields=id,amount         projection
+//	sort=-amount,id          descending with a leading "-"
+//	limit=50&cursor=...      paging
+//
+// Values are typed as JSON literals where possible (10, 2.5, true, null);
+// wrap a value in double quotes to compare it as a string.
+func Parse(values url.Values) (Query, error) {
+	q := Query{Limit: DefaultLimit}
+
+	for key, vals := range values {
+		if reservedParams[key] {
+			continue
+		}
+		field, op := key, OpEq
+		if i := strings.IndexByte(key, '['); i >= 0 && strings.HasSuffix(key, "]") {
+			field, op = key[:i], Op(key[i+1:len(key)-1])
+		}
+		if !fieldPattern.MatchString(field) {
+			return Query{}, fmt.Errorf("%w: invalid field %q", ErrInvalidQuery, field)
+		}
+		if !validOps[op] {
+			return Query{}, fmt.Errorf("%w: unknown operator %q", ErrInvalidQuery, op)
+		}
+		for _, raw := range vals {
+			filter, err := newFilter(field, op, raw)
+			if err != nil {
+				return Query{}, err
+			}
+			q.Filters = append(q.Filters, filter)
+		}
+	}
+	// Map iteration is random; keep filters stable for callers and tests.
+	sortFilters(q.Filters)
+
+	if raw := values.Get("fields"); raw != "" {
+		for _, field := range strings.Split(raw, ",") {
+			field = strings.TrimSpace(field)
+			if !fieldPattern.MatchString(field) || strings.Contains(field, ".") {
+				return Query{}, fmt.Errorf("%w: invalid projection field %q", ErrInvalidQuery, field)
+			}
+			q.Fields = append(q.Fields, field)
+		}
+	}
+
+	if raw := values.Get("sort"); raw != "" {
+		for _, field := range strings.Split(raw, ",") {
+			field = strings.TrimSpace(field)
+			key := SortKey{Field: strings.TrimPrefix(field, "-"), Desc: strings.HasPrefix(field, "-")}
+			if !fieldPattern.MatchString(key.Field) {
+				return Query{}, fmt.Errorf("%w: invalid sort field %q", ErrInvalidQuery, field)
+			}
+			q.Sort = append(q.Sort, key)
+		}
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return Query{}, fmt.Errorf("%w: limit must be a positive integer", ErrInvalidQuery)
+		}
+		q.Limit = min(limit, MaxLimit)
+	}
+
+	if raw := values.Get("cursor"); raw != "" {
+		offset, err := DecodeCursor(raw)
+		if err != nil {
+			return Query{}, err
+		}
+		q.Offset = offset
+	}
+	return q, nil
+}
+
+func newFilter(field string, op Op, raw string) (Filter, error) {
+	switch op {
+	case OpExists:
+		exists, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Filter{}, fmt.Errorf("%w: %s[exists] must be true or false", ErrInvalidQuery, field)
+		}
+		return Filter{Field: field, Op: op, Value: exists}, nil
+	case OpIn:
+		parts := strings.Split(raw, ",")
+		values := make([]any, len(parts))
+		for i, part := range parts {
+			values[i] = ParseLiteral(strings.TrimSpace(part))
+		}
+		return Filter{Field: field, Op: op, Value: values}, nil
+	case OpContains:
+		return Filter{Field: field, Op: op, Value: strings.Trim(raw, `"`)}, nil
+	}
+	return Filter{Field: field, Op: op, Value: ParseLiteral(raw)}, nil
+}
+
+// ParseLiteral types a filter value: integers and floats become int64 and
+// float64, true/false become bools and null becomes nil. Numbers with
+// leading zeros and double-quoted values stay strings.
+func ParseLiteral(raw string) any {
+	if len(raw) >= 2 && raw[0] == '"' && raw[len(raw)-1] == '"' {
+		return raw[1 : len(raw)-1]
+	}
+	switch raw {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if jsonNumber.MatchString(raw) {
+		if i, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			return f
+		}
+	}
+	return raw
+}
+
+// Path splits a dotted field into its keys.
+func Path(field string) []string {
+	return strings.Split(field, ".")
+}
+
+// cursor is the payload of an opaque page cursor.
+type cursor struct {
+	Offset int `json:"o"`
+}
+
+// EncodeCursor returns the cursor for the page starting at offset.
+func EncodeCursor(offset int) string {
+	data, _ := json.Marshal(cursor{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor returns the offset encoded by EncodeCursor.
+func DecodeCursor(raw string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Offset < 0 {
+		return 0, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	return c.Offset, nil
+}
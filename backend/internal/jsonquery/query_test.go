@@ -0,0 +1,174 @@
+package jsonquery
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	values, _ := url.ParseQuery(`status=paid&amount[gte]=10&tags[in]=a,2&user.name[contains]=ali&zip="01234"&deleted[exists]=false&fields=id,amount&sort=-amount,id&limit=20`)
+	q, err := Parse(values)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []Filter{
+		{Field: "amount", Op: OpGte, Value: int64(10)},
+		{Field: "deleted", Op: OpExists, Value: false},
+		{Field: "status", Op: OpEq, Value: "paid"},
+		{Field: "tags", Op: OpIn, Value: []any{"a", int64(2)}},
+		{Field: "user.name", Op: OpContains, Value: "ali"},
+		{Field: "zip", Op: OpEq, Value: "01234"},
+	}
+	if !reflect.DeepEqual(q.Filters, want) {
+		t.Fatalf("filters = %#v\nwant %#v", q.Filters, want)
+	}
+	if !reflect.DeepEqual(q.Fields, []string{"id", "amount"}) {
+		t.Fatalf("fields = %v", q.Fields)
+	}
+	if !reflect.DeepEqual(q.Sort, []SortKey{{Field: "amount", Desc: true}, {Field: "id"}}) {
+		t.Fatalf("sort = %v", q.Sort)
+	}
+	if q.Limit != 20 || q.Offset != 0 {
+		t.Fatalf("limit/offset = %d/%d", q.Limit, q.Offset)
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	for _, raw := range []string{
+		"amount[between]=1",
+		"$where=1",
+		"limit=-1",
+		"cursor=!!",
+		"flag[exists]=maybe",
+		"fields=a.b",
+		"sort=-",
+	} {
+		values, _ := url.ParseQuery(raw)
+		if _, err := Parse(values); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidQuery", raw, err)
+		}
+	}
+}
+
+func TestParseLiteral(t *testing.T) {
+	cases := map[string]any{
+		"42":       int64(42),
+		"-1.5":     -1.5,
+		"1e3":      1000.0,
+		"007":      "007",
+		"true":     true,
+		"null":     nil,
+		`"42"`:     "42",
+		"0x10":     "0x10",
+		"Infinity": "Infinity",
+	}
+	for raw, want := range cases {
+		if got := ParseLiteral(raw); got != want {
+			t.Errorf("ParseLiteral(%q) = %#v, want %#v", raw, got, want)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	offset, err := DecodeCursor(EncodeCursor(150))
+	if err != nil || offset != 150 {
+		t.Fatalf("round trip = %d, %v", offset, err)
+	}
+}
+
+func sampleDocs() []map[string]any {
+	return []map[string]any{
+		{"id": json.Number("1"), "amount": json.Number("20.5"), "status": "paid", "user": map[string]any{"name": "Alice"}},
+		{"id": json.Number("2"), "amount": json.Number("5"), "status": "pending"},
+		{"id": json.Number("3"), "status": "paid", "user": map[string]any{"name": "Bob"}},
+		{"id": json.Number("4"), "amount": "n/a", "status": "paid"},
+		{"id": json.Number("5"), "amount": json.Number("20.5"), "status": nil},
+	}
+}
+
+func ids(docs []map[string]any) []string {
+	out := make([]string, len(docs))
+	for i, doc := range docs {
+		out[i] = doc["id"].(json.Number).String()
+	}
+	return out
+}
+
+func run(t *testing.T, raw string, docs []map[string]any) []map[string]any {
+	t.Helper()
+	values, _ := url.ParseQuery(raw)
+	q, err := Parse(values)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", raw, err)
+	}
+	out, err := Collect(q, func(yield func(map[string]any) bool) error {
+		for _, doc := range docs {
+			if !yield(doc) {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	return out
+}
+
+func TestCollectFilters(t *testing.T) {
+	cases := map[string][]string{
+		"status=paid":             {"1", "3", "4"},
+		"amount[gt]=10":           {"1", "5"},
+		"amount[lte]=20.5":        {"1", "2", "5"},
+		"amount=20.5&status=paid": {"1"},
+		"status[ne]=paid":         {"2", "5"},
+		"status=null":             {"5"},
+		"amount[exists]=false":    {"3"},
+		"user.name[contains]=ALI": {"1"},
+		"id[in]=2,4":              {"2", "4"},
+		"amount[gt]=a":            {"4"},
+	}
+	for raw, want := range cases {
+		if got := ids(run(t, raw, sampleDocs())); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestCollectSortAndPage(t *testing.T) {
+	// Numbers before strings, missing last in both directions, ties in input order.
+	if got := ids(run(t, "sort=amount", sampleDocs())); !reflect.DeepEqual(got, []string{"2", "1", "5", "4", "3"}) {
+		t.Fatalf("ascending: got %v", got)
+	}
+	if got := ids(run(t, "sort=-amount", sampleDocs())); !reflect.DeepEqual(got, []string{"4", "1", "5", "2", "3"}) {
+		t.Fatalf("descending: got %v", got)
+	}
+	cursor := EncodeCursor(2)
+	if got := ids(run(t, "sort=amount&limit=2&cursor="+cursor, sampleDocs())); !reflect.DeepEqual(got, []string{"5", "4"}) {
+		t.Fatalf("second page: got %v", got)
+	}
+}
+
+func TestCollectBoundsSortedMemory(t *testing.T) {
+	docs := make([]map[string]any, 500)
+	for i := range docs {
+		docs[i] = map[string]any{"id": json.Number(strconv.Itoa(i)), "rank": json.Number(strconv.Itoa(i % 7))}
+	}
+	got := run(t, "sort=rank,-id&limit=3&cursor="+EncodeCursor(1), docs)
+	if want := []string{"490", "483", "476"}; !reflect.DeepEqual(ids(got), want) {
+		t.Fatalf("got %v, want %v", ids(got), want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := []map[string]any{{"id": json.Number("1"), "n": json.Number("1")}, {"id": json.Number("3"), "n": json.Number("3")}}
+	b := []map[string]any{{"id": json.Number("2"), "n": json.Number("2")}, {"id": json.Number("4"), "n": json.Number("4")}}
+	q := Query{Sort: []SortKey{{Field: "n"}}, Offset: 1, Limit: 2}
+	if got := ids(Merge(q, a, b)); !reflect.DeepEqual(got, []string{"2", "3"}) {
+		t.Fatalf("Merge = %v", got)
+	}
+}
@@ -128,10 +128,7 @@ func DecideStorage(namespace string, docs []map[string]any, summary Summary, ana
 	
 	reason = fmt.Sprintf("%s (score %.2f)", reason, score)
 
-	table := sanitizeIdentifier(namespace)
-	if table == "" {
-		table = "dataset"
-	}
+	table := TableName(namespace)
 
 	decision := Decision{
 		Engine:     engine,
@@ -153,6 +150,15 @@ func DecideStorage(namespace string, docs []map[string]any, summary Summary, ana
 	return decision
 }
 
+// TableName returns the table or collection name a namespace is stored under.
+func TableName(namespace string) string {
+	table := sanitizeIdentifier(namespace)
+	if table == "" {
+		return "dataset"
+	}
+	return table
+}
+
 func buildCreateTable(table string, summary Summary) string {
 	columns := make([]string, 0)
 	for path, field := range summary.Fields {
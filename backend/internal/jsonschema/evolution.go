@@ -0,0 +1,272 @@
+package jsonschema
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangeKind classifies how a column differs between schema versions.
+type ChangeKind string
+
+const (
+	// ChangeAdded is a field first seen in this batch.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved is a known field absent from this batch.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeWidened is a type that grew compatibly (BIGINT to DOUBLE
+	// PRECISION, longer VARCHAR, VARCHAR to TEXT).
+	ChangeWidened ChangeKind = "widened"
+	// ChangeConflicting is a type with no common scalar supertype; the
+	// column becomes JSONB.
+	ChangeConflicting ChangeKind = "conflicting"
+)
+
+// ColumnChange describes drift of one column.
+type ColumnChange struct {
+	Column string     `json:"column"`
+	Kind   ChangeKind `json:"kind"`
+	From   string     `json:"from,omitempty"`
+	To     string     `json:"to,omitempty"`
+}
+
+// SchemaVersion is one distinct schema of a namespace. Columns is the
+// cumulative schema after applying Changes to the previous version.
+type SchemaVersion struct {
+	Version   int                  `json:"version"`
+	Engine    string               `json:"engine"`
+	Columns   map[string]ColumnDef `json:"columns"`
+	Changes   []ColumnChange       `json:"changes,omitempty"`
+	Migration []string             `json:"migration,omitempty"`
+	FirstSeen time.Time            `json:"first_seen"`
+	LastSeen  time.Time            `json:"last_seen"`
+	Batches   int                  `json:"batches"`
+	Documents int                  `json:"documents"`
+}
+
+// SchemaHistory is the ordered list of schema versions of a namespace.
+type SchemaHistory struct {
+	Namespace string          `json:"namespace"`
+	Table     string          `json:"table"`
+	Versions  []SchemaVersion `json:"versions"`
+}
+
+// Current returns the latest cumulative schema, or nil before the first batch.
+func (h *SchemaHistory) Current() map[string]ColumnDef {
+	if len(h.Versions) == 0 {
+		return nil
+	}
+	return h.Versions[len(h.Versions)-1].Columns
+}
+
+// Record folds the columns observed in a batch into the history. A batch
+// that matches the current schema extends the latest version; otherwise a
+// new version is appended with its drift and migration DDL. It returns the
+// version the batch belongs to and whether that version is new.
+func (h *SchemaHistory) Record(observed map[string]ColumnDef, engine string, documents int, at time.Time) (SchemaVersion, bool) {
+	at = at.UTC()
+	current := h.Current()
+	if current != nil {
+		changes := DiffColumns(current, observed)
+		if len(changes) == 0 {
+			latest := &h.Versions[len(h.Versions)-1]
+			latest.LastSeen = at
+			latest.Batches++
+			latest.Documents += documents
+			return *latest, false
+		}
+		columns := ApplyChanges(current, observed, changes)
+		version := SchemaVersion{
+			Version:   len(h.Versions) + 1,
+			Engine:    engine,
+			Columns:   columns,
+			Changes:   changes,
+			Migration: MigrationDDL(h.Table, columns, changes),
+			FirstSeen: at,
+			LastSeen:  at,
+			Batches:   1,
+			Documents: documents,
+		}
+		h.Versions = append(h.Versions, version)
+		return version, true
+	}
+
+	columns := make(map[string]ColumnDef, len(observed))
+	for name, col := range observed {
+		if col.Type != "" {
+			columns[name] = col
+		}
+	}
+	version := SchemaVersion{
+		Version:   1,
+		Engine:    engine,
+		Columns:   columns,
+		Migration: []string{CreateTableDDL(h.Table, columns)},
+		FirstSeen: at,
+		LastSeen:  at,
+		Batches:   1,
+		Documents: documents,
+	}
+	h.Versions = append(h.Versions, version)
+	return version, true
+}
+
+// ObservedColumns infers the columns of a batch for schema tracking. Fields
+// that only held nulls carry no type and are returned with an empty Type.
+func ObservedColumns(table string, docs []map[string]any) map[string]ColumnDef {
+	_, columns := GeneratePostgresSchema(table, docs)
+	typed := make(map[string]bool, len(columns))
+	for _, doc := range docs {
+		for field, value := range doc {
+			if value != nil {
+				typed[field] = true
+			}
+		}
+	}
+	for name, col := range columns {
+		if !typed[name] {
+			col.Type = ""
+			columns[name] = col
+		}
+	}
+	return columns
+}
+
+// DiffColumns classifies how observed differs from the current schema.
+// Narrower types (an integer in a DOUBLE PRECISION column, a shorter string)
+// fit the existing column and are not drift; untyped fields are ignored.
+func DiffColumns(current, observed map[string]ColumnDef) []ColumnChange {
+	var changes []ColumnChange
+	for name, col := range observed {
+		if col.Type == "" {
+			continue
+		}
+		prev, ok := current[name]
+		if !ok {
+			changes = append(changes, ColumnChange{Column: name, Kind: ChangeAdded, To: col.Type})
+			continue
+		}
+		if merged, kind := WidenType(prev.Type, col.Type); kind != "" {
+			changes = append(changes, ColumnChange{Column: name, Kind: kind, From: prev.Type, To: merged})
+		}
+	}
+	for name, prev := range current {
+		if _, ok := observed[name]; !ok && prev.Required {
+			changes = append(changes, ColumnChange{Column: name, Kind: ChangeRemoved, From: prev.Type})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Column < changes[j].Column })
+	return changes
+}
+
+// ApplyChanges returns the cumulative schema after changes. Removed columns
+// are kept but no longer required, and added columns are never required
+// because earlier rows lack them.
+func ApplyChanges(current, observed map[string]ColumnDef, changes []ColumnChange) map[string]ColumnDef {
+	columns := make(map[string]ColumnDef, len(current)+len(changes))
+	for name, col := range current {
+		if obs, ok := observed[name]; !ok || !obs.Required {
+			col.Required = false
+		}
+		columns[name] = col
+	}
+	for _, change := range changes {
+		switch change.Kind {
+		case ChangeAdded:
+			columns[change.Column] = ColumnDef{Name: change.Column, Type: change.To}
+		case ChangeWidened, ChangeConflicting:
+			col := columns[change.Column]
+			col.Type = change.To
+			columns[change.Column] = col
+		}
+	}
+	return columns
+}
+
+// WidenType returns the type able to hold values of both current and
+// candidate, and how current had to change to get there ("" when it already fits).
+func WidenType(current, candidate string) (string, ChangeKind) {
+	if current == candidate || current == "JSONB" {
+		return current, ""
+	}
+	if current == "DOUBLE PRECISION" && candidate == "BIGINT" {
+		return current, ""
+	}
+	if current == "BIGINT" && candidate == "DOUBLE PRECISION" {
+		return candidate, ChangeWidened
+	}
+
+	curLen, curStr := stringTypeLength(current)
+	candLen, candStr := stringTypeLength(candidate)
+	if curStr && candStr {
+		switch {
+		case curLen == 0: // TEXT holds any string
+			return current, ""
+		case candLen == 0 || candLen > curLen:
+			return candidate, ChangeWidened
+		}
+		return current, ""
+	}
+	return "JSONB", ChangeConflicting
+}
+
+// stringTypeLength reports whether typ is a string type and its VARCHAR
+// length, 0 meaning unbounded TEXT.
+func stringTypeLength(typ string) (int, bool) {
+	if typ == "TEXT" {
+		return 0, true
+	}
+	if strings.HasPrefix(typ, "VARCHAR(") && strings.HasSuffix(typ, ")") {
+		n, err := strconv.Atoi(typ[len("VARCHAR(") : len(typ)-1])
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// MigrationDDL returns the ALTER TABLE statements moving table to columns.
+// Conflicting columns are converted to JSONB, keeping existing values.
+func MigrationDDL(table string, columns map[string]ColumnDef, changes []ColumnChange) []string {
+	stmts := make([]string, 0, len(changes))
+	for _, change := range changes {
+		col := pgIdentifier(change.Column)
+		prefix := fmt.Sprintf("ALTER TABLE %s ", pgIdentifier(table))
+		switch change.Kind {
+		case ChangeAdded:
+			stmts = append(stmts, prefix+fmt.Sprintf("ADD COLUMN IF NOT EXISTS %s %s;", col, columns[change.Column].Type))
+		case ChangeRemoved:
+			stmts = append(stmts, prefix+fmt.Sprintf("ALTER COLUMN %s DROP NOT NULL;", col))
+		case ChangeWidened:
+			stmts = append(stmts, prefix+fmt.Sprintf("ALTER COLUMN %s TYPE %s;", col, change.To))
+		case ChangeConflicting:
+			stmts = append(stmts, prefix+fmt.Sprintf("ALTER COLUMN %s TYPE JSONB USING to_jsonb(%s);", col, col))
+		}
+	}
+	return stmts
+}
+
+// CreateTableDDL returns the CREATE TABLE statement for columns.
+func CreateTableDDL(table string, columns map[string]ColumnDef) string {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	defs := make([]string, 0, len(names))
+	for _, name := range names {
+		col := columns[name]
+		nullability := "NULL"
+		if col.Required {
+			nullability = "NOT NULL"
+		}
+		defs = append(defs, fmt.Sprintf("    %s %s %s", pgIdentifier(name), col.Type, nullability))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n);", pgIdentifier(table), strings.Join(defs, ",\n"))
+}
+
+// pgIdentifier quotes a column or table name as stored by the loader,
+// which keeps field names as they appear in the documents.
+func pgIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
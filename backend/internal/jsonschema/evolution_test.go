@@ -0,0 +1,100 @@
+package jsonschema
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWidenType(t *testing.T) {
+	tests := []struct {
+		current, candidate string
+		want               string
+		kind               ChangeKind
+	}{
+		{"BIGINT", "BIGINT", "BIGINT", ""},
+		{"BIGINT", "DOUBLE PRECISION", "DOUBLE PRECISION", ChangeWidened},
+		{"DOUBLE PRECISION", "BIGINT", "DOUBLE PRECISION", ""},
+		{"VARCHAR(32)", "VARCHAR(64)", "VARCHAR(64)", ChangeWidened},
+		{"VARCHAR(64)", "VARCHAR(32)", "VARCHAR(64)", ""},
+		{"VARCHAR(64)", "TEXT", "TEXT", ChangeWidened},
+		{"TEXT", "VARCHAR(32)", "TEXT", ""},
+		{"JSONB", "BIGINT", "JSONB", ""},
+		{"BIGINT", "VARCHAR(32)", "JSONB", ChangeConflicting},
+		{"BOOLEAN", "JSONB", "JSONB", ChangeConflicting},
+	}
+	for _, tt := range tests {
+		got, kind := WidenType(tt.current, tt.candidate)
+		if got != tt.want || kind != tt.kind {
+			t.Errorf("WidenType(%q, %q) = %q, %q; want %q, %q", tt.current, tt.candidate, got, kind, tt.want, tt.kind)
+		}
+	}
+}
+
+func TestObservedColumnsLeavesNullOnlyFieldsUntyped(t *testing.T) {
+	columns := ObservedColumns("orders", []map[string]any{
+		{"id": jsonNumber("1"), "note": nil},
+		{"id": jsonNumber("2"), "note": nil},
+	})
+	if columns["id"].Type != "BIGINT" || columns["note"].Type != "" {
+		t.Fatalf("unexpected columns %+v", columns)
+	}
+}
+
+func TestSchemaHistoryRecord(t *testing.T) {
+	h := &SchemaHistory{Namespace: "orders", Table: "orders"}
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	v, changed := h.Record(ObservedColumns("orders", []map[string]any{
+		{"id": jsonNumber("1"), "amount": jsonNumber("10"), "status": "paid", "note": nil},
+	}), "sql", 1, at)
+	if !changed || v.Version != 1 || len(v.Migration) != 1 {
+		t.Fatalf("unexpected first version %+v", v)
+	}
+	if _, ok := v.Columns["note"]; ok {
+		t.Fatalf("null-only field should not become a column: %+v", v.Columns)
+	}
+
+	// Same shape, narrower values: no new version.
+	v, changed = h.Record(ObservedColumns("orders", []map[string]any{
+		{"id": jsonNumber("2"), "amount": jsonNumber("5"), "status": "new", "note": nil},
+	}), "sql", 1, at.Add(time.Hour))
+	if changed || v.Version != 1 || v.Batches != 2 || v.Documents != 2 {
+		t.Fatalf("expected batch to extend version 1, got %+v", v)
+	}
+
+	v, changed = h.Record(ObservedColumns("orders", []map[string]any{
+		{"id": "A-3", "amount": jsonNumber("7.5"), "currency": "EUR"},
+	}), "sql", 1, at.Add(2*time.Hour))
+	if !changed || v.Version != 2 {
+		t.Fatalf("expected version 2, got %+v", v)
+	}
+	wantChanges := []ColumnChange{
+		{Column: "amount", Kind: ChangeWidened, From: "BIGINT", To: "DOUBLE PRECISION"},
+		{Column: "currency", Kind: ChangeAdded, To: "VARCHAR(32)"},
+		{Column: "id", Kind: ChangeConflicting, From: "BIGINT", To: "JSONB"},
+		{Column: "status", Kind: ChangeRemoved, From: "VARCHAR(32)"},
+	}
+	if !reflect.DeepEqual(v.Changes, wantChanges) {
+		t.Fatalf("changes = %+v\nwant %+v", v.Changes, wantChanges)
+	}
+	wantDDL := []string{
+		`ALTER TABLE "orders" ALTER COLUMN "amount" TYPE DOUBLE PRECISION;`,
+		`ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "currency" VARCHAR(32);`,
+		`ALTER TABLE "orders" ALTER COLUMN "id" TYPE JSONB USING to_jsonb("id");`,
+		`ALTER TABLE "orders" ALTER COLUMN "status" DROP NOT NULL;`,
+	}
+	if !reflect.DeepEqual(v.Migration, wantDDL) {
+		t.Fatalf("migration = %q\nwant %q", v.Migration, wantDDL)
+	}
+	if v.Columns["status"].Required || v.Columns["currency"].Required || v.Columns["id"].Type != "JSONB" {
+		t.Fatalf("unexpected cumulative columns %+v", v.Columns)
+	}
+
+	// A removed column that is already nullable is not reported again.
+	if _, changed := h.Record(ObservedColumns("orders", []map[string]any{
+		{"id": "A-4", "amount": jsonNumber("1"), "currency": "USD"},
+	}), "sql", 1, at.Add(3*time.Hour)); changed {
+		t.Fatalf("expected no drift, got version %d", len(h.Versions))
+	}
+}
@@ -0,0 +1,293 @@
+package mediainfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // registers the GIF decoder for DecodeConfig
+	_ "image/jpeg" // registers the JPEG decoder for DecodeConfig
+	_ "image/png"  // registers the PNG decoder for DecodeConfig
+	"io"
+	"strings"
+	"time"
+)
+
+// TIFF tags read from IFD0, the EXIF sub-IFD and the GPS sub-IFD.
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagGPSLatitudeRef     = 0x0001
+	tagGPSLatitude        = 0x0002
+	tagGPSLongitudeRef    = 0x0003
+	tagGPSLongitude       = 0x0004
+	tagGPSAltitudeRef     = 0x0005
+	tagGPSAltitude        = 0x0006
+)
+
+const exifDateLayout = "2006:01:02 15:04:05"
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// extractImage reads the dimensions of a JPEG, PNG or GIF and, for JPEG, its EXIF block.
+func extractImage(r io.ReadSeeker, jpeg bool) (*Info, error) {
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{Kind: KindImage, Format: format, Width: cfg.Width, Height: cfg.Height}
+	if !jpeg {
+		return info, nil
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return info, err
+	}
+	exif, err := findJPEGExif(r)
+	if err != nil || exif == nil {
+		return info, err
+	}
+	return info, parseEXIF(exif, info)
+}
+
+// findJPEGExif returns the TIFF payload of the first APP1 Exif segment, or
+// nil if the image has none.
+func findJPEGExif(r io.ReadSeeker) ([]byte, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil {
+		return nil, err
+	}
+	var hdr [4]byte
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, nil
+		}
+		if hdr[0] != 0xFF {
+			return nil, fmt.Errorf("mediainfo: bad JPEG marker %#x", hdr[0])
+		}
+		marker := hdr[1]
+		// Start of scan or end of image: metadata segments come before either.
+		if marker == 0xDA || marker == 0xD9 {
+			return nil, nil
+		}
+		length := int(binary.BigEndian.Uint16(hdr[2:])) - 2
+		if length < 0 {
+			return nil, errTruncated
+		}
+		if marker != 0xE1 {
+			if _, err := r.Seek(int64(length), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil, errTruncated
+		}
+		if bytes.HasPrefix(segment, exifHeader) {
+			return segment[len(exifHeader):], nil
+		}
+	}
+}
+
+// tiff is a TIFF structure as embedded in EXIF.
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry is one tag of an image file directory with its value bytes resolved.
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// typeSizes maps TIFF field types to their element size in bytes.
+var typeSizes = map[uint16]uint64{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// parseEXIF reads camera, capture time, orientation and GPS tags into info.
+func parseEXIF(data []byte, info *Info) error {
+	if len(data) < 8 {
+		return errTruncated
+	}
+	t := &tiff{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return errors.New("mediainfo: bad TIFF byte order")
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return errors.New("mediainfo: bad TIFF magic")
+	}
+
+	ifd0, err := t.readIFD(t.order.Uint32(data[4:]))
+	if err != nil {
+		return err
+	}
+	info.CameraMake = t.text(ifd0[tagMake])
+	info.CameraModel = t.text(ifd0[tagModel])
+	if v, ok := t.uint(ifd0[tagOrientation]); ok {
+		info.Orientation = int(v)
+	}
+
+	taken := t.text(ifd0[tagDateTime])
+	offset := ""
+	if ptr, ok := t.uint(ifd0[tagExifIFD]); ok {
+		exifIFD, err := t.readIFD(ptr)
+		if err != nil {
+			return err
+		}
+		if original := t.text(exifIFD[tagDateTimeOriginal]); original != "" {
+			taken = original
+			offset = t.text(exifIFD[tagOffsetTimeOriginal])
+		}
+	}
+	if ts, ok := parseEXIFTime(taken, offset); ok {
+		info.TakenAt = &ts
+	}
+
+	if ptr, ok := t.uint(ifd0[tagGPSIFD]); ok {
+		gpsIFD, err := t.readIFD(ptr)
+		if err != nil {
+			return err
+		}
+		info.GPS = t.gps(gpsIFD)
+	}
+	return nil
+}
+
+// readIFD reads the directory at offset. Values larger than four bytes are
+// resolved through their offset; out-of-range entries are skipped.
+func (t *tiff) readIFD(offset uint32) (map[uint16]ifdEntry, error) {
+	start := uint64(offset)
+	if start+2 > uint64(len(t.data)) {
+		return nil, errTruncated
+	}
+	count := uint64(t.order.Uint16(t.data[start:]))
+	if start+2+count*12 > uint64(len(t.data)) {
+		return nil, errTruncated
+	}
+
+	entries := make(map[uint16]ifdEntry, count)
+	for i := uint64(0); i < count; i++ {
+		raw := t.data[start+2+i*12 : start+2+(i+1)*12]
+		typ := t.order.Uint16(raw[2:])
+		n := t.order.Uint32(raw[4:])
+		elem, ok := typeSizes[typ]
+		if !ok {
+			continue
+		}
+		size := elem * uint64(n)
+		var value []byte
+		if size <= 4 {
+			value = raw[8 : 8+size]
+		} else {
+			at := uint64(t.order.Uint32(raw[8:]))
+			if at+size > uint64(len(t.data)) {
+				continue
+			}
+			value = t.data[at : at+size]
+		}
+		entries[t.order.Uint16(raw)] = ifdEntry{typ: typ, count: n, value: value}
+	}
+	return entries, nil
+}
+
+// text decodes an ASCII value.
+func (t *tiff) text(e ifdEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(e.value), "\x00"))
+}
+
+// uint decodes the first SHORT or LONG of e.
+func (t *tiff) uint(e ifdEntry) (uint32, bool) {
+	switch {
+	case e.typ == 3 && len(e.value) >= 2:
+		return uint32(t.order.Uint16(e.value)), true
+	case e.typ == 4 && len(e.value) >= 4:
+		return t.order.Uint32(e.value), true
+	}
+	return 0, false
+}
+
+// rationals decodes an unsigned RATIONAL array.
+func (t *tiff) rationals(e ifdEntry) []float64 {
+	if e.typ != 5 {
+		return nil
+	}
+	values := make([]float64, 0, len(e.value)/8)
+	for i := 0; i+8 <= len(e.value); i += 8 {
+		num := t.order.Uint32(e.value[i:])
+		den := t.order.Uint32(e.value[i+4:])
+		if den == 0 {
+			return nil
+		}
+		values = append(values, float64(num)/float64(den))
+	}
+	return values
+}
+
+// gps converts the GPS IFD to decimal degrees; it returns nil without a position.
+func (t *tiff) gps(ifd map[uint16]ifdEntry) *GPS {
+	lat := degrees(t.rationals(ifd[tagGPSLatitude]))
+	lon := degrees(t.rationals(ifd[tagGPSLongitude]))
+	if lat == nil || lon == nil {
+		return nil
+	}
+	pos := &GPS{Latitude: *lat, Longitude: *lon}
+	if strings.EqualFold(t.text(ifd[tagGPSLatitudeRef]), "S") {
+		pos.Latitude = -pos.Latitude
+	}
+	if strings.EqualFold(t.text(ifd[tagGPSLongitudeRef]), "W") {
+		pos.Longitude = -pos.Longitude
+	}
+	if alt := t.rationals(ifd[tagGPSAltitude]); len(alt) == 1 {
+		altitude := alt[0]
+		if ref := ifd[tagGPSAltitudeRef]; len(ref.value) == 1 && ref.value[0] == 1 {
+			altitude = -altitude
+		}
+		pos.Altitude = &altitude
+	}
+	return pos
+}
+
+// degrees converts degrees, minutes and seconds to decimal degrees.
+func degrees(dms []float64) *float64 {
+	if len(dms) != 3 {
+		return nil
+	}
+	value := dms[0] + dms[1]/60 + dms[2]/3600
+	return &value
+}
+
+// parseEXIFTime parses an EXIF timestamp. Without an offset tag the camera's
+// local time is unknown, so the wall-clock value is recorded as UTC.
+func parseEXIFTime(value, offset string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if offset != "" {
+		if ts, err := time.Parse(exifDateLayout+"-07:00", value+offset); err == nil {
+			return ts.UTC(), true
+		}
+	}
+	ts, err := time.Parse(exifDateLayout, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ts, true
+}
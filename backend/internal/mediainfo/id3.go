@@ -0,0 +1,209 @@
+package mediainfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxID3Size bounds the ID3v2 tag read into memory; embedded cover art can be
+// large, but text frames always come first in practice.
+const maxID3Size = 16 << 20
+
+// id3Frames maps ID3v2.2 and v2.3/v2.4 frame IDs to the fields they fill.
+var id3Frames = map[string]string{
+	"TT2": "title", "TIT2": "title",
+	"TP1": "artist", "TPE1": "artist",
+	"TAL": "album", "TALB": "album",
+	"TYE": "year", "TYER": "year", "TDRC": "year",
+	"TCO": "genre", "TCON": "genre",
+	"TRK": "track", "TRCK": "track",
+	"TLE": "length", "TLEN": "length",
+}
+
+// numericGenre matches ID3 genre references such as "(17)Rock".
+var numericGenre = regexp.MustCompile(`^\((\d+)\)(.*)$`)
+
+// parseID3v2 reads the text frames of the ID3v2 tag at the start of r.
+func parseID3v2(r io.ReadSeeker, info *Info) error {
+	var hdr [10]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return errTruncated
+	}
+	major, flags := hdr[3], hdr[5]
+	size := syncsafe(hdr[6:10])
+	if major < 2 || major > 4 {
+		return nil
+	}
+	if size > maxID3Size {
+		size = maxID3Size
+	}
+	tag := make([]byte, size)
+	n, err := io.ReadFull(r, tag)
+	tag = tag[:n]
+	if flags&0x80 != 0 && major < 4 {
+		tag = bytes.ReplaceAll(tag, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+
+	pos := 0
+	if flags&0x40 != 0 && major >= 3 && len(tag) >= 4 {
+		if major == 3 {
+			pos = 4 + int(binary.BigEndian.Uint32(tag))
+		} else {
+			pos = syncsafe(tag[:4])
+		}
+	}
+
+	idLen, hdrLen := 4, 10
+	if major == 2 {
+		idLen, hdrLen = 3, 6
+	}
+	for pos+hdrLen <= len(tag) && tag[pos] != 0 {
+		id := string(tag[pos : pos+idLen])
+		var frameSize int
+		switch major {
+		case 2:
+			frameSize = int(tag[pos+3])<<16 | int(tag[pos+4])<<8 | int(tag[pos+5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(tag[pos+4:]))
+		default:
+			frameSize = syncsafe(tag[pos+4 : pos+8])
+		}
+		body := pos + hdrLen
+		if frameSize < 0 || body+frameSize > len(tag) {
+			break
+		}
+		if field, ok := id3Frames[id]; ok && frameSize > 0 {
+			setTag(info, field, decodeID3Text(tag[body:body+frameSize]))
+		}
+		pos = body + frameSize
+	}
+	if err != nil && n == 0 {
+		return errTruncated
+	}
+	return nil
+}
+
+// parseID3v1 fills empty fields from a 128-byte ID3v1 trailer, if present.
+func parseID3v1(r io.ReadSeeker, info *Info) error {
+	if _, err := r.Seek(-128, io.SeekEnd); err != nil {
+		return nil
+	}
+	var tag [128]byte
+	if _, err := io.ReadFull(r, tag[:]); err != nil || string(tag[:3]) != "TAG" {
+		return nil
+	}
+	field := func(b []byte) string {
+		return strings.TrimSpace(strings.TrimRight(latin1(b), "\x00"))
+	}
+	if info.Title == "" {
+		info.Title = field(tag[3:33])
+	}
+	if info.Artist == "" {
+		info.Artist = field(tag[33:63])
+	}
+	if info.Album == "" {
+		info.Album = field(tag[63:93])
+	}
+	if info.Year == 0 {
+		info.Year, _ = strconv.Atoi(field(tag[93:97]))
+	}
+	// ID3v1.1 stores the track number in the last comment byte after a NUL.
+	if info.Track == 0 && tag[125] == 0 && tag[126] != 0 {
+		info.Track = int(tag[126])
+	}
+	return nil
+}
+
+// setTag stores a decoded ID3 text value into the matching Info field.
+func setTag(info *Info, field, value string) {
+	if value == "" {
+		return
+	}
+	switch field {
+	case "title":
+		info.Title = value
+	case "artist":
+		info.Artist = value
+	case "album":
+		info.Album = value
+	case "genre":
+		if m := numericGenre.FindStringSubmatch(value); m != nil && strings.TrimSpace(m[2]) != "" {
+			value = strings.TrimSpace(m[2])
+		}
+		info.Genre = value
+	case "year":
+		if len(value) >= 4 {
+			info.Year, _ = strconv.Atoi(value[:4])
+		}
+	case "track":
+		number, _, _ := strings.Cut(value, "/")
+		info.Track, _ = strconv.Atoi(strings.TrimSpace(number))
+	case "length":
+		if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+			info.DurationSeconds = roundSeconds(float64(ms) / 1000)
+		}
+	}
+}
+
+// decodeID3Text decodes a text frame body. Only the first of several
+// NUL-separated values (ID3v2.4) is returned.
+func decodeID3Text(body []byte) string {
+	if len(body) < 2 {
+		return ""
+	}
+	data := body[1:]
+	var text string
+	switch body[0] {
+	case 0:
+		text = latin1(data)
+	case 1, 2:
+		text = utf16Text(data, body[0] == 2)
+	default:
+		text = string(data)
+	}
+	if i := strings.IndexByte(text, 0); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+// utf16Text decodes UTF-16 honouring a byte order mark; bigEndian is the
+// order assumed when there is none.
+func utf16Text(data []byte, bigEndian bool) string {
+	if len(data) >= 2 {
+		switch {
+		case data[0] == 0xFF && data[1] == 0xFE:
+			bigEndian, data = false, data[2:]
+		case data[0] == 0xFE && data[1] == 0xFF:
+			bigEndian, data = true, data[2:]
+		}
+	}
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, binary.BigEndian.Uint16(data[i:]))
+		} else {
+			units = append(units, binary.LittleEndian.Uint16(data[i:]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// latin1 decodes ISO-8859-1 bytes.
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// syncsafe decodes a 28-bit ID3 synchsafe integer.
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
@@ -0,0 +1,285 @@
+package mediainfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// EBML element IDs used by Matroska and WebM (marker bits included).
+const (
+	ebmlIDHeader        = 0x1A45DFA3
+	ebmlIDDocType       = 0x4282
+	mkvIDSegment        = 0x18538067
+	mkvIDInfo           = 0x1549A966
+	mkvIDTimecodeScale  = 0x2AD7B1
+	mkvIDDuration       = 0x4489
+	mkvIDTitle          = 0x7BA9
+	mkvIDTracks         = 0x1654AE6B
+	mkvIDTrackEntry     = 0xAE
+	mkvIDTrackType      = 0x83
+	mkvIDCodecID        = 0x86
+	mkvIDVideo          = 0xE0
+	mkvIDPixelWidth     = 0xB0
+	mkvIDPixelHeight    = 0xBA
+	mkvIDCluster        = 0x1F43B675
+	mkvTrackTypeVideo   = 1
+	mkvTrackTypeAudio   = 2
+	mkvDefaultTimescale = 1000000
+)
+
+// maxEBMLElementSize bounds the header elements read into memory.
+const maxEBMLElementSize = 8 << 20
+
+// unknownSize marks an EBML element whose size is not encoded (live streams).
+const unknownSize = -1
+
+// parseMatroska reads the EBML doc type, segment info and track list,
+// stopping at the first cluster of media data.
+func parseMatroska(r io.ReadSeeker, info *Info) error {
+	id, size, err := readElementHeader(r)
+	if err != nil {
+		return err
+	}
+	if id != ebmlIDHeader {
+		return errors.New("mediainfo: missing EBML header")
+	}
+	header, err := readBox(r, size, 4096)
+	if err != nil {
+		return err
+	}
+	_ = eachElement(header, func(id uint64, body []byte) error {
+		if id == ebmlIDDocType && strings.TrimRight(string(body), "\x00") == "webm" {
+			info.Format = "webm"
+		}
+		return nil
+	})
+
+	id, _, err = readElementHeader(r)
+	if err != nil {
+		return err
+	}
+	if id != mkvIDSegment {
+		return errors.New("mediainfo: missing Matroska segment")
+	}
+
+	var sawInfo, sawTracks, sawVideo bool
+	for !(sawInfo && sawTracks) {
+		id, size, err := readElementHeader(r)
+		if errors.Is(err, io.EOF) || id == mkvIDCluster {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch id {
+		case mkvIDInfo:
+			body, err := readBox(r, size, maxEBMLElementSize)
+			if err != nil {
+				return err
+			}
+			parseSegmentInfo(body, info)
+			sawInfo = true
+		case mkvIDTracks:
+			body, err := readBox(r, size, maxEBMLElementSize)
+			if err != nil {
+				return err
+			}
+			sawVideo = parseTracks(body, info)
+			sawTracks = true
+		default:
+			if size == unknownSize {
+				return nil
+			}
+			if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+	}
+	if sawTracks && !sawVideo {
+		info.Kind = KindAudio
+	}
+	return nil
+}
+
+// parseSegmentInfo reads the duration and title.
+func parseSegmentInfo(body []byte, info *Info) {
+	scale := uint64(mkvDefaultTimescale)
+	var duration float64
+	_ = eachElement(body, func(id uint64, value []byte) error {
+		switch id {
+		case mkvIDTimecodeScale:
+			if v := ebmlUint(value); v > 0 {
+				scale = v
+			}
+		case mkvIDDuration:
+			duration = ebmlFloat(value)
+		case mkvIDTitle:
+			info.Title = strings.TrimRight(string(value), "\x00")
+		}
+		return nil
+	})
+	if duration > 0 {
+		info.DurationSeconds = roundSeconds(duration * float64(scale) / 1e9)
+	}
+}
+
+// parseTracks records the first video and audio codec IDs and the video
+// size. It reports whether a video track was found.
+func parseTracks(body []byte, info *Info) bool {
+	sawVideo := false
+	_ = eachElement(body, func(id uint64, entry []byte) error {
+		if id != mkvIDTrackEntry {
+			return nil
+		}
+		var trackType uint64
+		var codec string
+		var width, height int
+		_ = eachElement(entry, func(id uint64, value []byte) error {
+			switch id {
+			case mkvIDTrackType:
+				trackType = ebmlUint(value)
+			case mkvIDCodecID:
+				codec = strings.TrimRight(string(value), "\x00")
+			case mkvIDVideo:
+				_ = eachElement(value, func(id uint64, value []byte) error {
+					switch id {
+					case mkvIDPixelWidth:
+						width = int(ebmlUint(value))
+					case mkvIDPixelHeight:
+						height = int(ebmlUint(value))
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		switch trackType {
+		case mkvTrackTypeVideo:
+			sawVideo = true
+			if info.VideoCodec == "" {
+				info.VideoCodec = codec
+				info.Width, info.Height = width, height
+			}
+		case mkvTrackTypeAudio:
+			if info.AudioCodec == "" {
+				info.AudioCodec = codec
+			}
+		}
+		return nil
+	})
+	return sawVideo
+}
+
+// readElementHeader reads an element ID and data size from r.
+func readElementHeader(r io.Reader) (uint64, int64, error) {
+	id, _, err := readVint(r, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	size, unknown, err := readVint(r, true)
+	if err != nil {
+		return 0, 0, errTruncated
+	}
+	if unknown {
+		return id, unknownSize, nil
+	}
+	if size > math.MaxInt64 {
+		return 0, 0, fmt.Errorf("mediainfo: element %#x too large", id)
+	}
+	return id, int64(size), nil
+}
+
+// readVint reads an EBML variable-length integer. IDs keep their length
+// marker; sizes have it stripped, and unknown reports the reserved all-ones size.
+func readVint(r io.Reader, stripMarker bool) (value uint64, unknown bool, err error) {
+	var first [1]byte
+	if _, err := io.ReadFull(r, first[:]); err != nil {
+		return 0, false, err
+	}
+	length := vintLength(first[0])
+	if length == 0 {
+		return 0, false, errors.New("mediainfo: invalid EBML integer")
+	}
+	buf := make([]byte, length)
+	buf[0] = first[0]
+	if _, err := io.ReadFull(r, buf[1:]); err != nil {
+		return 0, false, errTruncated
+	}
+	value, unknown = decodeVint(buf, stripMarker)
+	return value, unknown, nil
+}
+
+// vintLength returns the encoded length announced by the leading zero bits of b.
+func vintLength(b byte) int {
+	for i := 0; i < 8; i++ {
+		if b&(0x80>>i) != 0 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func decodeVint(buf []byte, stripMarker bool) (uint64, bool) {
+	value := uint64(buf[0])
+	if stripMarker {
+		value &^= 0x80 >> (len(buf) - 1)
+	}
+	for _, b := range buf[1:] {
+		value = value<<8 | uint64(b)
+	}
+	allOnes := uint64(1)<<(7*len(buf)) - 1
+	return value, stripMarker && value == allOnes
+}
+
+// eachElement calls fn for every child element in data.
+func eachElement(data []byte, fn func(id uint64, body []byte) error) error {
+	for len(data) > 0 {
+		idLen := vintLength(data[0])
+		if idLen == 0 || idLen > len(data) {
+			return errTruncated
+		}
+		id, _ := decodeVint(data[:idLen], false)
+		data = data[idLen:]
+		if len(data) == 0 {
+			return errTruncated
+		}
+		sizeLen := vintLength(data[0])
+		if sizeLen == 0 || sizeLen > len(data) {
+			return errTruncated
+		}
+		size, unknown := decodeVint(data[:sizeLen], true)
+		data = data[sizeLen:]
+		if unknown || size > uint64(len(data)) {
+			size = uint64(len(data))
+		}
+		if err := fn(id, data[:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// ebmlUint decodes a big-endian unsigned integer of up to eight bytes.
+func ebmlUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// ebmlFloat decodes a 4- or 8-byte IEEE float.
+func ebmlFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
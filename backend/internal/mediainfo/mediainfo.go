@@ -0,0 +1,127 @@
+// Package mediainfo extracts descriptive metadata (dimensions, EXIF, ID3 tags,
+// container duration and codecs) from images, audio and video files. All
+// parsers are pure Go and only read the headers they need.
+package mediainfo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// Kind is the broad media type of an analysed file.
+type Kind string
+
+const (
+	KindImage Kind = "image"
+	KindAudio Kind = "audio"
+	KindVideo Kind = "video"
+)
+
+// errTruncated is returned when a header ends before a structure it announces.
+var errTruncated = errors.New("mediainfo: truncated data")
+
+// GPS is a position recorded by a camera, in decimal degrees and metres.
+type GPS struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+}
+
+// Info is the metadata extracted from a file. Fields that do not apply to the
+// file's kind, or were not present, are left empty.
+type Info struct {
+	Kind   Kind   `json:"kind"`
+	Format string `json:"format"`
+
+	// Images and video
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+
+	// EXIF
+	CameraMake  string     `json:"camera_make,omitempty"`
+	CameraModel string     `json:"camera_model,omitempty"`
+	TakenAt     *time.Time `json:"taken_at,omitempty"`
+	Orientation int        `json:"orientation,omitempty"`
+	GPS         *GPS       `json:"gps,omitempty"`
+
+	// Audio and video containers
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	VideoCodec      string  `json:"video_codec,omitempty"`
+	AudioCodec      string  `json:"audio_codec,omitempty"`
+
+	// ID3 and container tags
+	Title  string `json:"title,omitempty"`
+	Artist string `json:"artist,omitempty"`
+	Album  string `json:"album,omitempty"`
+	Genre  string `json:"genre,omitempty"`
+	Year   int    `json:"year,omitempty"`
+	Track  int    `json:"track,omitempty"`
+}
+
+// Duration returns DurationSeconds as a time.Duration.
+func (i *Info) Duration() time.Duration {
+	return time.Duration(i.DurationSeconds * float64(time.Second))
+}
+
+var (
+	magicJPEG = []byte{0xFF, 0xD8, 0xFF}
+	magicPNG  = []byte("\x89PNG\r\n\x1a\n")
+	magicGIF  = []byte("GIF8")
+	magicID3  = []byte("ID3")
+	magicEBML = []byte{0x1A, 0x45, 0xDF, 0xA3}
+)
+
+// Extract identifies r by its leading bytes and parses the metadata of
+// supported formats: JPEG, PNG, GIF, MP3, MP4/QuickTime and Matroska/WebM.
+// It returns nil, nil for other formats. When parsing fails part-way, the
+// fields read so far are returned along with the error.
+func Extract(r io.ReadSeeker) (*Info, error) {
+	head := make([]byte, 12)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	head = head[:n]
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, magicJPEG):
+		return extractImage(r, true)
+	case bytes.HasPrefix(head, magicPNG), bytes.HasPrefix(head, magicGIF):
+		return extractImage(r, false)
+	case bytes.HasPrefix(head, magicID3):
+		info := &Info{Kind: KindAudio, Format: "mp3"}
+		err := parseID3v2(r, info)
+		if v1err := parseID3v1(r, info); err == nil {
+			err = v1err
+		}
+		return info, err
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		// MPEG audio frame sync without an ID3v2 tag; only an ID3v1 trailer can carry tags.
+		info := &Info{Kind: KindAudio, Format: "mp3"}
+		if err := parseID3v1(r, info); err != nil || info.Title == "" && info.Artist == "" && info.Album == "" {
+			return nil, err
+		}
+		return info, nil
+	case bytes.HasPrefix(head, magicEBML):
+		info := &Info{Kind: KindVideo, Format: "matroska"}
+		return info, parseMatroska(r, info)
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		info := &Info{Kind: KindVideo, Format: "mp4"}
+		return info, parseMP4(r, info)
+	}
+	return nil, nil
+}
+
+// roundSeconds trims durations to millisecond precision.
+func roundSeconds(seconds float64) float64 {
+	return math.Round(seconds*1000) / 1000
+}
@@ -0,0 +1,334 @@
+package mediainfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tiffField is one IFD entry for buildTIFF. When ifdRef is set the entry is a
+// LONG pointing at ifds[ifdRef].
+type tiffField struct {
+	tag    uint16
+	typ    uint16
+	count  uint32
+	data   []byte
+	ifdRef int
+}
+
+func asciiField(tag uint16, s string) tiffField {
+	return tiffField{tag: tag, typ: 2, count: uint32(len(s) + 1), data: append([]byte(s), 0)}
+}
+
+func rationalField(tag uint16, values ...[2]uint32) tiffField {
+	data := make([]byte, 0, 8*len(values))
+	for _, v := range values {
+		data = binary.LittleEndian.AppendUint32(data, v[0])
+		data = binary.LittleEndian.AppendUint32(data, v[1])
+	}
+	return tiffField{tag: tag, typ: 5, count: uint32(len(values)), data: data}
+}
+
+// buildTIFF lays out little-endian IFDs back to back, followed by the values
+// that do not fit inline.
+func buildTIFF(ifds ...[]tiffField) []byte {
+	le := binary.LittleEndian
+	offsets := make([]uint32, len(ifds))
+	pos := uint32(8)
+	for i, ifd := range ifds {
+		offsets[i] = pos
+		pos += 2 + 12*uint32(len(ifd)) + 4
+	}
+	out := make([]byte, pos)
+	copy(out, "II")
+	le.PutUint16(out[2:], 42)
+	le.PutUint32(out[4:], 8)
+	for i, ifd := range ifds {
+		at := offsets[i]
+		le.PutUint16(out[at:], uint16(len(ifd)))
+		for j, f := range ifd {
+			e := out[at+2+12*uint32(j):]
+			le.PutUint16(e, f.tag)
+			if f.ifdRef > 0 {
+				le.PutUint16(e[2:], 4)
+				le.PutUint32(e[4:], 1)
+				le.PutUint32(e[8:], offsets[f.ifdRef])
+				continue
+			}
+			le.PutUint16(e[2:], f.typ)
+			le.PutUint32(e[4:], f.count)
+			if len(f.data) <= 4 {
+				copy(e[8:], f.data)
+			} else {
+				le.PutUint32(e[8:], uint32(len(out)))
+				out = append(out, f.data...)
+			}
+		}
+	}
+	return out
+}
+
+func jpegWithExif(t *testing.T, tiffData []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 32, 16)), nil); err != nil {
+		t.Fatal(err)
+	}
+	plain := buf.Bytes()
+	segment := append([]byte("Exif\x00\x00"), tiffData...)
+	out := append([]byte(nil), plain[:2]...)
+	out = append(out, 0xFF, 0xE1)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, plain[2:]...)
+}
+
+func TestExtractJPEGExif(t *testing.T) {
+	orientation := make([]byte, 2)
+	binary.LittleEndian.PutUint16(orientation, 6)
+	tiffData := buildTIFF(
+		[]tiffField{
+			asciiField(tagMake, "Canon"),
+			asciiField(tagModel, "EOS R5"),
+			{tag: tagOrientation, typ: 3, count: 1, data: orientation},
+			{tag: tagExifIFD, ifdRef: 1},
+			{tag: tagGPSIFD, ifdRef: 2},
+		},
+		[]tiffField{
+			asciiField(tagDateTimeOriginal, "2024:06:01 14:30:00"),
+			asciiField(tagOffsetTimeOriginal, "+02:00"),
+		},
+		[]tiffField{
+			asciiField(tagGPSLatitudeRef, "N"),
+			rationalField(tagGPSLatitude, [2]uint32{37, 1}, [2]uint32{46, 1}, [2]uint32{30, 1}),
+			asciiField(tagGPSLongitudeRef, "W"),
+			rationalField(tagGPSLongitude, [2]uint32{122, 1}, [2]uint32{25, 1}, [2]uint32{12, 1}),
+			{tag: tagGPSAltitudeRef, typ: 1, count: 1, data: []byte{0}},
+			rationalField(tagGPSAltitude, [2]uint32{31, 2}),
+		},
+	)
+
+	info, err := Extract(bytes.NewReader(jpegWithExif(t, tiffData)))
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if info.Kind != KindImage || info.Format != "jpeg" || info.Width != 32 || info.Height != 16 {
+		t.Fatalf("unexpected image info %+v", info)
+	}
+	if info.CameraMake != "Canon" || info.CameraModel != "EOS R5" || info.Orientation != 6 {
+		t.Fatalf("unexpected camera fields %+v", info)
+	}
+	want := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	if info.TakenAt == nil || !info.TakenAt.Equal(want) {
+		t.Fatalf("expected taken_at %v, got %v", want, info.TakenAt)
+	}
+	if info.GPS == nil || math.Abs(info.GPS.Latitude-37.775) > 1e-9 || math.Abs(info.GPS.Longitude+122.42) > 1e-9 {
+		t.Fatalf("unexpected GPS %+v", info.GPS)
+	}
+	if info.GPS.Altitude == nil || *info.GPS.Altitude != 15.5 {
+		t.Fatalf("unexpected altitude %v", info.GPS.Altitude)
+	}
+}
+
+func TestExtractPNGDimensionsAndUnknownFormats(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 7, 3))); err != nil {
+		t.Fatal(err)
+	}
+	info, err := Extract(bytes.NewReader(buf.Bytes()))
+	if err != nil || info == nil || info.Format != "png" || info.Width != 7 || info.Height != 3 {
+		t.Fatalf("unexpected png info %+v (err %v)", info, err)
+	}
+
+	for _, data := range []string{"", "plain text file", "%PDF-1.7\n"} {
+		info, err := Extract(strings.NewReader(data))
+		if info != nil || err != nil {
+			t.Fatalf("expected nothing for %q, got %+v, %v", data, info, err)
+		}
+	}
+}
+
+func id3Frame(id string, body []byte) []byte {
+	frame := []byte(id)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(body)))
+	return append(frame, 0, 0)
+}
+
+func TestExtractID3Tags(t *testing.T) {
+	var frames []byte
+	addText := func(id string, enc byte, text []byte) {
+		body := append([]byte{enc}, text...)
+		frames = append(frames, id3Frame(id, body)...)
+		frames = append(frames, body...)
+	}
+	addText("TIT2", 0, []byte("Night Drive"))
+	// UTF-16 with a little-endian byte order mark
+	artist := []byte{0xFF, 0xFE}
+	for _, r := range "Émile" {
+		artist = binary.LittleEndian.AppendUint16(artist, uint16(r))
+	}
+	addText("TPE1", 1, artist)
+	addText("TALB", 3, []byte("Coastlines"))
+	addText("TYER", 0, []byte("2021"))
+	addText("TRCK", 0, []byte("3/12"))
+	addText("TCON", 0, []byte("(17)Rock"))
+	addText("TLEN", 0, []byte("215500"))
+	frames = append(frames, make([]byte, 32)...) // padding
+
+	size := len(frames)
+	tag := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)}
+	data := append(append(tag, frames...), 0xFF, 0xFB, 0x90, 0x00)
+
+	info, err := Extract(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if info.Kind != KindAudio || info.Format != "mp3" {
+		t.Fatalf("unexpected kind %+v", info)
+	}
+	if info.Title != "Night Drive" || info.Artist != "Émile" || info.Album != "Coastlines" {
+		t.Fatalf("unexpected text tags %+v", info)
+	}
+	if info.Year != 2021 || info.Track != 3 || info.Genre != "Rock" || info.DurationSeconds != 215.5 {
+		t.Fatalf("unexpected numeric tags %+v", info)
+	}
+}
+
+func TestExtractID3v1Trailer(t *testing.T) {
+	trailer := make([]byte, 128)
+	copy(trailer, "TAG")
+	copy(trailer[3:], "Old Song")
+	copy(trailer[33:], "Old Band")
+	copy(trailer[93:], "1999")
+	trailer[126] = 7
+	data := append([]byte{0xFF, 0xFB, 0x90, 0x00, 1, 2, 3, 4}, trailer...)
+
+	info, err := Extract(bytes.NewReader(data))
+	if err != nil || info == nil {
+		t.Fatalf("extract: %+v, %v", info, err)
+	}
+	if info.Title != "Old Song" || info.Artist != "Old Band" || info.Year != 1999 || info.Track != 7 {
+		t.Fatalf("unexpected ID3v1 tags %+v", info)
+	}
+}
+
+func box(typ string, parts ...[]byte) []byte {
+	payload := bytes.Join(parts, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+8))
+	return append(append(out, typ...), payload...)
+}
+
+func mp4Track(handler, codec string, width, height uint32) []byte {
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], width<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], height<<16)
+	hdlr := append(make([]byte, 8), handler...)
+	hdlr = append(hdlr, make([]byte, 13)...)
+	stsd := binary.BigEndian.AppendUint32(make([]byte, 4), 1)
+	stsd = append(stsd, box(codec, make([]byte, 8))...)
+	return box("trak",
+		box("tkhd", tkhd),
+		box("mdia", box("hdlr", hdlr), box("minf", box("stbl", box("stsd", stsd)))),
+	)
+}
+
+func TestExtractMP4(t *testing.T) {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 12500)
+	data := bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), make([]byte, 4), []byte("isomavc1")),
+		box("mdat", make([]byte, 4096)),
+		box("moov",
+			box("mvhd", mvhd),
+			mp4Track("vide", "avc1", 1920, 1080),
+			mp4Track("soun", "mp4a", 0, 0),
+		),
+	}, nil)
+
+	info, err := Extract(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if info.Kind != KindVideo || info.Format != "mp4" || info.DurationSeconds != 12.5 {
+		t.Fatalf("unexpected container info %+v", info)
+	}
+	if info.VideoCodec != "avc1" || info.AudioCodec != "mp4a" || info.Width != 1920 || info.Height != 1080 {
+		t.Fatalf("unexpected track info %+v", info)
+	}
+}
+
+func ebml(id uint32, parts ...[]byte) []byte {
+	payload := bytes.Join(parts, nil)
+	var out []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || len(out) > 0 {
+			out = append(out, b)
+		}
+	}
+	out = append(out, 0x01)
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(payload)))
+	out = append(out, size[1:]...)
+	return append(out, payload...)
+}
+
+func TestExtractWebM(t *testing.T) {
+	duration := binary.BigEndian.AppendUint64(nil, math.Float64bits(90500))
+	segment := bytes.Join([][]byte{
+		ebml(mkvIDInfo,
+			ebml(mkvIDTimecodeScale, []byte{0x0F, 0x42, 0x40}),
+			ebml(mkvIDDuration, duration),
+			ebml(mkvIDTitle, []byte("Launch")),
+		),
+		ebml(mkvIDTracks,
+			ebml(mkvIDTrackEntry,
+				ebml(mkvIDTrackType, []byte{1}),
+				ebml(mkvIDCodecID, []byte("V_VP9")),
+				ebml(mkvIDVideo, ebml(mkvIDPixelWidth, []byte{0x02, 0x80}), ebml(mkvIDPixelHeight, []byte{0x01, 0x68})),
+			),
+			ebml(mkvIDTrackEntry,
+				ebml(mkvIDTrackType, []byte{2}),
+				ebml(mkvIDCodecID, []byte("A_OPUS")),
+			),
+		),
+		ebml(mkvIDCluster, make([]byte, 64)),
+	}, nil)
+	// A live-recorded segment with an unknown size
+	data := append(ebml(ebmlIDHeader, ebml(ebmlIDDocType, []byte("webm"))),
+		0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	data = append(data, segment...)
+
+	info, err := Extract(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if info.Kind != KindVideo || info.Format != "webm" || info.DurationSeconds != 90.5 || info.Title != "Launch" {
+		t.Fatalf("unexpected container info %+v", info)
+	}
+	if info.VideoCodec != "V_VP9" || info.AudioCodec != "A_OPUS" || info.Width != 640 || info.Height != 360 {
+		t.Fatalf("unexpected track info %+v", info)
+	}
+}
+
+func TestExtractTruncatedInputDoesNotPanic(t *testing.T) {
+	tiffData := buildTIFF([]tiffField{asciiField(tagMake, "Nikon"), {tag: tagExifIFD, ifdRef: 1}}, []tiffField{asciiField(tagDateTimeOriginal, "2020:01:01 00:00:00")})
+	inputs := [][]byte{
+		jpegWithExif(t, tiffData),
+		box("ftyp", []byte("isom")),
+		append(ebml(ebmlIDHeader, ebml(ebmlIDDocType, []byte("matroska"))), 0x18, 0x53, 0x80, 0x67, 0x01),
+		[]byte("ID3\x04\x00\x00\x00\x00\x01\x00TIT2"),
+	}
+	for _, input := range inputs {
+		for cut := 1; cut < len(input); cut++ {
+			_, _ = Extract(bytes.NewReader(input[:cut]))
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package mediainfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxMoovSize bounds the movie header read into memory. The moov box holds
+// sample tables only, so even long recordings stay well below this.
+const maxMoovSize = 64 << 20
+
+// parseMP4 reads the ftyp brand and the moov box of an ISO base media file
+// (MP4, M4A, MOV), skipping media data without reading it.
+func parseMP4(r io.ReadSeeker, info *Info) error {
+	sawVideo := false
+	for {
+		typ, size, err := readBoxHeader(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch typ {
+		case "ftyp":
+			body, err := readBox(r, size, 1024)
+			if err != nil {
+				return err
+			}
+			if len(body) >= 4 && string(body[:4]) == "qt  " {
+				info.Format = "quicktime"
+			}
+		case "moov":
+			body, err := readBox(r, size, maxMoovSize)
+			if err != nil {
+				return err
+			}
+			sawVideo, err = parseMoov(body, info)
+			if err != nil {
+				return err
+			}
+			if !sawVideo {
+				info.Kind = KindAudio
+			}
+			return nil
+		default:
+			if size < 0 {
+				return nil
+			}
+			if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+	}
+	return errors.New("mediainfo: no moov box")
+}
+
+// readBoxHeader returns the type and payload size of the next box. A size of
+// -1 means the box extends to the end of the file.
+func readBoxHeader(r io.Reader) (string, int64, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return "", 0, errTruncated
+		}
+		return "", 0, err
+	}
+	size := int64(binary.BigEndian.Uint32(hdr[:4]))
+	typ := string(hdr[4:8])
+	switch size {
+	case 0:
+		return typ, -1, nil
+	case 1:
+		var large [8]byte
+		if _, err := io.ReadFull(r, large[:]); err != nil {
+			return "", 0, errTruncated
+		}
+		size = int64(binary.BigEndian.Uint64(large[:])) - 16
+	default:
+		size -= 8
+	}
+	if size < 0 {
+		return "", 0, fmt.Errorf("mediainfo: bad %q box size", typ)
+	}
+	return typ, size, nil
+}
+
+// readBox reads a box payload of at most limit bytes.
+func readBox(r io.Reader, size, limit int64) ([]byte, error) {
+	if size < 0 || size > limit {
+		return nil, fmt.Errorf("mediainfo: box of %d bytes exceeds %d", size, limit)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, errTruncated
+	}
+	return body, nil
+}
+
+// eachBox calls fn for every box in data.
+func eachBox(data []byte, fn func(typ string, body []byte) error) error {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data))
+		typ := string(data[4:8])
+		hdr := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return errTruncated
+			}
+			size, hdr = binary.BigEndian.Uint64(data[8:]), 16
+		}
+		if size < hdr || size > uint64(len(data)) {
+			return errTruncated
+		}
+		if err := fn(typ, data[hdr:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// parseMoov reads the movie duration and each track's codec. It reports
+// whether a video track was found.
+func parseMoov(moov []byte, info *Info) (bool, error) {
+	sawVideo := false
+	err := eachBox(moov, func(typ string, body []byte) error {
+		switch typ {
+		case "mvhd":
+			if seconds, ok := mvhdDuration(body); ok {
+				info.DurationSeconds = roundSeconds(seconds)
+			}
+		case "trak":
+			video, err := parseTrak(body, info)
+			sawVideo = sawVideo || video
+			return err
+		case "udta":
+			return parseUserData(body, info)
+		}
+		return nil
+	})
+	return sawVideo, err
+}
+
+// mvhdDuration decodes the movie header duration in seconds.
+func mvhdDuration(body []byte) (float64, bool) {
+	if len(body) < 4 {
+		return 0, false
+	}
+	var timescale uint32
+	var duration uint64
+	if body[0] == 1 {
+		if len(body) < 32 {
+			return 0, false
+		}
+		timescale = binary.BigEndian.Uint32(body[20:])
+		duration = binary.BigEndian.Uint64(body[24:])
+	} else {
+		if len(body) < 20 {
+			return 0, false
+		}
+		timescale = binary.BigEndian.Uint32(body[12:])
+		duration = uint64(binary.BigEndian.Uint32(body[16:]))
+	}
+	if timescale == 0 {
+		return 0, false
+	}
+	return float64(duration) / float64(timescale), true
+}
+
+// parseTrak records the codec of a video or sound track, and the display
+// size of a video track. It reports whether the track is video.
+func parseTrak(trak []byte, info *Info) (bool, error) {
+	var handler, codec string
+	var width, height int
+	err := eachBox(trak, func(typ string, body []byte) error {
+		switch typ {
+		case "tkhd":
+			width, height = tkhdSize(body)
+		case "mdia":
+			return eachBox(body, func(typ string, body []byte) error {
+				switch typ {
+				case "hdlr":
+					if len(body) >= 12 {
+						handler = string(body[8:12])
+					}
+				case "minf":
+					codec = sampleEntryCodec(body)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	switch handler {
+	case "vide":
+		if info.VideoCodec == "" {
+			info.VideoCodec = codec
+			info.Width, info.Height = width, height
+		}
+		return true, nil
+	case "soun":
+		if info.AudioCodec == "" {
+			info.AudioCodec = codec
+		}
+	}
+	return false, nil
+}
+
+// tkhdSize decodes the 16.16 fixed-point track width and height.
+func tkhdSize(body []byte) (int, int) {
+	offset := 76
+	if len(body) > 0 && body[0] == 1 {
+		offset = 88
+	}
+	if len(body) < offset+8 {
+		return 0, 0
+	}
+	return int(binary.BigEndian.Uint32(body[offset:]) >> 16), int(binary.BigEndian.Uint32(body[offset+4:]) >> 16)
+}
+
+// sampleEntryCodec returns the format of the first sample description in
+// minf/stbl/stsd, e.g. "avc1" or "mp4a".
+func sampleEntryCodec(minf []byte) string {
+	codec := ""
+	_ = eachBox(minf, func(typ string, body []byte) error {
+		if typ != "stbl" {
+			return nil
+		}
+		return eachBox(body, func(typ string, body []byte) error {
+			// stsd: version/flags (4), entry count (4), then sample entry boxes
+			if typ == "stsd" && len(body) >= 16 {
+				codec = strings.TrimSpace(string(body[12:16]))
+			}
+			return nil
+		})
+	})
+	return codec
+}
+
+// parseUserData reads iTunes-style title, artist and album atoms from
+// udta/meta/ilst, as written by most encoders for M4A files.
+func parseUserData(udta []byte, info *Info) error {
+	return eachBox(udta, func(typ string, body []byte) error {
+		if typ != "meta" || len(body) < 4 {
+			return nil
+		}
+		// meta is a full box: skip version/flags.
+		return eachBox(body[4:], func(typ string, body []byte) error {
+			if typ != "ilst" {
+				return nil
+			}
+			return eachBox(body, func(typ string, body []byte) error {
+				var field string
+				switch typ {
+				case "\xa9nam":
+					field = "title"
+				case "\xa9ART":
+					field = "artist"
+				case "\xa9alb":
+					field = "album"
+				case "\xa9day":
+					field = "year"
+				case "\xa9gen":
+					field = "genre"
+				default:
+					return nil
+				}
+				return eachBox(body, func(typ string, body []byte) error {
+					// data: type indicator (4), locale (4), value
+					if typ == "data" && len(body) > 8 {
+						setTag(info, field, strings.TrimSpace(string(body[8:])))
+					}
+					return nil
+				})
+			})
+		})
+	})
+}
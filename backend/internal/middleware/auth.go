@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/Muneer320/RhinoBox/internal/auth"
+	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
+)
+
+// AuthMiddleware enforces API keys and scopes when authentication is enabled
+type AuthMiddleware struct {
+	enabled      bool
+	keys         *auth.KeyStore
+	errorHandler *ErrorHandler
+	logger       *slog.Logger
+}
+
+// NewAuthMiddleware creates a new authentication middleware instance
+func NewAuthMiddleware(enabled bool, keys *auth.KeyStore, errorHandler *ErrorHandler, logger *slog.Logger) *AuthMiddleware {
+	return &AuthMiddleware{
+		enabled:      enabled,
+		keys:         keys,
+		errorHandler: errorHandler,
+		logger:       logger,
+	}
+}
+
+// Handler returns the middleware handler function
+func (a *AuthMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		required := auth.RequiredScope(r)
+		if required == auth.ScopeNone {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		secret := extractAPIKey(r)
+		if secret == "" {
+			a.reject(w, r, apierrors.Unauthorized("missing API key"))
+			return
+		}
+
+		key, err := a.keys.Authenticate(secret)
+		if err != nil {
+			message := "invalid API key"
+			switch {
+			case errors.Is(err, auth.ErrKeyRevoked):
+				message = "API key has been revoked"
+			case errors.Is(err, auth.ErrKeyExpired):
+				message = "API key has expired"
+			}
+			if a.logger != nil {
+				a.logger.Warn("request rejected by auth",
+					"path", r.URL.Path,
+					"reason", err.Error(),
+				)
+			}
+			a.reject(w, r, apierrors.Unauthorized(message))
+			return
+		}
+
+		if !key.Allows(required) {
+			a.reject(w, r, apierrors.Forbidden("API key lacks required scope").
+				WithDetails("required_scope", required).
+				WithDetails("key_id", key.ID))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithKey(r.Context(), key)))
+	})
+}
+
+func (a *AuthMiddleware) reject(w http.ResponseWriter, r *http.Request, apiErr *apierrors.APIError) {
+	if apiErr.Code == apierrors.ErrorCodeUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="rhinobox"`)
+	}
+	a.errorHandler.HandleError(w, r, apiErr)
+}
+
+// extractAPIKey reads the key from "Authorization: Bearer <key>" or X-API-Key
+func extractAPIKey(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, value, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
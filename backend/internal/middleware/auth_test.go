@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Muneer320/RhinoBox/internal/auth"
+)
+
+func TestAuthMiddleware_Handler(t *testing.T) {
+	keys, err := auth.NewKeyStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new key store: %v", err)
+	}
+	_, reader, err := keys.Create(auth.CreateKeyRequest{Name: "reader", Scopes: []auth.Scope{auth.ScopeRead}})
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	_, admin, err := keys.Create(auth.CreateKeyRequest{Name: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}})
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.KeyFromContext(r.Context()); !ok && r.URL.Path != "/healthz" {
+			t.Errorf("expected authenticated key in context for %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		enabled        bool
+		method         string
+		path           string
+		header         string
+		value          string
+		expectedStatus int
+	}{
+		{"Auth disabled", false, "DELETE", "/files/abc", "", "", http.StatusOK},
+		{"Public path", true, "GET", "/healthz", "", "", http.StatusOK},
+		{"Missing key", true, "GET", "/files", "", "", http.StatusUnauthorized},
+		{"Unknown key", true, "GET", "/files", "X-API-Key", "rbx_nope", http.StatusUnauthorized},
+		{"Bearer read key", true, "GET", "/files", "Authorization", "Bearer " + reader, http.StatusOK},
+		{"Header read key", true, "GET", "/files", "X-API-Key", reader, http.StatusOK},
+		{"Read key cannot delete", true, "DELETE", "/files/abc", "X-API-Key", reader, http.StatusForbidden},
+		{"Admin implies delete", true, "DELETE", "/files/abc", "Authorization", "Bearer " + admin, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw := NewAuthMiddleware(tt.enabled, keys, NewErrorHandler(logger), logger)
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			if tt.enabled {
+				mw.Handler(handler).ServeHTTP(rec, req)
+			} else {
+				mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				})).ServeHTTP(rec, req)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header on 401")
+			}
+		})
+	}
+}
@@ -10,6 +10,10 @@ import (
 	"github.com/Muneer320/RhinoBox/internal/config"
 )
 
+// corsExposeHeaders lists response headers browsers may read, including the
+// tus headers resumable upload clients depend on.
+const corsExposeHeaders = "Content-Length, Content-Type, X-Request-ID, Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, Upload-Metadata"
+
 // CORSMiddleware handles Cross-Origin Resource Sharing (CORS) requests
 type CORSMiddleware struct {
 	config config.SecurityConfig
@@ -34,8 +38,10 @@ func (c *CORSMiddleware) Handler(next http.Handler) http.Handler {
 
 		origin := r.Header.Get("Origin")
 
-		// Handle preflight OPTIONS requests
-		if r.Method == "OPTIONS" {
+		// Handle preflight OPTIONS requests. Browsers always send Origin on a
+		// preflight; OPTIONS without it (e.g. tus capability discovery) is
+		// routed to the handlers like any other request.
+		if r.Method == "OPTIONS" && origin != "" {
 			c.handlePreflight(w, r, origin)
 			return
 		}
@@ -48,7 +54,7 @@ func (c *CORSMiddleware) Handler(next http.Handler) http.Handler {
 				w.Header().Set("Access-Control-Allow-Credentials", "true")
 			}
 			// Set exposed headers on actual responses (not just preflight)
-			w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type, X-Request-ID")
+			w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
 		}
 
 		// Continue with the request
@@ -95,7 +101,7 @@ func (c *CORSMiddleware) handlePreflight(w http.ResponseWriter, r *http.Request,
 	}
 
 	// Set exposed headers if needed
-	w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type, X-Request-ID")
+	w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -10,6 +10,7 @@ import (
 	"runtime/debug"
 	"time"
 
+	"github.com/Muneer320/RhinoBox/internal/auth"
 	apierrors "github.com/Muneer320/RhinoBox/internal/errors"
 	"github.com/Muneer320/RhinoBox/internal/storage"
 )
@@ -102,12 +103,108 @@ func (eh *ErrorHandler) mapError(err error) (*apierrors.APIError, int) {
 	if errors.Is(err, storage.ErrMetadataTooLarge) {
 		return apierrors.BadRequest("metadata exceeds size limit"), http.StatusBadRequest
 	}
+	if errors.Is(err, storage.ErrInvalidTag) {
+		return apierrors.ValidationFailed(err.Error()), http.StatusBadRequest
+	}
+	if errors.Is(err, storage.ErrFolderNotFound) {
+		return apierrors.NotFound(err.Error()), http.StatusNotFound
+	}
+	if errors.Is(err, storage.ErrFolderExists) || errors.Is(err, storage.ErrFolderNotEmpty) {
+		return apierrors.Conflict(err.Error()), http.StatusConflict
+	}
+	if errors.Is(err, storage.ErrInvalidFolder) {
+		return apierrors.ValidationFailed(err.Error()), http.StatusBadRequest
+	}
+	if errors.Is(err, storage.ErrSavedSearchNotFound) {
+		return apierrors.NotFound(err.Error()), http.StatusNotFound
+	}
+	if errors.Is(err, storage.ErrSavedSearchExists) {
+		return apierrors.Conflict(err.Error()), http.StatusConflict
+	}
+	if errors.Is(err, storage.ErrInvalidSavedSearch) {
+		return apierrors.ValidationFailed(err.Error()), http.StatusBadRequest
+	}
+	if errors.Is(err, storage.ErrShareNotFound) {
+		return apierrors.NotFound(err.Error()), http.StatusNotFound
+	}
+	if errors.Is(err, storage.ErrShareUnavailable) {
+		return apierrors.NewAPIError(apierrors.ErrorCodeGone, err.Error()), http.StatusGone
+	}
+	if errors.Is(err, storage.ErrUploadURLUsed) {
+		return apierrors.NewAPIError(apierrors.ErrorCodeGone, err.Error()), http.StatusGone
+	}
+	if errors.Is(err, storage.ErrSharePassword) {
+		return apierrors.Unauthorized(err.Error()), http.StatusUnauthorized
+	}
+	if errors.Is(err, storage.ErrInvalidShare) {
+		return apierrors.ValidationFailed(err.Error()), http.StatusBadRequest
+	}
+	if errors.Is(err, storage.ErrInvalidArchive) {
+		return apierrors.ValidationFailed(err.Error()), http.StatusBadRequest
+	}
+	if errors.Is(err, storage.ErrArchiveEntryNotFound) {
+		return apierrors.NotFound(err.Error()), http.StatusNotFound
+	}
+	if errors.Is(err, storage.ErrUnsupportedArchive) {
+		return apierrors.NewAPIError(apierrors.ErrorCodeUnsupportedMedia, err.Error()), http.StatusUnsupportedMediaType
+	}
+	if errors.Is(err, storage.ErrInvalidBackup) {
+		return apierrors.ValidationFailed(err.Error()), http.StatusBadRequest
+	}
+	if errors.Is(err, storage.ErrArchiveLimit) {
+		return apierrors.NewAPIError(apierrors.ErrorCodeRequestTooLarge, err.Error()), http.StatusRequestEntityTooLarge
+	}
 	if errors.Is(err, storage.ErrInvalidMetadataKey) {
 		return apierrors.ValidationFailed("invalid metadata key"), http.StatusBadRequest
 	}
 	if errors.Is(err, storage.ErrProtectedField) {
 		return apierrors.BadRequest("cannot modify protected metadata field"), http.StatusBadRequest
 	}
+	if errors.Is(err, storage.ErrUploadNotFound) {
+		return apierrors.NotFound("upload not found"), http.StatusNotFound
+	}
+	if errors.Is(err, storage.ErrUploadOffsetMismatch) {
+		return apierrors.Conflict(err.Error()), http.StatusConflict
+	}
+	if errors.Is(err, storage.ErrUploadCompleted) {
+		return apierrors.Conflict("upload already completed"), http.StatusConflict
+	}
+	if errors.Is(err, storage.ErrUploadTooLarge) {
+		return apierrors.NewAPIError(apierrors.ErrorCodeRequestTooLarge, "upload exceeds declared length"), http.StatusRequestEntityTooLarge
+	}
+	if errors.Is(err, storage.ErrTrashNotFound) {
+		return apierrors.NotFound("file not found in trash"), http.StatusNotFound
+	}
+	if errors.Is(err, storage.ErrRestoreConflict) {
+		return apierrors.Conflict(err.Error()), http.StatusConflict
+	}
+	if errors.Is(err, storage.ErrInvalidThumbnailSize) {
+		return apierrors.BadRequest(err.Error()), http.StatusBadRequest
+	}
+	if errors.Is(err, storage.ErrThumbnailUnsupported) {
+		return apierrors.NewAPIError(apierrors.ErrorCodeUnsupportedMedia, err.Error()), http.StatusUnsupportedMediaType
+	}
+	if errors.Is(err, storage.ErrTextExtractionUnsupported) {
+		return apierrors.NewAPIError(apierrors.ErrorCodeUnsupportedMedia, err.Error()), http.StatusUnsupportedMediaType
+	}
+	if errors.Is(err, storage.ErrQuotaExceeded) {
+		return apierrors.NewAPIError(apierrors.ErrorCodeQuotaExceeded, err.Error()), http.StatusInsufficientStorage
+	}
+	if errors.Is(err, storage.ErrTenantNotFound) {
+		return apierrors.NotFound("tenant not found"), http.StatusNotFound
+	}
+	if errors.Is(err, storage.ErrTenantExists) {
+		return apierrors.Conflict("tenant already exists"), http.StatusConflict
+	}
+	if errors.Is(err, storage.ErrInvalidTenantID) {
+		return apierrors.ValidationFailed(err.Error()), http.StatusBadRequest
+	}
+	if errors.Is(err, auth.ErrKeyNotFound) {
+		return apierrors.NotFound("api key not found"), http.StatusNotFound
+	}
+	if errors.Is(err, auth.ErrInvalidScope) {
+		return apierrors.ValidationFailed(err.Error()), http.StatusBadRequest
+	}
 
 	// Check for context errors (timeouts, cancellations)
 	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
@@ -135,8 +232,14 @@ func (eh *ErrorHandler) statusCodeFromErrorCode(code apierrors.ErrorCode) int {
 		return http.StatusRequestEntityTooLarge
 	case apierrors.ErrorCodeRangeNotSatisfiable:
 		return http.StatusRequestedRangeNotSatisfiable
+	case apierrors.ErrorCodeGone:
+		return http.StatusGone
 	case apierrors.ErrorCodeTimeout:
 		return http.StatusRequestTimeout
+	case apierrors.ErrorCodeQuotaExceeded:
+		return http.StatusInsufficientStorage
+	case apierrors.ErrorCodeUnsupportedMedia:
+		return http.StatusUnsupportedMediaType
 	case apierrors.ErrorCodeNotImplemented:
 		return http.StatusNotImplemented
 	case apierrors.ErrorCodeServiceUnavailable:
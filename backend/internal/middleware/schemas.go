@@ -525,14 +525,13 @@ func RegisterAllSchemas(validator *Validator, maxUploadBytes int64) {
 	})
 
 	// GET /files/search - Search files
+	// At least one filter is required; the handler enforces that because
+	// any of its parameters may be the one given.
 	validator.RegisterSchema("GET:/files/search", &Schema{
 		QueryParams: map[string]QueryParamRule{
 			"name": {
-				Required: true,
+				Required: false,
 				Validate: func(value string) error {
-					if len(value) == 0 {
-						return fmt.Errorf("name query parameter cannot be empty")
-					}
 					if len(value) > 255 {
 						return fmt.Errorf("name query parameter too long (max 255 characters)")
 					}
@@ -2,76 +2,63 @@ package queue
 
 import (
 	"fmt"
-	"mime/multipart"
+	"os"
 
-	"github.com/Muneer320/RhinoBox/internal/retry"
 	"github.com/Muneer320/RhinoBox/internal/storage"
 )
 
-// MediaProcessor processes media upload jobs
+// MediaProcessor stores uploaded files. Each item reads its content from
+// the spooled copy named by JobItem.Source, so jobs survive a restart.
 type MediaProcessor struct {
-	storage *storage.Manager
+	resolve func(tenant string) (*storage.Manager, error)
 }
 
-// NewMediaProcessor creates a new media processor
-func NewMediaProcessor(storage *storage.Manager) *MediaProcessor {
-	return &MediaProcessor{storage: storage}
+// NewMediaProcessor creates a media processor. resolve returns the storage
+// manager for a job's tenant.
+func NewMediaProcessor(resolve func(tenant string) (*storage.Manager, error)) *MediaProcessor {
+	return &MediaProcessor{resolve: resolve}
 }
 
-// ProcessItem implements JobProcessor for media files with automatic retry logic
+// ProcessItem implements JobProcessor for media files. Failures are retried
+// by the queue with backoff.
 func (mp *MediaProcessor) ProcessItem(job *Job, item *JobItem) error {
-	// Extract file handle from item data
-	fileHeader, ok := item.Data.(*multipart.FileHeader)
-	if !ok {
-		return fmt.Errorf("invalid item data type")
+	if item.Source == "" {
+		return fmt.Errorf("item %s has no spooled content", item.ID)
+	}
+	store, err := mp.resolve(job.Tenant)
+	if err != nil {
+		return err
 	}
 
 	// Get category from item metadata or job namespace
 	category := job.Namespace
+	mimeType := ""
 	if item.Metadata != nil {
 		if cat, ok := item.Metadata["category"].(string); ok && cat != "" {
 			category = cat
 		}
+		mimeType, _ = item.Metadata["mime_type"].(string)
 	}
 
-	// Configure retry with exponential backoff
-	retryCfg := retry.DefaultConfig()
-	retryCfg.MaxAttempts = 3 // Retry up to 3 times total
-	
-	var result *storage.StoreResult
-	
-	// Wrap the storage operation with retry logic
-	err := retry.DoWithRetryable(func() error {
-		// Open file (needs to be reopened on each retry)
-		file, err := fileHeader.Open()
-		if err != nil {
-			return fmt.Errorf("failed to open file: %w", err)
-		}
-		defer file.Close()
-
-		// Store file using storage manager
-		req := storage.StoreRequest{
-			Reader:       file,
-			Filename:     fileHeader.Filename,
-			MimeType:     fileHeader.Header.Get("Content-Type"),
-			Size:         fileHeader.Size,
-			Metadata:     map[string]string{
-				"job_id":    job.ID,
-				"namespace": job.Namespace,
-			},
-			CategoryHint: category,
-		}
+	file, err := os.Open(item.Source)
+	if err != nil {
+		return fmt.Errorf("failed to open spooled file: %w", err)
+	}
+	defer file.Close()
 
-		result, err = mp.storage.StoreFile(req)
-		if err != nil {
-			return fmt.Errorf("failed to store file: %w", err)
-		}
-		
-		return nil
-	}, retryCfg)
-	
+	result, err := store.StoreFile(storage.StoreRequest{
+		Reader:   file,
+		Filename: item.Name,
+		MimeType: mimeType,
+		Size:     item.Size,
+		Metadata: map[string]string{
+			"job_id":    job.ID,
+			"namespace": job.Namespace,
+		},
+		CategoryHint: category,
+	})
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to store file: %w", err)
 	}
 
 	// Store result in item
@@ -88,3 +75,85 @@ func (mp *MediaProcessor) ProcessItem(job *Job, item *JobItem) error {
 
 	return nil
 }
+
+// Dispatcher routes each job to the processor registered for its type.
+type Dispatcher map[JobType]JobProcessor
+
+// ProcessItem implements JobProcessor
+func (d Dispatcher) ProcessItem(job *Job, item *JobItem) error {
+	processor, ok := d[job.Type]
+	if !ok {
+		return fmt.Errorf("no processor for job type %q", job.Type)
+	}
+	return processor.ProcessItem(job, item)
+}
+
+// ThumbnailProcessor regenerates thumbnails for the file hash named by each item
+type ThumbnailProcessor struct {
+	resolve func(tenant string) (*storage.Manager, error)
+}
+
+// NewThumbnailProcessor creates a thumbnail processor. resolve returns the
+// storage manager for a job's tenant.
+func NewThumbnailProcessor(resolve func(tenant string) (*storage.Manager, error)) *ThumbnailProcessor {
+	return &ThumbnailProcessor{resolve: resolve}
+}
+
+// ProcessItem implements JobProcessor for thumbnail regeneration
+func (tp *ThumbnailProcessor) ProcessItem(job *Job, item *JobItem) error {
+	store, err := tp.resolve(job.Tenant)
+	if err != nil {
+		return err
+	}
+
+	set, err := store.GenerateThumbnails(item.Name)
+	if err != nil {
+		return fmt.Errorf("generate thumbnails for %s: %w", item.Name, err)
+	}
+
+	sizes := make([]int, len(set.Thumbnails))
+	for i, thumb := range set.Thumbnails {
+		sizes[i] = thumb.Size
+	}
+	item.Result = &JobItemResult{
+		Hash: set.Hash,
+		Metadata: map[string]interface{}{
+			"sizes": sizes,
+		},
+	}
+	return nil
+}
+
+// TextExtractionProcessor re-extracts the text of the document named by each item
+type TextExtractionProcessor struct {
+	resolve func(tenant string) (*storage.Manager, error)
+}
+
+// NewTextExtractionProcessor creates a text extraction processor. resolve
+// returns the storage manager for a job's tenant.
+func NewTextExtractionProcessor(resolve func(tenant string) (*storage.Manager, error)) *TextExtractionProcessor {
+	return &TextExtractionProcessor{resolve: resolve}
+}
+
+// ProcessItem implements JobProcessor for text re-extraction
+func (tp *TextExtractionProcessor) ProcessItem(job *Job, item *JobItem) error {
+	store, err := tp.resolve(job.Tenant)
+	if err != nil {
+		return err
+	}
+
+	text, err := store.ExtractText(item.Name)
+	if err != nil {
+		return fmt.Errorf("extract text of %s: %w", item.Name, err)
+	}
+
+	item.Result = &JobItemResult{
+		Hash: text.Hash,
+		Metadata: map[string]interface{}{
+			"format":    text.Format,
+			"bytes":     text.Bytes,
+			"truncated": text.Truncated,
+		},
+	}
+	return nil
+}
@@ -2,16 +2,28 @@ package queue
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Muneer320/RhinoBox/internal/retry"
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrJobNotFound is returned when a job ID is unknown.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrNotDeadLettered is returned when replaying a job that is not in the dead-letter list.
+	ErrNotDeadLettered = errors.New("job is not dead-lettered")
+	// ErrQueueFull is returned when the pending buffer has no room.
+	ErrQueueFull = errors.New("queue is full")
+)
+
 // JobType represents the type of job to process
 type JobType string
 
@@ -19,6 +31,11 @@ const (
 	JobTypeMedia JobType = "media"
 	JobTypeJSON  JobType = "json"
 	JobTypeBatch JobType = "batch"
+	// JobTypeThumbnail regenerates image thumbnails; each item names a file hash.
+	JobTypeThumbnail JobType = "thumbnail"
+	// JobTypeTextExtraction re-extracts the searchable text of documents;
+	// each item names a file hash.
+	JobTypeTextExtraction JobType = "text_extraction"
 )
 
 // JobStatus represents the current state of a job
@@ -27,6 +44,8 @@ type JobStatus string
 const (
 	StatusQueued     JobStatus = "queued"
 	StatusProcessing JobStatus = "processing"
+	// StatusRetrying marks a job waiting out its backoff before failed items are retried.
+	StatusRetrying   JobStatus = "retrying"
 	StatusCompleted  JobStatus = "completed"
 	StatusFailed     JobStatus = "failed"
 	StatusCancelled  JobStatus = "cancelled"
@@ -39,6 +58,11 @@ type JobItem struct {
 	Name         string                 `json:"name"`
 	Size         int64                  `json:"size"`
 	Data         interface{}            `json:"data,omitempty"`
+	// Source is the path of a spooled copy of the item's content, so the
+	// item can be processed after a restart.
+	Source       string                 `json:"source,omitempty"`
+	// Status is queued until the item is processed, then completed or failed.
+	Status       JobStatus              `json:"status,omitempty"`
 	Result       *JobItemResult         `json:"result,omitempty"`
 	Error        string                 `json:"error,omitempty"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
@@ -66,9 +90,14 @@ type Job struct {
 	CompletedAt *time.Time  `json:"completed_at,omitempty"`
 	Error       string      `json:"error,omitempty"`
 	Namespace   string      `json:"namespace,omitempty"`
+	Tenant      string      `json:"tenant,omitempty"`
 	Comment     string      `json:"comment,omitempty"`
 	RetryCount  int         `json:"retry_count"`
 	MaxRetries  int         `json:"max_retries"`
+	// NextAttemptAt is when a retrying job's failed items run again.
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	// DeadLetteredAt is set once a job has exhausted its retries with items still failing.
+	DeadLetteredAt *time.Time `json:"dead_lettered_at,omitempty"`
 }
 
 // JobResult aggregates the final result of a job
@@ -81,19 +110,28 @@ type JobResult struct {
 	Duration    time.Duration `json:"duration_ms"`
 	Results     []JobItem     `json:"results"`
 	Error       string        `json:"error,omitempty"`
+	Tenant      string        `json:"tenant,omitempty"`
 }
 
-// JobQueue manages asynchronous job processing
+// JobQueue manages asynchronous job processing. Every job is persisted as a
+// JSON file under the persist path, so queued, in-flight and retrying jobs
+// resume after a restart.
 type JobQueue struct {
 	pending     chan *Job
 	processing  map[string]*Job
 	completed   map[string]*JobResult
+	deadLetters map[string]*Job
+	retrying    int // active jobs waiting out a backoff
 	mu          sync.RWMutex
 	maxWorkers  int
+	maxRetries  int
+	retry       retry.Config
 	workers     []*Worker
 	persistPath string
 	stopCh      chan struct{}
 	wg          sync.WaitGroup
+	// hold is read-held by workers while they touch storage or job files.
+	hold sync.RWMutex
 }
 
 // Worker processes jobs from the queue
@@ -113,7 +151,11 @@ type JobProcessor interface {
 type Config struct {
 	MaxWorkers  int
 	PersistPath string
-	MaxRetries  int
+	// MaxRetries is how many times a job's failed items are retried before
+	// the job is dead-lettered. Jobs may override it with Job.MaxRetries.
+	MaxRetries int
+	// Retry supplies the backoff between attempts; its MaxAttempts is unused.
+	Retry retry.Config
 }
 
 // DefaultConfig returns sensible defaults
@@ -122,6 +164,7 @@ func DefaultConfig() Config {
 		MaxWorkers:  10,
 		PersistPath: "./data/jobs",
 		MaxRetries:  3,
+		Retry:       retry.DefaultConfig(),
 	}
 }
 
@@ -130,6 +173,9 @@ func New(cfg Config, processor JobProcessor) (*JobQueue, error) {
 	if cfg.MaxWorkers <= 0 {
 		cfg.MaxWorkers = 10
 	}
+	if cfg.Retry.InitialDelay <= 0 {
+		cfg.Retry = retry.DefaultConfig()
+	}
 
 	// Ensure persist directory exists
 	if err := os.MkdirAll(cfg.PersistPath, 0755); err != nil {
@@ -140,12 +186,21 @@ func New(cfg Config, processor JobProcessor) (*JobQueue, error) {
 		pending:     make(chan *Job, 1000), // Buffer 1000 jobs
 		processing:  make(map[string]*Job),
 		completed:   make(map[string]*JobResult),
+		deadLetters: make(map[string]*Job),
 		maxWorkers:  cfg.MaxWorkers,
+		maxRetries:  cfg.MaxRetries,
+		retry:       cfg.Retry,
 		workers:     make([]*Worker, cfg.MaxWorkers),
 		persistPath: cfg.PersistPath,
 		stopCh:      make(chan struct{}),
 	}
 
+	// Restore incomplete jobs before the workers start, so nothing else
+	// touches the job maps while they are filled.
+	if err := jq.restore(); err != nil {
+		return nil, fmt.Errorf("failed to restore jobs: %w", err)
+	}
+
 	// Start workers
 	for i := 0; i < cfg.MaxWorkers; i++ {
 		worker := &Worker{
@@ -159,11 +214,6 @@ func New(cfg Config, processor JobProcessor) (*JobQueue, error) {
 		go worker.start()
 	}
 
-	// Restore incomplete jobs on startup
-	if err := jq.restore(); err != nil {
-		return nil, fmt.Errorf("failed to restore jobs: %w", err)
-	}
-
 	return jq, nil
 }
 
@@ -175,8 +225,14 @@ func (jq *JobQueue) Enqueue(job *Job) error {
 	if job.CreatedAt.IsZero() {
 		job.CreatedAt = time.Now()
 	}
+	if job.MaxRetries == 0 {
+		job.MaxRetries = jq.maxRetries
+	}
 	job.Status = StatusQueued
 	job.Total = len(job.Items)
+	for i := range job.Items {
+		job.Items[i].Status = StatusQueued
+	}
 
 	// Persist job immediately
 	if err := jq.persistJob(job); err != nil {
@@ -188,7 +244,8 @@ func (jq *JobQueue) Enqueue(job *Job) error {
 	case jq.pending <- job:
 		return nil
 	default:
-		return fmt.Errorf("queue is full")
+		_ = jq.deleteJob(job.ID)
+		return ErrQueueFull
 	}
 }
 
@@ -202,6 +259,11 @@ func (jq *JobQueue) Get(jobID string) (*Job, bool) {
 		return job, true
 	}
 
+	// Dead-lettered jobs keep their full state for replay
+	if job, ok := jq.deadLetters[jobID]; ok {
+		return job, true
+	}
+
 	// Check completed jobs
 	if result, ok := jq.completed[jobID]; ok {
 		// Reconstruct job from result
@@ -211,6 +273,7 @@ func (jq *JobQueue) Get(jobID string) (*Job, bool) {
 			Total:  result.Total,
 			Items:  result.Results,
 			Error:  result.Error,
+			Tenant: result.Tenant,
 		}
 		return job, true
 	}
@@ -233,7 +296,8 @@ func (jq *JobQueue) GetResult(jobID string) (*JobResult, bool) {
 	return result, ok
 }
 
-// ListJobs returns all jobs (processing + completed)
+// ListJobs returns all active jobs: queued after a restart, processing or
+// waiting to retry.
 func (jq *JobQueue) ListJobs() []*Job {
 	jq.mu.RLock()
 	defer jq.mu.RUnlock()
@@ -252,13 +316,64 @@ func (jq *JobQueue) Stats() map[string]interface{} {
 	defer jq.mu.RUnlock()
 
 	return map[string]interface{}{
-		"pending":    len(jq.pending),
-		"processing": len(jq.processing),
-		"completed":  len(jq.completed),
-		"workers":    jq.maxWorkers,
+		"pending":     len(jq.pending),
+		"processing":  len(jq.processing) - jq.retrying,
+		"retrying":    jq.retrying,
+		"completed":   len(jq.completed),
+		"dead_letter": len(jq.deadLetters),
+		"workers":     jq.maxWorkers,
 	}
 }
 
+// DeadLetters returns the jobs that exhausted their retries with items still
+// failing, oldest first.
+func (jq *JobQueue) DeadLetters() []*Job {
+	jq.mu.RLock()
+	defer jq.mu.RUnlock()
+
+	jobs := make([]*Job, 0, len(jq.deadLetters))
+	for _, job := range jq.deadLetters {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].DeadLetteredAt.Before(*jobs[j].DeadLetteredAt)
+	})
+	return jobs
+}
+
+// Replay takes a job off the dead-letter list and queues its failed items
+// again with a fresh retry budget. Items that succeeded are not rerun.
+func (jq *JobQueue) Replay(jobID string) (*Job, error) {
+	jq.mu.Lock()
+	job, ok := jq.deadLetters[jobID]
+	if !ok {
+		jq.mu.Unlock()
+		if _, found := jq.Get(jobID); found {
+			return nil, ErrNotDeadLettered
+		}
+		return nil, ErrJobNotFound
+	}
+	delete(jq.deadLetters, jobID)
+	delete(jq.completed, jobID)
+
+	job.resetFailedItems()
+	job.Status = StatusQueued
+	job.RetryCount = 0
+	job.Error = ""
+	job.StartedAt = nil
+	job.CompletedAt = nil
+	job.NextAttemptAt = nil
+	job.DeadLetteredAt = nil
+	jq.processing[jobID] = job
+	jq.mu.Unlock()
+
+	if err := jq.persistJob(job); err != nil {
+		return nil, fmt.Errorf("failed to persist job: %w", err)
+	}
+	jq.schedule(job, 0)
+	return job, nil
+}
+
 // Stop gracefully shuts down the queue
 func (jq *JobQueue) Stop() {
 	close(jq.stopCh)
@@ -279,7 +394,16 @@ func (jq *JobQueue) Stop() {
 	jq.mu.RUnlock()
 }
 
-// persistJob saves a job to disk
+// Hold waits for workers to finish the items they are on and keeps them
+// from starting others, so neither storage nor the persist path changes
+// under a backup. Call release to let them continue. Enqueue is not held.
+func (jq *JobQueue) Hold() (release func()) {
+	jq.hold.Lock()
+	return jq.hold.Unlock
+}
+
+// persistJob saves a job to disk. The file is written to a temporary name,
+// synced and renamed so a crash never leaves a truncated job behind.
 func (jq *JobQueue) persistJob(job *Job) error {
 	data, err := json.MarshalIndent(job, "", "  ")
 	if err != nil {
@@ -287,7 +411,25 @@ func (jq *JobQueue) persistJob(job *Job) error {
 	}
 
 	filename := filepath.Join(jq.persistPath, fmt.Sprintf("%s.json", job.ID))
-	return os.WriteFile(filename, data, 0644)
+	tmp, err := os.CreateTemp(jq.persistPath, job.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
 }
 
 // loadJob loads a job from disk
@@ -312,7 +454,9 @@ func (jq *JobQueue) deleteJob(jobID string) error {
 	return os.Remove(filename)
 }
 
-// restore loads incomplete jobs from disk and re-queues them
+// restore loads jobs from disk. Queued and interrupted jobs are re-queued,
+// retrying jobs wait out the rest of their backoff, and finished jobs
+// repopulate results and the dead-letter list.
 func (jq *JobQueue) restore() error {
 	entries, err := os.ReadDir(jq.persistPath)
 	if err != nil {
@@ -322,8 +466,23 @@ func (jq *JobQueue) restore() error {
 		return err
 	}
 
+	type dispatch struct {
+		job   *Job
+		delay time.Duration
+	}
+	var resumed []dispatch
+
+	jq.mu.Lock()
 	for _, entry := range entries {
-		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+		if entry.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			// Left behind by a crash mid-write; the previous version is intact.
+			_ = os.Remove(filepath.Join(jq.persistPath, entry.Name()))
+			continue
+		}
+		if filepath.Ext(entry.Name()) != ".json" {
 			continue
 		}
 
@@ -333,22 +492,164 @@ func (jq *JobQueue) restore() error {
 			continue // Skip corrupted jobs
 		}
 
-		// Only restore incomplete jobs
-		if job.Status == StatusQueued || job.Status == StatusProcessing {
+		switch job.Status {
+		case StatusQueued, StatusProcessing:
 			job.Status = StatusQueued // Reset to queued
 			job.StartedAt = nil
-			
-			select {
-			case jq.pending <- job:
-			default:
-				// Queue full, skip this job
+			jq.processing[job.ID] = job
+			resumed = append(resumed, dispatch{job: job})
+		case StatusRetrying:
+			delay := time.Duration(0)
+			if job.NextAttemptAt != nil {
+				delay = time.Until(*job.NextAttemptAt)
+			}
+			jq.processing[job.ID] = job
+			jq.retrying++
+			resumed = append(resumed, dispatch{job: job, delay: delay})
+		case StatusCompleted, StatusFailed:
+			jq.completed[job.ID] = job.result()
+			if job.DeadLetteredAt != nil {
+				jq.deadLetters[job.ID] = job
 			}
 		}
 	}
+	jq.mu.Unlock()
 
+	// Hand jobs over only once the maps are complete.
+	for _, d := range resumed {
+		jq.schedule(d.job, d.delay)
+	}
 	return nil
 }
 
+// schedule hands job to the workers after delay, without blocking the caller.
+func (jq *JobQueue) schedule(job *Job, delay time.Duration) {
+	dispatch := func() {
+		select {
+		case jq.pending <- job:
+		case <-jq.stopCh:
+		}
+	}
+	if delay <= 0 {
+		go dispatch()
+		return
+	}
+	time.AfterFunc(delay, dispatch)
+}
+
+// finish records the outcome of an attempt: failed items are retried after
+// a backoff while the job has retries left, otherwise the job completes and,
+// if items still failed, is dead-lettered.
+func (jq *JobQueue) finish(job *Job) {
+	succeeded, failed := job.counts()
+
+	if failed > 0 && job.RetryCount < job.MaxRetries {
+		job.RetryCount++
+		next := time.Now().Add(jq.retry.Delay(job.RetryCount))
+		job.NextAttemptAt = &next
+		job.Error = fmt.Sprintf("%d items failed; retry %d of %d at %s", failed, job.RetryCount, job.MaxRetries, next.Format(time.RFC3339))
+		job.resetFailedItems()
+		jq.mu.Lock()
+		job.Status = StatusRetrying
+		jq.retrying++
+		jq.mu.Unlock()
+		jq.persistJob(job)
+		jq.schedule(job, time.Until(next))
+		return
+	}
+
+	completedAt := time.Now()
+	job.CompletedAt = &completedAt
+	job.NextAttemptAt = nil
+
+	if failed == 0 {
+		job.Status = StatusCompleted
+		job.Error = ""
+	} else if succeeded == 0 {
+		job.Status = StatusFailed
+		job.Error = fmt.Sprintf("all %d items failed", failed)
+	} else {
+		job.Status = StatusCompleted
+		job.Error = fmt.Sprintf("partial success: %d succeeded, %d failed", succeeded, failed)
+	}
+	if failed > 0 {
+		job.DeadLetteredAt = &completedAt
+	}
+
+	// Move to completed
+	jq.mu.Lock()
+	delete(jq.processing, job.ID)
+	jq.completed[job.ID] = job.result()
+	if job.DeadLetteredAt != nil {
+		jq.deadLetters[job.ID] = job
+	}
+	jq.mu.Unlock()
+
+	// Persist final state
+	jq.persistJob(job)
+	if job.DeadLetteredAt == nil {
+		_ = os.RemoveAll(jq.spoolDir(job.ID))
+	}
+}
+
+// SpoolDir returns a directory, created on demand, for item content that
+// must survive a restart (see JobItem.Source). It is removed once the job
+// completes without failures; dead-lettered jobs keep it for replay.
+func (jq *JobQueue) SpoolDir(jobID string) (string, error) {
+	dir := jq.spoolDir(jobID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func (jq *JobQueue) spoolDir(jobID string) string {
+	return filepath.Join(jq.persistPath, "spool", jobID)
+}
+
+// counts returns how many items completed and failed.
+func (job *Job) counts() (succeeded, failed int) {
+	for _, item := range job.Items {
+		switch item.Status {
+		case StatusCompleted:
+			succeeded++
+		case StatusFailed:
+			failed++
+		}
+	}
+	return succeeded, failed
+}
+
+// resetFailedItems queues failed items for another attempt. Their last error
+// stays visible until they are processed again.
+func (job *Job) resetFailedItems() {
+	for i := range job.Items {
+		if job.Items[i].Status == StatusFailed {
+			job.Items[i].Status = StatusQueued
+			job.Progress--
+		}
+	}
+}
+
+// result builds the JobResult of a finished job.
+func (job *Job) result() *JobResult {
+	succeeded, failed := job.counts()
+	result := &JobResult{
+		JobID:     job.ID,
+		Status:    job.Status,
+		Total:     job.Total,
+		Succeeded: succeeded,
+		Failed:    failed,
+		Results:   job.Items,
+		Error:     job.Error,
+		Tenant:    job.Tenant,
+	}
+	if job.StartedAt != nil && job.CompletedAt != nil {
+		result.Duration = job.CompletedAt.Sub(*job.StartedAt)
+	}
+	return result
+}
+
 // Worker implementation
 func (w *Worker) start() {
 	defer w.queue.wg.Done()
@@ -366,29 +667,50 @@ func (w *Worker) start() {
 func (w *Worker) processJob(job *Job) {
 	now := time.Now()
 	job.StartedAt = &now
-	job.Status = StatusProcessing
+	job.NextAttemptAt = nil
+	// Progress is only persisted periodically, so recount after a restart.
+	succeeded, failed := job.counts()
+	job.Progress = succeeded + failed
 
+	w.queue.hold.RLock()
 	// Move to processing map
 	w.queue.mu.Lock()
+	if job.Status == StatusRetrying {
+		w.queue.retrying--
+	}
+	job.Status = StatusProcessing
 	w.queue.processing[job.ID] = job
 	w.queue.mu.Unlock()
 
 	// Persist state
 	w.queue.persistJob(job)
+	w.queue.hold.RUnlock()
 
-	// Process each item
-	succeeded := 0
-	failed := 0
-
+	// Process each item not yet settled in an earlier attempt
 	for i := range job.Items {
 		item := &job.Items[i]
-		
+		if item.Status == StatusCompleted || item.Status == StatusFailed {
+			continue
+		}
+
+		w.queue.hold.RLock()
+		// On shutdown leave the rest for the next boot; the job is
+		// persisted as processing and re-queued by restore.
+		select {
+		case <-w.stopCh:
+			w.queue.persistJob(job)
+			w.queue.hold.RUnlock()
+			return
+		default:
+		}
+
 		err := w.processor.ProcessItem(job, item)
 		if err != nil {
 			item.Error = err.Error()
-			failed++
+			item.Status = StatusFailed
 		} else {
-			succeeded++
+			item.Error = ""
+			item.Status = StatusCompleted
 		}
 
 		job.Progress++
@@ -397,40 +719,10 @@ func (w *Worker) processJob(job *Job) {
 		if job.Progress%10 == 0 {
 			w.queue.persistJob(job)
 		}
+		w.queue.hold.RUnlock()
 	}
 
-	// Complete job
-	completedAt := time.Now()
-	job.CompletedAt = &completedAt
-	
-	if failed == 0 {
-		job.Status = StatusCompleted
-	} else if succeeded == 0 {
-		job.Status = StatusFailed
-		job.Error = fmt.Sprintf("all %d items failed", failed)
-	} else {
-		job.Status = StatusCompleted
-		job.Error = fmt.Sprintf("partial success: %d succeeded, %d failed", succeeded, failed)
-	}
-
-	// Create result
-	result := &JobResult{
-		JobID:     job.ID,
-		Status:    job.Status,
-		Total:     job.Total,
-		Succeeded: succeeded,
-		Failed:    failed,
-		Duration:  completedAt.Sub(*job.StartedAt),
-		Results:   job.Items,
-		Error:     job.Error,
-	}
-
-	// Move to completed
-	w.queue.mu.Lock()
-	delete(w.queue.processing, job.ID)
-	w.queue.completed[job.ID] = result
-	w.queue.mu.Unlock()
-
-	// Persist final state
-	w.queue.persistJob(job)
+	w.queue.hold.RLock()
+	w.queue.finish(job)
+	w.queue.hold.RUnlock()
 }
@@ -1,10 +1,16 @@
 package queue
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/retry"
 )
 
 // MockProcessor is a simple processor for testing
@@ -406,3 +412,236 @@ func BenchmarkJobQueue(b *testing.B) {
 	// Wait for all jobs to complete
 	time.Sleep(time.Duration(b.N/10) * time.Millisecond)
 }
+
+// FlakyProcessor fails each item until it has been attempted failures times.
+type FlakyProcessor struct {
+	mu       sync.Mutex
+	failures map[string]int
+	attempts map[string]int
+}
+
+func NewFlakyProcessor() *FlakyProcessor {
+	return &FlakyProcessor{failures: make(map[string]int), attempts: make(map[string]int)}
+}
+
+func (fp *FlakyProcessor) ProcessItem(job *Job, item *JobItem) error {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	fp.attempts[item.ID]++
+	if fp.attempts[item.ID] <= fp.failures[item.ID] {
+		return fmt.Errorf("attempt %d of %s failed", fp.attempts[item.ID], item.ID)
+	}
+	return nil
+}
+
+func (fp *FlakyProcessor) Attempts(itemID string) int {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	return fp.attempts[itemID]
+}
+
+func (fp *FlakyProcessor) SetFailures(itemID string, n int) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	fp.failures[itemID] = n
+}
+
+func fastRetry() retry.Config {
+	return retry.Config{InitialDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond, Multiplier: 2}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobQueueRetriesFailedItemsWithBackoff(t *testing.T) {
+	processor := NewFlakyProcessor()
+	processor.SetFailures("item2", 2)
+
+	queue, err := New(Config{MaxWorkers: 1, PersistPath: t.TempDir(), MaxRetries: 3, Retry: fastRetry()}, processor)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queue.Stop()
+
+	job := &Job{Type: JobTypeMedia, Items: []JobItem{{ID: "item1"}, {ID: "item2"}}}
+	if err := queue.Enqueue(job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	waitFor(t, "job result", func() bool { _, ok := queue.GetResult(job.ID); return ok })
+	result, _ := queue.GetResult(job.ID)
+	if result.Status != StatusCompleted || result.Succeeded != 2 || result.Failed != 0 || result.Error != "" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if processor.Attempts("item1") != 1 || processor.Attempts("item2") != 3 {
+		t.Fatalf("expected only the failing item to be retried, got %d and %d attempts",
+			processor.Attempts("item1"), processor.Attempts("item2"))
+	}
+	if len(queue.DeadLetters()) != 0 {
+		t.Fatal("a job that eventually succeeded must not be dead-lettered")
+	}
+}
+
+func TestJobQueueDeadLetterSurvivesRestartAndReplays(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{MaxWorkers: 1, PersistPath: dir, MaxRetries: 1, Retry: fastRetry()}
+
+	processor := NewFlakyProcessor()
+	processor.SetFailures("bad", 100)
+	queue1, err := New(cfg, processor)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	job := &Job{Type: JobTypeMedia, Items: []JobItem{{ID: "good"}, {ID: "bad"}}}
+	if err := queue1.Enqueue(job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	waitFor(t, "dead letter", func() bool { return len(queue1.DeadLetters()) == 1 })
+	if processor.Attempts("bad") != 2 {
+		t.Fatalf("expected 2 attempts before dead-lettering, got %d", processor.Attempts("bad"))
+	}
+	queue1.Stop()
+
+	// After a restart the dead letter is still listed and can be replayed;
+	// only the failed item runs again.
+	processor2 := NewFlakyProcessor()
+	queue2, err := New(cfg, processor2)
+	if err != nil {
+		t.Fatalf("Failed to create second queue: %v", err)
+	}
+	defer queue2.Stop()
+
+	dead := queue2.DeadLetters()
+	if len(dead) != 1 || dead[0].ID != job.ID || dead[0].RetryCount != 1 {
+		t.Fatalf("unexpected dead letters after restart: %+v", dead)
+	}
+	if _, err := queue2.Replay("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+	if _, err := queue2.Replay(job.ID); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if _, err := queue2.Replay(job.ID); !errors.Is(err, ErrNotDeadLettered) {
+		t.Fatalf("expected ErrNotDeadLettered on second replay, got %v", err)
+	}
+
+	waitFor(t, "replayed result", func() bool { _, ok := queue2.GetResult(job.ID); return ok })
+	result, _ := queue2.GetResult(job.ID)
+	if result.Succeeded != 2 || result.Failed != 0 {
+		t.Fatalf("unexpected replay result %+v", result)
+	}
+	if processor2.Attempts("good") != 0 || processor2.Attempts("bad") != 1 {
+		t.Fatalf("replay reran the wrong items: good=%d bad=%d", processor2.Attempts("good"), processor2.Attempts("bad"))
+	}
+	if len(queue2.DeadLetters()) != 0 {
+		t.Fatal("replayed job should leave the dead-letter list")
+	}
+}
+
+func TestJobQueueResumesInterruptedJob(t *testing.T) {
+	dir := t.TempDir()
+	started := time.Now().Add(-time.Minute)
+	interrupted := Job{
+		ID:        "crashed",
+		Type:      JobTypeMedia,
+		Status:    StatusProcessing,
+		Total:     3,
+		Progress:  1,
+		CreatedAt: started,
+		StartedAt: &started,
+		Items: []JobItem{
+			{ID: "done", Status: StatusCompleted},
+			{ID: "next", Status: StatusQueued},
+			{ID: "last", Status: StatusQueued},
+		},
+	}
+	data, _ := json.Marshal(interrupted)
+	if err := os.WriteFile(filepath.Join(dir, "crashed.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A write torn by the crash is discarded on boot.
+	if err := os.WriteFile(filepath.Join(dir, "crashed.123.tmp"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	processor := NewFlakyProcessor()
+	queue, err := New(Config{MaxWorkers: 1, PersistPath: dir}, processor)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queue.Stop()
+
+	waitFor(t, "resumed result", func() bool { _, ok := queue.GetResult("crashed"); return ok })
+	if processor.Attempts("done") != 0 || processor.Attempts("next") != 1 || processor.Attempts("last") != 1 {
+		t.Fatal("expected only unfinished items to be processed after resume")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "crashed.123.tmp")); !os.IsNotExist(err) {
+		t.Fatalf("expected torn temp file to be removed, got %v", err)
+	}
+	job, _ := queue.loadJob("crashed")
+	if job.Status != StatusCompleted || job.Progress != 3 {
+		t.Fatalf("unexpected persisted job %+v", job)
+	}
+}
+
+func TestJobQueueHoldPausesWorkers(t *testing.T) {
+	processor := NewMockProcessor()
+	queue, err := New(Config{MaxWorkers: 1, PersistPath: t.TempDir()}, processor)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queue.Stop()
+
+	release := queue.Hold()
+	job := &Job{Type: JobTypeMedia, Items: []JobItem{{ID: "held"}}}
+	if err := queue.Enqueue(job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if processor.GetProcessedCount() != 0 {
+		t.Fatal("expected no items to be processed while held")
+	}
+
+	release()
+	waitFor(t, "result after release", func() bool { _, ok := queue.GetResult(job.ID); return ok })
+}
+
+func TestJobQueueRestoresManyJobsWhileWorkersRun(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 50; i++ {
+		job := Job{
+			ID:        fmt.Sprintf("job-%02d", i),
+			Type:      JobTypeMedia,
+			Status:    StatusQueued,
+			Total:     1,
+			CreatedAt: time.Now(),
+			Items:     []JobItem{{ID: fmt.Sprintf("item-%02d", i), Status: StatusQueued}},
+		}
+		data, _ := json.Marshal(job)
+		if err := os.WriteFile(filepath.Join(dir, job.ID+".json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	queue, err := New(Config{MaxWorkers: 8, PersistPath: dir}, NewMockProcessor())
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	defer queue.Stop()
+	waitFor(t, "all restored jobs", func() bool {
+		for i := 0; i < 50; i++ {
+			if _, ok := queue.GetResult(fmt.Sprintf("job-%02d", i)); !ok {
+				return false
+			}
+		}
+		return true
+	})
+}
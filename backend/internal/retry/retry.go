@@ -78,6 +78,11 @@ func DoWithContext(ctx context.Context, op OperationWithContext, cfg Config) err
 	return fmt.Errorf("operation failed after %d attempts: %w", cfg.MaxAttempts, lastErr)
 }
 
+// Delay returns the backoff to wait after the given failed attempt (1-based).
+func (c Config) Delay(attempt int) time.Duration {
+	return calculateDelay(attempt, c)
+}
+
 // calculateDelay computes the exponential backoff delay for a given attempt.
 func calculateDelay(attempt int, cfg Config) time.Duration {
 	// Exponential backoff: initialDelay * (multiplier ^ (attempt - 1))
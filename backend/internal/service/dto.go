@@ -80,6 +80,7 @@ type FileDeleteResponse struct {
 	OriginalName string    `json:"original_name"`
 	StoredPath   string    `json:"stored_path"`
 	Deleted      bool      `json:"deleted"`
+	Trashed      bool      `json:"trashed"`
 	DeletedAt    time.Time `json:"deleted_at"`
 	Message      string    `json:"message,omitempty"`
 }
@@ -164,6 +164,7 @@ func (s *FileService) DeleteFile(req FileDeleteRequest) (*FileDeleteResponse, er
 		OriginalName: result.OriginalName,
 		StoredPath:   result.StoredPath,
 		Deleted:      result.Deleted,
+		Trashed:      result.Trashed,
 		DeletedAt:    result.DeletedAt,
 		Message:      result.Message,
 	}, nil
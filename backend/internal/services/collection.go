@@ -28,6 +28,11 @@ type CollectionService struct {
 	cacheTTL  time.Duration
 	statsMu   sync.RWMutex
 	statsCache map[string]*CachedStats
+
+	// Smart collections are saved searches, run through evaluate.
+	evaluate   SmartEvaluator
+	smartMu    sync.Mutex
+	smartCache map[string]*cachedSmart
 }
 
 // CachedStats holds cached collection statistics with expiration.
@@ -56,6 +61,9 @@ type CollectionsResponse struct {
 	Collections []CollectionDTO `json:"collections"`
 	Total       int             `json:"total"`
 	GeneratedAt string          `json:"generated_at"`
+	// SmartCollections are the saved searches; they are filled in per
+	// request and never cached with the rest of the response.
+	SmartCollections []SmartCollectionDTO `json:"smart_collections,omitempty"`
 }
 
 // CollectionStatsResponse represents the response for collection statistics.
@@ -93,6 +101,7 @@ func NewCollectionService(storage *storage.Manager, cache *cache.Cache, logger *
 		logger:     logger,
 		cacheTTL:   5 * time.Minute,
 		statsCache: make(map[string]*CachedStats),
+		smartCache: make(map[string]*cachedSmart),
 	}
 }
 
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/storage"
+)
+
+// smartCollectionType is the CollectionStats type of smart collections.
+const smartCollectionType = "smart"
+
+// SmartEvaluator runs a saved search and returns the files it matches.
+type SmartEvaluator func(storage.SavedSearch) ([]storage.FileMetadata, error)
+
+// SmartCollectionDTO presents a saved search as a collection. Stats are
+// cached; NewSinceLastViewed counts the matching files uploaded after the
+// viewer last listed the collection's files.
+type SmartCollectionDTO struct {
+	ID                 string            `json:"id"`
+	DisplayName        string            `json:"display_name"`
+	Description        string            `json:"description,omitempty"`
+	Params             map[string]string `json:"params"`
+	SortBy             string            `json:"sort_by,omitempty"`
+	Order              string            `json:"order,omitempty"`
+	Stats              CollectionStats   `json:"stats"`
+	NewSinceLastViewed int               `json:"new_since_last_viewed"`
+	LastViewedAt       *time.Time        `json:"last_viewed_at,omitempty"`
+}
+
+// cachedSmart is the cached evaluation of one saved search.
+type cachedSmart struct {
+	stats CollectionStats
+	// uploaded holds the upload times of the matching files, ascending.
+	uploaded []time.Time
+	// version is the UpdatedAt of the saved search that was evaluated.
+	version   time.Time
+	expiresAt time.Time
+}
+
+// SetSmartEvaluator sets how saved searches are run. Until it is set, smart
+// collections cannot be listed.
+func (s *CollectionService) SetSmartEvaluator(evaluate SmartEvaluator) {
+	s.smartMu.Lock()
+	defer s.smartMu.Unlock()
+	s.evaluate = evaluate
+}
+
+// GetSmartCollections returns every saved search as a smart collection,
+// with the new-file counts of viewer.
+func (s *CollectionService) GetSmartCollections(viewer string) ([]SmartCollectionDTO, error) {
+	searches, err := s.storage.ListSavedSearches()
+	if err != nil {
+		return nil, err
+	}
+	collections := make([]SmartCollectionDTO, 0, len(searches))
+	for _, saved := range searches {
+		dto, err := s.smartCollection(saved, viewer)
+		if err != nil {
+			return nil, fmt.Errorf("smart collection %s: %w", saved.ID, err)
+		}
+		collections = append(collections, dto)
+	}
+	return collections, nil
+}
+
+// GetSmartCollection returns the saved search with id as a smart collection.
+func (s *CollectionService) GetSmartCollection(id, viewer string) (*SmartCollectionDTO, error) {
+	saved, err := s.storage.GetSavedSearch(id)
+	if err != nil {
+		return nil, err
+	}
+	dto, err := s.smartCollection(*saved, viewer)
+	if err != nil {
+		return nil, err
+	}
+	return &dto, nil
+}
+
+// RefreshSmartCollection replaces the cached evaluation of saved with files,
+// the result of a live evaluation.
+func (s *CollectionService) RefreshSmartCollection(saved storage.SavedSearch, files []storage.FileMetadata) {
+	entry := s.summarize(saved, files)
+	s.smartMu.Lock()
+	defer s.smartMu.Unlock()
+	s.smartCache[saved.ID] = entry
+}
+
+// InvalidateSmartCollection drops the cached evaluation of a saved search,
+// or of all saved searches when id is empty.
+func (s *CollectionService) InvalidateSmartCollection(id string) {
+	s.smartMu.Lock()
+	defer s.smartMu.Unlock()
+	if id == "" {
+		s.smartCache = make(map[string]*cachedSmart)
+		return
+	}
+	delete(s.smartCache, id)
+}
+
+func (s *CollectionService) smartCollection(saved storage.SavedSearch, viewer string) (SmartCollectionDTO, error) {
+	entry, err := s.smartStats(saved)
+	if err != nil {
+		return SmartCollectionDTO{}, err
+	}
+	dto := SmartCollectionDTO{
+		ID:          saved.ID,
+		DisplayName: saved.Name,
+		Description: saved.Description,
+		Params:      saved.Params,
+		SortBy:      saved.SortBy,
+		Order:       saved.Order,
+		Stats:       entry.stats,
+	}
+	viewedAt, err := s.storage.SavedSearchViewedAt(saved.ID, viewer)
+	if err != nil {
+		return SmartCollectionDTO{}, err
+	}
+	if viewedAt.IsZero() {
+		dto.NewSinceLastViewed = len(entry.uploaded)
+	} else {
+		dto.LastViewedAt = &viewedAt
+		first := sort.Search(len(entry.uploaded), func(i int) bool { return entry.uploaded[i].After(viewedAt) })
+		dto.NewSinceLastViewed = len(entry.uploaded) - first
+	}
+	return dto, nil
+}
+
+// smartStats returns the cached evaluation of saved, evaluating it again
+// when the cache entry expired or the saved search changed.
+func (s *CollectionService) smartStats(saved storage.SavedSearch) (*cachedSmart, error) {
+	s.smartMu.Lock()
+	entry, ok := s.smartCache[saved.ID]
+	evaluate := s.evaluate
+	s.smartMu.Unlock()
+	if ok && entry.version.Equal(saved.UpdatedAt) && time.Now().Before(entry.expiresAt) {
+		return entry, nil
+	}
+	if evaluate == nil {
+		return nil, errors.New("smart collections are not configured")
+	}
+
+	files, err := evaluate(saved)
+	if err != nil {
+		return nil, err
+	}
+	entry = s.summarize(saved, files)
+	s.smartMu.Lock()
+	s.smartCache[saved.ID] = entry
+	s.smartMu.Unlock()
+	return entry, nil
+}
+
+func (s *CollectionService) summarize(saved storage.SavedSearch, files []storage.FileMetadata) *cachedSmart {
+	uploaded := make([]time.Time, len(files))
+	for i, meta := range files {
+		uploaded[i] = meta.UploadedAt
+	}
+	sort.Slice(uploaded, func(i, j int) bool { return uploaded[i].Before(uploaded[j]) })
+	return &cachedSmart{
+		stats:     s.calculateStats(smartCollectionType, files),
+		uploaded:  uploaded,
+		version:   saved.UpdatedAt,
+		expiresAt: time.Now().Add(s.cacheTTL),
+	}
+}
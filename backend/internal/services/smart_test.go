@@ -0,0 +1,71 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/storage"
+)
+
+func TestSmartCollections(t *testing.T) {
+	service, store := setupTestService(t)
+	evaluations := 0
+	service.SetSmartEvaluator(func(saved storage.SavedSearch) ([]storage.FileMetadata, error) {
+		evaluations++
+		return store.SearchFiles(storage.SearchFilters{Extension: saved.Params["extension"]}), nil
+	})
+	addTestFile(t, store, "one.txt", "text/plain", "", 100)
+	addTestFile(t, store, "two.txt", "text/plain", "", 200)
+	addTestFile(t, store, "photo.jpg", "image/jpeg", "", 300)
+
+	saved, err := store.CreateSavedSearch(storage.SavedSearch{Name: "Text", Params: map[string]string{"extension": "txt"}})
+	if err != nil {
+		t.Fatalf("create saved search: %v", err)
+	}
+	collections, err := service.GetSmartCollections("alice")
+	if err != nil || len(collections) != 1 {
+		t.Fatalf("smart collections: %+v %v", collections, err)
+	}
+	smart := collections[0]
+	if smart.Stats.FileCount != 2 || smart.Stats.StorageUsed != 300 || smart.Stats.Type != smartCollectionType {
+		t.Fatalf("stats = %+v", smart.Stats)
+	}
+	if smart.NewSinceLastViewed != 2 || smart.LastViewedAt != nil {
+		t.Fatalf("expected every file to be new before the first view, got %+v", smart)
+	}
+
+	if _, err := store.MarkSavedSearchViewed(saved.ID, "alice", time.Now()); err != nil {
+		t.Fatalf("mark viewed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	three := addTestFile(t, store, "three.txt", "text/plain", "", 400)
+
+	// The cached evaluation is reused until it is invalidated.
+	if got, _ := service.GetSmartCollection(saved.ID, "alice"); got.Stats.FileCount != 2 || got.NewSinceLastViewed != 0 || evaluations != 1 {
+		t.Fatalf("expected the cached evaluation, got %+v after %d evaluations", got, evaluations)
+	}
+	service.InvalidateSmartCollection("")
+	got, err := service.GetSmartCollection(saved.ID, "alice")
+	if err != nil || got.Stats.FileCount != 3 || got.NewSinceLastViewed != 1 || got.LastViewedAt == nil {
+		t.Fatalf("after upload: %+v %v", got, err)
+	}
+	if got, _ := service.GetSmartCollection(saved.ID, "bob"); got.NewSinceLastViewed != 3 {
+		t.Fatalf("expected deltas per viewer, got %d", got.NewSinceLastViewed)
+	}
+
+	// Changing the saved search invalidates its cached evaluation.
+	ext := map[string]string{"extension": "jpg"}
+	updated, err := store.UpdateSavedSearch(saved.ID, storage.UpdateSavedSearchRequest{Params: ext})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, _ := service.GetSmartCollection(saved.ID, "alice"); got.Stats.FileCount != 1 || got.Stats.StorageUsed != 300 {
+		t.Fatalf("expected the updated search to be evaluated, got %+v", got.Stats)
+	}
+
+	// A live evaluation replaces the cached one.
+	service.RefreshSmartCollection(*updated, []storage.FileMetadata{three})
+	if got, _ := service.GetSmartCollection(saved.ID, "alice"); got.Stats.StorageUsed != 400 || evaluations != 3 {
+		t.Fatalf("refresh: %+v", got.Stats)
+	}
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrInvalidArchive is returned for archive requests with an unknown format.
+var ErrInvalidArchive = errors.New("invalid archive request")
+
+// ArchiveFormat is the container format of a streamed archive.
+type ArchiveFormat string
+
+const (
+	ArchiveZip   ArchiveFormat = "zip"
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+
+	// archiveManifestName is the entry holding the manifest, when requested.
+	archiveManifestName = "manifest.json"
+)
+
+// ParseArchiveFormat parses a format name; empty means zip.
+func ParseArchiveFormat(value string) (ArchiveFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "zip":
+		return ArchiveZip, nil
+	case "tar.gz", "tgz":
+		return ArchiveTarGz, nil
+	}
+	return "", fmt.Errorf("%w: unsupported format %q, expected zip or tar.gz", ErrInvalidArchive, value)
+}
+
+// ContentType returns the MIME type of archives in the format.
+func (f ArchiveFormat) ContentType() string {
+	if f == ArchiveTarGz {
+		return "application/gzip"
+	}
+	return "application/zip"
+}
+
+// Extension returns the file extension of archives in the format.
+func (f ArchiveFormat) Extension() string {
+	return "." + string(f)
+}
+
+// ArchiveOptions controls how WriteArchive packs files.
+type ArchiveOptions struct {
+	Format ArchiveFormat
+	// Manifest adds a manifest.json entry describing every packed file.
+	Manifest bool
+}
+
+// ArchiveManifest is the manifest.json entry of an archive.
+type ArchiveManifest struct {
+	CreatedAt time.Time              `json:"created_at"`
+	Count     int                    `json:"count"`
+	Files     []ArchiveManifestEntry `json:"files"`
+	// Missing lists the hashes whose blobs could not be read.
+	Missing []string `json:"missing,omitempty"`
+}
+
+// ArchiveManifestEntry is the metadata of one packed file and its name
+// inside the archive.
+type ArchiveManifestEntry struct {
+	ArchivePath string `json:"archive_path"`
+	FileMetadata
+}
+
+// ArchiveSummary reports what WriteArchive packed.
+type ArchiveSummary struct {
+	Files   int
+	Bytes   int64
+	Missing []string
+}
+
+// archiveWriter adds entries to a zip or tar.gz stream.
+type archiveWriter interface {
+	create(name string, size int64, modified time.Time, compress bool) (io.Writer, error)
+	Close() error
+}
+
+type zipArchiveWriter struct{ zw *zip.Writer }
+
+func (a *zipArchiveWriter) create(name string, _ int64, modified time.Time, compress bool) (io.Writer, error) {
+	method := zip.Store
+	if compress {
+		method = zip.Deflate
+	}
+	return a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modified})
+}
+
+func (a *zipArchiveWriter) Close() error { return a.zw.Close() }
+
+type tarGzArchiveWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (a *tarGzArchiveWriter) create(name string, size int64, modified time.Time, _ bool) (io.Writer, error) {
+	err := a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     size,
+		ModTime:  modified,
+		Format:   tar.FormatPAX,
+	})
+	return a.tw, err
+}
+
+func (a *tarGzArchiveWriter) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
+
+func newArchiveWriter(w io.Writer, format ArchiveFormat) archiveWriter {
+	if format == ArchiveTarGz {
+		gz := gzip.NewWriter(w)
+		return &tarGzArchiveWriter{gz: gz, tw: tar.NewWriter(gz)}
+	}
+	return &zipArchiveWriter{zw: zip.NewWriter(w)}
+}
+
+// WriteArchive streams files into w as one archive, reading one blob at a
+// time so memory use does not grow with the archive. Entries are named
+// after the original file names; clashing names get a " (2)", " (3)"
+// suffix. Files whose blobs are gone are skipped and reported as missing.
+// Once writing has started an error leaves w with a truncated archive.
+func (m *Manager) WriteArchive(w io.Writer, files []FileMetadata, opts ArchiveOptions) (*ArchiveSummary, error) {
+	if opts.Format == "" {
+		opts.Format = ArchiveZip
+	}
+	if opts.Format != ArchiveZip && opts.Format != ArchiveTarGz {
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidArchive, opts.Format)
+	}
+
+	aw := newArchiveWriter(w, opts.Format)
+	names := newArchiveNames()
+	if opts.Manifest {
+		names.reserve(archiveManifestName)
+	}
+	summary := &ArchiveSummary{}
+	manifest := ArchiveManifest{CreatedAt: time.Now().UTC(), Files: make([]ArchiveManifestEntry, 0, len(files))}
+	buf := make([]byte, 256*1024)
+
+	for _, meta := range files {
+		result, err := m.getFileByMetadata(meta)
+		if errors.Is(err, ErrFileNotFound) {
+			summary.Missing = append(summary.Missing, meta.Hash)
+			continue
+		}
+		if err != nil {
+			return summary, err
+		}
+		name := names.next(meta.OriginalName, meta.Hash)
+		entry, err := aw.create(name, result.Size, meta.UploadedAt, archiveCompressible(meta.MimeType))
+		if err == nil {
+			_, err = io.CopyBuffer(entry, result.Reader, buf)
+		}
+		result.Reader.Close()
+		if err != nil {
+			return summary, fmt.Errorf("write %s: %w", name, err)
+		}
+		summary.Files++
+		summary.Bytes += result.Size
+		manifest.Files = append(manifest.Files, ArchiveManifestEntry{ArchivePath: name, FileMetadata: meta})
+	}
+
+	if opts.Manifest {
+		manifest.Count = len(manifest.Files)
+		manifest.Missing = summary.Missing
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return summary, err
+		}
+		entry, err := aw.create(archiveManifestName, int64(len(data)), manifest.CreatedAt, true)
+		if err != nil {
+			return summary, err
+		}
+		if _, err := entry.Write(data); err != nil {
+			return summary, err
+		}
+	}
+	return summary, aw.Close()
+}
+
+// archiveCompressible reports whether a file of mimeType is worth
+// deflating; media and archives are already compressed.
+func archiveCompressible(mimeType string) bool {
+	for _, prefix := range []string{"image/", "video/", "audio/"} {
+		if strings.HasPrefix(mimeType, prefix) && mimeType != "image/svg+xml" && mimeType != "image/bmp" {
+			return false
+		}
+	}
+	switch mimeType {
+	case "application/zip", "application/gzip", "application/x-gzip", "application/x-7z-compressed",
+		"application/x-rar-compressed", "application/vnd.rar", "application/x-xz", "application/zstd", "application/x-bzip2":
+		return false
+	}
+	return true
+}
+
+// archiveNames hands out unique entry names, comparing case-insensitively
+// so archives extract cleanly on case-insensitive file systems.
+type archiveNames struct {
+	used map[string]bool
+}
+
+func newArchiveNames() *archiveNames {
+	return &archiveNames{used: make(map[string]bool)}
+}
+
+func (n *archiveNames) reserve(name string) {
+	n.used[strings.ToLower(name)] = true
+}
+
+// next returns a unique entry name for original, a file name that may
+// carry directories or be empty, falling back to hash.
+func (n *archiveNames) next(original, hash string) string {
+	name := path.Base(strings.ReplaceAll(original, "\\", "/"))
+	if name == "." || name == "/" || name == ".." || name == "" {
+		name = hash
+	}
+	candidate := name
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 2; n.used[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", stem, i, ext)
+	}
+	n.reserve(candidate)
+	return candidate
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrArchiveEntryNotFound is returned when a stored archive has no file at
+// the requested path.
+var ErrArchiveEntryNotFound = errors.New("archive entry not found")
+
+// ArchiveEntry describes one member of a stored archive.
+type ArchiveEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// CompressedSize is only known for zip members.
+	CompressedSize int64     `json:"compressed_size,omitempty"`
+	Modified       time.Time `json:"modified"`
+	IsDir          bool      `json:"is_dir,omitempty"`
+	// Type is "file", "dir", "symlink" or "other".
+	Type string `json:"type"`
+}
+
+// ArchiveListing is one page of the table of contents of a stored archive.
+// Total and TotalSize cover every member read, not just the page.
+type ArchiveListing struct {
+	Archive   FileMetadata   `json:"archive"`
+	Format    ArchiveFormat  `json:"format"`
+	Entries   []ArchiveEntry `json:"entries"`
+	Count     int            `json:"count"`
+	Total     int            `json:"total"`
+	TotalSize int64          `json:"total_size"`
+	// Truncated is set when the archive has more than MaxEntries members;
+	// the rest are neither listed nor counted.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ArchiveListOptions selects the page ListArchiveEntries returns.
+type ArchiveListOptions struct {
+	Offset int
+	// Limit bounds the entries returned; zero returns every entry read.
+	Limit int
+	// MaxEntries bounds how many members are read at all, so archives of
+	// millions of empty members cannot exhaust memory. Zero falls back to
+	// DefaultArchiveLimits.MaxEntries.
+	MaxEntries int
+}
+
+// ArchiveEntryReader streams one member of a stored archive. Closing it
+// closes the archive blob too.
+type ArchiveEntryReader struct {
+	io.Reader
+	Entry   ArchiveEntry
+	Archive FileMetadata
+	closers []io.Closer
+}
+
+// Close releases the member and the archive blob.
+func (r *ArchiveEntryReader) Close() error {
+	var firstErr error
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if err := r.closers[i].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ListArchiveEntries lists a page of the members of the zip, tar, tar.gz or
+// tar.zst file with hash without extracting it. Zip archives are read
+// through their central directory and plain tar archives by seeking from
+// header to header; compressed tar streams have to be read through. Reading
+// stops after opts.MaxEntries members.
+func (m *Manager) ListArchiveEntries(hash string, opts ArchiveListOptions) (*ArchiveListing, error) {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultArchiveLimits.MaxEntries
+	}
+	blob, format, err := m.openStoredArchive(hash)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Reader.Close()
+
+	listing := &ArchiveListing{Archive: blob.Metadata, Format: format, Entries: make([]ArchiveEntry, 0)}
+	// add records one member and reports whether another may be read.
+	add := func(entry ArchiveEntry) bool {
+		if listing.Total == opts.MaxEntries {
+			listing.Truncated = true
+			return false
+		}
+		if listing.Total >= opts.Offset && (opts.Limit <= 0 || len(listing.Entries) < opts.Limit) {
+			listing.Entries = append(listing.Entries, entry)
+		}
+		listing.Total++
+		if !entry.IsDir {
+			listing.TotalSize += entry.Size
+		}
+		return true
+	}
+	ra := archiveReaderAt(blob.Reader)
+	if format == ArchiveZip {
+		zr, err := zip.NewReader(ra, blob.Size)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedArchive, err)
+		}
+		for _, f := range zr.File {
+			if !add(zipArchiveEntry(f)) {
+				break
+			}
+		}
+	} else {
+		tr, closer, err := openTarStream(ra, blob.Size, format)
+		if err != nil {
+			return nil, err
+		}
+		defer closer.Close()
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrUnsupportedArchive, err)
+			}
+			if hdr.Typeflag == tar.TypeXGlobalHeader {
+				continue
+			}
+			if !add(tarArchiveEntry(hdr)) {
+				break
+			}
+		}
+	}
+	listing.Count = len(listing.Entries)
+	return listing, nil
+}
+
+// OpenArchiveEntry opens the file at entryPath inside the archive with hash.
+// Zip members are opened directly from the central directory; tar members
+// are found by walking the headers. The caller must close the reader.
+func (m *Manager) OpenArchiveEntry(hash, entryPath string) (*ArchiveEntryReader, error) {
+	want, ok := cleanArchivePath(entryPath)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPath, entryPath)
+	}
+	blob, format, err := m.openStoredArchive(hash)
+	if err != nil {
+		return nil, err
+	}
+	result := &ArchiveEntryReader{Archive: blob.Metadata, closers: []io.Closer{blob.Reader}}
+	fail := func(err error) (*ArchiveEntryReader, error) {
+		result.Close()
+		return nil, err
+	}
+
+	ra := archiveReaderAt(blob.Reader)
+	if format == ArchiveZip {
+		zr, err := zip.NewReader(ra, blob.Size)
+		if err != nil {
+			return fail(fmt.Errorf("%w: %v", ErrUnsupportedArchive, err))
+		}
+		for _, f := range zr.File {
+			if name, ok := cleanArchivePath(f.Name); !ok || name != want || !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fail(err)
+			}
+			result.Reader, result.Entry = rc, zipArchiveEntry(f)
+			result.closers = append(result.closers, rc)
+			return result, nil
+		}
+		return fail(fmt.Errorf("%w: %s", ErrArchiveEntryNotFound, entryPath))
+	}
+
+	tr, closer, err := openTarStream(ra, blob.Size, format)
+	if err != nil {
+		return fail(err)
+	}
+	result.closers = append(result.closers, closer)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fail(fmt.Errorf("%w: %s", ErrArchiveEntryNotFound, entryPath))
+		}
+		if err != nil {
+			return fail(fmt.Errorf("%w: %v", ErrUnsupportedArchive, err))
+		}
+		if name, ok := cleanArchivePath(hdr.Name); ok && name == want && hdr.Typeflag == tar.TypeReg {
+			result.Reader, result.Entry = tr, tarArchiveEntry(hdr)
+			return result, nil
+		}
+	}
+}
+
+// openStoredArchive opens the blob of hash and identifies its format.
+func (m *Manager) openStoredArchive(hash string) (*FileRetrievalResult, ArchiveFormat, error) {
+	blob, err := m.GetFileByHash(hash)
+	if err != nil {
+		return nil, "", err
+	}
+	format, ok := DetectArchiveFormat(archiveReaderAt(blob.Reader))
+	if !ok {
+		blob.Reader.Close()
+		return nil, "", fmt.Errorf("%w: %s is not a zip, tar, tar.gz or tar.zst archive", ErrUnsupportedArchive, blob.Metadata.OriginalName)
+	}
+	return blob, format, nil
+}
+
+// openTarStream returns a tar reader over a tar, tar.gz or tar.zst blob.
+// Plain tar reads through a section reader, which tar uses to seek past
+// member data instead of reading it.
+func openTarStream(ra io.ReaderAt, size int64, format ArchiveFormat) (*tar.Reader, io.Closer, error) {
+	section := io.NewSectionReader(ra, 0, size)
+	switch format {
+	case ArchiveTarGz:
+		gz, err := gzip.NewReader(section)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedArchive, err)
+		}
+		return tar.NewReader(gz), gz, nil
+	case ArchiveTarZst:
+		zr, err := zstd.NewReader(section, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedArchive, err)
+		}
+		return tar.NewReader(zr), zstdCloser{zr}, nil
+	}
+	return tar.NewReader(section), io.NopCloser(section), nil
+}
+
+// zstdCloser adapts zstd.Decoder, whose Close returns nothing.
+type zstdCloser struct{ d *zstd.Decoder }
+
+func (c zstdCloser) Close() error {
+	c.d.Close()
+	return nil
+}
+
+func zipArchiveEntry(f *zip.File) ArchiveEntry {
+	entry := ArchiveEntry{
+		Path:           f.Name,
+		Size:           int64(f.UncompressedSize64),
+		CompressedSize: int64(f.CompressedSize64),
+		Modified:       f.Modified.UTC(),
+		Type:           "file",
+	}
+	switch mode := f.Mode(); {
+	case mode.IsDir():
+		entry.IsDir, entry.Type, entry.Size = true, "dir", 0
+	case mode&fs.ModeSymlink != 0:
+		entry.Type = "symlink"
+	case !mode.IsRegular():
+		entry.Type = "other"
+	}
+	return entry
+}
+
+func tarArchiveEntry(hdr *tar.Header) ArchiveEntry {
+	entry := ArchiveEntry{Path: hdr.Name, Size: hdr.Size, Modified: hdr.ModTime.UTC(), Type: "other"}
+	switch hdr.Typeflag {
+	case tar.TypeReg:
+		entry.Type = "file"
+	case tar.TypeDir:
+		entry.IsDir, entry.Type, entry.Size = true, "dir", 0
+	case tar.TypeSymlink, tar.TypeLink:
+		entry.Type = "symlink"
+	}
+	return entry
+}
+
+// archiveReaderAt gives random access to a blob. Local files support it
+// directly; other backends are read by seeking.
+func archiveReaderAt(r BlobReader) io.ReaderAt {
+	if ra, ok := r.(io.ReaderAt); ok {
+		return ra
+	}
+	return &blobReaderAt{r: r}
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestListArchiveEntries(t *testing.T) {
+	m := newTestManager(t)
+	zipped := storeContent(t, m, "bundle.zip", "application/zip", string(buildZip(t,
+		archiveMember{"docs/", ""},
+		archiveMember{"docs/readme.txt", "read me"},
+		archiveMember{"logo.png", "\x89PNG logo"},
+	)))
+	tarred := storeContent(t, m, "bundle.tar.gz", "application/gzip", string(gzipBytes(t, buildTar(t,
+		archiveMember{"a.txt", "alpha"},
+		archiveMember{"b/c.txt", "gamma!"},
+	))))
+
+	listing, err := m.ListArchiveEntries(zipped.Hash, ArchiveListOptions{})
+	if err != nil {
+		t.Fatalf("list zip: %v", err)
+	}
+	if listing.Format != ArchiveZip || listing.Count != 3 || listing.TotalSize != int64(len("read me")+len("\x89PNG logo")) {
+		t.Fatalf("unexpected zip listing %+v", listing)
+	}
+	if !listing.Entries[0].IsDir || listing.Entries[0].Type != "dir" || listing.Entries[1].Path != "docs/readme.txt" || listing.Entries[1].Type != "file" {
+		t.Fatalf("unexpected zip entries %+v", listing.Entries)
+	}
+
+	listing, err = m.ListArchiveEntries(tarred.Hash, ArchiveListOptions{})
+	if err != nil {
+		t.Fatalf("list tar.gz: %v", err)
+	}
+	if listing.Format != ArchiveTarGz || listing.Count != 2 || listing.Entries[1].Path != "b/c.txt" || listing.Entries[1].Size != 6 {
+		t.Fatalf("unexpected tar.gz listing %+v", listing)
+	}
+
+	plain := storeContent(t, m, "notes.txt", "text/plain", "not an archive")
+	if _, err := m.ListArchiveEntries(plain.Hash, ArchiveListOptions{}); !errors.Is(err, ErrUnsupportedArchive) {
+		t.Fatalf("expected ErrUnsupportedArchive, got %v", err)
+	}
+	if _, err := m.ListArchiveEntries("missing", ArchiveListOptions{}); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected ErrFileNotFound, got %v", err)
+	}
+}
+
+func TestListArchiveEntriesPagesAndCaps(t *testing.T) {
+	m := newTestManager(t)
+	members := []archiveMember{{"a", "1"}, {"b", "22"}, {"c", "333"}, {"d", "4444"}, {"e", "55555"}}
+	tarred := storeContent(t, m, "many.tar.gz", "application/gzip", string(gzipBytes(t, buildTar(t, members...))))
+
+	listing, err := m.ListArchiveEntries(tarred.Hash, ArchiveListOptions{Offset: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("list page: %v", err)
+	}
+	if listing.Count != 2 || listing.Entries[0].Path != "b" || listing.Entries[1].Path != "c" || listing.Total != 5 || listing.TotalSize != 15 || listing.Truncated {
+		t.Fatalf("unexpected page %+v", listing)
+	}
+
+	listing, err = m.ListArchiveEntries(tarred.Hash, ArchiveListOptions{MaxEntries: 3})
+	if err != nil {
+		t.Fatalf("list capped: %v", err)
+	}
+	if listing.Count != 3 || listing.Total != 3 || listing.TotalSize != 6 || !listing.Truncated {
+		t.Fatalf("expected a listing truncated at 3 entries, got %+v", listing)
+	}
+
+	listing, err = m.ListArchiveEntries(tarred.Hash, ArchiveListOptions{MaxEntries: 5})
+	if err != nil || listing.Count != 5 || listing.Truncated {
+		t.Fatalf("archive at the cap must not be truncated: %+v, %v", listing, err)
+	}
+}
+
+func TestOpenArchiveEntry(t *testing.T) {
+	m := newTestManager(t)
+	members := []archiveMember{{"a.txt", "alpha"}, {"dir/b.txt", "bravo"}}
+	archives := map[string]FileMetadata{
+		"zip":    storeContent(t, m, "x.zip", "application/zip", string(buildZip(t, members...))),
+		"tar":    storeContent(t, m, "x.tar", "application/x-tar", string(buildTar(t, members...))),
+		"tar.gz": storeContent(t, m, "x.tgz", "application/gzip", string(gzipBytes(t, buildTar(t, members...)))),
+	}
+	for name, meta := range archives {
+		entry, err := m.OpenArchiveEntry(meta.Hash, "./dir/b.txt")
+		if err != nil {
+			t.Fatalf("%s: open: %v", name, err)
+		}
+		data, err := io.ReadAll(entry)
+		entry.Close()
+		if err != nil || string(data) != "bravo" || entry.Entry.Size != 5 || entry.Archive.Hash != meta.Hash {
+			t.Errorf("%s: read %q, %v, entry %+v", name, data, err, entry.Entry)
+		}
+		if _, err := m.OpenArchiveEntry(meta.Hash, "dir/missing.txt"); !errors.Is(err, ErrArchiveEntryNotFound) {
+			t.Errorf("%s: expected ErrArchiveEntryNotFound, got %v", name, err)
+		}
+		if _, err := m.OpenArchiveEntry(meta.Hash, "../a.txt"); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%s: expected ErrInvalidPath, got %v", name, err)
+		}
+	}
+}
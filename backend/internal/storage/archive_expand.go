@@ -0,0 +1,421 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	// ErrArchiveLimit is returned when expanding an archive would exceed
+	// one of its ArchiveLimits, as zip bombs do.
+	ErrArchiveLimit = errors.New("archive exceeds expansion limits")
+	// ErrUnsupportedArchive is returned for uploads that are not a zip,
+	// tar, tar.gz or tar.zst archive.
+	ErrUnsupportedArchive = errors.New("unsupported archive format")
+)
+
+const (
+	ArchiveTar    ArchiveFormat = "tar"
+	ArchiveTarZst ArchiveFormat = "tar.zst"
+
+	// archiveRatioSlack is expanded from any archive before the compression
+	// ratio limit applies, so tiny archives of text are not rejected.
+	archiveRatioSlack = 1 << 20
+)
+
+// ArchiveLimits bound what ExpandArchive unpacks. Entries and bytes are
+// counted across nested archives; the ratio applies to each archive.
+type ArchiveLimits struct {
+	MaxEntries    int
+	MaxTotalBytes int64
+	// MaxRatio bounds expanded bytes per compressed byte.
+	MaxRatio float64
+	// MaxDepth is how many levels of archives are unpacked: 1 unpacks the
+	// upload only and keeps archives inside it packed.
+	MaxDepth int
+}
+
+// DefaultArchiveLimits are used for limits left at zero.
+var DefaultArchiveLimits = ArchiveLimits{
+	MaxEntries:    10000,
+	MaxTotalBytes: 10 << 30,
+	MaxRatio:      100,
+	MaxDepth:      3,
+}
+
+func (l ArchiveLimits) withDefaults() ArchiveLimits {
+	if l.MaxEntries <= 0 {
+		l.MaxEntries = DefaultArchiveLimits.MaxEntries
+	}
+	if l.MaxTotalBytes <= 0 {
+		l.MaxTotalBytes = DefaultArchiveLimits.MaxTotalBytes
+	}
+	if l.MaxRatio <= 0 {
+		l.MaxRatio = DefaultArchiveLimits.MaxRatio
+	}
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = DefaultArchiveLimits.MaxDepth
+	}
+	return l
+}
+
+// ExpandArchiveRequest describes an uploaded archive to unpack.
+type ExpandArchiveRequest struct {
+	Reader   io.ReaderAt
+	Size     int64
+	Filename string
+	// Metadata and CategoryHint apply to every stored entry.
+	Metadata     map[string]string
+	CategoryHint string
+	Limits       ArchiveLimits
+}
+
+// ExpandedEntry is one archive member stored through StoreFile.
+type ExpandedEntry struct {
+	// Path is the member's path inside the archive; members of nested
+	// archives are prefixed with the nested archive's path.
+	Path   string
+	Result *StoreResult
+}
+
+// SkippedEntry is an archive member that was not stored.
+type SkippedEntry struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// ExpandArchiveResult reports what ExpandArchive stored.
+type ExpandArchiveResult struct {
+	Format        ArchiveFormat
+	Entries       []ExpandedEntry
+	Skipped       []SkippedEntry
+	ExpandedBytes int64
+}
+
+// DetectArchiveFormat identifies an archive by its magic bytes.
+func DetectArchiveFormat(r io.ReaderAt) (ArchiveFormat, bool) {
+	header := make([]byte, 512)
+	n, _ := r.ReadAt(header, 0)
+	header = header[:n]
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return ArchiveZip, true
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return ArchiveTarGz, true
+	case bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return ArchiveTarZst, true
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return ArchiveTar, true
+	}
+	return "", false
+}
+
+// ExpandArchive unpacks a zip, tar, tar.gz or tar.zst archive, storing each
+// regular file through StoreFile so it is classified and deduplicated like
+// a direct upload. Each stored file records its archive-relative path in
+// the "archive_path" metadata key and the archive's name in "archive".
+// Directories, links and entries escaping the archive root are skipped.
+//
+// Zip archives are checked against the limits before anything is stored;
+// tar archives are checked as they stream, so a limit hit part way through
+// returns the entries stored so far together with an ErrArchiveLimit error.
+func (m *Manager) ExpandArchive(req ExpandArchiveRequest) (*ExpandArchiveResult, error) {
+	format, ok := DetectArchiveFormat(req.Reader)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedArchive, req.Filename)
+	}
+	x := &archiveExpander{
+		m:      m,
+		req:    req,
+		limits: req.Limits.withDefaults(),
+		result: &ExpandArchiveResult{Format: format},
+	}
+	err := x.expand(req.Reader, req.Size, format, "", 1)
+	return x.result, err
+}
+
+// archiveExpander carries the limits and totals shared by an archive and
+// the archives nested in it.
+type archiveExpander struct {
+	m       *Manager
+	req     ExpandArchiveRequest
+	limits  ArchiveLimits
+	result  *ExpandArchiveResult
+	entries int
+}
+
+func (x *archiveExpander) expand(r io.ReaderAt, size int64, format ArchiveFormat, prefix string, depth int) error {
+	budget := &archiveBudget{x: x, ratioLimit: int64(x.limits.MaxRatio*float64(size)) + archiveRatioSlack}
+	if format == ArchiveZip {
+		return x.expandZip(r, size, budget, prefix, depth)
+	}
+
+	var stream io.Reader = io.NewSectionReader(r, 0, size)
+	switch format {
+	case ArchiveTarGz:
+		gz, err := gzip.NewReader(stream)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrUnsupportedArchive, x.displayName(prefix), err)
+		}
+		defer gz.Close()
+		stream = gz
+	case ArchiveTarZst:
+		zr, err := zstd.NewReader(stream, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrUnsupportedArchive, x.displayName(prefix), err)
+		}
+		defer zr.Close()
+		stream = zr
+	}
+
+	tr := tar.NewReader(stream)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// A gzip stream that is not a tar, such as a lone .gz file.
+			if len(x.result.Entries) == 0 && prefix == "" {
+				return fmt.Errorf("%w: %s: %v", ErrUnsupportedArchive, x.req.Filename, err)
+			}
+			return fmt.Errorf("read %s: %w", x.displayName(prefix), err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			if hdr.Typeflag != tar.TypeDir && hdr.Typeflag != tar.TypeXGlobalHeader {
+				x.skip(prefix, hdr.Name, "not a regular file")
+			}
+			continue
+		}
+		if err := x.storeEntry(tr, hdr.Size, budget, prefix, hdr.Name, depth); err != nil {
+			return err
+		}
+	}
+}
+
+func (x *archiveExpander) expandZip(r io.ReaderAt, size int64, budget *archiveBudget, prefix string, depth int) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrUnsupportedArchive, x.displayName(prefix), err)
+	}
+	// The central directory declares every size up front, so bombs that do
+	// not lie about their sizes are refused before anything is stored.
+	var declared uint64
+	for _, f := range zr.File {
+		declared += f.UncompressedSize64
+	}
+	if x.entries+len(zr.File) > x.limits.MaxEntries {
+		return fmt.Errorf("%w: %s has %d entries, the limit is %d", ErrArchiveLimit, x.displayName(prefix), len(zr.File), x.limits.MaxEntries)
+	}
+	if declared > uint64(x.limits.MaxTotalBytes-x.result.ExpandedBytes) {
+		return fmt.Errorf("%w: %s expands to %d bytes, the limit is %d", ErrArchiveLimit, x.displayName(prefix), declared, x.limits.MaxTotalBytes)
+	}
+	if declared > uint64(budget.ratioLimit) {
+		return fmt.Errorf("%w: %s exceeds the %.0f:1 compression ratio limit", ErrArchiveLimit, x.displayName(prefix), x.limits.MaxRatio)
+	}
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			x.skip(prefix, f.Name, "not a regular file")
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			x.skip(prefix, f.Name, err.Error())
+			continue
+		}
+		err = x.storeEntry(rc, int64(f.UncompressedSize64), budget, prefix, f.Name, depth)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeEntry stores one member read from r, or unpacks it when it is itself
+// an archive and depth allows.
+func (x *archiveExpander) storeEntry(r io.Reader, size int64, budget *archiveBudget, prefix, name string, depth int) error {
+	entryPath, ok := cleanArchivePath(name)
+	if !ok {
+		x.skip(prefix, name, "path escapes the archive")
+		return nil
+	}
+	if isArchiveJunk(entryPath) {
+		return nil
+	}
+	x.entries++
+	if x.entries > x.limits.MaxEntries {
+		return fmt.Errorf("%w: more than %d entries", ErrArchiveLimit, x.limits.MaxEntries)
+	}
+	fullPath := entryPath
+	if prefix != "" {
+		fullPath = prefix + "/" + entryPath
+	}
+	reader := &archiveEntryReader{r: r, budget: budget}
+
+	if depth < x.limits.MaxDepth && isArchiveName(entryPath) {
+		return x.expandNested(reader, size, fullPath, depth)
+	}
+	return x.store(reader, size, fullPath)
+}
+
+// expandNested spools a nested archive to disk, since zip needs random
+// access, and unpacks it under its own path. Files that only look like
+// archives are stored as they are.
+func (x *archiveExpander) expandNested(r io.Reader, size int64, fullPath string, depth int) error {
+	tmpDir := filepath.Join(x.m.storageRoot, ".tmp")
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(tmpDir, "archive_"+uuid.NewString()+"_*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	written, err := io.Copy(tmp, r)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", fullPath, err)
+	}
+
+	if format, ok := DetectArchiveFormat(tmp); ok {
+		// The spooled bytes were counted already; count the members instead.
+		x.result.ExpandedBytes -= written
+		return x.expand(tmp, written, format, fullPath, depth+1)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return x.store(tmp, written, fullPath)
+}
+
+func (x *archiveExpander) store(r io.Reader, size int64, fullPath string) error {
+	name := path.Base(fullPath)
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(r, sniff)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("read %s: %w", fullPath, err)
+	}
+	mimeType := mime.TypeByExtension(strings.ToLower(path.Ext(name)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(sniff[:n])
+	}
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = mediaType
+	}
+
+	metadata := make(map[string]string, len(x.req.Metadata)+2)
+	for k, v := range x.req.Metadata {
+		metadata[k] = v
+	}
+	metadata["archive"] = x.req.Filename
+	metadata["archive_path"] = fullPath
+
+	result, err := x.m.StoreFile(StoreRequest{
+		Reader:       io.MultiReader(bytes.NewReader(sniff[:n]), r),
+		Filename:     name,
+		MimeType:     mimeType,
+		Size:         size,
+		Metadata:     metadata,
+		CategoryHint: x.req.CategoryHint,
+	})
+	if err != nil {
+		return fmt.Errorf("store %s: %w", fullPath, err)
+	}
+	x.result.Entries = append(x.result.Entries, ExpandedEntry{Path: fullPath, Result: result})
+	return nil
+}
+
+func (x *archiveExpander) skip(prefix, name, reason string) {
+	if prefix != "" {
+		name = prefix + "/" + name
+	}
+	x.result.Skipped = append(x.result.Skipped, SkippedEntry{Path: name, Reason: reason})
+}
+
+func (x *archiveExpander) displayName(prefix string) string {
+	if prefix != "" {
+		return prefix
+	}
+	return x.req.Filename
+}
+
+// archiveBudget counts the bytes expanded from one archive.
+type archiveBudget struct {
+	x          *archiveExpander
+	expanded   int64
+	ratioLimit int64
+}
+
+// archiveEntryReader enforces the size and ratio limits while a member is
+// read, so archives that lie about their sizes are caught too.
+type archiveEntryReader struct {
+	r      io.Reader
+	budget *archiveBudget
+}
+
+func (e *archiveEntryReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	b := e.budget
+	b.expanded += int64(n)
+	b.x.result.ExpandedBytes += int64(n)
+	if b.x.result.ExpandedBytes > b.x.limits.MaxTotalBytes {
+		return n, fmt.Errorf("%w: expands to more than %d bytes", ErrArchiveLimit, b.x.limits.MaxTotalBytes)
+	}
+	if b.expanded > b.ratioLimit {
+		return n, fmt.Errorf("%w: exceeds the %.0f:1 compression ratio limit", ErrArchiveLimit, b.x.limits.MaxRatio)
+	}
+	return n, err
+}
+
+// cleanArchivePath returns name as a clean relative path, or false when it
+// is absolute or climbs out of the archive.
+func cleanArchivePath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return "", false
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", false
+	}
+	return cleaned, true
+}
+
+// isArchiveJunk reports members that archivers add and nobody wants stored.
+func isArchiveJunk(entryPath string) bool {
+	base := path.Base(entryPath)
+	return strings.HasPrefix(entryPath, "__MACOSX/") || base == ".DS_Store" || base == "Thumbs.db"
+}
+
+// isArchiveName reports whether name has the extension of an archive
+// ExpandArchive can unpack.
+func isArchiveName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz", ".tar.zst", ".tzst"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
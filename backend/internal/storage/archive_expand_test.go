@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type archiveMember struct {
+	name    string
+	content string
+}
+
+func buildZip(t *testing.T, members ...archiveMember) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, m := range members {
+		w, err := zw.Create(m.name)
+		if err != nil {
+			t.Fatalf("zip create %s: %v", m.name, err)
+		}
+		w.Write([]byte(m.content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func buildTar(t *testing.T, members ...archiveMember) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, m := range members {
+		if err := tw.WriteHeader(&tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("tar header %s: %v", m.name, err)
+		}
+		tw.Write([]byte(m.content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	gz.Close()
+	return buf.Bytes()
+}
+
+func expandBytes(m *Manager, name string, data []byte, limits ArchiveLimits) (*ExpandArchiveResult, error) {
+	return m.ExpandArchive(ExpandArchiveRequest{
+		Reader:   bytes.NewReader(data),
+		Size:     int64(len(data)),
+		Filename: name,
+		Metadata: map[string]string{"namespace": "import"},
+		Limits:   limits,
+	})
+}
+
+func expandedPaths(result *ExpandArchiveResult) []string {
+	paths := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		paths = append(paths, entry.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestExpandArchiveZip(t *testing.T) {
+	m := newTestManager(t)
+	inner := gzipBytes(t, buildTar(t, archiveMember{"deep/notes.txt", "nested notes"}))
+	data := buildZip(t,
+		archiveMember{"photos/", ""},
+		archiveMember{"photos/a.png", "\x89PNG\r\n\x1a\n first"},
+		archiveMember{"docs/readme.txt", "read me"},
+		archiveMember{"docs/copy.txt", "read me"},
+		archiveMember{"../escape.txt", "outside"},
+		archiveMember{"__MACOSX/photos/._a.png", "resource fork"},
+		archiveMember{"backup.tar.gz", string(inner)},
+	)
+
+	result, err := expandBytes(m, "upload.zip", data, ArchiveLimits{})
+	if err != nil {
+		t.Fatalf("ExpandArchive: %v", err)
+	}
+	want := []string{"backup.tar.gz/deep/notes.txt", "docs/copy.txt", "docs/readme.txt", "photos/a.png"}
+	if got := expandedPaths(result); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Path != "../escape.txt" {
+		t.Fatalf("expected the escaping entry to be skipped, got %+v", result.Skipped)
+	}
+
+	byPath := map[string]*StoreResult{}
+	for _, entry := range result.Entries {
+		byPath[entry.Path] = entry.Result
+	}
+	photo := byPath["photos/a.png"].Metadata
+	if !strings.HasPrefix(photo.Category, "images") || photo.Metadata["archive_path"] != "photos/a.png" || photo.Metadata["archive"] != "upload.zip" || photo.Metadata["namespace"] != "import" {
+		t.Fatalf("unexpected photo metadata %+v", photo)
+	}
+	if !byPath["docs/copy.txt"].Duplicate && !byPath["docs/readme.txt"].Duplicate {
+		t.Fatal("expected identical members to be deduplicated")
+	}
+	if byPath["backup.tar.gz/deep/notes.txt"].Metadata.OriginalName != "notes.txt" {
+		t.Fatalf("unexpected nested entry %+v", byPath["backup.tar.gz/deep/notes.txt"].Metadata)
+	}
+}
+
+func TestExpandArchiveTarFormats(t *testing.T) {
+	tarData := buildTar(t, archiveMember{"a.txt", "alpha"}, archiveMember{"b/c.txt", "gamma"})
+	var zst bytes.Buffer
+	zw, err := zstd.NewWriter(&zst)
+	if err != nil {
+		t.Fatalf("zstd: %v", err)
+	}
+	zw.Write(tarData)
+	zw.Close()
+
+	for name, data := range map[string][]byte{
+		"plain.tar":    tarData,
+		"gzip.tar.gz":  gzipBytes(t, tarData),
+		"zstd.tar.zst": zst.Bytes(),
+	} {
+		m := newTestManager(t)
+		result, err := expandBytes(m, name, data, ArchiveLimits{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := expandedPaths(result); strings.Join(got, ",") != "a.txt,b/c.txt" {
+			t.Errorf("%s: unexpected entries %v", name, got)
+		}
+	}
+
+	m := newTestManager(t)
+	if _, err := expandBytes(m, "notes.txt", []byte("not an archive"), ArchiveLimits{}); !errors.Is(err, ErrUnsupportedArchive) {
+		t.Errorf("expected ErrUnsupportedArchive, got %v", err)
+	}
+	if _, err := expandBytes(m, "log.gz", gzipBytes(t, []byte("just a log line")), ArchiveLimits{}); !errors.Is(err, ErrUnsupportedArchive) {
+		t.Errorf("expected a lone gzip file to be unsupported, got %v", err)
+	}
+}
+
+func TestExpandArchiveLimits(t *testing.T) {
+	m := newTestManager(t)
+	many := buildZip(t, archiveMember{"1.txt", "one"}, archiveMember{"2.txt", "two"}, archiveMember{"3.txt", "three"})
+	if _, err := expandBytes(m, "many.zip", many, ArchiveLimits{MaxEntries: 2}); !errors.Is(err, ErrArchiveLimit) {
+		t.Fatalf("expected the entry limit to apply, got %v", err)
+	}
+	if files := m.GetAllMetadata(); len(files) != 0 {
+		t.Fatalf("expected a zip over the limit to store nothing, got %d files", len(files))
+	}
+
+	// Two MiB of zeros compress far beyond 100:1.
+	zeros := strings.Repeat("\x00", 2<<20)
+	if _, err := expandBytes(m, "bomb.zip", buildZip(t, archiveMember{"zeros.bin", zeros}), ArchiveLimits{}); !errors.Is(err, ErrArchiveLimit) {
+		t.Fatalf("expected the ratio limit to apply to zip, got %v", err)
+	}
+	if _, err := expandBytes(m, "bomb.tar.gz", gzipBytes(t, buildTar(t, archiveMember{"zeros.bin", zeros})), ArchiveLimits{}); !errors.Is(err, ErrArchiveLimit) {
+		t.Fatalf("expected the ratio limit to apply to tar.gz, got %v", err)
+	}
+	if _, err := expandBytes(m, "big.tar", buildTar(t, archiveMember{"a.txt", "0123456789"}), ArchiveLimits{MaxTotalBytes: 5}); !errors.Is(err, ErrArchiveLimit) {
+		t.Fatalf("expected the size limit to apply, got %v", err)
+	}
+
+	// Past the depth limit nested archives are kept packed.
+	nested := buildZip(t, archiveMember{"inner.zip", string(buildZip(t, archiveMember{"x.txt", "x"}))})
+	result, err := expandBytes(m, "nested.zip", nested, ArchiveLimits{MaxDepth: 1})
+	if err != nil {
+		t.Fatalf("nested: %v", err)
+	}
+	if got := expandedPaths(result); len(got) != 1 || got[0] != "inner.zip" || !strings.HasPrefix(result.Entries[0].Result.Metadata.Category, "archives") {
+		t.Fatalf("expected inner.zip to be stored packed, got %v", got)
+	}
+}
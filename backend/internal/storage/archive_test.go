@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"testing"
+)
+
+func TestWriteArchive(t *testing.T) {
+	m := newTestManager(t)
+	report := storeContent(t, m, "report.txt", "text/plain", "quarterly numbers")
+	clash := storeContent(t, m, "Report.txt", "text/plain", "a different report")
+	photo := storeContent(t, m, "photo.png", "image/png", "\x89PNG not really")
+	files := []FileMetadata{report, clash, photo}
+
+	var buf bytes.Buffer
+	summary, err := m.WriteArchive(&buf, files, ArchiveOptions{Format: ArchiveZip, Manifest: true})
+	if err != nil {
+		t.Fatalf("WriteArchive: %v", err)
+	}
+	if summary.Files != 3 || summary.Bytes != report.Size+clash.Size+photo.Size {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(data)
+		if f.Name == "photo.png" && f.Method != zip.Store {
+			t.Errorf("expected images to be stored uncompressed, got method %d", f.Method)
+		}
+	}
+	if contents["report.txt"] != "quarterly numbers" || contents["Report (2).txt"] != "a different report" || contents["photo.png"] != "\x89PNG not really" {
+		t.Fatalf("unexpected entries %v", contents)
+	}
+
+	var manifest ArchiveManifest
+	if err := json.Unmarshal([]byte(contents["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	if manifest.Count != 3 || manifest.Files[1].ArchivePath != "Report (2).txt" || manifest.Files[1].Hash != clash.Hash {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+}
+
+func TestWriteArchiveTarGz(t *testing.T) {
+	m := newTestManager(t)
+	a := storeContent(t, m, "notes/a.txt", "text/plain", "alpha")
+	missing := storeContent(t, m, "b.txt", "text/plain", "beta!")
+	if err := m.backend.Delete(missing.StoredPath); err != nil {
+		t.Fatalf("remove blob: %v", err)
+	}
+
+	var buf bytes.Buffer
+	summary, err := m.WriteArchive(&buf, []FileMetadata{a, missing}, ArchiveOptions{Format: ArchiveTarGz})
+	if err != nil {
+		t.Fatalf("WriteArchive: %v", err)
+	}
+	if summary.Files != 1 || len(summary.Missing) != 1 || summary.Missing[0] != missing.Hash {
+		t.Fatalf("expected the missing blob to be skipped, got %+v", summary)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		if hdr.Name == "a.txt" && string(data) != "alpha" {
+			t.Errorf("unexpected content %q", data)
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	if len(names) != 1 || names[0] != "a.txt" {
+		t.Fatalf("unexpected entries %v", names)
+	}
+}
+
+func TestParseArchiveFormat(t *testing.T) {
+	for input, want := range map[string]ArchiveFormat{"": ArchiveZip, "ZIP": ArchiveZip, "tar.gz": ArchiveTarGz, "tgz": ArchiveTarGz} {
+		if got, err := ParseArchiveFormat(input); err != nil || got != want {
+			t.Errorf("ParseArchiveFormat(%q) = %q, %v", input, got, err)
+		}
+	}
+	if _, err := ParseArchiveFormat("rar"); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("expected ErrInvalidArchive, got %v", err)
+	}
+}
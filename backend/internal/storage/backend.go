@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrBlobNotFound is returned by a Backend when the requested object does not exist.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// Backend abstracts where file contents live. Keys are slash-separated paths
+// relative to the data root, i.e. the same value recorded in FileMetadata.StoredPath,
+// so switching backends never changes the metadata index or classifier output.
+type Backend interface {
+	// Name identifies the backend in logs and statistics ("local", "s3").
+	Name() string
+	// Put streams r into key. size may be -1 when unknown.
+	Put(key string, r io.Reader, size int64) error
+	// PutFile moves a fully staged local file into key. The local file is
+	// consumed: after a successful call it no longer exists at localPath.
+	PutFile(key, localPath string) error
+	// Open returns a seekable reader for key.
+	Open(key string) (BlobReader, error)
+	// Stat returns size and modification time for key.
+	Stat(key string) (BlobInfo, error)
+	// Delete removes key. Missing keys return ErrBlobNotFound.
+	Delete(key string) error
+	// Copy duplicates srcKey into dstKey.
+	Copy(srcKey, dstKey string) error
+	// Move relocates srcKey to dstKey.
+	Move(srcKey, dstKey string) error
+}
+
+// BlobReader is the handle returned by Backend.Open. *os.File satisfies it, so
+// callers that previously relied on Stat/Seek keep working unchanged.
+type BlobReader interface {
+	io.ReadSeekCloser
+	Stat() (fs.FileInfo, error)
+}
+
+// BlobInfo describes a stored object.
+type BlobInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// blobKey joins path components into a backend key.
+func blobKey(parts ...string) string {
+	return path.Join(parts...)
+}
+
+// LocalBackend stores blobs on the local filesystem below root.
+type LocalBackend struct {
+	root string
+}
+
+// NewLocalBackend returns a backend rooted at the RhinoBox data directory.
+func NewLocalBackend(root string) *LocalBackend {
+	return &LocalBackend{root: root}
+}
+
+// Name implements Backend.
+func (b *LocalBackend) Name() string {
+	return "local"
+}
+
+// resolve maps a key onto an absolute path and refuses anything outside root.
+func (b *LocalBackend) resolve(key string) (string, error) {
+	if err := validatePath(key); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPath, err)
+	}
+
+	absRoot, err := filepath.Abs(b.root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve root path: %w", err)
+	}
+	absPath, err := filepath.Abs(filepath.Join(b.root, filepath.FromSlash(key)))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve file path: %w", err)
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%w: path outside root directory", ErrInvalidPath)
+	}
+	return absPath, nil
+}
+
+// Put implements Backend.
+func (b *LocalBackend) Put(key string, r io.Reader, size int64) error {
+	dst, err := b.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := writeFastFile(dst, r, size); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// PutFile implements Backend using a rename, so staging and final paths must
+// share a filesystem (both live under the data root).
+func (b *LocalBackend) PutFile(key, localPath string) error {
+	dst, err := b.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(localPath, dst)
+}
+
+// Open implements Backend.
+func (b *LocalBackend) Open(key string) (BlobReader, error) {
+	p, err := b.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, localErr(key, err)
+	}
+	return file, nil
+}
+
+// Stat implements Backend.
+func (b *LocalBackend) Stat(key string) (BlobInfo, error) {
+	p, err := b.resolve(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return BlobInfo{}, localErr(key, err)
+	}
+	return BlobInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Delete implements Backend.
+func (b *LocalBackend) Delete(key string) error {
+	p, err := b.resolve(key)
+	if err != nil {
+		return err
+	}
+	return localErr(key, os.Remove(p))
+}
+
+// Copy implements Backend.
+func (b *LocalBackend) Copy(srcKey, dstKey string) error {
+	src, err := b.resolve(srcKey)
+	if err != nil {
+		return err
+	}
+	dst, err := b.resolve(dstKey)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return localErr(srcKey, copyFile(src, dst))
+}
+
+// Move implements Backend.
+func (b *LocalBackend) Move(srcKey, dstKey string) error {
+	src, err := b.resolve(srcKey)
+	if err != nil {
+		return err
+	}
+	dst, err := b.resolve(dstKey)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return localErr(srcKey, os.Rename(src, dst))
+}
+
+// localErr translates os not-exist errors into ErrBlobNotFound.
+func localErr(key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrBlobNotFound, key)
+	}
+	return err
+}
+
+// copyFile copies a file from source to destination.
+func copyFile(src, dst string) error {
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	destFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	_, err = io.Copy(destFile, sourceFile)
+	if err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+
+	return destFile.Sync()
+}
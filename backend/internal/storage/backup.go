@@ -0,0 +1,440 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BackupFormatVersion is the snapshot layout WriteBackup produces and
+// RestoreBackup reads.
+const BackupFormatVersion = 1
+
+const (
+	// backupManifestName is the first entry of every snapshot.
+	backupManifestName = "backup.json"
+	// backupDataPrefix holds the files of the data directory.
+	backupDataPrefix = "data/"
+	// backupIndexPrefix holds a Badger dump per index store.
+	backupIndexPrefix = "index/"
+	// backupLoadPendingWrites bounds the batches Badger keeps in flight while
+	// loading a dump.
+	backupLoadPendingWrites = 256
+)
+
+// ErrInvalidBackup is returned for snapshots that cannot be read or do not
+// chain onto the snapshot restored before them.
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// BackupManifest describes a snapshot of a data directory. It lists every
+// file the directory held, including those an incremental snapshot left to
+// its base.
+type BackupManifest struct {
+	Version   int       `json:"version"`
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Base is the ID of the snapshot an incremental snapshot builds on.
+	Base  string                `json:"base,omitempty"`
+	Files map[string]BackupFile `json:"files"`
+	// Stores lists the Badger index directories, dumped in full every time.
+	Stores []string `json:"stores"`
+}
+
+// BackupFile is one file of a snapshot, keyed by its slash-separated path
+// relative to the data directory.
+type BackupFile struct {
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	// Included is false when the file is unchanged since the base snapshot
+	// and its contents are only in an earlier archive.
+	Included bool `json:"included"`
+}
+
+// Incremental reports whether the snapshot needs its base to be restored.
+func (b *BackupManifest) Incremental() bool {
+	return b.Base != ""
+}
+
+// Included returns how many files and bytes the snapshot itself carries.
+func (b *BackupManifest) Included() (files int, size int64) {
+	for _, f := range b.Files {
+		if f.Included {
+			files++
+			size += f.Size
+		}
+	}
+	return files, size
+}
+
+// BackupOptions controls what WriteBackup captures.
+type BackupOptions struct {
+	// Base is the manifest of an earlier snapshot. Files whose size and
+	// modification time have not changed since are left out.
+	Base *BackupManifest
+}
+
+// WriteBackup writes a snapshot of the data directory root to w as a
+// tar.gz. Blobs, JSON indexes, audit logs, API keys and tenants are copied
+// as files; Badger index stores are dumped through Badger so open stores
+// are read consistently. Other Badger directories are caches and are left
+// out. Nothing may write to root while the snapshot runs: the server holds
+// back writes, and offline the stores' directory locks keep a running
+// server out.
+func WriteBackup(w io.Writer, root string, opts BackupOptions) (*BackupManifest, error) {
+	if opts.Base != nil && opts.Base.Version != BackupFormatVersion {
+		return nil, fmt.Errorf("%w: base snapshot has format version %d, expected %d", ErrInvalidBackup, opts.Base.Version, BackupFormatVersion)
+	}
+	manifest := &BackupManifest{
+		Version:   BackupFormatVersion,
+		ID:        uuid.NewString(),
+		CreatedAt: time.Now().UTC(),
+		Files:     make(map[string]BackupFile),
+		Stores:    make([]string, 0),
+	}
+	if opts.Base != nil {
+		manifest.Base = opts.Base.ID
+	}
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel == "." {
+				return nil
+			}
+			if d.Name() == ".tmp" {
+				return fs.SkipDir
+			}
+			if isBadgerDir(p) {
+				if d.Name() == kvDirName {
+					manifest.Stores = append(manifest.Stores, rel)
+				}
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		file := BackupFile{Size: info.Size(), Modified: info.ModTime().UTC(), Included: true}
+		if opts.Base != nil {
+			if prev, ok := opts.Base.Files[rel]; ok && prev.Size == file.Size && prev.Modified.Equal(file.Modified) {
+				file.Included = false
+			}
+		}
+		manifest.Files[rel] = file
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan data directory: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBackupEntry(tw, backupManifestName, int64(len(data)), manifest.CreatedAt, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(manifest.Files))
+	for rel, file := range manifest.Files {
+		if file.Included {
+			paths = append(paths, rel)
+		}
+	}
+	sort.Strings(paths)
+	for _, rel := range paths {
+		if err := writeBackupFile(tw, root, rel, manifest.Files[rel]); err != nil {
+			return nil, err
+		}
+	}
+	for _, rel := range manifest.Stores {
+		if err := writeBackupStore(tw, filepath.Join(root, filepath.FromSlash(rel)), rel); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gz.Close()
+}
+
+// writeBackupFile copies one file of the data directory into the snapshot.
+func writeBackupFile(tw *tar.Writer, root, rel string, file BackupFile) error {
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return fmt.Errorf("back up %s: %w", rel, err)
+	}
+	defer f.Close()
+	if err := writeBackupEntry(tw, backupDataPrefix+rel, file.Size, file.Modified, f); err != nil {
+		return fmt.Errorf("back up %s: %w", rel, err)
+	}
+	return nil
+}
+
+// writeBackupStore dumps the Badger store in dir. The dump is spooled to a
+// temporary file because tar needs its size up front.
+func writeBackupStore(tw *tar.Writer, dir, rel string) error {
+	store, err := openKVStore(dir)
+	if err != nil {
+		return fmt.Errorf("back up %s: %w", rel, err)
+	}
+	defer store.release()
+
+	tmp, err := os.CreateTemp("", "rhinobox-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := store.db.Backup(tmp, 0); err != nil {
+		return fmt.Errorf("back up %s: %w", rel, err)
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := writeBackupEntry(tw, backupIndexPrefix+rel, size, time.Now().UTC(), tmp); err != nil {
+		return fmt.Errorf("back up %s: %w", rel, err)
+	}
+	return nil
+}
+
+func writeBackupEntry(tw *tar.Writer, name string, size int64, modified time.Time, r io.Reader) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     size,
+		ModTime:  modified,
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return err
+	}
+	// A file that grew since the scan is cut at its scanned size; one that
+	// shrank fails the copy.
+	_, err = io.CopyN(tw, r, size)
+	return err
+}
+
+// isBadgerDir reports whether dir holds a Badger database.
+func isBadgerDir(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "KEYREGISTRY"))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// ReadBackupManifest reads the manifest at the start of a snapshot.
+func ReadBackupManifest(r io.Reader) (*BackupManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	defer gz.Close()
+	return readBackupManifest(tar.NewReader(gz))
+}
+
+func readBackupManifest(tr *tar.Reader) (*BackupManifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if hdr.Name != backupManifestName {
+		return nil, fmt.Errorf("%w: first entry is %q, expected %s", ErrInvalidBackup, hdr.Name, backupManifestName)
+	}
+	var manifest BackupManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("%w: decode %s: %v", ErrInvalidBackup, backupManifestName, err)
+	}
+	if manifest.Version != BackupFormatVersion {
+		return nil, fmt.Errorf("%w: format version %d, expected %d", ErrInvalidBackup, manifest.Version, BackupFormatVersion)
+	}
+	return &manifest, nil
+}
+
+// RestoreBackup rebuilds a data directory at root from a full snapshot and
+// the incremental snapshots taken after it, oldest first. root must be
+// empty or missing. Files come from whichever archive last carried them;
+// indexes come from the last archive alone. The restored directory holds
+// exactly the files of the last snapshot. It returns that snapshot's
+// manifest.
+func RestoreBackup(root string, archives ...io.Reader) (*BackupManifest, error) {
+	if len(archives) == 0 {
+		return nil, fmt.Errorf("%w: no archives to restore", ErrInvalidBackup)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("restore into %s: directory is not empty", root)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	var manifest *BackupManifest
+	for i, archive := range archives {
+		if manifest, err = restoreArchive(root, archive, manifest, i == len(archives)-1); err != nil {
+			return nil, err
+		}
+	}
+
+	// Drop files deleted since an earlier snapshot in the chain.
+	stores := make(map[string]bool, len(manifest.Stores))
+	for _, rel := range manifest.Stores {
+		stores[rel] = true
+	}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if stores[rel] {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if _, ok := manifest.Files[rel]; !ok {
+			return os.Remove(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for rel, file := range manifest.Files {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil || info.Size() != file.Size {
+			return nil, fmt.Errorf("%w: %s is missing from the snapshot chain", ErrInvalidBackup, rel)
+		}
+	}
+	return manifest, nil
+}
+
+// restoreArchive unpacks one snapshot of a chain into root. prev is the
+// manifest of the snapshot before it, nil for the first.
+func restoreArchive(root string, r io.Reader, prev *BackupManifest, last bool) (*BackupManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	manifest, err := readBackupManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case prev == nil && manifest.Incremental():
+		return nil, fmt.Errorf("%w: snapshot %s is incremental; restore its base %s first", ErrInvalidBackup, manifest.ID, manifest.Base)
+	case prev != nil && manifest.Base != prev.ID:
+		return nil, fmt.Errorf("%w: snapshot %s does not build on %s", ErrInvalidBackup, manifest.ID, prev.ID)
+	}
+
+	stores := make(map[string]bool, len(manifest.Stores))
+	for _, rel := range manifest.Stores {
+		stores[rel] = true
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return manifest, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(hdr.Name, backupDataPrefix):
+			rel, ok := cleanArchivePath(strings.TrimPrefix(hdr.Name, backupDataPrefix))
+			file, listed := manifest.Files[rel]
+			if !ok || !listed {
+				return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidBackup, hdr.Name)
+			}
+			if err := restoreBackupFile(filepath.Join(root, filepath.FromSlash(rel)), tr, file); err != nil {
+				return nil, fmt.Errorf("restore %s: %w", rel, err)
+			}
+		case strings.HasPrefix(hdr.Name, backupIndexPrefix):
+			rel, ok := cleanArchivePath(strings.TrimPrefix(hdr.Name, backupIndexPrefix))
+			if !ok || !stores[rel] {
+				return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidBackup, hdr.Name)
+			}
+			// Every snapshot dumps the indexes whole, so only the
+			// newest dump matters.
+			if !last {
+				continue
+			}
+			if err := restoreBackupStore(filepath.Join(root, filepath.FromSlash(rel)), tr); err != nil {
+				return nil, fmt.Errorf("restore %s: %w", rel, err)
+			}
+		}
+	}
+}
+
+func restoreBackupFile(target string, r io.Reader, file BackupFile) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// Keep modification times so the next incremental snapshot taken from
+	// the restored directory only picks up real changes.
+	return os.Chtimes(target, file.Modified, file.Modified)
+}
+
+func restoreBackupStore(dir string, r io.Reader) error {
+	store, err := openKVStore(dir)
+	if err != nil {
+		return err
+	}
+	if err := store.db.Load(r, backupLoadPendingWrites); err != nil {
+		store.release()
+		return err
+	}
+	return store.release()
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// backupEntries lists the entry names of a snapshot after its manifest.
+func backupEntries(t *testing.T, archive []byte) []string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+}
+
+func readStoredContent(t *testing.T, m *Manager, hash string) string {
+	t.Helper()
+	result, err := m.GetFileByHash(hash)
+	if err != nil {
+		t.Fatalf("get %s: %v", hash, err)
+	}
+	defer result.Reader.Close()
+	data, err := io.ReadAll(result.Reader)
+	if err != nil {
+		t.Fatalf("read %s: %v", hash, err)
+	}
+	return string(data)
+}
+
+func TestWriteBackupAndRestore(t *testing.T) {
+	m := newTestManager(t)
+	defer m.Close()
+	report := storeContent(t, m, "report.txt", "text/plain", "quarterly numbers")
+	if _, err := m.AddNote(report.Hash, "checked", "ana"); err != nil {
+		t.Fatalf("add note: %v", err)
+	}
+
+	var buf bytes.Buffer
+	manifest, err := WriteBackup(&buf, m.Root(), BackupOptions{})
+	if err != nil {
+		t.Fatalf("WriteBackup: %v", err)
+	}
+	if manifest.Incremental() || len(manifest.Stores) != 1 || manifest.Stores[0] != "metadata/index.db" {
+		t.Fatalf("unexpected manifest: base=%q stores=%v", manifest.Base, manifest.Stores)
+	}
+	names := backupEntries(t, buf.Bytes())
+	if names[0] != backupManifestName || names[len(names)-1] != "index/metadata/index.db" {
+		t.Fatalf("unexpected entries: %v", names)
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, "data/cache/") {
+			t.Fatalf("cache directory %s was backed up", name)
+		}
+	}
+
+	read, err := ReadBackupManifest(bytes.NewReader(buf.Bytes()))
+	if err != nil || read.ID != manifest.ID {
+		t.Fatalf("ReadBackupManifest = %+v, %v", read, err)
+	}
+
+	target := filepath.Join(t.TempDir(), "restored")
+	if _, err := RestoreBackup(target, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+	restored, err := NewManager(target)
+	if err != nil {
+		t.Fatalf("open restored: %v", err)
+	}
+	defer restored.Close()
+	if got := readStoredContent(t, restored, report.Hash); got != "quarterly numbers" {
+		t.Fatalf("restored content = %q", got)
+	}
+	notes, err := restored.GetNotes(report.Hash)
+	if err != nil || len(notes) != 1 || notes[0].Text != "checked" {
+		t.Fatalf("restored notes = %+v, %v", notes, err)
+	}
+}
+
+func TestIncrementalBackup(t *testing.T) {
+	m := newTestManager(t)
+	defer m.Close()
+	kept := storeContent(t, m, "kept.txt", "text/plain", "kept contents")
+	removed := storeContent(t, m, "removed.txt", "text/plain", "removed contents")
+
+	var full bytes.Buffer
+	base, err := WriteBackup(&full, m.Root(), BackupOptions{})
+	if err != nil {
+		t.Fatalf("full backup: %v", err)
+	}
+
+	added := storeContent(t, m, "added.txt", "text/plain", "added contents")
+	if _, err := m.DeleteFile(DeleteRequest{Hash: removed.Hash}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	var incr bytes.Buffer
+	manifest, err := WriteBackup(&incr, m.Root(), BackupOptions{Base: base})
+	if err != nil {
+		t.Fatalf("incremental backup: %v", err)
+	}
+	if manifest.Base != base.ID {
+		t.Fatalf("base = %q, want %q", manifest.Base, base.ID)
+	}
+	if file := manifest.Files[kept.StoredPath]; file.Included || file.Size == 0 {
+		t.Fatalf("unchanged blob %s: %+v", kept.StoredPath, file)
+	}
+	if file := manifest.Files[added.StoredPath]; !file.Included {
+		t.Fatalf("new blob %s not included: %+v", added.StoredPath, file)
+	}
+	for _, name := range backupEntries(t, incr.Bytes()) {
+		if name == backupDataPrefix+kept.StoredPath {
+			t.Fatalf("incremental backup carries unchanged %s", name)
+		}
+	}
+
+	t.Run("restore chain", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "restored")
+		if _, err := RestoreBackup(target, bytes.NewReader(full.Bytes()), bytes.NewReader(incr.Bytes())); err != nil {
+			t.Fatalf("RestoreBackup: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(target, removed.StoredPath)); !os.IsNotExist(err) {
+			t.Fatalf("deleted blob was restored: %v", err)
+		}
+		restored, err := NewManager(target)
+		if err != nil {
+			t.Fatalf("open restored: %v", err)
+		}
+		defer restored.Close()
+		if got := readStoredContent(t, restored, kept.Hash); got != "kept contents" {
+			t.Fatalf("kept content = %q", got)
+		}
+		if got := readStoredContent(t, restored, added.Hash); got != "added contents" {
+			t.Fatalf("added content = %q", got)
+		}
+		if _, err := restored.GetFileMetadata(removed.Hash); err == nil {
+			t.Fatal("deleted file is still indexed")
+		}
+	})
+
+	t.Run("incremental alone", func(t *testing.T) {
+		_, err := RestoreBackup(filepath.Join(t.TempDir(), "restored"), bytes.NewReader(incr.Bytes()))
+		if !errors.Is(err, ErrInvalidBackup) {
+			t.Fatalf("err = %v, want ErrInvalidBackup", err)
+		}
+	})
+
+	t.Run("wrong base", func(t *testing.T) {
+		var other bytes.Buffer
+		if _, err := WriteBackup(&other, m.Root(), BackupOptions{}); err != nil {
+			t.Fatalf("backup: %v", err)
+		}
+		_, err := RestoreBackup(filepath.Join(t.TempDir(), "restored"), bytes.NewReader(other.Bytes()), bytes.NewReader(incr.Bytes()))
+		if !errors.Is(err, ErrInvalidBackup) {
+			t.Fatalf("err = %v, want ErrInvalidBackup", err)
+		}
+	})
+}
+
+func TestRestoreBackupRefusesNonEmptyDirectory(t *testing.T) {
+	m := newTestManager(t)
+	defer m.Close()
+	storeContent(t, m, "a.txt", "text/plain", "a")
+	var buf bytes.Buffer
+	if _, err := WriteBackup(&buf, m.Root(), BackupOptions{}); err != nil {
+		t.Fatalf("WriteBackup: %v", err)
+	}
+
+	target := t.TempDir()
+	if err := os.WriteFile(filepath.Join(target, "keep.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestoreBackup(target, bytes.NewReader(buf.Bytes())); err == nil {
+		t.Fatal("restore into a non-empty directory succeeded")
+	}
+}
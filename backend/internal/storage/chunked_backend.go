@@ -0,0 +1,647 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Keys used by the chunk store inside the shared index store.
+const (
+	chunkKeyPrefix = "chunk:"
+	chunkStatsKey  = "sys:chunkstats"
+)
+
+// chunkBlobDir is the backend key prefix that holds chunk contents.
+const chunkBlobDir = "chunks"
+
+// manifestMagic starts every chunk manifest. Blobs without it were written
+// before chunking was enabled and are passed through unchanged.
+var manifestMagic = []byte("RBXCDC1\n")
+
+// ChunkStats reports how much content-defined chunking saves.
+type ChunkStats struct {
+	// LogicalBytes is the total size of all chunked blobs as seen by readers.
+	LogicalBytes int64 `json:"logical_bytes"`
+	// PhysicalBytes is the size of the unique chunks actually stored.
+	PhysicalBytes int64   `json:"physical_bytes"`
+	Chunks        int64   `json:"chunks"`
+	Blobs         int64   `json:"blobs"`
+	SavedBytes    int64   `json:"saved_bytes"`
+	SavingsRatio  float64 `json:"savings_ratio"`
+}
+
+// chunkRecord is the refcounted entry for one stored chunk.
+type chunkRecord struct {
+	Size int64 `json:"size"`
+	Refs int64 `json:"refs"`
+}
+
+// chunkRef is one entry of a manifest.
+type chunkRef struct {
+	Hash [sha256.Size]byte
+	Size int64
+}
+
+// chunkManifest lists the chunks that make up a blob, in order.
+type chunkManifest struct {
+	Size   int64
+	Chunks []chunkRef
+}
+
+// ChunkedBackend splits blobs into content-defined chunks stored once by
+// SHA-256 in the wrapped backend. The blob key itself holds a small manifest,
+// so Move stays a cheap rename and trash/restore keep working unchanged.
+//
+// Chunk reference counts live in the index store. Writes add references before
+// the manifest is stored and deletes drop the manifest before releasing
+// references, so a crash can leak a chunk but never free one still in use.
+//
+// Put chunks and uploads without holding mu; it only takes mu to pin each
+// chunk and, at the end, to add references and store the manifest. A pinned
+// chunk is not deleted when its last reference goes away, so a chunk Put found
+// stored cannot vanish before Put references it, and only one Put uploads a
+// given chunk at a time.
+type ChunkedBackend struct {
+	inner  Backend
+	store  *kvStore
+	params chunkParams
+	mu     sync.Mutex
+	pinned map[string]*chunkPin
+}
+
+// chunkPin tracks the Puts in flight that use one chunk.
+type chunkPin struct {
+	puts int
+	// uploading is closed when the Put uploading the chunk is done.
+	uploading chan struct{}
+	// stored is set once the chunk was uploaded, before it is referenced.
+	stored bool
+}
+
+// NewChunkedBackend wraps inner with chunk-level deduplication. Reference
+// counts are kept in the index store under root/metadata. avgChunkSize <= 0
+// selects DefaultChunkAvgSize.
+func NewChunkedBackend(root string, inner Backend, avgChunkSize int) (*ChunkedBackend, error) {
+	if inner == nil {
+		return nil, errors.New("chunked backend: inner backend is required")
+	}
+	store, err := openKVStore(kvDirFor(filepath.Join(root, "metadata", "chunks.json")))
+	if err != nil {
+		return nil, err
+	}
+	return &ChunkedBackend{inner: inner, store: store, params: newChunkParams(avgChunkSize), pinned: make(map[string]*chunkPin)}, nil
+}
+
+// Close releases the index store.
+func (b *ChunkedBackend) Close() error {
+	return b.store.release()
+}
+
+// Name implements Backend.
+func (b *ChunkedBackend) Name() string {
+	return b.inner.Name() + "+chunked"
+}
+
+func chunkBlobKey(hash string) string {
+	return blobKey(chunkBlobDir, hash[:2], hash)
+}
+
+// Put implements Backend.
+func (b *ChunkedBackend) Put(key string, r io.Reader, size int64) error {
+	manifest := &chunkManifest{}
+	var pinned []string
+	seen := make(map[string]bool)
+	committed := false
+	defer func() { b.unpin(pinned, committed) }()
+
+	ch := newChunker(r, b.params)
+	for {
+		chunk, err := ch.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(chunk)
+		hash := hex.EncodeToString(sum[:])
+		manifest.Chunks = append(manifest.Chunks, chunkRef{Hash: sum, Size: int64(len(chunk))})
+		manifest.Size += int64(len(chunk))
+
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		pinned = append(pinned, hash)
+		upload, err := b.pin(hash)
+		if err != nil {
+			return err
+		}
+		if !upload {
+			continue
+		}
+		err = b.inner.Put(chunkBlobKey(hash), bytes.NewReader(chunk), int64(len(chunk)))
+		b.uploaded(hash, err == nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	old, err := b.loadManifest(key)
+	if err != nil && !errors.Is(err, ErrBlobNotFound) {
+		return err
+	}
+	if _, err := b.adjustRefs(manifest, 1); err != nil {
+		return err
+	}
+	encoded := manifest.encode()
+	if err := b.inner.Put(key, bytes.NewReader(encoded), int64(len(encoded))); err != nil {
+		b.release(manifest)
+		return err
+	}
+	committed = true
+	if old != nil {
+		b.release(old)
+	}
+	return nil
+}
+
+// pin keeps the chunk hash from being deleted until unpin. It reports
+// whether the caller has to upload the chunk, which is the case when it is
+// neither referenced nor stored by another Put. While another Put uploads
+// it, pin waits for that upload.
+func (b *ChunkedBackend) pin(hash string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := b.pinned[hash]
+	if p == nil {
+		p = &chunkPin{}
+		b.pinned[hash] = p
+	}
+	p.puts++
+	for p.uploading != nil {
+		wait := p.uploading
+		b.mu.Unlock()
+		<-wait
+		b.mu.Lock()
+	}
+	if p.stored {
+		return false, nil
+	}
+	known, err := b.chunkKnown(hash)
+	if err != nil || known {
+		return false, err
+	}
+	p.uploading = make(chan struct{})
+	return true, nil
+}
+
+// uploaded records the outcome of the upload pin asked for.
+func (b *ChunkedBackend) uploaded(hash string, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := b.pinned[hash]
+	p.stored = ok
+	close(p.uploading)
+	p.uploading = nil
+}
+
+// unpin drops the pins of a Put. When the Put did not commit, chunks that
+// nobody references or pins any more are deleted.
+func (b *ChunkedBackend) unpin(hashes []string, committed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, hash := range hashes {
+		p := b.pinned[hash]
+		if p.puts--; p.puts > 0 {
+			continue
+		}
+		delete(b.pinned, hash)
+		if committed {
+			continue
+		}
+		if known, err := b.chunkKnown(hash); err == nil && !known {
+			if err := b.inner.Delete(chunkBlobKey(hash)); err != nil && !errors.Is(err, ErrBlobNotFound) {
+				fmt.Fprintf(os.Stderr, "chunk delete failed for %s: %v\n", hash, err)
+			}
+		}
+	}
+}
+
+// PutFile implements Backend. The staged file is chunked and then removed.
+func (b *ChunkedBackend) PutFile(key, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = b.Put(key, file, info.Size())
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(localPath)
+}
+
+// Open implements Backend, reassembling chunked blobs on the fly.
+func (b *ChunkedBackend) Open(key string) (BlobReader, error) {
+	manifest, err := b.loadManifest(key)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return b.inner.Open(key)
+	}
+	info, err := b.inner.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+	info.Size = manifest.Size
+	return newChunkReader(b.inner, manifest, info), nil
+}
+
+// Stat implements Backend, reporting the logical size of chunked blobs.
+func (b *ChunkedBackend) Stat(key string) (BlobInfo, error) {
+	info, err := b.inner.Stat(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	manifest, err := b.loadManifest(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	if manifest != nil {
+		info.Size = manifest.Size
+	}
+	return info, nil
+}
+
+// Delete implements Backend. Chunks are removed once no blob references them.
+func (b *ChunkedBackend) Delete(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	manifest, err := b.loadManifest(key)
+	if err != nil {
+		return err
+	}
+	if err := b.inner.Delete(key); err != nil {
+		return err
+	}
+	if manifest != nil {
+		b.release(manifest)
+	}
+	return nil
+}
+
+// Copy implements Backend. Chunked blobs are copied by adding references;
+// plain blobs are copied by the wrapped backend without holding the lock.
+func (b *ChunkedBackend) Copy(srcKey, dstKey string) error {
+	b.mu.Lock()
+	manifest, err := b.loadManifest(srcKey)
+	if err != nil || manifest == nil {
+		b.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		return b.inner.Copy(srcKey, dstKey)
+	}
+	defer b.mu.Unlock()
+
+	if _, err := b.adjustRefs(manifest, 1); err != nil {
+		return err
+	}
+	encoded := manifest.encode()
+	if err := b.inner.Put(dstKey, bytes.NewReader(encoded), int64(len(encoded))); err != nil {
+		b.release(manifest)
+		return err
+	}
+	return nil
+}
+
+// Move implements Backend; only the manifest moves.
+func (b *ChunkedBackend) Move(srcKey, dstKey string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inner.Move(srcKey, dstKey)
+}
+
+// Stats returns the current logical and physical totals.
+func (b *ChunkedBackend) Stats() (ChunkStats, error) {
+	var stats ChunkStats
+	err := b.store.db.View(func(txn *badger.Txn) error {
+		_, err := getJSON(txn, chunkStatsKey, &stats)
+		return err
+	})
+	if err != nil {
+		return ChunkStats{}, err
+	}
+	stats.SavedBytes = stats.LogicalBytes - stats.PhysicalBytes
+	if stats.LogicalBytes > 0 {
+		stats.SavingsRatio = float64(stats.SavedBytes) / float64(stats.LogicalBytes)
+	}
+	return stats, nil
+}
+
+// chunkKnown reports whether hash is already stored with live references.
+func (b *ChunkedBackend) chunkKnown(hash string) (bool, error) {
+	var found bool
+	err := b.store.db.View(func(txn *badger.Txn) error {
+		var rec chunkRecord
+		var err error
+		found, err = getJSON(txn, chunkKeyPrefix+hash, &rec)
+		return err
+	})
+	return found, err
+}
+
+// adjustRefs adds delta references for every chunk of manifest and updates the
+// totals. It returns the chunks whose count dropped to zero. Callers hold b.mu,
+// which makes the read-modify-write safe without one large transaction.
+func (b *ChunkedBackend) adjustRefs(manifest *chunkManifest, delta int64) ([]string, error) {
+	counts := make(map[string]int64)
+	sizes := make(map[string]int64)
+	for _, ref := range manifest.Chunks {
+		hash := hex.EncodeToString(ref.Hash[:])
+		counts[hash]++
+		sizes[hash] = ref.Size
+	}
+
+	records := make(map[string]chunkRecord, len(counts))
+	var stats ChunkStats
+	err := b.store.db.View(func(txn *badger.Txn) error {
+		if _, err := getJSON(txn, chunkStatsKey, &stats); err != nil {
+			return err
+		}
+		for hash := range counts {
+			var rec chunkRecord
+			if _, err := getJSON(txn, chunkKeyPrefix+hash, &rec); err != nil {
+				return err
+			}
+			records[hash] = rec
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wb := b.store.db.NewWriteBatch()
+	defer wb.Cancel()
+	var released []string
+	for hash, n := range counts {
+		rec := records[hash]
+		before := rec.Refs
+		rec.Size = sizes[hash]
+		rec.Refs += n * delta
+		switch {
+		case before <= 0 && rec.Refs > 0:
+			stats.Chunks++
+			stats.PhysicalBytes += rec.Size
+		case before > 0 && rec.Refs <= 0:
+			stats.Chunks--
+			stats.PhysicalBytes -= rec.Size
+		}
+		if rec.Refs <= 0 {
+			if before > 0 {
+				released = append(released, hash)
+			}
+			err = wb.Delete([]byte(chunkKeyPrefix + hash))
+		} else {
+			err = setJSON(wb, chunkKeyPrefix+hash, rec)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	stats.LogicalBytes += manifest.Size * delta
+	stats.Blobs += delta
+	stats.SavedBytes, stats.SavingsRatio = 0, 0
+	if err := setJSON(wb, chunkStatsKey, stats); err != nil {
+		return nil, err
+	}
+	if err := wb.Flush(); err != nil {
+		return nil, err
+	}
+	sort.Strings(released)
+	return released, nil
+}
+
+// release drops one reference to every chunk of manifest and deletes chunks
+// nobody uses or pins any more. Failures only leak space, so they are not
+// returned. Callers hold b.mu.
+func (b *ChunkedBackend) release(manifest *chunkManifest) {
+	released, err := b.adjustRefs(manifest, -1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chunk release failed: %v\n", err)
+		return
+	}
+	for _, hash := range released {
+		if b.pinned[hash] != nil {
+			continue
+		}
+		if err := b.inner.Delete(chunkBlobKey(hash)); err != nil && !errors.Is(err, ErrBlobNotFound) {
+			fmt.Fprintf(os.Stderr, "chunk delete failed for %s: %v\n", hash, err)
+		}
+	}
+}
+
+// loadManifest reads the manifest stored at key. It returns nil without an
+// error when key holds plain (pre-chunking) content.
+func (b *ChunkedBackend) loadManifest(key string) (*chunkManifest, error) {
+	reader, err := b.inner.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	br := bufio.NewReader(reader)
+	head, err := br.Peek(len(manifestMagic))
+	if err != nil || !bytes.Equal(head, manifestMagic) {
+		return nil, nil
+	}
+	manifest, err := decodeManifest(br)
+	if err != nil {
+		// Plain content that happens to start with the magic bytes.
+		return nil, nil
+	}
+	return manifest, nil
+}
+
+// encode serialises the manifest: magic, uvarint size, uvarint count, then
+// each chunk as its raw SHA-256 followed by a uvarint length.
+func (m *chunkManifest) encode() []byte {
+	buf := make([]byte, 0, len(manifestMagic)+2*binary.MaxVarintLen64+len(m.Chunks)*(sha256.Size+3))
+	buf = append(buf, manifestMagic...)
+	buf = binary.AppendUvarint(buf, uint64(m.Size))
+	buf = binary.AppendUvarint(buf, uint64(len(m.Chunks)))
+	for _, ref := range m.Chunks {
+		buf = append(buf, ref.Hash[:]...)
+		buf = binary.AppendUvarint(buf, uint64(ref.Size))
+	}
+	return buf
+}
+
+func decodeManifest(r *bufio.Reader) (*chunkManifest, error) {
+	if _, err := r.Discard(len(manifestMagic)); err != nil {
+		return nil, err
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(size)+1 {
+		return nil, errors.New("corrupt chunk manifest")
+	}
+	manifest := &chunkManifest{Size: int64(size), Chunks: make([]chunkRef, 0, count)}
+	var total int64
+	for i := uint64(0); i < count; i++ {
+		var ref chunkRef
+		if _, err := io.ReadFull(r, ref.Hash[:]); err != nil {
+			return nil, err
+		}
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		ref.Size = int64(n)
+		total += ref.Size
+		manifest.Chunks = append(manifest.Chunks, ref)
+	}
+	if total != manifest.Size {
+		return nil, errors.New("corrupt chunk manifest")
+	}
+	if _, err := r.ReadByte(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("trailing data after chunk manifest")
+	}
+	return manifest, nil
+}
+
+// chunkReader reassembles a chunked blob. Sequential reads keep one chunk
+// open; a seek closes it and the next read reopens the right chunk.
+type chunkReader struct {
+	inner    Backend
+	manifest *chunkManifest
+	offsets  []int64 // start offset of each chunk
+	info     BlobInfo
+	pos      int64
+	cur      BlobReader
+	curEnd   int64
+}
+
+func newChunkReader(inner Backend, manifest *chunkManifest, info BlobInfo) *chunkReader {
+	offsets := make([]int64, len(manifest.Chunks))
+	var off int64
+	for i, ref := range manifest.Chunks {
+		offsets[i] = off
+		off += ref.Size
+	}
+	return &chunkReader{inner: inner, manifest: manifest, offsets: offsets, info: info}
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.pos >= r.manifest.Size {
+		return 0, io.EOF
+	}
+	if r.cur == nil {
+		if err := r.openAt(r.pos); err != nil {
+			return 0, err
+		}
+	}
+	if remaining := r.curEnd - r.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := r.cur.Read(p)
+	r.pos += int64(n)
+	if r.pos >= r.curEnd {
+		r.closeChunk()
+		if err == io.EOF {
+			err = nil
+		}
+	}
+	if err == io.EOF {
+		// The chunk ended before its recorded size.
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// openAt opens the chunk containing pos and seeks inside it.
+func (r *chunkReader) openAt(pos int64) error {
+	idx := sort.Search(len(r.offsets), func(i int) bool { return r.offsets[i] > pos }) - 1
+	ref := r.manifest.Chunks[idx]
+	chunk, err := r.inner.Open(chunkBlobKey(hex.EncodeToString(ref.Hash[:])))
+	if err != nil {
+		return err
+	}
+	if within := pos - r.offsets[idx]; within > 0 {
+		if _, err := chunk.Seek(within, io.SeekStart); err != nil {
+			chunk.Close()
+			return err
+		}
+	}
+	r.cur = chunk
+	r.curEnd = r.offsets[idx] + ref.Size
+	return nil
+}
+
+func (r *chunkReader) closeChunk() {
+	if r.cur != nil {
+		r.cur.Close()
+		r.cur = nil
+	}
+}
+
+func (r *chunkReader) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = r.pos + offset
+	case io.SeekEnd:
+		next = r.manifest.Size + offset
+	default:
+		return 0, errors.New("chunk reader: invalid whence")
+	}
+	if next < 0 {
+		return 0, errors.New("chunk reader: negative position")
+	}
+	if next != r.pos {
+		r.closeChunk()
+		r.pos = next
+	}
+	return next, nil
+}
+
+func (r *chunkReader) Close() error {
+	r.closeChunk()
+	return nil
+}
+
+func (r *chunkReader) Stat() (fs.FileInfo, error) {
+	return blobFileInfo{info: r.info}, nil
+}
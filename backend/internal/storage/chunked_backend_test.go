@@ -0,0 +1,342 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func randomBytes(seed int64, n int) []byte {
+	buf := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(buf)
+	return buf
+}
+
+func TestChunkerBoundsAndStability(t *testing.T) {
+	params := newChunkParams(4 << 10)
+	data := randomBytes(1, 512<<10)
+
+	split := func(payload []byte) [][]byte {
+		var chunks [][]byte
+		c := newChunker(bytes.NewReader(payload), params)
+		for {
+			chunk, err := c.Next()
+			if err == io.EOF {
+				return chunks
+			}
+			if err != nil {
+				t.Fatalf("next: %v", err)
+			}
+			chunks = append(chunks, append([]byte(nil), chunk...))
+		}
+	}
+
+	chunks := split(data)
+	if !bytes.Equal(bytes.Join(chunks, nil), data) {
+		t.Fatal("chunks do not reassemble to the input")
+	}
+	for i, chunk := range chunks[:len(chunks)-1] {
+		if len(chunk) < params.min || len(chunk) > params.max {
+			t.Fatalf("chunk %d has size %d outside [%d, %d]", i, len(chunk), params.min, params.max)
+		}
+	}
+
+	// Inserting bytes near the start must only disturb the chunks around the edit.
+	edited := append(append(append([]byte(nil), data[:1000]...), []byte("inserted header")...), data[1000:]...)
+	seen := make(map[string]bool)
+	for _, chunk := range chunks {
+		seen[string(chunk)] = true
+	}
+	shared := 0
+	for _, chunk := range split(edited) {
+		if seen[string(chunk)] {
+			shared++
+		}
+	}
+	if shared < len(chunks)-3 {
+		t.Fatalf("expected nearly all %d chunks to survive an insert, only %d did", len(chunks), shared)
+	}
+}
+
+func newTestChunkedBackend(t *testing.T) (*ChunkedBackend, string) {
+	t.Helper()
+	root := t.TempDir()
+	backend, err := NewChunkedBackend(root, NewLocalBackend(root), 4<<10)
+	if err != nil {
+		t.Fatalf("new chunked backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return backend, root
+}
+
+func TestChunkedBackendRoundTripAndSeek(t *testing.T) {
+	backend, _ := newTestChunkedBackend(t)
+	data := randomBytes(2, 200<<10)
+
+	if err := backend.Put("storage/a.bin", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	info, err := backend.Stat("storage/a.bin")
+	if err != nil || info.Size != int64(len(data)) {
+		t.Fatalf("stat: size %d, err %v", info.Size, err)
+	}
+
+	reader, err := backend.Open("storage/a.bin")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("read back mismatch (err %v)", err)
+	}
+
+	// Ranged reads as used by http.ServeContent
+	if _, err := reader.Seek(123457, io.SeekStart); err != nil {
+		t.Fatalf("seek: %v", err)
+	}
+	part := make([]byte, 50000)
+	if _, err := io.ReadFull(reader, part); err != nil {
+		t.Fatalf("read after seek: %v", err)
+	}
+	if !bytes.Equal(part, data[123457:173457]) {
+		t.Fatal("data after seek does not match")
+	}
+	fi, err := reader.Stat()
+	if err != nil || fi.Size() != int64(len(data)) {
+		t.Fatalf("reader stat: %v", err)
+	}
+}
+
+func TestChunkedBackendSharesChunksAndReleasesThem(t *testing.T) {
+	backend, root := newTestChunkedBackend(t)
+	original := randomBytes(3, 256<<10)
+	edited := append([]byte(nil), original...)
+	copy(edited[10:], []byte("changed header"))
+
+	if err := backend.Put("storage/v1.bin", bytes.NewReader(original), -1); err != nil {
+		t.Fatalf("put v1: %v", err)
+	}
+	if err := backend.Put("storage/v2.bin", bytes.NewReader(edited), -1); err != nil {
+		t.Fatalf("put v2: %v", err)
+	}
+
+	stats, err := backend.Stats()
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.LogicalBytes != int64(len(original)+len(edited)) {
+		t.Fatalf("unexpected logical bytes %d", stats.LogicalBytes)
+	}
+	if stats.PhysicalBytes >= int64(len(original))*3/2 {
+		t.Fatalf("expected most chunks to be shared, physical=%d", stats.PhysicalBytes)
+	}
+	if stats.SavedBytes <= 0 || stats.Blobs != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// Copy adds references, Move keeps them, Delete drops them
+	if err := backend.Copy("storage/v1.bin", "storage/v1-copy.bin"); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if err := backend.Move("storage/v1-copy.bin", "trash/v1-copy"); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	for _, key := range []string{"storage/v1.bin", "storage/v2.bin", "trash/v1-copy"} {
+		if err := backend.Delete(key); err != nil {
+			t.Fatalf("delete %s: %v", key, err)
+		}
+	}
+
+	stats, _ = backend.Stats()
+	if stats.Chunks != 0 || stats.PhysicalBytes != 0 || stats.LogicalBytes != 0 {
+		t.Fatalf("expected empty chunk store, got %+v", stats)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, chunkBlobDir))
+	for _, dir := range entries {
+		files, _ := os.ReadDir(filepath.Join(root, chunkBlobDir, dir.Name()))
+		if len(files) > 0 {
+			t.Fatalf("chunk files left behind in %s", dir.Name())
+		}
+	}
+}
+
+// hookReader serves data and calls atEOF once before reporting io.EOF.
+type hookReader struct {
+	data  []byte
+	atEOF func()
+}
+
+func (r *hookReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		if r.atEOF != nil {
+			r.atEOF()
+			r.atEOF = nil
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestChunkedBackendPutKeepsChunksReleasedMeanwhile(t *testing.T) {
+	backend, root := newTestChunkedBackend(t)
+	data := randomBytes(5, 128<<10)
+	if err := backend.Put("storage/first.bin", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("put first: %v", err)
+	}
+
+	// While the second Put is still reading, the only other reference to its
+	// chunks goes away. The Put must not hold the lock meanwhile, and the
+	// chunks it found stored must survive.
+	reader := &hookReader{data: data, atEOF: func() {
+		done := make(chan error, 1)
+		go func() { done <- backend.Delete("storage/first.bin") }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("delete during put: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("delete blocked behind a put that is still reading")
+		}
+	}}
+	if err := backend.Put("storage/second.bin", reader, int64(len(data))); err != nil {
+		t.Fatalf("put second: %v", err)
+	}
+
+	got, err := backend.Open("storage/second.bin")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	content, err := io.ReadAll(got)
+	got.Close()
+	if err != nil || !bytes.Equal(content, data) {
+		t.Fatalf("second blob lost chunks (err %v)", err)
+	}
+	if stats, _ := backend.Stats(); stats.Blobs != 1 || stats.LogicalBytes != int64(len(data)) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	if err := backend.Delete("storage/second.bin"); err != nil {
+		t.Fatalf("delete second: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, chunkBlobDir))
+	for _, dir := range entries {
+		if files, _ := os.ReadDir(filepath.Join(root, chunkBlobDir, dir.Name())); len(files) > 0 {
+			t.Fatalf("chunk files left behind in %s", dir.Name())
+		}
+	}
+}
+
+func TestChunkedBackendConcurrentPutsAndDeletes(t *testing.T) {
+	backend, root := newTestChunkedBackend(t)
+	shared := randomBytes(6, 64<<10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data := append(append([]byte(nil), shared...), randomBytes(int64(100+i), 16<<10)...)
+			key := filepath.ToSlash(filepath.Join("storage", string(rune('a'+i))+".bin"))
+			for round := 0; round < 5; round++ {
+				if err := backend.Put(key, bytes.NewReader(data), int64(len(data))); err != nil {
+					t.Errorf("put %s: %v", key, err)
+					return
+				}
+				reader, err := backend.Open(key)
+				if err != nil {
+					t.Errorf("open %s: %v", key, err)
+					return
+				}
+				got, err := io.ReadAll(reader)
+				reader.Close()
+				if err != nil || !bytes.Equal(got, data) {
+					t.Errorf("read %s: mismatch (err %v)", key, err)
+					return
+				}
+				if err := backend.Delete(key); err != nil {
+					t.Errorf("delete %s: %v", key, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats, _ := backend.Stats()
+	if stats.Chunks != 0 || stats.PhysicalBytes != 0 || stats.Blobs != 0 {
+		t.Fatalf("expected empty chunk store, got %+v", stats)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, chunkBlobDir))
+	for _, dir := range entries {
+		if files, _ := os.ReadDir(filepath.Join(root, chunkBlobDir, dir.Name())); len(files) > 0 {
+			t.Fatalf("chunk files left behind in %s", dir.Name())
+		}
+	}
+}
+
+func TestChunkedBackendReadsPlainBlobs(t *testing.T) {
+	backend, root := newTestChunkedBackend(t)
+	if err := os.MkdirAll(filepath.Join(root, "storage"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "storage", "old.txt"), []byte("written before chunking"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := backend.Open("storage/old.txt")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	got, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(got) != "written before chunking" {
+		t.Fatalf("unexpected content %q", got)
+	}
+	if err := backend.Delete("storage/old.txt"); err != nil {
+		t.Fatalf("delete plain blob: %v", err)
+	}
+}
+
+func TestManagerWithChunkedBackend(t *testing.T) {
+	root := t.TempDir()
+	backend, err := NewChunkedBackend(root, NewLocalBackend(root), 0)
+	if err != nil {
+		t.Fatalf("new chunked backend: %v", err)
+	}
+	manager, err := NewManagerWithBackend(root, backend)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	defer manager.Close()
+
+	stored, err := storeText(t, manager, "notes.txt", "chunked content")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	result, err := manager.GetFileByHash(stored.Metadata.Hash)
+	if err != nil {
+		t.Fatalf("retrieve: %v", err)
+	}
+	defer result.Reader.Close()
+	got, _ := io.ReadAll(result.Reader)
+	if string(got) != "chunked content" {
+		t.Fatalf("unexpected content %q", got)
+	}
+
+	stats, err := manager.GetStatistics()
+	if err != nil {
+		t.Fatalf("statistics: %v", err)
+	}
+	if stats.Chunking == nil || stats.Chunking.LogicalBytes != int64(len("chunked content")) {
+		t.Fatalf("expected chunking stats, got %+v", stats.Chunking)
+	}
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// Default content-defined chunk sizes. Minimum and maximum are derived from
+// the average as avg/4 and avg*4, the ratios used by FastCDC.
+const (
+	DefaultChunkAvgSize = 64 << 10
+	minChunkAvgSize     = 1 << 10
+	maxChunkAvgSize     = 16 << 20
+)
+
+// gearTable holds the per-byte values of the gear rolling hash. It is
+// generated from a fixed seed so chunk boundaries are stable across builds
+// and restarts; changing it would stop new uploads sharing chunks with old ones.
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x5242585f43444331) // "RBX_CDC1"
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// chunkParams are the FastCDC size bounds and normalised-chunking masks.
+type chunkParams struct {
+	min, avg, max int
+	maskS, maskL  uint64
+}
+
+// newChunkParams derives FastCDC parameters for an average chunk size. The
+// average is rounded down to a power of two and clamped to a sane range.
+func newChunkParams(avg int) chunkParams {
+	if avg <= 0 {
+		avg = DefaultChunkAvgSize
+	}
+	if avg < minChunkAvgSize {
+		avg = minChunkAvgSize
+	}
+	if avg > maxChunkAvgSize {
+		avg = maxChunkAvgSize
+	}
+	avgBits := bits.Len(uint(avg)) - 1
+	avg = 1 << avgBits
+
+	// The gear hash shifts left, so the high bits summarise the most recent
+	// window; the masks therefore test the top bits. The stricter mask before
+	// the average and the looser one after it pull sizes towards the average.
+	return chunkParams{
+		min:   avg / 4,
+		avg:   avg,
+		max:   avg * 4,
+		maskS: ^uint64(0) << (64 - (avgBits + 2)),
+		maskL: ^uint64(0) << (64 - (avgBits - 2)),
+	}
+}
+
+// cut returns the length of the first chunk in data (len(data) <= p.max).
+func (p chunkParams) cut(data []byte) int {
+	n := len(data)
+	if n <= p.min {
+		return n
+	}
+	normal := p.avg
+	if n < normal {
+		normal = n
+	}
+
+	var h uint64
+	i := p.min
+	for ; i < normal; i++ {
+		h = (h << 1) + gearTable[data[i]]
+		if h&p.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		h = (h << 1) + gearTable[data[i]]
+		if h&p.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// chunker splits a stream into content-defined chunks.
+type chunker struct {
+	r      io.Reader
+	params chunkParams
+	buf    []byte
+	start  int
+	end    int
+	eof    bool
+}
+
+func newChunker(r io.Reader, params chunkParams) *chunker {
+	return &chunker{r: r, params: params, buf: make([]byte, params.max*2)}
+}
+
+// Next returns the next chunk. The slice is only valid until the next call.
+// It returns io.EOF once the stream is exhausted.
+func (c *chunker) Next() ([]byte, error) {
+	if c.end-c.start < c.params.max && !c.eof {
+		if err := c.fill(); err != nil {
+			return nil, err
+		}
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+
+	window := c.buf[c.start:c.end]
+	if len(window) > c.params.max {
+		window = window[:c.params.max]
+	}
+	n := c.params.cut(window)
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+	return chunk, nil
+}
+
+// fill compacts the buffer and reads until it holds at least max bytes or the
+// reader is exhausted.
+func (c *chunker) fill() error {
+	copy(c.buf, c.buf[c.start:c.end])
+	c.end -= c.start
+	c.start = 0
+	for c.end < len(c.buf) {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if errors.Is(err, io.EOF) {
+			c.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c.end >= c.params.max {
+			return nil
+		}
+	}
+	return nil
+}
@@ -49,7 +49,7 @@ func (m *Manager) GetCollectionStats(collectionType string) (*CollectionStats, e
 	normalizedType := strings.ToLower(strings.TrimSpace(collectionType))
 
 	// Iterate through all metadata entries
-	for _, meta := range m.index.data {
+	_ = m.index.ForEach(func(meta FileMetadata) bool {
 		// Extract the top-level category from the category path
 		// Category format is like "images/jpg" or "documents/pdf"
 		categoryParts := strings.Split(meta.Category, "/")
@@ -62,7 +62,8 @@ func (m *Manager) GetCollectionStats(collectionType string) (*CollectionStats, e
 				storageUsed += meta.Size
 			}
 		}
-	}
+		return true
+	})
 
 	return &CollectionStats{
 		Type:                collectionType,
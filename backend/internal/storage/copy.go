@@ -6,12 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/dgraph-io/badger/v4"
 )
 
 var (
@@ -19,44 +20,35 @@ var (
 	ErrCopyConflict = errors.New("copy conflict")
 )
 
+// refKeyPrefix namespaces hard-link references inside the shared index store.
+// Each reference is stored as ref:<physical path>\x00<hash> with an empty value.
+const refKeyPrefix = "ref:"
+
 // ReferenceIndex tracks hard links - files that share the same physical file.
 // Maps physical file path -> set of metadata hashes that reference it.
 type ReferenceIndex struct {
-	path string
-	mu   sync.RWMutex
-	data map[string]map[string]bool // physicalPath -> set of hashes
+	path  string
+	store *kvStore
+	mu    sync.RWMutex
 }
 
-// NewReferenceIndex creates a new reference index.
+// NewReferenceIndex creates a new reference index. A legacy references.json at
+// path is imported once.
 func NewReferenceIndex(path string) (*ReferenceIndex, error) {
-	idx := &ReferenceIndex{
-		path: path,
-		data: make(map[string]map[string]bool),
+	store, err := openKVStore(kvDirFor(path))
+	if err != nil {
+		return nil, err
 	}
-	if err := idx.load(); err != nil {
+	idx := &ReferenceIndex{path: path, store: store}
+	if err := migrateLegacyJSON(store, path, idx.importJSON); err != nil {
+		_ = store.release()
 		return nil, err
 	}
 	return idx, nil
 }
 
-func (idx *ReferenceIndex) load() error {
-	dir := filepath.Dir(idx.path)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return err
-	}
-
-	raw, err := os.ReadFile(idx.path)
-	if errors.Is(err, os.ErrNotExist) {
-		return nil
-	}
-	if err != nil {
-		return err
-	}
-
-	if len(raw) == 0 {
-		return nil
-	}
-
+// importJSON loads the legacy references.json array format.
+func (idx *ReferenceIndex) importJSON(raw []byte, w kvWriter) error {
 	var items []struct {
 		PhysicalPath string   `json:"physical_path"`
 		Hashes       []string `json:"hashes"`
@@ -66,87 +58,58 @@ func (idx *ReferenceIndex) load() error {
 	}
 
 	for _, item := range items {
-		hashSet := make(map[string]bool)
 		for _, hash := range item.Hashes {
-			hashSet[hash] = true
+			if err := w.Set([]byte(referenceKey(item.PhysicalPath, hash)), nil); err != nil {
+				return err
+			}
 		}
-		idx.data[item.PhysicalPath] = hashSet
 	}
 	return nil
 }
 
-func (idx *ReferenceIndex) persistLocked() error {
-	items := make([]struct {
-		PhysicalPath string   `json:"physical_path"`
-		Hashes       []string `json:"hashes"`
-	}, 0, len(idx.data))
-
-	for path, hashSet := range idx.data {
-		hashes := make([]string, 0, len(hashSet))
-		for hash := range hashSet {
-			hashes = append(hashes, hash)
-		}
-		items = append(items, struct {
-			PhysicalPath string   `json:"physical_path"`
-			Hashes       []string `json:"hashes"`
-		}{PhysicalPath: path, Hashes: hashes})
-	}
+// Close releases the index store.
+func (idx *ReferenceIndex) Close() error {
+	return idx.store.release()
+}
 
-	tmp := idx.path + ".tmp"
-	buf, err := json.MarshalIndent(items, "", "  ")
-	if err != nil {
-		return err
-	}
-	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
-		return err
-	}
-	return os.Rename(tmp, idx.path)
+func referenceKey(physicalPath, hash string) string {
+	return refKeyPrefix + physicalPath + "\x00" + hash
 }
 
 // AddReference adds a hash reference to a physical file path.
 func (idx *ReferenceIndex) AddReference(physicalPath, hash string) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
-	if idx.data[physicalPath] == nil {
-		idx.data[physicalPath] = make(map[string]bool)
-	}
-	idx.data[physicalPath][hash] = true
-	return idx.persistLocked()
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(referenceKey(physicalPath, hash)), nil)
+	})
 }
 
 // RemoveReference removes a hash reference from a physical file path.
 func (idx *ReferenceIndex) RemoveReference(physicalPath, hash string) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
-	if hashSet, exists := idx.data[physicalPath]; exists {
-		delete(hashSet, hash)
-		if len(hashSet) == 0 {
-			delete(idx.data, physicalPath)
-		}
-		return idx.persistLocked()
-	}
-	return nil
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(referenceKey(physicalPath, hash)))
+	})
 }
 
 // GetReferenceCount returns the number of references to a physical file.
 func (idx *ReferenceIndex) GetReferenceCount(physicalPath string) int {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
-	return len(idx.data[physicalPath])
+	return len(idx.GetReferences(physicalPath))
 }
 
 // GetReferences returns all hashes that reference a physical file.
 func (idx *ReferenceIndex) GetReferences(physicalPath string) []string {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
-	hashSet := idx.data[physicalPath]
-	if hashSet == nil {
-		return nil
-	}
-	hashes := make([]string, 0, len(hashSet))
-	for hash := range hashSet {
-		hashes = append(hashes, hash)
-	}
+	var hashes []string
+	_ = idx.store.db.View(func(txn *badger.Txn) error {
+		return scanPrefix(txn, refKeyPrefix+physicalPath+"\x00", false, func(key string, _ []byte) (bool, error) {
+			hashes = append(hashes, hashFromIndexKey(key))
+			return true, nil
+		})
+	})
 	return hashes
 }
 
@@ -227,11 +190,14 @@ func (m *Manager) CopyFile(req CopyRequest) (*CopyResult, error) {
 	if m.checkNameConflictInCategory(newHash, newName, newCategory) {
 		return nil, fmt.Errorf("%w: file with name %s already exists in category %s", ErrCopyConflict, newName, newCategory)
 	}
+	if err := m.checkQuotaLocked(original.Size); err != nil {
+		return nil, err
+	}
 
 	originalPath := filepath.Join(m.root, original.StoredPath)
 
 	// Verify original file exists
-	if _, err := os.Stat(originalPath); err != nil {
+	if _, err := m.backend.Stat(original.StoredPath); err != nil {
 		return nil, fmt.Errorf("original file not found: %w", err)
 	}
 
@@ -266,10 +232,6 @@ func (m *Manager) CopyFile(req CopyRequest) (*CopyResult, error) {
 	} else {
 		// Full copy mode: duplicate the physical file
 		components := m.classifier.Classify(original.MimeType, newName, newCategory)
-		fullDir := filepath.Join(append([]string{m.storageRoot}, components...)...)
-		if err := os.MkdirAll(fullDir, 0o755); err != nil {
-			return nil, fmt.Errorf("failed to create directory: %w", err)
-		}
 
 		base := strings.TrimSuffix(newName, filepath.Ext(newName))
 		if base == "" {
@@ -282,20 +244,12 @@ func (m *Manager) CopyFile(req CopyRequest) (*CopyResult, error) {
 		}
 
 		filename := fmt.Sprintf("%s_%s%s", newHash[:12], base, ext)
-		newPath = filepath.Join(fullDir, filename)
+		newPath = blobKey(append(append([]string{"storage"}, components...), filename)...)
 
 		// Copy the file
-		if err := copyFile(originalPath, newPath); err != nil {
+		if err := m.backend.Copy(original.StoredPath, newPath); err != nil {
 			return nil, fmt.Errorf("failed to copy file: %w", err)
 		}
-
-		// Get relative path
-		rel, err := filepath.Rel(m.root, newPath)
-		if err != nil {
-			_ = os.Remove(newPath)
-			return nil, fmt.Errorf("failed to compute relative path: %w", err)
-		}
-		newPath = filepath.ToSlash(rel)
 	}
 
 	// Merge metadata
@@ -321,6 +275,8 @@ func (m *Manager) CopyFile(req CopyRequest) (*CopyResult, error) {
 		Size:         original.Size,
 		UploadedAt:   time.Now().UTC(),
 		Metadata:     newMetadata,
+		Extracted:    original.Extracted,
+		Tags:         append([]string(nil), original.Tags...),
 	}
 
 	// Add to index
@@ -330,10 +286,14 @@ func (m *Manager) CopyFile(req CopyRequest) (*CopyResult, error) {
 			_ = m.referenceIndex.RemoveReference(originalPath, original.Hash)
 			_ = m.referenceIndex.RemoveReference(originalPath, newHash)
 		} else {
-			_ = os.Remove(filepath.Join(m.root, newPath))
+			_ = m.backend.Delete(newPath)
 		}
 		return nil, fmt.Errorf("failed to add metadata: %w", err)
 	}
+	if err := m.contentIndex.Copy(original.Hash, newHash); err != nil {
+		fmt.Fprintf(os.Stderr, "content index update failed for %s: %v\n", newHash, err)
+	}
+	m.copyExtractedTextLocked(original.Hash, newMeta)
 
 	// Log the copy operation
 	logEntry := CopyLog{
@@ -360,8 +320,8 @@ func (m *Manager) CopyFile(req CopyRequest) (*CopyResult, error) {
 
 // checkNameConflictInCategory checks if a name conflicts in a specific category.
 func (m *Manager) checkNameConflictInCategory(excludeHash, name, category string) bool {
-	for hash, meta := range m.index.data {
-		if hash == excludeHash {
+	for _, meta := range m.index.FindByCategory(category) {
+		if meta.Hash == excludeHash {
 			continue
 		}
 		if meta.Category == category && strings.EqualFold(meta.OriginalName, name) {
@@ -381,29 +341,6 @@ func generateCopyHash(originalHash, newName string, timestamp time.Time) string
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
-// copyFile copies a file from source to destination.
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer destFile.Close()
-
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		_ = os.Remove(dst)
-		return err
-	}
-
-	return destFile.Sync()
-}
-
 // logCopy appends a copy operation to the audit log.
 func (m *Manager) logCopy(log CopyLog) error {
 	logPath := filepath.Join(m.root, "metadata", "copy_log.ndjson")
@@ -357,6 +357,29 @@ func TestCopyFile_HardLinkDeletion(t *testing.T) {
 	}
 }
 
+func TestCopyFile_HardLinkKeepsOriginalPathLookup(t *testing.T) {
+	m := newTestManager(t)
+	defer m.Close()
+	original := storeContent(t, m, "a.txt", "text/plain", "shared contents")
+
+	copyResult, err := m.CopyFile(CopyRequest{Hash: original.Hash, NewName: "b.txt", HardLink: true})
+	if err != nil {
+		t.Fatalf("failed to create hard link: %v", err)
+	}
+	if _, err := m.DeleteFile(DeleteRequest{Hash: copyResult.NewHash}); err != nil {
+		t.Fatalf("failed to delete copy: %v", err)
+	}
+
+	result, err := m.GetFileByPath(original.StoredPath)
+	if err != nil {
+		t.Fatalf("original lost its path entry: %v", err)
+	}
+	defer result.Reader.Close()
+	if result.Metadata.Hash != original.Hash {
+		t.Fatalf("path resolved to %s, want %s", result.Metadata.Hash, original.Hash)
+	}
+}
+
 func TestReferenceIndex(t *testing.T) {
 	tmpDir := t.TempDir()
 	refPath := filepath.Join(tmpDir, "references.json")
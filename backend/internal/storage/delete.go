@@ -23,12 +23,22 @@ type DeleteResult struct {
 	OriginalName string    `json:"original_name"`
 	StoredPath   string    `json:"stored_path"`
 	Deleted      bool      `json:"deleted"`
+	Trashed      bool      `json:"trashed"`
 	DeletedAt    time.Time `json:"deleted_at"`
 	Message      string    `json:"message,omitempty"`
 }
 
+// Delete log actions. Entries written before trash support have no action and
+// are equivalent to DeleteActionTrash.
+const (
+	DeleteActionTrash   = "trash"
+	DeleteActionRestore = "restore"
+	DeleteActionPurge   = "purge"
+)
+
 // DeleteLog captures audit trail for deletion operations.
 type DeleteLog struct {
+	Action       string    `json:"action,omitempty"`
 	Hash         string    `json:"hash"`
 	OriginalName string    `json:"original_name"`
 	StoredPath   string    `json:"stored_path"`
@@ -38,7 +48,9 @@ type DeleteLog struct {
 	DeletedAt    time.Time `json:"deleted_at"`
 }
 
-// DeleteFile deletes a file identified by hash, removing both the physical file and metadata.
+// DeleteFile moves a file identified by hash to the trash. Its metadata leaves
+// the live index but is kept with the trash entry so RestoreFile can bring it
+// back; PurgeTrash removes it for good.
 func (m *Manager) DeleteFile(req DeleteRequest) (*DeleteResult, error) {
 	if req.Hash == "" {
 		return nil, fmt.Errorf("%w: hash is required", ErrInvalidInput)
@@ -58,52 +70,49 @@ func (m *Manager) DeleteFile(req DeleteRequest) (*DeleteResult, error) {
 
 	// Check if this is a hard link (has references)
 	filePath := filepath.Join(m.root, existing.StoredPath)
-	shouldDeletePhysicalFile := true
-	
+	shouldMovePhysicalFile := true
+
 	if m.referenceIndex != nil {
 		refCount := m.referenceIndex.GetReferenceCount(filePath)
 		if refCount > 1 {
-			// This is a hard link with other references - only delete metadata
-			shouldDeletePhysicalFile = false
+			// This is a hard link with other references - only trash metadata
+			shouldMovePhysicalFile = false
 		}
 	}
 
-	// Delete metadata first to maintain consistency
-	// If metadata deletion fails, we haven't touched the physical file yet
+	// Move the contents aside first so a failure leaves the file fully live
+	entry := TrashEntry{Metadata: *existing, DeletedAt: deletedAt}
+	if shouldMovePhysicalFile {
+		trashKey := blobKey(trashBlobDir, existing.Hash)
+		if err := m.backend.Move(existing.StoredPath, trashKey); err != nil {
+			// A missing blob is okay - the metadata still goes to the trash
+			if !errors.Is(err, ErrBlobNotFound) {
+				return nil, fmt.Errorf("failed to move file to trash: %w", err)
+			}
+		} else {
+			entry.BlobKey = trashKey
+		}
+	}
+
+	if err := m.trashIndex.Put(entry); err != nil {
+		m.undoTrashMove(entry)
+		return nil, fmt.Errorf("failed to record trash entry: %w", err)
+	}
 	if err := m.index.Delete(req.Hash); err != nil {
+		_ = m.trashIndex.Delete(req.Hash)
+		m.undoTrashMove(entry)
 		return nil, fmt.Errorf("failed to delete metadata: %w", err)
 	}
+	m.unindexContentLocked(req.Hash)
 
-	// Remove reference if it exists and check if we should delete physical file
+	// Drop this hash's hard-link reference; the remaining links keep the blob alive
 	if m.referenceIndex != nil {
 		_ = m.referenceIndex.RemoveReference(filePath, req.Hash)
-		// After removal, check if there are any remaining references
-		remainingRefs := m.referenceIndex.GetReferenceCount(filePath)
-		if remainingRefs > 0 {
-			shouldDeletePhysicalFile = false
-		}
-	}
-
-	// Delete the physical file only if it's not a hard link or it's the last reference
-	if shouldDeletePhysicalFile {
-		if err := os.Remove(filePath); err != nil {
-			// If file doesn't exist on disk, that's okay - metadata is already deleted
-			// If it's a different error, attempt to rollback metadata deletion
-			if !errors.Is(err, os.ErrNotExist) {
-				// Attempt to restore metadata entry
-				// Note: This is best-effort; if it fails, we log the inconsistency
-				if restoreErr := m.index.Add(*existing); restoreErr != nil {
-					// Log both errors but return the original file deletion error
-					// The metadata is now inconsistent (deleted but file still exists)
-					return nil, fmt.Errorf("failed to delete file (metadata rollback also failed): %w (rollback: %v)", err, restoreErr)
-				}
-				return nil, fmt.Errorf("failed to delete file: %w", err)
-			}
-		}
 	}
 
 	// Log the deletion operation
 	logEntry := DeleteLog{
+		Action:       DeleteActionTrash,
 		Hash:         existing.Hash,
 		OriginalName: existing.OriginalName,
 		StoredPath:   existing.StoredPath,
@@ -123,11 +132,22 @@ func (m *Manager) DeleteFile(req DeleteRequest) (*DeleteResult, error) {
 		OriginalName: existing.OriginalName,
 		StoredPath:   existing.StoredPath,
 		Deleted:      true,
+		Trashed:      true,
 		DeletedAt:    deletedAt,
-		Message:      fmt.Sprintf("deleted file %s", existing.OriginalName),
+		Message:      fmt.Sprintf("moved file %s to trash", existing.OriginalName),
 	}, nil
 }
 
+// undoTrashMove puts trashed contents back after a failed delete (best-effort).
+func (m *Manager) undoTrashMove(entry TrashEntry) {
+	if entry.BlobKey == "" {
+		return
+	}
+	if err := m.backend.Move(entry.BlobKey, entry.Metadata.StoredPath); err != nil {
+		fmt.Fprintf(os.Stderr, "trash rollback failed for %s: %v\n", entry.Metadata.Hash, err)
+	}
+}
+
 // logDelete appends a deletion operation to the audit log.
 func (m *Manager) logDelete(log DeleteLog) error {
 	logPath := filepath.Join(m.root, "metadata", "delete_log.ndjson")
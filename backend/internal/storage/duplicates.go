@@ -131,10 +131,12 @@ func (m *Manager) ScanForDuplicates(req DuplicateScanRequest) (*DuplicateScanRes
 	
 	m.mu.Lock()
 	// Collect all metadata entries
-	for _, meta := range m.index.data {
+	totalFiles := 0
+	_ = m.index.ForEach(func(meta FileMetadata) bool {
 		hashGroups[meta.Hash] = append(hashGroups[meta.Hash], meta)
-	}
-	totalFiles := len(m.index.data)
+		totalFiles++
+		return true
+	})
 	m.mu.Unlock()
 
 	// If deep scan is requested, verify hashes on disk
@@ -205,12 +207,12 @@ func (m *Manager) ScanForDuplicates(req DuplicateScanRequest) (*DuplicateScanRes
 func (m *Manager) verifyHashesOnDisk(hashGroups map[string][]FileMetadata) error {
 	for hash, files := range hashGroups {
 		for _, file := range files {
-			fullPath := filepath.Join(m.root, file.StoredPath)
+			fullPath := file.StoredPath
 			
 			// Open file and compute hash
-			f, err := os.Open(fullPath)
+			f, err := m.backend.Open(fullPath)
 			if err != nil {
-				if os.IsNotExist(err) {
+				if errors.Is(err, ErrBlobNotFound) {
 					// File missing - will be caught by verification
 					continue
 				}
@@ -259,11 +261,12 @@ func (m *Manager) VerifyDeduplicationSystem() (*VerificationResult, error) {
 
 	// Count files in metadata index
 	m.mu.Lock()
-	metadataCount := int64(len(m.index.data))
 	metadataMap := make(map[string]FileMetadata)
-	for hash, meta := range m.index.data {
-		metadataMap[hash] = meta
-	}
+	_ = m.index.ForEach(func(meta FileMetadata) bool {
+		metadataMap[meta.Hash] = meta
+		return true
+	})
+	metadataCount := int64(len(metadataMap))
 	m.mu.Unlock()
 
 	result.MetadataIndexCount = metadataCount
@@ -278,7 +281,10 @@ func (m *Manager) VerifyDeduplicationSystem() (*VerificationResult, error) {
 			return err
 		}
 
-		// Skip directories and temp files
+		// Skip derivatives, directories and temp files
+		if info.IsDir() && info.Name() == thumbnailDirName {
+			return filepath.SkipDir
+		}
 		if info.IsDir() || filepath.Base(path) == ".tmp" {
 			return nil
 		}
@@ -395,13 +401,11 @@ func (m *Manager) MergeDuplicates(req MergeRequest) (*MergeResult, error) {
 	var keepMeta *FileMetadata
 	var removeMetas []FileMetadata
 
-	for _, meta := range m.index.data {
-		if meta.Hash == req.Hash {
-			if meta.StoredPath == req.Keep {
-				keepMeta = &meta
-			} else {
-				removeMetas = append(removeMetas, meta)
-			}
+	if meta := m.index.FindByHash(req.Hash); meta != nil {
+		if meta.StoredPath == req.Keep {
+			keepMeta = meta
+		} else {
+			removeMetas = append(removeMetas, *meta)
 		}
 	}
 
@@ -420,9 +424,8 @@ func (m *Manager) MergeDuplicates(req MergeRequest) (*MergeResult, error) {
 	if req.RemoveOthers {
 		for _, meta := range removeMetas {
 			// Delete physical file
-			fullPath := filepath.Join(m.root, meta.StoredPath)
-			if err := os.Remove(fullPath); err != nil {
-				if !os.IsNotExist(err) {
+			if err := m.backend.Delete(meta.StoredPath); err != nil {
+				if !errors.Is(err, ErrBlobNotFound) {
 					return nil, fmt.Errorf("failed to remove file %s: %w", meta.StoredPath, err)
 				}
 			} else {
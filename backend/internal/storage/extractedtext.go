@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/textextract"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ErrTextExtractionUnsupported is returned for files that are not PDF, OOXML,
+// OpenDocument or EPUB documents, or whose contents are not recognised as such.
+var ErrTextExtractionUnsupported = errors.New("text extraction is not supported for this file")
+
+// extractedTextKeyPrefix namespaces extracted-text records inside the shared index store.
+const extractedTextKeyPrefix = "text:"
+
+// extractedTextDirName is the hidden directory, next to the original, that
+// holds the plain text extracted from it.
+const extractedTextDirName = ".text"
+
+// ExtractedText describes the plain-text derivative of a document.
+type ExtractedText struct {
+	Hash   string `json:"hash"`
+	Key    string `json:"key"`
+	Format string `json:"format"`
+	Bytes  int64  `json:"bytes"`
+	// Truncated reports that the text was cut at textextract.MaxTextSize.
+	Truncated   bool      `json:"truncated,omitempty"`
+	ExtractedAt time.Time `json:"extracted_at"`
+}
+
+// ExtractedTextIndex persists extracted-text records in the embedded index
+// store, keyed by hash.
+type ExtractedTextIndex struct {
+	store *kvStore
+	mu    sync.RWMutex
+}
+
+// NewExtractedTextIndex opens the extracted-text index in the store beside path.
+func NewExtractedTextIndex(path string) (*ExtractedTextIndex, error) {
+	store, err := openKVStore(kvDirFor(path))
+	if err != nil {
+		return nil, err
+	}
+	return &ExtractedTextIndex{store: store}, nil
+}
+
+// Close releases the index store.
+func (idx *ExtractedTextIndex) Close() error {
+	return idx.store.release()
+}
+
+// Put records text, replacing any previous record for the same hash.
+func (idx *ExtractedTextIndex) Put(text ExtractedText) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		return setJSON(txn, extractedTextKeyPrefix+text.Hash, text)
+	})
+}
+
+// Get returns the record for hash, or nil if no text was extracted.
+func (idx *ExtractedTextIndex) Get(hash string) (*ExtractedText, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var text ExtractedText
+	var found bool
+	err := idx.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		found, err = getJSON(txn, extractedTextKeyPrefix+hash, &text)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &text, nil
+}
+
+// Delete removes the record for hash.
+func (idx *ExtractedTextIndex) Delete(hash string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(extractedTextKeyPrefix + hash))
+	})
+}
+
+// ExtractedTextResult is an open text derivative ready to be served.
+type ExtractedTextResult struct {
+	Text   ExtractedText
+	Reader BlobReader
+}
+
+// SupportsTextExtraction reports whether text can be extracted from meta,
+// judged by its MIME type or, failing that, its file extension.
+func SupportsTextExtraction(meta FileMetadata) bool {
+	return textextract.Supported(meta.OriginalName, meta.MimeType)
+}
+
+// extractDocumentText extracts the text of a staged upload. Extraction is
+// best effort: unreadable or unrecognised documents yield nil, and a parse
+// error part-way through keeps whatever text was read before it.
+func extractDocumentText(path string) *textextract.Result {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil
+	}
+
+	result, _ := textextract.Extract(file, info.Size())
+	return result
+}
+
+// ExtractText (re)extracts the text of a stored document, replacing its
+// derivative and its content index entry. Parsing runs without the manager
+// lock; the result is discarded if the file was deleted or moved meanwhile.
+func (m *Manager) ExtractText(hash string) (*ExtractedText, error) {
+	meta := m.index.FindByHash(hash)
+	if meta == nil {
+		return nil, ErrFileNotFound
+	}
+	if !SupportsTextExtraction(*meta) {
+		return nil, ErrTextExtractionUnsupported
+	}
+
+	reader, err := m.backend.Open(meta.StoredPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := reader.Stat()
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	result, err := textextract.Extract(&blobReaderAt{r: reader}, info.Size())
+	reader.Close()
+	if result == nil {
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTextExtractionUnsupported, err)
+		}
+		return nil, ErrTextExtractionUnsupported
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := m.index.FindByHash(hash)
+	if current == nil || current.StoredPath != meta.StoredPath {
+		return nil, ErrFileNotFound
+	}
+	return m.storeExtractedTextLocked(*current, result)
+}
+
+// OpenExtractedText opens the text derivative of hash, extracting it first
+// if it is missing.
+func (m *Manager) OpenExtractedText(hash string) (*ExtractedTextResult, error) {
+	if m.index.FindByHash(hash) == nil {
+		return nil, ErrFileNotFound
+	}
+	text, err := m.extractedTextIndex.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if text != nil {
+		reader, err := m.backend.Open(text.Key)
+		if err == nil {
+			return &ExtractedTextResult{Text: *text, Reader: reader}, nil
+		}
+		if !errors.Is(err, ErrBlobNotFound) {
+			return nil, err
+		}
+	}
+
+	text, err = m.ExtractText(hash)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := m.backend.Open(text.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &ExtractedTextResult{Text: *text, Reader: reader}, nil
+}
+
+// storeExtractedTextLocked writes result as the text derivative of meta and
+// indexes it for content search. Caller holds m.mu.
+func (m *Manager) storeExtractedTextLocked(meta FileMetadata, result *textextract.Result) (*ExtractedText, error) {
+	text := ExtractedText{
+		Hash:        meta.Hash,
+		Key:         extractedTextKey(meta.StoredPath, meta.Hash),
+		Format:      result.Format,
+		Bytes:       int64(len(result.Text)),
+		Truncated:   result.Truncated,
+		ExtractedAt: time.Now().UTC(),
+	}
+	if err := m.backend.Put(text.Key, strings.NewReader(result.Text), text.Bytes); err != nil {
+		return nil, fmt.Errorf("store extracted text: %w", err)
+	}
+	if err := m.extractedTextIndex.Put(text); err != nil {
+		_ = m.backend.Delete(text.Key)
+		return nil, err
+	}
+	m.indexTextLocked(meta.Hash, result.Text)
+	return &text, nil
+}
+
+// extractedTextKey places a derivative in the hidden text directory beside storedPath.
+func extractedTextKey(storedPath, hash string) string {
+	return blobKey(path.Dir(storedPath), extractedTextDirName, hash+".txt")
+}
+
+// moveExtractedTextLocked follows a file to newStoredPath. A derivative that
+// cannot be moved is dropped; re-extraction recreates it. Caller holds m.mu.
+func (m *Manager) moveExtractedTextLocked(hash, newStoredPath string) {
+	text, err := m.extractedTextIndex.Get(hash)
+	if err != nil || text == nil {
+		return
+	}
+	newKey := extractedTextKey(newStoredPath, hash)
+	if err := m.backend.Move(text.Key, newKey); err != nil {
+		_ = m.backend.Delete(text.Key)
+		_ = m.extractedTextIndex.Delete(hash)
+		return
+	}
+	text.Key = newKey
+	_ = m.extractedTextIndex.Put(*text)
+}
+
+// copyExtractedTextLocked gives a copy of a document its own derivative.
+// Failures only cost a re-extraction. Caller holds m.mu.
+func (m *Manager) copyExtractedTextLocked(srcHash string, dst FileMetadata) {
+	text, err := m.extractedTextIndex.Get(srcHash)
+	if err != nil || text == nil {
+		return
+	}
+	newKey := extractedTextKey(dst.StoredPath, dst.Hash)
+	if err := m.backend.Copy(text.Key, newKey); err != nil {
+		return
+	}
+	text.Hash = dst.Hash
+	text.Key = newKey
+	if err := m.extractedTextIndex.Put(*text); err != nil {
+		_ = m.backend.Delete(newKey)
+	}
+}
+
+// deleteExtractedTextLocked removes the text derivative of hash. Caller holds m.mu.
+func (m *Manager) deleteExtractedTextLocked(hash string) error {
+	text, err := m.extractedTextIndex.Get(hash)
+	if err != nil || text == nil {
+		return err
+	}
+	_ = m.backend.Delete(text.Key)
+	return m.extractedTextIndex.Delete(hash)
+}
+
+// blobReaderAt adapts a BlobReader to io.ReaderAt by seeking before each
+// read. It is not safe for concurrent use.
+type blobReaderAt struct {
+	r BlobReader
+}
+
+func (b *blobReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := b.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(b.r, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const docxMime = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// testDOCX builds a minimal Word document with one paragraph per argument.
+func testDOCX(t *testing.T, paragraphs ...string) string {
+	t.Helper()
+	var body strings.Builder
+	for _, p := range paragraphs {
+		body.WriteString("<w:p><w:r><w:t>" + p + "</w:t></w:r></w:p>")
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("word/document.xml")
+	if err != nil {
+		t.Fatalf("create document.xml: %v", err)
+	}
+	w.Write([]byte(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` + body.String() + `</w:body></w:document>`))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close docx: %v", err)
+	}
+	return buf.String()
+}
+
+func readExtractedText(t *testing.T, m *Manager, hash string) (ExtractedText, string) {
+	t.Helper()
+	result, err := m.OpenExtractedText(hash)
+	if err != nil {
+		t.Fatalf("open extracted text: %v", err)
+	}
+	defer result.Reader.Close()
+	data, err := io.ReadAll(result.Reader)
+	if err != nil {
+		t.Fatalf("read extracted text: %v", err)
+	}
+	return result.Text, string(data)
+}
+
+func TestStoreFileExtractsDocumentText(t *testing.T) {
+	m := newTestManager(t)
+	meta := storeContent(t, m, "plan.docx", docxMime, testDOCX(t, "Migration plan", "Cut over the billing database"))
+	storeContent(t, m, "other.docx", docxMime, testDOCX(t, "Unrelated memo"))
+
+	text, body := readExtractedText(t, m, meta.Hash)
+	if text.Format != "docx" || body != "Migration plan\nCut over the billing database" {
+		t.Fatalf("unexpected derivative %+v %q", text, body)
+	}
+	if !strings.Contains(text.Key, "/"+extractedTextDirName+"/") {
+		t.Fatalf("expected derivative in the hidden text directory, got %s", text.Key)
+	}
+	if got := contentNames(t, m, SearchFilters{ContentSearch: `"billing database"`}); !reflect.DeepEqual(got, []string{"plan.docx"}) {
+		t.Fatalf("expected document to be searchable, got %v", got)
+	}
+
+	// A document stored before extraction existed is picked up by ExtractText.
+	m.mu.Lock()
+	if err := m.deleteExtractedTextLocked(meta.Hash); err != nil {
+		t.Fatalf("drop derivative: %v", err)
+	}
+	m.unindexContentLocked(meta.Hash)
+	m.mu.Unlock()
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "billing"}); len(got) != 0 {
+		t.Fatalf("expected no results without a derivative, got %v", got)
+	}
+	if _, err := m.ExtractText(meta.Hash); err != nil {
+		t.Fatalf("extract text: %v", err)
+	}
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "billing"}); !reflect.DeepEqual(got, []string{"plan.docx"}) {
+		t.Fatalf("expected re-extracted document to be searchable, got %v", got)
+	}
+
+	// Rebuilding the content index reads the derivative, not the raw document.
+	if _, err := m.RebuildContentIndex(); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "memo"}); !reflect.DeepEqual(got, []string{"other.docx"}) {
+		t.Fatalf("expected rebuild to index derivatives, got %v", got)
+	}
+}
+
+func TestExtractTextRejectsOtherFiles(t *testing.T) {
+	m := newTestManager(t)
+	plain := storeContent(t, m, "notes.txt", "text/plain", "just text")
+	fake := storeContent(t, m, "fake.pdf", "application/pdf", "not really a pdf")
+
+	if _, err := m.ExtractText(plain.Hash); !errors.Is(err, ErrTextExtractionUnsupported) {
+		t.Fatalf("text file: expected ErrTextExtractionUnsupported, got %v", err)
+	}
+	if _, err := m.ExtractText(fake.Hash); !errors.Is(err, ErrTextExtractionUnsupported) {
+		t.Fatalf("unrecognised content: expected ErrTextExtractionUnsupported, got %v", err)
+	}
+	if _, err := m.ExtractText("missing"); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("missing file: expected ErrFileNotFound, got %v", err)
+	}
+}
+
+func TestExtractedTextFollowsFileLifecycle(t *testing.T) {
+	m := newTestManager(t)
+	meta := storeContent(t, m, "spec.docx", docxMime, testDOCX(t, "Interface specification"))
+
+	if _, err := m.MoveFile(MoveRequest{Hash: meta.Hash, NewCategory: "archive/specs"}); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	moved, _ := readExtractedText(t, m, meta.Hash)
+	if !strings.HasPrefix(moved.Key, "storage/archive/specs/") {
+		t.Fatalf("expected derivative to follow the move, got %s", moved.Key)
+	}
+
+	copied, err := m.CopyFile(CopyRequest{Hash: meta.Hash, NewName: "spec_copy.docx"})
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	copyText, body := readExtractedText(t, m, copied.NewMeta.Hash)
+	if copyText.Key == moved.Key || body != "Interface specification" {
+		t.Fatalf("expected the copy to get its own derivative, got %+v %q", copyText, body)
+	}
+
+	if _, err := m.DeleteFile(DeleteRequest{Hash: meta.Hash}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := m.PurgeTrash(time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if text, err := m.extractedTextIndex.Get(meta.Hash); err != nil || text != nil {
+		t.Fatalf("purge should drop the derivative record, got %+v, %v", text, err)
+	}
+	if _, err := m.backend.Stat(moved.Key); !errors.Is(err, ErrBlobNotFound) {
+		t.Fatalf("purge should remove the derivative blob: %v", err)
+	}
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "specification"}); !reflect.DeepEqual(got, []string{"spec_copy.docx"}) {
+		t.Fatalf("expected only the copy to remain searchable, got %v", got)
+	}
+}
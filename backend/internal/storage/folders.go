@@ -0,0 +1,806 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrFolderNotFound is returned when no virtual folder has the requested ID.
+	ErrFolderNotFound = errors.New("folder not found")
+	// ErrFolderExists is returned when a sibling folder already has the name.
+	ErrFolderExists = errors.New("folder already exists")
+	// ErrFolderNotEmpty is returned when deleting a folder with subfolders
+	// without asking for a recursive delete.
+	ErrFolderNotEmpty = errors.New("folder has subfolders")
+	// ErrInvalidFolder is returned for invalid folder names, parents and orders.
+	ErrInvalidFolder = errors.New("invalid folder")
+)
+
+const (
+	// folderKeyPrefix holds one Folder record per folder.
+	folderKeyPrefix = "vfolder:"
+	// folderFilesPrefix holds the ordered file references of a folder.
+	folderFilesPrefix = "vfiles:"
+	// folderMemberPrefix indexes folder membership by file: vfmember:<hash>:<id>.
+	folderMemberPrefix = "vfmember:"
+
+	// maxFolderNameLength bounds a folder name in bytes.
+	maxFolderNameLength = 255
+	// maxFolderDepth bounds how deeply folders nest.
+	maxFolderDepth = 32
+	// maxFolderFiles bounds the files referenced by one folder.
+	maxFolderFiles = 10000
+	// maxFolderBatch bounds the files of one add or remove request.
+	maxFolderBatch = 1000
+)
+
+// Folder is a user-defined virtual folder. Folders reference files by hash
+// without moving them, so a file can sit in any number of folders.
+type Folder struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ParentID    string    `json:"parent_id,omitempty"`
+	Description string    `json:"description,omitempty"`
+	FileCount   int       `json:"file_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Path is the /-joined names from the root down to the folder. It is
+	// derived when the folder is read and not stored.
+	Path string `json:"path,omitempty"`
+}
+
+// FolderEntry is one file reference inside a folder, in folder order.
+type FolderEntry struct {
+	Hash    string    `json:"hash"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+func folderFilesKey(id string) string {
+	return folderFilesPrefix + id
+}
+
+func folderMemberKey(hash, id string) string {
+	return folderMemberPrefix + hash + ":" + id
+}
+
+// FolderIndex persists virtual folders in the embedded index store.
+type FolderIndex struct {
+	store *kvStore
+	mu    sync.Mutex
+}
+
+// NewFolderIndex opens the folder index in the store beside path.
+func NewFolderIndex(path string) (*FolderIndex, error) {
+	store, err := openKVStore(kvDirFor(path))
+	if err != nil {
+		return nil, err
+	}
+	return &FolderIndex{store: store}, nil
+}
+
+// Close releases the index store.
+func (idx *FolderIndex) Close() error {
+	return idx.store.release()
+}
+
+func getFolder(txn *badger.Txn, id string) (*Folder, error) {
+	var folder Folder
+	found, err := getJSON(txn, folderKeyPrefix+id, &folder)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrFolderNotFound, id)
+	}
+	return &folder, nil
+}
+
+func putFolder(txn *badger.Txn, folder Folder) error {
+	folder.Path = ""
+	return setJSON(txn, folderKeyPrefix+folder.ID, folder)
+}
+
+// allFolders loads every folder keyed by ID.
+func allFolders(txn *badger.Txn) (map[string]*Folder, error) {
+	folders := make(map[string]*Folder)
+	err := scanPrefix(txn, folderKeyPrefix, true, func(_ string, val []byte) (bool, error) {
+		var folder Folder
+		if err := json.Unmarshal(val, &folder); err != nil {
+			return false, err
+		}
+		folders[folder.ID] = &folder
+		return true, nil
+	})
+	return folders, err
+}
+
+// folderPath returns the path of id and its depth, walking up through folders.
+func folderPath(folders map[string]*Folder, id string) (string, int) {
+	var names []string
+	for id != "" && len(names) <= maxFolderDepth {
+		folder, ok := folders[id]
+		if !ok {
+			break
+		}
+		names = append(names, folder.Name)
+		id = folder.ParentID
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return "/" + strings.Join(names, "/"), len(names)
+}
+
+// isDescendant reports whether id is ancestor or sits below it.
+func isDescendant(folders map[string]*Folder, id, ancestor string) bool {
+	for depth := 0; id != "" && depth <= maxFolderDepth; depth++ {
+		if id == ancestor {
+			return true
+		}
+		folder, ok := folders[id]
+		if !ok {
+			return false
+		}
+		id = folder.ParentID
+	}
+	return false
+}
+
+func getFolderEntries(txn *badger.Txn, id string) ([]FolderEntry, error) {
+	var entries []FolderEntry
+	_, err := getJSON(txn, folderFilesKey(id), &entries)
+	return entries, err
+}
+
+// putFolderEntries stores the references of folder and keeps its file count
+// and the membership keys in step with them.
+func putFolderEntries(txn *badger.Txn, folder *Folder, old, entries []FolderEntry) error {
+	keep := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		keep[entry.Hash] = true
+	}
+	for _, entry := range old {
+		if !keep[entry.Hash] {
+			if err := txn.Delete([]byte(folderMemberKey(entry.Hash, folder.ID))); err != nil {
+				return err
+			}
+		}
+	}
+	for _, entry := range entries {
+		if err := txn.Set([]byte(folderMemberKey(entry.Hash, folder.ID)), nil); err != nil {
+			return err
+		}
+	}
+	if len(entries) == 0 {
+		if err := txn.Delete([]byte(folderFilesKey(folder.ID))); err != nil {
+			return err
+		}
+	} else if err := setJSON(txn, folderFilesKey(folder.ID), entries); err != nil {
+		return err
+	}
+	folder.FileCount = len(entries)
+	folder.UpdatedAt = time.Now().UTC()
+	return putFolder(txn, *folder)
+}
+
+// normalizeFolderName trims name and rejects names that are empty, too long
+// or contain a slash or control characters.
+func normalizeFolderName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidFolder)
+	}
+	if len(name) > maxFolderNameLength {
+		return "", fmt.Errorf("%w: name is longer than %d bytes", ErrInvalidFolder, maxFolderNameLength)
+	}
+	for _, r := range name {
+		if r == '/' || unicode.IsControl(r) {
+			return "", fmt.Errorf("%w: name %q contains %q", ErrInvalidFolder, name, r)
+		}
+	}
+	return name, nil
+}
+
+// checkPlacement verifies that a folder called name can live under parentID.
+// self is the folder being moved or renamed, or empty for a new folder.
+func checkPlacement(folders map[string]*Folder, self, parentID, name string) error {
+	depth := 0
+	if parentID != "" {
+		if _, ok := folders[parentID]; !ok {
+			return fmt.Errorf("%w: parent %s", ErrFolderNotFound, parentID)
+		}
+		if self != "" && isDescendant(folders, parentID, self) {
+			return fmt.Errorf("%w: a folder cannot be moved into itself", ErrInvalidFolder)
+		}
+		_, depth = folderPath(folders, parentID)
+	}
+	if depth+subtreeHeight(folders, self) > maxFolderDepth {
+		return fmt.Errorf("%w: folders nest at most %d levels deep", ErrInvalidFolder, maxFolderDepth)
+	}
+	for _, sibling := range folders {
+		if sibling.ID != self && sibling.ParentID == parentID && strings.EqualFold(sibling.Name, name) {
+			return fmt.Errorf("%w: %q", ErrFolderExists, name)
+		}
+	}
+	return nil
+}
+
+// subtreeHeight returns the number of levels of the folder id and everything
+// below it, or 1 for a folder that does not exist yet.
+func subtreeHeight(folders map[string]*Folder, id string) int {
+	height := 1
+	if id == "" {
+		return height
+	}
+	for _, folder := range folders {
+		if folder.ParentID == id {
+			if h := 1 + subtreeHeight(folders, folder.ID); h > height {
+				height = h
+			}
+		}
+	}
+	return height
+}
+
+// CreateFolderRequest describes a new virtual folder. An empty ParentID
+// creates a top-level folder.
+type CreateFolderRequest struct {
+	Name        string `json:"name"`
+	ParentID    string `json:"parent_id"`
+	Description string `json:"description"`
+}
+
+// UpdateFolderRequest renames, describes or moves a folder. Nil fields are
+// left unchanged; an empty ParentID moves the folder to the top level.
+type UpdateFolderRequest struct {
+	Name        *string `json:"name"`
+	ParentID    *string `json:"parent_id"`
+	Description *string `json:"description"`
+}
+
+// FolderFilesRequest adds files to or removes files from a folder. Position
+// is the 0-based index added files are inserted at; nil appends them.
+type FolderFilesRequest struct {
+	Hashes   []string `json:"hashes"`
+	Position *int     `json:"position,omitempty"`
+}
+
+// FolderFilesResult reports the outcome of a FolderFilesRequest. Skipped
+// lists the hashes that were already in the folder (when adding) or not in
+// it (when removing); Missing lists hashes of files that do not exist.
+type FolderFilesResult struct {
+	FolderID  string   `json:"folder_id"`
+	Added     int      `json:"added,omitempty"`
+	Removed   int      `json:"removed,omitempty"`
+	Skipped   []string `json:"skipped,omitempty"`
+	Missing   []string `json:"missing,omitempty"`
+	FileCount int      `json:"file_count"`
+}
+
+// DeleteFolderResult reports the folders removed by DeleteFolder.
+type DeleteFolderResult struct {
+	Deleted []string `json:"deleted"`
+}
+
+// CreateFolder creates a virtual folder.
+func (m *Manager) CreateFolder(req CreateFolderRequest) (*Folder, error) {
+	name, err := normalizeFolderName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	idx := m.folderIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	now := time.Now().UTC()
+	folder := Folder{
+		ID:          uuid.New().String(),
+		Name:        name,
+		ParentID:    strings.TrimSpace(req.ParentID),
+		Description: strings.TrimSpace(req.Description),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = idx.store.db.Update(func(txn *badger.Txn) error {
+		folders, err := allFolders(txn)
+		if err != nil {
+			return err
+		}
+		if err := checkPlacement(folders, "", folder.ParentID, name); err != nil {
+			return err
+		}
+		if err := putFolder(txn, folder); err != nil {
+			return err
+		}
+		folders[folder.ID] = &folder
+		folder.Path, _ = folderPath(folders, folder.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// GetFolder returns the folder with id.
+func (m *Manager) GetFolder(id string) (*Folder, error) {
+	var folder *Folder
+	err := m.folderIndex.store.db.View(func(txn *badger.Txn) error {
+		folders, err := allFolders(txn)
+		if err != nil {
+			return err
+		}
+		found, ok := folders[id]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrFolderNotFound, id)
+		}
+		found.Path, _ = folderPath(folders, id)
+		folder = found
+		return nil
+	})
+	return folder, err
+}
+
+// ListFolders returns the direct subfolders of parentID sorted by name, or
+// the top-level folders when parentID is empty.
+func (m *Manager) ListFolders(parentID string) ([]Folder, error) {
+	children := make([]Folder, 0)
+	err := m.folderIndex.store.db.View(func(txn *badger.Txn) error {
+		folders, err := allFolders(txn)
+		if err != nil {
+			return err
+		}
+		if _, ok := folders[parentID]; parentID != "" && !ok {
+			return fmt.Errorf("%w: %s", ErrFolderNotFound, parentID)
+		}
+		for _, folder := range folders {
+			if folder.ParentID == parentID {
+				folder.Path, _ = folderPath(folders, folder.ID)
+				children = append(children, *folder)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortFolders(children)
+	return children, nil
+}
+
+func sortFolders(folders []Folder) {
+	sort.Slice(folders, func(i, j int) bool {
+		a, b := strings.ToLower(folders[i].Name), strings.ToLower(folders[j].Name)
+		if a != b {
+			return a < b
+		}
+		return folders[i].ID < folders[j].ID
+	})
+}
+
+// UpdateFolder renames, describes or moves the folder with id.
+func (m *Manager) UpdateFolder(id string, req UpdateFolderRequest) (*Folder, error) {
+	idx := m.folderIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var folder *Folder
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		folders, err := allFolders(txn)
+		if err != nil {
+			return err
+		}
+		current, ok := folders[id]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrFolderNotFound, id)
+		}
+		updated := *current
+		if req.Name != nil {
+			if updated.Name, err = normalizeFolderName(*req.Name); err != nil {
+				return err
+			}
+		}
+		if req.ParentID != nil {
+			updated.ParentID = strings.TrimSpace(*req.ParentID)
+		}
+		if req.Description != nil {
+			updated.Description = strings.TrimSpace(*req.Description)
+		}
+		if err := checkPlacement(folders, id, updated.ParentID, updated.Name); err != nil {
+			return err
+		}
+		updated.UpdatedAt = time.Now().UTC()
+		if err := putFolder(txn, updated); err != nil {
+			return err
+		}
+		folders[id] = &updated
+		updated.Path, _ = folderPath(folders, id)
+		folder = &updated
+		return nil
+	})
+	return folder, err
+}
+
+// DeleteFolder removes a folder and its file references. The files
+// themselves are untouched. A folder with subfolders is only removed, along
+// with everything below it, when recursive is set.
+func (m *Manager) DeleteFolder(id string, recursive bool) (*DeleteFolderResult, error) {
+	idx := m.folderIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	result := &DeleteFolderResult{}
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		folders, err := allFolders(txn)
+		if err != nil {
+			return err
+		}
+		if _, ok := folders[id]; !ok {
+			return fmt.Errorf("%w: %s", ErrFolderNotFound, id)
+		}
+		var doomed []*Folder
+		for _, folder := range folders {
+			if folder.ID != id && isDescendant(folders, folder.ID, id) {
+				doomed = append(doomed, folder)
+			}
+		}
+		if len(doomed) > 0 && !recursive {
+			return fmt.Errorf("%w: delete the %d subfolders first or delete recursively", ErrFolderNotEmpty, len(doomed))
+		}
+		doomed = append(doomed, folders[id])
+		for _, folder := range doomed {
+			entries, err := getFolderEntries(txn, folder.ID)
+			if err != nil {
+				return err
+			}
+			if err := putFolderEntries(txn, folder, entries, nil); err != nil {
+				return err
+			}
+			if err := txn.Delete([]byte(folderKeyPrefix + folder.ID)); err != nil {
+				return err
+			}
+			result.Deleted = append(result.Deleted, folder.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkFolderBatch validates the hashes of a FolderFilesRequest.
+func checkFolderBatch(hashes []string) error {
+	if len(hashes) == 0 {
+		return fmt.Errorf("%w: no files provided", ErrInvalidFolder)
+	}
+	if len(hashes) > maxFolderBatch {
+		return fmt.Errorf("%w: at most %d files per request", ErrInvalidFolder, maxFolderBatch)
+	}
+	return nil
+}
+
+// AddFilesToFolder adds references to existing files. Files already in the
+// folder keep their place.
+func (m *Manager) AddFilesToFolder(id string, req FolderFilesRequest) (*FolderFilesResult, error) {
+	if err := checkFolderBatch(req.Hashes); err != nil {
+		return nil, err
+	}
+	result := &FolderFilesResult{FolderID: id}
+	hashes := make([]string, 0, len(req.Hashes))
+	m.mu.Lock()
+	for _, hash := range req.Hashes {
+		if m.index.FindByHash(hash) == nil {
+			result.Missing = append(result.Missing, hash)
+			continue
+		}
+		hashes = append(hashes, hash)
+	}
+	m.mu.Unlock()
+
+	idx := m.folderIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		folder, err := getFolder(txn, id)
+		if err != nil {
+			return err
+		}
+		entries, err := getFolderEntries(txn, id)
+		if err != nil {
+			return err
+		}
+		present := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			present[entry.Hash] = true
+		}
+		now := time.Now().UTC()
+		var added []FolderEntry
+		for _, hash := range hashes {
+			if present[hash] {
+				result.Skipped = append(result.Skipped, hash)
+				continue
+			}
+			present[hash] = true
+			added = append(added, FolderEntry{Hash: hash, AddedAt: now})
+		}
+		if len(entries)+len(added) > maxFolderFiles {
+			return fmt.Errorf("%w: a folder holds at most %d files", ErrInvalidFolder, maxFolderFiles)
+		}
+
+		pos := len(entries)
+		if req.Position != nil {
+			if *req.Position < 0 {
+				return fmt.Errorf("%w: position must not be negative", ErrInvalidFolder)
+			}
+			pos = min(*req.Position, len(entries))
+		}
+		updated := make([]FolderEntry, 0, len(entries)+len(added))
+		updated = append(updated, entries[:pos]...)
+		updated = append(updated, added...)
+		updated = append(updated, entries[pos:]...)
+		result.Added = len(added)
+		if len(added) > 0 {
+			if err := putFolderEntries(txn, folder, entries, updated); err != nil {
+				return err
+			}
+		}
+		result.FileCount = len(updated)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RemoveFilesFromFolder drops file references from a folder.
+func (m *Manager) RemoveFilesFromFolder(id string, req FolderFilesRequest) (*FolderFilesResult, error) {
+	if err := checkFolderBatch(req.Hashes); err != nil {
+		return nil, err
+	}
+	idx := m.folderIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	result := &FolderFilesResult{FolderID: id}
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		folder, err := getFolder(txn, id)
+		if err != nil {
+			return err
+		}
+		entries, err := getFolderEntries(txn, id)
+		if err != nil {
+			return err
+		}
+		remove := make(map[string]bool, len(req.Hashes))
+		for _, hash := range req.Hashes {
+			remove[hash] = true
+		}
+		updated := make([]FolderEntry, 0, len(entries))
+		for _, entry := range entries {
+			if remove[entry.Hash] {
+				delete(remove, entry.Hash)
+				result.Removed++
+				continue
+			}
+			updated = append(updated, entry)
+		}
+		for _, hash := range req.Hashes {
+			if remove[hash] {
+				result.Skipped = append(result.Skipped, hash)
+				delete(remove, hash)
+			}
+		}
+		if result.Removed > 0 {
+			if err := putFolderEntries(txn, folder, entries, updated); err != nil {
+				return err
+			}
+		}
+		result.FileCount = len(updated)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReorderFolder sets the order of the files in a folder. hashes must list
+// every file of the folder exactly once.
+func (m *Manager) ReorderFolder(id string, hashes []string) error {
+	idx := m.folderIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		folder, err := getFolder(txn, id)
+		if err != nil {
+			return err
+		}
+		entries, err := getFolderEntries(txn, id)
+		if err != nil {
+			return err
+		}
+		if len(hashes) != len(entries) {
+			return fmt.Errorf("%w: the order lists %d files but the folder has %d", ErrInvalidFolder, len(hashes), len(entries))
+		}
+		byHash := make(map[string]FolderEntry, len(entries))
+		for _, entry := range entries {
+			byHash[entry.Hash] = entry
+		}
+		ordered := make([]FolderEntry, 0, len(entries))
+		for _, hash := range hashes {
+			entry, ok := byHash[hash]
+			if !ok {
+				return fmt.Errorf("%w: %s is not in the folder or is listed twice", ErrInvalidFolder, hash)
+			}
+			delete(byHash, hash)
+			ordered = append(ordered, entry)
+		}
+		return putFolderEntries(txn, folder, entries, ordered)
+	})
+}
+
+// ListFolderFiles lists the files of a folder with the filters, sorting and
+// pagination of ListFiles. Without a sort field, files keep the folder order
+// (reversed for order=desc). Files in the trash are left out.
+func (m *Manager) ListFolderFiles(id string, options ListOptions) (*ListResult, error) {
+	var entries []FolderEntry
+	err := m.folderIndex.store.db.View(func(txn *badger.Txn) error {
+		if _, err := getFolder(txn, id); err != nil {
+			return err
+		}
+		var err error
+		entries, err = getFolderEntries(txn, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := make([]FileMetadata, 0, len(entries))
+	for _, entry := range entries {
+		meta := m.index.FindByHash(entry.Hash)
+		if meta != nil && matchesListFilters(*meta, options) {
+			files = append(files, *meta)
+		}
+	}
+	normalizeListOptions(&options)
+	switch options.SortBy {
+	case "", "position":
+		if strings.EqualFold(options.Order, "desc") {
+			for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+				files[i], files[j] = files[j], files[i]
+			}
+		}
+	default:
+		if options.Order == "" {
+			options.Order = "desc"
+		}
+		sortFiles(files, options.SortBy, options.Order)
+	}
+	return paginateFiles(files, options), nil
+}
+
+// FoldersForFile returns the folders referencing hash, sorted by name.
+func (m *Manager) FoldersForFile(hash string) ([]Folder, error) {
+	result := make([]Folder, 0)
+	err := m.folderIndex.store.db.View(func(txn *badger.Txn) error {
+		prefix := folderMemberPrefix + hash + ":"
+		var ids []string
+		if err := scanPrefix(txn, prefix, false, func(key string, _ []byte) (bool, error) {
+			ids = append(ids, strings.TrimPrefix(key, prefix))
+			return true, nil
+		}); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		folders, err := allFolders(txn)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if folder, ok := folders[id]; ok {
+				folder.Path, _ = folderPath(folders, id)
+				result = append(result, *folder)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortFolders(result)
+	return result, nil
+}
+
+// replaceInFolders swaps oldHash for newHash in every folder referencing
+// oldHash, so folders follow a file to its current version. Failures are
+// reported and otherwise ignored.
+func (m *Manager) replaceInFolders(oldHash, newHash string) {
+	if err := m.folderIndex.rewriteMember(oldHash, newHash); err != nil {
+		fmt.Fprintf(os.Stderr, "folder update failed for %s: %v\n", oldHash, err)
+	}
+}
+
+// forgetInFolders drops hash from every folder, once the file is gone for good.
+func (m *Manager) forgetInFolders(hash string) error {
+	return m.folderIndex.rewriteMember(hash, "")
+}
+
+// rewriteMember replaces hash with replacement in every folder holding it,
+// keeping its position, or removes it when replacement is empty or already
+// in the folder.
+func (idx *FolderIndex) rewriteMember(hash, replacement string) error {
+	if hash == "" || hash == replacement {
+		return nil
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		prefix := folderMemberPrefix + hash + ":"
+		var ids []string
+		if err := scanPrefix(txn, prefix, false, func(key string, _ []byte) (bool, error) {
+			ids = append(ids, strings.TrimPrefix(key, prefix))
+			return true, nil
+		}); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			folder, err := getFolder(txn, id)
+			if errors.Is(err, ErrFolderNotFound) {
+				if err := txn.Delete([]byte(folderMemberKey(hash, id))); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			entries, err := getFolderEntries(txn, id)
+			if err != nil {
+				return err
+			}
+			present := false
+			for _, entry := range entries {
+				if entry.Hash == replacement {
+					present = true
+					break
+				}
+			}
+			updated := make([]FolderEntry, 0, len(entries))
+			for _, entry := range entries {
+				if entry.Hash == hash {
+					if replacement == "" || present {
+						continue
+					}
+					entry.Hash = replacement
+				}
+				updated = append(updated, entry)
+			}
+			if err := putFolderEntries(txn, folder, entries, updated); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFolderTree(t *testing.T) {
+	m := newTestManager(t)
+	projects, err := m.CreateFolder(CreateFolderRequest{Name: " Projects "})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	apollo, err := m.CreateFolder(CreateFolderRequest{Name: "Apollo", ParentID: projects.ID})
+	if err != nil {
+		t.Fatalf("create child: %v", err)
+	}
+	if apollo.Path != "/Projects/Apollo" {
+		t.Fatalf("path = %q", apollo.Path)
+	}
+	if _, err := m.CreateFolder(CreateFolderRequest{Name: "apollo", ParentID: projects.ID}); !errors.Is(err, ErrFolderExists) {
+		t.Fatalf("expected ErrFolderExists, got %v", err)
+	}
+	if _, err := m.CreateFolder(CreateFolderRequest{Name: "a/b"}); !errors.Is(err, ErrInvalidFolder) {
+		t.Fatalf("expected ErrInvalidFolder, got %v", err)
+	}
+	if _, err := m.CreateFolder(CreateFolderRequest{Name: "orphan", ParentID: "missing"}); !errors.Is(err, ErrFolderNotFound) {
+		t.Fatalf("expected ErrFolderNotFound, got %v", err)
+	}
+
+	if _, err := m.UpdateFolder(projects.ID, UpdateFolderRequest{ParentID: &apollo.ID}); !errors.Is(err, ErrInvalidFolder) {
+		t.Fatalf("expected a cycle to be rejected, got %v", err)
+	}
+	name := "Missions"
+	if _, err := m.UpdateFolder(projects.ID, UpdateFolderRequest{Name: &name}); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if got, _ := m.GetFolder(apollo.ID); got.Path != "/Missions/Apollo" {
+		t.Fatalf("expected the path to follow the rename, got %q", got.Path)
+	}
+	root := ""
+	if moved, err := m.UpdateFolder(apollo.ID, UpdateFolderRequest{ParentID: &root}); err != nil || moved.Path != "/Apollo" {
+		t.Fatalf("move to top level: %+v %v", moved, err)
+	}
+	top, err := m.ListFolders("")
+	if err != nil || len(top) != 2 || top[0].Name != "Apollo" || top[1].Name != "Missions" {
+		t.Fatalf("top-level folders = %+v %v", top, err)
+	}
+
+	if _, err := m.CreateFolder(CreateFolderRequest{Name: "1969", ParentID: apollo.ID}); err != nil {
+		t.Fatalf("create grandchild: %v", err)
+	}
+	if _, err := m.DeleteFolder(apollo.ID, false); !errors.Is(err, ErrFolderNotEmpty) {
+		t.Fatalf("expected ErrFolderNotEmpty, got %v", err)
+	}
+	result, err := m.DeleteFolder(apollo.ID, true)
+	if err != nil || len(result.Deleted) != 2 {
+		t.Fatalf("recursive delete: %+v %v", result, err)
+	}
+	if _, err := m.GetFolder(apollo.ID); !errors.Is(err, ErrFolderNotFound) {
+		t.Fatalf("expected the folder to be gone, got %v", err)
+	}
+}
+
+func TestFolderFiles(t *testing.T) {
+	m := newTestManager(t)
+	a := storeContent(t, m, "a.txt", "text/plain", "alpha")
+	b := storeContent(t, m, "b.md", "text/markdown", "bravo")
+	c := storeContent(t, m, "c.txt", "text/plain", "charlie")
+	folder, _ := m.CreateFolder(CreateFolderRequest{Name: "Reading"})
+	other, _ := m.CreateFolder(CreateFolderRequest{Name: "Archive"})
+
+	names := func(options ListOptions) []string {
+		t.Helper()
+		result, err := m.ListFolderFiles(folder.ID, options)
+		if err != nil {
+			t.Fatalf("list folder: %v", err)
+		}
+		out := make([]string, 0, len(result.Files))
+		for _, meta := range result.Files {
+			out = append(out, meta.OriginalName)
+		}
+		return out
+	}
+
+	added, err := m.AddFilesToFolder(folder.ID, FolderFilesRequest{Hashes: []string{c.Hash, a.Hash, "missing"}})
+	if err != nil || added.Added != 2 || !reflect.DeepEqual(added.Missing, []string{"missing"}) {
+		t.Fatalf("add: %+v %v", added, err)
+	}
+	zero := 0
+	added, err = m.AddFilesToFolder(folder.ID, FolderFilesRequest{Hashes: []string{b.Hash, a.Hash}, Position: &zero})
+	if err != nil || added.Added != 1 || added.FileCount != 3 || !reflect.DeepEqual(added.Skipped, []string{a.Hash}) {
+		t.Fatalf("add at position: %+v %v", added, err)
+	}
+	if got := names(ListOptions{}); !reflect.DeepEqual(got, []string{"b.md", "c.txt", "a.txt"}) {
+		t.Fatalf("folder order = %v", got)
+	}
+	if got := names(ListOptions{Order: "desc", Extension: "txt"}); !reflect.DeepEqual(got, []string{"a.txt", "c.txt"}) {
+		t.Fatalf("filtered reverse order = %v", got)
+	}
+	if got := names(ListOptions{SortBy: "name", Order: "asc", Limit: 2}); !reflect.DeepEqual(got, []string{"a.txt", "b.md"}) {
+		t.Fatalf("sorted page = %v", got)
+	}
+
+	if err := m.ReorderFolder(folder.ID, []string{a.Hash, b.Hash}); !errors.Is(err, ErrInvalidFolder) {
+		t.Fatalf("expected an incomplete order to be rejected, got %v", err)
+	}
+	if err := m.ReorderFolder(folder.ID, []string{a.Hash, b.Hash, c.Hash}); err != nil {
+		t.Fatalf("reorder: %v", err)
+	}
+	if got := names(ListOptions{}); !reflect.DeepEqual(got, []string{"a.txt", "b.md", "c.txt"}) {
+		t.Fatalf("order after reorder = %v", got)
+	}
+
+	if _, err := m.AddFilesToFolder(other.ID, FolderFilesRequest{Hashes: []string{a.Hash}}); err != nil {
+		t.Fatalf("add to second folder: %v", err)
+	}
+	in, err := m.FoldersForFile(a.Hash)
+	if err != nil || len(in) != 2 || in[0].Name != "Archive" || in[1].Name != "Reading" {
+		t.Fatalf("folders of a.txt = %+v %v", in, err)
+	}
+
+	removed, err := m.RemoveFilesFromFolder(folder.ID, FolderFilesRequest{Hashes: []string{b.Hash, "missing"}})
+	if err != nil || removed.Removed != 1 || removed.FileCount != 2 || !reflect.DeepEqual(removed.Skipped, []string{"missing"}) {
+		t.Fatalf("remove: %+v %v", removed, err)
+	}
+
+	// A new version takes the place of the old one.
+	version, err := m.CreateVersion(VersionRequest{FileID: c.Hash, Reader: strings.NewReader("charlie v2"), Filename: "c.txt", MimeType: "text/plain", Size: 10})
+	if err != nil {
+		t.Fatalf("create version: %v", err)
+	}
+	result, _ := m.ListFolderFiles(folder.ID, ListOptions{})
+	if len(result.Files) != 2 || result.Files[1].Hash != version.Version.Hash {
+		t.Fatalf("expected the new version in the folder, got %+v", result.Files)
+	}
+
+	// Trashed files drop out of listings and purged ones out of the folder.
+	if _, err := m.DeleteFile(DeleteRequest{Hash: a.Hash}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := names(ListOptions{}); !reflect.DeepEqual(got, []string{"c.txt"}) {
+		t.Fatalf("expected trashed files to be hidden, got %v", got)
+	}
+	if _, err := m.PurgeTrash(time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if in, _ := m.FoldersForFile(a.Hash); len(in) != 0 {
+		t.Fatalf("expected purged files to leave their folders, got %+v", in)
+	}
+	if got, _ := m.GetFolder(other.ID); got.FileCount != 0 {
+		t.Fatalf("file count after purge = %d", got.FileCount)
+	}
+}
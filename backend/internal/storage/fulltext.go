@@ -0,0 +1,766 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Content index keys inside the shared index store. Postings are keyed
+// fts:post:<term>:<hash> so a term's documents are one prefix scan; terms
+// only hold letters and digits, so the separator is unambiguous.
+const (
+	ftsPrefix        = "fts:"
+	ftsDocPrefix     = "fts:doc:"
+	ftsPostingPrefix = "fts:post:"
+	ftsStatsKey      = "fts:stats"
+	ftsBuiltKey      = "fts:built"
+)
+
+// maxIndexedContentSize bounds the text read from a single file for indexing
+// and snippets; larger files are left out of the content index.
+const maxIndexedContentSize = 10 << 20
+
+// maxTermBytes drops tokens that are unlikely to be searched for (hashes,
+// base64 runs) and would otherwise bloat the index.
+const maxTermBytes = 64
+
+// BM25 parameters, at their customary values.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Snippet shape: the window starts snippetLead tokens before the first match
+// and spans snippetTokens tokens.
+const (
+	snippetLead   = 8
+	snippetTokens = 30
+)
+
+// textExtensions are indexed even when the upload carried a generic MIME type.
+var textExtensions = map[string]bool{
+	".txt": true, ".md": true, ".markdown": true, ".rst": true, ".csv": true, ".tsv": true,
+	".log": true, ".json": true, ".xml": true, ".yaml": true, ".yml": true, ".toml": true,
+	".ini": true, ".conf": true, ".html": true, ".htm": true, ".sql": true, ".sh": true,
+	".go": true, ".py": true, ".js": true, ".ts": true, ".java": true, ".c": true,
+	".h": true, ".cpp": true, ".rs": true, ".rb": true,
+}
+
+// ContentQuery is a parsed content search: every term and every phrase must
+// occur in a matching file.
+type ContentQuery struct {
+	Terms   []string
+	Phrases [][]string
+}
+
+// ParseContentQuery splits raw into terms and double-quoted phrases, using
+// the same tokenization as the index. An unterminated quote runs to the end.
+func ParseContentQuery(raw string) ContentQuery {
+	var q ContentQuery
+	seen := make(map[string]bool)
+	addTerm := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			q.Terms = append(q.Terms, term)
+		}
+	}
+	for i, part := range strings.Split(raw, `"`) {
+		tokens := tokenizeText(part)
+		if i%2 == 1 && len(tokens) > 1 {
+			phrase := make([]string, len(tokens))
+			for j, tok := range tokens {
+				phrase[j] = tok.term
+			}
+			q.Phrases = append(q.Phrases, phrase)
+			continue
+		}
+		for _, tok := range tokens {
+			addTerm(tok.term)
+		}
+	}
+	return q
+}
+
+// Empty reports whether the query has nothing to search for.
+func (q ContentQuery) Empty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0
+}
+
+// allTerms returns the distinct terms of q, phrase words included, sorted.
+func (q ContentQuery) allTerms() []string {
+	set := make(map[string]bool)
+	for _, term := range q.Terms {
+		set[term] = true
+	}
+	for _, phrase := range q.Phrases {
+		for _, term := range phrase {
+			set[term] = true
+		}
+	}
+	terms := make([]string, 0, len(set))
+	for term := range set {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// textToken is one indexed word and its byte range in the source text.
+type textToken struct {
+	term       string
+	start, end int
+}
+
+// tokenizeText splits text into lower-cased runs of letters and digits.
+func tokenizeText(text string) []textToken {
+	var tokens []textToken
+	start := -1
+	flush := func(end int) {
+		if start >= 0 && end-start <= maxTermBytes {
+			tokens = append(tokens, textToken{term: strings.ToLower(text[start:end]), start: start, end: end})
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+	return tokens
+}
+
+// ContentHit is one document matching a content query.
+type ContentHit struct {
+	Hash  string
+	Score float64
+}
+
+// ContentIndexStats summarizes the content index.
+type ContentIndexStats struct {
+	Documents int   `json:"documents"`
+	Tokens    int64 `json:"tokens"`
+}
+
+type ftsDoc struct {
+	Length int      `json:"len"`
+	Terms  []string `json:"terms"`
+}
+
+// ContentIndex is a persistent inverted index over file contents, keyed by
+// file hash, with positional postings for phrase queries and BM25 ranking.
+type ContentIndex struct {
+	store *kvStore
+	mu    sync.RWMutex
+}
+
+// NewContentIndex opens the content index in the store beside path.
+func NewContentIndex(path string) (*ContentIndex, error) {
+	store, err := openKVStore(kvDirFor(path))
+	if err != nil {
+		return nil, err
+	}
+	return &ContentIndex{store: store}, nil
+}
+
+// Close releases the index store.
+func (idx *ContentIndex) Close() error {
+	return idx.store.release()
+}
+
+func ftsDocKey(hash string) string {
+	return ftsDocPrefix + hash
+}
+
+func ftsPostingKey(term, hash string) string {
+	return ftsPostingPrefix + term + ":" + hash
+}
+
+func (idx *ContentIndex) statsLocked(txn *badger.Txn) (ContentIndexStats, error) {
+	var stats ContentIndexStats
+	_, err := getJSON(txn, ftsStatsKey, &stats)
+	return stats, err
+}
+
+// Put indexes the terms of hash in document order, replacing any previous entry.
+func (idx *ContentIndex) Put(hash string, terms []string) error {
+	positions := make(map[string][]int)
+	for pos, term := range terms {
+		positions[term] = append(positions[term], pos)
+	}
+
+	doc := ftsDoc{Length: len(terms), Terms: make([]string, 0, len(positions))}
+	for term := range positions {
+		doc.Terms = append(doc.Terms, term)
+	}
+	sort.Strings(doc.Terms)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.writeLocked(hash, doc, positions)
+}
+
+// writeLocked replaces the entry of hash. Large documents exceed Badger's
+// transaction limit, so the postings go through a WriteBatch.
+func (idx *ContentIndex) writeLocked(hash string, doc ftsDoc, positions map[string][]int) error {
+	var old ftsDoc
+	var stats ContentIndexStats
+	var found bool
+	if err := idx.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		if found, err = getJSON(txn, ftsDocKey(hash), &old); err != nil {
+			return err
+		}
+		stats, err = idx.statsLocked(txn)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	return idx.batchLocked(func(wb *badger.WriteBatch) error {
+		if found {
+			for _, term := range old.Terms {
+				if _, ok := positions[term]; !ok {
+					if err := wb.Delete([]byte(ftsPostingKey(term, hash))); err != nil {
+						return err
+					}
+				}
+			}
+			stats.Documents--
+			stats.Tokens -= int64(old.Length)
+		}
+		for term, pos := range positions {
+			if err := setJSON(wb, ftsPostingKey(term, hash), pos); err != nil {
+				return err
+			}
+		}
+		if err := setJSON(wb, ftsDocKey(hash), doc); err != nil {
+			return err
+		}
+		stats.Documents++
+		stats.Tokens += int64(doc.Length)
+		return setJSON(wb, ftsStatsKey, stats)
+	})
+}
+
+// batchLocked runs fill against a WriteBatch and flushes it, cancelling the
+// batch when fill fails.
+func (idx *ContentIndex) batchLocked(fill func(wb *badger.WriteBatch) error) error {
+	wb := idx.store.db.NewWriteBatch()
+	if err := fill(wb); err != nil {
+		wb.Cancel()
+		return err
+	}
+	return wb.Flush()
+}
+
+// Copy indexes dst with the contents indexed for src, as for a copied file.
+// It is a no-op when src is not indexed.
+func (idx *ContentIndex) Copy(src, dst string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var doc ftsDoc
+	positions := make(map[string][]int)
+	var found bool
+	if err := idx.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		if found, err = getJSON(txn, ftsDocKey(src), &doc); err != nil || !found {
+			return err
+		}
+		for _, term := range doc.Terms {
+			var pos []int
+			if _, err := getJSON(txn, ftsPostingKey(term, src), &pos); err != nil {
+				return err
+			}
+			positions[term] = pos
+		}
+		return nil
+	}); err != nil || !found {
+		return err
+	}
+	return idx.writeLocked(dst, doc, positions)
+}
+
+// Delete removes hash from the index.
+func (idx *ContentIndex) Delete(hash string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var doc ftsDoc
+	var stats ContentIndexStats
+	var found bool
+	if err := idx.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		if found, err = getJSON(txn, ftsDocKey(hash), &doc); err != nil {
+			return err
+		}
+		stats, err = idx.statsLocked(txn)
+		return err
+	}); err != nil || !found {
+		return err
+	}
+
+	return idx.batchLocked(func(wb *badger.WriteBatch) error {
+		for _, term := range doc.Terms {
+			if err := wb.Delete([]byte(ftsPostingKey(term, hash))); err != nil {
+				return err
+			}
+		}
+		if err := wb.Delete([]byte(ftsDocKey(hash))); err != nil {
+			return err
+		}
+		stats.Documents--
+		stats.Tokens -= int64(doc.Length)
+		return setJSON(wb, ftsStatsKey, stats)
+	})
+}
+
+// Has reports whether hash is indexed.
+func (idx *ContentIndex) Has(hash string) (bool, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var found bool
+	err := idx.store.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(ftsDocKey(hash)))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		found = err == nil
+		return err
+	})
+	return found, err
+}
+
+// Reset drops every entry, leaving the index unbuilt.
+func (idx *ContentIndex) Reset() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.store.db.DropPrefix([]byte(ftsPrefix))
+}
+
+// Built reports whether the index has been built from the stored files.
+// Data directories that predate the index start unbuilt.
+func (idx *ContentIndex) Built() (bool, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var built bool
+	err := idx.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		built, err = getJSON(txn, ftsBuiltKey, new(bool))
+		return err
+	})
+	return built, err
+}
+
+// MarkBuilt records that every stored file has been considered for indexing.
+func (idx *ContentIndex) MarkBuilt() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		return setJSON(txn, ftsBuiltKey, true)
+	})
+}
+
+// Stats returns the document and token counts.
+func (idx *ContentIndex) Stats() (ContentIndexStats, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var stats ContentIndexStats
+	err := idx.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		stats, err = idx.statsLocked(txn)
+		return err
+	})
+	return stats, err
+}
+
+// Search returns the documents containing every term and phrase of q,
+// best BM25 score first.
+func (idx *ContentIndex) Search(q ContentQuery) ([]ContentHit, error) {
+	terms := q.allTerms()
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var hits []ContentHit
+	err := idx.store.db.View(func(txn *badger.Txn) error {
+		stats, err := idx.statsLocked(txn)
+		if err != nil || stats.Documents == 0 {
+			return err
+		}
+
+		postings := make(map[string]map[string][]int, len(terms))
+		for _, term := range terms {
+			prefix := ftsPostingPrefix + term + ":"
+			docs := make(map[string][]int)
+			if err := scanPrefix(txn, prefix, true, func(key string, val []byte) (bool, error) {
+				var pos []int
+				if err := json.Unmarshal(val, &pos); err != nil {
+					return false, err
+				}
+				docs[strings.TrimPrefix(key, prefix)] = pos
+				return true, nil
+			}); err != nil {
+				return err
+			}
+			if len(docs) == 0 {
+				return nil
+			}
+			postings[term] = docs
+		}
+
+		avgLength := float64(stats.Tokens) / float64(stats.Documents)
+		for hash := range postings[terms[0]] {
+			if !matchesAll(hash, terms, q.Phrases, postings) {
+				continue
+			}
+			var doc ftsDoc
+			if _, err := getJSON(txn, ftsDocKey(hash), &doc); err != nil {
+				return err
+			}
+			score := 0.0
+			for _, term := range terms {
+				df := float64(len(postings[term]))
+				idf := math.Log(1 + (float64(stats.Documents)-df+0.5)/(df+0.5))
+				tf := float64(len(postings[term][hash]))
+				score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(doc.Length)/avgLength))
+			}
+			hits = append(hits, ContentHit{Hash: hash, Score: score})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Hash < hits[j].Hash
+	})
+	return hits, nil
+}
+
+// matchesAll reports whether hash holds every term and every phrase.
+func matchesAll(hash string, terms []string, phrases [][]string, postings map[string]map[string][]int) bool {
+	for _, term := range terms {
+		if _, ok := postings[term][hash]; !ok {
+			return false
+		}
+	}
+	for _, phrase := range phrases {
+		if len(phraseStarts(phrase, func(term string) []int { return postings[term][hash] })) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// phraseStarts returns the positions where phrase occurs, given each term's
+// sorted positions.
+func phraseStarts(phrase []string, positions func(term string) []int) []int {
+	var starts []int
+	for _, start := range positions(phrase[0]) {
+		match := true
+		for i, term := range phrase[1:] {
+			pos := positions(term)
+			j := sort.SearchInts(pos, start+i+1)
+			if j == len(pos) || pos[j] != start+i+1 {
+				match = false
+				break
+			}
+		}
+		if match {
+			starts = append(starts, start)
+		}
+	}
+	return starts
+}
+
+// isIndexable reports whether a file's contents go into the content index.
+func isIndexable(name, mimeType string) bool {
+	return isTextMimeType(mimeType) || textExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// readIndexableText reads the text of an indexable file. It reports false
+// for files over maxIndexedContentSize.
+func readIndexableText(r io.Reader) (string, bool, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxIndexedContentSize+1))
+	if err != nil {
+		return "", false, err
+	}
+	if len(data) > maxIndexedContentSize {
+		return "", false, nil
+	}
+	if !utf8.Valid(data) {
+		return strings.ToValidUTF8(string(data), " "), true, nil
+	}
+	return string(data), true, nil
+}
+
+// readIndexableFile is readIndexableText for a local file.
+func readIndexableFile(path string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	text, ok, err := readIndexableText(f)
+	return text, ok && err == nil
+}
+
+// hasTextContent reports whether a file contributes text to the content
+// index, either directly or through its extracted-text derivative.
+func hasTextContent(meta FileMetadata) bool {
+	return isIndexable(meta.OriginalName, meta.MimeType) || SupportsTextExtraction(meta)
+}
+
+// readStoredText reads the text of a stored file through the backend: the
+// file itself when it is text, otherwise the text extracted from it.
+func (m *Manager) readStoredText(meta FileMetadata) (string, bool, error) {
+	key := meta.StoredPath
+	if !isIndexable(meta.OriginalName, meta.MimeType) {
+		extracted, err := m.extractedTextIndex.Get(meta.Hash)
+		if err != nil || extracted == nil {
+			return "", false, err
+		}
+		key = extracted.Key
+	}
+	rc, err := m.backend.Open(key)
+	if err != nil {
+		return "", false, err
+	}
+	defer rc.Close()
+	return readIndexableText(rc)
+}
+
+// indexTextLocked records text as the contents of hash. Index failures never
+// fail the operation that triggered them; a rebuild repairs the index.
+// Caller holds m.mu.
+func (m *Manager) indexTextLocked(hash, text string) {
+	tokens := tokenizeText(text)
+	terms := make([]string, len(tokens))
+	for i, tok := range tokens {
+		terms[i] = tok.term
+	}
+	if err := m.contentIndex.Put(hash, terms); err != nil {
+		fmt.Fprintf(os.Stderr, "content index update failed for %s: %v\n", hash, err)
+	}
+}
+
+// reindexContentLocked re-reads a stored file into the content index, or
+// removes it when it is no longer indexable. Caller holds m.mu.
+func (m *Manager) reindexContentLocked(meta FileMetadata) {
+	text, ok, err := m.readStoredText(meta)
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "content index update failed for %s: %v\n", meta.Hash, err)
+	case ok:
+		m.indexTextLocked(meta.Hash, text)
+	default:
+		m.unindexContentLocked(meta.Hash)
+	}
+}
+
+// unindexContentLocked removes hash from the content index. Caller holds m.mu.
+func (m *Manager) unindexContentLocked(hash string) {
+	if err := m.contentIndex.Delete(hash); err != nil {
+		fmt.Fprintf(os.Stderr, "content index update failed for %s: %v\n", hash, err)
+	}
+}
+
+// RebuildContentIndex discards the content index and re-indexes every stored
+// file. Files are read without holding the manager lock; a file deleted
+// meanwhile is skipped and one stored meanwhile is indexed by StoreFile.
+func (m *Manager) RebuildContentIndex() (*ContentIndexStats, error) {
+	m.mu.Lock()
+	if err := m.contentIndex.Reset(); err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("reset content index: %w", err)
+	}
+	var files []FileMetadata
+	err := m.index.ForEach(func(meta FileMetadata) bool {
+		if hasTextContent(meta) {
+			files = append(files, meta)
+		}
+		return true
+	})
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, meta := range files {
+		text, ok, err := m.readStoredText(meta)
+		if err != nil || !ok {
+			continue
+		}
+		m.mu.Lock()
+		if current := m.index.FindByHash(meta.Hash); current != nil && current.StoredPath == meta.StoredPath {
+			m.indexTextLocked(meta.Hash, text)
+		}
+		m.mu.Unlock()
+	}
+
+	if err := m.contentIndex.MarkBuilt(); err != nil {
+		return nil, err
+	}
+	stats, err := m.contentIndex.Stats()
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// ContentMatch is a file matching a content search.
+type ContentMatch struct {
+	Metadata FileMetadata
+	Score    float64
+	// Snippet is HTML-escaped text around the first match with matched
+	// words wrapped in <mark>; empty beyond the first snippetLimit results.
+	Snippet string
+}
+
+// SearchContent answers filters.ContentSearch from the content index,
+// applying the remaining filters to each hit. Results are ranked by BM25
+// score; the first snippetLimit results carry a snippet. An index that was
+// never built is built first.
+func (m *Manager) SearchContent(filters SearchFilters, snippetLimit int) ([]ContentMatch, error) {
+	q := ParseContentQuery(filters.ContentSearch)
+	if q.Empty() {
+		return []ContentMatch{}, nil
+	}
+	if err := m.ensureContentIndex(); err != nil {
+		return nil, err
+	}
+
+	hits, err := m.contentIndex.Search(q)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]ContentMatch, 0, len(hits))
+	m.mu.Lock()
+	for _, hit := range hits {
+		meta := m.index.FindByHash(hit.Hash)
+		if meta == nil || !matchesFilters(*meta, filters) {
+			continue
+		}
+		matches = append(matches, ContentMatch{Metadata: *meta, Score: hit.Score})
+	}
+	m.mu.Unlock()
+
+	for i := range matches {
+		if i >= snippetLimit {
+			break
+		}
+		if text, ok, err := m.readStoredText(matches[i].Metadata); err == nil && ok {
+			matches[i].Snippet = contentSnippet(text, q)
+		}
+	}
+	return matches, nil
+}
+
+// contentSnippet renders the window of text around the first match of q.
+func contentSnippet(text string, q ContentQuery) string {
+	tokens := tokenizeText(text)
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	marked := make([]bool, len(tokens))
+	for i, tok := range tokens {
+		for _, term := range q.Terms {
+			if tok.term == term {
+				marked[i] = true
+			}
+		}
+	}
+	if len(q.Phrases) > 0 {
+		positions := make(map[string][]int)
+		for i, tok := range tokens {
+			positions[tok.term] = append(positions[tok.term], i)
+		}
+		for _, phrase := range q.Phrases {
+			for _, start := range phraseStarts(phrase, func(term string) []int { return positions[term] }) {
+				for i := range phrase {
+					marked[start+i] = true
+				}
+			}
+		}
+	}
+
+	first := 0
+	for i, m := range marked {
+		if m {
+			first = i
+			break
+		}
+	}
+	start := max(first-snippetLead, 0)
+	end := min(start+snippetTokens, len(tokens))
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("… ")
+	} else {
+		b.WriteString(html.EscapeString(strings.TrimLeftFunc(collapseSpace(text[:tokens[0].start]), unicode.IsSpace)))
+	}
+	for i := start; i < end; i++ {
+		if i > start {
+			b.WriteString(html.EscapeString(collapseSpace(text[tokens[i-1].end:tokens[i].start])))
+		}
+		word := html.EscapeString(text[tokens[i].start:tokens[i].end])
+		if marked[i] {
+			b.WriteString("<mark>" + word + "</mark>")
+		} else {
+			b.WriteString(word)
+		}
+	}
+	if end < len(tokens) {
+		b.WriteString(" …")
+	} else {
+		b.WriteString(html.EscapeString(strings.TrimRightFunc(collapseSpace(text[tokens[end-1].end:]), unicode.IsSpace)))
+	}
+	return b.String()
+}
+
+// collapseSpace replaces each run of whitespace in s with a single space.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func storeContent(t *testing.T, m *Manager, name, mimeType, content string) FileMetadata {
+	t.Helper()
+	res, err := m.StoreFile(StoreRequest{Reader: strings.NewReader(content), Filename: name, MimeType: mimeType, Size: int64(len(content))})
+	if err != nil {
+		t.Fatalf("store %s: %v", name, err)
+	}
+	return res.Metadata
+}
+
+func contentNames(t *testing.T, m *Manager, filters SearchFilters) []string {
+	t.Helper()
+	matches, err := m.SearchContent(filters, 10)
+	if err != nil {
+		t.Fatalf("SearchContent(%q): %v", filters.ContentSearch, err)
+	}
+	names := make([]string, len(matches))
+	for i, match := range matches {
+		names[i] = match.Metadata.OriginalName
+	}
+	return names
+}
+
+func TestParseContentQuery(t *testing.T) {
+	q := ParseContentQuery(`Quick "brown  FOX" jumps "over" quick "lazy`)
+	if !reflect.DeepEqual(q.Terms, []string{"quick", "jumps", "over", "lazy"}) {
+		t.Fatalf("terms = %v", q.Terms)
+	}
+	if !reflect.DeepEqual(q.Phrases, [][]string{{"brown", "fox"}}) {
+		t.Fatalf("phrases = %v", q.Phrases)
+	}
+	if !ParseContentQuery(` -- !! `).Empty() {
+		t.Fatalf("expected punctuation-only query to be empty")
+	}
+}
+
+func TestSearchContentRanksWithBM25(t *testing.T) {
+	m := newTestManager(t)
+	storeContent(t, m, "once.txt", "text/plain", "The gateway timed out once while the rest of the deployment went fine and nobody noticed anything at all today.")
+	storeContent(t, m, "often.txt", "text/plain", "gateway gateway gateway errors")
+	storeContent(t, m, "other.txt", "text/plain", "nothing relevant here")
+	storeContent(t, m, "photo.png", "image/png", "gateway")
+
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "GATEWAY"}); !reflect.DeepEqual(got, []string{"often.txt", "once.txt"}) {
+		t.Fatalf("ranked results = %v", got)
+	}
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "gateway errors"}); !reflect.DeepEqual(got, []string{"often.txt"}) {
+		t.Fatalf("expected every term to be required, got %v", got)
+	}
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "gateway", Name: "once"}); !reflect.DeepEqual(got, []string{"once.txt"}) {
+		t.Fatalf("expected metadata filters to apply, got %v", got)
+	}
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "gate"}); len(got) != 0 {
+		t.Fatalf("expected whole-word matching, got %v", got)
+	}
+}
+
+func TestSearchContentPhrasesAndSnippets(t *testing.T) {
+	m := newTestManager(t)
+	storeContent(t, m, "a.md", "application/octet-stream", "Intro line.\n\nThe <quick> brown fox jumps over the lazy dog.")
+	storeContent(t, m, "b.txt", "text/plain", "brown dogs are quick, the fox said")
+
+	matches, err := m.SearchContent(SearchFilters{ContentSearch: `"quick brown fox"`}, 10)
+	if err != nil {
+		t.Fatalf("SearchContent: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Metadata.OriginalName != "a.md" {
+		t.Fatalf("expected only a.md to hold the phrase, got %+v", matches)
+	}
+	want := "Intro line. The &lt;<mark>quick</mark>&gt; <mark>brown</mark> <mark>fox</mark> jumps over the lazy dog."
+	if matches[0].Snippet != want {
+		t.Fatalf("snippet = %q\nwant %q", matches[0].Snippet, want)
+	}
+
+	long := strings.Repeat("filler ", 50) + "needle " + strings.Repeat("filler ", 50)
+	snippet := contentSnippet(long, ParseContentQuery("needle"))
+	if !strings.HasPrefix(snippet, "… filler") || !strings.HasSuffix(snippet, "filler …") || !strings.Contains(snippet, "<mark>needle</mark>") {
+		t.Fatalf("unexpected windowed snippet %q", snippet)
+	}
+}
+
+func TestContentIndexFollowsFileLifecycle(t *testing.T) {
+	m := newTestManager(t)
+	meta := storeContent(t, m, "notes.txt", "text/plain", "quarterly roadmap draft")
+	binary := storeContent(t, m, "blob", "application/octet-stream", "roadmap hidden in a blob")
+
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "roadmap"}); !reflect.DeepEqual(got, []string{"notes.txt"}) {
+		t.Fatalf("expected only the text file, got %v", got)
+	}
+
+	// Renaming to a text extension makes the file searchable.
+	if _, err := m.RenameFile(RenameRequest{Hash: binary.Hash, NewName: "blob.txt"}); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "hidden roadmap"}); !reflect.DeepEqual(got, []string{"blob.txt"}) {
+		t.Fatalf("expected renamed file to be indexed, got %v", got)
+	}
+
+	copied, err := m.CopyFile(CopyRequest{Hash: meta.Hash, NewName: "notes_copy.txt"})
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "quarterly"}); len(got) != 2 {
+		t.Fatalf("expected original and copy, got %v", got)
+	}
+
+	if _, err := m.DeleteFile(DeleteRequest{Hash: meta.Hash}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "quarterly"}); !reflect.DeepEqual(got, []string{copied.NewMeta.OriginalName}) {
+		t.Fatalf("expected deleted file to leave the index, got %v", got)
+	}
+	if _, err := m.RestoreFile(meta.Hash); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "quarterly"}); len(got) != 2 {
+		t.Fatalf("expected restored file to be indexed again, got %v", got)
+	}
+
+	stats, err := m.contentIndex.Stats()
+	if err != nil || stats.Documents != 3 {
+		t.Fatalf("stats = %+v, %v", stats, err)
+	}
+}
+
+func TestRebuildContentIndex(t *testing.T) {
+	m := newTestManager(t)
+	storeContent(t, m, "a.txt", "text/plain", "alpha beta")
+	storeContent(t, m, "b.txt", "text/plain", "beta gamma")
+
+	// Simulate a data directory that predates the index.
+	if err := m.contentIndex.Reset(); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if got := contentNames(t, m, SearchFilters{ContentSearch: "beta"}); len(got) != 2 {
+		t.Fatalf("expected first search to build the index, got %v", got)
+	}
+
+	stats, err := m.RebuildContentIndex()
+	if err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	if stats.Documents != 2 || stats.Tokens != 4 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// jsonEngines are the engine directories NDJSON batches are written under.
+var jsonEngines = []string{"sql", "nosql"}
+
+// jsonNamespaceSlug maps a namespace to its batch directory name.
+func jsonNamespaceSlug(namespace string) string {
+	slug := sanitize(namespace)
+	if slug == "" {
+		slug = "default"
+	}
+	return slug
+}
+
+// ScanJSONNamespace calls fn for every document in the NDJSON batches of
+// namespace, oldest batch first, until fn returns false. Numbers are decoded
+// as json.Number. It reports whether the namespace has any batches.
+//
+// Batches are read without holding the manager lock; a trailing line still
+// being appended is skipped.
+func (m *Manager) ScanJSONNamespace(namespace string, fn func(doc map[string]any) bool) (bool, error) {
+	slug := jsonNamespaceSlug(namespace)
+	var batches []string
+	for _, engine := range jsonEngines {
+		dir := filepath.Join(m.root, "json", engine, slug)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return false, err
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if !e.IsDir() && strings.HasPrefix(name, "batch_") && strings.HasSuffix(name, ".ndjson") {
+				batches = append(batches, filepath.Join(dir, name))
+			}
+		}
+	}
+	// Batch names carry their UTC timestamp, so base names sort by age.
+	sort.SliceStable(batches, func(i, j int) bool {
+		return filepath.Base(batches[i]) < filepath.Base(batches[j])
+	})
+
+	for _, path := range batches {
+		more, err := scanNDJSON(path, fn)
+		if err != nil {
+			return true, err
+		}
+		if !more {
+			break
+		}
+	}
+	return len(batches) > 0, nil
+}
+
+// scanNDJSON feeds each object in an NDJSON file to fn. It reports false
+// once fn asks to stop.
+func scanNDJSON(path string, fn func(doc map[string]any) bool) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A final line without a newline is an in-progress append.
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		var doc map[string]any
+		if err := dec.Decode(&doc); err != nil {
+			return false, fmt.Errorf("%s line %d: %w", filepath.Base(path), lineNo, err)
+		}
+		if !fn(doc) {
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanJSONNamespace(t *testing.T) {
+	tmpDir := t.TempDir()
+	m, err := NewManager(tmpDir)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	found, err := m.ScanJSONNamespace("orders", func(map[string]any) bool { return true })
+	if err != nil || found {
+		t.Fatalf("empty namespace: found=%v err=%v", found, err)
+	}
+
+	older := filepath.Join("json", "sql", "orders", "batch_20250101T000000Z.ndjson")
+	newer := filepath.Join("json", "nosql", "orders", "batch_20250102T000000Z.ndjson")
+	if _, err := m.AppendNDJSON(newer, []map[string]any{{"id": 3}}); err != nil {
+		t.Fatalf("AppendNDJSON: %v", err)
+	}
+	if _, err := m.AppendNDJSON(older, []map[string]any{{"id": 1}, {"id": 2}}); err != nil {
+		t.Fatalf("AppendNDJSON: %v", err)
+	}
+	// Simulate an append still in progress.
+	f, err := os.OpenFile(filepath.Join(tmpDir, newer), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open batch: %v", err)
+	}
+	f.WriteString(`{"id": 4`)
+	f.Close()
+
+	var ids []string
+	found, err = m.ScanJSONNamespace("Orders", func(doc map[string]any) bool {
+		ids = append(ids, doc["id"].(json.Number).String())
+		return true
+	})
+	if err != nil || !found {
+		t.Fatalf("scan: found=%v err=%v", found, err)
+	}
+	if len(ids) != 3 || ids[0] != "1" || ids[1] != "2" || ids[2] != "3" {
+		t.Fatalf("expected ids 1,2,3 in batch order, got %v", ids)
+	}
+
+	ids = nil
+	m.ScanJSONNamespace("orders", func(doc map[string]any) bool {
+		ids = append(ids, doc["id"].(json.Number).String())
+		return false
+	})
+	if len(ids) != 1 {
+		t.Fatalf("expected scan to stop after first document, got %v", ids)
+	}
+}
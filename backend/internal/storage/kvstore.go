@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// kvDirName is the Badger directory created next to the legacy JSON index files.
+const kvDirName = "index.db"
+
+// kvStore is the embedded Badger database behind the metadata, version, notes
+// and reference indexes. Every index opened against the same directory within
+// a process shares one handle, so the Manager and any standalone index see the
+// same data and Badger's directory lock is only taken once.
+type kvStore struct {
+	dir  string
+	db   *badger.DB
+	refs int
+}
+
+var (
+	kvStoresMu sync.Mutex
+	kvStores   = map[string]*kvStore{}
+)
+
+// kvDirFor maps a legacy JSON index path (e.g. metadata/files.json) to the
+// shared store directory beside it (metadata/index.db).
+func kvDirFor(legacyPath string) string {
+	return filepath.Join(filepath.Dir(legacyPath), kvDirName)
+}
+
+// openKVStore returns the shared store for dir, opening it on first use.
+func openKVStore(dir string) (*kvStore, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	kvStoresMu.Lock()
+	defer kvStoresMu.Unlock()
+
+	if store, ok := kvStores[abs]; ok {
+		store.refs++
+		return store, nil
+	}
+
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, err
+	}
+	opts := badger.DefaultOptions(abs)
+	opts.Logger = nil          // Disable verbose logging
+	opts.SyncWrites = false    // Same durability trade-off as the L3 cache
+	opts.NumVersionsToKeep = 1 // Keep only latest version
+	// Index records are small; keep memtables and value log files modest so
+	// a data directory does not reserve hundreds of megabytes up front.
+	opts.MemTableSize = 16 << 20
+	opts.ValueLogFileSize = 64 << 20
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("open index store: %w", err)
+	}
+
+	store := &kvStore{dir: abs, db: db, refs: 1}
+	kvStores[abs] = store
+	return store, nil
+}
+
+// release drops one reference and closes the database with the last one.
+func (s *kvStore) release() error {
+	kvStoresMu.Lock()
+	defer kvStoresMu.Unlock()
+
+	if s.refs <= 0 {
+		return nil
+	}
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	delete(kvStores, s.dir)
+	return s.db.Close()
+}
+
+// kvWriter is satisfied by both *badger.Txn and *badger.WriteBatch so index
+// mutations can be shared between transactional updates and bulk migration.
+type kvWriter interface {
+	Set(key, value []byte) error
+	Delete(key []byte) error
+}
+
+// setJSON marshals v and stores it under key.
+func setJSON(w kvWriter, key string, v any) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return w.Set([]byte(key), buf)
+}
+
+// getJSON loads key into v. It reports false when the key does not exist.
+func getJSON(txn *badger.Txn, key string, v any) (bool, error) {
+	item, err := txn.Get([]byte(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, item.Value(func(val []byte) error {
+		return json.Unmarshal(val, v)
+	})
+}
+
+// scanPrefix calls fn for every key under prefix in ascending order. Returning
+// false from fn stops the scan.
+func scanPrefix(txn *badger.Txn, prefix string, withValues bool, fn func(key string, val []byte) (bool, error)) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = withValues
+	opts.Prefix = []byte(prefix)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(opts.Prefix); it.ValidForPrefix(opts.Prefix); it.Next() {
+		item := it.Item()
+		var val []byte
+		if withValues {
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			val = v
+		}
+		more, err := fn(string(item.KeyCopy(nil)), val)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+// scanRange calls fn for every key in [start, end) in ascending order.
+func scanRange(txn *badger.Txn, start, end string, fn func(key string) bool) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek([]byte(start)); it.Valid(); it.Next() {
+		key := string(it.Item().KeyCopy(nil))
+		if key >= end {
+			return
+		}
+		if !fn(key) {
+			return
+		}
+	}
+}
+
+// migrateLegacyJSON imports a whole-file JSON index into the store exactly once.
+// The import runs through a WriteBatch so large indexes do not hit Badger's
+// transaction size limit; afterwards a marker key is written and the JSON file
+// is renamed to <name>.migrated as a backup.
+func migrateLegacyJSON(store *kvStore, legacyPath string, load func(raw []byte, w kvWriter) error) error {
+	marker := "sys:migrated:" + filepath.Base(legacyPath)
+
+	raw, err := os.ReadFile(legacyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	done := false
+	if err := store.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(marker))
+		if err == nil {
+			done = true
+			return nil
+		}
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if !done {
+		wb := store.db.NewWriteBatch()
+		if len(raw) > 0 {
+			if err := load(raw, wb); err != nil {
+				wb.Cancel()
+				return fmt.Errorf("migrate %s: %w", filepath.Base(legacyPath), err)
+			}
+		}
+		if err := wb.Set([]byte(marker), []byte("1")); err != nil {
+			wb.Cancel()
+			return err
+		}
+		if err := wb.Flush(); err != nil {
+			return fmt.Errorf("migrate %s: %w", filepath.Base(legacyPath), err)
+		}
+	}
+
+	return os.Rename(legacyPath, legacyPath+".migrated")
+}
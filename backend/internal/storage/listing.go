@@ -22,6 +22,7 @@ type ListOptions struct {
 	DateFrom  time.Time     // Filter by upload date (from)
 	DateTo    time.Time     // Filter by upload date (to)
 	Name      string        // Filter by name (partial match)
+	Tags      []string      // Filter by normalized tags; all must match, descendants included
 }
 
 // ListResult contains paginated file listing results.
@@ -46,15 +47,7 @@ func (m *Manager) ListFiles(options ListOptions) (*ListResult, error) {
 	defer m.mu.Unlock()
 
 	// Default values
-	if options.Page < 1 {
-		options.Page = 1
-	}
-	if options.Limit < 1 {
-		options.Limit = 50
-	}
-	if options.Limit > 1000 {
-		options.Limit = 1000
-	}
+	normalizeListOptions(&options)
 	if options.SortBy == "" {
 		options.SortBy = "uploaded_at"
 	}
@@ -62,10 +55,18 @@ func (m *Manager) ListFiles(options ListOptions) (*ListResult, error) {
 		options.Order = "desc"
 	}
 
-	// Convert all metadata to slice for filtering and sorting
-	allFiles := make([]FileMetadata, 0, len(m.index.data))
-	for _, meta := range m.index.data {
-		allFiles = append(allFiles, meta)
+	// Narrow the candidate set with a secondary index where one applies;
+	// matchesListFilters below still checks every filter.
+	var allFiles []FileMetadata
+	switch {
+	case len(options.Tags) > 0:
+		allFiles = m.index.FindByTag(options.Tags[0])
+	case options.MimeType != "":
+		allFiles = m.index.FindByMimeType(options.MimeType)
+	case !options.DateFrom.IsZero() || !options.DateTo.IsZero():
+		allFiles = m.index.FindUploadedBetween(options.DateFrom, options.DateTo)
+	default:
+		allFiles = m.index.GetAll()
 	}
 
 	// Apply filters
@@ -79,8 +80,39 @@ func (m *Manager) ListFiles(options ListOptions) (*ListResult, error) {
 	// Sort
 	sortFiles(filtered, options.SortBy, options.Order)
 
-	// Paginate
-	total := len(filtered)
+	return paginateFiles(filtered, options), nil
+}
+
+// PageFiles sorts files by options.SortBy, keeping their order when it is
+// empty, and returns the requested page. It serves listings whose files do
+// not come from ListFiles.
+func PageFiles(files []FileMetadata, options ListOptions) *ListResult {
+	normalizeListOptions(&options)
+	if options.SortBy != "" {
+		if options.Order == "" {
+			options.Order = "desc"
+		}
+		sortFiles(files, options.SortBy, options.Order)
+	}
+	return paginateFiles(files, options)
+}
+
+// normalizeListOptions clamps the page and limit of options to valid values.
+func normalizeListOptions(options *ListOptions) {
+	if options.Page < 1 {
+		options.Page = 1
+	}
+	if options.Limit < 1 {
+		options.Limit = 50
+	}
+	if options.Limit > 1000 {
+		options.Limit = 1000
+	}
+}
+
+// paginateFiles cuts the requested page out of files.
+func paginateFiles(files []FileMetadata, options ListOptions) *ListResult {
+	total := len(files)
 	totalPages := (total + options.Limit - 1) / options.Limit
 	start := (options.Page - 1) * options.Limit
 	end := start + options.Limit
@@ -94,7 +126,7 @@ func (m *Manager) ListFiles(options ListOptions) (*ListResult, error) {
 
 	var paginated []FileMetadata
 	if start < end {
-		paginated = filtered[start:end]
+		paginated = files[start:end]
 	} else {
 		paginated = []FileMetadata{}
 	}
@@ -109,11 +141,15 @@ func (m *Manager) ListFiles(options ListOptions) (*ListResult, error) {
 			HasNext:    options.Page < totalPages,
 			HasPrev:    options.Page > 1,
 		},
-	}, nil
+	}
 }
 
 // matchesListFilters checks if a file matches the list filters.
 func matchesListFilters(meta FileMetadata, options ListOptions) bool {
+	if !hasAllTags(meta.Tags, options.Tags) {
+		return false
+	}
+
 	// Category filter
 	if options.Category != "" {
 		categoryLower := strings.ToLower(meta.Category)
@@ -362,13 +398,14 @@ func buildBreadcrumbs(path string) []Breadcrumb {
 func (m *Manager) countFilesInCategory(categoryPath string) int {
 	count := 0
 	categoryLower := strings.ToLower(categoryPath)
-	for _, meta := range m.index.data {
+	_ = m.index.ForEach(func(meta FileMetadata) bool {
 		metaCategoryLower := strings.ToLower(meta.Category)
 		if strings.HasPrefix(metaCategoryLower, categoryLower) || 
 		   strings.HasPrefix(categoryLower, metaCategoryLower) {
 			count++
 		}
-	}
+		return true
+	})
 	return count
 }
 
@@ -388,7 +425,7 @@ func (m *Manager) GetCategories() ([]CategoryInfo, error) {
 	categoryMap := make(map[string]*CategoryInfo)
 
 	// Aggregate by category
-	for _, meta := range m.index.data {
+	_ = m.index.ForEach(func(meta FileMetadata) bool {
 		category := meta.Category
 		if category == "" {
 			category = "uncategorized"
@@ -417,7 +454,8 @@ func (m *Manager) GetCategories() ([]CategoryInfo, error) {
 		if !mimeExists {
 			catInfo.MimeTypes = append(catInfo.MimeTypes, meta.MimeType)
 		}
-	}
+		return true
+	})
 
 	// Convert to slice and sort
 	categories := make([]CategoryInfo, 0, len(categoryMap))
@@ -469,7 +507,7 @@ func (m *Manager) GetStorageStats() (*StorageStats, error) {
 	last30d := now.Add(-30 * 24 * time.Hour)
 
 	// Process all files
-	for _, meta := range m.index.data {
+	_ = m.index.ForEach(func(meta FileMetadata) bool {
 		stats.TotalFiles++
 		stats.TotalSize += meta.Size
 
@@ -502,7 +540,8 @@ func (m *Manager) GetStorageStats() (*StorageStats, error) {
 		if meta.UploadedAt.After(last30d) {
 			stats.RecentUploads.Last30Days++
 		}
-	}
+		return true
+	})
 
 	return stats, nil
 }
@@ -16,21 +16,34 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/Muneer320/RhinoBox/internal/cache"
+	"github.com/Muneer320/RhinoBox/internal/textextract"
 )
 
 // Manager provides a tiny abstraction over the filesystem for hackspeed storage.
 type Manager struct {
-	root           string
-	storageRoot    string
-	classifier     *Classifier
-	rulesMgr       *RoutingRulesManager
-	index          *MetadataIndex
-	versionIndex   *VersionIndex
-	notesIndex     *NotesIndex
-	hashIndex      *cache.HashIndex
-	referenceIndex *ReferenceIndex
-	mu             sync.Mutex
-	scanState      scanState
+	root               string
+	storageRoot        string
+	backend            Backend
+	classifier         *Classifier
+	rulesMgr           *RoutingRulesManager
+	index              *MetadataIndex
+	versionIndex       *VersionIndex
+	notesIndex         *NotesIndex
+	trashIndex         *TrashIndex
+	folderIndex        *FolderIndex
+	savedSearchIndex   *SavedSearchIndex
+	shareIndex         *ShareIndex
+	thumbnailIndex     *ThumbnailIndex
+	contentIndex       *ContentIndex
+	extractedTextIndex *ExtractedTextIndex
+	hashIndex          *cache.HashIndex
+	cache              *cache.Cache
+	referenceIndex     *ReferenceIndex
+	mu                 sync.Mutex
+	scanState          scanState
+	quota              Quota
+	thumbnailSizes     []int
+	storeHooks         []func(FileMetadata)
 }
 
 // StoreRequest captures parameters for the high-throughput storage path.
@@ -49,8 +62,18 @@ type StoreResult struct {
 	Duplicate bool
 }
 
-// NewManager bootstraps the directory structure RhinoBox expects.
+// NewManager bootstraps the directory structure RhinoBox expects and keeps
+// file contents on the local disk.
 func NewManager(root string) (*Manager, error) {
+	return NewManagerWithBackend(root, NewLocalBackend(root))
+}
+
+// NewManagerWithBackend is NewManager with file contents delegated to backend.
+// Metadata, indexes, audit logs and upload staging always stay under root.
+func NewManagerWithBackend(root string, backend Backend) (*Manager, error) {
+	if backend == nil {
+		return nil, errors.New("storage backend is required")
+	}
 	mediaDir := filepath.Join(root, "media")
 	jsonDir := filepath.Join(root, "json")
 	if err := os.MkdirAll(mediaDir, 0o755); err != nil {
@@ -87,6 +110,48 @@ func NewManager(root string) (*Manager, error) {
 		return nil, fmt.Errorf("failed to initialize notes index: %w", err)
 	}
 
+	trashIndex, err := NewTrashIndex(filepath.Join(root, "metadata", "trash.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize trash index: %w", err)
+	}
+
+	folderIndex, err := NewFolderIndex(filepath.Join(root, "metadata", "folders.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize folder index: %w", err)
+	}
+
+	savedSearchIndex, err := NewSavedSearchIndex(filepath.Join(root, "metadata", "saved_searches.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize saved search index: %w", err)
+	}
+
+	shareIndex, err := NewShareIndex(filepath.Join(root, "metadata", "shares.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize share index: %w", err)
+	}
+
+	thumbnailIndex, err := NewThumbnailIndex(filepath.Join(root, "metadata", "thumbnails.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize thumbnail index: %w", err)
+	}
+
+	extractedTextIndex, err := NewExtractedTextIndex(filepath.Join(root, "metadata", "text.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize extracted text index: %w", err)
+	}
+
+	contentIndex, err := NewContentIndex(filepath.Join(root, "metadata", "content.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize content index: %w", err)
+	}
+	// A new data directory has nothing to index; existing ones are built on
+	// the first content search.
+	if index.Count() == 0 {
+		if err := contentIndex.MarkBuilt(); err != nil {
+			return nil, fmt.Errorf("failed to initialize content index: %w", err)
+		}
+	}
+
 	// Initialize cache for deduplication
 	cacheConfig := cache.DefaultConfig()
 	cacheConfig.L3Path = filepath.Join(root, "cache")
@@ -98,15 +163,24 @@ func NewManager(root string) (*Manager, error) {
 	hashIndex := cache.NewHashIndex(cacheInstance)
 
 	return &Manager{
-		root:           root,
-		storageRoot:    storageRoot,
-		classifier:     classifier,
-		rulesMgr:       rulesMgr,
-		index:          index,
-		versionIndex:   versionIndex,
-		notesIndex:     notesIndex,
-		hashIndex:      hashIndex,
-		referenceIndex: nil, // Lazily initialized when needed
+		root:               root,
+		storageRoot:        storageRoot,
+		backend:            backend,
+		classifier:         classifier,
+		rulesMgr:           rulesMgr,
+		index:              index,
+		versionIndex:       versionIndex,
+		notesIndex:         notesIndex,
+		trashIndex:         trashIndex,
+		folderIndex:        folderIndex,
+		savedSearchIndex:   savedSearchIndex,
+		shareIndex:         shareIndex,
+		thumbnailIndex:     thumbnailIndex,
+		contentIndex:       contentIndex,
+		extractedTextIndex: extractedTextIndex,
+		hashIndex:          hashIndex,
+		cache:              cacheInstance,
+		referenceIndex:     nil, // Lazily initialized when needed
 	}, nil
 }
 
@@ -115,11 +189,42 @@ func (m *Manager) Root() string {
 	return m.root
 }
 
+// Close releases the index store and dedup cache. The Manager must not be used afterwards.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	if m.referenceIndex != nil {
+		errs = append(errs, m.referenceIndex.Close())
+		m.referenceIndex = nil
+	}
+	errs = append(errs, m.contentIndex.Close(), m.extractedTextIndex.Close(), m.thumbnailIndex.Close(), m.shareIndex.Close(), m.savedSearchIndex.Close(), m.folderIndex.Close(), m.trashIndex.Close(), m.notesIndex.Close(), m.versionIndex.Close(), m.index.Close(), m.cache.Close())
+	if closer, ok := m.backend.(io.Closer); ok {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Backend returns the blob backend holding file contents.
+func (m *Manager) Backend() Backend {
+	return m.backend
+}
+
 // RoutingRules returns the routing rules manager.
 func (m *Manager) RoutingRules() *RoutingRulesManager {
 	return m.rulesMgr
 }
 
+// OnStore registers hook to run after a new (non-duplicate) file is stored.
+// Hooks run synchronously on the storing goroutine, so they should hand off
+// slow work rather than do it inline.
+func (m *Manager) OnStore(hook func(FileMetadata)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.storeHooks = append(m.storeHooks, hook)
+}
+
 // Classifier returns the file classifier.
 func (m *Manager) Classifier() *Classifier {
 	return m.classifier
@@ -132,10 +237,6 @@ func (m *Manager) StoreFile(req StoreRequest) (*StoreResult, error) {
 	}
 
 	components := m.classifier.ClassifyWithRules(req.MimeType, req.Filename, req.CategoryHint, m.rulesMgr)
-	fullDir := filepath.Join(append([]string{m.storageRoot}, components...)...)
-	if err := os.MkdirAll(fullDir, 0o755); err != nil {
-		return nil, err
-	}
 	if err := os.MkdirAll(filepath.Join(m.storageRoot, ".tmp"), 0o755); err != nil {
 		return nil, err
 	}
@@ -158,6 +259,20 @@ func (m *Manager) StoreFile(req StoreRequest) (*StoreResult, error) {
 		return nil, err
 	}
 	checksum := hex.EncodeToString(hasher.Sum(nil))
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+	extracted := extractMediaInfo(tmpPath)
+	var text string
+	var indexText bool
+	var document *textextract.Result
+	if isIndexable(req.Filename, req.MimeType) {
+		text, indexText = readIndexableFile(tmpPath)
+	} else if textextract.Supported(req.Filename, req.MimeType) {
+		document = extractDocumentText(tmpPath)
+	}
 
 	m.mu.Lock()
 	if existing := m.index.FindByHash(checksum); existing != nil {
@@ -165,23 +280,17 @@ func (m *Manager) StoreFile(req StoreRequest) (*StoreResult, error) {
 		_ = os.Remove(tmpPath)
 		return &StoreResult{Metadata: *existing, Duplicate: true}, nil
 	}
-
-	filename := fmt.Sprintf("%s_%s%s", checksum[:12], base, ext)
-	finalPath := filepath.Join(fullDir, filename)
-	if err := os.Rename(tmpPath, finalPath); err != nil {
+	if err := m.checkQuotaLocked(info.Size()); err != nil {
 		m.mu.Unlock()
 		_ = os.Remove(tmpPath)
 		return nil, err
 	}
 
-	rel, err := filepath.Rel(m.root, finalPath)
-	if err != nil {
-		m.mu.Unlock()
-		return nil, err
-	}
-	info, err := os.Stat(finalPath)
-	if err != nil {
+	filename := fmt.Sprintf("%s_%s%s", checksum[:12], base, ext)
+	key := blobKey(append(append([]string{"storage"}, components...), filename)...)
+	if err := m.backend.PutFile(key, tmpPath); err != nil {
 		m.mu.Unlock()
+		_ = os.Remove(tmpPath)
 		return nil, err
 	}
 
@@ -196,19 +305,33 @@ func (m *Manager) StoreFile(req StoreRequest) (*StoreResult, error) {
 	metadata := FileMetadata{
 		Hash:         checksum,
 		OriginalName: req.Filename,
-		StoredPath:   filepath.ToSlash(rel),
+		StoredPath:   key,
 		Category:     strings.Join(components, "/"),
 		MimeType:     req.MimeType,
 		Size:         info.Size(),
 		UploadedAt:   time.Now().UTC(),
 		Metadata:     metaCopy,
+		Extracted:    extracted,
 	}
 	if err := m.index.Add(metadata); err != nil {
 		m.mu.Unlock()
+		_ = m.backend.Delete(key)
 		return nil, err
 	}
+	if indexText {
+		m.indexTextLocked(checksum, text)
+	}
+	if document != nil {
+		if _, err := m.storeExtractedTextLocked(metadata, document); err != nil {
+			fmt.Fprintf(os.Stderr, "text extraction failed for %s: %v\n", checksum, err)
+		}
+	}
+	hooks := m.storeHooks
 	m.mu.Unlock()
 
+	for _, hook := range hooks {
+		hook(metadata)
+	}
 	return &StoreResult{Metadata: metadata, Duplicate: false}, nil
 }
 
@@ -262,6 +385,22 @@ func (m *Manager) WriteJSONFile(relPath string, payload any) (string, error) {
 	return filepath.ToSlash(relPath), nil
 }
 
+// ReadJSONFile decodes the JSON file at relPath into v. It reports false
+// without error when the file does not exist.
+func (m *Manager) ReadJSONFile(relPath string, v any) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(m.root, relPath))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("decode %s: %w", relPath, err)
+	}
+	return true, nil
+}
+
 // AppendNDJSON appends newline-delimited JSON documents atomically.
 func (m *Manager) AppendNDJSON(relPath string, docs []map[string]any) (string, error) {
 	abs := filepath.Join(m.root, relPath)
@@ -290,21 +429,19 @@ func (m *Manager) AppendNDJSON(relPath string, docs []map[string]any) (string, e
 
 // NextJSONBatchPath returns a timestamped file path for new JSON batches.
 func (m *Manager) NextJSONBatchPath(engine, namespace string) string {
-	slug := sanitize(namespace)
-	if slug == "" {
-		slug = "default"
-	}
 	ts := time.Now().UTC().Format("20060102T150405Z")
-	return filepath.ToSlash(filepath.Join("json", engine, slug, fmt.Sprintf("batch_%s.ndjson", ts)))
+	return filepath.ToSlash(filepath.Join("json", engine, jsonNamespaceSlug(namespace), fmt.Sprintf("batch_%s.ndjson", ts)))
 }
 
 // StatisticsResult contains aggregated statistics about stored files.
 type StatisticsResult struct {
-	TotalFiles   int64  `json:"total_files"`
-	StorageUsed  int64  `json:"storage_used_bytes"`
-	StorageUsedFormatted string `json:"storage_used"`
-	CollectionCount int `json:"collection_count"`
-	Collections map[string]int64 `json:"collections"`
+	TotalFiles           int64            `json:"total_files"`
+	StorageUsed          int64            `json:"storage_used_bytes"`
+	StorageUsedFormatted string           `json:"storage_used"`
+	CollectionCount      int              `json:"collection_count"`
+	Collections          map[string]int64 `json:"collections"`
+	// Chunking is set when the backend stores content-defined chunks.
+	Chunking *ChunkStats `json:"chunking,omitempty"`
 }
 
 // GetAllMetadata returns all file metadata entries.
@@ -342,13 +479,21 @@ func (m *Manager) GetStatistics() (*StatisticsResult, error) {
 	// Format storage size
 	storageFormatted := formatBytes(totalStorage)
 
-	return &StatisticsResult{
-		TotalFiles:          totalFiles,
-		StorageUsed:         totalStorage,
+	result := &StatisticsResult{
+		TotalFiles:           totalFiles,
+		StorageUsed:          totalStorage,
 		StorageUsedFormatted: storageFormatted,
-		CollectionCount:    len(collectionSet),
-		Collections:        collectionMap,
-	}, nil
+		CollectionCount:      len(collectionSet),
+		Collections:          collectionMap,
+	}
+	if chunked, ok := m.backend.(*ChunkedBackend); ok {
+		chunkStats, err := chunked.Stats()
+		if err != nil {
+			return nil, err
+		}
+		result.Chunking = &chunkStats
+	}
+	return result, nil
 }
 
 // formatBytes converts bytes to human-readable format.
@@ -450,6 +595,18 @@ func (m *Manager) CreateVersion(req VersionRequest) (*VersionResult, error) {
 	newHash := storeResult.Metadata.Hash
 	newSize := storeResult.Metadata.Size
 
+	// Tags and folders belong to the logical file: the new version inherits
+	// the tags of the current one and takes its place in folders. Content
+	// that was already stored is another file's record, whose tags stay its
+	// own.
+	previousHash := m.currentVersionHash(req.FileID)
+	if !storeResult.Duplicate {
+		m.mu.Lock()
+		m.inheritTagsLocked(previousHash, newHash)
+		m.mu.Unlock()
+	}
+	m.replaceInFolders(previousHash, newHash)
+
 	// Check if version chain exists for this file_id
 	chain, err := m.versionIndex.GetVersionChain(req.FileID)
 	isNewFile := false
@@ -539,9 +696,34 @@ func (m *Manager) GetVersionFile(fileID string, versionNumber int) (*FileRetriev
 	return m.GetFileByHash(version.Hash)
 }
 
-// RevertVersion reverts a file to a previous version
+// RevertVersion reverts a file to a previous version. The reverted version
+// picks up the tags and folder places of the version it replaces.
 func (m *Manager) RevertVersion(fileID string, versionNumber int, comment string) (*VersionMetadata, error) {
-	return m.versionIndex.RevertToVersion(fileID, versionNumber, comment)
+	previousHash := m.currentVersionHash(fileID)
+	version, err := m.versionIndex.RevertToVersion(fileID, versionNumber, comment)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.inheritTagsLocked(previousHash, version.Hash)
+	m.mu.Unlock()
+	m.replaceInFolders(previousHash, version.Hash)
+	return version, nil
+}
+
+// currentVersionHash returns the hash of the current version of fileID, or
+// fileID itself when it has no version chain yet.
+func (m *Manager) currentVersionHash(fileID string) string {
+	chain, err := m.versionIndex.GetVersionChain(fileID)
+	if err != nil {
+		return fileID
+	}
+	for _, version := range chain.Versions {
+		if version.IsCurrent {
+			return version.Hash
+		}
+	}
+	return fileID
 }
 
 // GetVersionDiff returns differences between two versions
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"os"
+	"strings"
+
+	"github.com/Muneer320/RhinoBox/internal/mediainfo"
+)
+
+// extractMediaInfo reads embedded media metadata from a staged upload.
+// Extraction is best effort: unreadable or unrecognised files yield nil, and
+// a parse error part-way through keeps whatever was read before it.
+func extractMediaInfo(path string) *mediainfo.Info {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	info, _ := mediainfo.Extract(file)
+	return info
+}
+
+// hasExtractedFilters reports whether any filter needs extracted metadata.
+func (f SearchFilters) hasExtractedFilters() bool {
+	return f.Camera != "" || !f.TakenFrom.IsZero() || !f.TakenTo.IsZero() || f.HasGPS ||
+		f.MinWidth > 0 || f.MinHeight > 0 || f.MinDuration > 0 || f.MaxDuration > 0 ||
+		f.Artist != "" || f.Album != "" || f.Codec != ""
+}
+
+// matchesExtracted applies the extracted-metadata filters to info.
+func matchesExtracted(info *mediainfo.Info, filters SearchFilters) bool {
+	if !filters.hasExtractedFilters() {
+		return true
+	}
+	if info == nil {
+		return false
+	}
+
+	if filters.Camera != "" && !containsFold(info.CameraMake+" "+info.CameraModel, filters.Camera) {
+		return false
+	}
+	if !filters.TakenFrom.IsZero() || !filters.TakenTo.IsZero() {
+		if info.TakenAt == nil {
+			return false
+		}
+		if !filters.TakenFrom.IsZero() && info.TakenAt.Before(filters.TakenFrom) {
+			return false
+		}
+		if !filters.TakenTo.IsZero() && info.TakenAt.After(filters.TakenTo) {
+			return false
+		}
+	}
+	if filters.HasGPS && info.GPS == nil {
+		return false
+	}
+	if info.Width < filters.MinWidth || info.Height < filters.MinHeight {
+		return false
+	}
+	if filters.MinDuration > 0 || filters.MaxDuration > 0 {
+		duration := info.Duration()
+		if duration <= 0 || duration < filters.MinDuration {
+			return false
+		}
+		if filters.MaxDuration > 0 && duration > filters.MaxDuration {
+			return false
+		}
+	}
+	if filters.Artist != "" && !containsFold(info.Artist, filters.Artist) {
+		return false
+	}
+	if filters.Album != "" && !containsFold(info.Album, filters.Album) {
+		return false
+	}
+	if filters.Codec != "" && !containsFold(info.VideoCodec, filters.Codec) && !containsFold(info.AudioCodec, filters.Codec) {
+		return false
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
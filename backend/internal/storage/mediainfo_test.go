@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// id3Tagged returns a minimal MP3 with an ID3v2.3 artist and length.
+func id3Tagged(artist string, millis string) []byte {
+	var frames []byte
+	for _, f := range []struct{ id, text string }{{"TPE1", artist}, {"TLEN", millis}} {
+		body := append([]byte{0}, f.text...)
+		frames = append(frames, f.id...)
+		frames = append(frames, 0, 0, 0, byte(len(body)), 0, 0)
+		frames = append(frames, body...)
+	}
+	tag := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, byte(len(frames))}
+	return append(append(tag, frames...), 0xFF, 0xFB, 0x90, 0x00)
+}
+
+func TestStoreFileRecordsExtractedMetadata(t *testing.T) {
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	img := storeImage(t, m, "wide.png", "image/png", testImage(300, 120))
+	song := id3Tagged("The Weather Band", "185000")
+	res, err := m.StoreFile(StoreRequest{Reader: bytes.NewReader(song), Filename: "song.mp3", MimeType: "audio/mpeg", Size: int64(len(song))})
+	if err != nil {
+		t.Fatalf("store song: %v", err)
+	}
+	if _, err := m.StoreFile(StoreRequest{Reader: bytes.NewReader([]byte("notes")), Filename: "notes.txt", MimeType: "text/plain", Size: 5}); err != nil {
+		t.Fatalf("store text: %v", err)
+	}
+
+	if ex := img.Metadata.Extracted; ex == nil || ex.Width != 300 || ex.Height != 120 || ex.Format != "png" {
+		t.Fatalf("unexpected image extraction %+v", ex)
+	}
+	stored, err := m.GetFileMetadata(res.Metadata.Hash)
+	if err != nil {
+		t.Fatalf("GetFileMetadata: %v", err)
+	}
+	if ex := stored.Extracted; ex == nil || ex.Artist != "The Weather Band" || ex.DurationSeconds != 185 {
+		t.Fatalf("unexpected audio extraction %+v", ex)
+	}
+
+	tests := []struct {
+		name    string
+		filters SearchFilters
+		want    []string
+	}{
+		{"min width", SearchFilters{MinWidth: 200}, []string{"wide.png"}},
+		{"min width excludes", SearchFilters{MinWidth: 400}, nil},
+		{"artist", SearchFilters{Artist: "weather"}, []string{"song.mp3"}},
+		{"duration range", SearchFilters{MinDuration: time.Minute, MaxDuration: 4 * time.Minute}, []string{"song.mp3"}},
+		{"duration too short", SearchFilters{MaxDuration: time.Minute}, nil},
+		{"gps requires position", SearchFilters{HasGPS: true}, nil},
+		{"combined with name", SearchFilters{Name: "song", Artist: "band"}, []string{"song.mp3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := m.SearchFiles(tt.filters)
+			if len(results) != len(tt.want) {
+				t.Fatalf("expected %v, got %d results", tt.want, len(results))
+			}
+			for i, name := range tt.want {
+				if results[i].OriginalName != name {
+					t.Fatalf("expected %q, got %q", name, results[i].OriginalName)
+				}
+			}
+		})
+	}
+}
@@ -1,164 +1,560 @@
 package storage
 
 import (
-    "encoding/json"
-    "errors"
-    "os"
-    "path/filepath"
-    "strings"
-    "sync"
-    "time"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Muneer320/RhinoBox/internal/mediainfo"
+	"github.com/dgraph-io/badger/v4"
 )
 
 // FileMetadata captures stored file information for dedup and auditing.
 type FileMetadata struct {
-    Hash         string            `json:"hash"`
-    OriginalName string            `json:"original_name"`
-    StoredPath   string            `json:"stored_path"`
-    Category     string            `json:"category"`
-    MimeType     string            `json:"mime_type"`
-    Size         int64             `json:"size"`
-    UploadedAt   time.Time         `json:"uploaded_at"`
-    Metadata     map[string]string `json:"metadata"`
+	Hash         string            `json:"hash"`
+	OriginalName string            `json:"original_name"`
+	StoredPath   string            `json:"stored_path"`
+	Category     string            `json:"category"`
+	MimeType     string            `json:"mime_type"`
+	Size         int64             `json:"size"`
+	UploadedAt   time.Time         `json:"uploaded_at"`
+	Metadata     map[string]string `json:"metadata"`
+	// Extracted holds EXIF, tag and container details read at ingest.
+	Extracted *mediainfo.Info `json:"extracted,omitempty"`
+	// Tags are normalized hierarchical tags such as project/apollo/2026,
+	// sorted and without duplicates.
+	Tags []string `json:"tags,omitempty"`
 }
 
-// MetadataIndex persists file metadata to disk and enables duplicate detection.
+// Key layout for file metadata inside the shared index store.
+//
+//	file:<hash>                          -> FileMetadata JSON
+//	fcat:<lower(category)>\x00<hash>     -> secondary index by category
+//	fmime:<lower(mime)>\x00<hash>        -> secondary index by MIME type
+//	fdate:<unix nanos, 20 digits>\x00<hash> -> secondary index by upload date
+//	ftag:<tag>\x00<hash>                 -> secondary index by tag
+//	fpath:<stored path>\x00<hash>       -> secondary index by stored path
+const (
+	fileKeyPrefix     = "file:"
+	fileCategoryIndex = "fcat:"
+	fileMimeIndex     = "fmime:"
+	fileDateIndex     = "fdate:"
+	fileTagIndex      = "ftag:"
+	filePathIndex     = "fpath:"
+)
+
+// MetadataIndex persists file metadata in the embedded index store and
+// enables duplicate detection plus lookups by category, MIME type and upload date.
 type MetadataIndex struct {
-    path string
-    mu   sync.RWMutex
-    data map[string]FileMetadata
+	path  string
+	store *kvStore
+	mu    sync.RWMutex
+
+	// usage is computed on first use and then maintained by Add and Delete.
+	usage       Usage
+	usageLoaded bool
+
+	// beforeAdd, when set, runs before Add writes a record and aborts the
+	// write with its error. Tests use it to simulate failed index writes.
+	beforeAdd func(FileMetadata) error
 }
 
+// NewMetadataIndex opens the index store next to path. A legacy whole-file JSON
+// index found at path is imported once and renamed to <path>.migrated.
 func NewMetadataIndex(path string) (*MetadataIndex, error) {
-    idx := &MetadataIndex{path: path, data: map[string]FileMetadata{}}
-    if err := idx.load(); err != nil {
-        return nil, err
-    }
-    return idx, nil
-}
-
-func (idx *MetadataIndex) load() error {
-    dir := filepath.Dir(idx.path)
-    if err := os.MkdirAll(dir, 0o755); err != nil {
-        return err
-    }
-
-    raw, err := os.ReadFile(idx.path)
-    if errors.Is(err, os.ErrNotExist) {
-        return nil
-    }
-    if err != nil {
-        return err
-    }
-
-    var items []FileMetadata
-    if len(raw) == 0 {
-        return nil
-    }
-    if err := json.Unmarshal(raw, &items); err != nil {
-        return err
-    }
-
-    for _, item := range items {
-        idx.data[item.Hash] = item
-    }
-    return nil
-}
-
-func (idx *MetadataIndex) persistLocked() error {
-    items := make([]FileMetadata, 0, len(idx.data))
-    for _, meta := range idx.data {
-        items = append(items, meta)
-    }
-
-    tmp := idx.path + ".tmp"
-    buf, err := json.MarshalIndent(items, "", "  ")
-    if err != nil {
-        return err
-    }
-    if err := os.WriteFile(tmp, buf, 0o644); err != nil {
-        return err
-    }
-    return os.Rename(tmp, idx.path)
+	store, err := openKVStore(kvDirFor(path))
+	if err != nil {
+		return nil, err
+	}
+	idx := &MetadataIndex{path: path, store: store}
+	if err := migrateLegacyJSON(store, path, idx.importJSON); err != nil {
+		_ = store.release()
+		return nil, err
+	}
+	if err := migratePathIndex(store); err != nil {
+		_ = store.release()
+		return nil, fmt.Errorf("migrate path index: %w", err)
+	}
+	return idx, nil
+}
+
+// pathIndexMarker records that fpath entries carry the hash in their key.
+const pathIndexMarker = "sys:migrated:fpath-multi"
+
+// migratePathIndex rewrites fpath:<path> -> hash entries, which held one
+// hash per path, as fpath:<path>\x00<hash> keys so hard-link copies sharing
+// a stored path each keep their own entry.
+func migratePathIndex(store *kvStore) error {
+	var legacy map[string]string
+	err := store.db.View(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(pathIndexMarker)); err == nil {
+			return nil
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		legacy = make(map[string]string)
+		return scanPrefix(txn, filePathIndex, true, func(key string, val []byte) (bool, error) {
+			if !strings.ContainsRune(key, 0) {
+				legacy[key] = string(val)
+			}
+			return true, nil
+		})
+	})
+	if err != nil || legacy == nil {
+		return err
+	}
+	wb := store.db.NewWriteBatch()
+	for key, hash := range legacy {
+		if err := wb.Delete([]byte(key)); err != nil {
+			wb.Cancel()
+			return err
+		}
+		if err := wb.Set([]byte(pathIndexKey(strings.TrimPrefix(key, filePathIndex), hash)), nil); err != nil {
+			wb.Cancel()
+			return err
+		}
+	}
+	if err := wb.Set([]byte(pathIndexMarker), []byte("1")); err != nil {
+		wb.Cancel()
+		return err
+	}
+	return wb.Flush()
+}
+
+// importJSON loads the legacy files.json array format.
+func (idx *MetadataIndex) importJSON(raw []byte, w kvWriter) error {
+	var items []FileMetadata
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := putFileMetadata(w, nil, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the index store.
+func (idx *MetadataIndex) Close() error {
+	return idx.store.release()
+}
+
+func fileKey(hash string) string {
+	return fileKeyPrefix + hash
+}
+
+func categoryIndexKey(category, hash string) string {
+	return fileCategoryIndex + strings.ToLower(category) + "\x00" + hash
+}
+
+func mimeIndexKey(mimeType, hash string) string {
+	return fileMimeIndex + strings.ToLower(mimeType) + "\x00" + hash
+}
+
+func dateIndexKey(t time.Time, hash string) string {
+	return fileDateIndex + dateIndexStamp(t) + "\x00" + hash
+}
+
+func tagIndexKey(tag, hash string) string {
+	return fileTagIndex + tag + "\x00" + hash
+}
+
+func pathIndexKey(storedPath, hash string) string {
+	return filePathIndex + storedPath + "\x00" + hash
+}
+
+func dateIndexStamp(t time.Time) string {
+	nanos := t.UnixNano()
+	if nanos < 0 {
+		nanos = 0
+	}
+	return fmt.Sprintf("%020d", nanos)
+}
+
+// hashFromIndexKey extracts the hash suffix of a secondary index key.
+func hashFromIndexKey(key string) string {
+	if i := strings.LastIndexByte(key, 0); i >= 0 {
+		return key[i+1:]
+	}
+	return ""
+}
+
+// putFileMetadata writes meta and its secondary index entries, removing the
+// entries that belonged to previous (if any).
+func putFileMetadata(w kvWriter, previous *FileMetadata, meta FileMetadata) error {
+	if previous != nil {
+		if err := deleteFileIndexes(w, *previous); err != nil {
+			return err
+		}
+	}
+	if err := setJSON(w, fileKey(meta.Hash), meta); err != nil {
+		return err
+	}
+	for _, key := range []string{
+		categoryIndexKey(meta.Category, meta.Hash),
+		mimeIndexKey(meta.MimeType, meta.Hash),
+		dateIndexKey(meta.UploadedAt, meta.Hash),
+	} {
+		if err := w.Set([]byte(key), nil); err != nil {
+			return err
+		}
+	}
+	for _, tag := range meta.Tags {
+		if err := w.Set([]byte(tagIndexKey(tag, meta.Hash)), nil); err != nil {
+			return err
+		}
+	}
+	return w.Set([]byte(pathIndexKey(meta.StoredPath, meta.Hash)), nil)
+}
+
+// deleteFileIndexes removes the secondary index entries for meta.
+func deleteFileIndexes(w kvWriter, meta FileMetadata) error {
+	for _, key := range []string{
+		categoryIndexKey(meta.Category, meta.Hash),
+		mimeIndexKey(meta.MimeType, meta.Hash),
+		dateIndexKey(meta.UploadedAt, meta.Hash),
+		pathIndexKey(meta.StoredPath, meta.Hash),
+	} {
+		if err := w.Delete([]byte(key)); err != nil {
+			return err
+		}
+	}
+	for _, tag := range meta.Tags {
+		if err := w.Delete([]byte(tagIndexKey(tag, meta.Hash))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getLocked reads a single record inside txn.
+func (idx *MetadataIndex) getLocked(txn *badger.Txn, hash string) (*FileMetadata, error) {
+	var meta FileMetadata
+	found, err := getJSON(txn, fileKey(hash), &meta)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// loadHashes resolves a list of hashes to metadata records, skipping dangling entries.
+func (idx *MetadataIndex) loadHashes(txn *badger.Txn, hashes []string) ([]FileMetadata, error) {
+	results := make([]FileMetadata, 0, len(hashes))
+	for _, hash := range hashes {
+		meta, err := idx.getLocked(txn, hash)
+		if err != nil {
+			return nil, err
+		}
+		if meta != nil {
+			results = append(results, *meta)
+		}
+	}
+	return results, nil
 }
 
 func (idx *MetadataIndex) FindByHash(hash string) *FileMetadata {
-    idx.mu.RLock()
-    defer idx.mu.RUnlock()
-    if meta, ok := idx.data[hash]; ok {
-        clone := meta
-        return &clone
-    }
-    return nil
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var meta *FileMetadata
+	_ = idx.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		meta, err = idx.getLocked(txn, hash)
+		return err
+	})
+	return meta
 }
 
+// FindByStoredPath returns a record whose StoredPath equals storedPath.
+// Hard-link copies share their original's path; the lowest hash wins.
+func (idx *MetadataIndex) FindByStoredPath(storedPath string) *FileMetadata {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var meta *FileMetadata
+	_ = idx.store.db.View(func(txn *badger.Txn) error {
+		return scanPrefix(txn, filePathIndex+storedPath+"\x00", false, func(key string, _ []byte) (bool, error) {
+			found, err := idx.getLocked(txn, hashFromIndexKey(key))
+			if err != nil {
+				return false, err
+			}
+			meta = found
+			return meta == nil, nil
+		})
+	})
+	return meta
+}
+
+// Add inserts or replaces the record for meta.Hash and keeps secondary indexes in sync.
 func (idx *MetadataIndex) Add(meta FileMetadata) error {
-    idx.mu.Lock()
-    defer idx.mu.Unlock()
-    idx.data[meta.Hash] = meta
-    return idx.persistLocked()
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.beforeAdd != nil {
+		if err := idx.beforeAdd(meta); err != nil {
+			return err
+		}
+	}
+	var previous *FileMetadata
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		var err error
+		previous, err = idx.getLocked(txn, meta.Hash)
+		if err != nil {
+			return err
+		}
+		return putFileMetadata(txn, previous, meta)
+	})
+	if err == nil && idx.usageLoaded {
+		if previous != nil {
+			idx.usage.Files--
+			idx.usage.Bytes -= previous.Size
+		}
+		idx.usage.Files++
+		idx.usage.Bytes += meta.Size
+	}
+	return err
 }
 
 // Delete removes a metadata entry by hash.
 func (idx *MetadataIndex) Delete(hash string) error {
-    idx.mu.Lock()
-    defer idx.mu.Unlock()
-    if _, exists := idx.data[hash]; !exists {
-        return errors.New("metadata entry not found")
-    }
-    delete(idx.data, hash)
-    return idx.persistLocked()
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	var existing *FileMetadata
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		var err error
+		existing, err = idx.getLocked(txn, hash)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return errors.New("metadata entry not found")
+		}
+		if err := deleteFileIndexes(txn, *existing); err != nil {
+			return err
+		}
+		return txn.Delete([]byte(fileKey(hash)))
+	})
+	if err == nil && idx.usageLoaded {
+		idx.usage.Files--
+		idx.usage.Bytes -= existing.Size
+	}
+	return err
+}
+
+// Usage returns the number of records and the sum of their sizes. The first
+// call scans the index; later calls return the running totals.
+func (idx *MetadataIndex) Usage() (Usage, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.usageLoaded {
+		return idx.usage, nil
+	}
+
+	var usage Usage
+	err := idx.store.db.View(func(txn *badger.Txn) error {
+		return scanPrefix(txn, fileKeyPrefix, true, func(_ string, val []byte) (bool, error) {
+			var meta FileMetadata
+			if err := json.Unmarshal(val, &meta); err != nil {
+				return false, err
+			}
+			usage.Files++
+			usage.Bytes += meta.Size
+			return true, nil
+		})
+	})
+	if err != nil {
+		return Usage{}, err
+	}
+	idx.usage = usage
+	idx.usageLoaded = true
+	return usage, nil
+}
+
+// Count returns the number of metadata records.
+func (idx *MetadataIndex) Count() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	count := 0
+	_ = idx.store.db.View(func(txn *badger.Txn) error {
+		return scanPrefix(txn, fileKeyPrefix, false, func(string, []byte) (bool, error) {
+			count++
+			return true, nil
+		})
+	})
+	return count
+}
+
+// ForEach streams every record to fn without materializing the whole index.
+// Returning false from fn stops the iteration.
+func (idx *MetadataIndex) ForEach(fn func(FileMetadata) bool) error {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return idx.store.db.View(func(txn *badger.Txn) error {
+		return scanPrefix(txn, fileKeyPrefix, true, func(_ string, val []byte) (bool, error) {
+			var meta FileMetadata
+			if err := json.Unmarshal(val, &meta); err != nil {
+				return false, err
+			}
+			return fn(meta), nil
+		})
+	})
+}
+
+// findByIndexPrefix resolves all hashes under a secondary index prefix.
+func (idx *MetadataIndex) findByIndexPrefix(prefix string) []FileMetadata {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	results := make([]FileMetadata, 0)
+	_ = idx.store.db.View(func(txn *badger.Txn) error {
+		hashes := make([]string, 0)
+		if err := scanPrefix(txn, prefix, false, func(key string, _ []byte) (bool, error) {
+			hashes = append(hashes, hashFromIndexKey(key))
+			return true, nil
+		}); err != nil {
+			return err
+		}
+		found, err := idx.loadHashes(txn, hashes)
+		if err != nil {
+			return err
+		}
+		results = found
+		return nil
+	})
+	return results
+}
+
+// FindByCategory returns files whose category equals category (case-insensitive).
+func (idx *MetadataIndex) FindByCategory(category string) []FileMetadata {
+	return idx.findByIndexPrefix(fileCategoryIndex + strings.ToLower(category) + "\x00")
+}
+
+// FindByMimeType returns files with the given MIME type (case-insensitive).
+func (idx *MetadataIndex) FindByMimeType(mimeType string) []FileMetadata {
+	return idx.findByIndexPrefix(fileMimeIndex + strings.ToLower(mimeType) + "\x00")
+}
+
+// FindByTag returns files tagged with tag or one of its descendants, so
+// project/apollo also finds files tagged project/apollo/2026. tag must be
+// normalized.
+func (idx *MetadataIndex) FindByTag(tag string) []FileMetadata {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	prefix := fileTagIndex + tag
+	results := make([]FileMetadata, 0)
+	_ = idx.store.db.View(func(txn *badger.Txn) error {
+		seen := make(map[string]bool)
+		hashes := make([]string, 0)
+		if err := scanPrefix(txn, prefix, false, func(key string, _ []byte) (bool, error) {
+			// Skip siblings that merely share a prefix, like project/apollonia.
+			if rest := key[len(prefix):]; rest[0] != 0 && rest[0] != '/' {
+				return true, nil
+			}
+			if hash := hashFromIndexKey(key); !seen[hash] {
+				seen[hash] = true
+				hashes = append(hashes, hash)
+			}
+			return true, nil
+		}); err != nil {
+			return err
+		}
+		found, err := idx.loadHashes(txn, hashes)
+		if err != nil {
+			return err
+		}
+		results = found
+		return nil
+	})
+	return results
+}
+
+// ForEachTag calls fn for every (tag, hash) pair in the tag index, ordered
+// by tag.
+func (idx *MetadataIndex) ForEachTag(fn func(tag, hash string)) error {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return idx.store.db.View(func(txn *badger.Txn) error {
+		return scanPrefix(txn, fileTagIndex, false, func(key string, _ []byte) (bool, error) {
+			tag, hash, _ := strings.Cut(key[len(fileTagIndex):], "\x00")
+			fn(tag, hash)
+			return true, nil
+		})
+	})
+}
+
+// FindUploadedBetween returns files uploaded in [from, to], oldest first.
+// A zero from or to leaves that side of the range open.
+func (idx *MetadataIndex) FindUploadedBetween(from, to time.Time) []FileMetadata {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	start := fileDateIndex
+	if !from.IsZero() {
+		start = fileDateIndex + dateIndexStamp(from)
+	}
+	// "\x01" sorts after the "\x00" separator, so the bound includes entries stamped exactly at to.
+	end := fileDateIndex + "\xff"
+	if !to.IsZero() {
+		end = fileDateIndex + dateIndexStamp(to) + "\x01"
+	}
+
+	results := make([]FileMetadata, 0)
+	_ = idx.store.db.View(func(txn *badger.Txn) error {
+		hashes := make([]string, 0)
+		scanRange(txn, start, end, func(key string) bool {
+			hashes = append(hashes, hashFromIndexKey(key))
+			return true
+		})
+		found, err := idx.loadHashes(txn, hashes)
+		if err != nil {
+			return err
+		}
+		results = found
+		return nil
+	})
+	return results
 }
 
 // FindByType returns all files matching the given collection type.
 // The type should match the first component of the Category path (e.g., "images", "videos").
 func (idx *MetadataIndex) FindByType(collectionType string) []FileMetadata {
-    idx.mu.RLock()
-    defer idx.mu.RUnlock()
-    
-    results := make([]FileMetadata, 0)
-    typeLower := strings.ToLower(collectionType)
-    
-    for _, meta := range idx.data {
-        // Category format is "type/subcategory/..." or just "type"
-        categoryParts := strings.Split(meta.Category, "/")
-        if len(categoryParts) > 0 && strings.ToLower(categoryParts[0]) == typeLower {
-            results = append(results, meta)
-        }
-    }
-    
-    return results
+	typeLower := strings.ToLower(collectionType)
+	results := idx.FindByCategory(typeLower)
+	return append(results, idx.findByIndexPrefix(fileCategoryIndex+typeLower+"/")...)
 }
 
 // FindByCategoryPrefix returns all files whose category starts with the given prefix.
 // This is useful for finding all files in a collection type (e.g., "images" matches "images/jpg", "images/png", etc.).
 func (idx *MetadataIndex) FindByCategoryPrefix(prefix string) []FileMetadata {
-    idx.mu.RLock()
-    defer idx.mu.RUnlock()
-    
-    results := make([]FileMetadata, 0)
-    for _, meta := range idx.data {
-        // Check if category starts with prefix (exact match or prefix with "/")
-        if meta.Category == prefix || 
-           (len(meta.Category) > len(prefix) && meta.Category[:len(prefix)+1] == prefix+"/") {
-            results = append(results, meta)
-        }
-    }
-    return results
+	// The category index is case-folded; re-check the exact prefix on the records.
+	candidates := idx.FindByType(prefix)
+	results := make([]FileMetadata, 0, len(candidates))
+	for _, meta := range candidates {
+		if meta.Category == prefix || strings.HasPrefix(meta.Category, prefix+"/") {
+			results = append(results, meta)
+		}
+	}
+	return results
 }
 
 // GetAllMetadata returns a copy of all metadata entries.
 func (idx *MetadataIndex) GetAllMetadata() []FileMetadata {
-    idx.mu.RLock()
-    defer idx.mu.RUnlock()
-    result := make([]FileMetadata, 0, len(idx.data))
-    for _, meta := range idx.data {
-        result = append(result, meta)
-    }
-    return result
+	result := make([]FileMetadata, 0)
+	_ = idx.ForEach(func(meta FileMetadata) bool {
+		result = append(result, meta)
+		return true
+	})
+	return result
 }
 
 // GetAll returns all file metadata entries (alias for GetAllMetadata for compatibility).
 func (idx *MetadataIndex) GetAll() []FileMetadata {
-    return idx.GetAllMetadata()
+	return idx.GetAllMetadata()
 }
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func newTestMetadataIndex(t *testing.T) (*MetadataIndex, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "metadata", "files.json")
+	idx, err := NewMetadataIndex(path)
+	if err != nil {
+		t.Fatalf("failed to create metadata index: %v", err)
+	}
+	t.Cleanup(func() { _ = idx.Close() })
+	return idx, path
+}
+
+func TestMetadataIndex_SecondaryIndexes(t *testing.T) {
+	idx, _ := newTestMetadataIndex(t)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []FileMetadata{
+		{Hash: "a1", OriginalName: "cat.jpg", StoredPath: "storage/images/jpg/a1_cat.jpg", Category: "images/jpg", MimeType: "image/jpeg", UploadedAt: base},
+		{Hash: "b2", OriginalName: "dog.png", StoredPath: "storage/images/png/b2_dog.png", Category: "images/png", MimeType: "image/png", UploadedAt: base.Add(24 * time.Hour)},
+		{Hash: "c3", OriginalName: "doc.pdf", StoredPath: "storage/documents/pdf/c3_doc.pdf", Category: "documents/pdf", MimeType: "application/pdf", UploadedAt: base.Add(48 * time.Hour)},
+	}
+	for _, meta := range files {
+		if err := idx.Add(meta); err != nil {
+			t.Fatalf("add %s: %v", meta.Hash, err)
+		}
+	}
+
+	if got := idx.Count(); got != 3 {
+		t.Fatalf("expected 3 entries, got %d", got)
+	}
+	if got := idx.FindByCategory("IMAGES/JPG"); len(got) != 1 || got[0].Hash != "a1" {
+		t.Errorf("FindByCategory: unexpected result %+v", got)
+	}
+	if got := idx.FindByType("images"); len(got) != 2 {
+		t.Errorf("FindByType(images): expected 2, got %d", len(got))
+	}
+	if got := idx.FindByMimeType("image/png"); len(got) != 1 || got[0].Hash != "b2" {
+		t.Errorf("FindByMimeType: unexpected result %+v", got)
+	}
+	if got := idx.FindByStoredPath("storage/documents/pdf/c3_doc.pdf"); got == nil || got.Hash != "c3" {
+		t.Errorf("FindByStoredPath: unexpected result %+v", got)
+	}
+
+	got := idx.FindUploadedBetween(base.Add(24*time.Hour), base.Add(48*time.Hour))
+	if len(got) != 2 || got[0].Hash != "b2" || got[1].Hash != "c3" {
+		t.Errorf("FindUploadedBetween: expected [b2 c3], got %+v", got)
+	}
+	if got := idx.FindUploadedBetween(time.Time{}, base); len(got) != 1 || got[0].Hash != "a1" {
+		t.Errorf("FindUploadedBetween open start: unexpected result %+v", got)
+	}
+}
+
+func TestMetadataIndex_AddReplacesSecondaryEntries(t *testing.T) {
+	idx, _ := newTestMetadataIndex(t)
+
+	meta := FileMetadata{Hash: "a1", OriginalName: "cat.jpg", StoredPath: "storage/images/jpg/a1_cat.jpg", Category: "images/jpg", MimeType: "image/jpeg", UploadedAt: time.Now().UTC()}
+	if err := idx.Add(meta); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	moved := meta
+	moved.Category = "archive"
+	moved.StoredPath = "storage/archive/a1_cat.jpg"
+	if err := idx.Add(moved); err != nil {
+		t.Fatalf("re-add: %v", err)
+	}
+
+	if got := idx.FindByCategory("images/jpg"); len(got) != 0 {
+		t.Errorf("stale category entry remained: %+v", got)
+	}
+	if got := idx.FindByStoredPath(meta.StoredPath); got != nil {
+		t.Errorf("stale stored path entry remained: %+v", got)
+	}
+	if got := idx.FindByCategory("archive"); len(got) != 1 {
+		t.Errorf("expected moved entry under archive, got %d", len(got))
+	}
+
+	if err := idx.Delete("a1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := idx.FindByMimeType("image/jpeg"); len(got) != 0 {
+		t.Errorf("delete left mime entry: %+v", got)
+	}
+	if err := idx.Delete("a1"); err == nil {
+		t.Error("expected error deleting missing entry")
+	}
+}
+
+func TestMetadataIndex_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata", "files.json")
+	idx, err := NewMetadataIndex(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := idx.Add(FileMetadata{Hash: "a1", StoredPath: "storage/other/a1_x", Category: "other", UploadedAt: time.Now().UTC()}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := idx.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	reopened, err := NewMetadataIndex(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.FindByHash("a1") == nil {
+		t.Error("entry not found after reopen")
+	}
+}
+
+func TestMetadataIndex_MigratesSingleHashPathEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata", "files.json")
+	idx, err := NewMetadataIndex(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	meta := FileMetadata{Hash: "a1", StoredPath: "storage/other/a1_x", Category: "other", UploadedAt: time.Now().UTC()}
+	if err := idx.Add(meta); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	// Rewrite the entry in the old fpath:<path> -> hash layout.
+	err = idx.store.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Delete([]byte(pathIndexKey(meta.StoredPath, meta.Hash))); err != nil {
+			return err
+		}
+		if err := txn.Delete([]byte(pathIndexMarker)); err != nil {
+			return err
+		}
+		return txn.Set([]byte(filePathIndex+meta.StoredPath), []byte(meta.Hash))
+	})
+	if err != nil {
+		t.Fatalf("write legacy entry: %v", err)
+	}
+	if err := idx.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	reopened, err := NewMetadataIndex(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if found := reopened.FindByStoredPath(meta.StoredPath); found == nil || found.Hash != meta.Hash {
+		t.Fatalf("FindByStoredPath after migration = %+v", found)
+	}
+}
+
+func writeLegacyJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	buf, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, buf, 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestLegacyJSONMigration(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "metadata")
+	uploaded := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	writeLegacyJSON(t, filepath.Join(dir, "files.json"), []FileMetadata{
+		{Hash: "a1", OriginalName: "cat.jpg", StoredPath: "storage/images/jpg/a1_cat.jpg", Category: "images/jpg", MimeType: "image/jpeg", UploadedAt: uploaded},
+	})
+	writeLegacyJSON(t, filepath.Join(dir, "versions.json"), []VersionChain{
+		{FileID: "a1", CurrentVersion: 1, Versions: []VersionMetadata{{Version: 1, Hash: "a1", IsCurrent: true}}},
+	})
+	writeLegacyJSON(t, filepath.Join(dir, "notes.json"), []Note{
+		{ID: "n1", FileID: "a1", Text: "first"},
+		{ID: "n2", FileID: "a1", Text: "second"},
+	})
+	writeLegacyJSON(t, filepath.Join(dir, "references.json"), []map[string]any{
+		{"physical_path": "/data/storage/images/jpg/a1_cat.jpg", "hashes": []string{"a1", "b2"}},
+	})
+
+	idx, err := NewMetadataIndex(filepath.Join(dir, "files.json"))
+	if err != nil {
+		t.Fatalf("metadata index: %v", err)
+	}
+	defer idx.Close()
+	versions, err := NewVersionIndex(filepath.Join(dir, "versions.json"))
+	if err != nil {
+		t.Fatalf("version index: %v", err)
+	}
+	defer versions.Close()
+	notes, err := NewNotesIndex(filepath.Join(dir, "notes.json"))
+	if err != nil {
+		t.Fatalf("notes index: %v", err)
+	}
+	defer notes.Close()
+	refs, err := NewReferenceIndex(filepath.Join(dir, "references.json"))
+	if err != nil {
+		t.Fatalf("reference index: %v", err)
+	}
+	defer refs.Close()
+
+	if got := idx.FindByMimeType("image/jpeg"); len(got) != 1 || !got[0].UploadedAt.Equal(uploaded) {
+		t.Errorf("migrated metadata not indexed: %+v", got)
+	}
+	if _, err := versions.GetVersion("a1", 1); err != nil {
+		t.Errorf("migrated version chain missing: %v", err)
+	}
+	if got := notes.GetNotes("a1"); len(got) != 2 || got[0].ID != "n1" || got[1].ID != "n2" {
+		t.Errorf("migrated notes unexpected: %+v", got)
+	}
+	if got := refs.GetReferenceCount("/data/storage/images/jpg/a1_cat.jpg"); got != 2 {
+		t.Errorf("expected 2 migrated references, got %d", got)
+	}
+
+	for _, name := range []string{"files.json", "versions.json", "notes.json", "references.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should have been renamed after migration", name)
+		}
+		if _, err := os.Stat(filepath.Join(dir, name+".migrated")); err != nil {
+			t.Errorf("%s.migrated backup missing: %v", name, err)
+		}
+	}
+}
+
+func TestLegacyJSONMigration_RunsOnce(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "metadata")
+	path := filepath.Join(dir, "files.json")
+	writeLegacyJSON(t, path, []FileMetadata{{Hash: "a1", StoredPath: "storage/other/a1_x", Category: "other"}})
+
+	idx, err := NewMetadataIndex(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := idx.Delete("a1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	// A stale JSON file reappearing (e.g. restored from an old backup) must not
+	// resurrect entries that were deleted after the migration.
+	writeLegacyJSON(t, path, []FileMetadata{{Hash: "a1", StoredPath: "storage/other/a1_x", Category: "other"}})
+	if err := idx.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	reopened, err := NewMetadataIndex(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.FindByHash("a1") != nil {
+		t.Error("migration ran twice and resurrected a deleted entry")
+	}
+}
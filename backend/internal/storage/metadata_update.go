@@ -152,11 +152,18 @@ func (idx *MetadataIndex) UpdateMetadata(hash string, action string, newMetadata
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
+	txn := idx.store.db.NewTransaction(true)
+	defer txn.Discard()
+
 	// Find existing metadata
-	existing, ok := idx.data[hash]
-	if !ok {
+	found, err := idx.getLocked(txn, hash)
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
 		return nil, fmt.Errorf("%w: hash=%s", ErrMetadataNotFound, hash)
 	}
+	existing := *found
 
 	// Create a copy of the metadata
 	var updatedMetadata map[string]string
@@ -199,10 +206,12 @@ func (idx *MetadataIndex) UpdateMetadata(hash string, action string, newMetadata
 
 	// Update the metadata
 	existing.Metadata = updatedMetadata
-	idx.data[hash] = existing
+	if err := setJSON(txn, fileKey(hash), existing); err != nil {
+		return nil, err
+	}
 
 	// Persist changes
-	if err := idx.persistLocked(); err != nil {
+	if err := txn.Commit(); err != nil {
 		return nil, err
 	}
 
@@ -218,6 +227,10 @@ func (idx *MetadataIndex) BatchUpdateMetadata(updates []MetadataUpdateRequest) (
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
+	// All successful updates commit together in one transaction
+	txn := idx.store.db.NewTransaction(true)
+	defer txn.Discard()
+
 	// Track if any updates succeeded
 	anySuccess := false
 
@@ -229,11 +242,16 @@ func (idx *MetadataIndex) BatchUpdateMetadata(updates []MetadataUpdateRequest) (
 		}
 
 		// Find existing metadata
-		existing, ok := idx.data[req.Hash]
-		if !ok {
+		found, err := idx.getLocked(txn, req.Hash)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if found == nil {
 			errs[i] = fmt.Errorf("%w: hash=%s", ErrMetadataNotFound, req.Hash)
 			continue
 		}
+		existing := *found
 
 		// Store old metadata for result
 		oldMetadata := make(map[string]string)
@@ -278,7 +296,10 @@ func (idx *MetadataIndex) BatchUpdateMetadata(updates []MetadataUpdateRequest) (
 
 		// Update the metadata
 		existing.Metadata = updatedMetadata
-		idx.data[req.Hash] = existing
+		if err := setJSON(txn, fileKey(req.Hash), existing); err != nil {
+			errs[i] = err
+			continue
+		}
 		anySuccess = true
 
 		// Build result
@@ -292,7 +313,7 @@ func (idx *MetadataIndex) BatchUpdateMetadata(updates []MetadataUpdateRequest) (
 
 	// Persist changes if any updates succeeded
 	if anySuccess {
-		if err := idx.persistLocked(); err != nil {
+		if err := txn.Commit(); err != nil {
 			// If persist fails, mark all as errors
 			for i := range errs {
 				if errs[i] == nil {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -133,32 +134,25 @@ func ValidateCategory(category string) error {
 	return nil
 }
 
-// ensureCategoryDirectory creates the category directory structure if it doesn't exist.
-func (m *Manager) ensureCategoryDirectory(category string) (string, error) {
+// categoryKey validates category and returns its backend key prefix.
+// Backends create any intermediate structure on write.
+func (m *Manager) categoryKey(category string) (string, error) {
 	if err := ValidateCategory(category); err != nil {
 		return "", err
 	}
 
 	// Split category into components
 	components := strings.Split(category, "/")
-	
-	// Build full directory path
-	fullDir := filepath.Join(append([]string{m.storageRoot}, components...)...)
-	
-	// Create directory structure
-	if err := os.MkdirAll(fullDir, 0o755); err != nil {
-		return "", fmt.Errorf("failed to create category directory: %w", err)
-	}
 
-	return fullDir, nil
+	return blobKey(append([]string{"storage"}, components...)...), nil
 }
 
 // checkFilenameConflict checks if a file with the same name exists in the target category.
 // REQUIRES: Caller must hold m.mu lock before calling this function.
 func (m *Manager) checkFilenameConflict(hash, targetCategory string, filename string) error {
-	for h, meta := range m.index.data {
+	for _, meta := range m.index.FindByCategory(targetCategory) {
 		// Skip the file being moved
-		if h == hash {
+		if meta.Hash == hash {
 			continue
 		}
 		// Check for same filename in target category
@@ -213,22 +207,22 @@ func (m *Manager) MoveFile(req MoveRequest) (*MoveResult, error) {
 		}, nil
 	}
 
-	// Get absolute path to current file
-	oldPath := filepath.Join(m.root, existing.StoredPath)
+	// Backend key of the current file
+	oldPath := existing.StoredPath
 
-	// Check if the file actually exists on disk
-	if _, err := os.Stat(oldPath); err != nil {
+	// Check if the file actually exists in the backend
+	if _, err := m.backend.Stat(oldPath); err != nil {
 		return nil, fmt.Errorf("stored file not found: %w", err)
 	}
 
-	// Ensure target category directory exists
-	targetDir, err := m.ensureCategoryDirectory(req.NewCategory)
+	// Resolve target category key
+	targetDir, err := m.categoryKey(req.NewCategory)
 	if err != nil {
 		return nil, err
 	}
 
 	// Extract filename from stored path
-	filename := filepath.Base(oldPath)
+	filename := path.Base(oldPath)
 
 	// Check for filename conflicts in target category
 	if err := m.checkFilenameConflict(req.Hash, req.NewCategory, filename); err != nil {
@@ -236,15 +230,14 @@ func (m *Manager) MoveFile(req MoveRequest) (*MoveResult, error) {
 	}
 
 	// Build new path
-	newPath := filepath.Join(targetDir, filename)
+	newPath := blobKey(targetDir, filename)
 
 	// If source and destination are the same, no move needed
 	if oldPath == newPath {
 		// Just update category in metadata
 		newMetadata := *existing
 		newMetadata.Category = req.NewCategory
-		m.index.data[req.Hash] = newMetadata
-		if err := m.index.persistLocked(); err != nil {
+		if err := m.index.Add(newMetadata); err != nil {
 			return nil, fmt.Errorf("failed to persist metadata: %w", err)
 		}
 
@@ -261,43 +254,33 @@ func (m *Manager) MoveFile(req MoveRequest) (*MoveResult, error) {
 	}
 
 	// Check if target file already exists (shouldn't happen due to hash-based naming, but check anyway)
-	if _, err := os.Stat(newPath); err == nil {
+	if _, err := m.backend.Stat(newPath); err == nil {
 		// File exists - this shouldn't happen with hash-based naming, but handle it
 		return nil, fmt.Errorf("%w: target file already exists", ErrCategoryConflict)
 	}
 
 	// Perform the actual file move
-	if err := os.Rename(oldPath, newPath); err != nil {
+	if err := m.backend.Move(oldPath, newPath); err != nil {
 		return nil, fmt.Errorf("failed to move file: %w", err)
 	}
 
-	// Calculate relative path for new location
-	rel, err := filepath.Rel(m.root, newPath)
-	if err != nil {
-		// Rollback: try to move file back
-		_ = os.Rename(newPath, oldPath)
-		return nil, fmt.Errorf("failed to compute relative path: %w", err)
-	}
-
 	// Update metadata
 	newMetadata := *existing
 	newMetadata.Category = req.NewCategory
-	newMetadata.StoredPath = filepath.ToSlash(rel)
+	newMetadata.StoredPath = newPath
 
 	// Track move operation start time for metrics
 	moveStart := time.Now()
 
-	// Update metadata in index
-	m.index.data[req.Hash] = newMetadata
-
-	// Persist metadata changes
-	if err := m.index.persistLocked(); err != nil {
+	// Update and persist metadata in index
+	if err := m.index.Add(newMetadata); err != nil {
 		// Rollback: try to move file back and restore metadata
-		_ = os.Rename(newPath, oldPath)
-		m.index.data[req.Hash] = *existing
-		_ = m.index.persistLocked()
+		_ = m.backend.Move(newPath, oldPath)
+		_ = m.index.Add(*existing)
 		return nil, fmt.Errorf("failed to persist metadata: %w", err)
 	}
+	m.moveThumbnailsLocked(existing.Hash, newPath)
+	m.moveExtractedTextLocked(existing.Hash, newPath)
 
 	// Log the move operation with metrics
 	duration := time.Since(moveStart)
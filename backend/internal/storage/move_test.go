@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -274,23 +275,12 @@ func TestMoveFile_AtomicRollback(t *testing.T) {
 		t.Fatalf("failed to add metadata: %v", err)
 	}
 
-	// Make the metadata file read-only to simulate persistence failure
-	// Note: This test may not work on all systems (e.g., macOS allows owner to write to read-only files)
-	manager.mu.Lock()
-	metadataPath := manager.index.path
-	manager.mu.Unlock()
-	
-	var originalPerms os.FileMode
-	if info, err := os.Stat(metadataPath); err == nil {
-		originalPerms = info.Mode().Perm()
-		if err := os.Chmod(metadataPath, 0o444); err != nil {
-			t.Fatalf("failed to make metadata file read-only: %v", err)
+	// Fail the index write for the moved record; the rollback write goes through.
+	manager.index.beforeAdd = func(meta FileMetadata) error {
+		if meta.Category != sourceCategory {
+			return errors.New("simulated index write failure")
 		}
-		defer func() {
-			_ = os.Chmod(metadataPath, originalPerms)
-		}()
-	} else {
-		t.Fatalf("metadata file does not exist: %v", err)
+		return nil
 	}
 
 	req := MoveRequest{
@@ -298,17 +288,10 @@ func TestMoveFile_AtomicRollback(t *testing.T) {
 		NewCategory: "documents/archived",
 	}
 
-	_, err = manager.MoveFile(req)
-	// On some systems (e.g., macOS), read-only files can still be written by the owner,
-	// so this test may not reliably simulate a persistence failure.
-	// If we get an error, that's the expected behavior (rollback worked).
-	// If we don't get an error, the system allowed the write despite read-only, which means
-	// we can't test rollback on this system - skip the rest of the test.
-	if err == nil {
-		// No error - system allowed write despite read-only (e.g., macOS)
-		// This means we can't reliably test rollback on this system
-		t.Skip("Cannot test rollback on this system - read-only files can still be written by owner")
+	if _, err := manager.MoveFile(req); err == nil {
+		t.Fatal("expected the move to fail when the index write fails")
 	}
+	manager.index.beforeAdd = nil
 
 	// Verify file was rolled back (should still be in source location)
 	if _, err := os.Stat(sourcePath); err != nil {
@@ -3,11 +3,10 @@ package storage
 import (
 	"encoding/json"
 	"errors"
-	"os"
-	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/dgraph-io/badger/v4"
 	"github.com/google/uuid"
 )
 
@@ -21,74 +20,67 @@ type Note struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// NotesIndex persists file notes to disk and enables querying by file ID.
+// noteKeyPrefix namespaces per-file note lists inside the shared index store.
+const noteKeyPrefix = "note:"
+
+// NotesIndex persists file notes in the embedded index store and enables querying by file ID.
 type NotesIndex struct {
-	path string
-	mu   sync.RWMutex
-	data map[string][]Note // fileID -> []Note
+	path  string
+	store *kvStore
+	mu    sync.RWMutex
 }
 
-// NewNotesIndex creates a new notes index and loads existing data from disk.
+// NewNotesIndex opens the notes index. A legacy notes.json at path is imported once.
 func NewNotesIndex(path string) (*NotesIndex, error) {
-	idx := &NotesIndex{
-		path: path,
-		data: make(map[string][]Note),
+	store, err := openKVStore(kvDirFor(path))
+	if err != nil {
+		return nil, err
 	}
-	if err := idx.load(); err != nil {
+	idx := &NotesIndex{path: path, store: store}
+	if err := migrateLegacyJSON(store, path, idx.importJSON); err != nil {
+		_ = store.release()
 		return nil, err
 	}
 	return idx, nil
 }
 
-// load reads notes from disk into memory.
-func (idx *NotesIndex) load() error {
-	dir := filepath.Dir(idx.path)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return err
-	}
-
-	raw, err := os.ReadFile(idx.path)
-	if errors.Is(err, os.ErrNotExist) {
-		return nil // File doesn't exist yet, start with empty index
-	}
-	if err != nil {
-		return err
-	}
-
-	if len(raw) == 0 {
-		return nil
-	}
-
+// importJSON loads the legacy notes.json array format.
+func (idx *NotesIndex) importJSON(raw []byte, w kvWriter) error {
 	var items []Note
 	if err := json.Unmarshal(raw, &items); err != nil {
 		return err
 	}
 
 	// Group notes by file ID
+	grouped := make(map[string][]Note)
 	for _, note := range items {
-		idx.data[note.FileID] = append(idx.data[note.FileID], note)
+		grouped[note.FileID] = append(grouped[note.FileID], note)
+	}
+	for fileID, notes := range grouped {
+		if err := setJSON(w, noteKeyPrefix+fileID, notes); err != nil {
+			return err
+		}
 	}
-
 	return nil
 }
 
-// persistLocked writes all notes to disk. Must be called with lock held.
-func (idx *NotesIndex) persistLocked() error {
-	// Flatten all notes into a single array
-	var allNotes []Note
-	for _, notes := range idx.data {
-		allNotes = append(allNotes, notes...)
-	}
+// Close releases the index store.
+func (idx *NotesIndex) Close() error {
+	return idx.store.release()
+}
 
-	tmp := idx.path + ".tmp"
-	buf, err := json.MarshalIndent(allNotes, "", "  ")
-	if err != nil {
-		return err
-	}
-	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
-		return err
+// getNotes loads the notes for fileID inside txn. exists is false when the file has none.
+func (idx *NotesIndex) getNotes(txn *badger.Txn, fileID string) (notes []Note, exists bool, err error) {
+	exists, err = getJSON(txn, noteKeyPrefix+fileID, &notes)
+	return notes, exists, err
+}
+
+// putNotes stores notes for fileID, dropping the key once the list is empty.
+func (idx *NotesIndex) putNotes(txn *badger.Txn, fileID string, notes []Note) error {
+	if len(notes) == 0 {
+		return txn.Delete([]byte(noteKeyPrefix + fileID))
 	}
-	return os.Rename(tmp, idx.path)
+	return setJSON(txn, noteKeyPrefix+fileID, notes)
 }
 
 // GetNotes returns all notes for a given file ID.
@@ -96,15 +88,16 @@ func (idx *NotesIndex) GetNotes(fileID string) []Note {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
-	notes, exists := idx.data[fileID]
-	if !exists {
+	var notes []Note
+	_ = idx.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		notes, _, err = idx.getNotes(txn, fileID)
+		return err
+	})
+	if notes == nil {
 		return []Note{}
 	}
-
-	// Return a copy to prevent external modification
-	result := make([]Note, len(notes))
-	copy(result, notes)
-	return result
+	return notes
 }
 
 // AddNote adds a new note for a file.
@@ -129,13 +122,14 @@ func (idx *NotesIndex) AddNote(fileID, text, author string) (*Note, error) {
 		UpdatedAt: now,
 	}
 
-	idx.data[fileID] = append(idx.data[fileID], note)
-	if err := idx.persistLocked(); err != nil {
-		// Rollback: remove the note we just added
-		notes := idx.data[fileID]
-		if len(notes) > 0 {
-			idx.data[fileID] = notes[:len(notes)-1]
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		notes, _, err := idx.getNotes(txn, fileID)
+		if err != nil {
+			return err
 		}
+		return idx.putNotes(txn, fileID, append(notes, note))
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -157,27 +151,32 @@ func (idx *NotesIndex) UpdateNote(fileID, noteID, text string) (*Note, error) {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	notes, exists := idx.data[fileID]
-	if !exists {
-		return nil, errors.New("file not found")
-	}
+	var result Note
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		notes, exists, err := idx.getNotes(txn, fileID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("file not found")
+		}
 
-	// Find the note
-	for i := range notes {
-		if notes[i].ID == noteID {
-			notes[i].Text = text
-			notes[i].UpdatedAt = time.Now().UTC()
-			if err := idx.persistLocked(); err != nil {
-				// Rollback: reload from disk
-				_ = idx.load()
-				return nil, err
+		// Find the note
+		for i := range notes {
+			if notes[i].ID == noteID {
+				notes[i].Text = text
+				notes[i].UpdatedAt = time.Now().UTC()
+				result = notes[i]
+				return idx.putNotes(txn, fileID, notes)
 			}
-			result := notes[i]
-			return &result, nil
 		}
+		return errors.New("note not found")
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, errors.New("note not found")
+	return &result, nil
 }
 
 // DeleteNote removes a note by ID.
@@ -192,26 +191,23 @@ func (idx *NotesIndex) DeleteNote(fileID, noteID string) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	notes, exists := idx.data[fileID]
-	if !exists {
-		return errors.New("file not found")
-	}
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		notes, exists, err := idx.getNotes(txn, fileID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("file not found")
+		}
 
-	// Find and remove the note
-	for i, note := range notes {
-		if note.ID == noteID {
-			// Remove note by creating new slice without it
-			idx.data[fileID] = append(notes[:i], notes[i+1:]...)
-			if err := idx.persistLocked(); err != nil {
-				// Rollback: reload from disk
-				_ = idx.load()
-				return err
+		// Find and remove the note
+		for i, note := range notes {
+			if note.ID == noteID {
+				return idx.putNotes(txn, fileID, append(notes[:i], notes[i+1:]...))
 			}
-			return nil
 		}
-	}
-
-	return errors.New("note not found")
+		return errors.New("note not found")
+	})
 }
 
 // DeleteAllNotes removes all notes for a file (useful when file is deleted).
@@ -223,11 +219,7 @@ func (idx *NotesIndex) DeleteAllNotes(fileID string) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	if _, exists := idx.data[fileID]; !exists {
-		return nil // No notes to delete, not an error
-	}
-
-	delete(idx.data, fileID)
-	return idx.persistLocked()
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(noteKeyPrefix + fileID))
+	})
 }
-
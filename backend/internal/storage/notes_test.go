@@ -332,15 +332,18 @@ func TestNotesIndexFileNotExists(t *testing.T) {
 		t.Error("notes file should not exist yet")
 	}
 
-	// Add a note (should create the file)
+	// Add a note
 	_, err = idx.AddNote("file1", "Test", "user1")
 	if err != nil {
 		t.Fatalf("failed to add note: %v", err)
 	}
 
-	// File should now exist
-	if _, err := os.Stat(notesPath); err != nil {
-		t.Errorf("notes file should exist after adding note: %v", err)
+	// Notes live in the shared index store, not a JSON file
+	if _, err := os.Stat(notesPath); err == nil {
+		t.Error("notes.json should not be written by the index store")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, kvDirName)); err != nil {
+		t.Errorf("index store should exist after adding note: %v", err)
 	}
 }
 
@@ -0,0 +1,340 @@
+package storage
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Muneer320/RhinoBox/internal/filequery"
+)
+
+// SearchQuery evaluates a parsed /files/search query, ANDed with filters.
+// Content terms are answered from the content index; when the query has
+// any, results are ranked by the summed BM25 score of the content terms a
+// file matched and the first snippetLimit scored results carry a snippet.
+// Otherwise results keep index order with a zero score.
+func (m *Manager) SearchQuery(expr filequery.Expr, filters SearchFilters, snippetLimit int) ([]ContentMatch, error) {
+	if filters.ContentSearch != "" {
+		expr = &filequery.And{Exprs: []filequery.Expr{expr, &filequery.Term{Field: "content", Op: filequery.OpMatch, Value: filters.ContentSearch}}}
+		filters.ContentSearch = ""
+	}
+
+	eval := queryEvaluator{content: make(map[string]map[string]float64)}
+	filequery.Walk(expr, func(t *filequery.Term) {
+		if t.Field == "content" {
+			eval.content[t.Value] = nil
+		}
+	})
+	if len(eval.content) > 0 {
+		if err := m.ensureContentIndex(); err != nil {
+			return nil, err
+		}
+		for value := range eval.content {
+			hits, err := m.contentIndex.Search(ParseContentQuery(value))
+			if err != nil {
+				return nil, err
+			}
+			scores := make(map[string]float64, len(hits))
+			for _, hit := range hits {
+				scores[hit.Hash] = hit.Score
+			}
+			eval.content[value] = scores
+		}
+	}
+
+	matches := make([]ContentMatch, 0)
+	m.mu.Lock()
+	_ = m.index.ForEach(func(meta FileMetadata) bool {
+		if matchesFilters(meta, filters) && eval.match(expr, meta) {
+			matches = append(matches, ContentMatch{Metadata: meta, Score: eval.score(meta.Hash)})
+		}
+		return true
+	})
+	m.mu.Unlock()
+
+	if len(eval.content) == 0 {
+		return matches, nil
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+
+	var combined ContentQuery
+	for value := range eval.content {
+		q := ParseContentQuery(value)
+		combined.Terms = append(combined.Terms, q.Terms...)
+		combined.Phrases = append(combined.Phrases, q.Phrases...)
+	}
+	for i := range matches {
+		if i >= snippetLimit || matches[i].Score == 0 {
+			break
+		}
+		if text, ok, err := m.readStoredText(matches[i].Metadata); err == nil && ok {
+			matches[i].Snippet = contentSnippet(text, combined)
+		}
+	}
+	return matches, nil
+}
+
+// ensureContentIndex builds the content index if it was never built.
+func (m *Manager) ensureContentIndex() error {
+	built, err := m.contentIndex.Built()
+	if err != nil || built {
+		return err
+	}
+	_, err = m.RebuildContentIndex()
+	return err
+}
+
+// queryEvaluator matches files against a query. content holds the hits of
+// every content term, keyed by the term's value.
+type queryEvaluator struct {
+	content map[string]map[string]float64
+}
+
+func (e queryEvaluator) match(expr filequery.Expr, meta FileMetadata) bool {
+	switch expr := expr.(type) {
+	case *filequery.And:
+		for _, sub := range expr.Exprs {
+			if !e.match(sub, meta) {
+				return false
+			}
+		}
+		return true
+	case *filequery.Or:
+		for _, sub := range expr.Exprs {
+			if e.match(sub, meta) {
+				return true
+			}
+		}
+		return false
+	case *filequery.Not:
+		return !e.match(expr.Expr, meta)
+	case *filequery.Term:
+		return e.matchTerm(expr, meta)
+	}
+	return false
+}
+
+// score sums the scores of the content terms hash matched.
+func (e queryEvaluator) score(hash string) float64 {
+	var total float64
+	for _, scores := range e.content {
+		total += scores[hash]
+	}
+	return total
+}
+
+func (e queryEvaluator) matchTerm(t *filequery.Term, meta FileMetadata) bool {
+	if strings.HasPrefix(t.Field, filequery.MetaPrefix) {
+		value, ok := lookupMetadata(meta.Metadata, strings.TrimPrefix(t.Field, filequery.MetaPrefix))
+		return ok && matchMetaValue(t, value)
+	}
+
+	info := meta.Extracted
+	switch t.Field {
+	case "name":
+		return matchText(t, meta.OriginalName)
+	case "ext":
+		ext := strings.TrimPrefix(filepath.Ext(meta.OriginalName), ".")
+		want := strings.TrimPrefix(t.Value, ".")
+		if t.Op == filequery.OpMatch && isGlob(want) {
+			return globMatchFold(want, ext)
+		}
+		return strings.EqualFold(ext, want)
+	case "category":
+		return matchText(t, meta.Category)
+	case "type":
+		if t.Op == filequery.OpEq {
+			return strings.EqualFold(meta.MimeType, t.Value) || strings.EqualFold(meta.Category, t.Value)
+		}
+		return matchesFilters(meta, SearchFilters{Type: t.Value})
+	case "mime":
+		return matchMime(t, meta.MimeType)
+	case "path":
+		return matchText(t, meta.StoredPath)
+	case "hash":
+		if t.Op == filequery.OpEq {
+			return strings.EqualFold(meta.Hash, t.Value)
+		}
+		return strings.HasPrefix(strings.ToLower(meta.Hash), strings.ToLower(t.Value))
+	case "content":
+		_, ok := e.content[t.Value][meta.Hash]
+		return ok
+	case "tag":
+		return matchTag(t, meta.Tags)
+	case "size":
+		return t.Range.Contains(float64(meta.Size))
+	case "uploaded":
+		return t.Range.ContainsTime(meta.UploadedAt)
+	case "has":
+		switch {
+		case t.Value == "extracted":
+			return info != nil
+		case t.Value == "gps":
+			return info != nil && info.GPS != nil
+		case t.Value == "tags":
+			return len(meta.Tags) > 0
+		default:
+			value, ok := lookupMetadata(meta.Metadata, strings.TrimPrefix(t.Value, filequery.MetaPrefix))
+			return ok && value != ""
+		}
+	}
+
+	// The remaining fields come from metadata extracted at ingest.
+	if info == nil {
+		return false
+	}
+	switch t.Field {
+	case "camera":
+		return matchText(t, strings.TrimSpace(info.CameraMake+" "+info.CameraModel))
+	case "artist":
+		return matchText(t, info.Artist)
+	case "album":
+		return matchText(t, info.Album)
+	case "codec":
+		return matchText(t, info.VideoCodec) || matchText(t, info.AudioCodec)
+	case "width":
+		return info.Width > 0 && t.Range.Contains(float64(info.Width))
+	case "height":
+		return info.Height > 0 && t.Range.Contains(float64(info.Height))
+	case "duration":
+		return info.DurationSeconds > 0 && t.Range.Contains(info.DurationSeconds)
+	case "taken":
+		return info.TakenAt != nil && t.Range.ContainsTime(*info.TakenAt)
+	}
+	return false
+}
+
+// matchText compares a text field: = is a case-insensitive exact match and
+// : a substring, or a glob when the value contains * or ?.
+func matchText(t *filequery.Term, value string) bool {
+	if value == "" && t.Value != "" {
+		return false
+	}
+	if t.Op == filequery.OpEq {
+		return strings.EqualFold(value, t.Value)
+	}
+	if isGlob(t.Value) {
+		return globMatchFold(t.Value, value)
+	}
+	return containsFold(value, t.Value)
+}
+
+// matchTag matches a tag and, with :, its descendants; : also allows globs
+// such as project/*/2026.
+func matchTag(t *filequery.Term, tags []string) bool {
+	if t.Op == filequery.OpMatch && isGlob(t.Value) {
+		for _, tag := range tags {
+			if globMatchFold(t.Value, tag) {
+				return true
+			}
+		}
+		return false
+	}
+	want, err := NormalizeTag(t.Value)
+	if err != nil {
+		return false
+	}
+	for _, tag := range tags {
+		if tag == want || t.Op == filequery.OpMatch && tagMatches(tag, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMime accepts a full MIME type, a wildcard such as image/* or, with
+// :, a bare major type such as image.
+func matchMime(t *filequery.Term, mimeType string) bool {
+	base, _, _ := strings.Cut(mimeType, ";")
+	base = strings.TrimSpace(base)
+	switch {
+	case t.Op == filequery.OpEq:
+		return strings.EqualFold(base, t.Value)
+	case isGlob(t.Value):
+		return globMatchFold(t.Value, base)
+	case !strings.Contains(t.Value, "/"):
+		major, _, _ := strings.Cut(base, "/")
+		return strings.EqualFold(major, t.Value)
+	}
+	return strings.EqualFold(base, t.Value)
+}
+
+// matchMetaValue compares a user metadata value. Equality is
+// case-insensitive and : allows globs; values that parse like the term's
+// number or date compare numerically, and other values compare as text.
+func matchMetaValue(t *filequery.Term, value string) bool {
+	if t.Range != nil {
+		if v, ok := filequery.ParseMetaValue(t.Range, value); ok && t.Range.Contains(v) {
+			return true
+		}
+	}
+	switch t.Op {
+	case filequery.OpMatch:
+		if isGlob(t.Value) {
+			return globMatchFold(t.Value, value)
+		}
+		return strings.EqualFold(value, t.Value)
+	case filequery.OpEq:
+		return strings.EqualFold(value, t.Value)
+	}
+	if t.Range != nil {
+		return false
+	}
+	cmp := strings.Compare(strings.ToLower(value), strings.ToLower(t.Value))
+	switch t.Op {
+	case filequery.OpGt:
+		return cmp > 0
+	case filequery.OpGte:
+		return cmp >= 0
+	case filequery.OpLt:
+		return cmp < 0
+	case filequery.OpLte:
+		return cmp <= 0
+	}
+	return false
+}
+
+// lookupMetadata finds key, falling back to a case-insensitive match.
+func lookupMetadata(metadata map[string]string, key string) (string, bool) {
+	if value, ok := metadata[key]; ok {
+		return value, true
+	}
+	for k, value := range metadata {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?")
+}
+
+// globMatchFold matches s against a pattern where * is any run of
+// characters and ? any single character, ignoring case.
+func globMatchFold(pattern, s string) bool {
+	p, str := []rune(strings.ToLower(pattern)), []rune(strings.ToLower(s))
+	pi, si := 0, 0
+	star, mark := -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == str[si]):
+			pi++
+			si++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, si
+			pi++
+		case star >= 0:
+			pi = star + 1
+			mark++
+			si = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Muneer320/RhinoBox/internal/filequery"
+)
+
+func queryNames(t *testing.T, m *Manager, query string) []string {
+	t.Helper()
+	expr, err := filequery.Parse(query)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", query, err)
+	}
+	matches, err := m.SearchQuery(expr, SearchFilters{}, 10)
+	if err != nil {
+		t.Fatalf("SearchQuery(%q): %v", query, err)
+	}
+	names := make([]string, len(matches))
+	for i, match := range matches {
+		names[i] = match.Metadata.OriginalName
+	}
+	return names
+}
+
+func TestSearchQuery(t *testing.T) {
+	m := newTestManager(t)
+	store := func(name, mimeType string, size int, metadata map[string]string) {
+		t.Helper()
+		body := strings.Repeat("x", size)
+		if _, err := m.StoreFile(StoreRequest{Reader: strings.NewReader(body), Filename: name, MimeType: mimeType, Size: int64(size), Metadata: metadata}); err != nil {
+			t.Fatalf("store %s: %v", name, err)
+		}
+	}
+	store("launch.png", "image/png", 3000, map[string]string{"project": "Apollo", "rev": "12"})
+	store("orbit.jpg", "image/jpeg", 200, map[string]string{"project": "apollo-2", "rev": "3"})
+	store("budget.pdf", "application/pdf", 50, map[string]string{"project": "gemini", "due": "2026-05-14"})
+	store("notes.txt", "text/plain", 10, nil)
+
+	cases := map[string][]string{
+		`category:images AND size>1KB AND (ext:png OR ext:jpg)`: {"launch.png"},
+		`meta.project:apollo`:                   {"launch.png"},
+		`meta.project:apollo*`:                  {"launch.png", "orbit.jpg"},
+		`meta.rev>5`:                            {"launch.png"},
+		`meta.rev:1..5`:                         {"orbit.jpg"},
+		`meta.due:2026-05 OR meta.due>2027`:     {"budget.pdf"},
+		`-has:meta.project`:                     {"notes.txt"},
+		`mime:image NOT ext:.PNG`:               {"orbit.jpg"},
+		`mime:application/* OR mime=text/plain`: {"budget.pdf", "notes.txt"},
+		`size:..100 name:*.txt`:                 {"notes.txt"},
+		`uploaded:2000..`:                       {"budget.pdf", "launch.png", "notes.txt", "orbit.jpg"},
+		`uploaded<2000`:                         {},
+		`width>10`:                              {},
+	}
+	for query, want := range cases {
+		got := queryNames(t, m, query)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestSearchQueryRanksContentTerms(t *testing.T) {
+	m := newTestManager(t)
+	storeContent(t, m, "deploy.md", "text/markdown", "rollback the deploy then rollback the database")
+	storeContent(t, m, "ops.md", "text/markdown", "rollback steps are written down in the operations handbook for the on-call team")
+	storeContent(t, m, "draft.md", "text/markdown", "rollback plan, draft")
+
+	if got := queryNames(t, m, `content:rollback -name:draft`); !reflect.DeepEqual(got, []string{"deploy.md", "ops.md"}) {
+		t.Fatalf("expected ranked content matches, got %v", got)
+	}
+
+	expr, _ := filequery.Parse(`ext:md`)
+	matches, err := m.SearchQuery(expr, SearchFilters{ContentSearch: `"rollback the database"`}, 10)
+	if err != nil {
+		t.Fatalf("SearchQuery: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Metadata.OriginalName != "deploy.md" || !strings.Contains(matches[0].Snippet, "<mark>database</mark>") {
+		t.Fatalf("expected the content filter to be ANDed with a snippet, got %+v", matches)
+	}
+}
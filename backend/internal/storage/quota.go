@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrQuotaExceeded is returned when storing a file would exceed the tenant's quota.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// Quota limits what a Manager may hold. Zero values mean unlimited.
+type Quota struct {
+	MaxBytes int64 `json:"max_bytes"`
+	MaxFiles int64 `json:"max_files"`
+}
+
+// Usage reports how much a Manager currently holds. Bytes is the logical size
+// recorded in metadata, so hard-linked copies count in full.
+type Usage struct {
+	Bytes int64 `json:"bytes"`
+	Files int64 `json:"files"`
+}
+
+// SetQuota replaces the quota enforced on new files. Existing files are never
+// removed when a quota is lowered; only further writes are rejected.
+func (m *Manager) SetQuota(q Quota) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quota = q
+}
+
+// Quota returns the quota currently enforced.
+func (m *Manager) Quota() Quota {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.quota
+}
+
+// Usage returns the current file count and stored bytes.
+func (m *Manager) Usage() (Usage, error) {
+	return m.index.Usage()
+}
+
+// checkQuotaLocked reports ErrQuotaExceeded if one more file of size bytes
+// would not fit. Callers must hold m.mu.
+func (m *Manager) checkQuotaLocked(size int64) error {
+	if m.quota.MaxBytes <= 0 && m.quota.MaxFiles <= 0 {
+		return nil
+	}
+	usage, err := m.index.Usage()
+	if err != nil {
+		return err
+	}
+	if m.quota.MaxFiles > 0 && usage.Files+1 > m.quota.MaxFiles {
+		return fmt.Errorf("%w: file limit of %d reached", ErrQuotaExceeded, m.quota.MaxFiles)
+	}
+	if m.quota.MaxBytes > 0 && usage.Bytes+size > m.quota.MaxBytes {
+		return fmt.Errorf("%w: %d of %d bytes used, %d more requested", ErrQuotaExceeded, usage.Bytes, m.quota.MaxBytes, size)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -143,15 +144,15 @@ func (m *Manager) RenameFile(req RenameRequest) (*RenameResult, error) {
 
 	// If updating the stored file, perform the rename
 	if req.UpdateStoredFile {
-		oldPath := filepath.Join(m.root, existing.StoredPath)
+		oldPath := existing.StoredPath
 
-		// Check if the file actually exists on disk
-		if _, err := os.Stat(oldPath); err != nil {
+		// Check if the file actually exists in the backend
+		if _, err := m.backend.Stat(oldPath); err != nil {
 			return nil, fmt.Errorf("stored file not found: %w", err)
 		}
 
 		// Extract the directory and generate new stored filename
-		dir := filepath.Dir(oldPath)
+		dir := path.Dir(oldPath)
 		ext := strings.ToLower(filepath.Ext(req.NewName))
 		base := strings.TrimSuffix(req.NewName, filepath.Ext(req.NewName))
 		if base == "" {
@@ -161,41 +162,36 @@ func (m *Manager) RenameFile(req RenameRequest) (*RenameResult, error) {
 
 		// Use hash prefix for uniqueness (same pattern as StoreFile)
 		newFilename := fmt.Sprintf("%s_%s%s", existing.Hash[:12], base, ext)
-		newPath := filepath.Join(dir, newFilename)
+		newPath := blobKey(dir, newFilename)
 
 		// Check for filename conflicts
 		if oldPath != newPath {
-			if _, err := os.Stat(newPath); err == nil {
+			if _, err := m.backend.Stat(newPath); err == nil {
 				return nil, fmt.Errorf("%w: file %s already exists", ErrNameConflict, newFilename)
 			}
-		}
 
-		// Perform the actual file rename
-		if err := os.Rename(oldPath, newPath); err != nil {
-			return nil, fmt.Errorf("failed to rename stored file: %w", err)
+			// Perform the actual file rename
+			if err := m.backend.Move(oldPath, newPath); err != nil {
+				return nil, fmt.Errorf("failed to rename stored file: %w", err)
+			}
 		}
 
 		// Update the stored path in metadata
-		rel, err := filepath.Rel(m.root, newPath)
-		if err != nil {
-			// Rollback the file rename
-			_ = os.Rename(newPath, oldPath)
-			return nil, fmt.Errorf("failed to compute relative path: %w", err)
-		}
-		newMetadata.StoredPath = filepath.ToSlash(rel)
+		newMetadata.StoredPath = newPath
 	}
 
 	// Update metadata in the index
-	m.index.data[req.Hash] = newMetadata
-	if err := m.index.persistLocked(); err != nil {
+	if err := m.index.Add(newMetadata); err != nil {
 		// If persistence fails and we renamed the file, try to rollback
-		if req.UpdateStoredFile {
-			oldPath := filepath.Join(m.root, oldMetadata.StoredPath)
-			newPath := filepath.Join(m.root, newMetadata.StoredPath)
-			_ = os.Rename(newPath, oldPath)
+		if req.UpdateStoredFile && oldMetadata.StoredPath != newMetadata.StoredPath {
+			_ = m.backend.Move(newMetadata.StoredPath, oldMetadata.StoredPath)
 		}
 		return nil, fmt.Errorf("failed to persist metadata: %w", err)
 	}
+	// The new extension may make the file indexable, or stop it being so.
+	if isIndexable(oldMetadata.OriginalName, oldMetadata.MimeType) != isIndexable(newMetadata.OriginalName, newMetadata.MimeType) {
+		m.reindexContentLocked(newMetadata)
+	}
 
 	// Log the rename operation
 	logEntry := RenameLog{
@@ -246,11 +242,12 @@ func (m *Manager) FindByOriginalName(name string) []FileMetadata {
 	searchLower := strings.ToLower(name)
 	results := make([]FileMetadata, 0)
 
-	for _, meta := range m.index.data {
+	_ = m.index.ForEach(func(meta FileMetadata) bool {
 		if strings.Contains(strings.ToLower(meta.OriginalName), searchLower) {
 			results = append(results, meta)
 		}
-	}
+		return true
+	})
 
 	return results
 }
@@ -267,9 +264,9 @@ func (m *Manager) CheckNameConflict(hash, newName, category string) bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for h, meta := range m.index.data {
+	for _, meta := range m.index.FindByCategory(category) {
 		// Skip the file being renamed
-		if h == hash {
+		if meta.Hash == hash {
 			continue
 		}
 		// Check for same name in same category
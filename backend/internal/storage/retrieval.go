@@ -19,7 +19,7 @@ var (
 // FileRetrievalResult contains file metadata and a reader for the file content.
 type FileRetrievalResult struct {
 	Metadata FileMetadata
-	Reader   *os.File
+	Reader   BlobReader
 	Size     int64
 }
 
@@ -53,14 +53,7 @@ func (m *Manager) GetFileByPath(storedPath string) (*FileRetrievalResult, error)
 
 	// Find metadata by stored path
 	m.mu.Lock()
-	var metadata *FileMetadata
-	for _, meta := range m.index.data {
-		if meta.StoredPath == storedPath {
-			metaCopy := meta
-			metadata = &metaCopy
-			break
-		}
-	}
+	metadata := m.index.FindByStoredPath(storedPath)
 	m.mu.Unlock()
 
 	if metadata == nil {
@@ -84,9 +77,8 @@ func (m *Manager) GetFileMetadata(hash string) (*FileMetadata, error) {
 		return nil, fmt.Errorf("%w: hash %s", ErrFileNotFound, hash)
 	}
 
-	// Verify file still exists on disk
-	fullPath := filepath.Join(m.root, metadata.StoredPath)
-	if _, err := os.Stat(fullPath); err != nil {
+	// Verify file still exists in the backend
+	if _, err := m.backend.Stat(metadata.StoredPath); err != nil {
 		return nil, fmt.Errorf("%w: file on disk not found", ErrFileNotFound)
 	}
 
@@ -97,49 +89,28 @@ func (m *Manager) GetFileMetadata(hash string) (*FileMetadata, error) {
 
 // getFileByMetadata opens the file and returns a retrieval result.
 func (m *Manager) getFileByMetadata(metadata FileMetadata) (*FileRetrievalResult, error) {
-	fullPath := filepath.Join(m.root, metadata.StoredPath)
-
-	// Security: ensure the resolved path is within the root directory
-	absRoot, err := filepath.Abs(m.root)
-	if err != nil {
-		return nil, fmt.Errorf("failed to resolve root path: %w", err)
-	}
-
-	absPath, err := filepath.Abs(fullPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to resolve file path: %w", err)
-	}
-
-	// Check if the resolved path is within the root directory
-	relPath, err := filepath.Rel(absRoot, absPath)
-	if err != nil {
-		return nil, fmt.Errorf("%w: path outside root directory", ErrInvalidPath)
-	}
-
-	// Prevent path traversal (should not contain "..")
-	if strings.Contains(relPath, "..") {
-		return nil, fmt.Errorf("%w: path traversal detected", ErrInvalidPath)
-	}
-
-	// Open the file
-	file, err := os.Open(absPath)
+	// Security: the backend rejects keys that escape the root directory
+	reader, err := m.backend.Open(metadata.StoredPath)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, ErrBlobNotFound) {
 			return nil, fmt.Errorf("%w: file not found on disk", ErrFileNotFound)
 		}
+		if errors.Is(err, ErrInvalidPath) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 
 	// Get file info for size
-	info, err := file.Stat()
+	info, err := reader.Stat()
 	if err != nil {
-		file.Close()
+		reader.Close()
 		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
 
 	return &FileRetrievalResult{
 		Metadata: metadata,
-		Reader:   file,
+		Reader:   reader,
 		Size:     info.Size(),
 	}, nil
 }
@@ -180,6 +151,8 @@ type DownloadLog struct {
 	RangeEnd     *int64    `json:"range_end,omitempty"`
 	UserAgent    string    `json:"user_agent,omitempty"`
 	IPAddress    string    `json:"ip_address,omitempty"`
+	// ShareID is set for downloads through a share link.
+	ShareID string `json:"share_id,omitempty"`
 }
 
 // LogDownload appends a download event to the audit log.
@@ -0,0 +1,515 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3EmptyPayload    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Config describes an S3-compatible endpoint such as AWS S3 or MinIO.
+type S3Config struct {
+	Endpoint  string // e.g. http://localhost:9000
+	Region    string // defaults to us-east-1
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Prefix    string // optional key prefix inside the bucket
+	PathStyle bool   // MinIO and most self-hosted gateways need path-style URLs
+	Client    *http.Client
+}
+
+// S3Backend stores blobs in an S3-compatible bucket using plain HTTP requests
+// signed with AWS Signature Version 4.
+type S3Backend struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Backend validates cfg and returns a ready backend. It does not contact
+// the endpoint; the first request surfaces connectivity or credential errors.
+func NewS3Backend(cfg S3Config) (*S3Backend, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("s3 backend: endpoint is required")
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 backend: bucket is required")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("s3 backend: access key and secret key are required")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3 backend: invalid endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &S3Backend{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   client,
+		now:      time.Now,
+	}, nil
+}
+
+// Name implements Backend.
+func (b *S3Backend) Name() string {
+	return "s3"
+}
+
+// objectKey applies the configured prefix to a backend key.
+func (b *S3Backend) objectKey(key string) (string, error) {
+	if err := validatePath(key); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPath, err)
+	}
+	key = strings.TrimPrefix(key, "/")
+	if b.cfg.Prefix != "" {
+		key = path.Join(b.cfg.Prefix, key)
+	}
+	return key, nil
+}
+
+// objectURL builds the request URL for an object key.
+func (b *S3Backend) objectURL(objectKey string) *url.URL {
+	u := *b.endpoint
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if b.cfg.PathStyle {
+		u.Path = basePath + "/" + b.cfg.Bucket + "/" + objectKey
+	} else {
+		u.Host = b.cfg.Bucket + "." + u.Host
+		u.Path = basePath + "/" + objectKey
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	return &u
+}
+
+// Put implements Backend. Streams of unknown size are spooled to a temporary
+// file first because a plain PUT needs a Content-Length.
+func (b *S3Backend) Put(key string, r io.Reader, size int64) error {
+	if size < 0 {
+		tmp, err := os.CreateTemp("", "rhinobox-s3-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		n, err := io.Copy(tmp, r)
+		if err != nil {
+			return err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r, size = tmp, n
+	}
+
+	objectKey, err := b.objectKey(key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, b.objectURL(objectKey).String(), io.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	resp, err := b.do(req, s3UnsignedPayload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// PutFile implements Backend.
+func (b *S3Backend) PutFile(key, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = b.Put(key, file, info.Size())
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(localPath)
+}
+
+// Open implements Backend. The returned reader issues ranged GETs lazily so
+// seeking (HTTP range requests, resumable downloads) does not fetch the whole object.
+func (b *S3Backend) Open(key string) (BlobReader, error) {
+	info, err := b.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Object{backend: b, info: info}, nil
+}
+
+// Stat implements Backend.
+func (b *S3Backend) Stat(key string) (BlobInfo, error) {
+	objectKey, err := b.objectKey(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	req, err := http.NewRequest(http.MethodHead, b.objectURL(objectKey).String(), nil)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	resp, err := b.do(req, s3EmptyPayload)
+	if err != nil {
+		return BlobInfo{}, b.annotate(key, err)
+	}
+	resp.Body.Close()
+
+	info := BlobInfo{Key: key, Size: resp.ContentLength}
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		if t, err := http.ParseTime(lm); err == nil {
+			info.ModTime = t
+		}
+	}
+	return info, nil
+}
+
+// Delete implements Backend. S3 answers 204 for missing keys, so a HEAD is
+// issued first to keep ErrBlobNotFound semantics identical to LocalBackend.
+func (b *S3Backend) Delete(key string) error {
+	if _, err := b.Stat(key); err != nil {
+		return err
+	}
+	objectKey, err := b.objectKey(key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodDelete, b.objectURL(objectKey).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.do(req, s3EmptyPayload)
+	if err != nil {
+		return b.annotate(key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Copy implements Backend with a server-side CopyObject.
+func (b *S3Backend) Copy(srcKey, dstKey string) error {
+	srcObject, err := b.objectKey(srcKey)
+	if err != nil {
+		return err
+	}
+	dstObject, err := b.objectKey(dstKey)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, b.objectURL(dstObject).String(), http.NoBody)
+	if err != nil {
+		return err
+	}
+	source := "/" + b.cfg.Bucket + "/" + srcObject
+	req.Header.Set("x-amz-copy-source", s3EscapePath(source))
+	resp, err := b.do(req, s3EmptyPayload)
+	if err != nil {
+		return b.annotate(srcKey, err)
+	}
+	defer resp.Body.Close()
+
+	// CopyObject may report failure inside a 200 response.
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(body, []byte("<Error>")) {
+		return b.annotate(srcKey, parseS3Error(resp.StatusCode, body))
+	}
+	return nil
+}
+
+// Move implements Backend. S3 has no rename, so this is copy + delete.
+func (b *S3Backend) Move(srcKey, dstKey string) error {
+	if err := b.Copy(srcKey, dstKey); err != nil {
+		return err
+	}
+	if err := b.Delete(srcKey); err != nil {
+		return fmt.Errorf("move: delete source after copy: %w", err)
+	}
+	return nil
+}
+
+// annotate converts a remote 404 into ErrBlobNotFound for the given key.
+func (b *S3Backend) annotate(key string, err error) error {
+	var s3err *S3Error
+	if errors.As(err, &s3err) && (s3err.StatusCode == http.StatusNotFound || s3err.Code == "NoSuchKey") {
+		return fmt.Errorf("%w: %s", ErrBlobNotFound, key)
+	}
+	return err
+}
+
+// getRange fetches object bytes starting at offset.
+func (b *S3Backend) getRange(key string, offset int64) (io.ReadCloser, error) {
+	objectKey, err := b.objectKey(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, b.objectURL(objectKey).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := b.do(req, s3EmptyPayload)
+	if err != nil {
+		return nil, b.annotate(key, err)
+	}
+	return resp.Body, nil
+}
+
+// do signs and sends req, turning non-2xx responses into *S3Error.
+func (b *S3Backend) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	signS3Request(req, b.cfg.AccessKey, b.cfg.SecretKey, b.cfg.Region, payloadHash, b.now().UTC())
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s %s: %w", req.Method, req.URL.Path, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return nil, parseS3Error(resp.StatusCode, body)
+}
+
+// S3Error is a non-successful response from the object store.
+type S3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func parseS3Error(status int, body []byte) error {
+	s3err := &S3Error{}
+	_ = xml.Unmarshal(body, s3err)
+	s3err.StatusCode = status
+	return s3err
+}
+
+// signS3Request adds AWS Signature Version 4 headers to req. The host header,
+// Range and every x-amz-* header are signed.
+func signS3Request(req *http.Request, accessKey, secretKey, region, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name)
+		canonicalHeaders.WriteByte(':')
+		canonicalHeaders.WriteString(headers[name])
+		canonicalHeaders.WriteByte('\n')
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath URI-encodes every byte except unreserved characters and '/'.
+func s3EscapePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	var sb strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", c)
+	}
+	return sb.String()
+}
+
+func s3CanonicalQuery(values url.Values) string {
+	if len(values) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		vs := append([]string(nil), values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, strings.ReplaceAll(url.QueryEscape(k), "+", "%20")+"="+strings.ReplaceAll(url.QueryEscape(v), "+", "%20"))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Object is a lazily-fetched, seekable view over a remote object.
+type s3Object struct {
+	backend *S3Backend
+	info    BlobInfo
+	offset  int64
+	body    io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.info.Size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		body, err := o.backend.getRange(o.info.Key, o.offset)
+		if err != nil {
+			return 0, err
+		}
+		o.body = body
+	}
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = o.offset + offset
+	case io.SeekEnd:
+		next = o.info.Size + offset
+	default:
+		return 0, errors.New("s3 object: invalid whence")
+	}
+	if next < 0 {
+		return 0, errors.New("s3 object: negative position")
+	}
+	if next != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = next
+	return next, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
+
+func (o *s3Object) Stat() (fs.FileInfo, error) {
+	return blobFileInfo{info: o.info}, nil
+}
+
+// blobFileInfo adapts BlobInfo to fs.FileInfo.
+type blobFileInfo struct {
+	info BlobInfo
+}
+
+func (fi blobFileInfo) Name() string       { return path.Base(fi.info.Key) }
+func (fi blobFileInfo) Size() int64        { return fi.info.Size }
+func (fi blobFileInfo) Mode() fs.FileMode  { return 0o444 }
+func (fi blobFileInfo) ModTime() time.Time { return fi.info.ModTime }
+func (fi blobFileInfo) IsDir() bool        { return false }
+func (fi blobFileInfo) Sys() any           { return nil }
@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrSavedSearchNotFound is returned when no saved search has the requested ID.
+	ErrSavedSearchNotFound = errors.New("saved search not found")
+	// ErrSavedSearchExists is returned when another saved search has the name.
+	ErrSavedSearchExists = errors.New("saved search already exists")
+	// ErrInvalidSavedSearch is returned for saved searches without a name or
+	// without parameters.
+	ErrInvalidSavedSearch = errors.New("invalid saved search")
+)
+
+const (
+	// savedSearchKeyPrefix holds one SavedSearch record per saved search.
+	savedSearchKeyPrefix = "ssearch:"
+	// savedSearchViewPrefix records when a viewer last looked at a saved
+	// search: ssview:<id>:<viewer>.
+	savedSearchViewPrefix = "ssview:"
+
+	// maxSavedSearchNameLength bounds a saved search name in bytes.
+	maxSavedSearchNameLength = 255
+	// maxSavedSearchParams bounds the parameters of a saved search.
+	maxSavedSearchParams = 32
+)
+
+// SavedSearch is a named set of /files/search parameters, such as extension,
+// tag or q, evaluated live whenever it is listed. SortBy and Order are the
+// ListOptions sort applied to its results.
+type SavedSearch struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Params      map[string]string `json:"params"`
+	SortBy      string            `json:"sort_by,omitempty"`
+	Order       string            `json:"order,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// UpdateSavedSearchRequest changes a saved search. Nil fields are left
+// unchanged; a non-nil Params replaces all parameters.
+type UpdateSavedSearchRequest struct {
+	Name        *string           `json:"name"`
+	Description *string           `json:"description"`
+	Params      map[string]string `json:"params"`
+	SortBy      *string           `json:"sort_by"`
+	Order       *string           `json:"order"`
+}
+
+func savedSearchViewKey(id, viewer string) string {
+	return savedSearchViewPrefix + id + ":" + viewer
+}
+
+// SavedSearchIndex persists saved searches in the embedded index store.
+type SavedSearchIndex struct {
+	store *kvStore
+	mu    sync.Mutex
+}
+
+// NewSavedSearchIndex opens the saved search index in the store beside path.
+func NewSavedSearchIndex(path string) (*SavedSearchIndex, error) {
+	store, err := openKVStore(kvDirFor(path))
+	if err != nil {
+		return nil, err
+	}
+	return &SavedSearchIndex{store: store}, nil
+}
+
+// Close releases the index store.
+func (idx *SavedSearchIndex) Close() error {
+	return idx.store.release()
+}
+
+func allSavedSearches(txn *badger.Txn) ([]SavedSearch, error) {
+	searches := make([]SavedSearch, 0)
+	err := scanPrefix(txn, savedSearchKeyPrefix, true, func(_ string, val []byte) (bool, error) {
+		var saved SavedSearch
+		if err := json.Unmarshal(val, &saved); err != nil {
+			return false, err
+		}
+		searches = append(searches, saved)
+		return true, nil
+	})
+	return searches, err
+}
+
+// checkSavedSearch normalizes saved and rejects it when it is incomplete or
+// its name is taken by another saved search.
+func checkSavedSearch(txn *badger.Txn, saved *SavedSearch) error {
+	saved.Name = strings.TrimSpace(saved.Name)
+	saved.Description = strings.TrimSpace(saved.Description)
+	if saved.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSavedSearch)
+	}
+	if len(saved.Name) > maxSavedSearchNameLength {
+		return fmt.Errorf("%w: name is longer than %d bytes", ErrInvalidSavedSearch, maxSavedSearchNameLength)
+	}
+	params := make(map[string]string, len(saved.Params))
+	for key, value := range saved.Params {
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key != "" && value != "" {
+			params[key] = value
+		}
+	}
+	if len(params) == 0 {
+		return fmt.Errorf("%w: at least one search parameter is required", ErrInvalidSavedSearch)
+	}
+	if len(params) > maxSavedSearchParams {
+		return fmt.Errorf("%w: at most %d search parameters", ErrInvalidSavedSearch, maxSavedSearchParams)
+	}
+	saved.Params = params
+
+	existing, err := allSavedSearches(txn)
+	if err != nil {
+		return err
+	}
+	for _, other := range existing {
+		if other.ID != saved.ID && strings.EqualFold(other.Name, saved.Name) {
+			return fmt.Errorf("%w: %q", ErrSavedSearchExists, saved.Name)
+		}
+	}
+	return nil
+}
+
+// CreateSavedSearch stores a new saved search. The parameters are not
+// interpreted here; callers validate them before saving.
+func (m *Manager) CreateSavedSearch(saved SavedSearch) (*SavedSearch, error) {
+	idx := m.savedSearchIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	now := time.Now().UTC()
+	saved.ID = uuid.New().String()
+	saved.CreatedAt = now
+	saved.UpdatedAt = now
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		if err := checkSavedSearch(txn, &saved); err != nil {
+			return err
+		}
+		return setJSON(txn, savedSearchKeyPrefix+saved.ID, saved)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// GetSavedSearch returns the saved search with id.
+func (m *Manager) GetSavedSearch(id string) (*SavedSearch, error) {
+	var saved SavedSearch
+	err := m.savedSearchIndex.store.db.View(func(txn *badger.Txn) error {
+		found, err := getJSON(txn, savedSearchKeyPrefix+id, &saved)
+		if err == nil && !found {
+			err = fmt.Errorf("%w: %s", ErrSavedSearchNotFound, id)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// ListSavedSearches returns every saved search sorted by name.
+func (m *Manager) ListSavedSearches() ([]SavedSearch, error) {
+	var searches []SavedSearch
+	err := m.savedSearchIndex.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		searches, err = allSavedSearches(txn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(searches, func(i, j int) bool {
+		return strings.ToLower(searches[i].Name) < strings.ToLower(searches[j].Name)
+	})
+	return searches, nil
+}
+
+// UpdateSavedSearch applies req to the saved search with id.
+func (m *Manager) UpdateSavedSearch(id string, req UpdateSavedSearchRequest) (*SavedSearch, error) {
+	idx := m.savedSearchIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var saved SavedSearch
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		found, err := getJSON(txn, savedSearchKeyPrefix+id, &saved)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("%w: %s", ErrSavedSearchNotFound, id)
+		}
+		if req.Name != nil {
+			saved.Name = *req.Name
+		}
+		if req.Description != nil {
+			saved.Description = *req.Description
+		}
+		if req.Params != nil {
+			saved.Params = req.Params
+		}
+		if req.SortBy != nil {
+			saved.SortBy = *req.SortBy
+		}
+		if req.Order != nil {
+			saved.Order = *req.Order
+		}
+		if err := checkSavedSearch(txn, &saved); err != nil {
+			return err
+		}
+		saved.UpdatedAt = time.Now().UTC()
+		return setJSON(txn, savedSearchKeyPrefix+id, saved)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// DeleteSavedSearch removes a saved search and its view history.
+func (m *Manager) DeleteSavedSearch(id string) error {
+	idx := m.savedSearchIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(savedSearchKeyPrefix + id)); errors.Is(err, badger.ErrKeyNotFound) {
+			return fmt.Errorf("%w: %s", ErrSavedSearchNotFound, id)
+		} else if err != nil {
+			return err
+		}
+		var views []string
+		if err := scanPrefix(txn, savedSearchViewPrefix+id+":", false, func(key string, _ []byte) (bool, error) {
+			views = append(views, key)
+			return true, nil
+		}); err != nil {
+			return err
+		}
+		for _, key := range views {
+			if err := txn.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return txn.Delete([]byte(savedSearchKeyPrefix + id))
+	})
+}
+
+// SavedSearchViewedAt returns when viewer last viewed the saved search with
+// id, or the zero time if they never have. viewer may be empty for
+// unauthenticated access.
+func (m *Manager) SavedSearchViewedAt(id, viewer string) (time.Time, error) {
+	var viewedAt time.Time
+	err := m.savedSearchIndex.store.db.View(func(txn *badger.Txn) error {
+		_, err := getJSON(txn, savedSearchViewKey(id, viewer), &viewedAt)
+		return err
+	})
+	return viewedAt, err
+}
+
+// MarkSavedSearchViewed records that viewer viewed the saved search with id
+// at the given time and returns the previous view time.
+func (m *Manager) MarkSavedSearchViewed(id, viewer string, at time.Time) (time.Time, error) {
+	idx := m.savedSearchIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var previous time.Time
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(savedSearchKeyPrefix + id)); errors.Is(err, badger.ErrKeyNotFound) {
+			return fmt.Errorf("%w: %s", ErrSavedSearchNotFound, id)
+		} else if err != nil {
+			return err
+		}
+		if _, err := getJSON(txn, savedSearchViewKey(id, viewer), &previous); err != nil {
+			return err
+		}
+		return setJSON(txn, savedSearchViewKey(id, viewer), at.UTC())
+	})
+	return previous, err
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSavedSearchCRUD(t *testing.T) {
+	m := newTestManager(t)
+	saved, err := m.CreateSavedSearch(SavedSearch{Name: " Recent PDFs ", Params: map[string]string{"extension": "pdf", "blank": " "}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if saved.Name != "Recent PDFs" || len(saved.Params) != 1 {
+		t.Fatalf("expected a normalized saved search, got %+v", saved)
+	}
+	if _, err := m.CreateSavedSearch(SavedSearch{Name: "recent pdfs", Params: map[string]string{"type": "image"}}); !errors.Is(err, ErrSavedSearchExists) {
+		t.Fatalf("expected ErrSavedSearchExists, got %v", err)
+	}
+	if _, err := m.CreateSavedSearch(SavedSearch{Name: "Empty"}); !errors.Is(err, ErrInvalidSavedSearch) {
+		t.Fatalf("expected ErrInvalidSavedSearch, got %v", err)
+	}
+	if _, err := m.CreateSavedSearch(SavedSearch{Name: "Images", Params: map[string]string{"type": "image"}}); err != nil {
+		t.Fatalf("create second: %v", err)
+	}
+
+	name := "PDFs"
+	updated, err := m.UpdateSavedSearch(saved.ID, UpdateSavedSearchRequest{Name: &name, Params: map[string]string{"q": "ext:pdf"}})
+	if err != nil || updated.Name != "PDFs" || updated.Params["q"] != "ext:pdf" || !updated.UpdatedAt.After(saved.UpdatedAt) {
+		t.Fatalf("update: %+v %v", updated, err)
+	}
+	all, err := m.ListSavedSearches()
+	if err != nil || len(all) != 2 || all[0].Name != "Images" || all[1].Name != "PDFs" {
+		t.Fatalf("list = %+v %v", all, err)
+	}
+
+	if err := m.DeleteSavedSearch(saved.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := m.GetSavedSearch(saved.ID); !errors.Is(err, ErrSavedSearchNotFound) {
+		t.Fatalf("expected ErrSavedSearchNotFound, got %v", err)
+	}
+	if err := m.DeleteSavedSearch(saved.ID); !errors.Is(err, ErrSavedSearchNotFound) {
+		t.Fatalf("expected ErrSavedSearchNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestSavedSearchViews(t *testing.T) {
+	m := newTestManager(t)
+	saved, err := m.CreateSavedSearch(SavedSearch{Name: "Text", Params: map[string]string{"extension": "txt"}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if viewed, err := m.SavedSearchViewedAt(saved.ID, "alice"); err != nil || !viewed.IsZero() {
+		t.Fatalf("expected no view yet, got %v %v", viewed, err)
+	}
+
+	first := time.Now().Add(-time.Hour)
+	if previous, err := m.MarkSavedSearchViewed(saved.ID, "alice", first); err != nil || !previous.IsZero() {
+		t.Fatalf("first view: %v %v", previous, err)
+	}
+	previous, err := m.MarkSavedSearchViewed(saved.ID, "alice", time.Now())
+	if err != nil || !previous.Equal(first) {
+		t.Fatalf("expected the previous view time %v, got %v %v", first, previous, err)
+	}
+	if viewed, _ := m.SavedSearchViewedAt(saved.ID, "bob"); !viewed.IsZero() {
+		t.Fatalf("expected views to be per viewer, got %v", viewed)
+	}
+	if _, err := m.MarkSavedSearchViewed("missing", "alice", time.Now()); !errors.Is(err, ErrSavedSearchNotFound) {
+		t.Fatalf("expected ErrSavedSearchNotFound, got %v", err)
+	}
+
+	if err := m.DeleteSavedSearch(saved.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if viewed, _ := m.SavedSearchViewedAt(saved.ID, "alice"); !viewed.IsZero() {
+		t.Fatalf("expected views to be deleted with the saved search, got %v", viewed)
+	}
+}
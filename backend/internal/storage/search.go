@@ -1,8 +1,6 @@
 package storage
 
 import (
-	"bufio"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -17,7 +15,22 @@ type SearchFilters struct {
 	DateTo        time.Time // Files uploaded on or before this date
 	Category      string    // Match on category path (supports partial match)
 	MimeType      string    // Exact match on MIME type
-	ContentSearch string    // Search inside text files (case-insensitive, only for text MIME types)
+	ContentSearch string    // Words and "quoted phrases" that must all occur in a text file; answered from the content index
+	Tags          []string  // Normalized tags that must all be present; a tag also matches its descendants
+
+	// Filters on metadata extracted at ingest; files without an extracted
+	// block never match when any of these is set.
+	Camera      string        // Partial match on camera make or model
+	TakenFrom   time.Time     // Photos taken on or after this time
+	TakenTo     time.Time     // Photos taken on or before this time
+	HasGPS      bool          // Only photos with a GPS position
+	MinWidth    int           // Minimum pixel width of images and video
+	MinHeight   int           // Minimum pixel height of images and video
+	MinDuration time.Duration // Minimum audio/video duration
+	MaxDuration time.Duration // Maximum audio/video duration
+	Artist      string        // Partial match on the artist tag
+	Album       string        // Partial match on the album tag
+	Codec       string        // Partial match on the video or audio codec
 }
 
 // SearchFiles performs a filtered search across all file metadata.
@@ -29,11 +42,12 @@ func (m *Manager) SearchFiles(filters SearchFilters) []FileMetadata {
 
 	results := make([]FileMetadata, 0)
 
-	for _, meta := range m.index.data {
+	_ = m.index.ForEach(func(meta FileMetadata) bool {
 		if matchesFilters(meta, filters) {
 			results = append(results, meta)
 		}
-	}
+		return true
+	})
 
 	return results
 }
@@ -105,6 +119,11 @@ func matchesFilters(meta FileMetadata, filters SearchFilters) bool {
 		}
 	}
 
+	// Filter by tags (hierarchical)
+	if !hasAllTags(meta.Tags, filters.Tags) {
+		return false
+	}
+
 	// Filter by date range
 	if !filters.DateFrom.IsZero() {
 		if meta.UploadedAt.Before(filters.DateFrom) {
@@ -118,7 +137,7 @@ func matchesFilters(meta FileMetadata, filters SearchFilters) bool {
 		}
 	}
 
-	return true
+	return matchesExtracted(meta.Extracted, filters)
 }
 
 // isTextMimeType checks if the MIME type is searchable text.
@@ -141,64 +160,3 @@ func isTextMimeType(mimeType string) bool {
 	}
 	return false
 }
-
-// SearchFilesWithContent performs search including content search for text files.
-func (m *Manager) SearchFilesWithContent(filters SearchFilters) []FileMetadata {
-	// First get results matching metadata filters
-	metadataResults := m.SearchFiles(filters)
-	
-	// If no content search, return metadata results
-	if filters.ContentSearch == "" {
-		return metadataResults
-	}
-	
-	// Filter results by content search
-	contentResults := make([]FileMetadata, 0)
-	searchLower := strings.ToLower(filters.ContentSearch)
-	
-	for _, meta := range metadataResults {
-		// Only search content in text files
-		if !isTextMimeType(meta.MimeType) {
-			continue
-		}
-		
-		// Build full path
-		fullPath := filepath.Join(m.root, meta.StoredPath)
-		
-		// Search file content
-		if searchFileContent(fullPath, searchLower) {
-			contentResults = append(contentResults, meta)
-		}
-	}
-	
-	return contentResults
-}
-
-// searchFileContent searches for a string in a file (case-insensitive).
-// Returns true if the search string is found.
-func searchFileContent(filePath, searchLower string) bool {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return false
-	}
-	defer file.Close()
-	
-	// Limit file size for content search (max 10 MB)
-	const maxSize = 10 * 1024 * 1024
-	fileInfo, err := file.Stat()
-	if err != nil || fileInfo.Size() > maxSize {
-		return false
-	}
-	
-	scanner := bufio.NewScanner(file)
-	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // 1MB max line size
-	
-	for scanner.Scan() {
-		line := strings.ToLower(scanner.Text())
-		if strings.Contains(line, searchLower) {
-			return true
-		}
-	}
-	
-	return false
-}
@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrShareNotFound is returned when no share link has the requested ID.
+	ErrShareNotFound = errors.New("share link not found")
+	// ErrShareUnavailable is returned for share links that were revoked,
+	// expired or used up their downloads.
+	ErrShareUnavailable = errors.New("share link is no longer available")
+	// ErrSharePassword is returned when a password protected share link is
+	// used without its password or with a wrong one.
+	ErrSharePassword = errors.New("share link password is missing or incorrect")
+	// ErrInvalidShare is returned for share requests with an expiry in the
+	// past or a negative download limit.
+	ErrInvalidShare = errors.New("invalid share link")
+)
+
+const (
+	// shareKeyPrefix holds one ShareLink record per share link.
+	shareKeyPrefix = "share:"
+
+	// sharePasswordIterations is the PBKDF2-SHA256 work factor of share
+	// link passwords.
+	sharePasswordIterations = 100_000
+)
+
+// ShareLink lets anyone holding its signed token download one file without
+// credentials until it expires, is revoked or has served MaxDownloads
+// downloads. The token itself is derived from the ID by the API layer.
+type ShareLink struct {
+	ID           string    `json:"id"`
+	Hash         string    `json:"hash"`
+	OriginalName string    `json:"original_name"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	// MaxDownloads of zero allows unlimited downloads.
+	MaxDownloads int `json:"max_downloads,omitempty"`
+	Downloads    int `json:"downloads"`
+	// PasswordHash is empty for links without a password.
+	PasswordHash   string     `json:"password_hash,omitempty"`
+	CreatedBy      string     `json:"created_by,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+}
+
+// Protected reports whether the share link requires a password.
+func (s *ShareLink) Protected() bool {
+	return s.PasswordHash != ""
+}
+
+// Available returns nil when the share link can still be used at now, or
+// an ErrShareUnavailable error naming why it cannot.
+func (s *ShareLink) Available(now time.Time) error {
+	if err := s.open(now); err != nil {
+		return err
+	}
+	if s.MaxDownloads > 0 && s.Downloads >= s.MaxDownloads {
+		return fmt.Errorf("%w: download limit reached", ErrShareUnavailable)
+	}
+	return nil
+}
+
+// open is Available without the download limit.
+func (s *ShareLink) open(now time.Time) error {
+	switch {
+	case s.RevokedAt != nil:
+		return fmt.Errorf("%w: revoked", ErrShareUnavailable)
+	case !now.Before(s.ExpiresAt):
+		return fmt.Errorf("%w: expired", ErrShareUnavailable)
+	}
+	return nil
+}
+
+// CreateShareRequest describes a new share link for the file with Hash.
+type CreateShareRequest struct {
+	Hash         string
+	ExpiresAt    time.Time
+	Password     string
+	MaxDownloads int
+	CreatedBy    string
+}
+
+// ShareIndex persists share links in the embedded index store.
+type ShareIndex struct {
+	store *kvStore
+	mu    sync.Mutex
+}
+
+// NewShareIndex opens the share index in the store beside path.
+func NewShareIndex(path string) (*ShareIndex, error) {
+	store, err := openKVStore(kvDirFor(path))
+	if err != nil {
+		return nil, err
+	}
+	return &ShareIndex{store: store}, nil
+}
+
+// Close releases the index store.
+func (idx *ShareIndex) Close() error {
+	return idx.store.release()
+}
+
+func getShare(txn *badger.Txn, id string) (*ShareLink, error) {
+	var share ShareLink
+	found, err := getJSON(txn, shareKeyPrefix+id, &share)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrShareNotFound, id)
+	}
+	return &share, nil
+}
+
+// CreateShare creates a share link for a stored file.
+func (m *Manager) CreateShare(req CreateShareRequest) (*ShareLink, error) {
+	m.mu.Lock()
+	meta := m.index.FindByHash(req.Hash)
+	m.mu.Unlock()
+	if meta == nil {
+		return nil, fmt.Errorf("%w: hash %s", ErrFileNotFound, req.Hash)
+	}
+	now := time.Now().UTC()
+	if !req.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("%w: expiry must be in the future", ErrInvalidShare)
+	}
+	if req.MaxDownloads < 0 {
+		return nil, fmt.Errorf("%w: max_downloads must not be negative", ErrInvalidShare)
+	}
+
+	share := ShareLink{
+		ID:           uuid.New().String(),
+		Hash:         meta.Hash,
+		OriginalName: meta.OriginalName,
+		CreatedAt:    now,
+		ExpiresAt:    req.ExpiresAt.UTC(),
+		MaxDownloads: req.MaxDownloads,
+		CreatedBy:    req.CreatedBy,
+	}
+	if req.Password != "" {
+		hash, err := hashSharePassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		share.PasswordHash = hash
+	}
+
+	idx := m.shareIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		return setJSON(txn, shareKeyPrefix+share.ID, share)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// GetShare returns the share link with id.
+func (m *Manager) GetShare(id string) (*ShareLink, error) {
+	var share *ShareLink
+	err := m.shareIndex.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		share, err = getShare(txn, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// ListShares returns the share links of the file with hash, or every share
+// link when hash is empty, newest first.
+func (m *Manager) ListShares(hash string) ([]ShareLink, error) {
+	shares := make([]ShareLink, 0)
+	err := m.shareIndex.store.db.View(func(txn *badger.Txn) error {
+		return scanPrefix(txn, shareKeyPrefix, true, func(_ string, val []byte) (bool, error) {
+			var share ShareLink
+			if err := json.Unmarshal(val, &share); err != nil {
+				return false, err
+			}
+			if hash == "" || share.Hash == hash {
+				shares = append(shares, share)
+			}
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].CreatedAt.After(shares[j].CreatedAt) })
+	return shares, nil
+}
+
+// RevokeShare disables a share link. Revoking a revoked link is a no-op.
+func (m *Manager) RevokeShare(id string) (*ShareLink, error) {
+	idx := m.shareIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var share *ShareLink
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		var err error
+		if share, err = getShare(txn, id); err != nil {
+			return err
+		}
+		if share.RevokedAt != nil {
+			return nil
+		}
+		now := time.Now().UTC()
+		share.RevokedAt = &now
+		return setJSON(txn, shareKeyPrefix+id, share)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// UseShare checks that the share link with id is available and that
+// password matches. When download is set the access counts against the
+// link's download limit, atomically with the check.
+func (m *Manager) UseShare(id, password string, download bool) (*ShareLink, error) {
+	idx := m.shareIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var share *ShareLink
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		var err error
+		if share, err = getShare(txn, id); err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		if err := share.Available(now); err != nil {
+			return err
+		}
+		if share.Protected() && !checkSharePassword(share.PasswordHash, password) {
+			return ErrSharePassword
+		}
+		if !download {
+			return nil
+		}
+		share.Downloads++
+		share.LastAccessedAt = &now
+		return setJSON(txn, shareKeyPrefix+id, share)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// ResumeShare checks that the share link with id is neither revoked nor
+// expired and that password matches, for a download that already counted
+// against the link's limit. The limit itself is not checked.
+func (m *Manager) ResumeShare(id, password string) (*ShareLink, error) {
+	var share *ShareLink
+	err := m.shareIndex.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		if share, err = getShare(txn, id); err != nil {
+			return err
+		}
+		if err := share.open(time.Now().UTC()); err != nil {
+			return err
+		}
+		if share.Protected() && !checkSharePassword(share.PasswordHash, password) {
+			return ErrSharePassword
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// hashSharePassword returns "pbkdf2-sha256$<iterations>$<salt>$<key>".
+func hashSharePassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate salt: %w", err)
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, sharePasswordIterations, sha256.Size)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", sharePasswordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func checkSharePassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
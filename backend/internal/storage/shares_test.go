@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShareLinks(t *testing.T) {
+	m := newTestManager(t)
+	meta := storeContent(t, m, "report.pdf", "application/pdf", "quarterly numbers")
+
+	if _, err := m.CreateShare(CreateShareRequest{Hash: "missing", ExpiresAt: time.Now().Add(time.Hour)}); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected ErrFileNotFound, got %v", err)
+	}
+	if _, err := m.CreateShare(CreateShareRequest{Hash: meta.Hash, ExpiresAt: time.Now().Add(-time.Second)}); !errors.Is(err, ErrInvalidShare) {
+		t.Fatalf("expected ErrInvalidShare, got %v", err)
+	}
+
+	share, err := m.CreateShare(CreateShareRequest{Hash: meta.Hash, ExpiresAt: time.Now().Add(time.Hour), Password: "hunter2", MaxDownloads: 2})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !share.Protected() || share.OriginalName != "report.pdf" || !strings.HasPrefix(share.PasswordHash, "pbkdf2-sha256$") {
+		t.Fatalf("unexpected share %+v", share)
+	}
+	if _, err := m.UseShare(share.ID, "", true); !errors.Is(err, ErrSharePassword) {
+		t.Fatalf("expected ErrSharePassword without a password, got %v", err)
+	}
+	if _, err := m.UseShare(share.ID, "wrong", true); !errors.Is(err, ErrSharePassword) {
+		t.Fatalf("expected ErrSharePassword for a wrong password, got %v", err)
+	}
+	if got, err := m.UseShare(share.ID, "hunter2", false); err != nil || got.Downloads != 0 {
+		t.Fatalf("check: %+v %v", got, err)
+	}
+	for i := 1; i <= 2; i++ {
+		got, err := m.UseShare(share.ID, "hunter2", true)
+		if err != nil || got.Downloads != i || got.LastAccessedAt == nil {
+			t.Fatalf("download %d: %+v %v", i, got, err)
+		}
+	}
+	if _, err := m.UseShare(share.ID, "hunter2", true); !errors.Is(err, ErrShareUnavailable) {
+		t.Fatalf("expected ErrShareUnavailable after the limit, got %v", err)
+	}
+	if _, err := m.ResumeShare(share.ID, "wrong"); !errors.Is(err, ErrSharePassword) {
+		t.Fatalf("expected ErrSharePassword resuming with a wrong password, got %v", err)
+	}
+	if got, err := m.ResumeShare(share.ID, "hunter2"); err != nil || got.Downloads != 2 {
+		t.Fatalf("expected a counted download to resume past the limit, got %+v %v", got, err)
+	}
+
+	open, err := m.CreateShare(CreateShareRequest{Hash: meta.Hash, ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("create second: %v", err)
+	}
+	if _, err := m.UseShare(open.ID, "", true); err != nil {
+		t.Fatalf("expected links without a password to open, got %v", err)
+	}
+	shares, err := m.ListShares(meta.Hash)
+	if err != nil || len(shares) != 2 || shares[0].ID != open.ID {
+		t.Fatalf("list = %+v %v", shares, err)
+	}
+
+	revoked, err := m.RevokeShare(open.ID)
+	if err != nil || revoked.RevokedAt == nil {
+		t.Fatalf("revoke: %+v %v", revoked, err)
+	}
+	if _, err := m.UseShare(open.ID, "", false); !errors.Is(err, ErrShareUnavailable) {
+		t.Fatalf("expected ErrShareUnavailable after revoking, got %v", err)
+	}
+	if err := (&ShareLink{ExpiresAt: time.Now().Add(-time.Minute)}).Available(time.Now()); !errors.Is(err, ErrShareUnavailable) {
+		t.Fatalf("expected expired links to be unavailable, got %v", err)
+	}
+	if _, err := m.RevokeShare("missing"); !errors.Is(err, ErrShareNotFound) {
+		t.Fatalf("expected ErrShareNotFound, got %v", err)
+	}
+}
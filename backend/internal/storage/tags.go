@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidTag is returned for tags that are empty, too long or too deep,
+// or contain control characters or commas.
+var ErrInvalidTag = errors.New("invalid tag")
+
+const (
+	// maxTagLength bounds a normalized tag in bytes.
+	maxTagLength = 128
+	// maxTagDepth bounds the number of /-separated levels of a tag.
+	maxTagDepth = 8
+	// maxTagsPerFile bounds the tags of a single file.
+	maxTagsPerFile = 64
+	// maxTagBatch bounds the files of one TagFiles request.
+	maxTagBatch = 1000
+)
+
+// NormalizeTag lower-cases tag and trims the spaces around each of its
+// /-separated levels, so " Project / Apollo " becomes project/apollo.
+func NormalizeTag(tag string) (string, error) {
+	levels := strings.Split(strings.ToLower(tag), "/")
+	for i, level := range levels {
+		level = strings.TrimSpace(level)
+		if level == "" {
+			return "", fmt.Errorf("%w: %q has an empty level", ErrInvalidTag, tag)
+		}
+		for _, r := range level {
+			if unicode.IsControl(r) || r == ',' {
+				return "", fmt.Errorf("%w: %q contains %q", ErrInvalidTag, tag, r)
+			}
+		}
+		levels[i] = level
+	}
+	if len(levels) > maxTagDepth {
+		return "", fmt.Errorf("%w: %q is deeper than %d levels", ErrInvalidTag, tag, maxTagDepth)
+	}
+	normalized := strings.Join(levels, "/")
+	if len(normalized) > maxTagLength {
+		return "", fmt.Errorf("%w: %q is longer than %d bytes", ErrInvalidTag, tag, maxTagLength)
+	}
+	return normalized, nil
+}
+
+// NormalizeTags normalizes every tag and returns them sorted without duplicates.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		n, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, n)
+	}
+	sort.Strings(normalized)
+	return compactStrings(normalized), nil
+}
+
+func compactStrings(sorted []string) []string {
+	out := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// tagMatches reports whether have is tag or one of its descendants.
+func tagMatches(have, tag string) bool {
+	return have == tag || strings.HasPrefix(have, tag+"/")
+}
+
+// hasAllTags reports whether tags cover every wanted tag, counting descendants.
+func hasAllTags(tags, wanted []string) bool {
+	for _, want := range wanted {
+		found := false
+		for _, have := range tags {
+			if tagMatches(have, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// TagRequest adds and removes tags on several files at once. Removing a tag
+// removes exactly that tag; its descendants stay.
+type TagRequest struct {
+	Hashes []string `json:"hashes"`
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// TagResult reports the outcome of a TagRequest for one file.
+type TagResult struct {
+	Hash    string   `json:"hash"`
+	Tags    []string `json:"tags"`
+	Changed bool     `json:"changed"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// BatchTagResult aggregates the per-file results of a TagRequest.
+type BatchTagResult struct {
+	Results      []TagResult `json:"results"`
+	Total        int         `json:"total"`
+	SuccessCount int         `json:"success_count"`
+	FailureCount int         `json:"failure_count"`
+}
+
+// TagFiles applies req to each file. Invalid tags fail the whole request;
+// missing files and files that would exceed the tag limit fail on their own.
+func (m *Manager) TagFiles(req TagRequest) (*BatchTagResult, error) {
+	if len(req.Hashes) == 0 {
+		return nil, fmt.Errorf("%w: no files provided", ErrInvalidInput)
+	}
+	if len(req.Hashes) > maxTagBatch {
+		return nil, fmt.Errorf("%w: tagging is limited to %d files per request", ErrInvalidInput, maxTagBatch)
+	}
+	if len(req.Add) == 0 && len(req.Remove) == 0 {
+		return nil, fmt.Errorf("%w: no tags to add or remove", ErrInvalidInput)
+	}
+	add, err := NormalizeTags(req.Add)
+	if err != nil {
+		return nil, err
+	}
+	remove, err := NormalizeTags(req.Remove)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := &BatchTagResult{Results: make([]TagResult, 0, len(req.Hashes)), Total: len(req.Hashes)}
+	for _, hash := range req.Hashes {
+		res, err := m.retagLocked(hash, add, remove)
+		if err != nil {
+			res = TagResult{Hash: hash, Error: err.Error()}
+			result.FailureCount++
+		} else {
+			result.SuccessCount++
+		}
+		result.Results = append(result.Results, res)
+	}
+	return result, nil
+}
+
+// retagLocked adds and removes normalized tags on one file. Caller holds m.mu.
+func (m *Manager) retagLocked(hash string, add, remove []string) (TagResult, error) {
+	meta := m.index.FindByHash(hash)
+	if meta == nil {
+		return TagResult{}, fmt.Errorf("%w: hash %s", ErrFileNotFound, hash)
+	}
+
+	removed := make(map[string]bool, len(remove))
+	for _, tag := range remove {
+		removed[tag] = true
+	}
+	tags := make([]string, 0, len(meta.Tags)+len(add))
+	for _, tag := range meta.Tags {
+		if !removed[tag] {
+			tags = append(tags, tag)
+		}
+	}
+	tags = append(tags, add...)
+	sort.Strings(tags)
+	tags = compactStrings(tags)
+	if len(tags) > maxTagsPerFile {
+		return TagResult{}, fmt.Errorf("%w: a file can have at most %d tags", ErrInvalidTag, maxTagsPerFile)
+	}
+
+	if equalStrings(tags, meta.Tags) {
+		return TagResult{Hash: hash, Tags: tags}, nil
+	}
+	updated := *meta
+	updated.Tags = tags
+	if len(tags) == 0 {
+		updated.Tags = nil
+	}
+	if err := m.index.Add(updated); err != nil {
+		return TagResult{}, err
+	}
+	return TagResult{Hash: hash, Tags: tags, Changed: true}, nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// inheritTagsLocked gives dstHash the tags of srcHash in addition to its
+// own, so tags follow a file to its new versions. Failures are reported and
+// otherwise ignored. Caller holds m.mu.
+func (m *Manager) inheritTagsLocked(srcHash, dstHash string) {
+	src := m.index.FindByHash(srcHash)
+	if src == nil || len(src.Tags) == 0 || srcHash == dstHash {
+		return
+	}
+	if _, err := m.retagLocked(dstHash, src.Tags, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "tag inheritance failed for %s: %v\n", dstHash, err)
+	}
+}
+
+// TagCount reports how many files carry a tag. Ancestors of a tag are listed
+// even when no file carries them directly.
+type TagCount struct {
+	Tag string `json:"tag"`
+	// Count is the number of files tagged with exactly Tag.
+	Count int `json:"count"`
+	// Total is the number of files tagged with Tag or a descendant.
+	Total int `json:"total"`
+}
+
+// ListTags returns every tag in use, sorted. A non-empty prefix limits the
+// list to that tag and its descendants.
+func (m *Manager) ListTags(prefix string) ([]TagCount, error) {
+	if prefix != "" {
+		var err error
+		if prefix, err = NormalizeTag(prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	counts := make(map[string]*TagCount)
+	seen := make(map[string]map[string]bool)
+	err := m.index.ForEachTag(func(tag, hash string) {
+		for level := tag; ; {
+			if prefix == "" || tagMatches(level, prefix) {
+				tc := counts[level]
+				if tc == nil {
+					tc = &TagCount{Tag: level}
+					counts[level] = tc
+					seen[level] = make(map[string]bool)
+				}
+				if level == tag {
+					tc.Count++
+				}
+				if !seen[level][hash] {
+					seen[level][hash] = true
+					tc.Total++
+				}
+			}
+			i := strings.LastIndexByte(level, '/')
+			if i < 0 {
+				break
+			}
+			level = level[:i]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]TagCount, 0, len(counts))
+	for _, tc := range counts {
+		tags = append(tags, *tc)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Tag < tags[j].Tag })
+	return tags, nil
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	for in, want := range map[string]string{
+		" Project / Apollo ": "project/apollo",
+		"2026":               "2026",
+		"Q1 review":          "q1 review",
+	} {
+		if got, err := NormalizeTag(in); err != nil || got != want {
+			t.Errorf("NormalizeTag(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "project//apollo", "/apollo", "a,b", "tab\there", strings.Repeat("a/", maxTagDepth) + "a", strings.Repeat("x", maxTagLength+1)} {
+		if _, err := NormalizeTag(bad); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("NormalizeTag(%q): expected ErrInvalidTag, got %v", bad, err)
+		}
+	}
+}
+
+func TestTagFilesAndFilter(t *testing.T) {
+	m := newTestManager(t)
+	launch := storeContent(t, m, "launch.txt", "text/plain", "launch")
+	orbit := storeContent(t, m, "orbit.txt", "text/plain", "orbit")
+	other := storeContent(t, m, "other.txt", "text/plain", "other")
+
+	result, err := m.TagFiles(TagRequest{Hashes: []string{launch.Hash, orbit.Hash, "missing"}, Add: []string{"Project/Apollo/2026", "urgent", "urgent"}})
+	if err != nil {
+		t.Fatalf("tag: %v", err)
+	}
+	if result.SuccessCount != 2 || result.FailureCount != 1 || !reflect.DeepEqual(result.Results[0].Tags, []string{"project/apollo/2026", "urgent"}) {
+		t.Fatalf("unexpected tag result %+v", result)
+	}
+	if _, err := m.TagFiles(TagRequest{Hashes: []string{orbit.Hash}, Remove: []string{"urgent"}}); err != nil {
+		t.Fatalf("untag: %v", err)
+	}
+	if _, err := m.TagFiles(TagRequest{Hashes: []string{other.Hash}, Add: []string{"project/apollonia"}}); err != nil {
+		t.Fatalf("tag other: %v", err)
+	}
+	if _, err := m.TagFiles(TagRequest{Hashes: []string{other.Hash}, Add: []string{"bad//tag"}}); !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("expected ErrInvalidTag, got %v", err)
+	}
+
+	tags, err := m.ListTags("")
+	if err != nil {
+		t.Fatalf("list tags: %v", err)
+	}
+	want := []TagCount{
+		{Tag: "project", Total: 3},
+		{Tag: "project/apollo", Total: 2},
+		{Tag: "project/apollo/2026", Count: 2, Total: 2},
+		{Tag: "project/apollonia", Count: 1, Total: 1},
+		{Tag: "urgent", Count: 1, Total: 1},
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Fatalf("tags = %+v\nwant %+v", tags, want)
+	}
+	if tags, _ := m.ListTags("Project/Apollo"); len(tags) != 2 || tags[0].Tag != "project/apollo" {
+		t.Fatalf("expected the apollo subtree, got %+v", tags)
+	}
+
+	listed, err := m.ListFiles(ListOptions{Tags: []string{"project/apollo"}})
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	var names []string
+	for _, meta := range listed.Files {
+		names = append(names, meta.OriginalName)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"launch.txt", "orbit.txt"}) {
+		t.Fatalf("expected hierarchical tag filter, got %v", names)
+	}
+	if found := m.SearchFiles(SearchFilters{Tags: []string{"project/apollo", "urgent"}}); len(found) != 1 || found[0].Hash != launch.Hash {
+		t.Fatalf("expected both tags to be required, got %+v", found)
+	}
+	if got := queryNames(t, m, "tag:project/apollo -tag:urgent"); !reflect.DeepEqual(got, []string{"orbit.txt"}) {
+		t.Fatalf("expected query language tag terms, got %v", got)
+	}
+}
+
+func TestTagsFollowFile(t *testing.T) {
+	m := newTestManager(t)
+	meta := storeContent(t, m, "spec.txt", "text/plain", "v1")
+	if _, err := m.TagFiles(TagRequest{Hashes: []string{meta.Hash}, Add: []string{"specs/api"}}); err != nil {
+		t.Fatalf("tag: %v", err)
+	}
+	tagsOf := func(hash string) []string {
+		t.Helper()
+		found := m.index.FindByHash(hash)
+		if found == nil {
+			t.Fatalf("file %s not found", hash)
+		}
+		return found.Tags
+	}
+	want := []string{"specs/api"}
+
+	if _, err := m.RenameFile(RenameRequest{Hash: meta.Hash, NewName: "api.txt", UpdateStoredFile: true}); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if _, err := m.MoveFile(MoveRequest{Hash: meta.Hash, NewCategory: "docs/specs"}); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if got := tagsOf(meta.Hash); !reflect.DeepEqual(got, want) {
+		t.Fatalf("tags after rename and move = %v", got)
+	}
+	copied, err := m.CopyFile(CopyRequest{Hash: meta.Hash, NewName: "api_copy.txt"})
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if got := tagsOf(copied.NewHash); !reflect.DeepEqual(got, want) {
+		t.Fatalf("tags of copy = %v", got)
+	}
+
+	version, err := m.CreateVersion(VersionRequest{FileID: meta.Hash, Reader: strings.NewReader("v2"), Filename: "api.txt", MimeType: "text/plain", Size: 2})
+	if err != nil {
+		t.Fatalf("create version: %v", err)
+	}
+	if got := tagsOf(version.Version.Hash); !reflect.DeepEqual(got, want) {
+		t.Fatalf("tags of new version = %v", got)
+	}
+	if _, err := m.TagFiles(TagRequest{Hashes: []string{version.Version.Hash}, Add: []string{"reviewed"}}); err != nil {
+		t.Fatalf("tag version: %v", err)
+	}
+	if _, err := m.RevertVersion(meta.Hash, 1, ""); err != nil {
+		t.Fatalf("revert: %v", err)
+	}
+	if got := tagsOf(meta.Hash); !reflect.DeepEqual(got, []string{"reviewed", "specs/api"}) {
+		t.Fatalf("tags after revert = %v", got)
+	}
+}
+
+func TestVersionDoesNotRetagDuplicateContent(t *testing.T) {
+	m := newTestManager(t)
+	spec := storeContent(t, m, "spec.txt", "text/plain", "draft")
+	other := storeContent(t, m, "notes.txt", "text/plain", "final")
+	if _, err := m.TagFiles(TagRequest{Hashes: []string{spec.Hash}, Add: []string{"specs"}}); err != nil {
+		t.Fatalf("tag spec: %v", err)
+	}
+	if _, err := m.TagFiles(TagRequest{Hashes: []string{other.Hash}, Add: []string{"notes"}}); err != nil {
+		t.Fatalf("tag notes: %v", err)
+	}
+
+	version, err := m.CreateVersion(VersionRequest{FileID: spec.Hash, Reader: strings.NewReader("final"), Filename: "spec.txt", MimeType: "text/plain", Size: 5})
+	if err != nil {
+		t.Fatalf("create version: %v", err)
+	}
+	if version.Version.Hash != other.Hash {
+		t.Fatalf("version hash = %s, want the existing %s", version.Version.Hash, other.Hash)
+	}
+	if got := m.index.FindByHash(other.Hash).Tags; !reflect.DeepEqual(got, []string{"notes"}) {
+		t.Fatalf("tags of the existing file = %v", got)
+	}
+}
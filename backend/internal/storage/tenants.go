@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTenantID is the tenant served from the data root itself, so
+// installations that predate tenants keep their files where they are.
+const DefaultTenantID = "default"
+
+var (
+	// ErrTenantNotFound is returned for unknown tenant IDs.
+	ErrTenantNotFound = errors.New("tenant not found")
+	// ErrTenantExists is returned when creating a tenant whose ID is taken.
+	ErrTenantExists = errors.New("tenant already exists")
+	// ErrInvalidTenantID is returned for IDs that are not safe directory names.
+	ErrInvalidTenantID = errors.New("invalid tenant id")
+)
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Tenant is the persisted description of one tenant.
+type Tenant struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Quota     Quota     `json:"quota"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BackendFactory builds the blob backend for a tenant rooted at root.
+type BackendFactory func(tenantID, root string) (Backend, error)
+
+// TenantRegistry maps tenant IDs to isolated Managers. Every tenant other than
+// the default one lives in <root>/tenants/<id> with its own storage tree,
+// metadata index and dedup cache, so hashes never match across tenants.
+// Managers are opened on first use and kept until Close.
+type TenantRegistry struct {
+	root       string
+	path       string
+	newBackend BackendFactory
+	mu         sync.Mutex
+	tenants    map[string]*Tenant
+	managers   map[string]*Manager
+}
+
+// NewTenantRegistry loads <root>/tenants/tenants.json. defaultMgr serves the
+// default tenant and stays owned by the caller.
+func NewTenantRegistry(root string, defaultMgr *Manager, newBackend BackendFactory) (*TenantRegistry, error) {
+	dir := filepath.Join(root, "tenants")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	reg := &TenantRegistry{
+		root:       root,
+		path:       filepath.Join(dir, "tenants.json"),
+		newBackend: newBackend,
+		tenants:    make(map[string]*Tenant),
+		managers:   map[string]*Manager{DefaultTenantID: defaultMgr},
+	}
+	if err := reg.load(); err != nil {
+		return nil, err
+	}
+	if _, ok := reg.tenants[DefaultTenantID]; !ok {
+		reg.tenants[DefaultTenantID] = &Tenant{ID: DefaultTenantID, Name: "Default", CreatedAt: time.Now().UTC()}
+	}
+	defaultMgr.SetQuota(reg.tenants[DefaultTenantID].Quota)
+	return reg, nil
+}
+
+func (r *TenantRegistry) load() error {
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var tenants []Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return fmt.Errorf("decode tenants: %w", err)
+	}
+	for i := range tenants {
+		r.tenants[tenants[i].ID] = &tenants[i]
+	}
+	return nil
+}
+
+// persist writes the tenant list. Callers must hold r.mu.
+func (r *TenantRegistry) persist() error {
+	buf, err := json.MarshalIndent(r.listLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+func (r *TenantRegistry) listLocked() []Tenant {
+	list := make([]Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Create registers a new tenant. Its storage tree is created on first use.
+func (r *TenantRegistry) Create(t Tenant) (*Tenant, error) {
+	t.ID = strings.ToLower(strings.TrimSpace(t.ID))
+	if !tenantIDPattern.MatchString(t.ID) {
+		return nil, fmt.Errorf("%w: %q (use 1-63 lowercase letters, digits, '-' or '_')", ErrInvalidTenantID, t.ID)
+	}
+	if t.Quota.MaxBytes < 0 || t.Quota.MaxFiles < 0 {
+		return nil, fmt.Errorf("%w: quota limits must not be negative", ErrInvalidInput)
+	}
+	if t.Name == "" {
+		t.Name = t.ID
+	}
+	t.CreatedAt = time.Now().UTC()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tenants[t.ID]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrTenantExists, t.ID)
+	}
+	r.tenants[t.ID] = &t
+	if err := r.persist(); err != nil {
+		delete(r.tenants, t.ID)
+		return nil, err
+	}
+	created := t
+	return &created, nil
+}
+
+// Get returns a tenant by ID.
+func (r *TenantRegistry) Get(id string) (*Tenant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTenantNotFound, id)
+	}
+	copied := *t
+	return &copied, nil
+}
+
+// List returns all tenants ordered by ID.
+func (r *TenantRegistry) List() []Tenant {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.listLocked()
+}
+
+// SetQuota updates a tenant's quota and applies it to an open Manager immediately.
+func (r *TenantRegistry) SetQuota(id string, q Quota) (*Tenant, error) {
+	if q.MaxBytes < 0 || q.MaxFiles < 0 {
+		return nil, fmt.Errorf("%w: quota limits must not be negative", ErrInvalidInput)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTenantNotFound, id)
+	}
+	previous := t.Quota
+	t.Quota = q
+	if err := r.persist(); err != nil {
+		t.Quota = previous
+		return nil, err
+	}
+	if mgr, ok := r.managers[id]; ok {
+		mgr.SetQuota(q)
+	}
+	copied := *t
+	return &copied, nil
+}
+
+// Manager returns the Manager for tenant id, opening it on first use.
+func (r *TenantRegistry) Manager(id string) (*Manager, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if mgr, ok := r.managers[id]; ok {
+		return mgr, nil
+	}
+	t, ok := r.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTenantNotFound, id)
+	}
+
+	root := r.TenantRoot(id)
+	backend, err := r.newBackend(id, root)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s backend: %w", id, err)
+	}
+	mgr, err := NewManagerWithBackend(root, backend)
+	if err != nil {
+		return nil, fmt.Errorf("open tenant %s: %w", id, err)
+	}
+	mgr.SetQuota(t.Quota)
+	r.managers[id] = mgr
+	return mgr, nil
+}
+
+// TenantRoot returns the data directory of tenant id.
+func (r *TenantRegistry) TenantRoot(id string) string {
+	if id == DefaultTenantID {
+		return r.root
+	}
+	return filepath.Join(r.root, "tenants", id)
+}
+
+// Close releases every Manager the registry opened. The default Manager is left to its owner.
+func (r *TenantRegistry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for id, mgr := range r.managers {
+		if id == DefaultTenantID {
+			continue
+		}
+		errs = append(errs, mgr.Close())
+		delete(r.managers, id)
+	}
+	return errors.Join(errs...)
+}
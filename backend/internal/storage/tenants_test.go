@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestRegistry(t *testing.T) (*TenantRegistry, *Manager) {
+	t.Helper()
+	root := t.TempDir()
+	defaultMgr, err := NewManager(root)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	reg, err := NewTenantRegistry(root, defaultMgr, func(_, tenantRoot string) (Backend, error) {
+		return NewLocalBackend(tenantRoot), nil
+	})
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = reg.Close()
+		_ = defaultMgr.Close()
+	})
+	return reg, defaultMgr
+}
+
+func storeText(t *testing.T, m *Manager, name, body string) (*StoreResult, error) {
+	t.Helper()
+	return m.StoreFile(StoreRequest{
+		Reader:   bytes.NewReader([]byte(body)),
+		Filename: name,
+		MimeType: "text/plain",
+		Size:     int64(len(body)),
+	})
+}
+
+func TestTenantRegistryIsolatesStorageAndDedup(t *testing.T) {
+	reg, defaultMgr := newTestRegistry(t)
+
+	if _, err := reg.Create(Tenant{ID: "Acme"}); err != nil {
+		t.Fatalf("create tenant: %v", err)
+	}
+	if _, err := reg.Create(Tenant{ID: "acme"}); !errors.Is(err, ErrTenantExists) {
+		t.Fatalf("expected ErrTenantExists, got %v", err)
+	}
+	if _, err := reg.Create(Tenant{ID: "../escape"}); !errors.Is(err, ErrInvalidTenantID) {
+		t.Fatalf("expected ErrInvalidTenantID, got %v", err)
+	}
+
+	acme, err := reg.Manager("acme")
+	if err != nil {
+		t.Fatalf("open tenant: %v", err)
+	}
+	if acme.Root() != filepath.Join(defaultMgr.Root(), "tenants", "acme") {
+		t.Fatalf("unexpected tenant root %s", acme.Root())
+	}
+
+	first, err := storeText(t, defaultMgr, "a.txt", "same bytes")
+	if err != nil {
+		t.Fatalf("store default: %v", err)
+	}
+	second, err := storeText(t, acme, "a.txt", "same bytes")
+	if err != nil {
+		t.Fatalf("store tenant: %v", err)
+	}
+	if second.Duplicate {
+		t.Fatal("identical content in another tenant must not be reported as a duplicate")
+	}
+	if first.Metadata.Hash != second.Metadata.Hash {
+		t.Fatal("hashes of identical content should match")
+	}
+	if _, err := os.Stat(filepath.Join(acme.Root(), filepath.FromSlash(second.Metadata.StoredPath))); err != nil {
+		t.Fatalf("tenant file missing from tenant root: %v", err)
+	}
+	if got := len(acme.GetAllMetadata()); got != 1 {
+		t.Fatalf("expected 1 file in tenant index, got %d", got)
+	}
+
+	if _, err := reg.Manager("missing"); !errors.Is(err, ErrTenantNotFound) {
+		t.Fatalf("expected ErrTenantNotFound, got %v", err)
+	}
+}
+
+func TestTenantQuotaEnforcement(t *testing.T) {
+	reg, _ := newTestRegistry(t)
+
+	if _, err := reg.Create(Tenant{ID: "small", Quota: Quota{MaxFiles: 2, MaxBytes: 10}}); err != nil {
+		t.Fatalf("create tenant: %v", err)
+	}
+	mgr, err := reg.Manager("small")
+	if err != nil {
+		t.Fatalf("open tenant: %v", err)
+	}
+
+	if _, err := storeText(t, mgr, "one.txt", "12345"); err != nil {
+		t.Fatalf("store 1: %v", err)
+	}
+	// Duplicates do not consume quota.
+	if res, err := storeText(t, mgr, "again.txt", "12345"); err != nil || !res.Duplicate {
+		t.Fatalf("expected duplicate within quota, got %+v, %v", res, err)
+	}
+	if _, err := storeText(t, mgr, "big.txt", "123456"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected byte quota error, got %v", err)
+	}
+	if _, err := storeText(t, mgr, "two.txt", "abcde"); err != nil {
+		t.Fatalf("store 2: %v", err)
+	}
+
+	usage, err := mgr.Usage()
+	if err != nil {
+		t.Fatalf("usage: %v", err)
+	}
+	if usage.Files != 2 || usage.Bytes != 10 {
+		t.Fatalf("expected 2 files / 10 bytes, got %+v", usage)
+	}
+
+	// Raising the quota applies to the open manager immediately.
+	if _, err := reg.SetQuota("small", Quota{MaxFiles: 3}); err != nil {
+		t.Fatalf("set quota: %v", err)
+	}
+	if _, err := storeText(t, mgr, "three.txt", "xyz"); err != nil {
+		t.Fatalf("store after raising quota: %v", err)
+	}
+	if _, err := storeText(t, mgr, "four.txt", "four"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected file quota error, got %v", err)
+	}
+	if _, err := mgr.CreateUpload(UploadRequest{Length: 1, Filename: "late.txt"}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected upload creation to hit quota, got %v", err)
+	}
+}
@@ -0,0 +1,463 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // registers the GIF decoder; the first frame is thumbnailed
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var (
+	// ErrThumbnailUnsupported is returned for files that are not JPEG, PNG or
+	// GIF images, or whose image data cannot be decoded.
+	ErrThumbnailUnsupported = errors.New("thumbnails are not supported for this file")
+	// ErrInvalidThumbnailSize is returned when a size is not one of the configured sizes.
+	ErrInvalidThumbnailSize = errors.New("invalid thumbnail size")
+)
+
+// DefaultThumbnailSizes are the longest-edge sizes, in pixels, generated when
+// none are configured.
+var DefaultThumbnailSizes = []int{128, 256, 512}
+
+// thumbnailKeyPrefix namespaces thumbnail records inside the shared index store.
+const thumbnailKeyPrefix = "thumb:"
+
+// thumbnailDirName is the hidden directory, next to the original, that holds
+// its derivatives. Directory browsing skips dot entries.
+const thumbnailDirName = ".thumbnails"
+
+// maxThumbnailSourcePixels bounds the decoded size of a source image so a
+// small, highly compressed file cannot exhaust memory.
+const maxThumbnailSourcePixels = 64 << 20
+
+const thumbnailJPEGQuality = 85
+
+// Thumbnail describes one stored derivative of an image.
+type Thumbnail struct {
+	Size     int    `json:"size"`
+	Key      string `json:"key"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	MimeType string `json:"mime_type"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// ThumbnailSet lists the derivatives generated for a file, smallest first.
+type ThumbnailSet struct {
+	Hash        string      `json:"hash"`
+	Thumbnails  []Thumbnail `json:"thumbnails"`
+	GeneratedAt time.Time   `json:"generated_at"`
+}
+
+// find returns the derivative of the given size, if any.
+func (s *ThumbnailSet) find(size int) (Thumbnail, bool) {
+	for _, thumb := range s.Thumbnails {
+		if thumb.Size == size {
+			return thumb, true
+		}
+	}
+	return Thumbnail{}, false
+}
+
+// merge replaces or adds thumbs, keeping the list sorted by size.
+func (s *ThumbnailSet) merge(thumbs []Thumbnail) {
+	for _, thumb := range thumbs {
+		replaced := false
+		for i := range s.Thumbnails {
+			if s.Thumbnails[i].Size == thumb.Size {
+				s.Thumbnails[i] = thumb
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			s.Thumbnails = append(s.Thumbnails, thumb)
+		}
+	}
+	sort.Slice(s.Thumbnails, func(i, j int) bool { return s.Thumbnails[i].Size < s.Thumbnails[j].Size })
+}
+
+// ThumbnailIndex persists thumbnail records in the embedded index store, keyed by hash.
+type ThumbnailIndex struct {
+	store *kvStore
+	mu    sync.RWMutex
+}
+
+// NewThumbnailIndex opens the thumbnail index in the store beside path.
+func NewThumbnailIndex(path string) (*ThumbnailIndex, error) {
+	store, err := openKVStore(kvDirFor(path))
+	if err != nil {
+		return nil, err
+	}
+	return &ThumbnailIndex{store: store}, nil
+}
+
+// Close releases the index store.
+func (idx *ThumbnailIndex) Close() error {
+	return idx.store.release()
+}
+
+// Put records set, replacing any previous record for the same hash.
+func (idx *ThumbnailIndex) Put(set ThumbnailSet) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		return setJSON(txn, thumbnailKeyPrefix+set.Hash, set)
+	})
+}
+
+// Get returns the record for hash, or nil if no thumbnails were generated.
+func (idx *ThumbnailIndex) Get(hash string) (*ThumbnailSet, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var set ThumbnailSet
+	var found bool
+	err := idx.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		found, err = getJSON(txn, thumbnailKeyPrefix+hash, &set)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// Delete removes the record for hash.
+func (idx *ThumbnailIndex) Delete(hash string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(thumbnailKeyPrefix + hash))
+	})
+}
+
+// ThumbnailResult is an open derivative ready to be served.
+type ThumbnailResult struct {
+	Thumbnail Thumbnail
+	Reader    BlobReader
+}
+
+// SetThumbnailSizes configures the sizes generated for each image. Invalid and
+// duplicate sizes are dropped; an empty list restores DefaultThumbnailSizes.
+func (m *Manager) SetThumbnailSizes(sizes []int) {
+	normalized := make([]int, 0, len(sizes))
+	seen := make(map[int]bool, len(sizes))
+	for _, size := range sizes {
+		if size > 0 && !seen[size] {
+			seen[size] = true
+			normalized = append(normalized, size)
+		}
+	}
+	sort.Ints(normalized)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.thumbnailSizes = normalized
+}
+
+// ThumbnailSizes returns the configured thumbnail sizes, smallest first.
+func (m *Manager) ThumbnailSizes() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.thumbnailSizes) == 0 {
+		return append([]int(nil), DefaultThumbnailSizes...)
+	}
+	return append([]int(nil), m.thumbnailSizes...)
+}
+
+// SupportsThumbnails reports whether thumbnails can be generated for meta,
+// judged by its MIME type or, failing that, its file extension.
+func SupportsThumbnails(meta FileMetadata) bool {
+	mime := strings.ToLower(strings.TrimSpace(meta.MimeType))
+	if i := strings.Index(mime, ";"); i >= 0 {
+		mime = strings.TrimSpace(mime[:i])
+	}
+	switch mime {
+	case "image/jpeg", "image/jpg", "image/png", "image/gif":
+		return true
+	}
+	switch strings.ToLower(path.Ext(meta.OriginalName)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// GenerateThumbnails (re)builds every configured thumbnail size for hash,
+// overwriting existing derivatives.
+func (m *Manager) GenerateThumbnails(hash string) (*ThumbnailSet, error) {
+	return m.generateThumbnails(hash, m.ThumbnailSizes())
+}
+
+// OpenThumbnail opens the derivative of hash at size, generating it first if
+// it is missing. A size of 0 selects the smallest configured size.
+func (m *Manager) OpenThumbnail(hash string, size int) (*ThumbnailResult, error) {
+	if m.index.FindByHash(hash) == nil {
+		return nil, ErrFileNotFound
+	}
+	sizes := m.ThumbnailSizes()
+	if size == 0 {
+		size = sizes[0]
+	}
+	valid := false
+	for _, s := range sizes {
+		if s == size {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, fmt.Errorf("%w: %d (available: %v)", ErrInvalidThumbnailSize, size, sizes)
+	}
+
+	set, err := m.thumbnailIndex.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if set != nil {
+		if thumb, ok := set.find(size); ok {
+			reader, err := m.backend.Open(thumb.Key)
+			if err == nil {
+				return &ThumbnailResult{Thumbnail: thumb, Reader: reader}, nil
+			}
+			if !errors.Is(err, ErrBlobNotFound) {
+				return nil, err
+			}
+		}
+	}
+
+	set, err = m.generateThumbnails(hash, []int{size})
+	if err != nil {
+		return nil, err
+	}
+	thumb, _ := set.find(size)
+	reader, err := m.backend.Open(thumb.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &ThumbnailResult{Thumbnail: thumb, Reader: reader}, nil
+}
+
+// generateThumbnails renders sizes for hash and records them. Decoding and
+// encoding run without the manager lock; the result is discarded if the file
+// was deleted or moved in the meantime.
+func (m *Manager) generateThumbnails(hash string, sizes []int) (*ThumbnailSet, error) {
+	meta := m.index.FindByHash(hash)
+	if meta == nil {
+		return nil, ErrFileNotFound
+	}
+	if !SupportsThumbnails(*meta) {
+		return nil, ErrThumbnailUnsupported
+	}
+
+	src, format, err := m.decodeImage(meta.StoredPath)
+	if err != nil {
+		return nil, err
+	}
+
+	thumbs := make([]Thumbnail, 0, len(sizes))
+	for _, size := range sizes {
+		thumb, err := m.writeThumbnail(*meta, src, format, size)
+		if err != nil {
+			m.deleteThumbnailBlobs(thumbs)
+			return nil, err
+		}
+		thumbs = append(thumbs, thumb)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := m.index.FindByHash(hash)
+	if current == nil || current.StoredPath != meta.StoredPath {
+		m.deleteThumbnailBlobs(thumbs)
+		return nil, ErrFileNotFound
+	}
+	set, err := m.thumbnailIndex.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		set = &ThumbnailSet{Hash: hash}
+	}
+	set.merge(thumbs)
+	set.GeneratedAt = time.Now().UTC()
+	if err := m.thumbnailIndex.Put(*set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// decodeImage reads and decodes the image stored at key, refusing images whose
+// dimensions exceed maxThumbnailSourcePixels.
+func (m *Manager) decodeImage(key string) (image.Image, string, error) {
+	reader, err := m.backend.Open(key)
+	if err != nil {
+		return nil, "", err
+	}
+	defer reader.Close()
+
+	cfg, _, err := image.DecodeConfig(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrThumbnailUnsupported, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxThumbnailSourcePixels {
+		return nil, "", fmt.Errorf("%w: image is %dx%d", ErrThumbnailUnsupported, cfg.Width, cfg.Height)
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	img, format, err := image.Decode(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrThumbnailUnsupported, err)
+	}
+	return img, format, nil
+}
+
+// writeThumbnail scales src to fit size and stores it next to the original.
+// JPEG sources produce JPEG thumbnails; PNG and GIF produce PNG to keep transparency.
+func (m *Manager) writeThumbnail(meta FileMetadata, src image.Image, format string, size int) (Thumbnail, error) {
+	img := resizeToFit(src, size)
+
+	var buf bytes.Buffer
+	thumb := Thumbnail{Size: size, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	ext := ".png"
+	if format == "jpeg" {
+		ext = ".jpg"
+		thumb.MimeType = "image/jpeg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+			return Thumbnail{}, err
+		}
+	} else {
+		thumb.MimeType = "image/png"
+		if err := png.Encode(&buf, img); err != nil {
+			return Thumbnail{}, err
+		}
+	}
+
+	thumb.Key = thumbnailKey(meta.StoredPath, meta.Hash, size, ext)
+	thumb.Bytes = int64(buf.Len())
+	if err := m.backend.Put(thumb.Key, &buf, thumb.Bytes); err != nil {
+		return Thumbnail{}, fmt.Errorf("store thumbnail: %w", err)
+	}
+	return thumb, nil
+}
+
+// thumbnailKey places a derivative in the hidden thumbnail directory beside storedPath.
+func thumbnailKey(storedPath, hash string, size int, ext string) string {
+	return blobKey(path.Dir(storedPath), thumbnailDirName, fmt.Sprintf("%s_%d%s", hash, size, ext))
+}
+
+// moveThumbnailsLocked follows a file to newStoredPath. Failures only cost a
+// regeneration on the next request, so they are not reported. Caller holds m.mu.
+func (m *Manager) moveThumbnailsLocked(hash, newStoredPath string) {
+	set, err := m.thumbnailIndex.Get(hash)
+	if err != nil || set == nil {
+		return
+	}
+	kept := set.Thumbnails[:0]
+	for _, thumb := range set.Thumbnails {
+		newKey := thumbnailKey(newStoredPath, hash, thumb.Size, path.Ext(thumb.Key))
+		if err := m.backend.Move(thumb.Key, newKey); err != nil {
+			_ = m.backend.Delete(thumb.Key)
+			continue
+		}
+		thumb.Key = newKey
+		kept = append(kept, thumb)
+	}
+	set.Thumbnails = kept
+	_ = m.thumbnailIndex.Put(*set)
+}
+
+// deleteThumbnailsLocked removes every derivative of hash. Caller holds m.mu.
+func (m *Manager) deleteThumbnailsLocked(hash string) error {
+	set, err := m.thumbnailIndex.Get(hash)
+	if err != nil || set == nil {
+		return err
+	}
+	m.deleteThumbnailBlobs(set.Thumbnails)
+	return m.thumbnailIndex.Delete(hash)
+}
+
+func (m *Manager) deleteThumbnailBlobs(thumbs []Thumbnail) {
+	for _, thumb := range thumbs {
+		_ = m.backend.Delete(thumb.Key)
+	}
+}
+
+// resizeToFit scales src down with a box filter so its longest edge is at
+// most size. Smaller images are returned at their original dimensions.
+func resizeToFit(src image.Image, size int) *image.RGBA {
+	rgba := toRGBA(src)
+	sw, sh := rgba.Rect.Dx(), rgba.Rect.Dy()
+	dw, dh := fitWithin(sw, sh, size)
+	if dw == sw && dh == sh {
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, (y+1)*sh/dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, (x+1)*sw/dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride+x0*4 : sy*rgba.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += uint64(row[i])
+					g += uint64(row[i+1])
+					b += uint64(row[i+2])
+					a += uint64(row[i+3])
+					n++
+				}
+			}
+			o := dst.PixOffset(x, y)
+			dst.Pix[o] = uint8((r + n/2) / n)
+			dst.Pix[o+1] = uint8((g + n/2) / n)
+			dst.Pix[o+2] = uint8((b + n/2) / n)
+			dst.Pix[o+3] = uint8((a + n/2) / n)
+		}
+	}
+	return dst
+}
+
+// toRGBA converts src to a zero-origin RGBA image. Averaging premultiplied
+// values keeps transparent pixels from bleeding colour into their neighbours.
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// fitWithin returns the dimensions of a w×h image scaled to fit a size×size box.
+func fitWithin(w, h, size int) (int, int) {
+	if w <= size && h <= size {
+		return w, h
+	}
+	if w >= h {
+		return size, max(1, (h*size+w/2)/w)
+	}
+	return max(1, (w*size+h/2)/h), size
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func storeImage(t *testing.T, m *Manager, name, mime string, img image.Image) *StoreResult {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	switch mime {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "image/gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatalf("encode %s: %v", name, err)
+	}
+	result, err := m.StoreFile(StoreRequest{Reader: &buf, Filename: name, MimeType: mime, Size: int64(buf.Len())})
+	if err != nil {
+		t.Fatalf("store %s: %v", name, err)
+	}
+	return result
+}
+
+func TestGenerateThumbnailsForEachFormat(t *testing.T) {
+	root := t.TempDir()
+	manager, err := NewManager(root)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	defer manager.Close()
+
+	cases := []struct {
+		name, mime, thumbMime string
+	}{
+		{"wide.png", "image/png", "image/png"},
+		{"wide.jpg", "image/jpeg", "image/jpeg"},
+		{"wide.gif", "image/gif", "image/png"},
+	}
+	for _, tc := range cases {
+		stored := storeImage(t, manager, tc.name, tc.mime, testImage(600, 300))
+		set, err := manager.GenerateThumbnails(stored.Metadata.Hash)
+		if err != nil {
+			t.Fatalf("%s: generate: %v", tc.name, err)
+		}
+		if len(set.Thumbnails) != len(DefaultThumbnailSizes) {
+			t.Fatalf("%s: expected %d thumbnails, got %d", tc.name, len(DefaultThumbnailSizes), len(set.Thumbnails))
+		}
+		for _, thumb := range set.Thumbnails {
+			if thumb.Width != thumb.Size || thumb.Height != thumb.Size/2 || thumb.MimeType != tc.thumbMime {
+				t.Fatalf("%s: unexpected thumbnail %+v", tc.name, thumb)
+			}
+			wantDir := filepath.ToSlash(filepath.Join(filepath.Dir(stored.Metadata.StoredPath), thumbnailDirName))
+			if !strings.HasPrefix(thumb.Key, wantDir+"/") {
+				t.Fatalf("%s: thumbnail %s not stored beside the original", tc.name, thumb.Key)
+			}
+			data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(thumb.Key)))
+			if err != nil {
+				t.Fatalf("%s: read thumbnail: %v", tc.name, err)
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil || cfg.Width != thumb.Width || cfg.Height != thumb.Height {
+				t.Fatalf("%s: decoded %dx%d (err %v), want %dx%d", tc.name, cfg.Width, cfg.Height, err, thumb.Width, thumb.Height)
+			}
+		}
+	}
+}
+
+func TestOpenThumbnailSizesAndErrors(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	defer manager.Close()
+	manager.SetThumbnailSizes([]int{64, 32, 64, -1})
+
+	if got := manager.ThumbnailSizes(); len(got) != 2 || got[0] != 32 || got[1] != 64 {
+		t.Fatalf("unexpected normalised sizes %v", got)
+	}
+
+	// Small images are never upscaled.
+	stored := storeImage(t, manager, "icon.png", "image/png", testImage(40, 20))
+	result, err := manager.OpenThumbnail(stored.Metadata.Hash, 64)
+	if err != nil {
+		t.Fatalf("open thumbnail: %v", err)
+	}
+	result.Reader.Close()
+	if result.Thumbnail.Width != 40 || result.Thumbnail.Height != 20 {
+		t.Fatalf("expected original dimensions, got %dx%d", result.Thumbnail.Width, result.Thumbnail.Height)
+	}
+
+	result, err = manager.OpenThumbnail(stored.Metadata.Hash, 0)
+	if err != nil {
+		t.Fatalf("open default size: %v", err)
+	}
+	result.Reader.Close()
+	if result.Thumbnail.Size != 32 || result.Thumbnail.Width != 32 {
+		t.Fatalf("expected the smallest size by default, got %+v", result.Thumbnail)
+	}
+
+	if _, err := manager.OpenThumbnail(stored.Metadata.Hash, 100); !errors.Is(err, ErrInvalidThumbnailSize) {
+		t.Fatalf("expected ErrInvalidThumbnailSize, got %v", err)
+	}
+	text, err := storeText(t, manager, "notes.txt", "not an image")
+	if err != nil {
+		t.Fatalf("store text: %v", err)
+	}
+	if _, err := manager.OpenThumbnail(text.Metadata.Hash, 32); !errors.Is(err, ErrThumbnailUnsupported) {
+		t.Fatalf("expected ErrThumbnailUnsupported, got %v", err)
+	}
+	if _, err := manager.OpenThumbnail("missing", 32); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected ErrFileNotFound, got %v", err)
+	}
+}
+
+func TestThumbnailsFollowMoveAndPurge(t *testing.T) {
+	root := t.TempDir()
+	manager, err := NewManager(root)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	defer manager.Close()
+
+	stored := storeImage(t, manager, "photo.png", "image/png", testImage(300, 300))
+	hash := stored.Metadata.Hash
+	set, err := manager.GenerateThumbnails(hash)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	oldKey := set.Thumbnails[0].Key
+
+	if _, err := manager.MoveFile(MoveRequest{Hash: hash, NewCategory: "images/archive"}); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	result, err := manager.OpenThumbnail(hash, set.Thumbnails[0].Size)
+	if err != nil {
+		t.Fatalf("open after move: %v", err)
+	}
+	result.Reader.Close()
+	if !strings.HasPrefix(result.Thumbnail.Key, "storage/images/archive/"+thumbnailDirName+"/") {
+		t.Fatalf("thumbnail did not follow the file: %s", result.Thumbnail.Key)
+	}
+	if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(oldKey))); !os.IsNotExist(err) {
+		t.Fatalf("old thumbnail should be gone: %v", err)
+	}
+
+	if _, err := manager.DeleteFile(DeleteRequest{Hash: hash}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := manager.OpenThumbnail(hash, set.Thumbnails[0].Size); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("trashed files should not serve thumbnails, got %v", err)
+	}
+	if _, err := manager.PurgeTrash(time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(result.Thumbnail.Key))); !os.IsNotExist(err) {
+		t.Fatalf("purge should remove thumbnails: %v", err)
+	}
+	if record, _ := manager.thumbnailIndex.Get(hash); record != nil {
+		t.Fatal("purge should drop the thumbnail record")
+	}
+}
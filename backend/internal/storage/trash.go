@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var (
+	// ErrTrashNotFound is returned when no trashed file has the requested hash.
+	ErrTrashNotFound = errors.New("file not found in trash")
+	// ErrRestoreConflict is returned when a trashed file cannot be restored
+	// because its hash is live again (e.g. the same content was re-uploaded).
+	ErrRestoreConflict = errors.New("restore conflict")
+)
+
+// trashKeyPrefix namespaces trash entries inside the shared index store.
+const trashKeyPrefix = "trash:"
+
+// trashBlobDir is the backend key prefix that holds trashed file contents.
+const trashBlobDir = "trash"
+
+// TrashEntry is a deleted file waiting to be restored or purged. Metadata is
+// the FileMetadata as it was when the file was deleted.
+type TrashEntry struct {
+	Metadata  FileMetadata `json:"metadata"`
+	DeletedAt time.Time    `json:"deleted_at"`
+	// BlobKey is where the contents were moved. It is empty when the blob was
+	// still referenced by a hard link and therefore left at StoredPath.
+	BlobKey string `json:"blob_key,omitempty"`
+}
+
+// TrashIndex persists trash entries in the embedded index store, keyed by hash.
+type TrashIndex struct {
+	store *kvStore
+	mu    sync.RWMutex
+}
+
+// NewTrashIndex opens the trash index in the store beside path.
+func NewTrashIndex(path string) (*TrashIndex, error) {
+	store, err := openKVStore(kvDirFor(path))
+	if err != nil {
+		return nil, err
+	}
+	return &TrashIndex{store: store}, nil
+}
+
+// Close releases the index store.
+func (idx *TrashIndex) Close() error {
+	return idx.store.release()
+}
+
+// Put records entry, replacing any previous entry with the same hash.
+func (idx *TrashIndex) Put(entry TrashEntry) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		return setJSON(txn, trashKeyPrefix+entry.Metadata.Hash, entry)
+	})
+}
+
+// Get returns the entry for hash, or nil if it is not in the trash.
+func (idx *TrashIndex) Get(hash string) (*TrashEntry, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var entry TrashEntry
+	var found bool
+	err := idx.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		found, err = getJSON(txn, trashKeyPrefix+hash, &entry)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Delete removes the entry for hash.
+func (idx *TrashIndex) Delete(hash string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(trashKeyPrefix + hash))
+	})
+}
+
+// List returns every entry, most recently deleted first.
+func (idx *TrashIndex) List() ([]TrashEntry, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	entries := make([]TrashEntry, 0)
+	err := idx.store.db.View(func(txn *badger.Txn) error {
+		return scanPrefix(txn, trashKeyPrefix, true, func(_ string, val []byte) (bool, error) {
+			var entry TrashEntry
+			if err := json.Unmarshal(val, &entry); err != nil {
+				return false, err
+			}
+			entries = append(entries, entry)
+			return true, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].DeletedAt.After(entries[j].DeletedAt) })
+	return entries, nil
+}
+
+// ListTrash returns the files currently in the trash, most recently deleted first.
+func (m *Manager) ListTrash() ([]TrashEntry, error) {
+	return m.trashIndex.List()
+}
+
+// RestoreResult surfaces the outcome of a restore operation.
+type RestoreResult struct {
+	Metadata   FileMetadata `json:"metadata"`
+	DeletedAt  time.Time    `json:"deleted_at"`
+	RestoredAt time.Time    `json:"restored_at"`
+}
+
+// RestoreFile moves a trashed file back to its original location and
+// re-registers its metadata. The restored file counts against the quota again.
+func (m *Manager) RestoreFile(hash string) (*RestoreResult, error) {
+	if hash == "" {
+		return nil, fmt.Errorf("%w: hash is required", ErrInvalidInput)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.trashIndex.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("%w: hash %s", ErrTrashNotFound, hash)
+	}
+	if m.index.FindByHash(hash) != nil {
+		return nil, fmt.Errorf("%w: hash %s is already stored", ErrRestoreConflict, hash)
+	}
+	if err := m.checkQuotaLocked(entry.Metadata.Size); err != nil {
+		return nil, err
+	}
+
+	meta := entry.Metadata
+	if entry.BlobKey != "" {
+		if _, err := m.backend.Stat(meta.StoredPath); err == nil {
+			return nil, fmt.Errorf("%w: %s is occupied", ErrRestoreConflict, meta.StoredPath)
+		}
+		if err := m.backend.Move(entry.BlobKey, meta.StoredPath); err != nil {
+			return nil, fmt.Errorf("failed to restore file contents: %w", err)
+		}
+	} else {
+		// The blob stayed in place for another hard link; it must still be there.
+		if _, err := m.backend.Stat(meta.StoredPath); err != nil {
+			return nil, fmt.Errorf("failed to restore file contents: %w", err)
+		}
+		if m.referenceIndex != nil {
+			_ = m.referenceIndex.AddReference(filepath.Join(m.root, meta.StoredPath), hash)
+		}
+	}
+
+	if err := m.index.Add(meta); err != nil {
+		if entry.BlobKey != "" {
+			_ = m.backend.Move(meta.StoredPath, entry.BlobKey)
+		}
+		return nil, fmt.Errorf("failed to restore metadata: %w", err)
+	}
+	m.reindexContentLocked(meta)
+	if err := m.trashIndex.Delete(hash); err != nil {
+		fmt.Fprintf(os.Stderr, "trash index cleanup failed for %s: %v\n", hash, err)
+	}
+
+	restoredAt := time.Now().UTC()
+	if err := m.logDelete(DeleteLog{
+		Action:       DeleteActionRestore,
+		Hash:         meta.Hash,
+		OriginalName: meta.OriginalName,
+		StoredPath:   meta.StoredPath,
+		Category:     meta.Category,
+		MimeType:     meta.MimeType,
+		Size:         meta.Size,
+		DeletedAt:    restoredAt,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "audit log restore failed: %v\n", err)
+	}
+
+	return &RestoreResult{Metadata: meta, DeletedAt: entry.DeletedAt, RestoredAt: restoredAt}, nil
+}
+
+// PurgeResult summarises a purge run.
+type PurgeResult struct {
+	Purged int   `json:"purged"`
+	Bytes  int64 `json:"bytes"`
+}
+
+// PurgeTrash permanently removes every trash entry deleted before cutoff.
+// Entries that fail to purge are left in place and retried on the next run.
+func (m *Manager) PurgeTrash(cutoff time.Time) (*PurgeResult, error) {
+	entries, err := m.trashIndex.List()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PurgeResult{}
+	var errs []error
+	for _, entry := range entries {
+		if !entry.DeletedAt.Before(cutoff) {
+			continue
+		}
+		purged, err := m.purgeEntry(entry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("purge %s: %w", entry.Metadata.Hash, err))
+			continue
+		}
+		if !purged {
+			continue
+		}
+		result.Purged++
+		result.Bytes += entry.Metadata.Size
+	}
+	return result, errors.Join(errs...)
+}
+
+// purgeEntry deletes the trashed contents of entry and forgets it. It reports
+// false when the entry changed since it was listed.
+func (m *Manager) purgeEntry(entry TrashEntry) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The entry may have been restored (and possibly deleted again) since it was listed.
+	current, err := m.trashIndex.Get(entry.Metadata.Hash)
+	if err != nil {
+		return false, err
+	}
+	if current == nil || !current.DeletedAt.Equal(entry.DeletedAt) {
+		return false, nil
+	}
+
+	if entry.BlobKey != "" {
+		if err := m.backend.Delete(entry.BlobKey); err != nil && !errors.Is(err, ErrBlobNotFound) {
+			return false, err
+		}
+	}
+	if err := m.deleteThumbnailsLocked(entry.Metadata.Hash); err != nil {
+		return false, err
+	}
+	if err := m.deleteExtractedTextLocked(entry.Metadata.Hash); err != nil {
+		return false, err
+	}
+	if err := m.forgetInFolders(entry.Metadata.Hash); err != nil {
+		return false, err
+	}
+	if err := m.trashIndex.Delete(entry.Metadata.Hash); err != nil {
+		return false, err
+	}
+
+	meta := entry.Metadata
+	if err := m.logDelete(DeleteLog{
+		Action:       DeleteActionPurge,
+		Hash:         meta.Hash,
+		OriginalName: meta.OriginalName,
+		StoredPath:   meta.StoredPath,
+		Category:     meta.Category,
+		MimeType:     meta.MimeType,
+		Size:         meta.Size,
+		DeletedAt:    time.Now().UTC(),
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "audit log purge failed: %v\n", err)
+	}
+	return true, nil
+}
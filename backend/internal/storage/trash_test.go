@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeleteMovesFileToTrashAndRestore(t *testing.T) {
+	root := t.TempDir()
+	manager, err := NewManager(root)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	defer manager.Close()
+
+	stored, err := storeText(t, manager, "report.txt", "quarterly numbers")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	hash := stored.Metadata.Hash
+	livePath := filepath.Join(root, filepath.FromSlash(stored.Metadata.StoredPath))
+
+	result, err := manager.DeleteFile(DeleteRequest{Hash: hash})
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if !result.Trashed {
+		t.Fatal("expected delete to move the file to trash")
+	}
+	if _, err := os.Stat(livePath); !os.IsNotExist(err) {
+		t.Fatalf("live file should be gone after delete: %v", err)
+	}
+	if manager.index.FindByHash(hash) != nil {
+		t.Fatal("metadata should leave the live index")
+	}
+
+	entries, err := manager.ListTrash()
+	if err != nil {
+		t.Fatalf("list trash: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Metadata.OriginalName != "report.txt" {
+		t.Fatalf("expected report.txt in trash, got %+v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(entries[0].BlobKey))); err != nil {
+		t.Fatalf("trashed contents missing: %v", err)
+	}
+
+	restored, err := manager.RestoreFile(hash)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.Metadata.StoredPath != stored.Metadata.StoredPath {
+		t.Fatalf("expected restore to original path %s, got %s", stored.Metadata.StoredPath, restored.Metadata.StoredPath)
+	}
+	data, err := os.ReadFile(livePath)
+	if err != nil || string(data) != "quarterly numbers" {
+		t.Fatalf("restored contents mismatch: %q, %v", data, err)
+	}
+	if manager.index.FindByHash(hash) == nil {
+		t.Fatal("metadata should be live again after restore")
+	}
+	if entries, _ := manager.ListTrash(); len(entries) != 0 {
+		t.Fatalf("trash should be empty after restore, got %d", len(entries))
+	}
+
+	if _, err := manager.RestoreFile(hash); !errors.Is(err, ErrTrashNotFound) {
+		t.Fatalf("expected ErrTrashNotFound, got %v", err)
+	}
+}
+
+func TestRestoreConflictsWithReuploadedContent(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	defer manager.Close()
+
+	stored, err := storeText(t, manager, "a.txt", "same")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if _, err := manager.DeleteFile(DeleteRequest{Hash: stored.Metadata.Hash}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	again, err := storeText(t, manager, "a.txt", "same")
+	if err != nil {
+		t.Fatalf("re-upload: %v", err)
+	}
+	if again.Duplicate {
+		t.Fatal("trashed files must not satisfy dedup")
+	}
+
+	if _, err := manager.RestoreFile(stored.Metadata.Hash); !errors.Is(err, ErrRestoreConflict) {
+		t.Fatalf("expected ErrRestoreConflict, got %v", err)
+	}
+}
+
+func TestPurgeTrashHonoursCutoff(t *testing.T) {
+	root := t.TempDir()
+	manager, err := NewManager(root)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	defer manager.Close()
+
+	stored, err := storeText(t, manager, "old.txt", "stale")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if _, err := manager.DeleteFile(DeleteRequest{Hash: stored.Metadata.Hash}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	entries, _ := manager.ListTrash()
+	trashPath := filepath.Join(root, filepath.FromSlash(entries[0].BlobKey))
+
+	result, err := manager.PurgeTrash(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if result.Purged != 0 {
+		t.Fatalf("nothing should be old enough to purge, got %d", result.Purged)
+	}
+
+	result, err = manager.PurgeTrash(time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if result.Purged != 1 || result.Bytes != int64(len("stale")) {
+		t.Fatalf("expected 1 purged file of 5 bytes, got %+v", result)
+	}
+	if _, err := os.Stat(trashPath); !os.IsNotExist(err) {
+		t.Fatalf("purged contents should be removed: %v", err)
+	}
+	if _, err := manager.RestoreFile(stored.Metadata.Hash); !errors.Is(err, ErrTrashNotFound) {
+		t.Fatalf("expected ErrTrashNotFound after purge, got %v", err)
+	}
+}
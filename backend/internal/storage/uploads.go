@@ -0,0 +1,472 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/google/uuid"
+)
+
+const (
+	// uploadKeyPrefix namespaces completed upload records inside the shared
+	// index store. Staged uploads keep their session file in storage/.tmp.
+	uploadKeyPrefix = "upload:"
+	// completedUploadTTL is how long the record of a completed upload stays
+	// readable through GetUpload.
+	completedUploadTTL = 24 * time.Hour
+	// uploadURLKeyPrefix namespaces the records of presigned upload URLs
+	// that have been used.
+	uploadURLKeyPrefix = "upload-url:"
+)
+
+var (
+	// ErrUploadNotFound is returned when a resumable upload ID is unknown or was terminated.
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrUploadOffsetMismatch is returned when a chunk does not start at the current upload offset.
+	ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+	// ErrUploadTooLarge is returned when a chunk would exceed the declared upload length.
+	ErrUploadTooLarge = errors.New("upload exceeds declared length")
+	// ErrUploadCompleted is returned when writing to an upload that has already been stored.
+	ErrUploadCompleted = errors.New("upload already completed")
+	// ErrUploadURLUsed is returned when a single-use presigned upload URL is used again.
+	ErrUploadURLUsed = errors.New("upload URL has already been used")
+)
+
+// UploadRequest captures parameters for starting a resumable upload.
+type UploadRequest struct {
+	Length       int64
+	Filename     string
+	MimeType     string
+	CategoryHint string
+	Metadata     map[string]string
+}
+
+// UploadSession tracks a resumable upload staged under storage/.tmp.
+type UploadSession struct {
+	ID           string            `json:"id"`
+	Length       int64             `json:"length"`
+	Offset       int64             `json:"offset"`
+	Filename     string            `json:"filename"`
+	MimeType     string            `json:"mime_type,omitempty"`
+	CategoryHint string            `json:"category_hint,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	Completed    bool              `json:"completed"`
+	Duplicate    bool              `json:"duplicate,omitempty"`
+	Result       *FileMetadata     `json:"result,omitempty"`
+}
+
+// uploadLocks serializes chunk writes per upload ID so concurrent PATCH
+// requests for the same upload cannot interleave. Entries are dropped once
+// an upload is completed, terminated or expired; later callers only see that
+// final state, so a fresh mutex for them is harmless.
+var uploadLocks sync.Map // id -> *sync.Mutex
+
+func lockUpload(id string) func() {
+	mu, _ := uploadLocks.LoadOrStore(id, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// uploadPaths returns the staged data and session files for id. Both live
+// directly in storage/.tmp, which the dedup verifier already skips.
+func (m *Manager) uploadPaths(id string) (dataPath, infoPath string, err error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return "", "", fmt.Errorf("%w: id=%s", ErrUploadNotFound, id)
+	}
+	base := filepath.Join(m.storageRoot, ".tmp", "upload_"+id)
+	return base, base + ".json", nil
+}
+
+// CreateUpload registers a new resumable upload and reserves its staging file.
+func (m *Manager) CreateUpload(req UploadRequest) (*UploadSession, error) {
+	if req.Length < 0 {
+		return nil, fmt.Errorf("%w: upload length must not be negative", ErrInvalidInput)
+	}
+	// Fail fast instead of after the last chunk; StoreFile checks again on completion.
+	m.mu.Lock()
+	quotaErr := m.checkQuotaLocked(req.Length)
+	m.mu.Unlock()
+	if quotaErr != nil {
+		return nil, quotaErr
+	}
+	if err := os.MkdirAll(filepath.Join(m.storageRoot, ".tmp"), 0o755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	session := &UploadSession{
+		ID:           uuid.NewString(),
+		Length:       req.Length,
+		Filename:     req.Filename,
+		MimeType:     req.MimeType,
+		CategoryHint: req.CategoryHint,
+		Metadata:     req.Metadata,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	dataPath, _, err := m.uploadPaths(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(dataPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(dataPath)
+		return nil, err
+	}
+	if err := m.saveUpload(session); err != nil {
+		_ = os.Remove(dataPath)
+		return nil, err
+	}
+	// An empty upload is complete as soon as it exists.
+	if session.Length == 0 {
+		if err := m.completeUpload(session); err != nil {
+			return session, err
+		}
+	}
+	return session, nil
+}
+
+// GetUpload returns the current state of a resumable upload.
+func (m *Manager) GetUpload(id string) (*UploadSession, error) {
+	_, infoPath, err := m.uploadPaths(id)
+	if err != nil {
+		return nil, err
+	}
+	if session, err := m.completedUpload(id); err != nil || session != nil {
+		return session, err
+	}
+	raw, err := os.ReadFile(infoPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: id=%s", ErrUploadNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session UploadSession
+	if err := json.Unmarshal(raw, &session); err != nil {
+		return nil, fmt.Errorf("decode upload %s: %w", id, err)
+	}
+	return &session, nil
+}
+
+// completedUpload returns the record of a completed upload, or nil when
+// there is none.
+func (m *Manager) completedUpload(id string) (*UploadSession, error) {
+	var session *UploadSession
+	err := m.index.store.db.View(func(txn *badger.Txn) error {
+		var stored UploadSession
+		found, err := getJSON(txn, uploadKeyPrefix+id, &stored)
+		if found {
+			session = &stored
+		}
+		return err
+	})
+	return session, err
+}
+
+// saveUpload persists session state atomically.
+func (m *Manager) saveUpload(session *UploadSession) error {
+	_, infoPath, err := m.uploadPaths(session.ID)
+	if err != nil {
+		return err
+	}
+	buf, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := infoPath + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, infoPath)
+}
+
+// WriteUploadChunk appends r to the upload at offset. Bytes received before a
+// dropped connection are kept, so the client can resume from the returned offset.
+// Once the declared length is reached the staged file is handed to StoreFile.
+func (m *Manager) WriteUploadChunk(id string, offset int64, r io.Reader) (*UploadSession, error) {
+	unlock := lockUpload(id)
+	defer unlock()
+
+	session, err := m.GetUpload(id)
+	if err != nil {
+		if errors.Is(err, ErrUploadNotFound) {
+			uploadLocks.Delete(id)
+		}
+		return nil, err
+	}
+	if session.Completed {
+		uploadLocks.Delete(id)
+		return session, ErrUploadCompleted
+	}
+	if offset != session.Offset {
+		return session, fmt.Errorf("%w: expected %d, got %d", ErrUploadOffsetMismatch, session.Offset, offset)
+	}
+
+	dataPath, _, err := m.uploadPaths(id)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(dataPath, os.O_WRONLY, 0o644)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: id=%s", ErrUploadNotFound, id)
+		}
+		return nil, err
+	}
+	// Drop anything past the recorded offset left behind by a crash between
+	// writing data and saving the session.
+	if err := file.Truncate(session.Offset); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(session.Offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	remaining := session.Length - session.Offset
+	written, copyErr := io.Copy(file, io.LimitReader(r, remaining))
+	if copyErr == nil && written == remaining {
+		// Reject bodies that run past the declared length.
+		var probe [1]byte
+		if n, _ := r.Read(probe[:]); n > 0 {
+			copyErr = ErrUploadTooLarge
+		}
+	}
+	syncErr := file.Sync()
+	closeErr := file.Close()
+	if syncErr != nil || closeErr != nil {
+		return nil, errors.Join(syncErr, closeErr)
+	}
+
+	session.Offset += written
+	session.UpdatedAt = time.Now().UTC()
+	if err := m.saveUpload(session); err != nil {
+		return nil, err
+	}
+	if copyErr != nil {
+		return session, copyErr
+	}
+
+	if session.Offset == session.Length {
+		if err := m.completeUpload(session); err != nil {
+			return session, err
+		}
+		uploadLocks.Delete(id)
+	}
+	return session, nil
+}
+
+// completeUpload stores the staged file through the regular StoreFile path so
+// classification, routing rules and deduplication apply unchanged. The
+// session then moves from storage/.tmp to a record in the index store that
+// expires after completedUploadTTL.
+func (m *Manager) completeUpload(session *UploadSession) error {
+	dataPath, infoPath, err := m.uploadPaths(session.ID)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(dataPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	mimeType := session.MimeType
+	if mimeType == "" {
+		sniff := make([]byte, 512)
+		n, _ := io.ReadFull(file, sniff)
+		mimeType = http.DetectContentType(sniff[:n])
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	result, err := m.StoreFile(StoreRequest{
+		Reader:       file,
+		Filename:     session.Filename,
+		MimeType:     mimeType,
+		Size:         session.Length,
+		Metadata:     session.Metadata,
+		CategoryHint: session.CategoryHint,
+	})
+	if err != nil {
+		return err
+	}
+
+	session.Completed = true
+	session.Duplicate = result.Duplicate
+	session.Result = &result.Metadata
+	session.UpdatedAt = time.Now().UTC()
+	buf, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	err = m.index.store.db.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry([]byte(uploadKeyPrefix+session.ID), buf).WithTTL(completedUploadTTL))
+	})
+	if err != nil {
+		return err
+	}
+	_ = os.Remove(dataPath)
+	_ = os.Remove(infoPath)
+	return nil
+}
+
+// TerminateUpload discards a resumable upload and its staged data.
+func (m *Manager) TerminateUpload(id string) error {
+	unlock := lockUpload(id)
+	defer unlock()
+
+	dataPath, infoPath, err := m.uploadPaths(id)
+	if err != nil {
+		return err
+	}
+	completed, err := m.completedUpload(id)
+	if err != nil {
+		return err
+	}
+	if completed != nil {
+		err := m.index.store.db.Update(func(txn *badger.Txn) error {
+			return txn.Delete([]byte(uploadKeyPrefix + id))
+		})
+		if err != nil {
+			return err
+		}
+	} else if _, err := os.Stat(infoPath); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: id=%s", ErrUploadNotFound, id)
+	}
+	if err := removeStagedUpload(dataPath, infoPath); err != nil {
+		return err
+	}
+	uploadLocks.Delete(id)
+	return nil
+}
+
+// removeStagedUpload deletes the staged data and session files of an upload.
+func removeStagedUpload(dataPath, infoPath string) error {
+	for _, path := range []string{dataPath, infoPath, infoPath + ".tmp"} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpireUploads discards uploads that were not written to since cutoff,
+// along with staged files left behind without a session, and returns how
+// many uploads it removed.
+func (m *Manager) ExpireUploads(cutoff time.Time) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(m.storageRoot, ".tmp"))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	ids := make(map[string]bool)
+	for _, entry := range entries {
+		name, ok := strings.CutPrefix(entry.Name(), "upload_")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		name = strings.TrimSuffix(strings.TrimSuffix(name, ".tmp"), ".json")
+		if _, err := uuid.Parse(name); err == nil {
+			ids[name] = true
+		}
+	}
+
+	expired := 0
+	var errs []error
+	for id := range ids {
+		removed, err := m.expireUpload(id, cutoff)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("upload %s: %w", id, err))
+		} else if removed {
+			expired++
+		}
+	}
+	return expired, errors.Join(errs...)
+}
+
+// expireUpload removes the staged upload id when it was last written before
+// cutoff. Uploads without a readable session go by the modification time of
+// their staged files.
+func (m *Manager) expireUpload(id string, cutoff time.Time) (bool, error) {
+	unlock := lockUpload(id)
+	defer unlock()
+
+	dataPath, infoPath, err := m.uploadPaths(id)
+	if err != nil {
+		return false, err
+	}
+	var updated time.Time
+	if raw, err := os.ReadFile(infoPath); err == nil {
+		var session UploadSession
+		if json.Unmarshal(raw, &session) == nil {
+			updated = session.UpdatedAt
+		}
+	}
+	if updated.IsZero() {
+		for _, path := range []string{dataPath, infoPath, infoPath + ".tmp"} {
+			if info, err := os.Stat(path); err == nil && info.ModTime().After(updated) {
+				updated = info.ModTime()
+			}
+		}
+	}
+	if updated.IsZero() || !updated.Before(cutoff) {
+		return false, nil
+	}
+	if err := removeStagedUpload(dataPath, infoPath); err != nil {
+		return false, err
+	}
+	uploadLocks.Delete(id)
+	return true, nil
+}
+
+// ClaimUploadURL marks the presigned upload URL identified by nonce as used,
+// failing with ErrUploadURLUsed when it already is. The record is kept until
+// the URL expires, after which the URL is refused anyway.
+func (m *Manager) ClaimUploadURL(nonce string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	key := []byte(uploadURLKeyPrefix + nonce)
+	err := m.index.store.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(key); err == nil {
+			return ErrUploadURLUsed
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry(key, nil).WithTTL(ttl))
+	})
+	if errors.Is(err, badger.ErrConflict) {
+		// A concurrent request claimed it first.
+		return ErrUploadURLUsed
+	}
+	return err
+}
+
+// ReleaseUploadURL drops the claim on a presigned upload URL whose upload
+// failed, so it can be retried.
+func (m *Manager) ReleaseUploadURL(nonce string) error {
+	return m.index.store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(uploadURLKeyPrefix + nonce))
+	})
+}
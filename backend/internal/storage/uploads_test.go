@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// droppingReader returns data and then fails, like a client connection that drops mid-chunk.
+type droppingReader struct {
+	data []byte
+}
+
+func (r *droppingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestUploadResumeAfterDroppedConnection(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	defer manager.Close()
+
+	content := []byte("0123456789abcdefghij")
+	session, err := manager.CreateUpload(UploadRequest{Length: int64(len(content)), Filename: "resume.txt", MimeType: "text/plain"})
+	if err != nil {
+		t.Fatalf("create upload: %v", err)
+	}
+
+	// The connection drops after 7 bytes; those bytes are kept.
+	session, err = manager.WriteUploadChunk(session.ID, 0, &droppingReader{data: content[:7]})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected dropped connection error, got %v", err)
+	}
+	if session.Offset != 7 {
+		t.Fatalf("expected offset 7 after drop, got %d", session.Offset)
+	}
+
+	session, err = manager.WriteUploadChunk(session.ID, 7, bytes.NewReader(content[7:]))
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if !session.Completed || session.Result == nil {
+		t.Fatalf("upload should be completed: %+v", session)
+	}
+	if session.Result.Category != "documents/txt" {
+		t.Errorf("expected classification documents/txt, got %s", session.Result.Category)
+	}
+
+	data, err := os.ReadFile(filepath.Join(manager.Root(), filepath.FromSlash(session.Result.StoredPath)))
+	if err != nil {
+		t.Fatalf("read stored file: %v", err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("stored content mismatch: %q", data)
+	}
+	dataPath, infoPath, _ := manager.uploadPaths(session.ID)
+	for _, path := range []string{dataPath, infoPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed after completion", filepath.Base(path))
+		}
+	}
+	if _, ok := uploadLocks.Load(session.ID); ok {
+		t.Error("upload lock should be released after completion")
+	}
+	if got, err := manager.GetUpload(session.ID); err != nil || !got.Completed || got.Result == nil || got.Result.Hash != session.Result.Hash {
+		t.Fatalf("completed upload should stay readable: %+v %v", got, err)
+	}
+
+	if _, err := manager.WriteUploadChunk(session.ID, session.Offset, bytes.NewReader(nil)); !errors.Is(err, ErrUploadCompleted) {
+		t.Errorf("expected ErrUploadCompleted, got %v", err)
+	}
+}
+
+func TestUploadRejectsUnknownID(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	defer manager.Close()
+
+	for _, id := range []string{"../../etc/passwd", "not-a-uuid", "0b8f1d7e-6a55-4c67-9f55-2a0f3b3d2c11"} {
+		if _, err := manager.GetUpload(id); !errors.Is(err, ErrUploadNotFound) {
+			t.Errorf("GetUpload(%q): expected ErrUploadNotFound, got %v", id, err)
+		}
+	}
+}
+
+func TestExpireUploads(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	defer manager.Close()
+
+	idle, err := manager.CreateUpload(UploadRequest{Length: 10, Filename: "idle.txt"})
+	if err != nil {
+		t.Fatalf("create upload: %v", err)
+	}
+	if _, err := manager.WriteUploadChunk(idle.ID, 0, bytes.NewReader([]byte("01234"))); err != nil {
+		t.Fatalf("write chunk: %v", err)
+	}
+	done, err := manager.CreateUpload(UploadRequest{Length: 4, Filename: "done.txt"})
+	if err != nil {
+		t.Fatalf("create upload: %v", err)
+	}
+	if _, err := manager.WriteUploadChunk(done.ID, 0, bytes.NewReader([]byte("done"))); err != nil {
+		t.Fatalf("complete upload: %v", err)
+	}
+	// Staged data left behind without a session, as after a crash in CreateUpload.
+	stray, _, _ := manager.uploadPaths("6f1c2a8e-3d4b-4c5a-9e7f-0a1b2c3d4e5f")
+	if err := os.WriteFile(stray, []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(stray, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := manager.ExpireUploads(time.Now().Add(-time.Hour)); err != nil || n != 1 {
+		t.Fatalf("expected only the stray upload to expire, got %d %v", n, err)
+	}
+	if _, err := os.Stat(stray); !os.IsNotExist(err) {
+		t.Error("stray staged data should be removed")
+	}
+	if _, err := manager.GetUpload(idle.ID); err != nil {
+		t.Fatalf("recent upload should be kept: %v", err)
+	}
+
+	if n, err := manager.ExpireUploads(time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("expected the idle upload to expire, got %d %v", n, err)
+	}
+	if _, err := manager.GetUpload(idle.ID); !errors.Is(err, ErrUploadNotFound) {
+		t.Fatalf("expected ErrUploadNotFound after expiry, got %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(manager.storageRoot, ".tmp"))
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected an empty staging directory, got %v %v", entries, err)
+	}
+	if got, err := manager.GetUpload(done.ID); err != nil || !got.Completed {
+		t.Fatalf("completed upload should not be expired: %+v %v", got, err)
+	}
+}
+
+func TestClaimUploadURL(t *testing.T) {
+	m := newTestManager(t)
+	expires := time.Now().Add(time.Hour)
+	if err := m.ClaimUploadURL("nonce-1", expires); err != nil {
+		t.Fatalf("first claim: %v", err)
+	}
+	if err := m.ClaimUploadURL("nonce-1", expires); !errors.Is(err, ErrUploadURLUsed) {
+		t.Fatalf("expected ErrUploadURLUsed on reuse, got %v", err)
+	}
+	if err := m.ClaimUploadURL("nonce-2", expires); err != nil {
+		t.Fatalf("other URL: %v", err)
+	}
+	if err := m.ReleaseUploadURL("nonce-1"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err := m.ClaimUploadURL("nonce-1", expires); err != nil {
+		t.Fatalf("claim after release: %v", err)
+	}
+}
@@ -4,11 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
 	"sync"
 	"time"
+
+	"github.com/dgraph-io/badger/v4"
 )
 
 var (
@@ -37,91 +37,106 @@ type VersionChain struct {
 	UpdatedAt      time.Time         `json:"updated_at"`
 }
 
+// versionKeyPrefix namespaces version chains inside the shared index store.
+const versionKeyPrefix = "ver:"
+
 // VersionIndex manages version chains for files
 type VersionIndex struct {
-	path string
-	mu   sync.RWMutex
-	data map[string]*VersionChain // file_id -> VersionChain
+	path  string
+	store *kvStore
+	mu    sync.RWMutex
 }
 
-// NewVersionIndex creates a new version index
+// NewVersionIndex creates a new version index backed by the shared index store.
+// A legacy versions.json at path is imported once.
 func NewVersionIndex(path string) (*VersionIndex, error) {
-	idx := &VersionIndex{
-		path: path,
-		data: make(map[string]*VersionChain),
+	store, err := openKVStore(kvDirFor(path))
+	if err != nil {
+		return nil, err
 	}
-	if err := idx.load(); err != nil {
+	idx := &VersionIndex{path: path, store: store}
+	if err := migrateLegacyJSON(store, path, idx.importJSON); err != nil {
+		_ = store.release()
 		return nil, err
 	}
 	return idx, nil
 }
 
-// load reads version chains from disk
-func (idx *VersionIndex) load() error {
-	dir := filepath.Dir(idx.path)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return err
-	}
-
-	raw, err := os.ReadFile(idx.path)
-	if errors.Is(err, os.ErrNotExist) {
-		return nil
-	}
-	if err != nil {
-		return err
-	}
-
-	if len(raw) == 0 {
-		return nil
-	}
-
+// importJSON loads the legacy versions.json array format.
+func (idx *VersionIndex) importJSON(raw []byte, w kvWriter) error {
 	var chains []VersionChain
 	if err := json.Unmarshal(raw, &chains); err != nil {
 		return err
 	}
-
-	for i := range chains {
-		chain := chains[i]
-		idx.data[chain.FileID] = &chain
+	for _, chain := range chains {
+		if err := setJSON(w, versionKeyPrefix+chain.FileID, chain); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// persistLocked writes version chains to disk
-func (idx *VersionIndex) persistLocked() error {
-	chains := make([]VersionChain, 0, len(idx.data))
-	for _, chain := range idx.data {
-		chains = append(chains, *chain)
-	}
+// Close releases the index store.
+func (idx *VersionIndex) Close() error {
+	return idx.store.release()
+}
 
-	tmp := idx.path + ".tmp"
-	buf, err := json.MarshalIndent(chains, "", "  ")
-	if err != nil {
-		return err
-	}
-	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
-		return err
+// getChain loads a chain inside txn, returning nil when it does not exist.
+func (idx *VersionIndex) getChain(txn *badger.Txn, fileID string) (*VersionChain, error) {
+	var chain VersionChain
+	found, err := getJSON(txn, versionKeyPrefix+fileID, &chain)
+	if err != nil || !found {
+		return nil, err
 	}
-	return os.Rename(tmp, idx.path)
+	return &chain, nil
 }
 
-// GetVersionChain retrieves a version chain by file ID
-func (idx *VersionIndex) GetVersionChain(fileID string) (*VersionChain, error) {
+// view runs fn against the chain for fileID in a read-only transaction.
+func (idx *VersionIndex) view(fileID string, fn func(chain *VersionChain) error) error {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
+	return idx.store.db.View(func(txn *badger.Txn) error {
+		chain, err := idx.getChain(txn, fileID)
+		if err != nil {
+			return err
+		}
+		if chain == nil {
+			return fmt.Errorf("%w: file_id=%s", ErrFileNotFound, fileID)
+		}
+		return fn(chain)
+	})
+}
 
-	chain, ok := idx.data[fileID]
-	if !ok {
-		return nil, fmt.Errorf("%w: file_id=%s", ErrFileNotFound, fileID)
-	}
-
-	// Return a deep copy
-	chainCopy := *chain
-	versionsCopy := make([]VersionMetadata, len(chain.Versions))
-	copy(versionsCopy, chain.Versions)
-	chainCopy.Versions = versionsCopy
+// update runs fn against the chain for fileID and persists it when fn succeeds.
+func (idx *VersionIndex) update(fileID string, fn func(chain *VersionChain) error) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.store.db.Update(func(txn *badger.Txn) error {
+		chain, err := idx.getChain(txn, fileID)
+		if err != nil {
+			return err
+		}
+		if chain == nil {
+			return fmt.Errorf("%w: file_id=%s", ErrFileNotFound, fileID)
+		}
+		if err := fn(chain); err != nil {
+			return err
+		}
+		return setJSON(txn, versionKeyPrefix+fileID, chain)
+	})
+}
 
-	return &chainCopy, nil
+// GetVersionChain retrieves a version chain by file ID
+func (idx *VersionIndex) GetVersionChain(fileID string) (*VersionChain, error) {
+	var result *VersionChain
+	err := idx.view(fileID, func(chain *VersionChain) error {
+		result = chain
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // CreateVersionChain creates a new version chain for a file
@@ -129,11 +144,6 @@ func (idx *VersionIndex) CreateVersionChain(fileID string, initialHash string, i
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	// Check if chain already exists
-	if _, exists := idx.data[fileID]; exists {
-		return nil, fmt.Errorf("version chain already exists for file_id=%s", fileID)
-	}
-
 	now := time.Now().UTC()
 	chain := &VersionChain{
 		FileID:         fileID,
@@ -153,8 +163,18 @@ func (idx *VersionIndex) CreateVersionChain(fileID string, initialHash string, i
 		UpdatedAt: now,
 	}
 
-	idx.data[fileID] = chain
-	if err := idx.persistLocked(); err != nil {
+	err := idx.store.db.Update(func(txn *badger.Txn) error {
+		// Check if chain already exists
+		existing, err := idx.getChain(txn, fileID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return fmt.Errorf("version chain already exists for file_id=%s", fileID)
+		}
+		return setJSON(txn, versionKeyPrefix+fileID, chain)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -164,40 +184,35 @@ func (idx *VersionIndex) CreateVersionChain(fileID string, initialHash string, i
 
 // AddVersion adds a new version to an existing chain
 func (idx *VersionIndex) AddVersion(fileID string, hash string, size int64, uploadedBy string, comment string, maxVersions int) (*VersionMetadata, error) {
-	idx.mu.Lock()
-	defer idx.mu.Unlock()
-
-	chain, ok := idx.data[fileID]
-	if !ok {
-		return nil, fmt.Errorf("%w: file_id=%s", ErrFileNotFound, fileID)
-	}
-
-	// Check version limit
-	if maxVersions > 0 && len(chain.Versions) >= maxVersions {
-		return nil, fmt.Errorf("%w: max versions (%d) reached", ErrVersionLimitReached, maxVersions)
-	}
-
-	// Mark current version as not current
-	for i := range chain.Versions {
-		chain.Versions[i].IsCurrent = false
-	}
+	var newVersion VersionMetadata
+	err := idx.update(fileID, func(chain *VersionChain) error {
+		// Check version limit
+		if maxVersions > 0 && len(chain.Versions) >= maxVersions {
+			return fmt.Errorf("%w: max versions (%d) reached", ErrVersionLimitReached, maxVersions)
+		}
 
-	// Create new version
-	newVersion := VersionMetadata{
-		Version:    chain.CurrentVersion + 1,
-		Hash:       hash,
-		Size:       size,
-		UploadedAt: time.Now().UTC(),
-		UploadedBy: uploadedBy,
-		Comment:    comment,
-		IsCurrent:  true,
-	}
+		// Mark current version as not current
+		for i := range chain.Versions {
+			chain.Versions[i].IsCurrent = false
+		}
 
-	chain.Versions = append(chain.Versions, newVersion)
-	chain.CurrentVersion = newVersion.Version
-	chain.UpdatedAt = time.Now().UTC()
+		// Create new version
+		newVersion = VersionMetadata{
+			Version:    chain.CurrentVersion + 1,
+			Hash:       hash,
+			Size:       size,
+			UploadedAt: time.Now().UTC(),
+			UploadedBy: uploadedBy,
+			Comment:    comment,
+			IsCurrent:  true,
+		}
 
-	if err := idx.persistLocked(); err != nil {
+		chain.Versions = append(chain.Versions, newVersion)
+		chain.CurrentVersion = newVersion.Version
+		chain.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -206,37 +221,34 @@ func (idx *VersionIndex) AddVersion(fileID string, hash string, size int64, uplo
 
 // GetVersion retrieves a specific version by file ID and version number
 func (idx *VersionIndex) GetVersion(fileID string, versionNumber int) (*VersionMetadata, error) {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
-
-	chain, ok := idx.data[fileID]
-	if !ok {
-		return nil, fmt.Errorf("%w: file_id=%s", ErrFileNotFound, fileID)
-	}
-
-	for i := range chain.Versions {
-		if chain.Versions[i].Version == versionNumber {
-			versionCopy := chain.Versions[i]
-			return &versionCopy, nil
+	var result *VersionMetadata
+	err := idx.view(fileID, func(chain *VersionChain) error {
+		for i := range chain.Versions {
+			if chain.Versions[i].Version == versionNumber {
+				versionCopy := chain.Versions[i]
+				result = &versionCopy
+				return nil
+			}
 		}
+		return fmt.Errorf("%w: version %d for file_id=%s", ErrVersionNotFound, versionNumber, fileID)
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return nil, fmt.Errorf("%w: version %d for file_id=%s", ErrVersionNotFound, versionNumber, fileID)
+	return result, nil
 }
 
 // ListVersions returns all versions for a file, sorted by version number (descending)
 func (idx *VersionIndex) ListVersions(fileID string) ([]VersionMetadata, error) {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
-
-	chain, ok := idx.data[fileID]
-	if !ok {
-		return nil, fmt.Errorf("%w: file_id=%s", ErrFileNotFound, fileID)
+	var versions []VersionMetadata
+	err := idx.view(fileID, func(chain *VersionChain) error {
+		versions = chain.Versions
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	versions := make([]VersionMetadata, len(chain.Versions))
-	copy(versions, chain.Versions)
-
 	// Sort by version number descending (newest first)
 	sort.Slice(versions, func(i, j int) bool {
 		return versions[i].Version > versions[j].Version
@@ -247,58 +259,51 @@ func (idx *VersionIndex) ListVersions(fileID string) ([]VersionMetadata, error)
 
 // RevertToVersion sets a previous version as the current version
 func (idx *VersionIndex) RevertToVersion(fileID string, versionNumber int, comment string) (*VersionMetadata, error) {
-	idx.mu.Lock()
-	defer idx.mu.Unlock()
-
-	chain, ok := idx.data[fileID]
-	if !ok {
-		return nil, fmt.Errorf("%w: file_id=%s", ErrFileNotFound, fileID)
-	}
-
-	// Find the version to revert to
-	var targetVersion *VersionMetadata
-	for i := range chain.Versions {
-		if chain.Versions[i].Version == versionNumber {
-			targetVersion = &chain.Versions[i]
-			break
+	var result VersionMetadata
+	err := idx.update(fileID, func(chain *VersionChain) error {
+		// Find the version to revert to
+		var targetVersion *VersionMetadata
+		for i := range chain.Versions {
+			if chain.Versions[i].Version == versionNumber {
+				targetVersion = &chain.Versions[i]
+				break
+			}
 		}
-	}
 
-	if targetVersion == nil {
-		return nil, fmt.Errorf("%w: version %d for file_id=%s", ErrVersionNotFound, versionNumber, fileID)
-	}
+		if targetVersion == nil {
+			return fmt.Errorf("%w: version %d for file_id=%s", ErrVersionNotFound, versionNumber, fileID)
+		}
 
-	// Mark all versions as not current
-	for i := range chain.Versions {
-		chain.Versions[i].IsCurrent = false
-	}
+		// Mark all versions as not current
+		for i := range chain.Versions {
+			chain.Versions[i].IsCurrent = false
+		}
 
-	// Mark target version as current
-	targetVersion.IsCurrent = true
-	chain.CurrentVersion = versionNumber
-	chain.UpdatedAt = time.Now().UTC()
+		// Mark target version as current
+		targetVersion.IsCurrent = true
+		chain.CurrentVersion = versionNumber
+		chain.UpdatedAt = time.Now().UTC()
 
-	// Update comment if provided
-	if comment != "" {
-		targetVersion.Comment = comment
-	}
+		// Update comment if provided
+		if comment != "" {
+			targetVersion.Comment = comment
+		}
 
-	if err := idx.persistLocked(); err != nil {
+		result = *targetVersion
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	versionCopy := *targetVersion
-	return &versionCopy, nil
+	return &result, nil
 }
 
 // GetVersionDiff returns metadata differences between two versions
 func (idx *VersionIndex) GetVersionDiff(fileID string, fromVersion, toVersion int) (map[string]any, error) {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
-
-	chain, ok := idx.data[fileID]
-	if !ok {
-		return nil, fmt.Errorf("%w: file_id=%s", ErrFileNotFound, fileID)
+	chain, err := idx.GetVersionChain(fileID)
+	if err != nil {
+		return nil, err
 	}
 
 	var fromMeta, toMeta *VersionMetadata
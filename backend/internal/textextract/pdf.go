@@ -0,0 +1,1117 @@
+package textextract
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// The PDF reader below is deliberately small. It does not trust the xref
+// table, which is often damaged, and instead scans the file for "N G obj"
+// headers, unpacks object streams, walks the page tree and interprets the
+// text operators of each content stream. Fonts are decoded through their
+// ToUnicode CMap when they have one, and as WinAnsi otherwise; composite
+// fonts without a CMap cannot be mapped to text and are skipped.
+
+// Object values produced by pdfLexer.object. Numbers are float64, strings
+// are pdfString, arrays []any and dictionaries map[string]any keyed without
+// the leading slash.
+type (
+	pdfName    string
+	pdfString  string
+	pdfKeyword string
+	pdfRef     struct{ num, gen int }
+)
+
+// maxObjectDepth bounds nesting of arrays, dictionaries and references.
+const maxObjectDepth = 32
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isPDFDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// token reads one primitive token. Delimiters come back as pdfKeyword.
+func (l *pdfLexer) token() (any, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, false
+	}
+	c := l.data[l.pos]
+	switch c {
+	case '(':
+		l.pos++
+		return l.literalString(), true
+	case '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return pdfKeyword("<<"), true
+		}
+		l.pos++
+		return l.hexString(), true
+	case '>':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return pdfKeyword(">>"), true
+		}
+		l.pos++
+		return pdfKeyword(">"), true
+	case '[', ']', '{', '}', ')':
+		l.pos++
+		return pdfKeyword(string(c)), true
+	case '/':
+		l.pos++
+		return pdfName(l.name()), true
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	switch {
+	case word == "true":
+		return true, true
+	case word == "false":
+		return false, true
+	case word == "null":
+		return nil, true
+	case strings.ContainsAny(word[:1], "0123456789+-."):
+		if f, err := strconv.ParseFloat(word, 64); err == nil {
+			return f, true
+		}
+	}
+	return pdfKeyword(word), true
+}
+
+// name reads a name after its slash, decoding #xx escapes.
+func (l *pdfLexer) name() string {
+	var b strings.Builder
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+		c := l.data[l.pos]
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		b.WriteByte(c)
+		l.pos++
+	}
+	return b.String()
+}
+
+// literalString reads a parenthesised string after its opening parenthesis.
+func (l *pdfLexer) literalString() pdfString {
+	var b []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(b)
+			}
+		case '\r':
+			// Raw end-of-line markers read as a single line feed.
+			if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+				l.pos++
+			}
+			c = '\n'
+		case '\\':
+			if l.pos >= len(l.data) {
+				continue
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return pdfString(b)
+}
+
+// hexString reads a hexadecimal string after its opening angle bracket.
+func (l *pdfLexer) hexString() pdfString {
+	var digits []byte
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		if c == '>' {
+			break
+		}
+		if isHexDigit(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	_, _ = hex.Decode(out, digits)
+	return pdfString(out)
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// object reads a complete object: arrays and dictionaries are assembled and
+// "N G R" becomes a pdfRef. Operators come back as pdfKeyword.
+func (l *pdfLexer) object(depth int) (any, bool) {
+	tok, ok := l.token()
+	if !ok || depth > maxObjectDepth {
+		return nil, false
+	}
+	switch t := tok.(type) {
+	case pdfKeyword:
+		switch t {
+		case "[":
+			arr := []any{}
+			for {
+				l.skipSpace()
+				if l.pos >= len(l.data) {
+					return arr, true
+				}
+				if l.data[l.pos] == ']' {
+					l.pos++
+					return arr, true
+				}
+				v, ok := l.object(depth + 1)
+				if !ok {
+					return arr, true
+				}
+				arr = append(arr, v)
+			}
+		case "<<":
+			dict := map[string]any{}
+			for {
+				l.skipSpace()
+				if l.pos >= len(l.data) {
+					return dict, true
+				}
+				if bytes.HasPrefix(l.data[l.pos:], []byte(">>")) {
+					l.pos += 2
+					return dict, true
+				}
+				key, ok := l.object(depth + 1)
+				if !ok {
+					return dict, true
+				}
+				name, isName := key.(pdfName)
+				if !isName {
+					continue
+				}
+				value, ok := l.object(depth + 1)
+				if !ok {
+					return dict, true
+				}
+				dict[string(name)] = value
+			}
+		}
+	case float64:
+		// Look ahead for "gen R" to form an indirect reference.
+		if t == float64(int(t)) && t >= 0 {
+			save := l.pos
+			if gen, ok := l.token(); ok {
+				if g, isNum := gen.(float64); isNum && g == float64(int(g)) {
+					if r, ok := l.token(); ok && r == pdfKeyword("R") {
+						return pdfRef{num: int(t), gen: int(g)}, true
+					}
+				}
+			}
+			l.pos = save
+		}
+	}
+	return tok, true
+}
+
+// pdfObject is an indirect object; stream holds the still-encoded data of
+// stream objects.
+type pdfObject struct {
+	value     any
+	stream    []byte
+	hasStream bool
+}
+
+type pdfDoc struct {
+	objects  map[int]*pdfObject
+	trailers []map[string]any
+	fonts    map[pdfRef]*pdfFont
+}
+
+// extractPDF reads a PDF into memory and writes the text of each page.
+func extractPDF(r io.ReaderAt, size int64) (*Result, error) {
+	if size > maxPartSize {
+		return nil, ErrTooLarge
+	}
+	data := make([]byte, size)
+	if _, err := r.ReadAt(data, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	doc := parsePDF(data)
+	if doc.encrypted() {
+		return nil, ErrEncrypted
+	}
+	w := &textWriter{}
+	for _, page := range doc.pages() {
+		doc.writePage(w, page)
+		w.lineBreak()
+		if w.full() {
+			break
+		}
+	}
+	return w.result("pdf"), nil
+}
+
+var (
+	keywordObj     = []byte("obj")
+	keywordTrailer = []byte("trailer")
+	keywordEnd     = []byte("endstream")
+)
+
+// parsePDF collects every indirect object in data. Later definitions win, as
+// they do for incremental updates.
+func parsePDF(data []byte) *pdfDoc {
+	doc := &pdfDoc{objects: map[int]*pdfObject{}, fonts: map[pdfRef]*pdfFont{}}
+	for i := 0; i < len(data); {
+		j := bytes.Index(data[i:], keywordObj)
+		if j < 0 {
+			break
+		}
+		at := i + j
+		i = at + len(keywordObj)
+		if at+3 < len(data) && !isPDFSpace(data[at+3]) && !isPDFDelim(data[at+3]) {
+			continue
+		}
+		num, ok := objectNumber(data, at)
+		if !ok {
+			continue
+		}
+		l := &pdfLexer{data: data, pos: at + 3}
+		value, ok := l.object(0)
+		if !ok {
+			continue
+		}
+		obj := &pdfObject{value: value}
+		save := l.pos
+		if tok, _ := l.token(); tok == pdfKeyword("stream") {
+			start := l.pos
+			if start < len(data) && data[start] == '\r' {
+				start++
+			}
+			if start < len(data) && data[start] == '\n' {
+				start++
+			}
+			obj.stream = streamData(data, start, value)
+			obj.hasStream = true
+			i = start + len(obj.stream)
+		} else {
+			l.pos = save
+		}
+		doc.objects[num] = obj
+	}
+
+	for i := 0; i < len(data); {
+		j := bytes.Index(data[i:], keywordTrailer)
+		if j < 0 {
+			break
+		}
+		l := &pdfLexer{data: data, pos: i + j + len(keywordTrailer)}
+		i = l.pos
+		if dict, ok := l.object(0); ok {
+			if d, isDict := dict.(map[string]any); isDict {
+				doc.trailers = append(doc.trailers, d)
+			}
+		}
+	}
+
+	nums := doc.objectNumbers()
+	for _, num := range nums {
+		obj := doc.objects[num]
+		dict, _ := obj.value.(map[string]any)
+		switch typ, _ := dict["Type"].(pdfName); typ {
+		case "ObjStm":
+			doc.expandObjectStream(obj)
+		case "XRef":
+			// Cross-reference streams carry the trailer entries.
+			doc.trailers = append(doc.trailers, dict)
+		}
+	}
+	return doc
+}
+
+// objectNumber parses the "N G" that precedes the obj keyword at pos.
+func objectNumber(data []byte, pos int) (int, bool) {
+	p := pos
+	readInt := func() (int, bool) {
+		for p > 0 && isPDFSpace(data[p-1]) {
+			p--
+		}
+		end := p
+		for p > 0 && data[p-1] >= '0' && data[p-1] <= '9' {
+			p--
+		}
+		if p == end {
+			return 0, false
+		}
+		n, err := strconv.Atoi(string(data[p:end]))
+		return n, err == nil
+	}
+	if _, ok := readInt(); !ok {
+		return 0, false
+	}
+	num, ok := readInt()
+	if !ok || p > 0 && !isPDFSpace(data[p-1]) && !isPDFDelim(data[p-1]) {
+		return 0, false
+	}
+	return num, true
+}
+
+// streamData returns the raw bytes of a stream starting at start, trusting a
+// direct /Length only when endstream follows it.
+func streamData(data []byte, start int, value any) []byte {
+	dict, _ := value.(map[string]any)
+	if length, ok := dict["Length"].(float64); ok {
+		end := start + int(length)
+		if length >= 0 && end <= len(data) {
+			rest := bytes.TrimLeft(data[end:min(end+8, len(data))], "\r\n \t")
+			if len(rest) > 0 && bytes.HasPrefix(keywordEnd, rest) {
+				return data[start:end]
+			}
+		}
+	}
+	end := bytes.Index(data[start:], keywordEnd)
+	if end < 0 {
+		return data[start:]
+	}
+	return bytes.TrimRight(data[start:start+end], "\r\n")
+}
+
+func (d *pdfDoc) objectNumbers() []int {
+	nums := make([]int, 0, len(d.objects))
+	for num := range d.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// expandObjectStream adds the objects packed in a compressed object stream.
+// Objects defined directly in the file take precedence.
+func (d *pdfDoc) expandObjectStream(obj *pdfObject) {
+	data, err := d.decodeStream(obj)
+	if err != nil {
+		return
+	}
+	dict := obj.value.(map[string]any)
+	n := d.intValue(dict["N"])
+	first := d.intValue(dict["First"])
+	header := &pdfLexer{data: data}
+	for i := 0; i < n; i++ {
+		numTok, ok1 := header.token()
+		offTok, ok2 := header.token()
+		num, isNum := numTok.(float64)
+		off, isOff := offTok.(float64)
+		if !ok1 || !ok2 || !isNum || !isOff {
+			return
+		}
+		if _, exists := d.objects[int(num)]; exists {
+			continue
+		}
+		pos := first + int(off)
+		if pos < 0 || pos >= len(data) {
+			continue
+		}
+		l := &pdfLexer{data: data, pos: pos}
+		if value, ok := l.object(0); ok {
+			d.objects[int(num)] = &pdfObject{value: value}
+		}
+	}
+}
+
+func (d *pdfDoc) encrypted() bool {
+	for _, trailer := range d.trailers {
+		if _, ok := trailer["Encrypt"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve follows indirect references.
+func (d *pdfDoc) resolve(v any) any {
+	for depth := 0; depth < maxObjectDepth; depth++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		obj := d.objects[ref.num]
+		if obj == nil {
+			return nil
+		}
+		v = obj.value
+	}
+	return nil
+}
+
+func (d *pdfDoc) dict(v any) map[string]any {
+	dict, _ := d.resolve(v).(map[string]any)
+	return dict
+}
+
+func (d *pdfDoc) array(v any) []any {
+	arr, _ := d.resolve(v).([]any)
+	return arr
+}
+
+func (d *pdfDoc) name(v any) string {
+	name, _ := d.resolve(v).(pdfName)
+	return string(name)
+}
+
+func (d *pdfDoc) intValue(v any) int {
+	f, _ := d.resolve(v).(float64)
+	return int(f)
+}
+
+// stream returns the stream object v refers to.
+func (d *pdfDoc) stream(v any) *pdfObject {
+	ref, ok := v.(pdfRef)
+	if !ok {
+		return nil
+	}
+	obj := d.objects[ref.num]
+	if obj == nil || !obj.hasStream {
+		return nil
+	}
+	return obj
+}
+
+// decodeStream applies the stream's filters. Image codecs and LZW are not
+// supported; such streams carry no text anyway or are vanishingly rare.
+func (d *pdfDoc) decodeStream(obj *pdfObject) ([]byte, error) {
+	dict, _ := obj.value.(map[string]any)
+	var filters []string
+	switch f := d.resolve(dict["Filter"]).(type) {
+	case pdfName:
+		filters = []string{string(f)}
+	case []any:
+		for _, item := range f {
+			filters = append(filters, d.name(item))
+		}
+	}
+
+	data := obj.stream
+	for _, filter := range filters {
+		var r io.Reader
+		switch filter {
+		case "FlateDecode", "Fl":
+			if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+				r = zr
+			} else {
+				r = flate.NewReader(bytes.NewReader(data))
+			}
+		case "ASCIIHexDecode", "AHx":
+			l := &pdfLexer{data: data}
+			r = strings.NewReader(string(l.hexString()))
+		case "ASCII85Decode", "A85":
+			trimmed := bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+			if end := bytes.Index(trimmed, []byte("~>")); end >= 0 {
+				trimmed = trimmed[:end]
+			}
+			r = ascii85.NewDecoder(bytes.NewReader(trimmed))
+		default:
+			return nil, fmt.Errorf("textextract: unsupported PDF filter %s", filter)
+		}
+		decoded, err := io.ReadAll(&limitedReader{r: r, n: maxPartSize})
+		if errors.Is(err, ErrTooLarge) {
+			return nil, err
+		}
+		// A truncated or damaged deflate stream still yields a usable prefix.
+		if err != nil && len(decoded) == 0 {
+			return nil, err
+		}
+		data = decoded
+	}
+	return data, nil
+}
+
+// pdfPage is a page dictionary with its inherited resources.
+type pdfPage struct {
+	dict      map[string]any
+	resources map[string]any
+}
+
+// pages walks the page tree from the catalog. Documents without a usable
+// tree fall back to every page object in object-number order.
+func (d *pdfDoc) pages() []pdfPage {
+	var root map[string]any
+	for i := len(d.trailers) - 1; i >= 0 && root == nil; i-- {
+		root = d.dict(d.trailers[i]["Root"])
+	}
+	if root == nil {
+		for _, num := range d.objectNumbers() {
+			if dict, ok := d.objects[num].value.(map[string]any); ok && d.name(dict["Type"]) == "Catalog" {
+				root = dict
+			}
+		}
+	}
+
+	var pages []pdfPage
+	visited := map[pdfRef]bool{}
+	var walk func(node any, resources map[string]any, depth int)
+	walk = func(node any, resources map[string]any, depth int) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		dict := d.dict(node)
+		if dict == nil || depth > maxObjectDepth {
+			return
+		}
+		if res := d.dict(dict["Resources"]); res != nil {
+			resources = res
+		}
+		kids, hasKids := dict["Kids"]
+		if d.name(dict["Type"]) == "Page" || !hasKids {
+			pages = append(pages, pdfPage{dict: dict, resources: resources})
+			return
+		}
+		for _, kid := range d.array(kids) {
+			walk(kid, resources, depth+1)
+		}
+	}
+	if root != nil {
+		walk(root["Pages"], nil, 0)
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	for _, num := range d.objectNumbers() {
+		if dict, ok := d.objects[num].value.(map[string]any); ok && d.name(dict["Type"]) == "Page" {
+			pages = append(pages, pdfPage{dict: dict, resources: d.dict(dict["Resources"])})
+		}
+	}
+	return pages
+}
+
+// writePage interprets the page's content streams, which concatenate.
+func (d *pdfDoc) writePage(w *textWriter, page pdfPage) {
+	contents := page.dict["Contents"]
+	refs := []any{contents}
+	if arr := d.array(contents); arr != nil {
+		refs = arr
+	}
+	var content []byte
+	for _, ref := range refs {
+		obj := d.stream(ref)
+		if obj == nil {
+			continue
+		}
+		data, err := d.decodeStream(obj)
+		if err != nil {
+			continue
+		}
+		content = append(content, data...)
+		content = append(content, '\n')
+	}
+	d.writeContent(w, content, page.resources, 0)
+}
+
+// maxFormDepth bounds nesting of form XObjects drawn from content streams.
+const maxFormDepth = 4
+
+// writeContent interprets the text operators of a content stream.
+func (d *pdfDoc) writeContent(w *textWriter, content []byte, resources map[string]any, depth int) {
+	l := &pdfLexer{data: content}
+	var operands []any
+	var font *pdfFont
+	lineY, haveLine := 0.0, false
+
+	number := func(i int) float64 {
+		if i < 0 || i >= len(operands) {
+			return 0
+		}
+		f, _ := operands[i].(float64)
+		return f
+	}
+	lastString := func() string {
+		if len(operands) == 0 {
+			return ""
+		}
+		s, _ := operands[len(operands)-1].(pdfString)
+		return string(s)
+	}
+
+	for !w.full() {
+		tok, ok := l.object(0)
+		if !ok {
+			return
+		}
+		op, isOp := tok.(pdfKeyword)
+		if !isOp {
+			operands = append(operands, tok)
+			continue
+		}
+		switch op {
+		case "ET":
+			w.lineBreak()
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					font = d.font(resources, string(name))
+				}
+			}
+		case "Tj":
+			w.write(font.decode(lastString()))
+		case "'", "\"":
+			w.lineBreak()
+			w.write(font.decode(lastString()))
+		case "TJ":
+			if len(operands) == 0 {
+				break
+			}
+			arr, _ := operands[len(operands)-1].([]any)
+			for _, item := range arr {
+				switch v := item.(type) {
+				case pdfString:
+					w.write(font.decode(string(v)))
+				case float64:
+					// Adjustments are in thousandths of an em; a large
+					// negative one moves right far enough to be a space.
+					if v < -200 {
+						w.separate()
+					}
+				}
+			}
+		case "Td", "TD":
+			if number(1) != 0 {
+				w.lineBreak()
+			} else {
+				w.separate()
+			}
+		case "T*":
+			w.lineBreak()
+		case "Tm":
+			if y := number(5); haveLine && y != lineY {
+				w.lineBreak()
+			} else {
+				w.separate()
+			}
+			lineY, haveLine = number(5), true
+		case "Do":
+			if len(operands) > 0 && depth < maxFormDepth {
+				if name, ok := operands[len(operands)-1].(pdfName); ok {
+					d.writeForm(w, resources, string(name), depth)
+				}
+			}
+		case "BI":
+			l.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+// writeForm draws the form XObject name, whose text counts as the page's.
+func (d *pdfDoc) writeForm(w *textWriter, resources map[string]any, name string, depth int) {
+	ref := d.dict(resources["XObject"])[name]
+	obj := d.stream(ref)
+	if obj == nil {
+		return
+	}
+	dict, _ := obj.value.(map[string]any)
+	if d.name(dict["Subtype"]) != "Form" {
+		return
+	}
+	data, err := d.decodeStream(obj)
+	if err != nil {
+		return
+	}
+	if res := d.dict(dict["Resources"]); res != nil {
+		resources = res
+	}
+	d.writeContent(w, data, resources, depth+1)
+}
+
+// skipInlineImage moves past the binary data of an inline image, which
+// runs from the ID operator to an EI surrounded by whitespace.
+func (l *pdfLexer) skipInlineImage() {
+	for {
+		tok, ok := l.token()
+		if !ok {
+			return
+		}
+		if tok == pdfKeyword("ID") {
+			break
+		}
+	}
+	l.pos++
+	for l.pos < len(l.data) {
+		i := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if i < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		at := l.pos + i
+		l.pos = at + 2
+		if at > 0 && isPDFSpace(l.data[at-1]) && (l.pos >= len(l.data) || isPDFSpace(l.data[l.pos])) {
+			return
+		}
+	}
+}
+
+// pdfFont maps the character codes of shown strings to text.
+type pdfFont struct {
+	toUnicode *cmap
+	// composite fonts use multi-byte codes that mean nothing without a CMap.
+	composite bool
+	// differences overrides single-byte codes of a simple font.
+	differences map[byte]string
+}
+
+// font resolves the font resource name, caching fonts by reference.
+func (d *pdfDoc) font(resources map[string]any, name string) *pdfFont {
+	ref := d.dict(resources["Font"])[name]
+	if r, ok := ref.(pdfRef); ok {
+		if font, cached := d.fonts[r]; cached {
+			return font
+		}
+		font := d.loadFont(d.dict(r))
+		d.fonts[r] = font
+		return font
+	}
+	return d.loadFont(d.dict(ref))
+}
+
+func (d *pdfDoc) loadFont(dict map[string]any) *pdfFont {
+	font := &pdfFont{composite: d.name(dict["Subtype"]) == "Type0"}
+	if obj := d.stream(dict["ToUnicode"]); obj != nil {
+		if data, err := d.decodeStream(obj); err == nil {
+			font.toUnicode = parseCMap(data)
+		}
+	}
+	if enc := d.dict(dict["Encoding"]); enc != nil {
+		code := 0
+		for _, item := range d.array(enc["Differences"]) {
+			switch v := d.resolve(item).(type) {
+			case float64:
+				code = int(v)
+			case pdfName:
+				if s, ok := glyphText(string(v)); ok && code >= 0 && code < 256 {
+					if font.differences == nil {
+						font.differences = map[byte]string{}
+					}
+					font.differences[byte(code)] = s
+				}
+				code++
+			}
+		}
+	}
+	return font
+}
+
+// decode converts the codes of a shown string to text. A nil font is a font
+// resource that could not be resolved and is read as WinAnsi.
+func (f *pdfFont) decode(s string) string {
+	if f != nil && f.toUnicode != nil {
+		return f.toUnicode.decode(s)
+	}
+	if f != nil && f.composite {
+		return ""
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if f != nil {
+			if text, ok := f.differences[s[i]]; ok {
+				b.WriteString(text)
+				continue
+			}
+		}
+		if r := winAnsi(s[i]); r != 0 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// winAnsiHigh maps 0x80-0x9F of WinAnsiEncoding; zero marks unused codes.
+var winAnsiHigh = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+func winAnsi(c byte) rune {
+	switch {
+	case c == '\t' || c == '\n' || c == '\r':
+		return ' '
+	case c < 0x20 || c == 0x7F:
+		return 0
+	case c >= 0x80 && c < 0xA0:
+		return winAnsiHigh[c-0x80]
+	}
+	return rune(c)
+}
+
+// glyphNames covers the glyph names of /Differences arrays that are not
+// single letters or uniXXXX names.
+var glyphNames = map[string]string{
+	"space": " ", "period": ".", "comma": ",", "colon": ":", "semicolon": ";",
+	"hyphen": "-", "endash": "–", "emdash": "—", "quoteright": "’", "quoteleft": "‘",
+	"quotedblleft": "“", "quotedblright": "”", "quotesingle": "'", "quotedbl": "\"",
+	"exclam": "!", "question": "?", "parenleft": "(", "parenright": ")", "slash": "/",
+	"ampersand": "&", "percent": "%", "bullet": "•", "fi": "fi", "fl": "fl", "ff": "ff",
+	"ffi": "ffi", "ffl": "ffl", "zero": "0", "one": "1", "two": "2", "three": "3",
+	"four": "4", "five": "5", "six": "6", "seven": "7", "eight": "8", "nine": "9",
+}
+
+// glyphText maps a glyph name to its text.
+func glyphText(name string) (string, bool) {
+	if len(name) == 1 && (name[0] >= 'a' && name[0] <= 'z' || name[0] >= 'A' && name[0] <= 'Z') {
+		return name, true
+	}
+	if s, ok := glyphNames[name]; ok {
+		return s, true
+	}
+	if strings.HasPrefix(name, "uni") && len(name) == 7 {
+		if v, err := strconv.ParseUint(name[3:], 16, 16); err == nil {
+			return string(rune(v)), true
+		}
+	}
+	return "", false
+}
+
+// cmap is a parsed ToUnicode CMap.
+type cmap struct {
+	// codeLengths are the byte lengths of the codespace ranges, shortest first.
+	codeLengths []int
+	chars       map[string]string
+	ranges      []cmapRange
+}
+
+type cmapRange struct {
+	lo, hi []byte
+	// dst is the text of lo, incremented for later codes; or, when dsts
+	// is set, one entry per code.
+	dst  []uint16
+	dsts []string
+}
+
+// parseCMap reads the codespace and bfchar/bfrange sections of a CMap.
+func parseCMap(data []byte) *cmap {
+	c := &cmap{chars: map[string]string{}}
+	l := &pdfLexer{data: data}
+	var operands []any
+	lengths := map[int]bool{}
+	for {
+		tok, ok := l.object(0)
+		if !ok {
+			break
+		}
+		op, isOp := tok.(pdfKeyword)
+		if !isOp {
+			operands = append(operands, tok)
+			continue
+		}
+		// Operands accumulate from a begin operator to its end operator.
+		switch op {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if lo, ok := operands[i].(pdfString); ok && len(lo) > 0 {
+					lengths[len(lo)] = true
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					c.chars[string(src)] = utf16Text(utf16Units(string(dst)))
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) {
+					continue
+				}
+				r := cmapRange{lo: []byte(lo), hi: []byte(hi)}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					r.dst = utf16Units(string(dst))
+				case []any:
+					for _, item := range dst {
+						s, _ := item.(pdfString)
+						r.dsts = append(r.dsts, utf16Text(utf16Units(string(s))))
+					}
+				default:
+					continue
+				}
+				c.ranges = append(c.ranges, r)
+			}
+		}
+		operands = operands[:0]
+	}
+
+	for n := range lengths {
+		c.codeLengths = append(c.codeLengths, n)
+	}
+	if len(c.codeLengths) == 0 {
+		// No codespace: infer the code length from the mappings.
+		for src := range c.chars {
+			lengths[len(src)] = true
+		}
+		for _, r := range c.ranges {
+			lengths[len(r.lo)] = true
+		}
+		for n := range lengths {
+			c.codeLengths = append(c.codeLengths, n)
+		}
+	}
+	sort.Ints(c.codeLengths)
+	return c
+}
+
+// decode maps each code of s to its text, preferring the shortest code
+// length that has a mapping. Unmapped codes are dropped.
+func (c *cmap) decode(s string) string {
+	if len(c.codeLengths) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		matched := false
+		for _, n := range c.codeLengths {
+			if i+n > len(s) {
+				break
+			}
+			if text, ok := c.lookup(s[i : i+n]); ok {
+				b.WriteString(text)
+				i += n
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			i += c.codeLengths[len(c.codeLengths)-1]
+		}
+	}
+	return b.String()
+}
+
+func (c *cmap) lookup(code string) (string, bool) {
+	if text, ok := c.chars[code]; ok {
+		return text, true
+	}
+	for _, r := range c.ranges {
+		if len(r.lo) != len(code) || code < string(r.lo) || code > string(r.hi) {
+			continue
+		}
+		offset := codeValue(code) - codeValue(string(r.lo))
+		if r.dsts != nil {
+			if offset < len(r.dsts) {
+				return r.dsts[offset], true
+			}
+			return "", false
+		}
+		if len(r.dst) == 0 {
+			return "", false
+		}
+		units := append([]uint16(nil), r.dst...)
+		units[len(units)-1] += uint16(offset)
+		return utf16Text(units), true
+	}
+	return "", false
+}
+
+func codeValue(code string) int {
+	v := 0
+	for i := 0; i < len(code); i++ {
+		v = v<<8 | int(code[i])
+	}
+	return v
+}
+
+func utf16Units(s string) []uint16 {
+	units := make([]uint16, len(s)/2)
+	for i := range units {
+		units[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+	}
+	return units
+}
+
+func utf16Text(units []uint16) string {
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,192 @@
+// Package textextract pulls plain text out of documents so their contents can
+// be searched: PDF, OOXML (docx, xlsx, pptx), OpenDocument (odt, ods, odp)
+// and EPUB. All parsers are pure Go. Layout, styling and images are
+// discarded; paragraphs, table cells and slides end up on separate lines.
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrEncrypted is returned for password-protected documents.
+	ErrEncrypted = errors.New("textextract: document is encrypted")
+	// ErrTooLarge is returned when a document, or one of its decompressed
+	// parts, exceeds the extraction limits.
+	ErrTooLarge = errors.New("textextract: document exceeds size limit")
+)
+
+const (
+	// MaxTextSize bounds the extracted text; longer text is truncated.
+	MaxTextSize = 10 << 20
+	// maxPartSize bounds a decompressed archive member or PDF stream, and the
+	// size of a PDF read into memory, so a small file cannot exhaust memory.
+	maxPartSize = 64 << 20
+)
+
+// Result is the text extracted from a document.
+type Result struct {
+	// Format is the detected format: pdf, docx, xlsx, pptx, odt, ods, odp or epub.
+	Format string
+	Text   string
+	// Truncated reports that the text was cut at MaxTextSize.
+	Truncated bool
+}
+
+var supportedExtensions = map[string]bool{
+	".pdf": true, ".docx": true, ".xlsx": true, ".pptx": true,
+	".odt": true, ".ods": true, ".odp": true, ".epub": true,
+}
+
+var supportedMimeTypes = map[string]bool{
+	"application/pdf": true,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   true,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         true,
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": true,
+	"application/vnd.oasis.opendocument.text":                                   true,
+	"application/vnd.oasis.opendocument.spreadsheet":                            true,
+	"application/vnd.oasis.opendocument.presentation":                           true,
+	"application/epub+zip": true,
+}
+
+// Supported reports whether a file named name with mimeType is in a format
+// Extract handles, judged by its MIME type or, failing that, its extension.
+func Supported(name, mimeType string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = strings.TrimSpace(mimeType[:i])
+	}
+	return supportedMimeTypes[mimeType] || supportedExtensions[strings.ToLower(path.Ext(name))]
+}
+
+var (
+	magicPDF = []byte("%PDF-")
+	magicZip = []byte("PK\x03\x04")
+)
+
+// Extract identifies r by its content and returns its text. It returns
+// nil, nil for formats it does not handle, including ZIP archives that are
+// not documents.
+func Extract(r io.ReaderAt, size int64) (*Result, error) {
+	head := make([]byte, 1024)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, magicZip):
+		zr, err := zip.NewReader(r, size)
+		if err != nil {
+			return nil, nil
+		}
+		return extractZip(zr)
+	case bytes.Contains(head, magicPDF):
+		// Readers accept junk before the header within the first kilobyte.
+		return extractPDF(r, size)
+	}
+	return nil, nil
+}
+
+// textWriter accumulates extracted text up to MaxTextSize. Separators are
+// held back until more text follows, so they are never doubled or trailing.
+type textWriter struct {
+	b         strings.Builder
+	pending   byte
+	truncated bool
+}
+
+func (w *textWriter) full() bool {
+	return w.truncated
+}
+
+func (w *textWriter) write(s string) {
+	if w.truncated || s == "" {
+		return
+	}
+	if w.pending != 0 && w.b.Len() > 0 {
+		w.append(string(w.pending))
+	}
+	w.pending = 0
+	w.append(s)
+}
+
+func (w *textWriter) append(s string) {
+	if w.truncated {
+		return
+	}
+	if room := MaxTextSize - w.b.Len(); len(s) > room {
+		s = s[:room]
+		for len(s) > 0 && !utf8.ValidString(s) {
+			s = s[:len(s)-1]
+		}
+		w.truncated = true
+	}
+	w.b.WriteString(s)
+}
+
+// writeCollapsed writes s with every whitespace run reduced to one space, as
+// HTML and OpenDocument render it.
+func (w *textWriter) writeCollapsed(s string) {
+	if s == "" {
+		return
+	}
+	if isSpace(s[0]) {
+		w.separate()
+	}
+	if fields := strings.Fields(s); len(fields) > 0 {
+		w.write(strings.Join(fields, " "))
+		if isSpace(s[len(s)-1]) {
+			w.separate()
+		}
+	}
+}
+
+// separate ends the current word.
+func (w *textWriter) separate() {
+	if w.pending == 0 {
+		w.pending = ' '
+	}
+}
+
+// lineBreak ends the current line.
+func (w *textWriter) lineBreak() {
+	w.pending = '\n'
+}
+
+func (w *textWriter) result(format string) *Result {
+	return &Result{Format: format, Text: strings.TrimSpace(w.b.String()), Truncated: w.truncated}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\t' || c == '\r'
+}
+
+// limitedReader fails with ErrTooLarge instead of silently stopping at n bytes.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		var probe [1]byte
+		if n, _ := l.r.Read(probe[:]); n > 0 {
+			return 0, ErrTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
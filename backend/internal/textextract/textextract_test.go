@@ -0,0 +1,241 @@
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func buildZip(t *testing.T, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i+1 < len(files); i += 2 {
+		w, err := zw.Create(files[i])
+		if err != nil {
+			t.Fatalf("create %s: %v", files[i], err)
+		}
+		if _, err := w.Write([]byte(files[i+1])); err != nil {
+			t.Fatalf("write %s: %v", files[i], err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func extract(t *testing.T, data []byte) *Result {
+	t.Helper()
+	res, err := Extract(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if res == nil {
+		t.Fatalf("Extract: format not recognised")
+	}
+	return res
+}
+
+func TestExtractDOCX(t *testing.T) {
+	doc := buildZip(t,
+		"[Content_Types].xml", `<Types/>`,
+		"word/document.xml", `<?xml version="1.0"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+  <w:p><w:pPr><w:tabs><w:tab w:val="left" w:pos="720"/></w:tabs></w:pPr><w:r><w:t>Quarterly</w:t></w:r><w:r><w:t xml:space="preserve"> report</w:t></w:r></w:p>
+  <w:p><w:r><w:t>Revenue</w:t><w:tab/><w:t>grew</w:t><w:br/><w:t>fast</w:t></w:r><w:del><w:r><w:delText>gone</w:delText></w:r></w:del></w:p>
+</w:body></w:document>`,
+		"word/footnotes.xml", `<w:footnotes xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:footnote><w:p><w:r><w:t>See appendix</w:t></w:r></w:p></w:footnote></w:footnotes>`,
+	)
+	res := extract(t, doc)
+	if want := "Quarterly report\nRevenue\tgrew\nfast\nSee appendix"; res.Format != "docx" || res.Text != want {
+		t.Fatalf("got %s %q, want %q", res.Format, res.Text, want)
+	}
+}
+
+func TestExtractXLSXAndPPTX(t *testing.T) {
+	xlsx := buildZip(t,
+		"xl/workbook.xml", `<workbook/>`,
+		"xl/sharedStrings.xml", `<sst><si><t>Region</t></si><si><r><t>North</t></r><r><t>east</t></r><rPh><t>ignored</t></rPh></si></sst>`,
+		"xl/worksheets/sheet1.xml", `<worksheet><sheetData><row><c t="s"><v>0</v></c><c><v>42</v></c><c t="inlineStr"><is><t>Inline note</t></is></c></row></sheetData></worksheet>`,
+	)
+	res := extract(t, xlsx)
+	if want := "Region\nNortheast\nInline note"; res.Format != "xlsx" || res.Text != want {
+		t.Fatalf("xlsx: got %s %q, want %q", res.Format, res.Text, want)
+	}
+
+	slide := func(text string) string {
+		return `<p:sld xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"><a:p><a:r><a:t>` + text + `</a:t></a:r></a:p></p:sld>`
+	}
+	pptx := buildZip(t,
+		"ppt/presentation.xml", `<presentation/>`,
+		"ppt/slides/slide10.xml", slide("Last"),
+		"ppt/slides/slide2.xml", slide("Second"),
+		"ppt/slides/slide1.xml", slide("First"),
+	)
+	res = extract(t, pptx)
+	if want := "First\nSecond\nLast"; res.Format != "pptx" || res.Text != want {
+		t.Fatalf("pptx: got %s %q, want %q", res.Format, res.Text, want)
+	}
+}
+
+func TestExtractODT(t *testing.T) {
+	odt := buildZip(t,
+		"mimetype", "application/vnd.oasis.opendocument.text",
+		"content.xml", `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+  <office:automatic-styles><style>ignored</style></office:automatic-styles>
+  <office:body><office:text>
+    <text:h>Meeting   notes</text:h>
+    <text:p>Ship<text:s/>the<text:tab/>release<text:line-break/>on Friday</text:p>
+  </office:text></office:body>
+</office:document-content>`,
+	)
+	res := extract(t, odt)
+	if want := "Meeting notes\nShip the\trelease\non Friday"; res.Format != "odt" || res.Text != want {
+		t.Fatalf("got %s %q, want %q", res.Format, res.Text, want)
+	}
+}
+
+func TestExtractEPUB(t *testing.T) {
+	epub := buildZip(t,
+		"mimetype", "application/epub+zip",
+		"META-INF/container.xml", `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`,
+		"OEBPS/content.opf", `<package xmlns="http://www.idpf.org/2007/opf"><manifest>
+  <item id="c2" href="text/chapter%202.xhtml" media-type="application/xhtml+xml"/>
+  <item id="c1" href="text/chapter1.xhtml" media-type="application/xhtml+xml"/>
+  <item id="css" href="style.css" media-type="text/css"/>
+</manifest><spine><itemref idref="c1"/><itemref idref="c2"/><itemref idref="css"/></spine></package>`,
+		"OEBPS/text/chapter1.xhtml", `<html><head><title>Skip</title><style>p{}</style></head><body><h1>Chapter One</h1><p>It was a&nbsp;dark <em>and</em>
+    stormy night.<br>Really.</p></body></html>`,
+		"OEBPS/text/chapter 2.xhtml", `<html><body><p>The end.</p></body></html>`,
+	)
+	res := extract(t, epub)
+	if want := "Chapter One\nIt was a dark and stormy night.\nReally.\nThe end."; res.Format != "epub" || res.Text != want {
+		t.Fatalf("got %s %q, want %q", res.Format, res.Text, want)
+	}
+}
+
+// buildPDF assembles a PDF from object bodies, numbered from 1; empty bodies
+// leave a gap. Streams are given as "dict\x00data" and are Flate-compressed.
+// No xref table is written, which the reader does not need.
+func buildPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	for i, body := range objects {
+		if body == "" {
+			continue
+		}
+		fmt.Fprintf(&buf, "%d 0 obj\n", i+1)
+		if dict, data, ok := strings.Cut(body, "\x00"); ok {
+			var z bytes.Buffer
+			zw := zlib.NewWriter(&z)
+			zw.Write([]byte(data))
+			zw.Close()
+			fmt.Fprintf(&buf, "<< %s /Filter /FlateDecode /Length %d >>\nstream\n", dict, z.Len())
+			buf.Write(z.Bytes())
+			buf.WriteString("\nendstream")
+		} else {
+			buf.WriteString(body)
+		}
+		buf.WriteString("\nendobj\n")
+	}
+	buf.WriteString("trailer\n<< /Root 1 0 R /Size 9 >>\n%%EOF\n")
+	return buf.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+2 beginbfchar
+<0001> <0048>
+<0002> <00E9>
+endbfchar
+1 beginbfrange
+<0010> <0012> <0061>
+endbfrange
+endcmap`
+	page1 := `BT /F1 12 Tf 72 720 Td (Quarterly \(draft\) report) Tj 0 -14 Td [(Reven) 30 (ue grew) -300 (fast)] TJ ET
+BI /W 2 /H 2 /BPC 8 /CS /G ID ` + "\x00\xff\x10EI" + ` EI
+BT /F2 12 Tf 1 0 0 1 72 600 Tm <0001000200100011> Tj 1 0 0 1 72 580 Tm <00120012> Tj ET
+/Fm1 Do`
+	doc := buildPDF(
+		`<< /Type /Catalog /Pages 2 0 R >>`,
+		`<< /Type /Pages /Kids [4 0 R 3 0 R] /Count 2 /Resources << /Font << /F1 5 0 R /F2 6 0 R >> /XObject << /Fm1 8 0 R >> >> >>`,
+		`<< /Type /Page /Parent 2 0 R /Contents 9 0 R >>`,
+		`<< /Type /Page /Parent 2 0 R /Contents [10 0 R] >>`,
+		`<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding << /Differences [39 /quoteright] >> >>`,
+		`<< /Type /Font /Subtype /Type0 /BaseFont /Subset /Encoding /Identity-H /ToUnicode 7 0 R >>`,
+		"\x00"+cmap,
+		"/Type /XObject /Subtype /Form /BBox [0 0 100 100]\x00BT /F1 10 Tf (Footer) Tj ET",
+		"\x00BT /F1 12 Tf (Second page) Tj (Don't panic) ' ET",
+		"\x00"+page1,
+	)
+	// The page tree, not object order, decides the page order.
+	res := extract(t, doc)
+	want := "Quarterly (draft) report\nRevenue grew fast\nHéab\ncc\nFooter\nSecond page\nDon’t panic"
+	if res.Format != "pdf" || res.Text != want {
+		t.Fatalf("got %s %q\nwant %q", res.Format, res.Text, want)
+	}
+}
+
+func TestExtractPDFObjectStreamAndEncryption(t *testing.T) {
+	page := `<< /Type /Page /Contents 5 0 R >>`
+	pages := `<< /Type /Pages /Kids [3 0 R] >>`
+	header := fmt.Sprintf("3 0 4 %d ", len(page))
+	doc := buildPDF(
+		`<< /Type /Catalog /Pages 4 0 R >>`,
+		fmt.Sprintf("/Type /ObjStm /N 2 /First %d\x00%s%s%s", len(header), header, page, pages),
+		"",
+		"",
+		"\x00BT (packed objects) Tj ET",
+	)
+	if res := extract(t, doc); res.Text != "packed objects" {
+		t.Fatalf("got %q", res.Text)
+	}
+
+	encrypted := bytes.Replace(doc, []byte("/Root 1 0 R"), []byte("/Root 1 0 R /Encrypt 9 0 R"), 1)
+	if _, err := Extract(bytes.NewReader(encrypted), int64(len(encrypted))); !errors.Is(err, ErrEncrypted) {
+		t.Fatalf("expected ErrEncrypted, got %v", err)
+	}
+}
+
+func TestExtractUnsupported(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"plain zip": buildZip(t, "readme.txt", "hello"),
+		"text":      []byte("just text"),
+		"empty":     nil,
+	} {
+		res, err := Extract(bytes.NewReader(data), int64(len(data)))
+		if res != nil || err != nil {
+			t.Errorf("%s: expected nil, nil; got %+v, %v", name, res, err)
+		}
+	}
+}
+
+func TestSupported(t *testing.T) {
+	if !Supported("Report.PDF", "") || !Supported("x", "application/epub+zip; charset=binary") {
+		t.Fatalf("expected extension and MIME type to be recognised")
+	}
+	if Supported("notes.txt", "text/plain") || Supported("legacy.doc", "application/msword") {
+		t.Fatalf("expected unsupported formats to be rejected")
+	}
+}
+
+func TestTextWriterTruncates(t *testing.T) {
+	w := &textWriter{}
+	w.write(strings.Repeat("a", MaxTextSize-1))
+	w.lineBreak()
+	w.write("é and more")
+	res := w.result("test")
+	if !res.Truncated || len(res.Text) != MaxTextSize-1 {
+		t.Fatalf("expected truncation at a rune boundary, got truncated=%v len=%d", res.Truncated, len(res.Text))
+	}
+}
@@ -0,0 +1,399 @@
+package textextract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// xmlRules maps a document's XML vocabulary onto text, by element local name.
+type xmlRules struct {
+	// text, when set, keeps character data only inside these elements.
+	text map[string]bool
+	// blocks end a line when they close.
+	blocks map[string]bool
+	// breaks stand for whitespace where they open: "\n", "\t" or " ".
+	breaks map[string]string
+	// skip drops whole subtrees, such as style definitions.
+	skip map[string]bool
+	// collapse folds whitespace runs in character data, as HTML and ODF do.
+	collapse bool
+	// html parses leniently, with HTML entities and void elements.
+	html bool
+}
+
+func names(list ...string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, name := range list {
+		set[name] = true
+	}
+	return set
+}
+
+var (
+	// WordprocessingML keeps run text in w:t; w:tab also appears inside
+	// paragraph properties as tab-stop definitions, hence skipping pPr.
+	docxRules = xmlRules{
+		text:   names("t"),
+		blocks: names("p"),
+		breaks: map[string]string{"tab": "\t", "br": "\n", "cr": "\n"},
+		skip:   names("pPr", "rPr", "sectPr", "instrText"),
+	}
+	// DrawingML text of slides.
+	pptxRules = xmlRules{
+		text:   names("t"),
+		blocks: names("p"),
+		breaks: map[string]string{"br": "\n"},
+	}
+	// Shared and inline strings of SpreadsheetML; rPh holds phonetic hints.
+	xlsxRules = xmlRules{
+		text:   names("t"),
+		blocks: names("si", "is"),
+		skip:   names("rPh"),
+	}
+	odfRules = xmlRules{
+		blocks:   names("p", "h", "list-item"),
+		breaks:   map[string]string{"s": " ", "tab": "\t", "line-break": "\n"},
+		skip:     names("automatic-styles", "font-face-decls", "tracked-changes", "annotation"),
+		collapse: true,
+	}
+	xhtmlRules = xmlRules{
+		blocks: names("p", "div", "h1", "h2", "h3", "h4", "h5", "h6", "li", "tr", "td", "th",
+			"blockquote", "pre", "section", "article", "dt", "dd", "figcaption"),
+		breaks:   map[string]string{"br": "\n"},
+		skip:     names("head", "script", "style"),
+		collapse: true,
+		html:     true,
+	}
+)
+
+// writeXML streams the character data of r into w according to rules.
+func (w *textWriter) writeXML(r io.Reader, rules xmlRules) error {
+	dec := xml.NewDecoder(r)
+	if rules.html {
+		dec.Strict = false
+		dec.AutoClose = xml.HTMLAutoClose
+		dec.Entity = xml.HTMLEntity
+	}
+	localName := func(name xml.Name) string {
+		if rules.html {
+			return strings.ToLower(name.Local)
+		}
+		return name.Local
+	}
+
+	inText, skipping := 0, 0
+	for !w.full() {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := localName(t.Name)
+			if skipping > 0 || rules.skip[name] {
+				skipping++
+				continue
+			}
+			if rules.text[name] {
+				inText++
+			}
+			switch sep := rules.breaks[name]; sep {
+			case "":
+			case "\n":
+				w.lineBreak()
+			case " ":
+				w.separate()
+			default:
+				w.write(sep)
+			}
+		case xml.EndElement:
+			if skipping > 0 {
+				skipping--
+				continue
+			}
+			name := localName(t.Name)
+			if rules.text[name] {
+				inText--
+			}
+			if rules.blocks[name] {
+				w.lineBreak()
+			}
+		case xml.CharData:
+			if skipping > 0 || rules.text != nil && inText <= 0 {
+				continue
+			}
+			if rules.collapse {
+				w.writeCollapsed(string(t))
+			} else {
+				w.write(string(t))
+			}
+		}
+	}
+	return nil
+}
+
+// zipDocument gives access to the members of a ZIP-based document.
+type zipDocument struct {
+	files map[string]*zip.File
+}
+
+func (d *zipDocument) has(name string) bool {
+	return d.files[name] != nil
+}
+
+// open returns member name, decompressed up to maxPartSize.
+func (d *zipDocument) open(name string) (io.ReadCloser, error) {
+	f := d.files[name]
+	if f == nil {
+		return nil, fmt.Errorf("textextract: missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{&limitedReader{r: rc, n: maxPartSize}, rc}, nil
+}
+
+func (d *zipDocument) writeXML(w *textWriter, name string, rules xmlRules) error {
+	rc, err := d.open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := w.writeXML(rc, rules); err != nil {
+		return fmt.Errorf("textextract: %s: %w", name, err)
+	}
+	w.lineBreak()
+	return nil
+}
+
+// numbered returns the members matching prefix + N + suffix in numeric order,
+// e.g. ppt/slides/slide2.xml before slide10.xml.
+func (d *zipDocument) numbered(prefix, suffix string) []string {
+	type member struct {
+		name string
+		n    int
+	}
+	var members []member
+	for name := range d.files {
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix))
+		if err == nil {
+			members = append(members, member{name, n})
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].n < members[j].n })
+	list := make([]string, len(members))
+	for i, m := range members {
+		list[i] = m.name
+	}
+	return list
+}
+
+// extractZip recognises OOXML, ODF and EPUB packages by their members.
+func extractZip(zr *zip.Reader) (*Result, error) {
+	doc := &zipDocument{files: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		doc.files[f.Name] = f
+	}
+
+	var mimetype string
+	if doc.has("mimetype") {
+		if rc, err := doc.open("mimetype"); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(rc, 128))
+			rc.Close()
+			mimetype = strings.TrimSpace(string(data))
+		}
+	}
+
+	switch {
+	case mimetype == "application/epub+zip":
+		return extractEPUB(doc)
+	case strings.HasPrefix(mimetype, "application/vnd.oasis.opendocument."):
+		return extractODF(doc, mimetype)
+	case doc.has("word/document.xml"):
+		return extractDOCX(doc)
+	case doc.has("xl/workbook.xml"):
+		return extractXLSX(doc)
+	case doc.has("ppt/presentation.xml"):
+		return extractPPTX(doc)
+	}
+	return nil, nil
+}
+
+// extractDOCX reads the body, then footnotes and endnotes.
+func extractDOCX(doc *zipDocument) (*Result, error) {
+	w := &textWriter{}
+	for _, name := range []string{"word/document.xml", "word/footnotes.xml", "word/endnotes.xml"} {
+		if !doc.has(name) {
+			continue
+		}
+		if err := doc.writeXML(w, name, docxRules); err != nil {
+			return w.result("docx"), err
+		}
+	}
+	return w.result("docx"), nil
+}
+
+// extractXLSX reads the shared string table, which holds the text of every
+// string cell, then inline strings of each sheet. Numbers are not extracted.
+func extractXLSX(doc *zipDocument) (*Result, error) {
+	w := &textWriter{}
+	parts := doc.numbered("xl/worksheets/sheet", ".xml")
+	if doc.has("xl/sharedStrings.xml") {
+		parts = append([]string{"xl/sharedStrings.xml"}, parts...)
+	}
+	for _, name := range parts {
+		if err := doc.writeXML(w, name, xlsxRules); err != nil {
+			return w.result("xlsx"), err
+		}
+	}
+	return w.result("xlsx"), nil
+}
+
+// extractPPTX reads slides in order, one block per slide.
+func extractPPTX(doc *zipDocument) (*Result, error) {
+	w := &textWriter{}
+	for _, name := range doc.numbered("ppt/slides/slide", ".xml") {
+		if err := doc.writeXML(w, name, pptxRules); err != nil {
+			return w.result("pptx"), err
+		}
+	}
+	return w.result("pptx"), nil
+}
+
+var odfFormats = map[string]string{
+	"text":         "odt",
+	"spreadsheet":  "ods",
+	"presentation": "odp",
+}
+
+// extractODF reads content.xml of an OpenDocument package.
+func extractODF(doc *zipDocument, mimetype string) (*Result, error) {
+	kind := strings.TrimPrefix(mimetype, "application/vnd.oasis.opendocument.")
+	format, ok := odfFormats[kind]
+	if !ok {
+		return nil, nil
+	}
+	if doc.has("META-INF/manifest.xml") && odfEncrypted(doc) {
+		return nil, ErrEncrypted
+	}
+	w := &textWriter{}
+	if err := doc.writeXML(w, "content.xml", odfRules); err != nil {
+		return w.result(format), err
+	}
+	return w.result(format), nil
+}
+
+// odfEncrypted reports whether the manifest declares encrypted members.
+func odfEncrypted(doc *zipDocument) bool {
+	rc, err := doc.open("META-INF/manifest.xml")
+	if err != nil {
+		return false
+	}
+	defer rc.Close()
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "encryption-data" {
+			return true
+		}
+	}
+}
+
+// epubContainer is META-INF/container.xml, which locates the package document.
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage is the part of the OPF package document naming the reading order.
+type epubPackage struct {
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+func (d *zipDocument) decodeXML(name string, v any) error {
+	rc, err := d.open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("textextract: %s: %w", name, err)
+	}
+	return nil
+}
+
+// extractEPUB reads the XHTML documents of the spine in reading order.
+func extractEPUB(doc *zipDocument) (*Result, error) {
+	if doc.has("META-INF/encryption.xml") {
+		return nil, ErrEncrypted
+	}
+	var container epubContainer
+	if err := doc.decodeXML("META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, errors.New("textextract: epub has no package document")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	var pkg epubPackage
+	if err := doc.decodeXML(opfPath, &pkg); err != nil {
+		return nil, err
+	}
+
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		if item.MediaType == "application/xhtml+xml" || item.MediaType == "text/html" {
+			hrefs[item.ID] = item.Href
+		}
+	}
+	w := &textWriter{}
+	for _, ref := range pkg.Spine {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		name := path.Join(path.Dir(opfPath), href)
+		if !doc.has(name) {
+			continue
+		}
+		if err := doc.writeXML(w, name, xhtmlRules); err != nil {
+			return w.result("epub"), err
+		}
+		if w.full() {
+			break
+		}
+	}
+	return w.result("epub"), nil
+}
@@ -11,6 +11,40 @@ import (
 	"github.com/Muneer320/RhinoBox/internal/storage"
 )
 
+// addDuplicateMetadata closes manager, records dup in a legacy
+// metadata/files.json next to the current index entries and reopens the
+// manager, which imports that file into the index.
+func addDuplicateMetadata(t *testing.T, root string, manager *storage.Manager, dup storage.FileMetadata) *storage.Manager {
+	t.Helper()
+	items := append(manager.GetAllMetadata(), dup)
+	if err := manager.Close(); err != nil {
+		t.Fatalf("failed to close manager: %v", err)
+	}
+
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to marshal metadata: %v", err)
+	}
+	metadataPath := filepath.Join(root, "metadata", "files.json")
+	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+		t.Fatalf("failed to write metadata file: %v", err)
+	}
+
+	reopened, err := storage.NewManager(root)
+	if err != nil {
+		t.Fatalf("failed to reopen manager: %v", err)
+	}
+	t.Cleanup(func() { reopened.Close() })
+	if _, err := os.Stat(metadataPath + ".migrated"); err != nil {
+		t.Fatalf("metadata file was not imported: %v", err)
+	}
+	meta, err := reopened.GetFileMetadata(dup.Hash)
+	if err != nil || meta.StoredPath != dup.StoredPath {
+		t.Fatalf("duplicate metadata not indexed: %+v %v", meta, err)
+	}
+	return reopened
+}
+
 func TestScanForDuplicates(t *testing.T) {
 	root := t.TempDir()
 	manager, err := storage.NewManager(root)
@@ -78,24 +112,12 @@ func TestScanForDuplicates(t *testing.T) {
 		t.Fatalf("failed to write duplicate file: %v", err)
 	}
 
-	// Add duplicate to metadata index by reading and modifying the metadata file
-	metadataPath := filepath.Join(root, "metadata", "files.json")
-	data, err := os.ReadFile(metadataPath)
-	if err != nil {
-		t.Fatalf("failed to read metadata file: %v", err)
-	}
-
-	var items []storage.FileMetadata
-	if err := json.Unmarshal(data, &items); err != nil {
-		t.Fatalf("failed to unmarshal metadata: %v", err)
-	}
-
-	// Add duplicate metadata entry
+	// Add duplicate to the metadata index
 	relPath, err := filepath.Rel(root, duplicatePath)
 	if err != nil {
 		t.Fatalf("failed to get relative path: %v", err)
 	}
-	duplicateMeta := storage.FileMetadata{
+	manager = addDuplicateMetadata(t, root, manager, storage.FileMetadata{
 		Hash:         hash,
 		OriginalName: "duplicate.txt",
 		StoredPath:   filepath.ToSlash(relPath),
@@ -103,23 +125,9 @@ func TestScanForDuplicates(t *testing.T) {
 		MimeType:     "text/plain",
 		Size:         int64(len(content1)),
 		UploadedAt:   time.Now().UTC(),
-	}
-	items = append(items, duplicateMeta)
-
-	// Write back to metadata file
-	updatedData, err := json.MarshalIndent(items, "", "  ")
-	if err != nil {
-		t.Fatalf("failed to marshal metadata: %v", err)
-	}
-	if err := os.WriteFile(metadataPath, updatedData, 0644); err != nil {
-		t.Fatalf("failed to write metadata file: %v", err)
-	}
-
-	// Note: We can't reload the manager due to cache lock, so we'll test verification
-	// which reads directly from disk. For scan testing, we'll rely on the existing
-	// duplicate detection during upload.
+	})
 	
-	// Test verification instead (reads from disk)
+	// Verification reads from disk
 	verifyResult, err := manager.VerifyDeduplicationSystem()
 	if err != nil {
 		t.Fatalf("failed to verify: %v", err)
@@ -131,8 +139,8 @@ func TestScanForDuplicates(t *testing.T) {
 	}
 
 	// Scan for duplicates
-	// Note: The scan uses in-memory index, so it won't see the duplicate we manually added
-	// to the metadata file. However, we can test that the scan functionality works.
+	// Note: The index keeps one record per hash, so the imported duplicate replaced the
+	// original's record rather than forming a group. We can still test that the scan works.
 	scanResult, err := manager.ScanForDuplicates(storage.DuplicateScanRequest{
 		DeepScan:       false,
 		IncludeMetadata: true,
@@ -145,7 +153,7 @@ func TestScanForDuplicates(t *testing.T) {
 		t.Errorf("expected status 'completed', got %s", scanResult.Status)
 	}
 
-	// The scan should complete successfully even if no duplicates are found in memory
+	// The scan should complete successfully even if no duplicates are found in the index
 	if scanResult.TotalFiles < 1 {
 		t.Errorf("expected at least 1 file scanned, got %d", scanResult.TotalFiles)
 	}
@@ -156,8 +164,8 @@ func TestScanForDuplicates(t *testing.T) {
 		t.Fatalf("failed to get duplicate report: %v", err)
 	}
 
-	// The report may be empty if no duplicates were found in the in-memory index
-	// This is expected since we manually added to the file but not the in-memory index
+	// The report may be empty if no duplicates were found in the index
+	// This is expected since the duplicate shares its record's hash key
 	_ = groups // Acknowledge we're checking the report functionality works
 }
 
@@ -263,24 +271,12 @@ func TestMergeDuplicates(t *testing.T) {
 		t.Fatalf("failed to write duplicate file: %v", err)
 	}
 
-	// Add duplicate to metadata by reading and modifying the metadata file
-	metadataPath := filepath.Join(root, "metadata", "files.json")
-	data, err := os.ReadFile(metadataPath)
-	if err != nil {
-		t.Fatalf("failed to read metadata file: %v", err)
-	}
-
-	var items []storage.FileMetadata
-	if err := json.Unmarshal(data, &items); err != nil {
-		t.Fatalf("failed to unmarshal metadata: %v", err)
-	}
-
-	// Add duplicate metadata entry
+	// Add duplicate to the metadata index
 	relPath, err := filepath.Rel(root, duplicatePath)
 	if err != nil {
 		t.Fatalf("failed to get relative path: %v", err)
 	}
-	duplicateMeta := storage.FileMetadata{
+	manager = addDuplicateMetadata(t, root, manager, storage.FileMetadata{
 		Hash:         hash,
 		OriginalName: "duplicate.txt",
 		StoredPath:   filepath.ToSlash(relPath),
@@ -288,24 +284,9 @@ func TestMergeDuplicates(t *testing.T) {
 		MimeType:     "text/plain",
 		Size:         int64(len(content)),
 		UploadedAt:   time.Now().UTC(),
-	}
-	items = append(items, duplicateMeta)
-
-	// Write back to metadata file
-	updatedData, err := json.MarshalIndent(items, "", "  ")
-	if err != nil {
-		t.Fatalf("failed to marshal metadata: %v", err)
-	}
-	if err := os.WriteFile(metadataPath, updatedData, 0644); err != nil {
-		t.Fatalf("failed to write metadata file: %v", err)
-	}
+	})
 
-	// Note: Can't reload manager due to cache lock. Instead, test that merge would work
-	// by verifying the duplicate exists in the metadata file.
-	// For a full test, we'd need a way to reload the index or close the manager.
-	// For now, we'll test the merge logic with files that are already in memory.
-	
-	// Since we can't reload, let's test that the verification detects the issue
+	// Verification should detect the issue
 	verifyResult, err := manager.VerifyDeduplicationSystem()
 	if err != nil {
 		t.Fatalf("failed to verify: %v", err)
@@ -316,9 +297,9 @@ func TestMergeDuplicates(t *testing.T) {
 		t.Errorf("expected at least 2 physical files, got %d", verifyResult.PhysicalFilesCount)
 	}
 	
-	// Skip merge test for now due to reload limitation
-	// In a real scenario, the manager would be reloaded or the index would be refreshed
-	t.Skip("Skipping merge test - requires manager reload which conflicts with cache lock")
+	// The index keeps one record per hash, so the duplicate replaced the
+	// original's record and there is nothing left to merge it with.
+	t.Skip("Skipping merge test - the metadata index keeps one record per hash")
 	mergeResult, err := manager.MergeDuplicates(storage.MergeRequest{
 		Hash:         hash,
 		Keep:         result1.Metadata.StoredPath,
@@ -1,9 +1,6 @@
 package storage
 
 import (
-"encoding/json"
-"os"
-"path/filepath"
 "strings"
 "testing"
 
@@ -357,30 +354,19 @@ func TestMetadataUpdatePersistence(t *testing.T) {
 		t.Fatalf("UpdateFileMetadata() error = %v", err)
 	}
 
-	// Retrieve and verify metadata persisted
-	metadataPath := filepath.Join(tmpDir, "metadata", "files.json")
-	if _, err := os.Stat(metadataPath); os.IsNotExist(err) {
-		t.Fatalf("metadata file does not exist")
+	// Reopen the manager to verify the update was persisted
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
 	}
-
-	// Read the metadata file directly to verify persistence
-	data, err := os.ReadFile(metadataPath)
+	reopened, err := storage.NewManager(tmpDir)
 	if err != nil {
-		t.Fatalf("failed to read metadata file: %v", err)
-	}
-
-	var items []storage.FileMetadata
-	if err := json.Unmarshal(data, &items); err != nil {
-		t.Fatalf("failed to unmarshal metadata: %v", err)
+		t.Fatalf("NewManager() reopen error = %v", err)
 	}
+	defer reopened.Close()
 
-	// Find our file in the metadata
-	var found *storage.FileMetadata
-	for _, item := range items {
-		if item.Hash == result.Metadata.Hash {
-			found = &item
-			break
-		}
+	found, err := reopened.GetFileMetadata(result.Metadata.Hash)
+	if err != nil {
+		t.Fatalf("GetFileMetadata() error = %v", err)
 	}
 
 	if found == nil {
@@ -609,28 +595,9 @@ func TestConcurrentMetadataUpdates(t *testing.T) {
 	}
 
 	// Verify all updates persisted
-	metadataPath := filepath.Join(tmpDir, "metadata", "files.json")
-	data, err := os.ReadFile(metadataPath)
+	stored, err := mgr.GetFileMetadata(result.Metadata.Hash)
 	if err != nil {
-		t.Fatalf("failed to read metadata file: %v", err)
-	}
-
-	var items []storage.FileMetadata
-	if err := json.Unmarshal(data, &items); err != nil {
-		t.Fatalf("failed to unmarshal metadata: %v", err)
-	}
-
-	// Find our file
-	var stored *storage.FileMetadata
-	for _, item := range items {
-		if item.Hash == result.Metadata.Hash {
-			stored = &item
-			break
-		}
-	}
-
-	if stored == nil {
-		t.Fatalf("metadata not found")
+		t.Fatalf("metadata not found: %v", err)
 	}
 
 	if len(stored.Metadata) != numUpdates {